@@ -26,19 +26,23 @@ import (
 	"github.com/urfave/cli"
 	_ "sofastack.io/sofa-mosn/pkg/buffer"
 	_ "sofastack.io/sofa-mosn/pkg/filter/network/proxy"
+	_ "sofastack.io/sofa-mosn/pkg/filter/network/redisproxy"
 	_ "sofastack.io/sofa-mosn/pkg/filter/network/tcpproxy"
 	_ "sofastack.io/sofa-mosn/pkg/filter/stream/faultinject"
 	_ "sofastack.io/sofa-mosn/pkg/filter/stream/healthcheck/sofarpc"
 	_ "sofastack.io/sofa-mosn/pkg/filter/stream/mixer"
 	_ "sofastack.io/sofa-mosn/pkg/metrics/sink"
 	_ "sofastack.io/sofa-mosn/pkg/metrics/sink/prometheus"
+	_ "sofastack.io/sofa-mosn/pkg/metrics/sink/statsd"
 	_ "sofastack.io/sofa-mosn/pkg/network"
 	_ "sofastack.io/sofa-mosn/pkg/protocol"
+	_ "sofastack.io/sofa-mosn/pkg/protocol/grpc/conv"
 	_ "sofastack.io/sofa-mosn/pkg/protocol/http/conv"
 	_ "sofastack.io/sofa-mosn/pkg/protocol/http2/conv"
 	_ "sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc/codec"
 	_ "sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc/conv"
 	_ "sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol/tars"
+	_ "sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol/thrift"
 	_ "sofastack.io/sofa-mosn/pkg/router"
 	_ "sofastack.io/sofa-mosn/pkg/stream/http"
 	_ "sofastack.io/sofa-mosn/pkg/stream/http2"
@@ -50,6 +54,7 @@ import (
 	_ "sofastack.io/sofa-mosn/pkg/trace/sofa/http"
 	_ "sofastack.io/sofa-mosn/pkg/trace/sofa/rpc"
 	_ "sofastack.io/sofa-mosn/pkg/trace/sofa/rpc/ext"
+	_ "sofastack.io/sofa-mosn/pkg/trace/zipkin"
 )
 
 var Version = "0.4.0"
@@ -47,6 +47,13 @@ func init() {
 		"/api/v1/enable_log":      enableLogger,
 		"/api/v1/disbale_log":     disableLogger,
 		"/api/v1/states":          getState,
+		// upstream apis
+		"/api/v1/clusters":                getClusters,
+		"/api/v1/hosts/set_health_flag":   setHostHealthFlag,
+		"/api/v1/hosts/clear_health_flag": clearHostHealthFlag,
+		"/api/v1/hosts/update_weight":     updateHostWeight,
+		"/api/v1/hosts/add":               addHosts,
+		"/api/v1/hosts/remove":            removeHosts,
 	}
 }
 
@@ -0,0 +1,314 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
+)
+
+// healthFlagMap maps the health flag name used in admin api to the health flag
+var healthFlagMap = map[string]types.HealthFlag{
+	"active_hc":     types.FAILED_ACTIVE_HC,
+	"outlier_check": types.FAILED_OUTLIER_CHECK,
+	"manual_drain":  types.MANUAL_DRAIN,
+}
+
+// readonlyHealthFlags cannot be set or cleared by the admin api, they are managed by the outlier detection
+var readonlyHealthFlags = map[types.HealthFlag]bool{
+	types.FAILED_OUTLIER_CHECK: true,
+}
+
+// getClusterManager returns the cluster manager used by the admin api
+var getClusterManager = func() types.ClusterManager {
+	return cluster.GetClusterMngAdapterInstance()
+}
+
+// HostStatus is the runtime status of an upstream host
+type HostStatus struct {
+	Address           string   `json:"address"`
+	Hostname          string   `json:"hostname,omitempty"`
+	Weight            uint32   `json:"weight"`
+	Healthy           bool     `json:"healthy"`
+	HealthFlags       []string `json:"health_flags,omitempty"`
+	ActiveConnections int64    `json:"active_connections"`
+	ActiveRequests    int64    `json:"active_requests"`
+}
+
+// ClusterStatus is the runtime status of an upstream cluster
+type ClusterStatus struct {
+	Name         string       `json:"name"`
+	LbType       string       `json:"lb_type,omitempty"`
+	HealthyHosts int          `json:"healthy_hosts"`
+	Hosts        []HostStatus `json:"hosts"`
+}
+
+// HostHealthFlagData is the post data of set/clear host health flag
+// the default flag is manual_drain
+type HostHealthFlagData struct {
+	ClusterName string `json:"cluster_name"`
+	Address     string `json:"address"`
+	Flag        string `json:"flag,omitempty"`
+}
+
+// HostWeightData is the post data of update host weight
+type HostWeightData struct {
+	ClusterName string `json:"cluster_name"`
+	Address     string `json:"address"`
+	Weight      uint32 `json:"weight"`
+}
+
+// HostsData is the post data of add hosts
+type HostsData struct {
+	ClusterName string    `json:"cluster_name"`
+	Hosts       []v2.Host `json:"hosts"`
+}
+
+// HostAddressesData is the post data of remove hosts
+type HostAddressesData struct {
+	ClusterName string   `json:"cluster_name"`
+	Addresses   []string `json:"addresses"`
+}
+
+func newHostStatus(host types.Host) HostStatus {
+	status := HostStatus{
+		Address:  host.AddressString(),
+		Hostname: host.Hostname(),
+		Weight:   host.Weight(),
+		Healthy:  host.Health(),
+	}
+	for name, flag := range healthFlagMap {
+		if host.ContainHealthFlag(flag) {
+			status.HealthFlags = append(status.HealthFlags, name)
+		}
+	}
+	sort.Strings(status.HealthFlags)
+	stats := host.HostStats()
+	if stats.UpstreamConnectionActive != nil {
+		status.ActiveConnections = stats.UpstreamConnectionActive.Count()
+	}
+	if stats.UpstreamRequestActive != nil {
+		status.ActiveRequests = stats.UpstreamRequestActive.Count()
+	}
+	return status
+}
+
+func newClusterStatus(name string, snapshot types.ClusterSnapshot) ClusterStatus {
+	status := ClusterStatus{
+		Name:         name,
+		LbType:       string(snapshot.ClusterInfo().LbType()),
+		HealthyHosts: len(snapshot.HostSet().HealthyHosts()),
+		Hosts:        []HostStatus{},
+	}
+	for _, host := range snapshot.HostSet().Hosts() {
+		status.Hosts = append(status.Hosts, newHostStatus(host))
+	}
+	return status
+}
+
+// getClusterStatus returns the cluster's status, returns false if the cluster is not exists
+func getClusterStatus(cm types.ClusterManager, name string) (ClusterStatus, bool) {
+	snapshot := cm.GetClusterSnapshot(context.Background(), name)
+	if snapshot == nil {
+		return ClusterStatus{}, false
+	}
+	return newClusterStatus(name, snapshot), true
+}
+
+func writeErrorResponse(w http.ResponseWriter, code int, msg string) {
+	w.WriteHeader(code)
+	fmt.Fprintf(w, errMsgFmt, msg)
+}
+
+func writeJSONResponse(w http.ResponseWriter, api string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", api, err)
+		writeErrorResponse(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// writeClusterResponse responses the cluster's status after it is changed
+func writeClusterResponse(w http.ResponseWriter, api string, cm types.ClusterManager, name string) {
+	status, ok := getClusterStatus(cm, name)
+	if !ok {
+		writeErrorResponse(w, http.StatusNotFound, "cluster not found")
+		return
+	}
+	writeJSONResponse(w, api, status)
+}
+
+// readPostData checks the method and unmarshals the post data
+func readPostData(w http.ResponseWriter, r *http.Request, api string, data interface{}) bool {
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return false
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: read body failed, %v", api, err)
+		writeErrorResponse(w, http.StatusBadRequest, "read body error")
+		return false
+	}
+	if err := json.Unmarshal(body, data); err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid post data: %s", api, string(body))
+		writeErrorResponse(w, http.StatusBadRequest, "invalid post data")
+		return false
+	}
+	return true
+}
+
+// returns all clusters' status, or the specified cluster's status by query cluster=xxx
+func getClusters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "get clusters", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	cm := getClusterManager()
+	if name := r.URL.Query().Get("cluster"); name != "" {
+		writeClusterResponse(w, "get clusters", cm, name)
+		return
+	}
+	clusters := []ClusterStatus{}
+	for _, name := range cm.ClusterNames() {
+		// the cluster maybe removed
+		if status, ok := getClusterStatus(cm, name); ok {
+			clusters = append(clusters, status)
+		}
+	}
+	writeJSONResponse(w, "get clusters", clusters)
+}
+
+func setHostHealthFlag(w http.ResponseWriter, r *http.Request) {
+	updateHostHealthFlag(w, r, "set host health flag", true)
+}
+
+func clearHostHealthFlag(w http.ResponseWriter, r *http.Request) {
+	updateHostHealthFlag(w, r, "clear host health flag", false)
+}
+
+func updateHostHealthFlag(w http.ResponseWriter, r *http.Request, api string, set bool) {
+	data := &HostHealthFlagData{}
+	if !readPostData(w, r, api, data) {
+		return
+	}
+	if data.Flag == "" {
+		data.Flag = "manual_drain"
+	}
+	flag, ok := healthFlagMap[data.Flag]
+	if !ok {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: unknown health flag: %s", api, data.Flag)
+		writeErrorResponse(w, http.StatusBadRequest, "unknown health flag")
+		return
+	}
+	if readonlyHealthFlags[flag] {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: health flag %s is readonly", api, data.Flag)
+		writeErrorResponse(w, http.StatusBadRequest, "readonly health flag")
+		return
+	}
+	cm := getClusterManager()
+	var err error
+	if set {
+		err = cm.SetHostHealthFlag(data.ClusterName, data.Address, flag)
+	} else {
+		err = cm.ClearHostHealthFlag(data.ClusterName, data.Address, flag)
+	}
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", api, err)
+		writeErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.DefaultLogger.Infof("[admin api] [%s] cluster %s host %s health flag %s", api, data.ClusterName, data.Address, data.Flag)
+	writeClusterResponse(w, api, cm, data.ClusterName)
+}
+
+func updateHostWeight(w http.ResponseWriter, r *http.Request) {
+	data := &HostWeightData{}
+	if !readPostData(w, r, "update host weight", data) {
+		return
+	}
+	if data.Weight < config.MinHostWeight || data.Weight > config.MaxHostWeight {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid weight: %d", "update host weight", data.Weight)
+		writeErrorResponse(w, http.StatusBadRequest, "invalid weight")
+		return
+	}
+	cm := getClusterManager()
+	if err := cm.UpdateHostWeight(data.ClusterName, data.Address, data.Weight); err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "update host weight", err)
+		writeErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.DefaultLogger.Infof("[admin api] [update host weight] cluster %s host %s weight %d", data.ClusterName, data.Address, data.Weight)
+	writeClusterResponse(w, "update host weight", cm, data.ClusterName)
+}
+
+func addHosts(w http.ResponseWriter, r *http.Request) {
+	data := &HostsData{}
+	if !readPostData(w, r, "add hosts", data) {
+		return
+	}
+	for i := range data.Hosts {
+		host := &data.Hosts[i]
+		if host.Weight == 0 {
+			host.Weight = config.MinHostWeight
+		}
+		if host.Address == "" || host.Weight > config.MaxHostWeight {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid host: %v", "add hosts", host)
+			writeErrorResponse(w, http.StatusBadRequest, "invalid host")
+			return
+		}
+	}
+	cm := getClusterManager()
+	if err := cm.AppendClusterHosts(data.ClusterName, data.Hosts); err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "add hosts", err)
+		writeErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.DefaultLogger.Infof("[admin api] [add hosts] cluster %s add %d hosts", data.ClusterName, len(data.Hosts))
+	writeClusterResponse(w, "add hosts", cm, data.ClusterName)
+}
+
+func removeHosts(w http.ResponseWriter, r *http.Request) {
+	data := &HostAddressesData{}
+	if !readPostData(w, r, "remove hosts", data) {
+		return
+	}
+	cm := getClusterManager()
+	if err := cm.RemoveClusterHosts(data.ClusterName, data.Addresses); err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", "remove hosts", err)
+		writeErrorResponse(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.DefaultLogger.Infof("[admin api] [remove hosts] cluster %s remove hosts %v", data.ClusterName, data.Addresses)
+	writeClusterResponse(w, "remove hosts", cm, data.ClusterName)
+}
@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
+)
+
+func createTestClusterManager() types.ClusterManager {
+	return cluster.NewClusterManagerSingleton([]v2.Cluster{
+		{
+			Name:   "test_cluster",
+			LbType: v2.LB_ROUNDROBIN,
+		},
+	}, map[string][]v2.Host{
+		"test_cluster": {
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000", Weight: 1}},
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10001", Weight: 1}},
+		},
+	})
+}
+
+func callUpstreamAPI(handler http.HandlerFunc, method string, url string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func parseClusterStatus(t *testing.T, w *httptest.ResponseRecorder) ClusterStatus {
+	if w.Code != http.StatusOK {
+		t.Fatalf("response status is not ok: %d, %s", w.Code, w.Body.String())
+	}
+	status := ClusterStatus{}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unmarshal cluster status failed: %v", err)
+	}
+	return status
+}
+
+func findHostStatus(status ClusterStatus, addr string) *HostStatus {
+	for i := range status.Hosts {
+		if status.Hosts[i].Address == addr {
+			return &status.Hosts[i]
+		}
+	}
+	return nil
+}
+
+func TestGetClusters(t *testing.T) {
+	cm := createTestClusterManager()
+	defer cm.Destroy()
+
+	w := callUpstreamAPI(getClusters, http.MethodGet, "/api/v1/clusters", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("get clusters failed: %d", w.Code)
+	}
+	clusters := []ClusterStatus{}
+	if err := json.Unmarshal(w.Body.Bytes(), &clusters); err != nil {
+		t.Fatalf("unmarshal clusters failed: %v", err)
+	}
+	if len(clusters) != 1 || clusters[0].Name != "test_cluster" || len(clusters[0].Hosts) != 2 || clusters[0].HealthyHosts != 2 {
+		t.Fatalf("get clusters unexpected: %s", w.Body.String())
+	}
+	// query a cluster
+	status := parseClusterStatus(t, callUpstreamAPI(getClusters, http.MethodGet, "/api/v1/clusters?cluster=test_cluster", ""))
+	if status.LbType != string(v2.LB_ROUNDROBIN) {
+		t.Errorf("cluster lb type unexpected: %s", status.LbType)
+	}
+	if host := findHostStatus(status, "127.0.0.1:10000"); host == nil || !host.Healthy || host.Weight != 1 {
+		t.Errorf("host status unexpected: %v", host)
+	}
+	if w := callUpstreamAPI(getClusters, http.MethodGet, "/api/v1/clusters?cluster=not_exists", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get a not exists cluster expected 404, but got %d", w.Code)
+	}
+	if w := callUpstreamAPI(getClusters, http.MethodPost, "/api/v1/clusters", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("invalid method expected 405, but got %d", w.Code)
+	}
+}
+
+func TestHostHealthFlag(t *testing.T) {
+	cm := createTestClusterManager()
+	defer cm.Destroy()
+
+	// default flag is manual drain
+	status := parseClusterStatus(t, callUpstreamAPI(setHostHealthFlag, http.MethodPost, "/api/v1/hosts/set_health_flag",
+		`{"cluster_name":"test_cluster","address":"127.0.0.1:10000"}`))
+	host := findHostStatus(status, "127.0.0.1:10000")
+	if host == nil || host.Healthy || len(host.HealthFlags) != 1 || host.HealthFlags[0] != "manual_drain" {
+		t.Fatalf("host status unexpected after drained: %v", host)
+	}
+	if status.HealthyHosts != 1 {
+		t.Errorf("healthy hosts unexpected: %d", status.HealthyHosts)
+	}
+	status = parseClusterStatus(t, callUpstreamAPI(clearHostHealthFlag, http.MethodPost, "/api/v1/hosts/clear_health_flag",
+		`{"cluster_name":"test_cluster","address":"127.0.0.1:10000","flag":"manual_drain"}`))
+	if host := findHostStatus(status, "127.0.0.1:10000"); host == nil || !host.Healthy || status.HealthyHosts != 2 {
+		t.Fatalf("host status unexpected after clear the flag: %v", host)
+	}
+	// invalid requests
+	for _, tc := range []struct {
+		method string
+		body   string
+		code   int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "invalid", http.StatusBadRequest},
+		{http.MethodPost, `{"cluster_name":"test_cluster","address":"127.0.0.1:10000","flag":"unknown"}`, http.StatusBadRequest},
+		// outlier check flag is managed by the outlier detection
+		{http.MethodPost, `{"cluster_name":"test_cluster","address":"127.0.0.1:10000","flag":"outlier_check"}`, http.StatusBadRequest},
+		{http.MethodPost, `{"cluster_name":"test_cluster","address":"127.0.0.1:9999"}`, http.StatusNotFound},
+	} {
+		if w := callUpstreamAPI(setHostHealthFlag, tc.method, "/api/v1/hosts/set_health_flag", tc.body); w.Code != tc.code {
+			t.Errorf("request %s expected %d, but got %d", tc.body, tc.code, w.Code)
+		}
+	}
+}
+
+func TestUpdateHostWeight(t *testing.T) {
+	cm := createTestClusterManager()
+	defer cm.Destroy()
+
+	status := parseClusterStatus(t, callUpstreamAPI(updateHostWeight, http.MethodPost, "/api/v1/hosts/update_weight",
+		`{"cluster_name":"test_cluster","address":"127.0.0.1:10001","weight":50}`))
+	if host := findHostStatus(status, "127.0.0.1:10001"); host == nil || host.Weight != 50 {
+		t.Fatalf("host weight is not updated: %v", host)
+	}
+	if w := callUpstreamAPI(updateHostWeight, http.MethodPost, "/api/v1/hosts/update_weight",
+		`{"cluster_name":"test_cluster","address":"127.0.0.1:10001","weight":0}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid weight expected 400, but got %d", w.Code)
+	}
+	if w := callUpstreamAPI(updateHostWeight, http.MethodPost, "/api/v1/hosts/update_weight",
+		`{"cluster_name":"not_exists","address":"127.0.0.1:10001","weight":10}`); w.Code != http.StatusNotFound {
+		t.Errorf("not exists cluster expected 404, but got %d", w.Code)
+	}
+}
+
+func TestAddAndRemoveHosts(t *testing.T) {
+	cm := createTestClusterManager()
+	defer cm.Destroy()
+
+	status := parseClusterStatus(t, callUpstreamAPI(addHosts, http.MethodPost, "/api/v1/hosts/add",
+		`{"cluster_name":"test_cluster","hosts":[{"address":"127.0.0.1:10002","hostname":"new_host"}]}`))
+	if len(status.Hosts) != 3 {
+		t.Fatalf("add hosts failed: %v", status)
+	}
+	if host := findHostStatus(status, "127.0.0.1:10002"); host == nil || host.Hostname != "new_host" || host.Weight != 1 {
+		t.Fatalf("added host status unexpected: %v", host)
+	}
+	status = parseClusterStatus(t, callUpstreamAPI(removeHosts, http.MethodPost, "/api/v1/hosts/remove",
+		`{"cluster_name":"test_cluster","addresses":["127.0.0.1:10000","127.0.0.1:10002"]}`))
+	if len(status.Hosts) != 1 || status.Hosts[0].Address != "127.0.0.1:10001" {
+		t.Fatalf("remove hosts failed: %v", status)
+	}
+	if w := callUpstreamAPI(addHosts, http.MethodPost, "/api/v1/hosts/add",
+		`{"cluster_name":"test_cluster","hosts":[{"weight":1}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid host expected 400, but got %d", w.Code)
+	}
+	if w := callUpstreamAPI(removeHosts, http.MethodPost, "/api/v1/hosts/remove",
+		`{"cluster_name":"not_exists","addresses":["127.0.0.1:10001"]}`); w.Code != http.StatusNotFound {
+		t.Errorf("not exists cluster expected 404, but got %d", w.Code)
+	}
+}
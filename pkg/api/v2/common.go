@@ -83,3 +83,5 @@ func (d DurationConfig) MarshalJSON() (b []byte, err error) {
 var ErrDuplicateTLSConfig = errors.New("tls_context and tls_context_set can only exists one at the same time")
 
 var ErrDuplicateStaticAndDynamic = errors.New("only one of static config or dynamic config should be exists")
+
+var ErrPerHostThresholdsPriority = errors.New("per_host_thresholds only supports the default priority")
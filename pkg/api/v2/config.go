@@ -29,12 +29,27 @@ type HealthCheckConfig struct {
 	CommonCallbacks      []string               `json:"common_callbacks,omitempty"` // HealthCheck support register some common callbacks that are not related to specific cluster
 }
 
+type OutlierDetectionConfig struct {
+	Consecutive5xx            uint32         `json:"consecutive_5xx,omitempty"`
+	ConsecutiveConnectFailure uint32         `json:"consecutive_connect_failure,omitempty"`
+	IntervalConfig            DurationConfig `json:"interval,omitempty"`
+	BaseEjectionTimeConfig    DurationConfig `json:"base_ejection_time,omitempty"`
+	MaxEjectionTimeConfig     DurationConfig `json:"max_ejection_time,omitempty"`
+	MaxEjectionPercent        uint32         `json:"max_ejection_percent,omitempty"`
+	SuccessRateMinimumHosts   uint32         `json:"success_rate_minimum_hosts,omitempty"`
+	SuccessRateRequestVolume  uint32         `json:"success_rate_request_volume,omitempty"`
+	SuccessRateStdevFactor    uint32         `json:"success_rate_stdev_factor,omitempty"` // the factor is divided by 1000, 1900 means 1.9
+}
+
 type HostConfig struct {
 	Address        string          `json:"address,omitempty"`
 	Hostname       string          `json:"hostname,omitempty"`
 	Weight         uint32          `json:"weight,omitempty"`
 	MetaDataConfig *MetadataConfig `json:"metadata,omitempty"`
 	TLSDisable     bool            `json:"tls_disable,omitempty"`
+	Locality       *Locality       `json:"locality,omitempty"`
+	Priority       uint32          `json:"priority,omitempty"`
+	LocalityWeight uint32          `json:"locality_weight,omitempty"`
 }
 
 // ListenerType: Ingress or Egress
@@ -49,8 +64,9 @@ type ListenerConfig struct {
 	AddrConfig            string          `json:"address,omitempty"`
 	BindToPort            bool            `json:"bind_port,omitempty"`
 	UseOriginalDst        bool            `json:"use_original_dst,omitempty"`
+	UseProxyProtocol      bool            `json:"use_proxy_protocol,omitempty"` // parse the PROXY protocol header on accepted connections
 	AccessLogs            []AccessLog     `json:"access_logs,omitempty"`
-	FilterChains          []FilterChain   `json:"filter_chains,omitempty"` // only one filterchains at this time
+	FilterChains          []FilterChain   `json:"filter_chains,omitempty"` // filter chain is chosen by filter_chain_match
 	StreamFilters         []Filter        `json:"stream_filters,omitempty"`
 	Inspector             bool            `json:"inspector,omitempty"`
 	ConnectionIdleTimeout *DurationConfig `json:"connection_idle_timeout,omitempty"`
@@ -89,9 +105,11 @@ type RouterConfigurationConfig struct {
 }
 
 type RouterConfig struct {
+	Name            string                 `json:"name,omitempty"`
 	Match           RouterMatch            `json:"match,omitempty"`
 	Route           RouteAction            `json:"route,omitempty"`
 	DirectResponse  *DirectResponseAction  `json:"direct_response,omitempty"`
+	Redirect        *RedirectAction        `json:"redirect,omitempty"`
 	MetadataConfig  *MetadataConfig        `json:"metadata,omitempty"`
 	PerFilterConfig map[string]interface{} `json:"per_filter_config,omitempty"`
 }
@@ -105,11 +123,15 @@ type RouterActionConfig struct {
 	TimeoutConfig           DurationConfig       `json:"timeout,omitempty"`
 	RetryPolicy             *RetryPolicy         `json:"retry_policy,omitempty"`
 	PrefixRewrite           string               `json:"prefix_rewrite,omitempty"`
+	RegexRewrite            *RegexRewrite        `json:"regex_rewrite,omitempty"`
 	HostRewrite             string               `json:"host_rewrite,omitempty"`
 	AutoHostRewrite         bool                 `json:"auto_host_rewrite,omitempty"`
 	RequestHeadersToAdd     []*HeaderValueOption `json:"request_headers_to_add,omitempty"`
 	ResponseHeadersToAdd    []*HeaderValueOption `json:"response_headers_to_add,omitempty"`
 	ResponseHeadersToRemove []string             `json:"response_headers_to_remove,omitempty"`
+	HashPolicy              []HashPolicy         `json:"hash_policy,omitempty"`
+	ShadowPolicy            *ShadowPolicy        `json:"shadow_policy,omitempty"`
+	Priority                RoutingPriority      `json:"priority,omitempty"`
 }
 
 type ClusterWeightConfig struct {
@@ -119,14 +141,52 @@ type ClusterWeightConfig struct {
 }
 
 type RetryPolicyConfig struct {
-	RetryOn            bool           `json:"retry_on,omitempty"`
-	RetryTimeoutConfig DurationConfig `json:"retry_timeout,omitempty"`
-	NumRetries         uint32         `json:"num_retries,omitempty"`
+	RetryOn                       bool                `json:"retry_on,omitempty"`
+	RetryTimeoutConfig            DurationConfig      `json:"retry_timeout,omitempty"`
+	NumRetries                    uint32              `json:"num_retries,omitempty"`
+	RetryConditions               []RetryCondition    `json:"retry_conditions,omitempty"`
+	RetriableStatusCodes          []uint32            `json:"retriable_status_codes,omitempty"`
+	RetriableSofaRpcStatus        []uint32            `json:"retriable_sofarpc_status,omitempty"`
+	RetryBackOff                  *RetryBackOffConfig `json:"retry_back_off,omitempty"`
+	HostSelectionRetryMaxAttempts uint32              `json:"host_selection_retry_max_attempts,omitempty"`
+	RetryBudget                   *RetryBudgetConfig  `json:"retry_budget,omitempty"`
+}
+
+// RetryBackOffConfig is the exponential back off with jitter between retries
+type RetryBackOffConfig struct {
+	BaseIntervalConfig DurationConfig `json:"base_interval,omitempty"`
+	MaxIntervalConfig  DurationConfig `json:"max_interval,omitempty"`
+}
+
+// RetryBudgetConfig limits the concurrent retries of a route to a percentage of the active requests
+type RetryBudgetConfig struct {
+	BudgetPercent       float64 `json:"budget_percent,omitempty"`
+	MinRetryConcurrency uint32  `json:"min_retry_concurrency,omitempty"`
 }
 
 type FilterChainConfig struct {
-	FilterChainMatch string      `json:"match,omitempty"`
-	TLSConfig        *TLSConfig  `json:"tls_context,omitempty"`
-	TLSConfigs       []TLSConfig `json:"tls_context_set,omitempty"`
-	Filters          []Filter    `json:"filters,omitempty"`
+	FilterChainMatch string                  `json:"match,omitempty"`
+	Match            *FilterChainMatchConfig `json:"filter_chain_match,omitempty"`
+	TLSConfig        *TLSConfig              `json:"tls_context,omitempty"`
+	TLSConfigs       []TLSConfig             `json:"tls_context_set,omitempty"`
+	Filters          []Filter                `json:"filters,omitempty"`
+	// StreamFilters overrides the listener's stream filters if it is not empty
+	StreamFilters []Filter `json:"stream_filters,omitempty"`
+}
+
+// FilterChainMatchConfig is the conditions to choose a filter chain for a connection.
+// A filter chain is chosen if all the conditions configured are matched.
+type FilterChainMatchConfig struct {
+	// DestinationPort matches the destination port, or the original destination port
+	DestinationPort uint32 `json:"destination_port,omitempty"`
+	// PrefixRanges matches the destination ip, in CIDR format such as 10.0.0.0/8
+	PrefixRanges []string `json:"prefix_ranges,omitempty"`
+	// SourcePrefixRanges matches the source ip, in CIDR format
+	SourcePrefixRanges []string `json:"source_prefix_ranges,omitempty"`
+	// ServerNames matches the tls SNI, supports wildcard such as *.example.com
+	ServerNames []string `json:"server_names,omitempty"`
+	// TransportProtocol can be "tls" or "raw_buffer"
+	TransportProtocol string `json:"transport_protocol,omitempty"`
+	// ApplicationProtocols matches the tls ALPN
+	ApplicationProtocols []string `json:"application_protocols,omitempty"`
 }
@@ -416,6 +416,58 @@ func TestCircuitBreakersUnmarshal(t *testing.T) {
 	}
 }
 
+func TestCircuitBreakersPerHost(t *testing.T) {
+	cfgStr := `{
+		"thresholds": [
+			{
+				"priority": "HIGH",
+				"max_requests": 1024
+			}
+		],
+		"per_host_thresholds": [
+			{
+				"max_connections": 10,
+				"max_requests": 100
+			}
+		]
+	}`
+	cb := &CircuitBreakers{}
+	if err := json.Unmarshal([]byte(cfgStr), cb); err != nil {
+		t.Fatal(err)
+	}
+	if !(len(cb.Thresholds) == 1 &&
+		cb.Thresholds[0].Priority == PRIORITY_HIGH &&
+		cb.Thresholds[0].MaxRequests == 1024 &&
+		len(cb.PerHostThresholds) == 1 &&
+		cb.PerHostThresholds[0].MaxConnections == 10 &&
+		cb.PerHostThresholds[0].MaxRequests == 100) {
+		t.Fatalf("unmarshal unexpected %v", cb)
+	}
+	b, err := json.Marshal(cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ncb := &CircuitBreakers{}
+	if err := json.Unmarshal(b, ncb); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cb, ncb) {
+		t.Errorf("marshal and unmarshal not equal, %v, %v", cb, ncb)
+	}
+	// per host thresholds only supports the default priority
+	highStr := `{
+		"per_host_thresholds": [
+			{
+				"priority": "HIGH",
+				"max_connections": 10
+			}
+		]
+	}`
+	if err := json.Unmarshal([]byte(highStr), &CircuitBreakers{}); err == nil {
+		t.Error("per host thresholds with high priority should be rejected")
+	}
+}
+
 func TestFilterChainMarshal(t *testing.T) {
 	filterChain := &FilterChain{
 		TLSContexts: []TLSConfig{
@@ -18,11 +18,13 @@
 package v2
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
@@ -40,6 +42,7 @@ const (
 	CONNECTION_MANAGER          = "connection_manager"
 	DEFAULT_NETWORK_FILTER      = "proxy"
 	TCP_PROXY                   = "tcp_proxy"
+	REDIS_PROXY                 = "redis_proxy"
 	FAULT_INJECT_NETWORK_FILTER = "fault_inject"
 	RPC_PROXY                   = "rpc_proxy"
 	X_PROXY                     = "x_proxy"
@@ -67,25 +70,53 @@ type LbType string
 
 // Group of load balancer type
 const (
-	LB_RANDOM     LbType = "LB_RANDOM"
-	LB_ROUNDROBIN LbType = "LB_ROUNDROBIN"
+	LB_RANDOM              LbType = "LB_RANDOM"
+	LB_ROUNDROBIN          LbType = "LB_ROUNDROBIN"
+	LB_WEIGHTED_ROUNDROBIN LbType = "LB_WEIGHTED_ROUNDROBIN"
+	LB_LEAST_REQUEST       LbType = "LB_LEAST_REQUEST"
+	LB_RING_HASH           LbType = "LB_RING_HASH"
+	LB_MAGLEV              LbType = "LB_MAGLEV"
+	LB_LOCALITY_AWARE      LbType = "LB_LOCALITY_AWARE"
+)
+
+// RoutingPriority
+type RoutingPriority string
+
+// Group of routing priority
+const (
+	PRIORITY_DEFAULT RoutingPriority = "DEFAULT"
+	PRIORITY_HIGH    RoutingPriority = "HIGH"
 )
 
 // Cluster represents a cluster's information
 type Cluster struct {
-	Name                 string          `json:"name,omitempty"`
-	ClusterType          ClusterType     `json:"type,omitempty"`
-	SubType              string          `json:"sub_type,omitempty"` //not used yet
-	LbType               LbType          `json:"lb_type,omitempty"`
-	MaxRequestPerConn    uint32          `json:"max_request_per_conn,omitempty"`
-	ConnBufferLimitBytes uint32          `json:"conn_buffer_limit_bytes,omitempty"`
-	CirBreThresholds     CircuitBreakers `json:"circuit_breakers,omitempty"`
-	HealthCheck          HealthCheck     `json:"health_check,omitempty"`
-	Spec                 ClusterSpecInfo `json:"spec,omitempty"`
-	LBSubSetConfig       LBSubsetConfig  `json:"lb_subset_config,omitempty"`
-	TLS                  TLSConfig       `json:"tls_context,omitempty"`
-	Hosts                []Host          `json:"hosts,omitempty"`
-}
+	Name                 string            `json:"name,omitempty"`
+	ClusterType          ClusterType       `json:"type,omitempty"`
+	SubType              string            `json:"sub_type,omitempty"` //not used yet
+	LbType               LbType            `json:"lb_type,omitempty"`
+	MaxRequestPerConn    uint32            `json:"max_request_per_conn,omitempty"`
+	ConnBufferLimitBytes uint32            `json:"conn_buffer_limit_bytes,omitempty"`
+	CirBreThresholds     CircuitBreakers   `json:"circuit_breakers,omitempty"`
+	HealthCheck          HealthCheck       `json:"health_check,omitempty"`
+	OutlierDetection     *OutlierDetection `json:"outlier_detection,omitempty"`
+	Spec                 ClusterSpecInfo   `json:"spec,omitempty"`
+	LBSubSetConfig       LBSubsetConfig    `json:"lb_subset_config,omitempty"`
+	TLS                  TLSConfig         `json:"tls_context,omitempty"`
+	Hosts                []Host            `json:"hosts,omitempty"`
+	// OverprovisioningFactor is used by the locality aware load balancer, in percentage, default is 140
+	OverprovisioningFactor uint32 `json:"overprovisioning_factor,omitempty"`
+	// ProxyProtocol prepends a PROXY protocol header on the upstream connections, only plaintext connections are supported
+	ProxyProtocol ProxyProtocolVersion `json:"proxy_protocol,omitempty"`
+}
+
+// ProxyProtocolVersion is the PROXY protocol version
+type ProxyProtocolVersion string
+
+// Group of ProxyProtocolVersion
+const (
+	ProxyProtocolV1 ProxyProtocolVersion = "v1"
+	ProxyProtocolV2 ProxyProtocolVersion = "v2"
+)
 
 // HealthCheck is a configuration of health check
 // use DurationConfig to parse string to time.Duration
@@ -114,6 +145,40 @@ func (hc *HealthCheck) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// OutlierDetection is a configuration of passive outlier detection
+// use DurationConfig to parse string to time.Duration
+type OutlierDetection struct {
+	OutlierDetectionConfig
+	Interval         time.Duration `json:"-"`
+	BaseEjectionTime time.Duration `json:"-"`
+	MaxEjectionTime  time.Duration `json:"-"`
+}
+
+// Marshal implement a json.Marshaler
+func (od OutlierDetection) MarshalJSON() (b []byte, err error) {
+	od.OutlierDetectionConfig.IntervalConfig.Duration = od.Interval
+	od.OutlierDetectionConfig.BaseEjectionTimeConfig.Duration = od.BaseEjectionTime
+	od.OutlierDetectionConfig.MaxEjectionTimeConfig.Duration = od.MaxEjectionTime
+	return json.Marshal(od.OutlierDetectionConfig)
+}
+
+func (od *OutlierDetection) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &od.OutlierDetectionConfig); err != nil {
+		return err
+	}
+	od.Interval = od.IntervalConfig.Duration
+	od.BaseEjectionTime = od.BaseEjectionTimeConfig.Duration
+	od.MaxEjectionTime = od.MaxEjectionTimeConfig.Duration
+	return nil
+}
+
+// Locality represents the deploy locality of an upstream host or the local mosn
+type Locality struct {
+	Region  string `json:"region,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	SubZone string `json:"sub_zone,omitempty"`
+}
+
 // Host represenets a host information
 type Host struct {
 	HostConfig
@@ -301,6 +366,27 @@ func (cw *ClusterWeight) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// RetryCondition is the condition to retry an upstream request
+type RetryCondition string
+
+// Group of retry condition
+const (
+	// retry on 5xx response, connection failure, reset and timeout
+	RETRY_ON_5XX RetryCondition = "5xx"
+	// retry on 502, 503 and 504 response
+	RETRY_ON_GATEWAY_ERROR RetryCondition = "gateway-error"
+	// retry on connection failure
+	RETRY_ON_CONNECT_FAILURE RetryCondition = "connect-failure"
+	// retry on stream refused by upstream, such as http2 REFUSED_STREAM
+	RETRY_ON_REFUSED_STREAM RetryCondition = "refused-stream"
+	// retry on the response status code in RetriableStatusCodes
+	RETRY_ON_RETRIABLE_STATUS_CODES RetryCondition = "retriable-status-codes"
+	// retry on the sofarpc response status in RetriableSofaRpcStatus
+	RETRY_ON_RETRIABLE_SOFARPC_STATUS RetryCondition = "retriable-sofarpc-status"
+	// retry on no response from upstream, such as connection failure, reset and timeout
+	RETRY_ON_RESET RetryCondition = "reset"
+)
+
 // RetryPolicy represents the retry parameters
 type RetryPolicy struct {
 	RetryPolicyConfig
@@ -322,23 +408,55 @@ func (rp *RetryPolicy) UnmarshalJSON(b []byte) error {
 
 // CircuitBreakers is a configuration of circuit breakers
 // CircuitBreakers implements json.Marshaler and json.Unmarshaler
+// Thresholds are the cluster's thresholds of each routing priority,
+// PerHostThresholds limits the connections and requests of each host, zero means no limit.
+// PerHostThresholds is not routing priority aware, so only the default priority is allowed.
 type CircuitBreakers struct {
-	Thresholds []Thresholds
+	Thresholds        []Thresholds
+	PerHostThresholds []Thresholds
+}
+
+// circuitBreakersConfig is the object format of circuit breakers configuration,
+// the array format contains the thresholds only, and is still supported.
+type circuitBreakersConfig struct {
+	Thresholds        []Thresholds `json:"thresholds,omitempty"`
+	PerHostThresholds []Thresholds `json:"per_host_thresholds,omitempty"`
 }
 
 // CircuitBreakers's implements json.Marshaler and json.Unmarshaler
 func (cb CircuitBreakers) MarshalJSON() (b []byte, err error) {
-	return json.Marshal(cb.Thresholds)
+	if len(cb.PerHostThresholds) == 0 {
+		return json.Marshal(cb.Thresholds)
+	}
+	return json.Marshal(circuitBreakersConfig{
+		Thresholds:        cb.Thresholds,
+		PerHostThresholds: cb.PerHostThresholds,
+	})
 }
 func (cb *CircuitBreakers) UnmarshalJSON(b []byte) (err error) {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		cfg := circuitBreakersConfig{}
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return err
+		}
+		for _, thresholds := range cfg.PerHostThresholds {
+			if thresholds.Priority != "" && !strings.EqualFold(string(thresholds.Priority), string(PRIORITY_DEFAULT)) {
+				return ErrPerHostThresholdsPriority
+			}
+		}
+		cb.Thresholds = cfg.Thresholds
+		cb.PerHostThresholds = cfg.PerHostThresholds
+		return nil
+	}
 	return json.Unmarshal(b, &cb.Thresholds)
 }
 
 type Thresholds struct {
-	MaxConnections     uint32 `json:"max_connections,omitempty"`
-	MaxPendingRequests uint32 `json:"max_pending_requests,omitempty"`
-	MaxRequests        uint32 `json:"max_requests,omitempty"`
-	MaxRetries         uint32 `json:"max_retries,omitempty"`
+	Priority           RoutingPriority `json:"priority,omitempty"`
+	MaxConnections     uint32          `json:"max_connections,omitempty"`
+	MaxPendingRequests uint32          `json:"max_pending_requests,omitempty"`
+	MaxRequests        uint32          `json:"max_requests,omitempty"`
+	MaxRetries         uint32          `json:"max_retries,omitempty"`
 }
 
 // ClusterSpecInfo is a configuration of subscribe
@@ -395,6 +513,30 @@ func (c *SdsConfig) Valid() bool {
 type AccessLog struct {
 	Path   string `json:"log_path,omitempty"`
 	Format string `json:"log_format,omitempty"`
+	// JSONFormat makes the access log in json, the key is the json field's name,
+	// and the value is the field's format, such as "%StartTime%"
+	JSONFormat map[string]string `json:"log_json_format,omitempty"`
+	// Filters decide whether a request is logged, a request is logged only if all of the filters pass
+	Filters []Filter `json:"log_filters,omitempty"`
+	// GRPCService streams the access log to a collector, the log_path is used as the local file fallback
+	GRPCService *GRPCAccessLog `json:"grpc_service,omitempty"`
+}
+
+// GRPCAccessLog streams the access log to a collector by the envoy access log service(ALS) protocol
+type GRPCAccessLog struct {
+	// LogName identifies the access log in the collector
+	LogName string `json:"log_name,omitempty"`
+	// Address is the collector's address, such as "127.0.0.1:9001"
+	Address                        string   `json:"address,omitempty"`
+	AdditionalRequestHeadersToLog  []string `json:"additional_request_headers_to_log,omitempty"`
+	AdditionalResponseHeadersToLog []string `json:"additional_response_headers_to_log,omitempty"`
+	// QueueSize limits the entries waiting to be sent, the entries are written to the local file if the queue is full
+	QueueSize             int            `json:"queue_size,omitempty"`
+	BatchSize             int            `json:"batch_size,omitempty"`
+	FlushInterval         DurationConfig `json:"flush_interval,omitempty"`
+	ConnectTimeout        DurationConfig `json:"connect_timeout,omitempty"`
+	ReconnectBaseInterval DurationConfig `json:"reconnect_base_interval,omitempty"`
+	ReconnectMaxInterval  DurationConfig `json:"reconnect_max_interval,omitempty"`
 }
 
 // FilterChain wraps a set of match criteria, an option TLS context,
@@ -449,11 +591,24 @@ type TCPProxy struct {
 	Routes             []*TCPRoute    `json:"routes,omitempty"`
 }
 
-// WebSocketProxy
+// RedisProxy proxies the redis commands to the shards of a cluster by the keys
+type RedisProxy struct {
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	Cluster    string `json:"cluster,omitempty"`
+}
+
+// WebSocketProxy configs the tunnel of the upgraded connections, such as WebSocket
 type WebSocketProxy struct {
-	StatPrefix         string
-	IdleTimeout        *time.Duration
-	MaxConnectAttempts uint32
+	IdleTimeout *DurationConfig `json:"idle_timeout,omitempty"`
+}
+
+// StreamingProxy configs the streaming of request and response bodies, the body pieces are
+// passed through as they arrive instead of being buffered until the message ends.
+// The stream that sends the body is read disabled while the bytes waiting to be forwarded
+// are above the high watermark, and enabled again once they drop below the low watermark.
+type StreamingProxy struct {
+	HighWatermark uint32 `json:"high_watermark,omitempty"`
+	LowWatermark  uint32 `json:"low_watermark,omitempty"`
 }
 
 // Proxy
@@ -463,7 +618,12 @@ type Proxy struct {
 	UpstreamProtocol   string                 `json:"upstream_protocol,omitempty"`
 	RouterConfigName   string                 `json:"router_config_name,omitempty"`
 	ValidateClusters   bool                   `json:"validate_clusters,omitempty"`
+	WebSocketConfig    *WebSocketProxy        `json:"websocket_config,omitempty"`
 	ExtendConfig       map[string]interface{} `json:"extend_config,omitempty"`
+	// ProtocolDetectTimeout is the time waiting for the first bytes when the downstream protocol is Auto
+	ProtocolDetectTimeout *DurationConfig `json:"protocol_detect_timeout,omitempty"`
+	// StreamingConfig enables streaming the bodies if it is set
+	StreamingConfig *StreamingProxy `json:"streaming_config,omitempty"`
 }
 
 // HeaderValueOption is header name/value pair plus option to control append behavior.
@@ -585,6 +745,62 @@ type DirectResponseAction struct {
 	Body       string `json:"body,omitempty"`
 }
 
+// RedirectAction represents the redirect response parameters
+// The redirect location is built from the request's scheme, host, path and query string,
+// each part can be replaced. PathRedirect replaces the whole path, PrefixRewrite replaces the matched prefix.
+type RedirectAction struct {
+	ResponseCode   int    `json:"response_code,omitempty"` // 301, 302, 303, 307 or 308, default is 301
+	SchemeRedirect string `json:"scheme_redirect,omitempty"`
+	HostRedirect   string `json:"host_redirect,omitempty"`
+	PathRedirect   string `json:"path_redirect,omitempty"`
+	PrefixRewrite  string `json:"prefix_rewrite,omitempty"`
+	StripQuery     bool   `json:"strip_query,omitempty"`
+}
+
+// RegexRewrite rewrites the request path by the regex pattern, the matched parts are replaced by the substitution.
+// The substitution can use the capture groups, such as \1 or ${1}
+type RegexRewrite struct {
+	Pattern      string `json:"pattern,omitempty"`
+	Substitution string `json:"substitution,omitempty"`
+}
+
+// HashPolicy specifies how to generate the hash key for consistent hash load balancer.
+// Only one of the policy should be set in a HashPolicy,
+// if multiple hash policies are configured in a route, the hash keys will be combined.
+type HashPolicy struct {
+	Header               *HeaderHashPolicy               `json:"header,omitempty"`
+	SofaService          *SofaServiceHashPolicy          `json:"sofa_service,omitempty"`
+	Cookie               *CookieHashPolicy               `json:"cookie,omitempty"`
+	ConnectionProperties *ConnectionPropertiesHashPolicy `json:"connection_properties,omitempty"`
+}
+
+// HeaderHashPolicy uses the request header's value as the hash key
+type HeaderHashPolicy struct {
+	Key string `json:"key,omitempty"`
+}
+
+// SofaServiceHashPolicy uses the sofarpc request's service header as the hash key
+type SofaServiceHashPolicy struct{}
+
+// CookieHashPolicy uses the request cookie's value as the hash key
+type CookieHashPolicy struct {
+	Name string `json:"name,omitempty"`
+}
+
+// ConnectionPropertiesHashPolicy uses the downstream connection's properties as the hash key
+type ConnectionPropertiesHashPolicy struct {
+	SourceIP bool `json:"source_ip,omitempty"`
+}
+
+// ShadowPolicy mirrors the requests to the shadow cluster, the shadow requests are fire and forget,
+// their responses are ignored and their failures never affect the original requests.
+// Percent is the percentage of the requests to be mirrored, such as 0.5 means 0.5% requests are mirrored,
+// zero means all the requests are mirrored.
+type ShadowPolicy struct {
+	Cluster string  `json:"cluster,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+}
+
 // WeightedCluster.
 // Multiple upstream clusters unsupport stream filter type:  healthcheckcan be specified for a given route.
 // The request is routed to one of the upstream
@@ -619,6 +835,7 @@ type ApplicationInfo struct {
 	AntShareCloud bool   `json:"ant_share_cloud,omitempty"`
 	DataCenter    string `json:"data_center,omitempty"`
 	AppName       string `json:"app_name,omitempty"`
+	Region        string `json:"region,omitempty"`
 	Zone          string `json:"zone,omitempty"`
 	DeployMode    bool   `json:"deploy_mode,omitempty"`
 	MasterSystem  bool   `json:"master_system,omitempty"`
@@ -48,6 +48,8 @@ type MetricsConfig struct {
 	StatsMatcher v2.StatsMatcher   `json:"stats_matcher"`
 	ShmZone      string            `json:"shm_zone"`
 	ShmSize      datasize.ByteSize `json:"shm_size"`
+	// HistogramBuckets are the histogram bucket upper bounds by metrics key, the key "default" applies to the others
+	HistogramBuckets map[string][]int64 `json:"histogram_buckets,omitempty"`
 }
 
 // ClusterManagerConfig for making up cluster manager
@@ -46,6 +46,8 @@ func ResetServiceRegistryInfo(appInfo v2.ApplicationInfo, subServiceList []strin
 		AntShareCloud: appInfo.AntShareCloud,
 		DataCenter:    appInfo.DataCenter,
 		AppName:       appInfo.AppName,
+		Region:        appInfo.Region,
+		Zone:          appInfo.Zone,
 		DeployMode:    appInfo.DeployMode,
 		MasterSystem:  appInfo.MasterSystem,
 		CloudName:     appInfo.CloudName,
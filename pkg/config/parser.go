@@ -318,6 +318,20 @@ func ParseTCPProxy(cfg map[string]interface{}) (*v2.TCPProxy, error) {
 	return proxy, nil
 }
 
+// ParseRedisProxy
+func ParseRedisProxy(cfg map[string]interface{}) (*v2.RedisProxy, error) {
+	proxy := &v2.RedisProxy{}
+	if data, err := json.Marshal(cfg); err == nil {
+		json.Unmarshal(data, proxy)
+	} else {
+		return nil, fmt.Errorf("[config] config is not a redis proxy config: %v", err)
+	}
+	if proxy.Cluster == "" {
+		return nil, fmt.Errorf("[config] redis proxy cluster is required")
+	}
+	return proxy, nil
+}
+
 func ParseServiceRegistry(src v2.ServiceRegistryInfo) {
 	//trigger all callbacks
 	if cbs, ok := configParsedCBMaps[ParseCallbackKeyServiceRgtInfo]; ok {
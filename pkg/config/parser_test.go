@@ -386,6 +386,23 @@ func TestParseTCPProxy(t *testing.T) {
 		}
 	}
 }
+
+func TestParseRedisProxy(t *testing.T) {
+	m := map[string]interface{}{
+		"stat_prefix": "redis",
+		"cluster":     "redis_cluster",
+	}
+	redisproxy, err := ParseRedisProxy(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redisproxy.StatPrefix != "redis" || redisproxy.Cluster != "redis_cluster" {
+		t.Errorf("parse redis proxy failed: %+v", redisproxy)
+	}
+	if _, err := ParseRedisProxy(map[string]interface{}{"stat_prefix": "redis"}); err == nil {
+		t.Error("redis proxy without cluster should be failed")
+	}
+}
 func TestParseServiceRegistry(t *testing.T) {
 	cb.Count = 0
 	ParseServiceRegistry(v2.ServiceRegistryInfo{})
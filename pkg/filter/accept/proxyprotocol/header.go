@@ -0,0 +1,252 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxyprotocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PROXY protocol versions
+const (
+	Version1 = 1
+	Version2 = 2
+)
+
+const (
+	v1Prefix       = "PROXY "
+	v1MaxHeaderLen = 107
+	v2HeaderLen    = 16
+	v2CmdLocal     = 0x0
+	v2CmdProxy     = 0x1
+	v2FamilyUnspec = 0x00
+	v2FamilyTCP4   = 0x11
+	v2FamilyUDP4   = 0x12
+	v2FamilyTCP6   = 0x21
+	v2FamilyUDP6   = 0x22
+	v2AddrLenIPv4  = 12
+	v2AddrLenIPv6  = 36
+)
+
+var v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// Errors
+var (
+	ErrNoProxyProtocol = errors.New("connection does not start with PROXY protocol header")
+	ErrInvalidHeader   = errors.New("invalid PROXY protocol header")
+)
+
+// Header is the PROXY protocol header
+// The addresses are nil if the header is a v1 UNKNOWN or a v2 LOCAL header,
+// which means the connection's real addresses should be used.
+type Header struct {
+	Version         int
+	SourceAddr      net.Addr
+	DestinationAddr net.Addr
+}
+
+// ReadHeader reads a PROXY protocol v1 or v2 header from r.
+// It never reads more bytes than the header, so r can be used after the header is read.
+func ReadHeader(r io.Reader) (*Header, error) {
+	prefix := make([]byte, len(v1Prefix))
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, err
+	}
+	if string(prefix) == v1Prefix {
+		return readV1(r)
+	}
+	if bytes.Equal(prefix, v2Signature[:len(v1Prefix)]) {
+		return readV2(r, prefix)
+	}
+	return nil, ErrNoProxyProtocol
+}
+
+func readV1(r io.Reader) (*Header, error) {
+	line := make([]byte, 0, v1MaxHeaderLen)
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		line = append(line, b[0])
+		if b[0] == '\n' {
+			break
+		}
+		if len(line)+len(v1Prefix) >= v1MaxHeaderLen {
+			return nil, ErrInvalidHeader
+		}
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, ErrInvalidHeader
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	header := &Header{
+		Version: Version1,
+	}
+	switch fields[0] {
+	case "UNKNOWN":
+		return header, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, ErrInvalidHeader
+	}
+	if len(fields) != 5 {
+		return nil, ErrInvalidHeader
+	}
+	src, err := parseV1Addr(fields[1], fields[3])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	header.SourceAddr = src
+	header.DestinationAddr = dst
+	return header, nil
+}
+
+func parseV1Addr(ip, port string) (net.Addr, error) {
+	addr := &net.TCPAddr{
+		IP: net.ParseIP(ip),
+	}
+	if addr.IP == nil {
+		return nil, ErrInvalidHeader
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+	addr.Port = int(p)
+	return addr, nil
+}
+
+func readV2(r io.Reader, prefix []byte) (*Header, error) {
+	buf := make([]byte, v2HeaderLen)
+	copy(buf, prefix)
+	if _, err := io.ReadFull(r, buf[len(prefix):]); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(buf[:len(v2Signature)], v2Signature) || buf[12]>>4 != Version2 {
+		return nil, ErrInvalidHeader
+	}
+	cmd := buf[12] & 0x0F
+	family := buf[13]
+	payload := make([]byte, binary.BigEndian.Uint16(buf[14:16]))
+	// the payload should be read even if the addresses are ignored
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	header := &Header{
+		Version: Version2,
+	}
+	switch cmd {
+	case v2CmdLocal:
+		return header, nil
+	case v2CmdProxy:
+	default:
+		return nil, ErrInvalidHeader
+	}
+	var ipLen int
+	switch family {
+	case v2FamilyTCP4, v2FamilyUDP4:
+		ipLen = net.IPv4len
+	case v2FamilyTCP6, v2FamilyUDP6:
+		ipLen = net.IPv6len
+	default:
+		// unspec and unix sockets, use the connection's addresses
+		return header, nil
+	}
+	if len(payload) < 2*ipLen+4 {
+		return nil, ErrInvalidHeader
+	}
+	srcIP := make(net.IP, ipLen)
+	dstIP := make(net.IP, ipLen)
+	copy(srcIP, payload[:ipLen])
+	copy(dstIP, payload[ipLen:2*ipLen])
+	srcPort := int(binary.BigEndian.Uint16(payload[2*ipLen:]))
+	dstPort := int(binary.BigEndian.Uint16(payload[2*ipLen+2:]))
+	if family == v2FamilyUDP4 || family == v2FamilyUDP6 {
+		header.SourceAddr = &net.UDPAddr{IP: srcIP, Port: srcPort}
+		header.DestinationAddr = &net.UDPAddr{IP: dstIP, Port: dstPort}
+	} else {
+		header.SourceAddr = &net.TCPAddr{IP: srcIP, Port: srcPort}
+		header.DestinationAddr = &net.TCPAddr{IP: dstIP, Port: dstPort}
+	}
+	return header, nil
+}
+
+// EncodeHeader encodes a PROXY protocol header.
+// If the addresses are not tcp addresses with the same ip family, a v1 UNKNOWN or
+// a v2 LOCAL header is encoded.
+func EncodeHeader(version int, src, dst net.Addr) ([]byte, error) {
+	srcAddr, _ := src.(*net.TCPAddr)
+	dstAddr, _ := dst.(*net.TCPAddr)
+	var srcIP, dstIP net.IP
+	if srcAddr != nil && dstAddr != nil {
+		srcIP, dstIP = srcAddr.IP.To4(), dstAddr.IP.To4()
+		if srcIP == nil || dstIP == nil {
+			srcIP, dstIP = srcAddr.IP.To16(), dstAddr.IP.To16()
+			// ipv4 and ipv6 mixed
+			if srcAddr.IP.To4() != nil || dstAddr.IP.To4() != nil {
+				srcIP, dstIP = nil, nil
+			}
+		}
+	}
+	switch version {
+	case Version1:
+		if srcIP == nil || dstIP == nil {
+			return []byte(v1Prefix + "UNKNOWN\r\n"), nil
+		}
+		proto := "TCP4"
+		if len(srcIP) == net.IPv6len {
+			proto = "TCP6"
+		}
+		return []byte(fmt.Sprintf("%s%s %s %s %d %d\r\n", v1Prefix, proto, srcIP, dstIP, srcAddr.Port, dstAddr.Port)), nil
+	case Version2:
+		buf := make([]byte, v2HeaderLen, v2HeaderLen+v2AddrLenIPv6)
+		copy(buf, v2Signature)
+		if srcIP == nil || dstIP == nil {
+			buf[12] = Version2<<4 | v2CmdLocal
+			buf[13] = v2FamilyUnspec
+			return buf, nil
+		}
+		buf[12] = Version2<<4 | v2CmdProxy
+		if len(srcIP) == net.IPv4len {
+			buf[13] = v2FamilyTCP4
+			binary.BigEndian.PutUint16(buf[14:], v2AddrLenIPv4)
+		} else {
+			buf[13] = v2FamilyTCP6
+			binary.BigEndian.PutUint16(buf[14:], v2AddrLenIPv6)
+		}
+		buf = append(buf, srcIP...)
+		buf = append(buf, dstIP...)
+		ports := make([]byte, 4)
+		binary.BigEndian.PutUint16(ports, uint16(srcAddr.Port))
+		binary.BigEndian.PutUint16(ports[2:], uint16(dstAddr.Port))
+		return append(buf, ports...), nil
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol version: %d", version)
+	}
+}
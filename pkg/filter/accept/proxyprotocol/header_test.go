@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxyprotocol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestReadHeaderV1(t *testing.T) {
+	data := "PROXY TCP4 192.168.1.10 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\n"
+	r := bytes.NewReader([]byte(data))
+	header, err := ReadHeader(r)
+	if err != nil {
+		t.Fatalf("read header failed: %v", err)
+	}
+	if header.Version != Version1 ||
+		header.SourceAddr.String() != "192.168.1.10:56324" ||
+		header.DestinationAddr.String() != "10.0.0.1:443" {
+		t.Errorf("header is not expected: %+v", header)
+	}
+	// the data after header should not be read
+	if r.Len() != len("GET / HTTP/1.1\r\n") {
+		t.Errorf("read more data than the header, remain: %d", r.Len())
+	}
+	// ipv6
+	header, err = ReadHeader(bytes.NewReader([]byte("PROXY TCP6 2001:db8::1 2001:db8::2 1234 80\r\n")))
+	if err != nil || header.SourceAddr.String() != "[2001:db8::1]:1234" {
+		t.Errorf("read ipv6 header failed: %+v, %v", header, err)
+	}
+	// unknown
+	header, err = ReadHeader(bytes.NewReader([]byte("PROXY UNKNOWN\r\n")))
+	if err != nil || header.SourceAddr != nil || header.DestinationAddr != nil {
+		t.Errorf("read unknown header failed: %+v, %v", header, err)
+	}
+}
+
+func TestReadHeaderV2(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443}
+	data, err := EncodeHeader(Version2, src, dst)
+	if err != nil {
+		t.Fatalf("encode header failed: %v", err)
+	}
+	// append a tlv, should be ignored
+	data[15] += 3
+	data = append(data, 0x04, 0x00, 0x00)
+	r := bytes.NewReader(append(data, []byte("hello")...))
+	header, err := ReadHeader(r)
+	if err != nil {
+		t.Fatalf("read header failed: %v", err)
+	}
+	if header.Version != Version2 ||
+		header.SourceAddr.String() != src.String() ||
+		header.DestinationAddr.String() != dst.String() {
+		t.Errorf("header is not expected: %+v", header)
+	}
+	if r.Len() != len("hello") {
+		t.Errorf("read more data than the header, remain: %d", r.Len())
+	}
+	// local command
+	local, _ := EncodeHeader(Version2, nil, nil)
+	header, err = ReadHeader(bytes.NewReader(local))
+	if err != nil || header.SourceAddr != nil {
+		t.Errorf("read local header failed: %+v, %v", header, err)
+	}
+}
+
+func TestReadInvalidHeader(t *testing.T) {
+	for _, data := range []string{
+		"GET / HTTP/1.1\r\n\r\n",
+		"PROXY TCP4 192.168.1.10 10.0.0.1 56324\r\n",
+		"PROXY TCP4 192.168.1.300 10.0.0.1 56324 443\r\n",
+		"PROXY TCP4 192.168.1.10 10.0.0.1 56324 443\n",
+		"PROXY TCP4 192.168.1.10 10.0.0.1 56324 443 " + string(bytes.Repeat([]byte("a"), 100)) + "\r\n",
+		"\r\n\r\n\x00\r\nQUIT\n\x30\x11\x00\x00",
+	} {
+		if _, err := ReadHeader(bytes.NewReader([]byte(data))); err == nil {
+			t.Errorf("invalid header %q should be failed", data)
+		}
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443}
+	data, err := EncodeHeader(Version1, src, dst)
+	if err != nil || string(data) != "PROXY TCP4 192.168.1.10 10.0.0.1 56324 443\r\n" {
+		t.Errorf("encode v1 header failed: %q, %v", data, err)
+	}
+	// mixed ip family
+	data, _ = EncodeHeader(Version1, src, &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443})
+	if string(data) != "PROXY UNKNOWN\r\n" {
+		t.Errorf("encode mixed address header failed: %q", data)
+	}
+	// v2 ipv6 round trip
+	src6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1234}
+	dst6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 80}
+	data, _ = EncodeHeader(Version2, src6, dst6)
+	header, err := ReadHeader(bytes.NewReader(data))
+	if err != nil || header.SourceAddr.String() != src6.String() || header.DestinationAddr.String() != dst6.String() {
+		t.Errorf("v2 ipv6 round trip failed: %+v, %v", header, err)
+	}
+	if _, err := EncodeHeader(3, src, dst); err == nil {
+		t.Error("unsupported version should be failed")
+	}
+}
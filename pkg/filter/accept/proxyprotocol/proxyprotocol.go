@@ -0,0 +1,60 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxyprotocol
+
+import (
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// ProxyProtocol filter parses the PROXY protocol header sent by the L4 load balancers,
+// and sets the client address as the connection's remote address.
+
+// HeaderReadTimeout is the max time to wait for the PROXY protocol header
+var HeaderReadTimeout = 5 * time.Second
+
+type proxyProtocol struct {
+}
+
+// NewProxyProtocol new a PROXY protocol filter
+func NewProxyProtocol() types.ListenerFilter {
+	return &proxyProtocol{}
+}
+
+// OnAccept called when connection accept
+func (filter *proxyProtocol) OnAccept(cb types.ListenerFilterCallbacks) types.FilterStatus {
+	conn := cb.Conn()
+	conn.SetReadDeadline(time.Now().Add(HeaderReadTimeout))
+	header, err := ReadHeader(conn)
+	if err != nil {
+		log.DefaultLogger.Errorf("[proxyprotocol] read PROXY protocol header from %s failed: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return types.Stop
+	}
+	conn.SetReadDeadline(time.Time{})
+	if header.SourceAddr != nil {
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[proxyprotocol] connection from %s, PROXY protocol v%d source: %s, destination: %s",
+				conn.RemoteAddr(), header.Version, header.SourceAddr, header.DestinationAddr)
+		}
+		cb.SetRemoteAddr(header.SourceAddr)
+	}
+	return types.Continue
+}
@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxyprotocol
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type mockListenerFilterCallbacks struct {
+	conn       net.Conn
+	remoteAddr net.Addr
+}
+
+func (cb *mockListenerFilterCallbacks) Conn() net.Conn {
+	return cb.conn
+}
+
+func (cb *mockListenerFilterCallbacks) ContinueFilterChain(ctx context.Context, success bool) {}
+
+func (cb *mockListenerFilterCallbacks) SetOriginalAddr(ip string, port int) {}
+
+func (cb *mockListenerFilterCallbacks) SetRemoteAddr(addr net.Addr) {
+	cb.remoteAddr = addr
+}
+
+func TestProxyProtocolOnAccept(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		client.Write([]byte("PROXY TCP4 192.168.1.10 10.0.0.1 56324 443\r\nhello"))
+		client.Close()
+	}()
+	cb := &mockListenerFilterCallbacks{conn: server}
+	if status := NewProxyProtocol().OnAccept(cb); status != types.Continue {
+		t.Fatalf("filter status is not expected: %v", status)
+	}
+	if cb.remoteAddr == nil || cb.remoteAddr.String() != "192.168.1.10:56324" {
+		t.Fatalf("remote address is not expected: %v", cb.remoteAddr)
+	}
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(server, b); err != nil || string(b) != "hello" {
+		t.Fatalf("read data after header failed: %s, %v", b, err)
+	}
+}
+
+func TestProxyProtocolOnAcceptFailed(t *testing.T) {
+	timeout := HeaderReadTimeout
+	HeaderReadTimeout = 100 * time.Millisecond
+	defer func() {
+		HeaderReadTimeout = timeout
+	}()
+	// no header
+	client, server := net.Pipe()
+	go client.Write([]byte("GET / HTTP/1.1\r\n"))
+	cb := &mockListenerFilterCallbacks{conn: server}
+	if status := NewProxyProtocol().OnAccept(cb); status != types.Stop || cb.remoteAddr != nil {
+		t.Fatalf("connection without header should be rejected")
+	}
+	// the connection should be closed
+	if _, err := server.Write([]byte("a")); err == nil {
+		t.Fatal("connection should be closed")
+	}
+	client.Close()
+	// timeout
+	client, server = net.Pipe()
+	defer client.Close()
+	cb = &mockListenerFilterCallbacks{conn: server}
+	if status := NewProxyProtocol().OnAccept(cb); status != types.Stop {
+		t.Fatalf("connection read header timeout should be rejected")
+	}
+}
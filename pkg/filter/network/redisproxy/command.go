@@ -0,0 +1,175 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// singleKeyCommands are the commands proxied to the shard of the first argument
+var singleKeyCommands = map[string]bool{
+	// strings
+	"append": true, "bitcount": true, "bitpos": true, "decr": true, "decrby": true, "get": true,
+	"getbit": true, "getrange": true, "getset": true, "incr": true, "incrby": true, "incrbyfloat": true,
+	"psetex": true, "set": true, "setbit": true, "setex": true, "setnx": true, "setrange": true, "strlen": true,
+	// keys
+	"dump": true, "expire": true, "expireat": true, "persist": true, "pexpire": true, "pexpireat": true,
+	"pttl": true, "restore": true, "sort": true, "ttl": true, "type": true,
+	// hashes
+	"hdel": true, "hexists": true, "hget": true, "hgetall": true, "hincrby": true, "hincrbyfloat": true,
+	"hkeys": true, "hlen": true, "hmget": true, "hmset": true, "hscan": true, "hset": true, "hsetnx": true,
+	"hstrlen": true, "hvals": true,
+	// lists
+	"lindex": true, "linsert": true, "llen": true, "lpop": true, "lpush": true, "lpushx": true,
+	"lrange": true, "lrem": true, "lset": true, "ltrim": true, "rpop": true, "rpush": true, "rpushx": true,
+	// sets
+	"sadd": true, "scard": true, "sismember": true, "smembers": true, "spop": true, "srandmember": true,
+	"srem": true, "sscan": true,
+	// sorted sets
+	"zadd": true, "zcard": true, "zcount": true, "zincrby": true, "zlexcount": true, "zrange": true,
+	"zrangebylex": true, "zrangebyscore": true, "zrank": true, "zrem": true, "zremrangebylex": true,
+	"zremrangebyrank": true, "zremrangebyscore": true, "zrevrange": true, "zrevrangebylex": true,
+	"zrevrangebyscore": true, "zrevrank": true, "zscan": true, "zscore": true,
+	// hyperloglog
+	"pfadd": true,
+	// geo
+	"geoadd": true, "geodist": true, "geohash": true, "geopos": true, "georadius": true, "georadiusbymember": true,
+}
+
+// multiKeyCommand is a command with several keys, it is split into single key commands
+// sent to the shards of the keys, and the replies are merged
+type multiKeyCommand struct {
+	// step is the number of arguments of each key, including the key
+	step int
+	// single is the command sent for each key
+	single string
+	merge  func(replies []*Value) *Value
+}
+
+var multiKeyCommands = map[string]*multiKeyCommand{
+	"mget":   {step: 1, single: "get", merge: mergeArray},
+	"mset":   {step: 2, single: "set", merge: mergeOK},
+	"del":    {step: 1, single: "del", merge: mergeSum},
+	"unlink": {step: 1, single: "unlink", merge: mergeSum},
+	"exists": {step: 1, single: "exists", merge: mergeSum},
+	"touch":  {step: 1, single: "touch", merge: mergeSum},
+}
+
+// unsupportedCommand is the stats name of the commands not proxied, so the stats labels are bounded
+const unsupportedCommand = "unsupported"
+
+// subRequest is a single key command sent to the shard of the key
+type subRequest struct {
+	key     []byte
+	command *Value
+}
+
+// splitCommand returns the command name for stats, the sub requests and the function merges their replies.
+// If the command is answered by the proxy, such as PING or an invalid command, the local reply is returned.
+func splitCommand(cmd *Value) (name string, subs []subRequest, merge func([]*Value) *Value, local *Value) {
+	if cmd.Type != Array || len(cmd.Array) == 0 {
+		return unsupportedCommand, nil, nil, NewError("ERR invalid request")
+	}
+	args := make([][]byte, 0, len(cmd.Array))
+	for _, arg := range cmd.Array {
+		if arg.Type != BulkString || arg.Null {
+			return unsupportedCommand, nil, nil, NewError("ERR invalid request")
+		}
+		args = append(args, arg.Str)
+	}
+	name = strings.ToLower(string(args[0]))
+
+	if name == "ping" {
+		switch len(args) {
+		case 1:
+			return name, nil, nil, NewSimpleString("PONG")
+		case 2:
+			return name, nil, nil, NewBulkString(args[1])
+		default:
+			return name, nil, nil, wrongArguments(name)
+		}
+	}
+
+	if singleKeyCommands[name] {
+		if len(args) < 2 {
+			return name, nil, nil, wrongArguments(name)
+		}
+		return name, []subRequest{{key: args[1], command: cmd}}, mergeFirst, nil
+	}
+
+	if multi, ok := multiKeyCommands[name]; ok {
+		if len(args) < 2 || (len(args)-1)%multi.step != 0 {
+			return name, nil, nil, wrongArguments(name)
+		}
+		subs = make([]subRequest, 0, (len(args)-1)/multi.step)
+		for i := 1; i < len(args); i += multi.step {
+			subArgs := append([][]byte{[]byte(multi.single)}, args[i:i+multi.step]...)
+			subs = append(subs, subRequest{key: args[i], command: NewCommand(subArgs...)})
+		}
+		return name, subs, multi.merge, nil
+	}
+
+	return unsupportedCommand, nil, nil, NewError(fmt.Sprintf("ERR unsupported command '%s'", name))
+}
+
+func wrongArguments(name string) *Value {
+	return NewError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+}
+
+// firstError returns the first error reply
+func firstError(replies []*Value) *Value {
+	for _, reply := range replies {
+		if reply.IsError() {
+			return reply
+		}
+	}
+	return nil
+}
+
+func mergeFirst(replies []*Value) *Value {
+	return replies[0]
+}
+
+// mergeArray merges the replies of MGET
+func mergeArray(replies []*Value) *Value {
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	return &Value{Type: Array, Array: replies}
+}
+
+// mergeOK merges the replies of MSET
+func mergeOK(replies []*Value) *Value {
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	return NewSimpleString("OK")
+}
+
+// mergeSum merges the integer replies of DEL, EXISTS and so on
+func mergeSum(replies []*Value) *Value {
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	var sum int64
+	for _, reply := range replies {
+		sum += reply.Int
+	}
+	return NewInteger(sum)
+}
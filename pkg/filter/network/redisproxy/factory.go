@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"context"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/config"
+	"sofastack.io/sofa-mosn/pkg/filter"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func init() {
+	filter.RegisterNetwork(v2.REDIS_PROXY, CreateRedisProxyFactory)
+}
+
+type redisProxyFilterConfigFactory struct {
+	Proxy *v2.RedisProxy
+}
+
+func (f *redisProxyFilterConfigFactory) CreateFilterChain(context context.Context, clusterManager types.ClusterManager, callbacks types.NetWorkFilterChainFactoryCallbacks) {
+	rf := NewProxy(context, f.Proxy, clusterManager)
+	callbacks.AddReadFilter(rf)
+}
+
+func CreateRedisProxyFactory(conf map[string]interface{}) (types.NetworkFilterChainFactory, error) {
+	p, err := config.ParseRedisProxy(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &redisProxyFilterConfigFactory{
+		Proxy: p,
+	}, nil
+}
@@ -0,0 +1,195 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// request is a downstream command, the replies are written in the order of the requests
+type request struct {
+	command string
+	start   time.Time
+	replies []*Value
+	pending int
+	merge   func([]*Value) *Value
+	reply   *Value
+}
+
+// proxy is a ReadFilter parses the pipelined redis commands,
+// proxies each command to the shard of its keys and writes the replies in order
+type proxy struct {
+	config         *v2.RedisProxy
+	clusterManager types.ClusterManager
+	readCallbacks  types.ReadFilterCallbacks
+	router         router
+
+	mutex    sync.Mutex
+	requests []*request
+	stats    map[string]types.Metrics
+	closed   bool
+}
+
+// NewProxy returns a redis proxy read filter
+func NewProxy(ctx context.Context, config *v2.RedisProxy, clusterManager types.ClusterManager) types.ReadFilter {
+	return &proxy{
+		config:         config,
+		clusterManager: clusterManager,
+		stats:          make(map[string]types.Metrics),
+	}
+}
+
+func (p *proxy) OnData(buffer types.IoBuffer) types.FilterStatus {
+	for buffer.Len() > 0 {
+		cmd, n, err := Decode(buffer.Bytes())
+		if err != nil {
+			log.DefaultLogger.Errorf("[redisproxy] decode command error: %v", err)
+			buffer.Drain(buffer.Len())
+			p.onProtocolError()
+			return types.Stop
+		}
+		if cmd == nil {
+			break
+		}
+		buffer.Drain(n)
+		p.handleCommand(cmd)
+	}
+	return types.Stop
+}
+
+func (p *proxy) OnNewConnection() types.FilterStatus {
+	return types.Continue
+}
+
+func (p *proxy) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {
+	p.readCallbacks = cb
+	p.readCallbacks.Connection().AddConnectionEventListener(p)
+	if p.router == nil {
+		p.router = newClusterRouter(p.config.Cluster, p.clusterManager, cb.Connection())
+	}
+}
+
+// OnEvent closes the upstream connections when the downstream connection is closed
+func (p *proxy) OnEvent(event types.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	p.mutex.Lock()
+	p.closed = true
+	p.requests = nil
+	p.mutex.Unlock()
+	p.router.Close()
+}
+
+func (p *proxy) handleCommand(cmd *Value) {
+	name, subs, merge, local := splitCommand(cmd)
+	req := &request{
+		command: name,
+		start:   time.Now(),
+		replies: make([]*Value, len(subs)),
+		pending: len(subs),
+		merge:   merge,
+	}
+	p.mutex.Lock()
+	p.requests = append(p.requests, req)
+	p.mutex.Unlock()
+
+	if local != nil {
+		p.complete(req, local)
+		return
+	}
+	for i := range subs {
+		idx := i
+		upstream, err := p.router.Route(subs[idx].key)
+		if err != nil {
+			p.onSubReply(req, idx, NewError("ERR "+err.Error()))
+			continue
+		}
+		upstream.Send(subs[idx].command, func(reply *Value) {
+			p.onSubReply(req, idx, reply)
+		})
+	}
+}
+
+func (p *proxy) onSubReply(req *request, idx int, reply *Value) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	req.replies[idx] = reply
+	req.pending--
+	if req.pending == 0 {
+		p.completeLocked(req, req.merge(req.replies))
+	}
+}
+
+func (p *proxy) complete(req *request, reply *Value) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.completeLocked(req, reply)
+}
+
+// completeLocked records the stats of the request and writes the completed replies in order
+func (p *proxy) completeLocked(req *request, reply *Value) {
+	req.reply = reply
+
+	stats, ok := p.stats[req.command]
+	if !ok {
+		stats = metrics.NewRedisCommandStats(p.config.StatPrefix, req.command)
+		p.stats[req.command] = stats
+	}
+	stats.Counter(metrics.RedisCommandTotal).Inc(1)
+	if reply.IsError() {
+		stats.Counter(metrics.RedisCommandError).Inc(1)
+	}
+	stats.Histogram(metrics.RedisCommandDuration).Update(time.Since(req.start).Nanoseconds())
+
+	if p.closed {
+		return
+	}
+	var data []byte
+	for len(p.requests) > 0 && p.requests[0].reply != nil {
+		data = p.requests[0].reply.Encode(data)
+		p.requests = p.requests[1:]
+	}
+	if len(data) > 0 {
+		p.readCallbacks.Connection().Write(buffer.NewIoBufferBytes(data))
+	}
+}
+
+// onProtocolError replies the error once the previous replies are written, and closes the downstream connection,
+// the replies not completed yet are dropped
+func (p *proxy) onProtocolError() {
+	req := &request{
+		command: unsupportedCommand,
+		start:   time.Now(),
+	}
+	p.mutex.Lock()
+	p.requests = append(p.requests, req)
+	p.completeLocked(req, NewError("ERR Protocol error"))
+	p.mutex.Unlock()
+	p.readCallbacks.Connection().Close(types.FlushWrite, types.LocalClose)
+}
@@ -0,0 +1,327 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// fakeConn records the data written to the downstream connection
+type fakeConn struct {
+	types.ClientConnection
+	mutex  sync.Mutex
+	data   bytes.Buffer
+	closed bool
+}
+
+func (c *fakeConn) Write(bufs ...types.IoBuffer) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, buf := range bufs {
+		c.data.Write(buf.Bytes())
+	}
+	return nil
+}
+
+func (c *fakeConn) Close(ccType types.ConnectionCloseType, eventType types.ConnectionEvent) error {
+	c.mutex.Lock()
+	c.closed = true
+	c.mutex.Unlock()
+	return nil
+}
+
+func (c *fakeConn) AddConnectionEventListener(cb types.ConnectionEventListener) {}
+
+func (c *fakeConn) String() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.data.String()
+}
+
+type fakeReadFilterCallbacks struct {
+	types.ReadFilterCallbacks
+	conn *fakeConn
+}
+
+func (cb *fakeReadFilterCallbacks) Connection() types.Connection {
+	return cb.conn
+}
+
+// fakeShard is an in-process redis server stand-in
+type fakeShard struct {
+	mutex sync.Mutex
+	store map[string]string
+	delay time.Duration
+}
+
+func (s *fakeShard) Send(command *Value, cb func(reply *Value)) {
+	if s.delay > 0 {
+		go func() {
+			time.Sleep(s.delay)
+			cb(s.execute(command))
+		}()
+		return
+	}
+	cb(s.execute(command))
+}
+
+func (s *fakeShard) execute(command *Value) *Value {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	args := command.Array
+	key := string(args[1].Str)
+	switch strings.ToLower(string(args[0].Str)) {
+	case "get":
+		if v, ok := s.store[key]; ok {
+			return NewBulkString([]byte(v))
+		}
+		return &Value{Type: BulkString, Null: true}
+	case "set":
+		s.store[key] = string(args[2].Str)
+		return NewSimpleString("OK")
+	case "incr":
+		i, err := strconv.ParseInt(s.store[key], 10, 64)
+		if _, ok := s.store[key]; ok && err != nil {
+			return NewError("ERR value is not an integer or out of range")
+		}
+		s.store[key] = strconv.FormatInt(i+1, 10)
+		return NewInteger(i + 1)
+	case "del", "exists":
+		_, ok := s.store[key]
+		if ok && strings.ToLower(string(args[0].Str)) == "del" {
+			delete(s.store, key)
+		}
+		if ok {
+			return NewInteger(1)
+		}
+		return NewInteger(0)
+	}
+	return NewError("ERR unknown command")
+}
+
+// fakeRouter shards the keys with the key hash
+type fakeRouter struct {
+	shards []*fakeShard
+	closed bool
+}
+
+func newFakeRouter(n int) *fakeRouter {
+	r := &fakeRouter{}
+	for i := 0; i < n; i++ {
+		r.shards = append(r.shards, &fakeShard{store: make(map[string]string)})
+	}
+	return r
+}
+
+func (r *fakeRouter) shard(key string) *fakeShard {
+	return r.shards[utils.HashString(key)%uint64(len(r.shards))]
+}
+
+func (r *fakeRouter) Route(key []byte) (upstream, error) {
+	return r.shard(string(key)), nil
+}
+
+func (r *fakeRouter) Close() {
+	r.closed = true
+}
+
+func newTestProxy(statPrefix string, r router) (*proxy, *fakeConn) {
+	p := NewProxy(nil, &v2.RedisProxy{StatPrefix: statPrefix, Cluster: "redis"}, nil).(*proxy)
+	p.router = r
+	conn := &fakeConn{}
+	p.InitializeReadFilterCallbacks(&fakeReadFilterCallbacks{conn: conn})
+	return p, conn
+}
+
+func encodeCommands(commands ...string) []byte {
+	var data []byte
+	for _, command := range commands {
+		var args [][]byte
+		for _, arg := range strings.Fields(command) {
+			args = append(args, []byte(arg))
+		}
+		data = NewCommand(args...).Encode(data)
+	}
+	return data
+}
+
+func waitForData(t *testing.T, conn *fakeConn, expected string) {
+	for i := 0; i < 100; i++ {
+		if len(conn.String()) >= len(expected) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := conn.String(); got != expected {
+		t.Errorf("expected replies %q, got %q", expected, got)
+	}
+}
+
+func TestProxyPipeline(t *testing.T) {
+	r := newFakeRouter(3)
+	p, conn := newTestProxy("pipeline", r)
+	data := encodeCommands(
+		"SET a 1",
+		"GET a",
+		"PING",
+		"MSET b 2 c 3 d 4",
+		"MGET a b c d x",
+		"DEL a b x",
+		"EXISTS a c",
+		"INCR c",
+		"KEYS *",
+		"GET",
+	)
+	// the commands are split across reads
+	buf := buffer.NewIoBufferBytes(append([]byte(nil), data[:7]...))
+	p.OnData(buf)
+	buf.Write(data[7:])
+	p.OnData(buf)
+	if buf.Len() != 0 {
+		t.Errorf("all the commands should be consumed, remain %d", buf.Len())
+	}
+	expected := "+OK\r\n" +
+		"$1\r\n1\r\n" +
+		"+PONG\r\n" +
+		"+OK\r\n" +
+		"*5\r\n$1\r\n1\r\n$1\r\n2\r\n$1\r\n3\r\n$1\r\n4\r\n$-1\r\n" +
+		":2\r\n" +
+		":1\r\n" +
+		":4\r\n" +
+		"-ERR unsupported command 'keys'\r\n" +
+		"-ERR wrong number of arguments for 'get' command\r\n"
+	waitForData(t, conn, expected)
+
+	// the keys are stored in their shards
+	for _, key := range []string{"c", "d"} {
+		if _, ok := r.shard(key).store[key]; !ok {
+			t.Errorf("key %s is not stored in its shard", key)
+		}
+	}
+
+	getStats := metrics.NewRedisCommandStats("pipeline", "get")
+	if total := getStats.Counter(metrics.RedisCommandTotal).Count(); total != 2 {
+		t.Errorf("expected 2 get commands, got %d", total)
+	}
+	if count := getStats.Histogram(metrics.RedisCommandDuration).Count(); count != 2 {
+		t.Errorf("expected 2 get latencies, got %d", count)
+	}
+	if errors := getStats.Counter(metrics.RedisCommandError).Count(); errors != 1 {
+		t.Errorf("expected 1 get error, got %d", errors)
+	}
+	unsupported := metrics.NewRedisCommandStats("pipeline", unsupportedCommand)
+	if errors := unsupported.Counter(metrics.RedisCommandError).Count(); errors != 1 {
+		t.Errorf("expected 1 unsupported error, got %d", errors)
+	}
+
+	p.OnEvent(types.RemoteClose)
+	if !r.closed {
+		t.Error("upstreams should be closed with the downstream")
+	}
+}
+
+func TestProxyReplyOrder(t *testing.T) {
+	r := newFakeRouter(2)
+	// the first shard replies later than the second one
+	r.shards[0].delay = 50 * time.Millisecond
+	r.shards[0].store["slow"] = "0"
+	p, conn := newTestProxy("order", r)
+	// find a key of each shard
+	var slow, fast string
+	for i := 0; slow == "" || fast == ""; i++ {
+		key := "key" + strconv.Itoa(i)
+		if r.shard(key) == r.shards[0] {
+			slow = key
+		} else {
+			fast = key
+		}
+	}
+	r.shards[0].store[slow] = "slow"
+	r.shards[1].store[fast] = "fast"
+
+	p.OnData(buffer.NewIoBufferBytes(encodeCommands("GET "+slow, "GET "+fast, "MGET "+fast+" "+slow)))
+	expected := "$4\r\nslow\r\n$4\r\nfast\r\n*2\r\n$4\r\nfast\r\n$4\r\nslow\r\n"
+	waitForData(t, conn, expected)
+}
+
+func TestProxyProtocolError(t *testing.T) {
+	p, conn := newTestProxy("error", newFakeRouter(1))
+	p.OnData(buffer.NewIoBufferBytes([]byte("PING\r\n")))
+	if got := conn.String(); got != "-ERR Protocol error\r\n" {
+		t.Errorf("unexpected reply %q", got)
+	}
+	if !conn.closed {
+		t.Error("downstream should be closed on protocol error")
+	}
+}
+
+func TestUpstreamClient(t *testing.T) {
+	conn := &fakeConn{}
+	closed := false
+	client := &upstreamClient{
+		conn:    conn,
+		onClose: func() { closed = true },
+	}
+	var replies []string
+	record := func(reply *Value) {
+		replies = append(replies, string(reply.Encode(nil)))
+	}
+	client.Send(NewCommand([]byte("get"), []byte("a")), record)
+	client.Send(NewCommand([]byte("get"), []byte("b")), record)
+	client.Send(NewCommand([]byte("get"), []byte("c")), record)
+	if conn.String() != string(encodeCommands("get a", "get b", "get c")) {
+		t.Errorf("unexpected commands written %q", conn.String())
+	}
+
+	// the second reply is not complete
+	buf := buffer.NewIoBufferBytes([]byte("$1\r\n1\r\n$1\r\n"))
+	client.OnData(buf)
+	buf.Write([]byte("2\r\n"))
+	client.OnData(buf)
+	if len(replies) != 2 || replies[0] != "$1\r\n1\r\n" || replies[1] != "$1\r\n2\r\n" {
+		t.Errorf("unexpected replies %v", replies)
+	}
+
+	// the pending command is failed on close
+	client.OnEvent(types.RemoteClose)
+	if len(replies) != 3 || replies[2] != "-ERR upstream connection closed\r\n" || !closed {
+		t.Errorf("pending command should be failed on close, replies %v", replies)
+	}
+	client.Send(NewCommand([]byte("get"), []byte("d")), record)
+	if len(replies) != 4 || replies[3] != "-ERR upstream connection closed\r\n" {
+		t.Errorf("command on closed upstream should be failed, replies %v", replies)
+	}
+}
+
+func TestLbContextHashKey(t *testing.T) {
+	ctx := &LbContext{hashKey: utils.HashString("user:1")}
+	if key, ok := ctx.ComputeHashKey(); !ok || key != utils.HashString("user:1") {
+		t.Error("the hash key should be the hash of the redis key")
+	}
+}
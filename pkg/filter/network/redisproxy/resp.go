@@ -0,0 +1,188 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// RESP value types
+const (
+	SimpleString = '+'
+	Error        = '-'
+	Integer      = ':'
+	BulkString   = '$'
+	Array        = '*'
+)
+
+const maxBulkLength = 512 * 1024 * 1024
+
+var (
+	ErrProtocol = errors.New("redis protocol error")
+	crlf        = []byte("\r\n")
+)
+
+// Value is a RESP value
+type Value struct {
+	Type byte
+	// Str is the content of simple string, error and bulk string
+	Str   []byte
+	Int   int64
+	Array []*Value
+	// Null is true on null bulk string or null array
+	Null bool
+}
+
+// NewError returns an error value
+func NewError(msg string) *Value {
+	return &Value{Type: Error, Str: []byte(msg)}
+}
+
+// NewSimpleString returns a simple string value
+func NewSimpleString(s string) *Value {
+	return &Value{Type: SimpleString, Str: []byte(s)}
+}
+
+// NewInteger returns an integer value
+func NewInteger(i int64) *Value {
+	return &Value{Type: Integer, Int: i}
+}
+
+// NewBulkString returns a bulk string value
+func NewBulkString(s []byte) *Value {
+	return &Value{Type: BulkString, Str: s}
+}
+
+// NewCommand returns a command array of bulk strings
+func NewCommand(args ...[]byte) *Value {
+	v := &Value{Type: Array, Array: make([]*Value, 0, len(args))}
+	for _, arg := range args {
+		v.Array = append(v.Array, NewBulkString(arg))
+	}
+	return v
+}
+
+// IsError returns true if the value is an error reply
+func (v *Value) IsError() bool {
+	return v.Type == Error
+}
+
+// Decode decodes a RESP value from data, returns the value and the consumed length.
+// If data is not a complete value, it returns nil and 0.
+// The strings are copied, so the value is still valid after data is reused.
+func Decode(data []byte) (*Value, int, error) {
+	if len(data) == 0 {
+		return nil, 0, nil
+	}
+	line, n := readLine(data)
+	if n == 0 {
+		return nil, 0, nil
+	}
+	v := &Value{Type: data[0]}
+	switch data[0] {
+	case SimpleString, Error:
+		v.Str = append([]byte(nil), line...)
+		return v, n, nil
+	case Integer:
+		i, err := strconv.ParseInt(string(line), 10, 64)
+		if err != nil {
+			return nil, 0, ErrProtocol
+		}
+		v.Int = i
+		return v, n, nil
+	case BulkString:
+		length, err := strconv.Atoi(string(line))
+		if err != nil || length < -1 || length > maxBulkLength {
+			return nil, 0, ErrProtocol
+		}
+		if length == -1 {
+			v.Null = true
+			return v, n, nil
+		}
+		end := n + length + len(crlf)
+		if len(data) < end {
+			return nil, 0, nil
+		}
+		if !bytes.Equal(data[n+length:end], crlf) {
+			return nil, 0, ErrProtocol
+		}
+		v.Str = append([]byte(nil), data[n:n+length]...)
+		return v, end, nil
+	case Array:
+		count, err := strconv.Atoi(string(line))
+		if err != nil || count < -1 {
+			return nil, 0, ErrProtocol
+		}
+		if count == -1 {
+			v.Null = true
+			return v, n, nil
+		}
+		v.Array = make([]*Value, 0, count)
+		for i := 0; i < count; i++ {
+			elem, m, err := Decode(data[n:])
+			if err != nil || elem == nil {
+				return nil, 0, err
+			}
+			v.Array = append(v.Array, elem)
+			n += m
+		}
+		return v, n, nil
+	default:
+		return nil, 0, ErrProtocol
+	}
+}
+
+// readLine returns the line after the type byte and the consumed length with CRLF
+func readLine(data []byte) ([]byte, int) {
+	idx := bytes.Index(data, crlf)
+	if idx < 0 {
+		return nil, 0
+	}
+	return data[1:idx], idx + len(crlf)
+}
+
+// Encode appends the RESP encoding of the value to b
+func (v *Value) Encode(b []byte) []byte {
+	b = append(b, v.Type)
+	switch v.Type {
+	case SimpleString, Error:
+		b = append(b, v.Str...)
+	case Integer:
+		b = strconv.AppendInt(b, v.Int, 10)
+	case BulkString:
+		if v.Null {
+			return append(b, "-1\r\n"...)
+		}
+		b = strconv.AppendInt(b, int64(len(v.Str)), 10)
+		b = append(b, crlf...)
+		b = append(b, v.Str...)
+	case Array:
+		if v.Null {
+			return append(b, "-1\r\n"...)
+		}
+		b = strconv.AppendInt(b, int64(len(v.Array)), 10)
+		b = append(b, crlf...)
+		for _, elem := range v.Array {
+			b = elem.Encode(b)
+		}
+		return b
+	}
+	return append(b, crlf...)
+}
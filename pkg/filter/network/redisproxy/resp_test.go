@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeEncode(t *testing.T) {
+	testCases := []string{
+		"+OK\r\n",
+		"-ERR unknown\r\n",
+		":1024\r\n",
+		"$5\r\nhello\r\n",
+		"$0\r\n\r\n",
+		"$-1\r\n",
+		"*-1\r\n",
+		"*0\r\n",
+		"*3\r\n$3\r\nset\r\n$3\r\nkey\r\n$5\r\nva\r\nl\r\n",
+		"*2\r\n*1\r\n:1\r\n$-1\r\n",
+	}
+	for _, tc := range testCases {
+		v, n, err := Decode([]byte(tc))
+		if err != nil || v == nil || n != len(tc) {
+			t.Errorf("decode %q failed: %v %d %v", tc, v, n, err)
+			continue
+		}
+		if encoded := v.Encode(nil); !bytes.Equal(encoded, []byte(tc)) {
+			t.Errorf("encode %q got %q", tc, encoded)
+		}
+	}
+}
+
+func TestDecodeIncomplete(t *testing.T) {
+	data := []byte("*2\r\n$3\r\nget\r\n$3\r\nkey\r\n")
+	for i := 0; i < len(data); i++ {
+		v, n, err := Decode(data[:i])
+		if v != nil || n != 0 || err != nil {
+			t.Errorf("decode incomplete data %q should wait for more data", data[:i])
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	testCases := []string{
+		"?\r\n",
+		":abc\r\n",
+		"$abc\r\n",
+		"$3\r\nabcde\r\n",
+		"*x\r\n",
+		"*1\r\n!\r\n",
+	}
+	for _, tc := range testCases {
+		if _, _, err := Decode([]byte(tc)); err != ErrProtocol {
+			t.Errorf("decode %q expected protocol error, got %v", tc, err)
+		}
+	}
+}
+
+func TestDecodeCopy(t *testing.T) {
+	data := []byte("$5\r\nhello\r\n")
+	v, _, _ := Decode(data)
+	copy(data, "xxxxxxxxxxx")
+	if string(v.Str) != "hello" {
+		t.Errorf("decoded value should not share the data, got %s", v.Str)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+var (
+	ErrNoCluster           = errors.New("no cluster found")
+	ErrNoHealthyUpstream   = errors.New("no healthy upstream")
+	ErrUpstreamConnect     = errors.New("upstream connect failed")
+	errUpstreamClosedReply = NewError("ERR upstream connection closed")
+)
+
+// upstream sends the commands to a redis server, the callback is called with the reply
+type upstream interface {
+	Send(command *Value, cb func(reply *Value))
+}
+
+// router routes a key to the upstream of its shard
+type router interface {
+	Route(key []byte) (upstream, error)
+	// Close closes the upstreams
+	Close()
+}
+
+// clusterRouter chooses the shard by the cluster load balancer with the hash of the key,
+// a consistent hash load balancer (ring hash or maglev) keeps the keys on the same host.
+// The upstream connections are owned by the downstream connection.
+type clusterRouter struct {
+	cluster        string
+	clusterManager types.ClusterManager
+	downstream     types.Connection
+
+	mutex   sync.Mutex
+	clients map[string]*upstreamClient
+	closed  bool
+}
+
+func newClusterRouter(cluster string, clusterManager types.ClusterManager, downstream types.Connection) *clusterRouter {
+	return &clusterRouter{
+		cluster:        cluster,
+		clusterManager: clusterManager,
+		downstream:     downstream,
+		clients:        make(map[string]*upstreamClient),
+	}
+}
+
+func (r *clusterRouter) Route(key []byte) (upstream, error) {
+	lbCtx := &LbContext{
+		hashKey: utils.HashString(string(key)),
+		conn:    r.downstream,
+	}
+	snapshot := r.clusterManager.GetClusterSnapshot(context.Background(), r.cluster)
+	if snapshot == nil || reflect.ValueOf(snapshot).IsNil() {
+		return nil, ErrNoCluster
+	}
+	host := snapshot.LoadBalancer().ChooseHost(lbCtx)
+	if host == nil {
+		return nil, ErrNoHealthyUpstream
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return nil, ErrUpstreamConnect
+	}
+	addr := host.AddressString()
+	if client, ok := r.clients[addr]; ok {
+		return client, nil
+	}
+	data := host.CreateConnection(lbCtx.DownstreamContext())
+	if data.Connection == nil {
+		return nil, ErrUpstreamConnect
+	}
+	client := &upstreamClient{
+		conn: data.Connection,
+	}
+	client.onClose = func() {
+		r.mutex.Lock()
+		if r.clients[addr] == client {
+			delete(r.clients, addr)
+		}
+		r.mutex.Unlock()
+	}
+	data.Connection.AddConnectionEventListener(client)
+	data.Connection.FilterManager().AddReadFilter(client)
+	if err := data.Connection.Connect(true); err != nil {
+		log.DefaultLogger.Errorf("[redisproxy] connect to upstream %s failed: %v", addr, err)
+		return nil, ErrUpstreamConnect
+	}
+	r.clients[addr] = client
+	return client, nil
+}
+
+func (r *clusterRouter) Close() {
+	r.mutex.Lock()
+	r.closed = true
+	clients := r.clients
+	r.clients = make(map[string]*upstreamClient)
+	r.mutex.Unlock()
+
+	for _, client := range clients {
+		client.conn.Close(types.NoFlush, types.LocalClose)
+	}
+}
+
+// upstreamClient is a connection to a redis server,
+// the replies are returned in the order of the commands, so the callbacks are queued
+type upstreamClient struct {
+	conn    types.ClientConnection
+	onClose func()
+
+	mutex     sync.Mutex
+	callbacks []func(*Value)
+	closed    bool
+}
+
+func (c *upstreamClient) Send(command *Value, cb func(reply *Value)) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		cb(errUpstreamClosedReply)
+		return
+	}
+	c.callbacks = append(c.callbacks, cb)
+	// write in the lock, so the commands are written in the order of the callbacks
+	c.conn.Write(buffer.NewIoBufferBytes(command.Encode(nil)))
+	c.mutex.Unlock()
+}
+
+func (c *upstreamClient) OnData(buf types.IoBuffer) types.FilterStatus {
+	for buf.Len() > 0 {
+		reply, n, err := Decode(buf.Bytes())
+		if err != nil {
+			log.DefaultLogger.Errorf("[redisproxy] decode upstream reply error: %v", err)
+			buf.Drain(buf.Len())
+			c.conn.Close(types.NoFlush, types.LocalClose)
+			return types.Stop
+		}
+		if reply == nil {
+			break
+		}
+		buf.Drain(n)
+
+		c.mutex.Lock()
+		if len(c.callbacks) == 0 {
+			c.mutex.Unlock()
+			log.DefaultLogger.Errorf("[redisproxy] receive upstream reply without request")
+			continue
+		}
+		cb := c.callbacks[0]
+		c.callbacks = c.callbacks[1:]
+		c.mutex.Unlock()
+		cb(reply)
+	}
+	return types.Stop
+}
+
+func (c *upstreamClient) OnNewConnection() types.FilterStatus {
+	return types.Continue
+}
+
+func (c *upstreamClient) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {}
+
+// OnEvent fails the pending commands when the connection is closed
+func (c *upstreamClient) OnEvent(event types.ConnectionEvent) {
+	switch {
+	case event == types.Connected:
+		c.conn.SetNoDelay(true)
+	case event.IsClose() || event == types.ConnectFailed || event == types.ConnectTimeout:
+		c.mutex.Lock()
+		if c.closed {
+			c.mutex.Unlock()
+			return
+		}
+		c.closed = true
+		callbacks := c.callbacks
+		c.callbacks = nil
+		c.mutex.Unlock()
+
+		for _, cb := range callbacks {
+			cb(errUpstreamClosedReply)
+		}
+		if c.onClose != nil {
+			c.onClose()
+		}
+	}
+}
+
+// LbContext is a types.LoadBalancerContext implementation, the hash key is the hash of the redis key
+type LbContext struct {
+	hashKey uint64
+	conn    types.Connection
+}
+
+func (c *LbContext) MetadataMatchCriteria() types.MetadataMatchCriteria {
+	return nil
+}
+
+func (c *LbContext) DownstreamConnection() net.Conn {
+	return c.conn.RawConn()
+}
+
+// Redis Proxy have no header
+func (c *LbContext) DownstreamHeaders() types.HeaderMap {
+	return nil
+}
+
+// DownstreamContext contains the downstream addresses, used to create the upstream connection
+func (c *LbContext) DownstreamContext() context.Context {
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyDownstreamRemoteAddr, c.conn.RemoteAddr())
+	return mosnctx.WithValue(ctx, types.ContextKeyDownstreamLocalAddr, c.conn.LocalAddr())
+}
+
+func (c *LbContext) ComputeHashKey() (uint64, bool) {
+	return c.hashKey, true
+}
+
+// Redis Proxy have no retry
+func (c *LbContext) HostSelectionRetryCount() uint32 {
+	return 0
+}
+
+func (c *LbContext) ShouldSelectAnotherHost(host types.Host) bool {
+	return false
+}
@@ -426,6 +426,23 @@ func (c *LbContext) DownstreamHeaders() types.HeaderMap {
 	return nil
 }
 
+// DownstreamContext contains the downstream addresses, used to create the upstream connection
 func (c *LbContext) DownstreamContext() context.Context {
-	return nil
+	conn := c.conn.Connection()
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyDownstreamRemoteAddr, conn.RemoteAddr())
+	return mosnctx.WithValue(ctx, types.ContextKeyDownstreamLocalAddr, conn.LocalAddr())
+}
+
+// TCP Proxy have no hash policy
+func (c *LbContext) ComputeHashKey() (uint64, bool) {
+	return 0, false
+}
+
+// TCP Proxy have no retry
+func (c *LbContext) HostSelectionRetryCount() uint32 {
+	return 0
+}
+
+func (c *LbContext) ShouldSelectAnotherHost(host types.Host) bool {
+	return false
 }
@@ -18,16 +18,21 @@
 package log
 
 import (
+	"encoding/json"
+	"sort"
 	"strconv"
 	"strings"
 
 	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/protocol/sofarpc/models"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
 // RequestInfoFuncMap is a map which key is the format-key, value is the func to get corresponding string value
+// ReqHeaderFuncMap is a map which key is the format-key, value is the func to get corresponding string value from request headers
 var (
 	RequestInfoFuncMap      map[string]func(info types.RequestInfo) string
+	ReqHeaderFuncMap        map[string]func(reqHeaders types.HeaderMap) string
 	DefaultDisableAccessLog bool
 	accessLogs              []*accesslog
 )
@@ -50,6 +55,15 @@ func init() {
 		types.LogDownstreamLocalAddress:     DownstreamLocalAddressGetter,
 		types.LogDownstreamRemoteAddress:    DownstreamRemoteAddressGetter,
 		types.LogUpstreamHostSelectedGetter: UpstreamHostSelectedGetter,
+		types.LogRouteName:                  RouteNameGetter,
+		types.LogClusterName:                ClusterNameGetter,
+		types.LogUpstreamConnectDuration:    UpstreamConnectDurationGetter,
+		types.LogRetryCount:                 RetryCountGetter,
+		types.LogTraceId:                    TraceIdGetter,
+	}
+	ReqHeaderFuncMap = map[string]func(reqHeaders types.HeaderMap) string{
+		types.LogSofaRpcService: SofaRpcServiceGetter,
+		types.LogSofaRpcMethod:  SofaRpcMethodGetter,
 	}
 	accessLogs = []*accesslog{}
 }
@@ -72,6 +86,18 @@ type accesslog struct {
 // NewAccessLog
 func NewAccessLog(output string, filter types.AccessLogFilter,
 	format string) (types.AccessLog, error) {
+	return newAccessLog(output, filter, NewAccessLogFormatter(format))
+}
+
+// NewJSONAccessLog creates an access log that prints a json object for each request,
+// the fields' key is the json field's name, and the value is the field's format
+func NewJSONAccessLog(output string, filter types.AccessLogFilter,
+	fields map[string]string) (types.AccessLog, error) {
+	return newAccessLog(output, filter, NewJSONAccessLogFormatter(fields))
+}
+
+func newAccessLog(output string, filter types.AccessLogFilter,
+	formatter types.AccessLogFormatter) (types.AccessLog, error) {
 	lg, err := GetOrCreateLogger(output)
 	if err != nil {
 		return nil, err
@@ -79,7 +105,7 @@ func NewAccessLog(output string, filter types.AccessLogFilter,
 	l := &accesslog{
 		output:    output,
 		filter:    filter,
-		formatter: NewAccessLogFormatter(format),
+		formatter: formatter,
 		logger:    lg,
 	}
 	if DefaultDisableAccessLog {
@@ -105,7 +131,7 @@ func (l *accesslog) Log(reqHeaders types.HeaderMap, respHeaders types.HeaderMap,
 	buf := buffer.GetIoBuffer(AccessLogLen)
 	l.formatter.Format(buf, reqHeaders, respHeaders, requestInfo)
 	// delete first " "
-	if buf.Len() > 0 {
+	if buf.Len() > 0 && buf.Bytes()[0] == ' ' {
 		buf.Drain(1)
 	}
 	buf.WriteString("\n")
@@ -211,58 +237,162 @@ func (f *simpleRespHeadersFormatter) Format(buf types.IoBuffer, reqHeaders types
 	}
 }
 
-// format to formatter by parsing format
-func formatToFormatter(format string) []types.AccessLogFormatter {
+// types.AccessLogFormatter
+type headerValueFormatter struct {
+	getters []func(reqHeaders types.HeaderMap, respHeaders types.HeaderMap) string
+}
 
-	strArray := strings.Split(format, " ")
+// Format header values, the missing header is printed as "-"
+func (f *headerValueFormatter) Format(buf types.IoBuffer, reqHeaders types.HeaderMap, respHeaders types.HeaderMap, requestInfo types.RequestInfo) {
+	for _, getter := range f.getters {
+		buf.WriteString(" ")
+		s := getter(reqHeaders, respHeaders)
+		if s == "" {
+			s = "-"
+		}
+		buf.WriteString(s)
+	}
+}
 
-	// delete %
-	for i := 0; i < len(strArray); i++ {
-		strArray[i] = strArray[i][1 : len(strArray[i])-1]
+// types.AccessLogFormatter
+type jsonFormatter struct {
+	keys       []string
+	formatters []types.AccessLogFormatter
+}
+
+// NewJSONAccessLogFormatter creates a formatter that formats a json object,
+// the fields are sorted by the key, and each field's value is formatted as a string.
+func NewJSONAccessLogFormatter(fields map[string]string) types.AccessLogFormatter {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	f := &jsonFormatter{}
+	for _, name := range names {
+		key, _ := json.Marshal(name)
+		f.keys = append(f.keys, string(key))
+		f.formatters = append(f.formatters, &accesslogformatter{
+			formatters: formatToFormatter(fields[name]),
+		})
+	}
+	return f
+}
 
-	// classify keys
-	var reqInfoArray, reqHeaderArray, respHeaderArray []string
-	for _, s := range strArray {
-		if strings.HasPrefix(s, types.ReqHeaderPrefix) {
-			reqHeaderArray = append(reqHeaderArray, s)
+func (f *jsonFormatter) Format(buf types.IoBuffer, reqHeaders types.HeaderMap, respHeaders types.HeaderMap, requestInfo types.RequestInfo) {
+	value := buffer.GetIoBuffer(AccessLogLen)
+	defer buffer.PutIoBuffer(value)
 
-		} else if strings.HasPrefix(s, types.RespHeaderPrefix) {
-			respHeaderArray = append(respHeaderArray, s)
-		} else {
-			reqInfoArray = append(reqInfoArray, s)
+	buf.WriteString("{")
+	for i, formatter := range f.formatters {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(f.keys[i])
+		buf.WriteString(":")
+
+		value.Reset()
+		formatter.Format(value, reqHeaders, respHeaders, requestInfo)
+		// delete first " "
+		if value.Len() > 0 {
+			value.Drain(1)
 		}
+		s, _ := json.Marshal(value.String())
+		buf.Write(s)
 	}
+	buf.WriteString("}")
+}
 
-	// delete REQ.
-	if reqHeaderArray != nil {
-		for i := 0; i < len(reqHeaderArray); i++ {
-			reqHeaderArray[i] = reqHeaderArray[i][len(types.ReqHeaderPrefix):]
+// format to formatter by parsing format
+// the keys of the same kind are grouped into one formatter, and the formatters keep the order of the format
+func formatToFormatter(format string) []types.AccessLogFormatter {
+	var formatters []types.AccessLogFormatter
+	last := func() types.AccessLogFormatter {
+		if len(formatters) == 0 {
+			return nil
 		}
+		return formatters[len(formatters)-1]
 	}
-
-	// delete RESP.
-	if respHeaderArray != nil {
-		for i := 0; i < len(respHeaderArray); i++ {
-			respHeaderArray[i] = respHeaderArray[i][len(types.RespHeaderPrefix):]
+	appendHeaderGetter := func(getter func(reqHeaders types.HeaderMap, respHeaders types.HeaderMap) string) {
+		if f, ok := last().(*headerValueFormatter); ok {
+			f.getters = append(f.getters, getter)
+			return
 		}
+		formatters = append(formatters, &headerValueFormatter{
+			getters: []func(reqHeaders types.HeaderMap, respHeaders types.HeaderMap) string{getter},
+		})
 	}
 
-	// set info function
-	var infoFunc []func(info types.RequestInfo) string
-	for _, key := range reqInfoArray {
-		if vFunc, ok := RequestInfoFuncMap[key]; ok {
-			infoFunc = append(infoFunc, vFunc)
-		} else {
-			DefaultLogger.Debugf("Invalid ReqInfo Format Keys: %s", key)
+	for _, s := range strings.Split(format, " ") {
+		// delete %
+		if len(s) < 2 || s[0] != '%' || s[len(s)-1] != '%' {
+			DefaultLogger.Debugf("Invalid Format Keys: %s", s)
+			continue
+		}
+		key := s[1 : len(s)-1]
+
+		switch {
+		case strings.HasPrefix(key, types.ReqHeaderOperator) && strings.HasSuffix(key, ")"):
+			name := key[len(types.ReqHeaderOperator) : len(key)-1]
+			appendHeaderGetter(func(reqHeaders types.HeaderMap, respHeaders types.HeaderMap) string {
+				return headerValue(reqHeaders, name)
+			})
+
+		case strings.HasPrefix(key, types.RespHeaderOperator) && strings.HasSuffix(key, ")"):
+			name := key[len(types.RespHeaderOperator) : len(key)-1]
+			appendHeaderGetter(func(reqHeaders types.HeaderMap, respHeaders types.HeaderMap) string {
+				return headerValue(respHeaders, name)
+			})
+
+		case strings.HasPrefix(key, types.ReqHeaderPrefix):
+			// delete REQ.
+			name := key[len(types.ReqHeaderPrefix):]
+			if f, ok := last().(*simpleReqHeadersFormatter); ok {
+				f.reqHeaderFormat = append(f.reqHeaderFormat, name)
+			} else {
+				formatters = append(formatters, &simpleReqHeadersFormatter{reqHeaderFormat: []string{name}})
+			}
+
+		case strings.HasPrefix(key, types.RespHeaderPrefix):
+			// delete RESP.
+			name := key[len(types.RespHeaderPrefix):]
+			if f, ok := last().(*simpleRespHeadersFormatter); ok {
+				f.respHeaderFormat = append(f.respHeaderFormat, name)
+			} else {
+				formatters = append(formatters, &simpleRespHeadersFormatter{respHeaderFormat: []string{name}})
+			}
+
+		default:
+			if hFunc, ok := ReqHeaderFuncMap[key]; ok {
+				appendHeaderGetter(func(reqHeaders types.HeaderMap, respHeaders types.HeaderMap) string {
+					if reqHeaders == nil {
+						return ""
+					}
+					return hFunc(reqHeaders)
+				})
+			} else if vFunc, ok := RequestInfoFuncMap[key]; ok {
+				// set info function
+				if f, ok := last().(*simpleRequestInfoFormatter); ok {
+					f.reqInfoFunc = append(f.reqInfoFunc, vFunc)
+				} else {
+					formatters = append(formatters, &simpleRequestInfoFormatter{reqInfoFunc: []func(info types.RequestInfo) string{vFunc}})
+				}
+			} else {
+				DefaultLogger.Debugf("Invalid ReqInfo Format Keys: %s", key)
+			}
 		}
 	}
 
-	return []types.AccessLogFormatter{
-		&simpleRequestInfoFormatter{reqInfoFunc: infoFunc},
-		&simpleReqHeadersFormatter{reqHeaderFormat: reqHeaderArray},
-		&simpleRespHeadersFormatter{respHeaderFormat: respHeaderArray},
+	return formatters
+}
+
+func headerValue(headers types.HeaderMap, key string) string {
+	if headers == nil {
+		return ""
 	}
+	v, _ := headers.Get(key)
+	return v
 }
 
 // StartTimeGetter
@@ -358,3 +488,57 @@ func UpstreamHostSelectedGetter(info types.RequestInfo) string {
 	}
 	return ""
 }
+
+// RouteNameGetter
+// get the name of the request's route
+func RouteNameGetter(info types.RequestInfo) string {
+	if info.RouteEntry() != nil {
+		return info.RouteEntry().RouteName()
+	}
+	return ""
+}
+
+// ClusterNameGetter
+// get the name of the cluster that the request is forwarded to
+func ClusterNameGetter(info types.RequestInfo) string {
+	if info.UpstreamHost() != nil && info.UpstreamHost().ClusterInfo() != nil {
+		return info.UpstreamHost().ClusterInfo().Name()
+	}
+	return ""
+}
+
+// UpstreamConnectDurationGetter
+// get duration between requesting an upstream stream and the stream is ready
+func UpstreamConnectDurationGetter(info types.RequestInfo) string {
+	return info.UpstreamConnectDuration().String()
+}
+
+// RetryCountGetter
+// get the times the request is retried
+func RetryCountGetter(info types.RequestInfo) string {
+	return strconv.FormatUint(uint64(info.RetryCount()), 10)
+}
+
+// TraceIdGetter
+// get the request's trace id
+func TraceIdGetter(info types.RequestInfo) string {
+	return info.TraceId()
+}
+
+// SofaRpcServiceGetter
+// get the service of a SOFARPC request
+func SofaRpcServiceGetter(reqHeaders types.HeaderMap) string {
+	if v, ok := reqHeaders.Get(models.SERVICE_KEY); ok {
+		return v
+	}
+	// old key
+	v, _ := reqHeaders.Get(models.TARGET_SERVICE_KEY)
+	return v
+}
+
+// SofaRpcMethodGetter
+// get the method of a SOFARPC request
+func SofaRpcMethodGetter(reqHeaders types.HeaderMap) string {
+	v, _ := reqHeaders.Get(models.TARGET_METHOD)
+	return v
+}
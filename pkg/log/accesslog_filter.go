@@ -0,0 +1,251 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// The access log filter's types
+const (
+	StatusCodeFilter      = "status_code"
+	DurationFilter        = "duration"
+	RuntimeFractionFilter = "runtime_fraction"
+	AndFilter             = "and"
+	OrFilter              = "or"
+)
+
+// The comparison operators of the access log filter
+const (
+	FilterOpEQ = "eq"
+	FilterOpNE = "ne"
+	FilterOpGT = "gt"
+	FilterOpGE = "ge"
+	FilterOpLT = "lt"
+	FilterOpLE = "le"
+)
+
+var (
+	ErrUnknownFilterType = errors.New("unknown access log filter type")
+	ErrInvalidFilterOp   = errors.New("invalid access log filter comparison operator")
+	ErrInvalidFraction   = errors.New("invalid access log runtime fraction")
+)
+
+// comparison compares a request's value with the configured value
+type comparison struct {
+	op    string
+	value int64
+}
+
+func newComparison(op string, value int64) (comparison, error) {
+	switch op {
+	case FilterOpEQ, FilterOpNE, FilterOpGT, FilterOpGE, FilterOpLT, FilterOpLE:
+		return comparison{op: op, value: value}, nil
+	}
+	return comparison{}, ErrInvalidFilterOp
+}
+
+func (c comparison) compare(v int64) bool {
+	switch c.op {
+	case FilterOpEQ:
+		return v == c.value
+	case FilterOpNE:
+		return v != c.value
+	case FilterOpGT:
+		return v > c.value
+	case FilterOpGE:
+		return v >= c.value
+	case FilterOpLT:
+		return v < c.value
+	case FilterOpLE:
+		return v <= c.value
+	}
+	return false
+}
+
+// types.AccessLogFilter
+type statusCodeFilter struct {
+	comparison
+}
+
+// NewStatusCodeFilter creates a filter that logs the request if the response code matches the comparison
+func NewStatusCodeFilter(op string, code int) (types.AccessLogFilter, error) {
+	c, err := newComparison(op, int64(code))
+	if err != nil {
+		return nil, err
+	}
+	return &statusCodeFilter{comparison: c}, nil
+}
+
+func (f *statusCodeFilter) Decide(reqHeaders types.HeaderMap, requestInfo types.RequestInfo) bool {
+	return f.compare(int64(requestInfo.ResponseCode()))
+}
+
+// types.AccessLogFilter
+type durationFilter struct {
+	comparison
+}
+
+// NewDurationFilter creates a filter that logs the request if the request's duration matches the comparison
+func NewDurationFilter(op string, duration time.Duration) (types.AccessLogFilter, error) {
+	c, err := newComparison(op, int64(duration))
+	if err != nil {
+		return nil, err
+	}
+	return &durationFilter{comparison: c}, nil
+}
+
+func (f *durationFilter) Decide(reqHeaders types.HeaderMap, requestInfo types.RequestInfo) bool {
+	return f.compare(int64(requestInfo.RequestFinishedDuration()))
+}
+
+// types.AccessLogFilter
+type runtimeFractionFilter struct {
+	numerator   uint32
+	denominator uint32
+}
+
+// NewRuntimeFractionFilter creates a filter that logs numerator/denominator of the requests randomly
+func NewRuntimeFractionFilter(numerator, denominator uint32) (types.AccessLogFilter, error) {
+	if denominator == 0 || numerator > denominator {
+		return nil, ErrInvalidFraction
+	}
+	return &runtimeFractionFilter{
+		numerator:   numerator,
+		denominator: denominator,
+	}, nil
+}
+
+func (f *runtimeFractionFilter) Decide(reqHeaders types.HeaderMap, requestInfo types.RequestInfo) bool {
+	return uint32(rand.Int63n(int64(f.denominator))) < f.numerator
+}
+
+// types.AccessLogFilter
+type andFilter struct {
+	filters []types.AccessLogFilter
+}
+
+// NewAndFilter creates a filter that logs the request if all of the filters pass
+func NewAndFilter(filters ...types.AccessLogFilter) types.AccessLogFilter {
+	return &andFilter{filters: filters}
+}
+
+func (f *andFilter) Decide(reqHeaders types.HeaderMap, requestInfo types.RequestInfo) bool {
+	for _, filter := range f.filters {
+		if !filter.Decide(reqHeaders, requestInfo) {
+			return false
+		}
+	}
+	return true
+}
+
+// types.AccessLogFilter
+type orFilter struct {
+	filters []types.AccessLogFilter
+}
+
+// NewOrFilter creates a filter that logs the request if any of the filters passes
+func NewOrFilter(filters ...types.AccessLogFilter) types.AccessLogFilter {
+	return &orFilter{filters: filters}
+}
+
+func (f *orFilter) Decide(reqHeaders types.HeaderMap, requestInfo types.RequestInfo) bool {
+	for _, filter := range f.filters {
+		if filter.Decide(reqHeaders, requestInfo) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterConfig contains all of the access log filters' config
+type filterConfig struct {
+	Op          string                   `json:"op,omitempty"`
+	Value       json.RawMessage          `json:"value,omitempty"`
+	Numerator   uint32                   `json:"numerator,omitempty"`
+	Denominator uint32                   `json:"denominator,omitempty"`
+	Filters     []map[string]interface{} `json:"filters,omitempty"`
+}
+
+// CreateAccessLogFilter creates an access log filter by the filter's type and config, such as:
+//
+//	status_code:      {"op": "ge", "value": 500}
+//	duration:         {"op": "ge", "value": "1s"}
+//	runtime_fraction: {"numerator": 1, "denominator": 100}
+//	and / or:         {"filters": [{"type": "status_code", "config": {...}}, ...]}
+func CreateAccessLogFilter(typ string, config map[string]interface{}) (types.AccessLogFilter, error) {
+	cfg := &filterConfig{}
+	if data, err := json.Marshal(config); err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse access log filter %s config failed: %v", typ, err)
+		}
+	} else {
+		return nil, err
+	}
+
+	switch typ {
+	case StatusCodeFilter:
+		var code int
+		if err := json.Unmarshal(cfg.Value, &code); err != nil {
+			return nil, fmt.Errorf("parse access log filter %s value failed: %v", typ, err)
+		}
+		return NewStatusCodeFilter(cfg.Op, code)
+
+	case DurationFilter:
+		var s string
+		if err := json.Unmarshal(cfg.Value, &s); err != nil {
+			return nil, fmt.Errorf("parse access log filter %s value failed: %v", typ, err)
+		}
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("parse access log filter %s value failed: %v", typ, err)
+		}
+		return NewDurationFilter(cfg.Op, duration)
+
+	case RuntimeFractionFilter:
+		denominator := cfg.Denominator
+		// the fraction is a percentage by default
+		if denominator == 0 {
+			denominator = 100
+		}
+		return NewRuntimeFractionFilter(cfg.Numerator, denominator)
+
+	case AndFilter, OrFilter:
+		filters := make([]types.AccessLogFilter, 0, len(cfg.Filters))
+		for _, sub := range cfg.Filters {
+			subType, _ := sub["type"].(string)
+			subConfig, _ := sub["config"].(map[string]interface{})
+			filter, err := CreateAccessLogFilter(subType, subConfig)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+		if typ == AndFilter {
+			return NewAndFilter(filters...), nil
+		}
+		return NewOrFilter(filters...), nil
+	}
+
+	return nil, ErrUnknownFilterType
+}
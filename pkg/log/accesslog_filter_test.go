@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package log
+
+import (
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestStatusCodeFilter(t *testing.T) {
+	requestInfo := newRequestInfo()
+	requestInfo.SetResponseCode(503)
+	testCases := []struct {
+		op       string
+		code     int
+		expected bool
+	}{
+		{FilterOpEQ, 503, true},
+		{FilterOpNE, 503, false},
+		{FilterOpGE, 500, true},
+		{FilterOpGT, 503, false},
+		{FilterOpLE, 503, true},
+		{FilterOpLT, 500, false},
+	}
+	for i, tc := range testCases {
+		filter, err := NewStatusCodeFilter(tc.op, tc.code)
+		if err != nil {
+			t.Fatalf("#%d create filter failed: %v", i, err)
+		}
+		if filter.Decide(nil, requestInfo) != tc.expected {
+			t.Errorf("#%d %s %d expected %v", i, tc.op, tc.code, tc.expected)
+		}
+	}
+	if _, err := NewStatusCodeFilter("gte", 500); err != ErrInvalidFilterOp {
+		t.Errorf("invalid op expected an error, but got: %v", err)
+	}
+}
+
+func TestDurationFilter(t *testing.T) {
+	requestInfo := newRequestInfo()
+	requestInfo.SetRequestFinishedDuration(requestInfo.StartTime().Add(2 * time.Second))
+	slow, _ := NewDurationFilter(FilterOpGE, time.Second)
+	if !slow.Decide(nil, requestInfo) {
+		t.Error("the slow request should be logged")
+	}
+	fast, _ := NewDurationFilter(FilterOpLT, time.Second)
+	if fast.Decide(nil, requestInfo) {
+		t.Error("the slow request should not be logged")
+	}
+}
+
+func TestRuntimeFractionFilter(t *testing.T) {
+	if _, err := NewRuntimeFractionFilter(101, 100); err != ErrInvalidFraction {
+		t.Errorf("invalid fraction expected an error, but got: %v", err)
+	}
+	none, _ := NewRuntimeFractionFilter(0, 100)
+	all, _ := NewRuntimeFractionFilter(100, 100)
+	half, _ := NewRuntimeFractionFilter(50, 100)
+	logged := 0
+	for i := 0; i < 10000; i++ {
+		if none.Decide(nil, nil) {
+			t.Fatal("the 0% fraction should not log")
+		}
+		if !all.Decide(nil, nil) {
+			t.Fatal("the 100% fraction should log all")
+		}
+		if half.Decide(nil, nil) {
+			logged++
+		}
+	}
+	if logged < 4000 || logged > 6000 {
+		t.Errorf("the 50%% fraction logged %d of 10000", logged)
+	}
+}
+
+func TestCreateAccessLogFilter(t *testing.T) {
+	filter, err := CreateAccessLogFilter(OrFilter, map[string]interface{}{
+		"filters": []interface{}{
+			map[string]interface{}{
+				"type":   StatusCodeFilter,
+				"config": map[string]interface{}{"op": "ge", "value": 500},
+			},
+			map[string]interface{}{
+				"type":   DurationFilter,
+				"config": map[string]interface{}{"op": "gt", "value": "1s"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		code     int
+		duration time.Duration
+		expected bool
+	}{
+		{200, time.Millisecond, false},
+		{502, time.Millisecond, true},
+		{200, 2 * time.Second, true},
+	}
+	for i, tc := range testCases {
+		requestInfo := newRequestInfo()
+		requestInfo.SetResponseCode(tc.code)
+		requestInfo.SetRequestFinishedDuration(requestInfo.StartTime().Add(tc.duration))
+		if filter.Decide(nil, requestInfo) != tc.expected {
+			t.Errorf("#%d expected %v", i, tc.expected)
+		}
+	}
+
+	fraction, err := CreateAccessLogFilter(RuntimeFractionFilter, map[string]interface{}{"numerator": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fraction.Decide(nil, nil) {
+		t.Error("the denominator should be 100 by default")
+	}
+
+	for _, invalid := range []struct {
+		typ    string
+		config map[string]interface{}
+	}{
+		{"unknown", nil},
+		{StatusCodeFilter, map[string]interface{}{"op": "ge", "value": "500"}},
+		{DurationFilter, map[string]interface{}{"op": "ge", "value": "1"}},
+		{AndFilter, map[string]interface{}{"filters": []interface{}{map[string]interface{}{"type": "unknown"}}}},
+	} {
+		if _, err := CreateAccessLogFilter(invalid.typ, invalid.config); err == nil {
+			t.Errorf("create filter %s with config %v expected an error", invalid.typ, invalid.config)
+		}
+	}
+}
+
+var _ types.AccessLogFilter = NewAndFilter()
@@ -18,6 +18,7 @@
 package log
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -28,6 +29,7 @@ import (
 	"os"
 	"regexp"
 
+	"sofastack.io/sofa-mosn/pkg/buffer"
 	"sofastack.io/sofa-mosn/pkg/protocol"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
@@ -42,7 +44,7 @@ func TestAccessLog(t *testing.T) {
 	accessLog, err := NewAccessLog(logName, nil, format)
 
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err)
 	}
 	reqHeaders := map[string]string{
 		"service": "test",
@@ -169,6 +171,108 @@ func TestAccessLogManage(t *testing.T) {
 	}
 }
 
+type mockRouteRule struct {
+	types.RouteRule
+	name string
+}
+
+func (r *mockRouteRule) RouteName() string {
+	return r.name
+}
+
+func TestAccessLogFormatOperators(t *testing.T) {
+	format := "%Protocol% %REQ(x-foo)% %RESP(x-bar)% %REQ(x-missing)% %RouteName% %RetryCount%" + " " +
+		"%UpstreamConnectDuration% %TraceId% %SofaRpcService% %SofaRpcMethod% %REQ.service%"
+	formatter := NewAccessLogFormatter(format)
+	reqHeaders := protocol.CommonHeader{
+		"x-foo":                 "foo",
+		"service":               "com.alipay.test.TestService:1.0",
+		"sofa_head_method_name": "echo",
+	}
+	respHeaders := protocol.CommonHeader{
+		"x-bar": "bar",
+	}
+	requestInfo := newRequestInfo()
+	requestInfo.SetRouteEntry(&mockRouteRule{name: "test_route"})
+	requestInfo.SetRetryCount(2)
+	requestInfo.SetUpstreamConnectDuration(time.Millisecond)
+	requestInfo.SetTraceId("0a0fe8c71568000000001")
+
+	buf := buffer.NewIoBuffer(AccessLogLen)
+	formatter.Format(buf, reqHeaders, respHeaders, requestInfo)
+	// the keys keep the order of the format
+	expected := " - foo bar - test_route 2 1ms 0a0fe8c71568000000001 com.alipay.test.TestService:1.0 echo REQ.com.alipay.test.TestService:1.0"
+	if buf.String() != expected {
+		t.Errorf("format access log unexpected, got: %q, want: %q", buf.String(), expected)
+	}
+
+	// the old service key is supported
+	buf.Reset()
+	NewAccessLogFormatter("%SofaRpcService% %ClusterName%").Format(buf, protocol.CommonHeader{
+		"sofa_head_target_service": "com.alipay.test.OldService:1.0",
+	}, nil, newRequestInfo())
+	if buf.String() != " com.alipay.test.OldService:1.0 -" {
+		t.Errorf("format access log unexpected, got: %q", buf.String())
+	}
+}
+
+func TestJSONAccessLogFormat(t *testing.T) {
+	formatter := NewJSONAccessLogFormatter(map[string]string{
+		"code":     "%ResponseCode%",
+		"route":    "%RouteName%",
+		"foo":      "%REQ(x-foo)%",
+		"upstream": "%UpstreamHostSelected% %RetryCount%",
+	})
+	reqHeaders := protocol.CommonHeader{
+		"x-foo": "a \"quoted\" value",
+	}
+	requestInfo := newRequestInfo()
+	requestInfo.SetResponseCode(200)
+	requestInfo.SetRetryCount(1)
+
+	buf := buffer.NewIoBuffer(AccessLogLen)
+	formatter.Format(buf, reqHeaders, nil, requestInfo)
+	// the fields are sorted by the key
+	expected := `{"code":"200","foo":"a \"quoted\" value","route":"-","upstream":"- 1"}`
+	if buf.String() != expected {
+		t.Fatalf("format json access log unexpected, got: %s, want: %s", buf.String(), expected)
+	}
+	fields := map[string]string{}
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatalf("the json access log is invalid: %v", err)
+	}
+}
+
+func TestJSONAccessLog(t *testing.T) {
+	DefaultDisableAccessLog = false
+	logName := "/tmp/mosn_accesslog/json_access.log"
+	os.Remove(logName)
+	filter, err := NewStatusCodeFilter(FilterOpGE, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessLog, err := NewJSONAccessLog(logName, filter, map[string]string{
+		"code": "%ResponseCode%",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestInfo := newRequestInfo()
+	requestInfo.SetResponseCode(200)
+	// filtered
+	accessLog.Log(protocol.CommonHeader{}, nil, requestInfo)
+	requestInfo.SetResponseCode(503)
+	accessLog.Log(protocol.CommonHeader{}, nil, requestInfo)
+	time.Sleep(time.Second)
+	b, err := ioutil.ReadFile(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "{\"code\":\"503\"}\n" {
+		t.Fatalf("json access log unexpected: %q", string(b))
+	}
+}
+
 func BenchmarkAccessLog(b *testing.B) {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	InitDefaultLogger("", INFO)
@@ -176,7 +280,7 @@ func BenchmarkAccessLog(b *testing.B) {
 	accessLog, err := NewAccessLog("/tmp/mosn_bench/benchmark_access.log", nil, "")
 
 	if err != nil {
-		fmt.Errorf(err.Error())
+		b.Fatal(err)
 	}
 	reqHeaders := map[string]string{
 		"service": "test",
@@ -210,7 +314,7 @@ func BenchmarkAccessLogParallel(b *testing.B) {
 	accessLog, err := NewAccessLog("/tmp/mosn_bench/benchmark_access.log", nil, "")
 
 	if err != nil {
-		b.Errorf(err.Error())
+		b.Error(err)
 	}
 	reqHeaders := map[string]string{
 		"service": "test",
@@ -255,6 +359,9 @@ type mock_requestInfo struct {
 	downstreamRemoteAddress  net.Addr
 	isHealthCheckRequest     bool
 	routerRule               types.RouteRule
+	upstreamConnectDuration  time.Duration
+	retryCount               uint32
+	traceId                  string
 }
 
 // NewrequestInfo
@@ -383,3 +490,27 @@ func (r *mock_requestInfo) RouteEntry() types.RouteRule {
 func (r *mock_requestInfo) SetRouteEntry(routerRule types.RouteRule) {
 	r.routerRule = routerRule
 }
+
+func (r *mock_requestInfo) UpstreamConnectDuration() time.Duration {
+	return r.upstreamConnectDuration
+}
+
+func (r *mock_requestInfo) SetUpstreamConnectDuration(duration time.Duration) {
+	r.upstreamConnectDuration = duration
+}
+
+func (r *mock_requestInfo) RetryCount() uint32 {
+	return r.retryCount
+}
+
+func (r *mock_requestInfo) SetRetryCount(count uint32) {
+	r.retryCount = count
+}
+
+func (r *mock_requestInfo) TraceId() string {
+	return r.traceId
+}
+
+func (r *mock_requestInfo) SetTraceId(traceId string) {
+	r.traceId = traceId
+}
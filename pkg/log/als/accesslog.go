@@ -0,0 +1,412 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package als
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"google.golang.org/grpc"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/server/keeper"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+const (
+	defaultQueueSize             = 4096
+	defaultBatchSize             = 100
+	defaultFlushInterval         = time.Second
+	defaultConnectTimeout        = 5 * time.Second
+	defaultReconnectBaseInterval = 500 * time.Millisecond
+	defaultReconnectMaxInterval  = 30 * time.Second
+
+	streamAccessLogsMethod = "/envoy.service.accesslog.v2.AccessLogService/StreamAccessLogs"
+)
+
+var ErrNoCollector = errors.New("grpc access log collector address is required")
+
+var streamAccessLogsDesc = &grpc.StreamDesc{
+	StreamName:    "StreamAccessLogs",
+	ClientStreams: true,
+}
+
+// types.AccessLog
+// accessLog streams the access log entries to a collector, the entries are queued and sent in batches.
+// If the collector is unreachable or the queue is full, the entries are written to a local file in json.
+type accessLog struct {
+	address               string
+	batchSize             int
+	flushInterval         time.Duration
+	connectTimeout        time.Duration
+	reconnectBaseInterval time.Duration
+	reconnectMaxInterval  time.Duration
+	requestHeadersToLog   []string
+	responseHeadersToLog  []string
+	identifier            []byte
+
+	filter   types.AccessLogFilter
+	queue    chan *HTTPAccessLogEntry
+	fallback *log.Logger
+	// unreachable is set if the collector can not be connected or the stream is broken
+	unreachable uint32
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewAccessLog creates an access log streaming to the collector, the entries are written to the
+// fallback path if the collector is unreachable.
+func NewAccessLog(config *v2.GRPCAccessLog, fallback string, filter types.AccessLogFilter) (types.AccessLog, error) {
+	l, err := newAccessLog(config, fallback, filter)
+	if err != nil {
+		return nil, err
+	}
+	utils.GoWithRecover(l.run, nil)
+	keeper.OnProcessShutDown(func() error {
+		l.Close()
+		return nil
+	})
+	return l, nil
+}
+
+func newAccessLog(config *v2.GRPCAccessLog, fallback string, filter types.AccessLogFilter) (*accessLog, error) {
+	if config.Address == "" {
+		return nil, ErrNoCollector
+	}
+	lg, err := log.GetOrCreateLogger(fallback)
+	if err != nil {
+		return nil, err
+	}
+	if log.DefaultDisableAccessLog {
+		lg.Toggle(true)
+	}
+	identifier, err := encodeIdentifier(&core.Node{
+		Id:      types.ServiceNode,
+		Cluster: types.ServiceCluster,
+	}, config.LogName)
+	if err != nil {
+		return nil, err
+	}
+	l := &accessLog{
+		address:               config.Address,
+		batchSize:             config.BatchSize,
+		flushInterval:         config.FlushInterval.Duration,
+		connectTimeout:        config.ConnectTimeout.Duration,
+		reconnectBaseInterval: config.ReconnectBaseInterval.Duration,
+		reconnectMaxInterval:  config.ReconnectMaxInterval.Duration,
+		requestHeadersToLog:   config.AdditionalRequestHeadersToLog,
+		responseHeadersToLog:  config.AdditionalResponseHeadersToLog,
+		identifier:            identifier,
+		filter:                filter,
+		fallback:              lg,
+		stop:                  make(chan struct{}),
+		done:                  make(chan struct{}),
+	}
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	l.queue = make(chan *HTTPAccessLogEntry, queueSize)
+	if l.batchSize <= 0 {
+		l.batchSize = defaultBatchSize
+	}
+	if l.flushInterval <= 0 {
+		l.flushInterval = defaultFlushInterval
+	}
+	if l.connectTimeout <= 0 {
+		l.connectTimeout = defaultConnectTimeout
+	}
+	if l.reconnectBaseInterval <= 0 {
+		l.reconnectBaseInterval = defaultReconnectBaseInterval
+	}
+	if l.reconnectMaxInterval < l.reconnectBaseInterval {
+		l.reconnectMaxInterval = defaultReconnectMaxInterval
+		if l.reconnectMaxInterval < l.reconnectBaseInterval {
+			l.reconnectMaxInterval = l.reconnectBaseInterval
+		}
+	}
+	return l, nil
+}
+
+func (l *accessLog) Log(reqHeaders types.HeaderMap, respHeaders types.HeaderMap, requestInfo types.RequestInfo) {
+	if log.DefaultDisableAccessLog {
+		return
+	}
+	if l.filter != nil && !l.filter.Decide(reqHeaders, requestInfo) {
+		return
+	}
+	// the request info is reused after the request is finished, so the entry is made before queued
+	entry := l.newEntry(reqHeaders, respHeaders, requestInfo)
+	if atomic.LoadUint32(&l.unreachable) == 0 {
+		select {
+		case l.queue <- entry:
+			return
+		default:
+		}
+	}
+	l.writeFallback(entry, true)
+}
+
+// Close flushes the queued entries and stops streaming
+func (l *accessLog) Close() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+	<-l.done
+}
+
+func (l *accessLog) newEntry(reqHeaders types.HeaderMap, respHeaders types.HeaderMap, info types.RequestInfo) *HTTPAccessLogEntry {
+	entry := &HTTPAccessLogEntry{
+		StartTime:                  info.StartTime(),
+		TimeToLastRxByte:           info.RequestReceivedDuration(),
+		TimeToFirstUpstreamRxByte:  info.ResponseReceivedDuration(),
+		TimeToLastDownstreamTxByte: info.RequestFinishedDuration(),
+		Protocol:                   string(info.Protocol()),
+		RequestBodyBytes:           info.BytesReceived(),
+		ResponseCode:               uint32(info.ResponseCode()),
+		ResponseBodyBytes:          info.BytesSent(),
+	}
+	if addr := info.DownstreamRemoteAddress(); addr != nil {
+		entry.DownstreamRemoteAddress = addr.String()
+	}
+	if addr := info.DownstreamLocalAddress(); addr != nil {
+		entry.DownstreamLocalAddress = addr.String()
+	}
+	if addr := info.UpstreamLocalAddress(); addr != nil {
+		entry.UpstreamLocalAddress = addr.String()
+	}
+	if host := info.UpstreamHost(); host != nil {
+		entry.UpstreamRemoteAddress = host.AddressString()
+		if host.ClusterInfo() != nil {
+			entry.UpstreamCluster = host.ClusterInfo().Name()
+		}
+	}
+	if route := info.RouteEntry(); route != nil {
+		entry.RouteName = route.RouteName()
+	}
+	for _, rf := range responseFlags {
+		if info.GetResponseFlag(rf.flag) {
+			entry.ResponseFlags |= rf.flag
+		}
+	}
+
+	if reqHeaders != nil {
+		entry.RequestMethod, _ = reqHeaders.Get(protocol.MosnHeaderMethod)
+		entry.Authority, _ = reqHeaders.Get(protocol.MosnHeaderHostKey)
+		entry.Path, _ = reqHeaders.Get(protocol.MosnHeaderPathKey)
+		if query, ok := reqHeaders.Get(protocol.MosnHeaderQueryStringKey); ok && query != "" {
+			entry.Path += "?" + query
+		}
+		entry.UserAgent, _ = reqHeaders.Get("user-agent")
+		entry.Referer, _ = reqHeaders.Get("referer")
+		entry.ForwardedFor, _ = reqHeaders.Get("x-forwarded-for")
+		entry.RequestId, _ = reqHeaders.Get("x-request-id")
+		entry.RequestHeadersBytes = reqHeaders.ByteSize()
+		entry.RequestHeaders = headersToLog(reqHeaders, l.requestHeadersToLog)
+	}
+	if respHeaders != nil {
+		entry.ResponseHeadersBytes = respHeaders.ByteSize()
+		entry.ResponseHeaders = headersToLog(respHeaders, l.responseHeadersToLog)
+	}
+	return entry
+}
+
+func headersToLog(headers types.HeaderMap, keys []string) map[string]string {
+	var m map[string]string
+	for _, key := range keys {
+		if v, ok := headers.Get(key); ok {
+			if m == nil {
+				m = make(map[string]string, len(keys))
+			}
+			m[key] = v
+		}
+	}
+	return m
+}
+
+// writeFallback writes the entry to the local file in json
+func (l *accessLog) writeFallback(entry *HTTPAccessLogEntry, discard bool) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.DefaultLogger.Errorf("[als] marshal access log entry failed: %v", err)
+		return
+	}
+	buf := buffer.GetIoBuffer(len(data) + 1)
+	buf.Write(data)
+	buf.WriteString("\n")
+	l.fallback.Print(buf, discard)
+}
+
+func (l *accessLog) run() {
+	defer func() {
+		// the entries logged after closing are written to the local file
+		atomic.StoreUint32(&l.unreachable, 1)
+		l.drainFallback()
+		close(l.done)
+	}()
+
+	backOff := l.reconnectBaseInterval
+	for {
+		conn, stream, cancel, err := l.connect()
+		if err != nil {
+			atomic.StoreUint32(&l.unreachable, 1)
+			log.DefaultLogger.Warnf("[als] connect to collector %s failed, retry in %s: %v", l.address, backOff, err)
+			if !l.waitBackOff(backOff) {
+				return
+			}
+			backOff *= 2
+			if backOff > l.reconnectMaxInterval {
+				backOff = l.reconnectMaxInterval
+			}
+			continue
+		}
+		backOff = l.reconnectBaseInterval
+		atomic.StoreUint32(&l.unreachable, 0)
+		log.DefaultLogger.Infof("[als] stream access logs to collector %s", l.address)
+
+		err = l.send(stream, cancel)
+		cancel()
+		conn.Close()
+		if err == nil {
+			// stopped
+			return
+		}
+		atomic.StoreUint32(&l.unreachable, 1)
+		log.DefaultLogger.Warnf("[als] stream access logs to collector %s broken: %v", l.address, err)
+	}
+}
+
+func (l *accessLog) connect() (*grpc.ClientConn, grpc.ClientStream, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.connectTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, l.address, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	streamCtx, streamCancel := context.WithCancel(context.Background())
+	stream, err := conn.NewStream(streamCtx, streamAccessLogsDesc, streamAccessLogsMethod)
+	if err != nil {
+		streamCancel()
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	return conn, stream, streamCancel, nil
+}
+
+// waitBackOff waits before reconnecting, the entries queued are written to the local file.
+// it returns false if the access log is closed.
+func (l *accessLog) waitBackOff(backOff time.Duration) bool {
+	timer := time.NewTimer(backOff)
+	defer timer.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return false
+		case <-timer.C:
+			return true
+		case entry := <-l.queue:
+			l.writeFallback(entry, false)
+		}
+	}
+}
+
+func (l *accessLog) drainFallback() {
+	for {
+		select {
+		case entry := <-l.queue:
+			l.writeFallback(entry, false)
+		default:
+			return
+		}
+	}
+}
+
+// send sends the queued entries in batches until the stream is broken or the access log is closed,
+// the batch failed to send is written to the local file.
+func (l *accessLog) send(stream grpc.ClientStream, cancel context.CancelFunc) error {
+	ticker := time.NewTicker(l.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*HTTPAccessLogEntry, 0, l.batchSize)
+	identifier := l.identifier
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := stream.SendMsg(&streamAccessLogsMessage{
+			identifier: identifier,
+			entries:    batch,
+		})
+		if err != nil {
+			for _, entry := range batch {
+				l.writeFallback(entry, false)
+			}
+		} else {
+			// the identifier is only sent in the first message
+			identifier = nil
+		}
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		select {
+		case <-l.stop:
+			// flush the queued entries before closing
+			for len(l.queue) > 0 {
+				batch = append(batch, <-l.queue)
+				if len(batch) >= l.batchSize {
+					if err := flush(); err != nil {
+						return nil
+					}
+				}
+			}
+			if err := flush(); err != nil {
+				return nil
+			}
+			// wait the collector to receive the entries
+			stream.CloseSend()
+			timer := time.AfterFunc(l.connectTimeout, cancel)
+			stream.RecvMsg(&streamAccessLogsResponse{})
+			timer.Stop()
+			return nil
+
+		case entry := <-l.queue:
+			batch = append(batch, entry)
+			if len(batch) >= l.batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
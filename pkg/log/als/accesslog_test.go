@@ -0,0 +1,348 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package als
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"google.golang.org/grpc"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// rawMessage keeps the encoded message, the collector decodes it in the test
+type rawMessage []byte
+
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *(v.(*rawMessage)), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*(v.(*rawMessage)) = append(rawMessage(nil), data...)
+	return nil
+}
+
+func (rawCodec) String() string {
+	return "raw"
+}
+
+// collector is an in-process access log service
+type collector struct {
+	server   *grpc.Server
+	address  string
+	mutex    sync.Mutex
+	messages []rawMessage
+}
+
+func startCollector(t *testing.T, address string) *collector {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &collector{
+		server:  grpc.NewServer(grpc.CustomCodec(rawCodec{})),
+		address: ln.Addr().String(),
+	}
+	c.server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "envoy.service.accesslog.v2.AccessLogService",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "StreamAccessLogs",
+			Handler:       c.streamAccessLogs,
+			ClientStreams: true,
+		}},
+	}, c)
+	go c.server.Serve(ln)
+	return c
+}
+
+func (c *collector) streamAccessLogs(srv interface{}, stream grpc.ServerStream) error {
+	for {
+		var m rawMessage
+		if err := stream.RecvMsg(&m); err != nil {
+			if err == io.EOF {
+				return stream.SendMsg(&rawMessage{})
+			}
+			return err
+		}
+		c.mutex.Lock()
+		c.messages = append(c.messages, m)
+		c.mutex.Unlock()
+	}
+}
+
+// entries returns the decoded messages' identifiers and entries
+func (c *collector) entries(t *testing.T) (identifiers []fields, entries []fields) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, m := range c.messages {
+		message := decode(t, m)
+		if id := message.message(t, 1); id != nil {
+			identifiers = append(identifiers, id)
+		}
+		for _, entry := range message.message(t, 2)[1] {
+			entries = append(entries, decode(t, entry.([]byte)))
+		}
+	}
+	return
+}
+
+// fields is a decoded protobuf message, the value is uint64 for varint, and []byte for bytes
+type fields map[uint64][]interface{}
+
+func decode(t *testing.T, data []byte) fields {
+	f := fields{}
+	for i := 0; i < len(data); {
+		key, n := proto.DecodeVarint(data[i:])
+		if n == 0 {
+			t.Fatal("invalid field key")
+		}
+		i += n
+		switch key & 7 {
+		case proto.WireVarint:
+			v, n := proto.DecodeVarint(data[i:])
+			if n == 0 {
+				t.Fatal("invalid varint")
+			}
+			i += n
+			f[key>>3] = append(f[key>>3], v)
+		case proto.WireBytes:
+			l, n := proto.DecodeVarint(data[i:])
+			if n == 0 || i+n+int(l) > len(data) {
+				t.Fatal("invalid bytes")
+			}
+			i += n
+			f[key>>3] = append(f[key>>3], data[i:i+int(l)])
+			i += int(l)
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return f
+}
+
+func (f fields) message(t *testing.T, field uint64) fields {
+	if len(f[field]) == 0 {
+		return nil
+	}
+	return decode(t, f[field][0].([]byte))
+}
+
+func (f fields) string(field uint64) string {
+	if len(f[field]) == 0 {
+		return ""
+	}
+	return string(f[field][0].([]byte))
+}
+
+func (f fields) varint(field uint64) uint64 {
+	if len(f[field]) == 0 {
+		return 0
+	}
+	return f[field][0].(uint64)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for i := 0; i < 500; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("wait for condition timeout")
+}
+
+func testConfig(address string) *v2.GRPCAccessLog {
+	return &v2.GRPCAccessLog{
+		LogName:                       "test_log",
+		Address:                       address,
+		AdditionalRequestHeadersToLog: []string{"x-foo"},
+		BatchSize:                     2,
+		FlushInterval:                 v2.DurationConfig{Duration: 10 * time.Millisecond},
+		ConnectTimeout:                v2.DurationConfig{Duration: 200 * time.Millisecond},
+		ReconnectBaseInterval:         v2.DurationConfig{Duration: 10 * time.Millisecond},
+		ReconnectMaxInterval:          v2.DurationConfig{Duration: 50 * time.Millisecond},
+	}
+}
+
+func logRequest(l types.AccessLog, path string) {
+	reqHeaders := protocol.CommonHeader{
+		protocol.MosnHeaderMethod:         "POST",
+		protocol.MosnHeaderPathKey:        path,
+		protocol.MosnHeaderQueryStringKey: "a=1",
+		"x-foo":                           "foo",
+	}
+	respHeaders := protocol.CommonHeader{
+		"content-type": "text/plain",
+	}
+	requestInfo := network.NewRequestInfo()
+	requestInfo.SetResponseCode(503)
+	requestInfo.SetResponseFlag(types.UpstreamOverflow)
+	requestInfo.SetDownstreamRemoteAddress(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 12345})
+	l.Log(reqHeaders, respHeaders, requestInfo)
+}
+
+func TestStreamAccessLogs(t *testing.T) {
+	c := startCollector(t, "127.0.0.1:0")
+	defer c.server.Stop()
+
+	l, err := newAccessLog(testConfig(c.address), "/tmp/mosn_als/stream_access.log", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go l.run()
+	for _, path := range []string{"/a", "/b", "/c"} {
+		logRequest(l, path)
+	}
+	l.Close()
+
+	identifiers, entries := c.entries(t)
+	if len(identifiers) != 1 || identifiers[0].string(2) != "test_log" {
+		t.Fatalf("the identifier should be sent once in the stream, got: %v", identifiers)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	entry := entries[0]
+	common := entry.message(t, 1)
+	socket := common.message(t, 2).message(t, 1)
+	if socket.string(2) != "10.0.0.1" || socket.varint(3) != 12345 {
+		t.Errorf("unexpected downstream remote address: %v", socket)
+	}
+	if common.message(t, 16).varint(8) != 1 {
+		t.Error("the upstream overflow flag should be set")
+	}
+	request := entry.message(t, 3)
+	if request.varint(1) != 3 || request.string(5) != "/a?a=1" {
+		t.Errorf("unexpected request method %d or path %s", request.varint(1), request.string(5))
+	}
+	header := decode(t, request[13][0].([]byte))
+	if header.string(1) != "x-foo" || header.string(2) != "foo" {
+		t.Errorf("unexpected request headers: %v", header)
+	}
+	if code := entry.message(t, 4).message(t, 1).varint(1); code != 503 {
+		t.Errorf("unexpected response code: %d", code)
+	}
+}
+
+func TestFallbackWhenUnreachable(t *testing.T) {
+	// an address without a collector
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	// the logger is cached by the path, so each test uses a new file
+	fallback := fmt.Sprintf("/tmp/mosn_als/fallback_access.%d.log", time.Now().UnixNano())
+	defer os.Remove(fallback)
+	l, err := newAccessLog(testConfig(address), fallback, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go l.run()
+	logRequest(l, "/queued")
+	waitFor(t, func() bool {
+		return atomic.LoadUint32(&l.unreachable) == 1
+	})
+	logRequest(l, "/unreachable")
+	l.Close()
+
+	var paths []string
+	waitFor(t, func() bool {
+		f, err := os.Open(fallback)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		paths = paths[:0]
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			entry := &HTTPAccessLogEntry{}
+			if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+				t.Fatalf("invalid fallback entry %s: %v", scanner.Text(), err)
+			}
+			paths = append(paths, entry.Path)
+		}
+		return len(paths) == 2
+	})
+	if paths[0] != "/queued?a=1" || paths[1] != "/unreachable?a=1" {
+		t.Errorf("unexpected fallback entries: %v", paths)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	c := startCollector(t, "127.0.0.1:0")
+	l, err := newAccessLog(testConfig(c.address), "/tmp/mosn_als/reconnect_access.log", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go l.run()
+	defer l.Close()
+	logRequest(l, "/before")
+	waitFor(t, func() bool {
+		_, entries := c.entries(t)
+		return len(entries) == 1
+	})
+
+	// the collector is down
+	c.server.Stop()
+	waitFor(t, func() bool {
+		logRequest(l, "/down")
+		return atomic.LoadUint32(&l.unreachable) == 1
+	})
+
+	// the collector is up again, a new stream is created
+	restarted := startCollector(t, c.address)
+	defer restarted.server.Stop()
+	waitFor(t, func() bool {
+		return atomic.LoadUint32(&l.unreachable) == 0
+	})
+	logRequest(l, "/after")
+	waitFor(t, func() bool {
+		_, entries := restarted.entries(t)
+		return len(entries) == 1
+	})
+	identifiers, entries := restarted.entries(t)
+	if len(identifiers) != 1 {
+		t.Errorf("the identifier should be sent in the new stream")
+	}
+	if path := entries[0].message(t, 3).string(5); path != "/after?a=1" {
+		t.Errorf("unexpected path: %s", path)
+	}
+}
+
+func TestNewAccessLogWithoutCollector(t *testing.T) {
+	if _, err := NewAccessLog(&v2.GRPCAccessLog{}, "/tmp/mosn_als/access.log", nil); err != ErrNoCollector {
+		t.Errorf("expected ErrNoCollector, but got: %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package als
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/gogo/protobuf/proto"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// The protocol version of envoy.data.accesslog.v2.HTTPAccessLogEntry
+const (
+	protocolUnspecified = 0
+	protocolHTTP11      = 2
+	protocolHTTP2       = 3
+)
+
+// requestMethods maps the http method to envoy.api.v2.core.RequestMethod
+var requestMethods = map[string]uint64{
+	"GET":     1,
+	"HEAD":    2,
+	"POST":    3,
+	"PUT":     4,
+	"DELETE":  5,
+	"CONNECT": 6,
+	"OPTIONS": 7,
+	"TRACE":   8,
+	"PATCH":   9,
+}
+
+// responseFlags maps the response flag to the field of envoy.data.accesslog.v2.ResponseFlags
+var responseFlags = []struct {
+	flag  types.ResponseFlag
+	field uint64
+}{
+	{types.NoHealthyUpstream, 2},
+	{types.UpstreamRequestTimeout, 3},
+	{types.UpstreamLocalReset, 4},
+	{types.UpstreamRemoteReset, 5},
+	{types.UpstreamConnectionFailure, 6},
+	{types.UpstreamConnectionTermination, 7},
+	{types.UpstreamOverflow, 8},
+	{types.NoRouteFound, 9},
+	{types.DelayInjected, 10},
+	{types.FaultInjected, 11},
+	{types.RateLimited, 12},
+}
+
+// HTTPAccessLogEntry is an access log entry of a http request, it is encoded as
+// envoy.data.accesslog.v2.HTTPAccessLogEntry, and encoded as json in the local file fallback.
+type HTTPAccessLogEntry struct {
+	StartTime                  time.Time          `json:"start_time"`
+	DownstreamRemoteAddress    string             `json:"downstream_remote_address,omitempty"`
+	DownstreamLocalAddress     string             `json:"downstream_local_address,omitempty"`
+	UpstreamRemoteAddress      string             `json:"upstream_remote_address,omitempty"`
+	UpstreamLocalAddress       string             `json:"upstream_local_address,omitempty"`
+	UpstreamCluster            string             `json:"upstream_cluster,omitempty"`
+	RouteName                  string             `json:"route_name,omitempty"`
+	ResponseFlags              types.ResponseFlag `json:"response_flags,omitempty"`
+	TimeToLastRxByte           time.Duration      `json:"time_to_last_rx_byte,omitempty"`
+	TimeToFirstUpstreamRxByte  time.Duration      `json:"time_to_first_upstream_rx_byte,omitempty"`
+	TimeToLastDownstreamTxByte time.Duration      `json:"time_to_last_downstream_tx_byte,omitempty"`
+	Protocol                   string             `json:"protocol,omitempty"`
+	RequestMethod              string             `json:"request_method,omitempty"`
+	Authority                  string             `json:"authority,omitempty"`
+	Path                       string             `json:"path,omitempty"`
+	UserAgent                  string             `json:"user_agent,omitempty"`
+	Referer                    string             `json:"referer,omitempty"`
+	ForwardedFor               string             `json:"forwarded_for,omitempty"`
+	RequestId                  string             `json:"request_id,omitempty"`
+	RequestHeadersBytes        uint64             `json:"request_headers_bytes,omitempty"`
+	RequestBodyBytes           uint64             `json:"request_body_bytes,omitempty"`
+	RequestHeaders             map[string]string  `json:"request_headers,omitempty"`
+	ResponseCode               uint32             `json:"response_code,omitempty"`
+	ResponseHeadersBytes       uint64             `json:"response_headers_bytes,omitempty"`
+	ResponseBodyBytes          uint64             `json:"response_body_bytes,omitempty"`
+	ResponseHeaders            map[string]string  `json:"response_headers,omitempty"`
+}
+
+// encode encodes the entry as envoy.data.accesslog.v2.HTTPAccessLogEntry
+func (e *HTTPAccessLogEntry) encode(b *proto.Buffer) {
+	common := proto.NewBuffer(nil)
+	encodeTime(common, 5, e.StartTime)
+	encodeAddress(common, 2, e.DownstreamRemoteAddress)
+	encodeAddress(common, 3, e.DownstreamLocalAddress)
+	encodeDuration(common, 6, e.TimeToLastRxByte)
+	encodeDuration(common, 9, e.TimeToFirstUpstreamRxByte)
+	encodeDuration(common, 12, e.TimeToLastDownstreamTxByte)
+	encodeAddress(common, 13, e.UpstreamRemoteAddress)
+	encodeAddress(common, 14, e.UpstreamLocalAddress)
+	encodeString(common, 15, e.UpstreamCluster)
+	if e.ResponseFlags != 0 {
+		flags := proto.NewBuffer(nil)
+		for _, rf := range responseFlags {
+			if e.ResponseFlags&rf.flag != 0 {
+				encodeVarint(flags, rf.field, 1)
+			}
+		}
+		encodeMessage(common, 16, flags)
+	}
+	encodeString(common, 19, e.RouteName)
+	encodeMessage(b, 1, common)
+
+	switch types.Protocol(e.Protocol) {
+	case protocol.HTTP1:
+		encodeVarint(b, 2, protocolHTTP11)
+	case protocol.HTTP2:
+		encodeVarint(b, 2, protocolHTTP2)
+	default:
+		encodeVarint(b, 2, protocolUnspecified)
+	}
+
+	request := proto.NewBuffer(nil)
+	encodeVarint(request, 1, requestMethods[e.RequestMethod])
+	encodeString(request, 3, e.Authority)
+	encodeString(request, 5, e.Path)
+	encodeString(request, 6, e.UserAgent)
+	encodeString(request, 7, e.Referer)
+	encodeString(request, 8, e.ForwardedFor)
+	encodeString(request, 9, e.RequestId)
+	encodeVarint(request, 11, e.RequestHeadersBytes)
+	encodeVarint(request, 12, e.RequestBodyBytes)
+	encodeMap(request, 13, e.RequestHeaders)
+	encodeMessage(b, 3, request)
+
+	response := proto.NewBuffer(nil)
+	if e.ResponseCode != 0 {
+		// google.protobuf.UInt32Value
+		code := proto.NewBuffer(nil)
+		encodeVarint(code, 1, uint64(e.ResponseCode))
+		encodeMessage(response, 1, code)
+	}
+	encodeVarint(response, 2, e.ResponseHeadersBytes)
+	encodeVarint(response, 3, e.ResponseBodyBytes)
+	encodeMap(response, 4, e.ResponseHeaders)
+	encodeMessage(b, 4, response)
+}
+
+// streamAccessLogsMessage is envoy.service.accesslog.v2.StreamAccessLogsMessage,
+// the identifier is only sent in the first message of a stream.
+type streamAccessLogsMessage struct {
+	identifier []byte
+	entries    []*HTTPAccessLogEntry
+}
+
+func (m *streamAccessLogsMessage) Reset() {
+	*m = streamAccessLogsMessage{}
+}
+
+func (m *streamAccessLogsMessage) String() string {
+	return fmt.Sprintf("StreamAccessLogsMessage{entries: %d}", len(m.entries))
+}
+
+func (m *streamAccessLogsMessage) ProtoMessage() {}
+
+func (m *streamAccessLogsMessage) Marshal() ([]byte, error) {
+	b := proto.NewBuffer(nil)
+	if len(m.identifier) > 0 {
+		b.EncodeVarint(1<<3 | proto.WireBytes)
+		b.EncodeRawBytes(m.identifier)
+	}
+	logs := proto.NewBuffer(nil)
+	entry := proto.NewBuffer(nil)
+	for _, e := range m.entries {
+		entry.Reset()
+		e.encode(entry)
+		logs.EncodeVarint(1<<3 | proto.WireBytes)
+		logs.EncodeRawBytes(entry.Bytes())
+	}
+	// http_logs
+	b.EncodeVarint(2<<3 | proto.WireBytes)
+	b.EncodeRawBytes(logs.Bytes())
+	return b.Bytes(), nil
+}
+
+// streamAccessLogsResponse is envoy.service.accesslog.v2.StreamAccessLogsResponse, which is empty
+type streamAccessLogsResponse struct{}
+
+func (m *streamAccessLogsResponse) Reset() {}
+
+func (m *streamAccessLogsResponse) String() string {
+	return "StreamAccessLogsResponse{}"
+}
+
+func (m *streamAccessLogsResponse) ProtoMessage() {}
+
+func (m *streamAccessLogsResponse) Unmarshal(data []byte) error {
+	return nil
+}
+
+// encodeIdentifier encodes envoy.service.accesslog.v2.StreamAccessLogsMessage.Identifier
+func encodeIdentifier(node *core.Node, logName string) ([]byte, error) {
+	b := proto.NewBuffer(nil)
+	data, err := node.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	b.EncodeVarint(1<<3 | proto.WireBytes)
+	b.EncodeRawBytes(data)
+	encodeString(b, 2, logName)
+	return b.Bytes(), nil
+}
+
+func encodeVarint(b *proto.Buffer, field uint64, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.EncodeVarint(field<<3 | proto.WireVarint)
+	b.EncodeVarint(v)
+}
+
+func encodeString(b *proto.Buffer, field uint64, s string) {
+	if s == "" {
+		return
+	}
+	b.EncodeVarint(field<<3 | proto.WireBytes)
+	b.EncodeStringBytes(s)
+}
+
+func encodeMessage(b *proto.Buffer, field uint64, message *proto.Buffer) {
+	if len(message.Bytes()) == 0 {
+		return
+	}
+	b.EncodeVarint(field<<3 | proto.WireBytes)
+	b.EncodeRawBytes(message.Bytes())
+}
+
+func encodeMap(b *proto.Buffer, field uint64, m map[string]string) {
+	for k, v := range m {
+		pair := proto.NewBuffer(nil)
+		pair.EncodeVarint(1<<3 | proto.WireBytes)
+		pair.EncodeStringBytes(k)
+		pair.EncodeVarint(2<<3 | proto.WireBytes)
+		pair.EncodeStringBytes(v)
+		b.EncodeVarint(field<<3 | proto.WireBytes)
+		b.EncodeRawBytes(pair.Bytes())
+	}
+}
+
+// encodeTime encodes google.protobuf.Timestamp
+func encodeTime(b *proto.Buffer, field uint64, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	ts := proto.NewBuffer(nil)
+	encodeVarint(ts, 1, uint64(t.Unix()))
+	encodeVarint(ts, 2, uint64(t.Nanosecond()))
+	encodeMessage(b, field, ts)
+}
+
+// encodeDuration encodes google.protobuf.Duration
+func encodeDuration(b *proto.Buffer, field uint64, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	duration := proto.NewBuffer(nil)
+	encodeVarint(duration, 1, uint64(d/time.Second))
+	encodeVarint(duration, 2, uint64(d%time.Second))
+	encodeMessage(b, field, duration)
+}
+
+// encodeAddress encodes envoy.api.v2.core.Address with a tcp socket address
+func encodeAddress(b *proto.Buffer, field uint64, address string) {
+	if address == "" {
+		return
+	}
+	socket := proto.NewBuffer(nil)
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		encodeString(socket, 2, host)
+		if p, err := strconv.ParseUint(port, 10, 32); err == nil {
+			encodeVarint(socket, 3, p)
+		}
+	} else {
+		encodeString(socket, 2, address)
+	}
+	addr := proto.NewBuffer(nil)
+	encodeMessage(addr, 1, socket)
+	encodeMessage(b, field, addr)
+}
@@ -41,3 +41,14 @@ func NewHealthStats(serviceName string) types.Metrics {
 	metrics, _ := NewMetrics(HealthCheckType, map[string]string{"service": serviceName})
 	return metrics
 }
+
+// NewHostHealthStats returns a stats with namespace prefix service and host
+func NewHostHealthStats(serviceName string, host string) types.Metrics {
+	metrics, _ := NewMetrics(HealthCheckType, map[string]string{"service": serviceName, "host": host})
+	return metrics
+}
+
+// DeleteHostHealthStats deletes the stats of the host, it is called when the host is removed
+func DeleteHostHealthStats(serviceName string, host string) {
+	DeleteMetrics(HealthCheckType, map[string]string{"service": serviceName, "host": host})
+}
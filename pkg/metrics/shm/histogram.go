@@ -0,0 +1,384 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package shm
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+var ErrBucketsMismatch = errors.New("histogram buckets mismatch")
+
+// ShmHistogram is a histogram with fixed bucket boundaries.
+// Each bucket, the count, sum, min and max are int64 cells updated with atomic
+// operations, so Update is lock free. The cells are allocated in the metrics
+// shm zone if it is initialized, so the values survive a hot upgrade like ShmCounter.
+type ShmHistogram struct {
+	// bounds are the inclusive upper bounds of the buckets, sorted ascending,
+	// the last bucket (+Inf) is implicit
+	bounds  []int64
+	buckets []*int64
+	sum     *int64
+	min     *int64
+	max     *int64
+
+	entries []*hashEntry
+}
+
+// NewShmHistogramFunc returns a histogram constructor with the given bucket bounds
+func NewShmHistogramFunc(name string, bounds []int64) func() gometrics.Histogram {
+	return func() gometrics.Histogram {
+		if defaultZone != nil {
+			if h, err := newZoneHistogram(defaultZone, name, bounds); err == nil {
+				return h
+			}
+		} else if fallback {
+			return NewHistogram(bounds)
+		}
+		return gometrics.NilHistogram{}
+	}
+}
+
+// NewHistogram returns a histogram in process memory
+func NewHistogram(bounds []int64) *ShmHistogram {
+	h := &ShmHistogram{
+		bounds: sortedBounds(bounds),
+		sum:    new(int64),
+		min:    new(int64),
+		max:    new(int64),
+	}
+	*h.min = math.MaxInt64
+	*h.max = math.MinInt64
+	h.buckets = make([]*int64, len(h.bounds)+1)
+	for i := range h.buckets {
+		h.buckets[i] = new(int64)
+	}
+	return h
+}
+
+func newZoneHistogram(z *zone, name string, bounds []int64) (*ShmHistogram, error) {
+	h := &ShmHistogram{
+		bounds: sortedBounds(bounds),
+	}
+	alloc := func(suffix string, init int64) (*int64, error) {
+		entry, err := z.allocInit(name+suffix, init)
+		if err != nil {
+			return nil, err
+		}
+		h.entries = append(h.entries, entry)
+		return &entry.value, nil
+	}
+
+	var err error
+	h.buckets = make([]*int64, len(h.bounds)+1)
+	for i := range h.buckets {
+		if h.buckets[i], err = alloc(".bucket_"+strconv.Itoa(i), 0); err != nil {
+			h.Stop()
+			return nil, err
+		}
+	}
+	if h.sum, err = alloc(".sum", 0); err != nil {
+		h.Stop()
+		return nil, err
+	}
+	if h.min, err = alloc(".min", math.MaxInt64); err != nil {
+		h.Stop()
+		return nil, err
+	}
+	if h.max, err = alloc(".max", math.MinInt64); err != nil {
+		h.Stop()
+		return nil, err
+	}
+	return h, nil
+}
+
+func sortedBounds(bounds []int64) []int64 {
+	sorted := make([]int64, 0, len(bounds))
+	for _, b := range bounds {
+		if i := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= b }); i == len(sorted) || sorted[i] != b {
+			sorted = append(sorted, 0)
+			copy(sorted[i+1:], sorted[i:])
+			sorted[i] = b
+		}
+	}
+	return sorted
+}
+
+// Update records a value
+func (h *ShmHistogram) Update(v int64) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return h.bounds[i] >= v })
+	atomic.AddInt64(h.buckets[i], 1)
+	atomic.AddInt64(h.sum, v)
+	for {
+		old := atomic.LoadInt64(h.min)
+		if v >= old || atomic.CompareAndSwapInt64(h.min, old, v) {
+			break
+		}
+	}
+	for {
+		old := atomic.LoadInt64(h.max)
+		if v <= old || atomic.CompareAndSwapInt64(h.max, old, v) {
+			break
+		}
+	}
+}
+
+// Clear resets the histogram
+func (h *ShmHistogram) Clear() {
+	for _, b := range h.buckets {
+		atomic.StoreInt64(b, 0)
+	}
+	atomic.StoreInt64(h.sum, 0)
+	atomic.StoreInt64(h.min, math.MaxInt64)
+	atomic.StoreInt64(h.max, math.MinInt64)
+}
+
+// Snapshot returns a read-only copy of the histogram
+func (h *ShmHistogram) Snapshot() gometrics.Histogram {
+	s := &HistogramSnapshot{
+		bounds:  h.bounds,
+		buckets: make([]int64, len(h.buckets)),
+		sum:     atomic.LoadInt64(h.sum),
+		min:     atomic.LoadInt64(h.min),
+		max:     atomic.LoadInt64(h.max),
+	}
+	for i, b := range h.buckets {
+		s.buckets[i] = atomic.LoadInt64(b)
+		s.count += s.buckets[i]
+	}
+	return s
+}
+
+func (h *ShmHistogram) Count() int64                       { return h.Snapshot().Count() }
+func (h *ShmHistogram) Max() int64                         { return h.Snapshot().Max() }
+func (h *ShmHistogram) Mean() float64                      { return h.Snapshot().Mean() }
+func (h *ShmHistogram) Min() int64                         { return h.Snapshot().Min() }
+func (h *ShmHistogram) Percentile(p float64) float64       { return h.Snapshot().Percentile(p) }
+func (h *ShmHistogram) Percentiles(ps []float64) []float64 { return h.Snapshot().Percentiles(ps) }
+func (h *ShmHistogram) Sample() gometrics.Sample           { return gometrics.NilSample{} }
+func (h *ShmHistogram) StdDev() float64                    { return h.Snapshot().StdDev() }
+func (h *ShmHistogram) Sum() int64                         { return h.Snapshot().Sum() }
+func (h *ShmHistogram) Variance() float64                  { return h.Snapshot().Variance() }
+
+// stoppable
+func (h *ShmHistogram) Stop() {
+	if defaultZone != nil {
+		for _, entry := range h.entries {
+			defaultZone.free(entry)
+		}
+	}
+	h.entries = nil
+}
+
+// HistogramSnapshot is a read-only copy of a ShmHistogram.
+// The counts are cumulative since the histogram is created, snapshots of the
+// same bounds can be merged, and a later snapshot minus an earlier one is the
+// distribution of the reporting interval between them.
+type HistogramSnapshot struct {
+	bounds  []int64
+	buckets []int64
+	count   int64
+	sum     int64
+	min     int64
+	max     int64
+}
+
+// Bounds returns the inclusive upper bounds of the buckets, the +Inf bucket is not included
+func (s *HistogramSnapshot) Bounds() []int64 {
+	return s.bounds
+}
+
+// Buckets returns the count of each bucket, not cumulative, the last one is the +Inf bucket
+func (s *HistogramSnapshot) Buckets() []int64 {
+	return s.buckets
+}
+
+// Merge adds the counts of other to s
+func (s *HistogramSnapshot) Merge(other *HistogramSnapshot) error {
+	if !equalBounds(s.bounds, other.bounds) {
+		return ErrBucketsMismatch
+	}
+	for i := range s.buckets {
+		s.buckets[i] += other.buckets[i]
+	}
+	s.count += other.count
+	s.sum += other.sum
+	if other.min < s.min {
+		s.min = other.min
+	}
+	if other.max > s.max {
+		s.max = other.max
+	}
+	return nil
+}
+
+// Sub returns the distribution recorded since the earlier snapshot prev.
+// The min and max are the ones of s as they can not be subtracted.
+func (s *HistogramSnapshot) Sub(prev *HistogramSnapshot) (*HistogramSnapshot, error) {
+	if !equalBounds(s.bounds, prev.bounds) {
+		return nil, ErrBucketsMismatch
+	}
+	delta := &HistogramSnapshot{
+		bounds:  s.bounds,
+		buckets: make([]int64, len(s.buckets)),
+		count:   s.count - prev.count,
+		sum:     s.sum - prev.sum,
+		min:     s.min,
+		max:     s.max,
+	}
+	for i := range s.buckets {
+		delta.buckets[i] = s.buckets[i] - prev.buckets[i]
+	}
+	return delta, nil
+}
+
+func equalBounds(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Clear panics.
+func (*HistogramSnapshot) Clear() {
+	panic("Clear called on a HistogramSnapshot")
+}
+
+// Update panics.
+func (*HistogramSnapshot) Update(int64) {
+	panic("Update called on a HistogramSnapshot")
+}
+
+func (s *HistogramSnapshot) Count() int64 {
+	return s.count
+}
+
+func (s *HistogramSnapshot) Sum() int64 {
+	return s.sum
+}
+
+func (s *HistogramSnapshot) Min() int64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.min
+}
+
+func (s *HistogramSnapshot) Max() int64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.max
+}
+
+func (s *HistogramSnapshot) Mean() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return float64(s.sum) / float64(s.count)
+}
+
+// Percentile estimates the p-quantile (0 <= p <= 1) by linear interpolation inside
+// the bucket it falls in, the buckets are narrowed to [min, max]
+func (s *HistogramSnapshot) Percentile(p float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	if p <= 0 {
+		return float64(s.Min())
+	}
+	if p >= 1 {
+		return float64(s.Max())
+	}
+	rank := p * float64(s.count)
+	var cumulative int64
+	for i, n := range s.buckets {
+		if n == 0 || float64(cumulative+n) < rank {
+			cumulative += n
+			continue
+		}
+		lower, upper := s.bucketRange(i)
+		return lower + (upper-lower)*(rank-float64(cumulative))/float64(n)
+	}
+	return float64(s.Max())
+}
+
+func (s *HistogramSnapshot) Percentiles(ps []float64) []float64 {
+	values := make([]float64, len(ps))
+	for i, p := range ps {
+		values[i] = s.Percentile(p)
+	}
+	return values
+}
+
+// bucketRange returns the range of the bucket i narrowed to [min, max]
+func (s *HistogramSnapshot) bucketRange(i int) (lower, upper float64) {
+	min, max := float64(s.Min()), float64(s.Max())
+	lower, upper = min, max
+	if i > 0 && float64(s.bounds[i-1]) > lower {
+		lower = float64(s.bounds[i-1])
+	}
+	if i < len(s.bounds) && float64(s.bounds[i]) < upper {
+		upper = float64(s.bounds[i])
+	}
+	if lower > upper {
+		lower = upper
+	}
+	return
+}
+
+func (s *HistogramSnapshot) Sample() gometrics.Sample {
+	return gometrics.NilSample{}
+}
+
+// Variance estimates the variance with the middle value of each bucket
+func (s *HistogramSnapshot) Variance() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	mean := s.Mean()
+	var sum float64
+	for i, n := range s.buckets {
+		if n == 0 {
+			continue
+		}
+		lower, upper := s.bucketRange(i)
+		d := (lower+upper)/2 - mean
+		sum += d * d * float64(n)
+	}
+	return sum / float64(s.count)
+}
+
+func (s *HistogramSnapshot) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Snapshot returns the snapshot itself.
+func (s *HistogramSnapshot) Snapshot() gometrics.Histogram {
+	return s
+}
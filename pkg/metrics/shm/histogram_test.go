@@ -0,0 +1,196 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package shm
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+func linearBounds(start, width int64, n int) []int64 {
+	bounds := make([]int64, n)
+	for i := range bounds {
+		bounds[i] = start + width*int64(i)
+	}
+	return bounds
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram(linearBounds(100, 100, 10))
+	for i := int64(1); i <= 1000; i++ {
+		h.Update(i)
+	}
+	if h.Count() != 1000 || h.Sum() != 500500 || h.Min() != 1 || h.Max() != 1000 || h.Mean() != 500.5 {
+		t.Errorf("unexpected histogram: count %d sum %d min %d max %d mean %f", h.Count(), h.Sum(), h.Min(), h.Max(), h.Mean())
+	}
+	for _, tc := range []struct {
+		p        float64
+		expected float64
+	}{
+		{0, 1}, {0.5, 500}, {0.9, 900}, {0.99, 990}, {0.999, 999}, {1, 1000},
+	} {
+		if v := h.Percentile(tc.p); math.Abs(v-tc.expected) > 1 {
+			t.Errorf("p%v expected %v, got %v", tc.p, tc.expected, v)
+		}
+	}
+	if sd := h.StdDev(); math.Abs(sd-288.7) > 5 {
+		t.Errorf("unexpected stddev %f", sd)
+	}
+
+	// values out of the bounds are in the +Inf bucket, narrowed by max
+	h.Update(5000)
+	s := h.Snapshot().(*HistogramSnapshot)
+	if s.Buckets()[10] != 1 || s.Percentile(1) != 5000 {
+		t.Errorf("unexpected +Inf bucket: %v", s.Buckets())
+	}
+
+	h.Clear()
+	if h.Count() != 0 || h.Min() != 0 || h.Max() != 0 || h.Percentile(0.99) != 0 {
+		t.Error("histogram is not cleared")
+	}
+	h.Update(-5)
+	if h.Min() != -5 || h.Max() != -5 {
+		t.Errorf("unexpected min %d max %d", h.Min(), h.Max())
+	}
+}
+
+func TestHistogramBounds(t *testing.T) {
+	h := NewHistogram([]int64{30, 10, 20, 10})
+	s := h.Snapshot().(*HistogramSnapshot)
+	if len(s.Bounds()) != 3 || s.Bounds()[0] != 10 || s.Bounds()[2] != 30 || len(s.Buckets()) != 4 {
+		t.Errorf("unexpected bounds %v, buckets %v", s.Bounds(), s.Buckets())
+	}
+	// upper bounds are inclusive
+	for _, v := range []int64{10, 11, 30, 31} {
+		h.Update(v)
+	}
+	s = h.Snapshot().(*HistogramSnapshot)
+	for i, n := range []int64{1, 1, 1, 1} {
+		if s.Buckets()[i] != n {
+			t.Errorf("unexpected buckets %v", s.Buckets())
+		}
+	}
+}
+
+func TestHistogramConcurrent(t *testing.T) {
+	h := NewHistogram(linearBounds(10, 10, 10))
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				h.Update(int64(i*10 + j%10))
+			}
+		}(i)
+	}
+	wg.Wait()
+	if h.Count() != 10000 || h.Min() != 0 || h.Max() != 99 {
+		t.Errorf("unexpected histogram: count %d min %d max %d", h.Count(), h.Min(), h.Max())
+	}
+}
+
+func TestHistogramSnapshotMergeSub(t *testing.T) {
+	bounds := linearBounds(10, 10, 5)
+	h := NewHistogram(bounds)
+	for i := int64(0); i < 20; i++ {
+		h.Update(i)
+	}
+	first := h.Snapshot().(*HistogramSnapshot)
+	for i := int64(20); i < 50; i++ {
+		h.Update(i)
+	}
+	second := h.Snapshot().(*HistogramSnapshot)
+
+	// the distribution of the interval between two snapshots
+	delta, err := second.Sub(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta.Count() != 30 || delta.Sum() != 1035 {
+		t.Errorf("unexpected delta: %+v", delta)
+	}
+	// upper bounds are inclusive, 20 is in (10, 20]
+	for i, n := range []int64{0, 1, 10, 10, 9, 0} {
+		if delta.Buckets()[i] != n {
+			t.Fatalf("unexpected delta buckets: %v", delta.Buckets())
+		}
+	}
+
+	// merging the intervals gets the whole
+	if err := first.Merge(delta); err != nil {
+		t.Fatal(err)
+	}
+	for i := range second.Buckets() {
+		if first.Buckets()[i] != second.Buckets()[i] {
+			t.Fatalf("merged buckets %v, expected %v", first.Buckets(), second.Buckets())
+		}
+	}
+	if first.Count() != second.Count() || first.Sum() != second.Sum() || first.Min() != 0 || first.Max() != 49 {
+		t.Errorf("unexpected merged snapshot: %+v", first)
+	}
+
+	other := NewHistogram(linearBounds(10, 10, 4)).Snapshot().(*HistogramSnapshot)
+	if err := first.Merge(other); err != ErrBucketsMismatch {
+		t.Errorf("expected buckets mismatch, got %v", err)
+	}
+	if _, err := first.Sub(other); err != ErrBucketsMismatch {
+		t.Errorf("expected buckets mismatch, got %v", err)
+	}
+
+	// snapshot is read only
+	defer func() {
+		if recover() == nil {
+			t.Error("update a snapshot should panic")
+		}
+	}()
+	first.Update(1)
+}
+
+func TestShmHistogram(t *testing.T) {
+	zone := InitMetricsZone("TestShmHistogram", 64*1024)
+	defer func() {
+		zone.Detach()
+		Reset()
+	}()
+
+	bounds := []int64{10, 100}
+	h1 := NewShmHistogramFunc("TestShmHistogram", bounds)().(*ShmHistogram)
+	h1.Update(5)
+	h1.Update(50)
+
+	// the same name shares the cells, like another process after a hot upgrade
+	h2 := NewShmHistogramFunc("TestShmHistogram", bounds)().(*ShmHistogram)
+	h2.Update(500)
+	if h1.Count() != 3 || h1.Min() != 5 || h1.Max() != 500 || h1.Sum() != 555 {
+		t.Errorf("unexpected shared histogram: count %d min %d max %d sum %d", h1.Count(), h1.Min(), h1.Max(), h1.Sum())
+	}
+
+	h2.Stop()
+	h1.Stop()
+
+	// out of shm memory
+	small := InitMetricsZone("TestShmHistogramSmall", 1024)
+	defer small.Detach()
+	if _, ok := NewShmHistogramFunc("TestShmHistogramSmall", linearBounds(1, 1, 100))().(gometrics.NilHistogram); !ok {
+		t.Error("expected nil histogram if alloc failed")
+	}
+}
@@ -190,6 +190,25 @@ func (z *zone) alloc(name string) (*hashEntry, error) {
 	return entry, nil
 }
 
+// allocInit is like alloc, but sets the value of a newly created entry to init
+func (z *zone) allocInit(name string, init int64) (*hashEntry, error) {
+	z.lock()
+	defer z.unlock()
+
+	entry, create := z.set.Alloc(name)
+	if entry == nil {
+		return nil, errors.New("alloc failed")
+	}
+
+	if create {
+		atomic.StoreInt64(&entry.value, init)
+	} else {
+		entry.incRef()
+	}
+
+	return entry, nil
+}
+
 func (z *zone) free(entry *hashEntry) error {
 	z.lock()
 	defer z.unlock()
@@ -39,6 +39,7 @@ import (
 	"sofastack.io/sofa-mosn/pkg/admin/store"
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/metrics/shm"
 	"sofastack.io/sofa-mosn/pkg/metrics/sink"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
@@ -146,6 +147,10 @@ func (psink *promSink) Flush(writer io.Writer, ms []types.Metrics) {
 }
 
 func (psink *promSink) flushHistogram(tracker map[string]bool, buf types.IoBuffer, name string, labels string, snapshot gometrics.Histogram) {
+	// buckets
+	if h, ok := snapshot.(*shm.HistogramSnapshot); ok {
+		psink.flushBuckets(tracker, buf, name, labels, h)
+	}
 	// min
 	psink.flushGauge(tracker, buf, name+"_min", labels, float64(snapshot.Min()))
 	// max
@@ -153,6 +158,54 @@ func (psink *promSink) flushHistogram(tracker map[string]bool, buf types.IoBuffe
 	// TODO: flush P90 P95 P99 if configured
 }
 
+// flushBuckets flushes a native prometheus histogram, the buckets are cumulative
+func (psink *promSink) flushBuckets(tracker map[string]bool, buf types.IoBuffer, name string, labels string, h *shm.HistogramSnapshot) {
+	// type
+	if !tracker[name] {
+		buf.WriteString("# TYPE ")
+		buf.WriteString(name)
+		buf.WriteString(" histogram\n")
+		tracker[name] = true
+	}
+
+	leLabels := "le=\""
+	if labels != "" {
+		leLabels = labels + ",le=\""
+	}
+
+	bounds := h.Bounds()
+	var cumulative int64
+	for i, count := range h.Buckets() {
+		cumulative += count
+		buf.WriteString(name)
+		buf.WriteString("_bucket{")
+		buf.WriteString(leLabels)
+		if i < len(bounds) {
+			writeFloat(buf, float64(bounds[i]))
+		} else {
+			buf.WriteString("+Inf")
+		}
+		buf.WriteString("\"} ")
+		writeFloat(buf, float64(cumulative))
+		buf.WriteString("\n")
+	}
+
+	// sum & count
+	buf.WriteString(name)
+	buf.WriteString("_sum{")
+	buf.WriteString(labels)
+	buf.WriteString("} ")
+	writeFloat(buf, float64(h.Sum()))
+	buf.WriteString("\n")
+
+	buf.WriteString(name)
+	buf.WriteString("_count{")
+	buf.WriteString(labels)
+	buf.WriteString("} ")
+	writeFloat(buf, float64(cumulative))
+	buf.WriteString("\n")
+}
+
 func (psink *promSink) flushGauge(tracker map[string]bool, buf types.IoBuffer, name string, labels string, val float64) {
 	// type
 	if !tracker[name] {
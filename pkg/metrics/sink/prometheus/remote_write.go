@@ -0,0 +1,284 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/metrics/shm"
+	"sofastack.io/sofa-mosn/pkg/metrics/sink"
+	"sofastack.io/sofa-mosn/pkg/server/keeper"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+var (
+	remoteWriteSinkType    = "prometheus_remote_write"
+	defaultRemoteBatchSize = 500
+	defaultRemoteTimeout   = 10 * time.Second
+	remoteWriteVersion     = "0.1.0"
+	remoteWriteContentType = "application/x-protobuf"
+	remoteWriteNameLabel   = "__name__"
+	remoteWriteBucketLabel = "le"
+)
+
+func init() {
+	sink.RegisterSink(remoteWriteSinkType, remoteWriteBuilder)
+}
+
+// remoteWriteConfig contains config for the prometheus remote write sink
+type remoteWriteConfig struct {
+	sink.PushConfig
+
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers"`
+	Timeout  string            `json:"timeout"`
+	// BatchSize is the max time series count of a request
+	BatchSize int `json:"batch_size"`
+
+	timeout time.Duration
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+type timeSeries struct {
+	labels []label
+	value  float64
+}
+
+// remoteWriteSink writes the metrics in prometheus remote write requests,
+// each one is a snappy compressed WriteRequest protobuf of at most BatchSize time series
+type remoteWriteSink struct {
+	config *remoteWriteConfig
+
+	mutex sync.Mutex
+	now   func() time.Time
+}
+
+func newRemoteWriteSink(config *remoteWriteConfig) *remoteWriteSink {
+	return &remoteWriteSink{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// ~ MetricsSink
+func (rw *remoteWriteSink) Flush(writer io.Writer, ms []types.Metrics) {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	timestamp := rw.now().UnixNano() / int64(time.Millisecond)
+	batch := make([]timeSeries, 0, rw.config.BatchSize)
+	add := func(name string, labels []label, value float64, extra ...label) {
+		series := timeSeries{
+			labels: make([]label, 0, len(labels)+len(extra)+1),
+			value:  value,
+		}
+		series.labels = append(series.labels, label{remoteWriteNameLabel, name})
+		series.labels = append(series.labels, labels...)
+		series.labels = append(series.labels, extra...)
+		sort.Slice(series.labels, func(i, j int) bool { return series.labels[i].name < series.labels[j].name })
+		batch = append(batch, series)
+		if len(batch) >= rw.config.BatchSize {
+			writer.Write(encodeWriteRequest(batch, timestamp))
+			batch = batch[:0]
+		}
+	}
+
+	for _, m := range ms {
+		labelKeys, labelVals := m.SortedLabels()
+		if sink.IsExclusionLabels(labelKeys) {
+			continue
+		}
+		labels := make([]label, len(labelKeys))
+		for i := range labelKeys {
+			labels[i] = label{labelKeys[i], labelVals[i]}
+		}
+		prefix := m.Type() + "_"
+
+		m.Each(func(key string, i interface{}) {
+			if sink.IsExclusionKeys(key) {
+				return
+			}
+			name := flattenKey(prefix + key)
+			switch metric := i.(type) {
+			case gometrics.Counter:
+				add(name, labels, float64(metric.Count()))
+			case gometrics.Gauge:
+				add(name, labels, float64(metric.Value()))
+			case gometrics.Histogram:
+				snapshot := metric.Snapshot()
+				if h, ok := snapshot.(*shm.HistogramSnapshot); ok {
+					bounds := h.Bounds()
+					var cumulative int64
+					for i, count := range h.Buckets() {
+						cumulative += count
+						le := "+Inf"
+						if i < len(bounds) {
+							le = formatFloat(float64(bounds[i]))
+						}
+						add(name+"_bucket", labels, float64(cumulative), label{remoteWriteBucketLabel, le})
+					}
+					add(name+"_sum", labels, float64(h.Sum()))
+					add(name+"_count", labels, float64(cumulative))
+				}
+				add(name+"_min", labels, float64(snapshot.Min()))
+				add(name+"_max", labels, float64(snapshot.Max()))
+			}
+		})
+	}
+	if len(batch) > 0 {
+		writer.Write(encodeWriteRequest(batch, timestamp))
+	}
+}
+
+// encodeWriteRequest encodes the prometheus.WriteRequest protobuf and compresses it:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(batch []timeSeries, timestamp int64) []byte {
+	request := proto.NewBuffer(nil)
+	series := proto.NewBuffer(nil)
+	field := proto.NewBuffer(nil)
+	for _, ts := range batch {
+		series.Reset()
+		for _, l := range ts.labels {
+			field.Reset()
+			field.EncodeVarint(1<<3 | proto.WireBytes)
+			field.EncodeStringBytes(l.name)
+			field.EncodeVarint(2<<3 | proto.WireBytes)
+			field.EncodeStringBytes(l.value)
+			series.EncodeVarint(1<<3 | proto.WireBytes)
+			series.EncodeRawBytes(field.Bytes())
+		}
+		field.Reset()
+		field.EncodeVarint(1<<3 | proto.WireFixed64)
+		field.EncodeFixed64(math.Float64bits(ts.value))
+		field.EncodeVarint(2<<3 | proto.WireVarint)
+		field.EncodeVarint(uint64(timestamp))
+		series.EncodeVarint(2<<3 | proto.WireBytes)
+		series.EncodeRawBytes(field.Bytes())
+
+		request.EncodeVarint(1<<3 | proto.WireBytes)
+		request.EncodeRawBytes(series.Bytes())
+	}
+	return snappyEncode(request.Bytes())
+}
+
+// formatFloat formats the float like writeFloat
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, "e.") {
+		s += ".0"
+	}
+	return s
+}
+
+func (rw *remoteWriteSink) sender(client *http.Client) sink.Sender {
+	return func(batch []byte) error {
+		req, err := http.NewRequest(http.MethodPost, rw.config.Endpoint, bytes.NewReader(batch))
+		if err != nil {
+			return &sink.DiscardError{Err: err}
+		}
+		for k, v := range rw.config.Headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("Content-Type", remoteWriteContentType)
+		req.Header.Set("X-Prometheus-Remote-Write-Version", remoteWriteVersion)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		if resp.StatusCode/100 == 2 {
+			return nil
+		}
+		err = fmt.Errorf("remote write status %s: %s", resp.Status, body)
+		// the request is rejected, retrying does not help
+		if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+			return &sink.DiscardError{Err: err}
+		}
+		return err
+	}
+}
+
+// factory
+func remoteWriteBuilder(cfg map[string]interface{}) (types.MetricsSink, error) {
+	// parse config
+	rwCfg := &remoteWriteConfig{}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing prometheus remote write sink error, err: %v, cfg: %v", err, cfg)
+	}
+	if err := json.Unmarshal(data, rwCfg); err != nil {
+		return nil, fmt.Errorf("parsing prometheus remote write sink error, err: %v, cfg: %v", err, cfg)
+	}
+
+	if rwCfg.Endpoint == "" {
+		return nil, errors.New("prometheus remote write sink's endpoint is not specified")
+	}
+	if !strings.HasPrefix(rwCfg.Endpoint, "http://") && !strings.HasPrefix(rwCfg.Endpoint, "https://") {
+		return nil, fmt.Errorf("invalid endpoint format:%s", rwCfg.Endpoint)
+	}
+	if rwCfg.BatchSize <= 0 {
+		rwCfg.BatchSize = defaultRemoteBatchSize
+	}
+	rwCfg.timeout = defaultRemoteTimeout
+	if rwCfg.Timeout != "" {
+		if rwCfg.timeout, err = time.ParseDuration(rwCfg.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout: %v", err)
+		}
+	}
+
+	rwSink := newRemoteWriteSink(rwCfg)
+	pusher, err := sink.NewPusher(rwCfg.PushConfig, rwSink, metrics.GetAll, rwSink.sender(&http.Client{Timeout: rwCfg.timeout}))
+	if err != nil {
+		return nil, err
+	}
+	pusher.Start()
+
+	keeper.OnProcessShutDown(func() error {
+		pusher.Stop()
+		return nil
+	})
+
+	return rwSink, nil
+}
@@ -0,0 +1,297 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/metrics/sink"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// snappyDecode decodes the snappy block format
+func snappyDecode(src []byte) ([]byte, error) {
+	n, i := binary.Uvarint(src)
+	if i <= 0 {
+		return nil, errors.New("invalid length")
+	}
+	src = src[i:]
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 0x03 {
+		case snappyTagLiteral:
+			length := int(tag >> 2)
+			src = src[1:]
+			switch length {
+			case 60:
+				length = int(src[0])
+				src = src[1:]
+			case 61:
+				length = int(src[0]) | int(src[1])<<8
+				src = src[2:]
+			}
+			length++
+			if length > len(src) {
+				return nil, errors.New("invalid literal")
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+		case snappyTagCopy2:
+			length := int(tag>>2) + 1
+			offset := int(src[1]) | int(src[2])<<8
+			src = src[3:]
+			if offset == 0 || offset > len(dst) {
+				return nil, errors.New("invalid offset")
+			}
+			for j := 0; j < length; j++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		default:
+			return nil, errors.New("unsupported tag")
+		}
+	}
+	if uint64(len(dst)) != n {
+		return nil, errors.New("length mismatch")
+	}
+	return dst, nil
+}
+
+func TestSnappy(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.Read(random)
+	for _, src := range [][]byte{
+		nil,
+		[]byte("abc"),
+		[]byte("abcdabcdabcdabcdabcdabcd"),
+		bytes.Repeat([]byte("mosn_metrics "), 20000),
+		random,
+	} {
+		encoded := snappyEncode(src)
+		decoded, err := snappyDecode(encoded)
+		if err != nil {
+			t.Fatalf("decode %d bytes failed: %v", len(src), err)
+		}
+		if !bytes.Equal(decoded, src) {
+			t.Fatalf("round trip %d bytes failed", len(src))
+		}
+	}
+	if src := bytes.Repeat([]byte("mosn_metrics "), 20000); len(snappyEncode(src)) > len(src)/10 {
+		t.Error("repeated input is not compressed")
+	}
+}
+
+// pbReader reads the protobuf wire format
+type pbReader []byte
+
+func (r *pbReader) varint() uint64 {
+	v, n := binary.Uvarint(*r)
+	*r = (*r)[n:]
+	return v
+}
+
+func (r *pbReader) bytes() pbReader {
+	n := r.varint()
+	b := (*r)[:n]
+	*r = (*r)[n:]
+	return b
+}
+
+func (r *pbReader) fixed64() uint64 {
+	v := binary.LittleEndian.Uint64(*r)
+	*r = (*r)[8:]
+	return v
+}
+
+// decodeWriteRequest decodes the time series to name{labels} -> value
+func decodeWriteRequest(t *testing.T, body []byte) map[string]float64 {
+	data, err := snappyDecode(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := make(map[string]float64)
+	request := pbReader(data)
+	for len(request) > 0 {
+		if tag := request.varint(); tag != 1<<3|proto.WireBytes {
+			t.Fatalf("unexpected timeseries tag %d", tag)
+		}
+		ts := request.bytes()
+		var name, labels string
+		var value float64
+		for len(ts) > 0 {
+			tag := ts.varint()
+			field := ts.bytes()
+			switch tag {
+			case 1<<3 | proto.WireBytes:
+				field.varint()
+				k := string(field.bytes())
+				field.varint()
+				v := string(field.bytes())
+				if k == remoteWriteNameLabel {
+					name = v
+				} else {
+					labels += k + "=" + v + ","
+				}
+			case 2<<3 | proto.WireBytes:
+				field.varint()
+				value = math.Float64frombits(field.fixed64())
+				field.varint()
+				if ts := field.varint(); ts != 1000 {
+					t.Errorf("unexpected timestamp %d", ts)
+				}
+			}
+		}
+		series[name+"{"+labels+"}"] = value
+	}
+	return series
+}
+
+func TestRemoteWriteEncode(t *testing.T) {
+	metrics.ResetAll()
+	m, _ := metrics.NewMetrics("upstream", map[string]string{"cluster": "c1"})
+	m.Counter("request_total").Inc(3)
+	m.Gauge("request_active").Update(2)
+	m.Histogram("request_time").Update(1)
+	m.Histogram("request_time").Update(2e10)
+
+	rw := newRemoteWriteSink(&remoteWriteConfig{BatchSize: 5})
+	rw.now = func() time.Time { return time.Unix(1, 0) }
+	buf := &batchWriter{}
+	rw.Flush(buf, []types.Metrics{m})
+
+	// 2 + 17 buckets + sum + count + min + max time series in batches of 5
+	if len(buf.batches) != 5 {
+		t.Fatalf("expected 5 batches, got %d", len(buf.batches))
+	}
+	series := make(map[string]float64)
+	for _, b := range buf.batches {
+		for k, v := range decodeWriteRequest(t, b) {
+			series[k] = v
+		}
+	}
+	for name, expected := range map[string]float64{
+		"upstream_request_total{cluster=c1,}":                   3,
+		"upstream_request_active{cluster=c1,}":                  2,
+		"upstream_request_time_bucket{cluster=c1,le=100000.0,}": 1,
+		"upstream_request_time_bucket{cluster=c1,le=1e+10,}":    1,
+		"upstream_request_time_bucket{cluster=c1,le=+Inf,}":     2,
+		"upstream_request_time_sum{cluster=c1,}":                2e10 + 1,
+		"upstream_request_time_count{cluster=c1,}":              2,
+		"upstream_request_time_max{cluster=c1,}":                2e10,
+	} {
+		if v, ok := series[name]; !ok || v != expected {
+			t.Errorf("%s expected %v, got %v %v", name, expected, v, ok)
+		}
+	}
+}
+
+type batchWriter struct {
+	batches [][]byte
+}
+
+func (w *batchWriter) Write(p []byte) (int, error) {
+	w.batches = append(w.batches, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestRemoteWritePush(t *testing.T) {
+	var mutex sync.Mutex
+	status := http.StatusInternalServerError
+	received := make(chan map[string]float64, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != remoteWriteContentType ||
+			r.Header.Get("X-Prometheus-Remote-Write-Version") != remoteWriteVersion || r.Header.Get("X-Scope-OrgID") != "mosn" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		mutex.Lock()
+		code := status
+		mutex.Unlock()
+		w.WriteHeader(code)
+		if code == http.StatusOK {
+			body, _ := ioutil.ReadAll(r.Body)
+			data, _ := snappyDecode(body)
+			if len(data) > 0 {
+				received <- map[string]float64{}
+			}
+		}
+	}))
+	defer server.Close()
+
+	metrics.ResetAll()
+	m, _ := metrics.NewMetrics("upstream", map[string]string{"cluster": "c1"})
+	m.Counter("request_total").Inc(1)
+
+	rw := newRemoteWriteSink(&remoteWriteConfig{Endpoint: server.URL, BatchSize: 100})
+	p, err := sink.NewPusher(sink.PushConfig{MaxBufferedBatches: 2}, rw, metrics.GetAll, rw.sender(http.DefaultClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw.config.Headers = map[string]string{"X-Scope-OrgID": "mosn"}
+
+	// the collector fails, batches are buffered up to the max
+	for i := 0; i < 3; i++ {
+		p.Flush()
+	}
+	if p.Pending() != 2 {
+		t.Fatalf("expected 2 batches buffered, got %d", p.Pending())
+	}
+
+	// rejected batches are dropped
+	mutex.Lock()
+	status = http.StatusBadRequest
+	mutex.Unlock()
+	p.Flush()
+	if p.Pending() != 0 {
+		t.Fatalf("expected rejected batches dropped, got %d", p.Pending())
+	}
+
+	mutex.Lock()
+	status = http.StatusOK
+	mutex.Unlock()
+	p.Flush()
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("no batch received")
+	}
+}
+
+func TestRemoteWriteBuilder(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"endpoint": "127.0.0.1:9090"},
+		{"endpoint": "http://127.0.0.1:9090", "timeout": "x"},
+		{"endpoint": "http://127.0.0.1:9090", "flush_interval": "x"},
+	} {
+		if _, err := remoteWriteBuilder(cfg); err == nil {
+			t.Errorf("expected config %v invalid", cfg)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"encoding/binary"
+)
+
+// snappy block format encoder for the remote write body, see
+// https://github.com/google/snappy/blob/master/format_description.txt
+// The input is split into blocks of 64KB, so the copies use 2 bytes offsets.
+
+const (
+	snappyBlockSize  = 1 << 16
+	snappyTableBits  = 14
+	snappyMinMatch   = 4
+	snappyMaxCopyLen = 64
+
+	snappyTagLiteral = 0x00
+	snappyTagCopy2   = 0x02
+)
+
+// snappyEncode returns the snappy block encoding of src
+func snappyEncode(src []byte) []byte {
+	dst := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(src)+len(src)/6+32)
+	dst = dst[:binary.PutUvarint(dst, uint64(len(src)))]
+	for len(src) > 0 {
+		block := src
+		if len(block) > snappyBlockSize {
+			block = block[:snappyBlockSize]
+		}
+		src = src[len(block):]
+		dst = snappyEncodeBlock(dst, block)
+	}
+	return dst
+}
+
+func snappyHash(u uint32) uint32 {
+	return (u * 0x1e35a7bd) >> (32 - snappyTableBits)
+}
+
+func snappyEncodeBlock(dst, src []byte) []byte {
+	if len(src) < snappyMinMatch {
+		return snappyLiteral(dst, src)
+	}
+
+	var table [1 << snappyTableBits]int32
+	for i := range table {
+		table[i] = -1
+	}
+
+	literal := 0
+	for i := 0; i+snappyMinMatch <= len(src); {
+		u := binary.LittleEndian.Uint32(src[i:])
+		h := snappyHash(u)
+		candidate := int(table[h])
+		table[h] = int32(i)
+		if candidate < 0 || binary.LittleEndian.Uint32(src[candidate:]) != u {
+			i++
+			continue
+		}
+
+		// extend the match
+		length := snappyMinMatch
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = snappyLiteral(dst, src[literal:i])
+		dst = snappyCopy(dst, i-candidate, length)
+		i += length
+		literal = i
+	}
+	return snappyLiteral(dst, src[literal:])
+}
+
+func snappyLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|snappyTagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|snappyTagLiteral, byte(n))
+	default:
+		// blocks are at most 64KB
+		dst = append(dst, 61<<2|snappyTagLiteral, byte(n), byte(n>>8))
+	}
+	return append(dst, lit...)
+}
+
+func snappyCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		n := length
+		if n > snappyMaxCopyLen {
+			n = snappyMaxCopyLen
+			// keep the remainder long enough to be worth a copy
+			if length-n < snappyMinMatch {
+				n = length - snappyMinMatch
+			}
+		}
+		dst = append(dst, byte(n-1)<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+		length -= n
+	}
+	return dst
+}
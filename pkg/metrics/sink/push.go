@@ -0,0 +1,174 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+const (
+	defaultFlushInterval      = 10 * time.Second
+	defaultMaxBufferedBatches = 100
+)
+
+// PushConfig is the common config of the sinks pushing metrics to a collector
+type PushConfig struct {
+	FlushInterval string `json:"flush_interval"`
+	// MaxBufferedBatches limits the batches kept while the collector is down, the oldest ones are dropped
+	MaxBufferedBatches int `json:"max_buffered_batches"`
+}
+
+// Sender sends a batch to the collector
+type Sender func(batch []byte) error
+
+// DiscardError is returned by a Sender if the batch should be dropped instead of retried,
+// for example the collector rejects it
+type DiscardError struct {
+	Err error
+}
+
+func (e *DiscardError) Error() string {
+	return e.Err.Error()
+}
+
+// Pusher flushes the metrics to a sink periodically and sends what the sink writes.
+// Each Write of the sink is a batch sent in one request or packet. The batches
+// failed to send are kept and retried in the next flush.
+type Pusher struct {
+	interval    time.Duration
+	maxBuffered int
+	sink        types.MetricsSink
+	gather      func() []types.Metrics
+	send        Sender
+
+	mutex   sync.Mutex
+	pending [][]byte
+	dropped int
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPusher creates a pusher, gather returns the metrics to flush, usually metrics.GetAll
+func NewPusher(config PushConfig, sink types.MetricsSink, gather func() []types.Metrics, send Sender) (*Pusher, error) {
+	p := &Pusher{
+		interval:    defaultFlushInterval,
+		maxBuffered: defaultMaxBufferedBatches,
+		sink:        sink,
+		gather:      gather,
+		send:        send,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if config.FlushInterval != "" {
+		interval, err := time.ParseDuration(config.FlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flush interval: %v", err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("invalid flush interval: %s", config.FlushInterval)
+		}
+		p.interval = interval
+	}
+	if config.MaxBufferedBatches > 0 {
+		p.maxBuffered = config.MaxBufferedBatches
+	}
+	return p, nil
+}
+
+// Start flushes every interval until Stop
+func (p *Pusher) Start() {
+	utils.GoWithRecover(p.run, nil)
+}
+
+func (p *Pusher) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.Flush()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the periodic flush and flushes for the last time
+func (p *Pusher) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+		p.Flush()
+	})
+}
+
+// Flush flushes the metrics to the sink and sends the pending batches
+func (p *Pusher) Flush() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.sink.Flush(p, p.gather())
+	if p.dropped > 0 {
+		log.DefaultLogger.Warnf("[metrics] [sink] push buffer is full, %d batches dropped", p.dropped)
+		p.dropped = 0
+	}
+
+	for len(p.pending) > 0 {
+		err := p.send(p.pending[0])
+		if err != nil {
+			if _, ok := err.(*DiscardError); !ok {
+				log.DefaultLogger.Errorf("[metrics] [sink] push metrics failed, %d batches buffered: %v", len(p.pending), err)
+				return
+			}
+			log.DefaultLogger.Errorf("[metrics] [sink] push metrics failed, batch dropped: %v", err)
+		}
+		p.pending[0] = nil
+		p.pending = p.pending[1:]
+	}
+	p.pending = nil
+}
+
+// Write buffers a batch, it is called by the sink in Flush
+func (p *Pusher) Write(batch []byte) (int, error) {
+	b := make([]byte, len(batch))
+	copy(b, batch)
+	if len(p.pending) >= p.maxBuffered {
+		p.pending[0] = nil
+		p.pending = p.pending[1:]
+		p.dropped++
+	}
+	p.pending = append(p.pending, b)
+	return len(batch), nil
+}
+
+// Pending returns the count of the batches not sent yet
+func (p *Pusher) Pending() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.pending)
+}
@@ -0,0 +1,126 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// countSink writes one batch per flush, numbered from 0
+type countSink struct {
+	flushed int
+}
+
+func (s *countSink) Flush(writer io.Writer, ms []types.Metrics) {
+	writer.Write([]byte(strconv.Itoa(s.flushed)))
+	s.flushed++
+}
+
+func gatherNothing() []types.Metrics {
+	return nil
+}
+
+func TestPusherBuffering(t *testing.T) {
+	var sent []string
+	var sendErr error
+	p, err := NewPusher(PushConfig{MaxBufferedBatches: 3}, &countSink{}, gatherNothing, func(batch []byte) error {
+		if sendErr != nil {
+			return sendErr
+		}
+		sent = append(sent, string(batch))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Flush()
+	if len(sent) != 1 || sent[0] != "0" || p.Pending() != 0 {
+		t.Fatalf("unexpected sent %v, pending %d", sent, p.Pending())
+	}
+
+	// the collector is down, batches are buffered and the oldest ones dropped
+	sendErr = errors.New("connection refused")
+	for i := 0; i < 5; i++ {
+		p.Flush()
+	}
+	if p.Pending() != 3 {
+		t.Fatalf("expected 3 batches buffered, got %d", p.Pending())
+	}
+
+	// the collector is back, the buffered batches are sent in order
+	sendErr = nil
+	p.Flush()
+	expected := []string{"0", "4", "5", "6"}
+	if len(sent) != len(expected) || p.Pending() != 0 {
+		t.Fatalf("unexpected sent %v", sent)
+	}
+	for i := range expected {
+		if sent[i] != expected[i] {
+			t.Errorf("unexpected sent %v", sent)
+		}
+	}
+
+	// discarded batches are not retried
+	sendErr = &DiscardError{Err: errors.New("bad request")}
+	p.Flush()
+	if p.Pending() != 0 {
+		t.Errorf("discarded batch should not be buffered")
+	}
+}
+
+func TestPusherStartStop(t *testing.T) {
+	if _, err := NewPusher(PushConfig{FlushInterval: "x"}, &countSink{}, gatherNothing, nil); err == nil {
+		t.Error("expected invalid flush interval")
+	}
+	if _, err := NewPusher(PushConfig{FlushInterval: "-1s"}, &countSink{}, gatherNothing, nil); err == nil {
+		t.Error("expected invalid flush interval")
+	}
+
+	sent := make(chan string, 100)
+	p, err := NewPusher(PushConfig{FlushInterval: "10ms"}, &countSink{}, gatherNothing, func(batch []byte) error {
+		sent <- string(batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Start()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("no batch sent by interval")
+	}
+	p.Stop()
+	// stop flushes for the last time
+	count := len(sent)
+	if count == 0 {
+		t.Error("expected the last flush on stop")
+	}
+	p.Stop()
+	time.Sleep(30 * time.Millisecond)
+	if len(sent) != count {
+		t.Error("flushed after stop")
+	}
+}
@@ -0,0 +1,263 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package statsd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/metrics/shm"
+	"sofastack.io/sofa-mosn/pkg/metrics/sink"
+	"sofastack.io/sofa-mosn/pkg/server/keeper"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+var (
+	sinkType             = "statsd"
+	defaultMaxPacketSize = 1432
+	// histogram output percents of each flush interval
+	percents = []float64{0.5, 0.9, 0.99}
+)
+
+// flavors of the statsd protocol
+const (
+	FlavorStatsd    = "statsd"
+	FlavorDogStatsd = "dogstatsd"
+)
+
+func init() {
+	sink.RegisterSink(sinkType, builder)
+}
+
+// statsdConfig contains config for the statsd sink
+type statsdConfig struct {
+	sink.PushConfig
+
+	Address string `json:"address"`
+	Prefix  string `json:"prefix"`
+	// Flavor is statsd or dogstatsd, labels are tags in dogstatsd and part of the name in statsd
+	Flavor        string `json:"flavor"`
+	MaxPacketSize int    `json:"max_packet_size"`
+}
+
+// statsdSink writes the metrics in statsd lines, packed into packets of at most MaxPacketSize.
+// Counters are sent as the delta since the last flush, histograms as the percentiles
+// of the values recorded since the last flush.
+type statsdSink struct {
+	config *statsdConfig
+
+	mutex sync.Mutex
+	// the values of the last flush
+	counters   map[string]int64
+	histograms map[string]*shm.HistogramSnapshot
+}
+
+func newStatsdSink(config *statsdConfig) *statsdSink {
+	return &statsdSink{
+		config:     config,
+		counters:   make(map[string]int64),
+		histograms: make(map[string]*shm.HistogramSnapshot),
+	}
+}
+
+// ~ MetricsSink
+func (s *statsdSink) Flush(writer io.Writer, ms []types.Metrics) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	counters := make(map[string]int64, len(s.counters))
+	histograms := make(map[string]*shm.HistogramSnapshot, len(s.histograms))
+	packet := &bytes.Buffer{}
+	line := &bytes.Buffer{}
+
+	write := func() {
+		if packet.Len()+line.Len()+1 > s.config.MaxPacketSize && packet.Len() > 0 {
+			writer.Write(packet.Bytes())
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.Write(line.Bytes())
+		line.Reset()
+	}
+
+	for _, m := range ms {
+		labelKeys, labelVals := m.SortedLabels()
+		if sink.IsExclusionLabels(labelKeys) {
+			continue
+		}
+		prefix, tags := s.namespace(m.Type(), labelKeys, labelVals)
+
+		m.Each(func(key string, i interface{}) {
+			if sink.IsExclusionKeys(key) {
+				return
+			}
+			name := prefix + sanitizeName(key)
+			switch metric := i.(type) {
+			case gometrics.Counter:
+				count := metric.Count()
+				counters[name+tags] = count
+				if delta := count - s.counters[name+tags]; delta != 0 {
+					writeLine(line, name, strconv.FormatInt(delta, 10), "c", tags)
+					write()
+				}
+			case gometrics.Gauge:
+				writeLine(line, name, strconv.FormatInt(metric.Value(), 10), "g", tags)
+				write()
+			case gometrics.Histogram:
+				snapshot := metric.Snapshot()
+				h, ok := snapshot.(*shm.HistogramSnapshot)
+				if !ok {
+					writeLine(line, name+".min", strconv.FormatInt(snapshot.Min(), 10), "g", tags)
+					write()
+					writeLine(line, name+".max", strconv.FormatInt(snapshot.Max(), 10), "g", tags)
+					write()
+					return
+				}
+				histograms[name+tags] = h
+				interval := h
+				if prev, ok := s.histograms[name+tags]; ok {
+					if delta, err := h.Sub(prev); err == nil {
+						interval = delta
+					}
+				}
+				if interval.Count() == 0 {
+					return
+				}
+				writeLine(line, name+".count", strconv.FormatInt(interval.Count(), 10), "c", tags)
+				write()
+				writeLine(line, name+".sum", strconv.FormatInt(interval.Sum(), 10), "c", tags)
+				write()
+				for _, p := range percents {
+					value := strconv.FormatFloat(interval.Percentile(p), 'f', 0, 64)
+					writeLine(line, name+".p"+strconv.FormatFloat(p*100, 'f', -1, 64), value, "g", tags)
+					write()
+				}
+			}
+		})
+	}
+	if packet.Len() > 0 {
+		writer.Write(packet.Bytes())
+	}
+
+	s.counters = counters
+	s.histograms = histograms
+}
+
+// namespace returns the name prefix and the tags suffix of the metrics
+func (s *statsdSink) namespace(typ string, keys, values []string) (prefix string, tags string) {
+	prefix = s.config.Prefix + sanitizeName(typ) + "."
+	if len(keys) == 0 {
+		return
+	}
+	if s.config.Flavor == FlavorDogStatsd {
+		pairs := make([]string, 0, len(keys))
+		for i := range keys {
+			pairs = append(pairs, sanitizeTag(keys[i])+":"+sanitizeTag(values[i]))
+		}
+		tags = "|#" + strings.Join(pairs, ",")
+		return
+	}
+	for i := range keys {
+		prefix += sanitizeName(keys[i]) + "." + sanitizeName(values[i]) + "."
+	}
+	return
+}
+
+func writeLine(line *bytes.Buffer, name, value, typ, tags string) {
+	line.WriteString(name)
+	line.WriteByte(':')
+	line.WriteString(value)
+	line.WriteByte('|')
+	line.WriteString(typ)
+	line.WriteString(tags)
+}
+
+var (
+	nameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+	tagReplacer  = strings.NewReplacer("|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+)
+
+func sanitizeName(s string) string {
+	return nameReplacer.Replace(s)
+}
+
+func sanitizeTag(s string) string {
+	return tagReplacer.Replace(s)
+}
+
+// factory
+func builder(cfg map[string]interface{}) (types.MetricsSink, error) {
+	// parse config
+	statsdCfg := &statsdConfig{}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing statsd sink error, err: %v, cfg: %v", err, cfg)
+	}
+	if err := json.Unmarshal(data, statsdCfg); err != nil {
+		return nil, fmt.Errorf("parsing statsd sink error, err: %v, cfg: %v", err, cfg)
+	}
+
+	if statsdCfg.Address == "" {
+		return nil, errors.New("statsd sink's address is not specified")
+	}
+	switch statsdCfg.Flavor {
+	case "":
+		statsdCfg.Flavor = FlavorStatsd
+	case FlavorStatsd, FlavorDogStatsd:
+	default:
+		return nil, fmt.Errorf("invalid statsd flavor: %s", statsdCfg.Flavor)
+	}
+	if statsdCfg.MaxPacketSize <= 0 {
+		statsdCfg.MaxPacketSize = defaultMaxPacketSize
+	}
+
+	conn, err := net.Dial("udp", statsdCfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("statsd sink dial %s error: %v", statsdCfg.Address, err)
+	}
+
+	statsdSink := newStatsdSink(statsdCfg)
+	pusher, err := sink.NewPusher(statsdCfg.PushConfig, statsdSink, metrics.GetAll, func(batch []byte) error {
+		_, err := conn.Write(batch)
+		return err
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	pusher.Start()
+
+	keeper.OnProcessShutDown(func() error {
+		pusher.Stop()
+		return conn.Close()
+	})
+
+	return statsdSink, nil
+}
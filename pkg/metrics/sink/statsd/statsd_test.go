@@ -0,0 +1,188 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package statsd
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type packetWriter struct {
+	packets []string
+}
+
+func (w *packetWriter) Write(p []byte) (int, error) {
+	w.packets = append(w.packets, string(p))
+	return len(p), nil
+}
+
+func (w *packetWriter) lines() []string {
+	var lines []string
+	for _, p := range w.packets {
+		lines = append(lines, strings.Split(p, "\n")...)
+	}
+	return lines
+}
+
+func contains(lines []string, expected string) bool {
+	for _, l := range lines {
+		if l == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestMetrics(t *testing.T) types.Metrics {
+	metrics.ResetAll()
+	m, err := metrics.NewMetrics("downstream", map[string]string{"listener": "127.0.0.1:2045"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestStatsdFlush(t *testing.T) {
+	m := newTestMetrics(t)
+	m.Counter("request_total").Inc(3)
+	m.Gauge("request_active").Update(2)
+	for i := int64(1); i <= 100; i++ {
+		m.Histogram("request_time").Update(i * 1e6)
+	}
+
+	s := newStatsdSink(&statsdConfig{Prefix: "mosn.", Flavor: FlavorStatsd, MaxPacketSize: defaultMaxPacketSize})
+	w := &packetWriter{}
+	s.Flush(w, []types.Metrics{m})
+	lines := w.lines()
+	prefix := "mosn.downstream.listener.127.0.0.1_2045."
+	for _, expected := range []string{
+		prefix + "request_total:3|c",
+		prefix + "request_active:2|g",
+		prefix + "request_time.count:100|c",
+		prefix + "request_time.sum:5050000000|c",
+		prefix + "request_time.p50:50000000|g",
+	} {
+		if !contains(lines, expected) {
+			t.Errorf("%s not found in %v", expected, lines)
+		}
+	}
+
+	// counters are deltas, histograms are the values of the interval
+	m.Counter("request_total").Inc(2)
+	m.Histogram("request_time").Update(500e6)
+	w = &packetWriter{}
+	s.Flush(w, []types.Metrics{m})
+	lines = w.lines()
+	for _, expected := range []string{
+		prefix + "request_total:2|c",
+		prefix + "request_time.count:1|c",
+		prefix + "request_time.sum:500000000|c",
+	} {
+		if !contains(lines, expected) {
+			t.Errorf("%s not found in %v", expected, lines)
+		}
+	}
+	// the only value of the interval is in the bucket (250ms, 500ms]
+	for _, l := range lines {
+		if strings.HasPrefix(l, prefix+"request_time.p99:") {
+			v, _ := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(l, prefix+"request_time.p99:"), "|g"), 10, 64)
+			if v <= 250e6 || v > 500e6 {
+				t.Errorf("unexpected interval p99 %s", l)
+			}
+		}
+	}
+
+	// nothing changed
+	w = &packetWriter{}
+	s.Flush(w, []types.Metrics{m})
+	if lines := w.lines(); len(lines) != 1 || lines[0] != prefix+"request_active:2|g" {
+		t.Errorf("unexpected lines %v", lines)
+	}
+}
+
+func TestDogStatsdTags(t *testing.T) {
+	m := newTestMetrics(t)
+	m.Counter("request_total").Inc(1)
+
+	s := newStatsdSink(&statsdConfig{Flavor: FlavorDogStatsd, MaxPacketSize: defaultMaxPacketSize})
+	w := &packetWriter{}
+	s.Flush(w, []types.Metrics{m})
+	if lines := w.lines(); !contains(lines, "downstream.request_total:1|c|#listener:127.0.0.1:2045") {
+		t.Errorf("unexpected lines %v", lines)
+	}
+}
+
+func TestStatsdPacketSize(t *testing.T) {
+	m := newTestMetrics(t)
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		m.Gauge(key).Update(1)
+	}
+	s := newStatsdSink(&statsdConfig{Flavor: FlavorStatsd, MaxPacketSize: 100})
+	w := &packetWriter{}
+	s.Flush(w, []types.Metrics{m})
+	if len(w.packets) < 2 || len(w.lines()) != 6 {
+		t.Fatalf("expected lines split into packets, got %v", w.packets)
+	}
+	for _, p := range w.packets {
+		if len(p) > 100 {
+			t.Errorf("packet exceeds max size: %d", len(p))
+		}
+	}
+}
+
+func TestStatsdBuilder(t *testing.T) {
+	if _, err := builder(map[string]interface{}{}); err == nil {
+		t.Error("expected address required")
+	}
+	if _, err := builder(map[string]interface{}{"address": "127.0.0.1:8125", "flavor": "unknown"}); err == nil {
+		t.Error("expected invalid flavor")
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m := newTestMetrics(t)
+	m.Gauge("request_active").Update(7)
+	if _, err := builder(map[string]interface{}{
+		"address":        conn.LocalAddr().String(),
+		"flavor":         FlavorDogStatsd,
+		"flush_interval": "10ms",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1500)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf[:n], []byte("downstream.request_active:7|g|#listener:127.0.0.1:2045")) {
+		t.Errorf("unexpected packet: %s", buf[:n])
+	}
+}
@@ -31,6 +31,16 @@ import (
 
 const maxLabelCount = 10
 
+// DefaultHistogramBucketsKey is the key of the histogram buckets used by the keys not configured
+const DefaultHistogramBucketsKey = "default"
+
+// defaultHistogramBuckets are the upper bounds of the latency histograms in nanoseconds, from 100us to 10s
+var defaultHistogramBuckets = []int64{
+	100e3, 250e3, 500e3,
+	1e6, 2.5e6, 5e6, 10e6, 25e6, 50e6, 100e6, 250e6, 500e6,
+	1e9, 2.5e9, 5e9, 10e9,
+}
+
 var (
 	defaultStore *store
 	defaultMatcher *metricsMatcher
@@ -43,6 +53,9 @@ type store struct {
 
 	metrics map[string]types.Metrics
 	mutex   sync.RWMutex
+
+	// histogram bucket bounds by key
+	buckets map[string][]int64
 }
 
 // metrics is a wrapper of go-metrics registry, is an implement of types.Metrics
@@ -80,6 +93,29 @@ func SetStatsMatcher(all bool, exclusionLabels, exclusionKeys []string) {
 	}
 }
 
+// SetHistogramBuckets sets the bucket bounds of the histograms by key,
+// the DefaultHistogramBucketsKey sets the bounds of the keys not listed.
+// It affects the histograms created after it is called.
+func SetHistogramBuckets(buckets map[string][]int64) {
+	defaultStore.mutex.Lock()
+	defer defaultStore.mutex.Unlock()
+
+	defaultStore.buckets = buckets
+}
+
+func histogramBuckets(key string) []int64 {
+	defaultStore.mutex.RLock()
+	defer defaultStore.mutex.RUnlock()
+
+	if bounds, ok := defaultStore.buckets[key]; ok && len(bounds) > 0 {
+		return bounds
+	}
+	if bounds, ok := defaultStore.buckets[DefaultHistogramBucketsKey]; ok && len(bounds) > 0 {
+		return bounds
+	}
+	return defaultHistogramBuckets
+}
+
 // NewMetrics returns a metrics
 // Same (type + labels) pair will leading to the same Metrics instance
 func NewMetrics(typ string, labels map[string]string) (types.Metrics, error) {
@@ -115,6 +151,18 @@ func NewMetrics(typ string, labels map[string]string) (types.Metrics, error) {
 	return stats, nil
 }
 
+// DeleteMetrics unregisters the metrics of the (type + labels) pair and removes it from the store
+func DeleteMetrics(typ string, labels map[string]string) {
+	defaultStore.mutex.Lock()
+	defer defaultStore.mutex.Unlock()
+
+	name, _, _ := fullName(typ, labels)
+	if m, ok := defaultStore.metrics[name]; ok {
+		m.UnregisterAll()
+		delete(defaultStore.metrics, name)
+	}
+}
+
 func sortedLabels(labels map[string]string) (keys, values []string) {
 	keys = make([]string, 0, len(labels))
 	values = make([]string, 0, len(labels))
@@ -171,7 +219,7 @@ func (s *metrics) Histogram(key string) gometrics.Histogram {
 		return gometrics.NilHistogram{}
 	}
 
-	return s.registry.GetOrRegister(key, func() gometrics.Histogram { return gometrics.NewHistogram(gometrics.NewUniformSample(100)) }).(gometrics.Histogram)
+	return s.registry.GetOrRegister(key, shm.NewShmHistogramFunc(s.fullName(key), histogramBuckets(key))).(gometrics.Histogram)
 }
 
 func (s *metrics) Each(f func(string, interface{})) {
@@ -189,3 +189,58 @@ func BenchmarkNewMetrics_DifferentLabels(b *testing.B) {
 		b.Errorf("different labels gets same metrics, total %d, registered %d", total, registered)
 	}
 }
+
+func TestDeleteMetrics(t *testing.T) {
+	zone := shm.InitMetricsZone("TestDeleteMetrics", 10*1024)
+	defer func() {
+		zone.Detach()
+		shm.Reset()
+	}()
+
+	ResetAll()
+
+	NewHostHealthStats("service", "host1")
+	NewHostHealthStats("service", "host2")
+	DeleteHostHealthStats("service", "host1")
+	// delete a not exists one is ok
+	DeleteHostHealthStats("service", "host3")
+	all := GetAll()
+	if len(all) != 1 || all[0].Labels()["host"] != "host2" {
+		t.Errorf("delete metrics failed, remains: %d", len(all))
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	ResetAll()
+	defer SetHistogramBuckets(nil)
+
+	SetHistogramBuckets(map[string][]int64{
+		DefaultHistogramBucketsKey: {1, 2},
+		"latency":                  {10, 20, 30},
+	})
+
+	m, _ := NewMetrics("buckets", map[string]string{"lk": "lv"})
+	testCases := []struct {
+		key    string
+		bounds int
+	}{
+		{"latency", 3},
+		{"others", 2},
+	}
+	for _, tc := range testCases {
+		h := m.Histogram(tc.key)
+		h.Update(15)
+		s, ok := h.Snapshot().(*shm.HistogramSnapshot)
+		if !ok {
+			t.Fatalf("%s expected a bucket histogram, got %T", tc.key, h)
+		}
+		if len(s.Bounds()) != tc.bounds || s.Count() != 1 {
+			t.Errorf("%s unexpected bounds %v", tc.key, s.Bounds())
+		}
+	}
+
+	SetHistogramBuckets(nil)
+	if bounds := histogramBuckets("latency"); len(bounds) != len(defaultHistogramBuckets) {
+		t.Errorf("expected default buckets, got %v", bounds)
+	}
+}
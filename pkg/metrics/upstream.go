@@ -59,6 +59,24 @@ const (
 	UpstreamBytesReadBuffered    = "connection_bytes_read_buffered"
 	UpstreamBytesWriteTotal      = "connection_bytes_write"
 	UpstreamBytesWriteBuffered   = "connection_bytes_write_buffered"
+
+	UpstreamOutlierEjectionsTotal                     = "outlier_ejections_total"
+	UpstreamOutlierEjectionsActive                    = "outlier_ejections_active"
+	UpstreamOutlierEjectionsOverflow                  = "outlier_ejections_overflow"
+	UpstreamOutlierEjectionsConsecutive5xx            = "outlier_ejections_consecutive_5xx"
+	UpstreamOutlierEjectionsConsecutiveConnectFailure = "outlier_ejections_consecutive_connect_failure"
+	UpstreamOutlierEjectionsSuccessRate               = "outlier_ejections_success_rate"
+
+	UpstreamRequestShadow       = "request_shadow"
+	UpstreamRequestShadowFailed = "request_shadow_failed"
+)
+
+// key in cluster priority/host, the remaining capacity of circuit breakers
+const (
+	UpstreamRemainingConnections     = "remaining_connections"
+	UpstreamRemainingPendingRequests = "remaining_pending_requests"
+	UpstreamRemainingRequests        = "remaining_requests"
+	UpstreamRemainingRetries         = "remaining_retries"
 )
 
 // NewHostStats returns a stats that namespace contains cluster and host address
@@ -72,3 +90,9 @@ func NewClusterStats(clusterName string) types.Metrics {
 	metrics, _ := NewMetrics(UpstreamType, map[string]string{"cluster": clusterName})
 	return metrics
 }
+
+// NewClusterPriorityStats returns a stats that namespace contains cluster and routing priority
+func NewClusterPriorityStats(clusterName string, priority string) types.Metrics {
+	metrics, _ := NewMetrics(UpstreamType, map[string]string{"cluster": clusterName, "priority": priority})
+	return metrics
+}
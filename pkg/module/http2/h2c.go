@@ -0,0 +1,153 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http2
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/lex/httplex"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+var (
+	errNotH2cUpgrade  = errors.New("http2: not an h2c upgrade request")
+	errUpgradeTooLong = errors.New("http2: h2c upgrade request header too long")
+
+	headerEnd       = []byte("\r\n\r\n")
+	switchProtocols = []byte("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n")
+)
+
+// H2cUpgrade is the request carried by an HTTP/1.1 "Upgrade: h2c" handshake.
+// Once the connection switched to HTTP/2 the request becomes stream 1 in the
+// half-closed (remote) state, see RFC 7540 section 3.2.
+type H2cUpgrade struct {
+	Stream *MStream
+	Data   []byte
+}
+
+// ReadUpgrade checks the first bytes of a cleartext connection. A client that
+// starts with the connection preface is using prior knowledge and nil is
+// returned without consuming anything. Otherwise the data must hold an
+// HTTP/1.1 request asking for h2c, which is answered with 101 Switching
+// Protocols and returned as stream 1.
+func (sc *MServerConn) ReadUpgrade(data types.IoBuffer) (*H2cUpgrade, error) {
+	b := data.Bytes()
+
+	n := len(b)
+	if n > len(clientPreface) {
+		n = len(clientPreface)
+	}
+	if bytes.Equal(b[:n], clientPreface[:n]) {
+		if n < len(clientPreface) {
+			return nil, ErrAGAIN
+		}
+		return nil, nil
+	}
+
+	end := bytes.Index(b, headerEnd)
+	if end < 0 {
+		if len(b) > http.DefaultMaxHeaderBytes {
+			return nil, errUpgradeTooLong
+		}
+		return nil, ErrAGAIN
+	}
+	end += len(headerEnd)
+
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(b[:end])))
+	if err != nil {
+		return nil, err
+	}
+	settings, err := h2cSettings(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.ContentLength < 0 {
+		// a chunked body can not be delimited before the protocol switch
+		return nil, errNotH2cUpgrade
+	}
+	if int64(len(b)-end) < req.ContentLength {
+		return nil, ErrAGAIN
+	}
+
+	var body []byte
+	if req.ContentLength > 0 {
+		body = make([]byte, req.ContentLength)
+		copy(body, b[end:])
+	}
+	data.Drain(end + int(req.ContentLength))
+
+	for _, s := range settings {
+		if err := sc.processSetting(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sc.Connection.Write(buffer.NewIoBufferBytes(switchProtocols)); err != nil {
+		return nil, err
+	}
+
+	for _, k := range []string{"Connection", "Upgrade", "Http2-Settings", "Keep-Alive", "Proxy-Connection"} {
+		req.Header.Del(k)
+	}
+	req.Proto = "HTTP/2.0"
+	req.ProtoMajor = 2
+	req.ProtoMinor = 0
+	req.Body = nil
+
+	sc.maxClientStreamID = 1
+	st := sc.newStream(1, 0, stateHalfClosedRemote)
+	st.declBodyBytes = req.ContentLength
+
+	return &H2cUpgrade{
+		Stream: &MStream{
+			stream:  st,
+			conn:    sc,
+			Request: req,
+		},
+		Data: body,
+	}, nil
+}
+
+// h2cSettings validates the upgrade headers and decodes the SETTINGS payload
+// carried by the HTTP2-Settings header.
+func h2cSettings(req *http.Request) ([]Setting, error) {
+	if req.ProtoMajor != 1 || req.ProtoMinor != 1 {
+		return nil, errNotH2cUpgrade
+	}
+	if !httplex.HeaderValuesContainsToken(req.Header["Upgrade"], "h2c") ||
+		!httplex.HeaderValuesContainsToken(req.Header["Connection"], "Upgrade") ||
+		!httplex.HeaderValuesContainsToken(req.Header["Connection"], "HTTP2-Settings") {
+		return nil, errNotH2cUpgrade
+	}
+
+	values := req.Header["Http2-Settings"]
+	if len(values) != 1 {
+		return nil, errNotH2cUpgrade
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(values[0], "="))
+	if err != nil {
+		return nil, fmt.Errorf("http2: invalid HTTP2-Settings header: %v", err)
+	}
+	if len(payload)%6 != 0 {
+		return nil, fmt.Errorf("http2: invalid HTTP2-Settings length %d", len(payload))
+	}
+
+	settings := make([]Setting, 0, len(payload)/6)
+	for i := 0; i < len(payload); i += 6 {
+		settings = append(settings, Setting{
+			ID:  SettingID(binary.BigEndian.Uint16(payload[i:])),
+			Val: binary.BigEndian.Uint32(payload[i+2:]),
+		})
+	}
+	return settings, nil
+}
@@ -33,6 +33,7 @@ var (
 type MStream struct {
 	*stream
 	sentContentLen int64
+	returnedBytes  int64
 	conn           *MServerConn
 	Request        *http.Request
 	Response       *http.Response
@@ -48,10 +49,7 @@ func (ms *MStream) ID() uint32 {
 
 // SendResponse is Http2 Server send response
 func (ms *MStream) SendResponse() error {
-	ms.conn.closeStream(ms.stream, nil)
-	if ms.bodyBytes > 0 {
-		ms.conn.sendWindowUpdate(nil, int(ms.bodyBytes))
-	}
+	ms.finish()
 
 	isHeadResp := ms.Request.Method == "HEAD"
 	var ctype, clen string
@@ -0,0 +1,132 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http2
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ReturnFlow refunds the flow control of n bytes of the request body consumed
+// by the streaming mode, so the peer keeps sending the body as it is forwarded.
+func (ms *MStream) ReturnFlow(n int) {
+	if n <= 0 {
+		return
+	}
+	ms.returnedBytes += int64(n)
+	ms.conn.sendWindowUpdate(nil, n)
+	if ms.state == stateOpen {
+		ms.conn.sendWindowUpdate(ms.stream, n)
+	}
+}
+
+// WriteHeaders sends the response headers in the streaming mode,
+// the body follows with WriteData and WriteTrailers.
+func (ms *MStream) WriteHeaders(endStream bool) error {
+	rsp := ms.Response
+
+	var date string
+	if rsp.Header.Get("Date") == "" {
+		date = time.Now().UTC().Format(http.TimeFormat)
+	}
+
+	if endStream {
+		ms.finish()
+	}
+	return ms.conn.writeHeaders(&writeResHeaders{
+		streamID:    ms.id,
+		httpResCode: rsp.StatusCode,
+		h:           rsp.Header,
+		endStream:   endStream,
+		date:        date,
+	})
+}
+
+// WriteData sends a piece of the response body in the streaming mode
+func (ms *MStream) WriteData(data []byte, endStream bool) error {
+	if len(data) == 0 {
+		data = nil
+	}
+	if endStream {
+		ms.finish()
+	}
+	return ms.conn.Framer.writeData(ms.id, endStream, data)
+}
+
+// WriteTrailers sends the response trailers in the streaming mode, which ends the stream
+func (ms *MStream) WriteTrailers() error {
+	ms.finish()
+
+	trailers := ms.Response.Trailer
+	var keys []string
+	for k := range trailers {
+		k = http.CanonicalHeaderKey(k)
+		if ValidTrailerHeader(k) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ms.conn.Framer.writeData(ms.id, true, nil)
+	}
+	return ms.conn.writeHeaders(&writeResHeaders{
+		streamID:  ms.id,
+		h:         trailers,
+		trailers:  keys,
+		endStream: true,
+	})
+}
+
+// finish closes the stream and refunds the connection flow control of the body not returned yet
+func (ms *MStream) finish() {
+	ms.conn.closeStream(ms.stream, nil)
+	if n := ms.bodyBytes - ms.returnedBytes; n > 0 {
+		ms.conn.sendWindowUpdate(nil, int(n))
+	}
+}
+
+// WriteHeaders sends the request headers in the streaming mode,
+// the body follows with WriteData and WriteTrailers.
+func (cc *MClientStream) WriteHeaders(ctx context.Context, endStream bool) error {
+	// the body length is unknown unless the content length is given
+	cc.Request.ContentLength = -1
+	if clen, err := strconv.ParseInt(cc.Request.Header.Get("Content-Length"), 10, 64); err == nil && clen >= 0 {
+		cc.Request.ContentLength = clen
+	}
+
+	cc.conn.mu.Lock()
+	defer cc.conn.mu.Unlock()
+
+	cs, err := cc.conn.WriteHeaders(ctx, cc.Request, "", endStream)
+	if err != nil {
+		return err
+	}
+	cc.clientStream = cs
+	return nil
+}
+
+// WriteData sends a piece of the request body in the streaming mode
+func (cc *MClientStream) WriteData(data []byte, endStream bool) error {
+	if len(data) == 0 {
+		data = nil
+	}
+	return cc.conn.Framer.writeData(cc.ID, endStream, data)
+}
+
+// WriteTrailers sends the request trailers in the streaming mode, which ends the stream
+func (cc *MClientStream) WriteTrailers() error {
+	cc.conn.mu.Lock()
+	defer cc.conn.mu.Unlock()
+
+	trls, err := cc.conn.encodeTrailers(cc.Request)
+	if err != nil {
+		return err
+	}
+	if len(trls) == 0 {
+		return cc.conn.Framer.writeData(cc.ID, true, nil)
+	}
+	return cc.conn.writeHeaders(cc.ID, true, int(cc.conn.maxFrameSize), trls)
+}
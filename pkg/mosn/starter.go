@@ -110,6 +110,14 @@ func NewMosn(c *config.MOSNConfig) *Mosn {
 	//cluster manager filter
 	cmf := &clusterManagerFilter{}
 
+	// the local locality is used by the locality aware load balancer,
+	// the region is compared only if it is configured
+	appInfo := c.ServiceRegistry.ServiceAppInfo
+	cluster.SetLocalLocality(v2.Locality{
+		Region: appInfo.Region,
+		Zone:   appInfo.Zone,
+	})
+
 	// parse cluster all in one
 	clusters, clusterMap := config.ParseClusterConfig(c.ClusterManager.Clusters)
 	// create cluster manager
@@ -151,8 +159,10 @@ func NewMosn(c *config.MOSNConfig) *Mosn {
 				lc.DisableConnIo = config.GetListenerDisableIO(&lc.FilterChains[0])
 
 				// parse routers from connection_manager filter and add it the routerManager
-				if routerConfig := config.ParseRouterConfiguration(&lc.FilterChains[0]); routerConfig.RouterConfigName != "" {
-					m.routerManager.AddOrUpdateRouters(routerConfig)
+				for fcIdx := range lc.FilterChains {
+					if routerConfig := config.ParseRouterConfiguration(&lc.FilterChains[fcIdx]); routerConfig.RouterConfigName != "" {
+						m.routerManager.AddOrUpdateRouters(routerConfig)
+					}
 				}
 
 				var nfcf []types.NetworkFilterChainFactory
@@ -301,6 +311,7 @@ func initializeMetrics(config config.MetricsConfig) {
 	// set metrics package
 	statsMatcher := config.StatsMatcher
 	metrics.SetStatsMatcher(statsMatcher.RejectAll, statsMatcher.ExclusionLabels, statsMatcher.ExclusionKeys)
+	metrics.SetHistogramBuckets(config.HistogramBuckets)
 	// create sinks
 	for _, cfg := range config.SinkConfigs {
 		_, err := sink.CreateMetricsSink(cfg.Type, cfg.Config)
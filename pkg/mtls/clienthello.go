@@ -0,0 +1,193 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+)
+
+const (
+	recordTypeHandshake       = 0x16
+	handshakeTypeClientHello  = 0x01
+	extensionServerName       = 0x0000
+	extensionALPN             = 0x0010
+	recordHeaderLen           = 5
+	maxClientHelloRecordBytes = 16384 + 2048
+)
+
+// ClientHello contains the TLS ClientHello informations
+// that can be used before the handshake, such as choose a filter chain
+type ClientHello struct {
+	ServerName string
+	ALPN       []string
+}
+
+// PeekClientHello reads the first TLS record from the connection without draining it,
+// the returned Conn will replay the data read.
+// If the connection does not start with a TLS handshake, the returned ClientHello is nil.
+func PeekClientHello(c net.Conn, timeout time.Duration) (*Conn, *ClientHello) {
+	conn, ok := c.(*Conn)
+	if !ok {
+		conn = &Conn{
+			Conn: c,
+		}
+	}
+	if timeout > 0 {
+		conn.Conn.SetReadDeadline(time.Now().Add(timeout))
+		defer conn.Conn.SetReadDeadline(time.Time{})
+	}
+	if !conn.peekFull(1) || conn.peek[0] != recordTypeHandshake {
+		return conn, nil
+	}
+	if !conn.peekFull(recordHeaderLen) {
+		return conn, nil
+	}
+	length := int(binary.BigEndian.Uint16(conn.peek[3:5]))
+	if length > maxClientHelloRecordBytes {
+		return conn, nil
+	}
+	if !conn.peekFull(recordHeaderLen + length) {
+		return conn, nil
+	}
+	hello := parseClientHello(conn.peek[recordHeaderLen : recordHeaderLen+length])
+	return conn, hello
+}
+
+// peekFull makes sure the peeked data is at least n bytes
+func (c *Conn) peekFull(n int) bool {
+	if len(c.peek) >= n {
+		return true
+	}
+	b := make([]byte, n-len(c.peek))
+	read, err := io.ReadFull(c.Conn, b)
+	c.peek = append(c.peek, b[:read]...)
+	if err != nil {
+		if log.DefaultLogger.GetLogLevel() >= log.INFO {
+			log.DefaultLogger.Infof("[mtls] peek client hello error: %v", err)
+		}
+		return false
+	}
+	return true
+}
+
+// parseClientHello parses the handshake message in a TLS record.
+// It returns nil if the message is not a ClientHello.
+func parseClientHello(data []byte) *ClientHello {
+	// handshake type(1) + length(3)
+	if len(data) < 4 || data[0] != handshakeTypeClientHello {
+		return nil
+	}
+	hello := &ClientHello{}
+	msg := data[4:]
+	if length := int(data[1])<<16 | int(data[2])<<8 | int(data[3]); length < len(msg) {
+		msg = msg[:length]
+	}
+	// client version(2) + random(32)
+	if len(msg) < 34 {
+		return hello
+	}
+	msg = msg[34:]
+	// session id
+	var ok bool
+	if _, msg, ok = readVector(msg, 1); !ok {
+		return hello
+	}
+	// cipher suites
+	if _, msg, ok = readVector(msg, 2); !ok {
+		return hello
+	}
+	// compression methods
+	if _, msg, ok = readVector(msg, 1); !ok {
+		return hello
+	}
+	extensions, _, ok := readVector(msg, 2)
+	if !ok {
+		return hello
+	}
+	for len(extensions) >= 4 {
+		typ := binary.BigEndian.Uint16(extensions)
+		var ext []byte
+		if ext, extensions, ok = readVector(extensions[2:], 2); !ok {
+			return hello
+		}
+		switch typ {
+		case extensionServerName:
+			hello.ServerName = parseServerName(ext)
+		case extensionALPN:
+			hello.ALPN = parseALPN(ext)
+		}
+	}
+	return hello
+}
+
+func parseServerName(ext []byte) string {
+	names, _, ok := readVector(ext, 2)
+	if !ok {
+		return ""
+	}
+	for len(names) > 0 {
+		nameType := names[0]
+		var name []byte
+		if name, names, ok = readVector(names[1:], 2); !ok {
+			return ""
+		}
+		// host_name
+		if nameType == 0 {
+			return string(name)
+		}
+	}
+	return ""
+}
+
+func parseALPN(ext []byte) []string {
+	list, _, ok := readVector(ext, 2)
+	if !ok {
+		return nil
+	}
+	var protos []string
+	for len(list) > 0 {
+		var proto []byte
+		if proto, list, ok = readVector(list, 1); !ok {
+			return protos
+		}
+		protos = append(protos, string(proto))
+	}
+	return protos
+}
+
+// readVector reads a variable-length vector whose length is encoded in lenBytes bytes,
+// it returns the vector and the remaining data.
+func readVector(data []byte, lenBytes int) ([]byte, []byte, bool) {
+	if len(data) < lenBytes {
+		return nil, nil, false
+	}
+	length := 0
+	for i := 0; i < lenBytes; i++ {
+		length = length<<8 | int(data[i])
+	}
+	data = data[lenBytes:]
+	if len(data) < length {
+		return nil, nil, false
+	}
+	return data[:length], data[length:], true
+}
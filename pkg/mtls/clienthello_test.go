@@ -0,0 +1,89 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"bytes"
+	gotls "crypto/tls"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPeekClientHello(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		tlsConn := gotls.Client(client, &gotls.Config{
+			ServerName:         "www.example.com",
+			NextProtos:         []string{"h2", "http/1.1"},
+			InsecureSkipVerify: true,
+		})
+		tlsConn.Handshake()
+		client.Close()
+	}()
+	conn, hello := PeekClientHello(server, time.Second)
+	if hello == nil {
+		t.Fatal("expected a client hello")
+	}
+	if hello.ServerName != "www.example.com" {
+		t.Errorf("server name is not expected: %s", hello.ServerName)
+	}
+	if !reflect.DeepEqual(hello.ALPN, []string{"h2", "http/1.1"}) {
+		t.Errorf("alpn is not expected: %v", hello.ALPN)
+	}
+	// the peeked data should be read again
+	peeked := make([]byte, len(conn.peek))
+	copy(peeked, conn.peek)
+	b := make([]byte, len(peeked))
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatalf("read peeked data failed: %v", err)
+	}
+	if !bytes.Equal(b, peeked) || b[0] != recordTypeHandshake {
+		t.Error("peeked data is not replayed")
+	}
+}
+
+func TestPeekClientHelloNonTLS(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		client.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+		client.Close()
+	}()
+	conn, hello := PeekClientHello(server, time.Second)
+	if hello != nil {
+		t.Fatal("non tls connection should not have a client hello")
+	}
+	b := make([]byte, 3)
+	if _, err := io.ReadFull(conn, b); err != nil || string(b) != "GET" {
+		t.Fatalf("read data is not expected: %s, %v", b, err)
+	}
+}
+
+func TestPeekClientHelloTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go client.Write([]byte{recordTypeHandshake, 0x03})
+	if _, hello := PeekClientHello(server, 100*time.Millisecond); hello != nil {
+		t.Fatal("uncompleted client hello should not be parsed")
+	}
+}
@@ -42,12 +42,16 @@ type TLSConn struct {
 // It implements the net.Conn interface.
 type Conn struct {
 	net.Conn
-	peek    [1]byte
-	haspeek bool
+	// peek stores the data read from connection by Peek or PeekClientHello,
+	// it will be returned by Read first.
+	peek []byte
 }
 
 // Peek returns 1 byte from connection, without draining any buffered data.
 func (c *Conn) Peek() []byte {
+	if len(c.peek) > 0 {
+		return c.peek[:1]
+	}
 	b := make([]byte, 1, 1)
 	n, err := c.Conn.Read(b)
 	if n == 0 {
@@ -56,26 +60,21 @@ func (c *Conn) Peek() []byte {
 		}
 		return nil
 	}
-	c.peek[0] = b[0]
-	c.haspeek = true
+	c.peek = append(c.peek, b[0])
 	return b
 }
 
 // Read reads data from the connection.
 func (c *Conn) Read(b []byte) (int, error) {
-	peek := 0
-	if c.haspeek {
-		c.haspeek = false
-		b[0] = c.peek[0]
-		if len(b) == 1 {
-			return 1, nil
+	if len(c.peek) > 0 {
+		n := copy(b, c.peek)
+		c.peek = c.peek[n:]
+		if len(c.peek) == 0 {
+			c.peek = nil
 		}
-		peek = 1
-		b = b[peek:]
+		return n, nil
 	}
-
-	n, err := c.Conn.Read(b)
-	return n + peek, err
+	return c.Conn.Read(b)
 }
 
 // ConnectionState records basic TLS details about the connection.
@@ -90,7 +90,10 @@ func (mng *serverContextManager) GetConfigForClient(info *tls.ClientHelloInfo) (
 }
 
 func (mng *serverContextManager) Conn(c net.Conn) net.Conn {
-	if _, ok := c.(*net.TCPConn); !ok {
+	// the connection may be peeked already, see PeekClientHello
+	switch c.(type) {
+	case *net.TCPConn, *Conn:
+	default:
 		return c
 	}
 	if !mng.Enabled() {
@@ -102,8 +105,11 @@ func (mng *serverContextManager) Conn(c net.Conn) net.Conn {
 		}
 	}
 	// inspector
-	conn := &Conn{
-		Conn: c,
+	conn, ok := c.(*Conn)
+	if !ok {
+		conn = &Conn{
+			Conn: c,
+		}
 	}
 	buf := conn.Peek()
 	if buf == nil {
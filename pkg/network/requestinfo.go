@@ -41,6 +41,9 @@ type RequestInfo struct {
 	downstreamRemoteAddress  net.Addr
 	isHealthCheckRequest     bool
 	routerRule               types.RouteRule
+	upstreamConnectDuration  time.Duration
+	retryCount               uint32
+	traceId                  string
 }
 
 // todo check
@@ -178,3 +181,27 @@ func (r *RequestInfo) RouteEntry() types.RouteRule {
 func (r *RequestInfo) SetRouteEntry(routerRule types.RouteRule) {
 	r.routerRule = routerRule
 }
+
+func (r *RequestInfo) UpstreamConnectDuration() time.Duration {
+	return r.upstreamConnectDuration
+}
+
+func (r *RequestInfo) SetUpstreamConnectDuration(duration time.Duration) {
+	r.upstreamConnectDuration = duration
+}
+
+func (r *RequestInfo) RetryCount() uint32 {
+	return r.retryCount
+}
+
+func (r *RequestInfo) SetRetryCount(count uint32) {
+	r.retryCount = count
+}
+
+func (r *RequestInfo) TraceId() string {
+	return r.traceId
+}
+
+func (r *RequestInfo) SetTraceId(traceId string) {
+	r.traceId = traceId
+}
@@ -66,7 +66,8 @@ type ProtocolConv interface {
 	ConvTrailer(ctx context.Context, headerMap types.HeaderMap) (types.HeaderMap, error)
 }
 
-// RegisterConv register concrete protocol convert function for specified source protocol and destination protocol
+// RegisterConv register concrete protocol convert function for specified source protocol and destination protocol.
+// The function can return ErrNotFound to leave the conversion to the common path.
 func RegisterConv(src, dst types.Protocol, f ProtocolConv) {
 	if _, subOk := protoConvFactory[src]; !subOk {
 		protoConvFactory[src] = make(map[types.Protocol]ProtocolConv)
@@ -87,7 +88,9 @@ func ConvertHeader(ctx context.Context, src, dst types.Protocol, srcHeader types
 	// 1. try direct path
 	if sub, subOk := protoConvFactory[src]; subOk {
 		if f, ok := sub[dst]; ok {
-			return f.ConvHeader(ctx, srcHeader)
+			if header, err := f.ConvHeader(ctx, srcHeader); err != ErrNotFound {
+				return header, err
+			}
 		}
 	}
 
@@ -111,7 +114,9 @@ func ConvertData(ctx context.Context, src, dst types.Protocol, srcData types.IoB
 	// 1. try direct path
 	if sub, subOk := protoConvFactory[src]; subOk {
 		if f, ok := sub[dst]; ok {
-			return f.ConvData(ctx, srcData)
+			if data, err := f.ConvData(ctx, srcData); err != ErrNotFound {
+				return data, err
+			}
 		}
 	}
 
@@ -132,7 +137,9 @@ func ConvertTrailer(ctx context.Context, src, dst types.Protocol, srcTrailer typ
 	// 1. try direct path
 	if sub, subOk := protoConvFactory[src]; subOk {
 		if f, ok := sub[dst]; ok {
-			return f.ConvTrailer(ctx, srcTrailer)
+			if trailer, err := f.ConvTrailer(ctx, srcTrailer); err != ErrNotFound {
+				return trailer, err
+			}
 		}
 	}
 
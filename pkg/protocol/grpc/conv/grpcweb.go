@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conv
+
+import (
+	"context"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/grpc"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/protocol/http2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// the grpc-web requests sent by http1 are converted to the grpc requests for the http2 upstream,
+// other http1 and http2 messages are left to the common path.
+func init() {
+	protocol.RegisterConv(protocol.HTTP1, protocol.HTTP2, &web2grpc{})
+	protocol.RegisterConv(protocol.HTTP2, protocol.HTTP1, &grpc2web{})
+}
+
+// webContentType returns the grpc-web content type of the stream, which is recorded when the request is converted
+func webContentType(ctx context.Context) string {
+	if ct, ok := mosnctx.Get(ctx, types.ContextKeyGrpcWebContentType).(string); ok {
+		return ct
+	}
+	return ""
+}
+
+// convertContentType replaces the content type with the target one, keeps the sub type such as +proto
+func convertContentType(contentType, from, to string) string {
+	return to + strings.TrimPrefix(contentType, from)
+}
+
+// grpc-web -> grpc converter
+type web2grpc struct{}
+
+func (c *web2grpc) ConvHeader(ctx context.Context, headerMap types.HeaderMap) (types.HeaderMap, error) {
+	header, ok := headerMap.(http.RequestHeader)
+	if !ok {
+		return nil, protocol.ErrNotFound
+	}
+	contentType, _ := header.Get(grpc.HeaderContentType)
+	if !grpc.IsWeb(contentType) {
+		return nil, protocol.ErrNotFound
+	}
+
+	// the stream context is a mosn value context, the content type is kept for converting the data and response
+	mosnctx.WithValue(ctx, types.ContextKeyGrpcWebContentType, contentType)
+
+	cheader := make(map[string]string, header.Len())
+	header.Range(func(key, value string) bool {
+		cheader[strings.ToLower(key)] = value
+		return true
+	})
+
+	if grpc.IsWebText(contentType) {
+		cheader[grpc.HeaderContentType] = convertContentType(contentType, grpc.ContentTypeWebText, grpc.ContentType)
+	} else {
+		cheader[grpc.HeaderContentType] = convertContentType(contentType, grpc.ContentTypeWeb, grpc.ContentType)
+	}
+	// the length changes for the grpc-web-text body
+	delete(cheader, "content-length")
+	cheader[grpc.HeaderTE] = "trailers"
+
+	return protocol.CommonHeader(cheader), nil
+}
+
+func (c *web2grpc) ConvData(ctx context.Context, buf types.IoBuffer) (types.IoBuffer, error) {
+	contentType := webContentType(ctx)
+	if contentType == "" {
+		return nil, protocol.ErrNotFound
+	}
+	if !grpc.IsWebText(contentType) || buf == nil {
+		return buf, nil
+	}
+	data, err := grpc.DecodeWebText(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return buffer.NewIoBufferBytes(data), nil
+}
+
+func (c *web2grpc) ConvTrailer(ctx context.Context, headerMap types.HeaderMap) (types.HeaderMap, error) {
+	return nil, protocol.ErrNotFound
+}
+
+// grpc -> grpc-web converter
+type grpc2web struct{}
+
+func (c *grpc2web) ConvHeader(ctx context.Context, headerMap types.HeaderMap) (types.HeaderMap, error) {
+	contentType := webContentType(ctx)
+	if _, ok := headerMap.(*http2.RspHeader); !ok || contentType == "" {
+		return nil, protocol.ErrNotFound
+	}
+
+	header := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	http2.DecodeHeader(headerMap).Range(func(key, value string) bool {
+		header.Set(key, value)
+		return true
+	})
+
+	// reply the grpc-web type the client sent, with the sub type of the upstream
+	webType := grpc.ContentTypeWeb
+	if grpc.IsWebText(contentType) {
+		webType = grpc.ContentTypeWebText
+	}
+	if upstreamType, _ := header.Get(grpc.HeaderContentType); grpc.IsGRPC(upstreamType) {
+		header.Set(grpc.HeaderContentType, convertContentType(upstreamType, grpc.ContentType, webType))
+	} else {
+		header.Set(grpc.HeaderContentType, webType)
+	}
+	header.Del("content-length")
+	header.Del("trailer")
+
+	return header, nil
+}
+
+func (c *grpc2web) ConvData(ctx context.Context, buf types.IoBuffer) (types.IoBuffer, error) {
+	contentType := webContentType(ctx)
+	if contentType == "" {
+		return nil, protocol.ErrNotFound
+	}
+	if !grpc.IsWebText(contentType) || buf == nil {
+		return buf, nil
+	}
+	return buffer.NewIoBufferBytes(grpc.EncodeWebText(buf.Bytes())), nil
+}
+
+// ConvTrailer keeps the trailers, http1 stream encodes them into the body of the grpc-web response
+func (c *grpc2web) ConvTrailer(ctx context.Context, headerMap types.HeaderMap) (types.HeaderMap, error) {
+	if webContentType(ctx) == "" {
+		return nil, protocol.ErrNotFound
+	}
+	return headerMap, nil
+}
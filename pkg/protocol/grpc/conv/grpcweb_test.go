@@ -0,0 +1,150 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conv
+
+import (
+	"context"
+	"encoding/base64"
+	nethttp "net/http"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	_ "sofastack.io/sofa-mosn/pkg/protocol/http/conv"
+	"sofastack.io/sofa-mosn/pkg/protocol/http2"
+	_ "sofastack.io/sofa-mosn/pkg/protocol/http2/conv"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func newStreamContext() context.Context {
+	return mosnctx.WithValue(context.Background(), types.ContextKeyStreamID, uint64(1))
+}
+
+func newRequestHeader(contentType string) http.RequestHeader {
+	header := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	header.Set("Content-Type", contentType)
+	header.Set("X-Custom", "value")
+	header.SetContentLength(10)
+	return header
+}
+
+func TestConvertNotGrpcWeb(t *testing.T) {
+	ctx := newStreamContext()
+	header, err := protocol.ConvertHeader(ctx, protocol.HTTP1, protocol.HTTP2, newRequestHeader("application/json"))
+	if err != nil {
+		t.Fatalf("convert header failed: %v", err)
+	}
+	if ct, _ := header.Get("content-type"); ct != "application/json" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	if _, ok := header.Get("te"); ok {
+		t.Error("te should not be added")
+	}
+	data := buffer.NewIoBufferString("body")
+	if conv, err := protocol.ConvertData(ctx, protocol.HTTP1, protocol.HTTP2, data); err != nil || conv.String() != "body" {
+		t.Errorf("unexpected converted data: %v %v", conv, err)
+	}
+
+	rsp := http2.NewRspHeader(&nethttp.Response{StatusCode: 200, Header: nethttp.Header{"Content-Type": []string{"application/grpc"}}})
+	rspHeader, err := protocol.ConvertHeader(ctx, protocol.HTTP2, protocol.HTTP1, rsp)
+	if err != nil {
+		t.Fatalf("convert response header failed: %v", err)
+	}
+	if ct, _ := rspHeader.Get("content-type"); ct != "application/grpc" {
+		t.Errorf("unexpected response content type: %s", ct)
+	}
+}
+
+func TestConvertGrpcWeb(t *testing.T) {
+	ctx := newStreamContext()
+	header, err := protocol.ConvertHeader(ctx, protocol.HTTP1, protocol.HTTP2, newRequestHeader("application/grpc-web+proto"))
+	if err != nil {
+		t.Fatalf("convert header failed: %v", err)
+	}
+	if _, ok := header.(protocol.CommonHeader); !ok {
+		t.Fatalf("unexpected header type: %T", header)
+	}
+	if ct, _ := header.Get("content-type"); ct != "application/grpc+proto" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	if te, _ := header.Get("te"); te != "trailers" {
+		t.Errorf("unexpected te: %s", te)
+	}
+	if v, _ := header.Get("x-custom"); v != "value" {
+		t.Errorf("unexpected custom header: %s", v)
+	}
+	if _, ok := header.Get("content-length"); ok {
+		t.Error("content length should be removed")
+	}
+
+	// binary body is not changed
+	data := buffer.NewIoBufferBytes([]byte{0, 0, 0, 0, 1, 'a'})
+	if conv, err := protocol.ConvertData(ctx, protocol.HTTP1, protocol.HTTP2, data); err != nil || conv != data {
+		t.Errorf("unexpected converted data: %v %v", conv, err)
+	}
+
+	rsp := http2.NewRspHeader(&nethttp.Response{StatusCode: 200, Header: nethttp.Header{
+		"Content-Type":   []string{"application/grpc+proto"},
+		"Content-Length": []string{"6"},
+	}})
+	rspHeader, err := protocol.ConvertHeader(ctx, protocol.HTTP2, protocol.HTTP1, rsp)
+	if err != nil {
+		t.Fatalf("convert response header failed: %v", err)
+	}
+	if _, ok := rspHeader.(http.ResponseHeader); !ok {
+		t.Fatalf("unexpected response header type: %T", rspHeader)
+	}
+	if ct, _ := rspHeader.Get("content-type"); ct != "application/grpc-web+proto" {
+		t.Errorf("unexpected response content type: %s", ct)
+	}
+
+	trailers := http2.NewHeaderMap(nethttp.Header{"Grpc-Status": []string{"0"}})
+	if conv, err := protocol.ConvertTrailer(ctx, protocol.HTTP2, protocol.HTTP1, trailers); err != nil || conv != trailers {
+		t.Errorf("unexpected converted trailers: %v %v", conv, err)
+	}
+}
+
+func TestConvertGrpcWebText(t *testing.T) {
+	ctx := newStreamContext()
+	if _, err := protocol.ConvertHeader(ctx, protocol.HTTP1, protocol.HTTP2, newRequestHeader("application/grpc-web-text")); err != nil {
+		t.Fatalf("convert header failed: %v", err)
+	}
+
+	message := []byte{0, 0, 0, 0, 1, 'a'}
+	text := buffer.NewIoBufferString(base64.StdEncoding.EncodeToString(message))
+	if conv, err := protocol.ConvertData(ctx, protocol.HTTP1, protocol.HTTP2, text); err != nil || conv.String() != string(message) {
+		t.Errorf("unexpected decoded data: %v %v", conv, err)
+	}
+
+	rsp := http2.NewRspHeader(&nethttp.Response{StatusCode: 200, Header: nethttp.Header{"Content-Type": []string{"application/grpc"}}})
+	rspHeader, err := protocol.ConvertHeader(ctx, protocol.HTTP2, protocol.HTTP1, rsp)
+	if err != nil {
+		t.Fatalf("convert response header failed: %v", err)
+	}
+	if ct, _ := rspHeader.Get("content-type"); ct != "application/grpc-web-text" {
+		t.Errorf("unexpected response content type: %s", ct)
+	}
+
+	data := buffer.NewIoBufferBytes(message)
+	if conv, err := protocol.ConvertData(ctx, protocol.HTTP2, protocol.HTTP1, data); err != nil || conv.String() != base64.StdEncoding.EncodeToString(message) {
+		t.Errorf("unexpected encoded data: %v %v", conv, err)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"net/http"
+	"strconv"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// HTTPStatusCode maps the grpc status code to a http status code, so the retry, access log
+// and metrics can handle grpc responses the same way as the http ones.
+func HTTPStatusCode(code Code) int {
+	switch code {
+	case OK:
+		return http.StatusOK
+	case Canceled:
+		// client closed request, same as nginx and envoy
+		return 499
+	case InvalidArgument, FailedPrecondition, OutOfRange:
+		return http.StatusBadRequest
+	case DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case NotFound:
+		return http.StatusNotFound
+	case AlreadyExists, Aborted:
+		return http.StatusConflict
+	case PermissionDenied:
+		return http.StatusForbidden
+	case ResourceExhausted:
+		return http.StatusTooManyRequests
+	case Unimplemented:
+		return http.StatusNotImplemented
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	case Unauthenticated:
+		return http.StatusUnauthorized
+	default:
+		// Unknown, Internal, DataLoss and the undefined codes
+		return http.StatusInternalServerError
+	}
+}
+
+// CodeFromHTTPStatus maps the http status code of a reply generated by mosn to a grpc status code,
+// see https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+func CodeFromHTTPStatus(status int) Code {
+	switch status {
+	case http.StatusOK:
+		return OK
+	case http.StatusBadRequest:
+		return Internal
+	case http.StatusUnauthorized:
+		return Unauthenticated
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusNotFound:
+		return Unimplemented
+	case http.StatusGatewayTimeout:
+		// mosn replies 504 on the request timeout
+		return DeadlineExceeded
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return Unavailable
+	default:
+		return Unknown
+	}
+}
+
+// MappingStatusCode returns the http status code mapped from the grpc-status in the headers.
+// The headers can be the trailers of a grpc response, or the headers of a trailers-only response.
+// false is returned if the headers contain no valid grpc-status.
+func MappingStatusCode(headers types.HeaderMap) (int, bool) {
+	if headers == nil {
+		return 0, false
+	}
+	status, ok := headers.Get(HeaderStatus)
+	if !ok || status == "" {
+		return 0, false
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil || code < 0 {
+		return 0, false
+	}
+	return HTTPStatusCode(Code(code)), true
+}
+
+// TrailersOnly returns the headers of a trailers-only response for the http status code, grpc clients
+// read the status from the trailers, so the error replies should not be carried by the http status.
+func TrailersOnly(status int) map[string]string {
+	return map[string]string{
+		HeaderContentType: ContentType,
+		HeaderStatus:      strconv.Itoa(int(CodeFromHTTPStatus(status))),
+		HeaderMessage:     http.StatusText(status),
+	}
+}
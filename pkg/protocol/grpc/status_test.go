@@ -0,0 +1,142 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"net/http"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// headerMap is a simple types.HeaderMap, the protocol.CommonHeader can not be used for import cycle
+type headerMap map[string]string
+
+func (h headerMap) Get(key string) (string, bool) {
+	v, ok := h[key]
+	return v, ok
+}
+
+func (h headerMap) Set(key, value string) {
+	h[key] = value
+}
+
+func (h headerMap) Add(key, value string) {
+	h[key] = value
+}
+
+func (h headerMap) Del(key string) {
+	delete(h, key)
+}
+
+func (h headerMap) Range(f func(key, value string) bool) {
+	for k, v := range h {
+		if !f(k, v) {
+			break
+		}
+	}
+}
+
+func (h headerMap) Clone() types.HeaderMap {
+	c := make(headerMap, len(h))
+	for k, v := range h {
+		c[k] = v
+	}
+	return c
+}
+
+func (h headerMap) ByteSize() uint64 {
+	var size uint64
+	for k, v := range h {
+		size += uint64(len(k) + len(v))
+	}
+	return size
+}
+
+func TestContentType(t *testing.T) {
+	testcases := []struct {
+		contentType string
+		grpc        bool
+		web         bool
+		text        bool
+	}{
+		{"application/grpc", true, false, false},
+		{"application/grpc+proto", true, false, false},
+		{"application/grpc;charset=utf-8", true, false, false},
+		{"application/grpc-web", false, true, false},
+		{"application/grpc-web+proto", false, true, false},
+		{"application/grpc-web-text", false, true, true},
+		{"application/grpc-web-text+proto", false, true, true},
+		{"application/grpcx", false, false, false},
+		{"application/json", false, false, false},
+		{"", false, false, false},
+	}
+	for i, tc := range testcases {
+		if IsGRPC(tc.contentType) != tc.grpc || IsWeb(tc.contentType) != tc.web || IsWebText(tc.contentType) != tc.text {
+			t.Errorf("#%d %s unexpected content type check", i, tc.contentType)
+		}
+	}
+}
+
+func TestMappingStatusCode(t *testing.T) {
+	testcases := []struct {
+		headers types.HeaderMap
+		code    int
+		ok      bool
+	}{
+		{nil, 0, false},
+		{headerMap{}, 0, false},
+		{headerMap{HeaderStatus: ""}, 0, false},
+		{headerMap{HeaderStatus: "abc"}, 0, false},
+		{headerMap{HeaderStatus: "0"}, http.StatusOK, true},
+		{headerMap{HeaderStatus: "1"}, 499, true},
+		{headerMap{HeaderStatus: "4"}, http.StatusGatewayTimeout, true},
+		{headerMap{HeaderStatus: "8"}, http.StatusTooManyRequests, true},
+		{headerMap{HeaderStatus: "12"}, http.StatusNotImplemented, true},
+		{headerMap{HeaderStatus: "13"}, http.StatusInternalServerError, true},
+		{headerMap{HeaderStatus: "14"}, http.StatusServiceUnavailable, true},
+		{headerMap{HeaderStatus: "16"}, http.StatusUnauthorized, true},
+		{headerMap{HeaderStatus: "100"}, http.StatusInternalServerError, true},
+	}
+	for i, tc := range testcases {
+		code, ok := MappingStatusCode(tc.headers)
+		if code != tc.code || ok != tc.ok {
+			t.Errorf("#%d expected %d %v, but got %d %v", i, tc.code, tc.ok, code, ok)
+		}
+	}
+}
+
+func TestTrailersOnly(t *testing.T) {
+	testcases := []struct {
+		status int
+		code   string
+	}{
+		{http.StatusNotFound, "12"},
+		{http.StatusBadGateway, "14"},
+		{http.StatusServiceUnavailable, "14"},
+		{http.StatusGatewayTimeout, "4"},
+		{http.StatusForbidden, "7"},
+		{http.StatusInternalServerError, "2"},
+	}
+	for i, tc := range testcases {
+		h := TrailersOnly(tc.status)
+		if h[HeaderContentType] != ContentType || h[HeaderStatus] != tc.code || h[HeaderMessage] != http.StatusText(tc.status) {
+			t.Errorf("#%d unexpected trailers-only headers: %v", i, h)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseTimeout(t *testing.T) {
+	testcases := []struct {
+		value   string
+		timeout time.Duration
+		err     error
+	}{
+		{"1H", time.Hour, nil},
+		{"2M", 2 * time.Minute, nil},
+		{"3S", 3 * time.Second, nil},
+		{"100m", 100 * time.Millisecond, nil},
+		{"200u", 200 * time.Microsecond, nil},
+		{"300n", 300 * time.Nanosecond, nil},
+		{"99999999H", time.Duration(math.MaxInt64), nil},
+		{"", 0, ErrInvalidTimeout},
+		{"m", 0, ErrInvalidTimeout},
+		{"100", 0, ErrInvalidTimeout},
+		{"100x", 0, ErrInvalidTimeout},
+		{"-1S", 0, ErrInvalidTimeout},
+		{"123456789S", 0, ErrInvalidTimeout},
+	}
+	for i, tc := range testcases {
+		timeout, err := ParseTimeout(tc.value)
+		if timeout != tc.timeout || err != tc.err {
+			t.Errorf("#%d %s expected %v %v, but got %v %v", i, tc.value, tc.timeout, tc.err, timeout, err)
+		}
+	}
+}
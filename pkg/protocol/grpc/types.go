@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"strings"
+)
+
+// Code is the grpc status code carried by the grpc-status header
+type Code int
+
+// grpc status codes, see https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+const (
+	OK Code = iota
+	Canceled
+	Unknown
+	InvalidArgument
+	DeadlineExceeded
+	NotFound
+	AlreadyExists
+	PermissionDenied
+	ResourceExhausted
+	FailedPrecondition
+	Aborted
+	OutOfRange
+	Unimplemented
+	Internal
+	Unavailable
+	DataLoss
+	Unauthenticated
+)
+
+// Header keys
+const (
+	HeaderContentType = "content-type"
+	HeaderStatus      = "grpc-status"
+	HeaderMessage     = "grpc-message"
+	HeaderTimeout     = "grpc-timeout"
+	HeaderTE          = "te"
+)
+
+// Content types
+const (
+	ContentType        = "application/grpc"
+	ContentTypeWeb     = "application/grpc-web"
+	ContentTypeWebText = "application/grpc-web-text"
+)
+
+// IsGRPC returns true if the content type is a grpc one, grpc-web is not included
+func IsGRPC(contentType string) bool {
+	return isContentType(contentType, ContentType)
+}
+
+// IsWeb returns true if the content type is a grpc-web one, both binary and text format
+func IsWeb(contentType string) bool {
+	return isContentType(contentType, ContentTypeWeb) || IsWebText(contentType)
+}
+
+// IsWebText returns true if the content type is the base64 encoded grpc-web one
+func IsWebText(contentType string) bool {
+	return isContentType(contentType, ContentTypeWebText)
+}
+
+// isContentType checks the content type is the given type, or its sub type such as application/grpc+proto
+func isContentType(contentType, t string) bool {
+	if !strings.HasPrefix(contentType, t) {
+		return false
+	}
+	if len(contentType) == len(t) {
+		return true
+	}
+	switch contentType[len(t)] {
+	case '+', ';':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// webTrailerFlag marks the grpc-web frame carries the trailers instead of a message
+const webTrailerFlag byte = 0x80
+
+// EncodeWebTrailers encodes the trailers as a grpc-web trailer frame, which is appended to the response body
+// because http/1.1 has no trailers. The frame is base64 encoded for the grpc-web-text content type.
+// see https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md
+func EncodeWebTrailers(contentType string, trailers types.HeaderMap) []byte {
+	var block []byte
+	if trailers != nil {
+		trailers.Range(func(key, value string) bool {
+			block = append(block, strings.ToLower(key)...)
+			block = append(block, ':', ' ')
+			block = append(block, value...)
+			block = append(block, '\r', '\n')
+			return true
+		})
+	}
+
+	frame := make([]byte, 5+len(block))
+	frame[0] = webTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(block)))
+	copy(frame[5:], block)
+
+	if IsWebText(contentType) {
+		return EncodeWebText(frame)
+	}
+	return frame
+}
+
+// EncodeWebText encodes the grpc-web frames with base64 for the grpc-web-text content type
+func EncodeWebText(data []byte) []byte {
+	text := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(text, data)
+	return text
+}
+
+// DecodeWebText decodes the grpc-web-text body, which can be the concatenation of padded base64 segments
+func DecodeWebText(text []byte) ([]byte, error) {
+	data := make([]byte, 0, base64.StdEncoding.DecodedLen(len(text)))
+	for len(text) > 0 {
+		end := len(text)
+		if i := bytes.IndexByte(text, '='); i >= 0 {
+			for end = i; end < len(text) && text[end] == '='; end++ {
+			}
+		}
+		segment := make([]byte, base64.StdEncoding.DecodedLen(end))
+		n, err := base64.StdEncoding.Decode(segment, text[:end])
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, segment[:n]...)
+		text = text[end:]
+	}
+	return data, nil
+}
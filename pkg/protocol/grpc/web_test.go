@@ -0,0 +1,52 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestEncodeWebTrailers(t *testing.T) {
+	trailers := headerMap{"Grpc-Status": "0"}
+	expected := append([]byte{0x80, 0, 0, 0, 16}, []byte("grpc-status: 0\r\n")...)
+
+	if frame := EncodeWebTrailers(ContentTypeWeb, trailers); !bytes.Equal(frame, expected) {
+		t.Errorf("unexpected trailer frame: %v", frame)
+	}
+
+	text := EncodeWebTrailers(ContentTypeWebText+"+proto", trailers)
+	if frame, err := base64.StdEncoding.DecodeString(string(text)); err != nil || !bytes.Equal(frame, expected) {
+		t.Errorf("unexpected text trailer frame: %s", text)
+	}
+
+	if frame := EncodeWebTrailers(ContentTypeWeb, nil); !bytes.Equal(frame, []byte{0x80, 0, 0, 0, 0}) {
+		t.Errorf("unexpected empty trailer frame: %v", frame)
+	}
+}
+
+func TestWebText(t *testing.T) {
+	text := append(EncodeWebText([]byte("a")), EncodeWebText([]byte("bcd"))...)
+	if data, err := DecodeWebText(text); err != nil || string(data) != "abcd" {
+		t.Errorf("unexpected decoded text: %s %v", data, err)
+	}
+	if _, err := DecodeWebText([]byte("!!!")); err == nil {
+		t.Error("expected decode error")
+	}
+}
@@ -45,3 +45,21 @@ func ParseQueryString(query string) types.QueryParams {
 
 	return QueryParams
 }
+
+// IsUpgradeRequest checks whether the request asks for a protocol upgrade, such as WebSocket.
+// An upgrade request contains an 'Upgrade' header and a 'Connection' header with the 'upgrade' option
+func IsUpgradeRequest(headers types.HeaderMap) bool {
+	if headers == nil {
+		return false
+	}
+	if upgrade, ok := headers.Get(HeaderUpgrade); !ok || upgrade == "" {
+		return false
+	}
+	connection, _ := headers.Get(HeaderConnection)
+	for _, option := range strings.Split(connection, ",") {
+		if strings.EqualFold(strings.TrimSpace(option), HeaderUpgrade) {
+			return true
+		}
+	}
+	return false
+}
@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/valyala/fasthttp"
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
@@ -83,3 +84,55 @@ func TestParseQueryString(t *testing.T) {
 		})
 	}
 }
+
+func TestIsUpgradeRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{
+			name: "websocket",
+			headers: map[string]string{
+				"Connection": "Upgrade",
+				"Upgrade":    "websocket",
+			},
+			want: true,
+		},
+		{
+			name: "multiple connection options",
+			headers: map[string]string{
+				"Connection": "keep-alive, upgrade",
+				"Upgrade":    "websocket",
+			},
+			want: true,
+		},
+		{
+			name: "no upgrade header",
+			headers: map[string]string{
+				"Connection": "Upgrade",
+			},
+			want: false,
+		},
+		{
+			name: "no upgrade option",
+			headers: map[string]string{
+				"Connection": "keep-alive",
+				"Upgrade":    "websocket",
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := RequestHeader{&fasthttp.RequestHeader{}, nil}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+			}
+			if got := IsUpgradeRequest(header); got != tt.want {
+				t.Errorf("IsUpgradeRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
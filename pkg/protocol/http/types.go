@@ -25,8 +25,9 @@ import (
 type Code uint32
 
 const (
-	Continue Code = 100
-	OK            = 200
+	Continue           Code = 100
+	SwitchingProtocols      = 101
+	OK                      = 200
 
 	Created                     = 201
 	Accepted                    = 202
@@ -87,6 +88,14 @@ const (
 	NetworkAuthenticationRequired = 511
 )
 
+// Header keys
+const (
+	HeaderLocation       = "location"
+	HeaderForwardedProto = "x-forwarded-proto"
+	HeaderConnection     = "connection"
+	HeaderUpgrade        = "upgrade"
+)
+
 type RequestHeader struct {
 	*fasthttp.RequestHeader
 
@@ -40,8 +40,17 @@ func (c *serverCodec) Encode(ctx context.Context, model interface{}) (types.IoBu
 
 func (c *serverCodec) Decode(ctx context.Context, data types.IoBuffer) (interface{}, error) {
 	if !c.init {
+		// a cleartext client may start with an HTTP/1.1 "Upgrade: h2c"
+		// request instead of the connection preface
+		upgrade, err := c.sc.ReadUpgrade(data)
+		if err != nil {
+			return nil, err
+		}
 		c.init = true
 		c.sc.Init()
+		if upgrade != nil {
+			return upgrade, nil
+		}
 	}
 	if !c.preface {
 		if err := c.sc.Framer.ReadPreface(data); err == nil {
@@ -0,0 +1,184 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package http2
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/module/http2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type mockConnection struct {
+	types.Connection
+	out bytes.Buffer
+}
+
+func (c *mockConnection) Write(bufs ...types.IoBuffer) error {
+	for _, b := range bufs {
+		c.out.Write(b.Bytes())
+	}
+	return nil
+}
+
+func h2cSettings(settings ...http2.Setting) string {
+	payload := make([]byte, 0, 6*len(settings))
+	for _, s := range settings {
+		var b [6]byte
+		binary.BigEndian.PutUint16(b[:], uint16(s.ID))
+		binary.BigEndian.PutUint32(b[2:], s.Val)
+		payload = append(payload, b[:]...)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func upgradeRequest(settings string, extra string) string {
+	return "POST /echo?name=mosn HTTP/1.1\r\n" +
+		"Host: mosn.io\r\n" +
+		"Connection: Upgrade, HTTP2-Settings\r\n" +
+		"Upgrade: h2c\r\n" +
+		"HTTP2-Settings: " + settings + "\r\n" +
+		"X-Service: echo\r\n" +
+		extra +
+		"\r\n"
+}
+
+// settingsFrame is an empty SETTINGS frame on stream 0
+var settingsFrame = []byte{0, 0, 0, byte(http2.FrameSettings), 0, 0, 0, 0, 0}
+
+func TestServerCodecH2cUpgrade(t *testing.T) {
+	conn := &mockConnection{}
+	sc := http2.NewServerConn(conn)
+	codec := &serverCodec{sc: sc}
+
+	req := upgradeRequest(h2cSettings(http2.Setting{ID: http2.SettingMaxFrameSize, Val: 1 << 15}), "Content-Length: 5\r\n")
+	data := buffer.NewIoBufferString(req)
+
+	// the body has not arrived yet
+	if _, err := codec.Decode(context.Background(), data); err != http2.ErrAGAIN {
+		t.Fatalf("expected ErrAGAIN for a partial body, got %v", err)
+	}
+	if conn.out.Len() != 0 {
+		t.Fatalf("nothing should be written before the upgrade request is complete")
+	}
+
+	data.Write([]byte("hello"))
+	data.Write([]byte(http2.ClientPreface))
+	data.Write(settingsFrame)
+
+	model, err := codec.Decode(context.Background(), data)
+	if err != nil {
+		t.Fatalf("decode upgrade request failed: %v", err)
+	}
+	upgrade, ok := model.(*http2.H2cUpgrade)
+	if !ok {
+		t.Fatalf("expected an h2c upgrade, got %T", model)
+	}
+	if upgrade.Stream.ID() != 1 {
+		t.Errorf("upgraded request should be stream 1, got %d", upgrade.Stream.ID())
+	}
+	r := upgrade.Stream.Request
+	if r.Method != "POST" || r.URL.Path != "/echo" || r.URL.RawQuery != "name=mosn" || r.Host != "mosn.io" {
+		t.Errorf("unexpected upgraded request: %s %s %s", r.Method, r.Host, r.URL)
+	}
+	if r.ProtoMajor != 2 {
+		t.Errorf("upgraded request should be http2, got %s", r.Proto)
+	}
+	if r.Header.Get("X-Service") != "echo" {
+		t.Errorf("request header lost: %v", r.Header)
+	}
+	for _, k := range []string{"Connection", "Upgrade", "Http2-Settings"} {
+		if _, ok := r.Header[k]; ok {
+			t.Errorf("connection header %s should be removed", k)
+		}
+	}
+	if string(upgrade.Data) != "hello" {
+		t.Errorf("unexpected upgrade body: %q", upgrade.Data)
+	}
+
+	// 101 must be sent before the server connection preface
+	out := conn.out.Bytes()
+	switching := "HTTP/1.1 101 Switching Protocols\r\n"
+	if !bytes.HasPrefix(out, []byte(switching)) {
+		t.Fatalf("expected 101 response first, got %q", out)
+	}
+	end := bytes.Index(out, []byte("\r\n\r\n")) + 4
+	if len(out) <= end || http2.FrameType(out[end+3]) != http2.FrameSettings {
+		t.Errorf("server SETTINGS should follow the 101 response")
+	}
+
+	// then the client preface and its frames
+	model, err = codec.Decode(context.Background(), data)
+	if err != nil {
+		t.Fatalf("decode frame after upgrade failed: %v", err)
+	}
+	if _, ok := model.(*http2.SettingsFrame); !ok {
+		t.Errorf("expected a settings frame after the preface, got %T", model)
+	}
+	if data.Len() != 0 {
+		t.Errorf("all data should be consumed, left %d", data.Len())
+	}
+}
+
+func TestServerCodecPriorKnowledge(t *testing.T) {
+	conn := &mockConnection{}
+	codec := &serverCodec{sc: http2.NewServerConn(conn)}
+
+	preface := []byte(http2.ClientPreface)
+	data := buffer.NewIoBufferBytes(preface[:10])
+	if _, err := codec.Decode(context.Background(), data); err != http2.ErrAGAIN {
+		t.Fatalf("expected ErrAGAIN for a partial preface, got %v", err)
+	}
+	data.Write(preface[10:])
+	data.Write(settingsFrame)
+
+	model, err := codec.Decode(context.Background(), data)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if _, ok := model.(*http2.SettingsFrame); !ok {
+		t.Errorf("expected a settings frame, got %T", model)
+	}
+	if bytes.Contains(conn.out.Bytes(), []byte("HTTP/1.1")) {
+		t.Errorf("no 101 response expected with prior knowledge")
+	}
+}
+
+func TestServerCodecH2cUpgradeInvalid(t *testing.T) {
+	for name, req := range map[string]string{
+		"plain http1":      "GET / HTTP/1.1\r\nHost: mosn.io\r\n\r\n",
+		"no settings":      "GET / HTTP/1.1\r\nHost: mosn.io\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\n\r\n",
+		"bad settings":     upgradeRequest("AAM", ""),
+		"chunked body":     upgradeRequest("", "Transfer-Encoding: chunked\r\n"),
+		"websocket":        "GET / HTTP/1.1\r\nHost: mosn.io\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: websocket\r\nHTTP2-Settings: \r\n\r\n",
+		"connection token": "GET / HTTP/1.1\r\nHost: mosn.io\r\nConnection: Upgrade\r\nUpgrade: h2c\r\nHTTP2-Settings: \r\n\r\n",
+	} {
+		conn := &mockConnection{}
+		codec := &serverCodec{sc: http2.NewServerConn(conn)}
+		if _, err := codec.Decode(context.Background(), buffer.NewIoBufferString(req)); err == nil || err == http2.ErrAGAIN {
+			t.Errorf("%s: expected an error, got %v", name, err)
+		}
+		if conn.out.Len() != 0 {
+			t.Errorf("%s: nothing should be written for a rejected upgrade, got %q", name, conn.out.Bytes())
+		}
+	}
+}
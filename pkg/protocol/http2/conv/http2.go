@@ -20,6 +20,8 @@ package conv
 import (
 	"context"
 	"errors"
+	"strings"
+
 	"sofastack.io/sofa-mosn/pkg/protocol"
 	"sofastack.io/sofa-mosn/pkg/protocol/http2"
 	"sofastack.io/sofa-mosn/pkg/types"
@@ -38,8 +40,11 @@ func (c *common2http) ConvHeader(ctx context.Context, headerMap types.HeaderMap)
 
 		delete(header, protocol.MosnHeaderDirection)
 
-		// copy headers
+		// copy headers, connection-specific fields are not allowed in http2
 		for k, v := range header {
+			if isConnectionHeader(k, v) {
+				continue
+			}
 			cheader[k] = v
 		}
 
@@ -56,6 +61,19 @@ func (c *common2http) ConvTrailer(ctx context.Context, headerMap types.HeaderMap
 	return headerMap, nil
 }
 
+// isConnectionHeader reports whether a header only makes sense on the
+// http1 hop it came from, see RFC 7540 section 8.1.2.2
+func isConnectionHeader(key, value string) bool {
+	switch strings.ToLower(key) {
+	case "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade", "http2-settings",
+		protocol.IstioHeaderHostKey:
+		return true
+	case "te":
+		return !strings.EqualFold(value, "trailers")
+	}
+	return false
+}
+
 // http2 -> common converter
 type http2common struct{}
 
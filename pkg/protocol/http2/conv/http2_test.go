@@ -0,0 +1,80 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package conv
+
+import (
+	"context"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	_ "sofastack.io/sofa-mosn/pkg/protocol/http/conv"
+)
+
+func TestConvertHttp1RequestToHttp2(t *testing.T) {
+	header := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	header.Set("Connection", "keep-alive, Upgrade")
+	header.Set("Keep-Alive", "timeout=5")
+	header.Set("Upgrade", "h2c")
+	header.Set("HTTP2-Settings", "AAMAAABkAAQAAP__")
+	header.Set("TE", "gzip")
+	header.Set("X-Service", "echo")
+	header.Set(protocol.IstioHeaderHostKey, "mosn.io")
+	header.Set(protocol.MosnHeaderMethod, "POST")
+	header.Set(protocol.MosnHeaderHostKey, "mosn.io")
+	header.Set(protocol.MosnHeaderPathKey, "/echo")
+
+	h, err := protocol.ConvertHeader(context.Background(), protocol.HTTP1, protocol.HTTP2, header)
+	if err != nil {
+		t.Fatalf("convert http1 to http2 failed: %v", err)
+	}
+	converted, ok := h.(protocol.CommonHeader)
+	if !ok {
+		t.Fatalf("unexpected converted header type %T", h)
+	}
+
+	for _, k := range []string{"connection", "keep-alive", "upgrade", "http2-settings", "te",
+		protocol.IstioHeaderHostKey, protocol.MosnHeaderDirection} {
+		if v, ok := converted.Get(k); ok {
+			t.Errorf("header %s should not be sent over http2, got %s", k, v)
+		}
+	}
+	// pseudo headers are kept for the http2 stream to encode
+	for k, v := range map[string]string{
+		"x-service":                "echo",
+		protocol.MosnHeaderMethod:  "POST",
+		protocol.MosnHeaderHostKey: "mosn.io",
+		protocol.MosnHeaderPathKey: "/echo",
+	} {
+		if got, _ := converted.Get(k); got != v {
+			t.Errorf("header %s expected %s, got %s", k, v, got)
+		}
+	}
+}
+
+func TestIsConnectionHeader(t *testing.T) {
+	if isConnectionHeader("te", "trailers") {
+		t.Errorf("te: trailers is allowed in http2")
+	}
+	if !isConnectionHeader("Transfer-Encoding", "chunked") {
+		t.Errorf("transfer-encoding is not allowed in http2")
+	}
+	if isConnectionHeader("content-type", "application/grpc") {
+		t.Errorf("content-type is allowed in http2")
+	}
+}
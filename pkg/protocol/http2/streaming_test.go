@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/module/http2"
+)
+
+func TestServerStreamingResponse(t *testing.T) {
+	conn := &mockConnection{}
+	codec := &serverCodec{sc: http2.NewServerConn(conn)}
+
+	data := buffer.NewIoBufferString(upgradeRequest(h2cSettings(), ""))
+	data.Write([]byte(http2.ClientPreface))
+	model, err := codec.Decode(context.Background(), data)
+	if err != nil {
+		t.Fatalf("decode upgrade request failed: %v", err)
+	}
+	ms := model.(*http2.H2cUpgrade).Stream
+	conn.out.Reset()
+
+	ms.Response = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+	}
+	if err := ms.WriteHeaders(false); err != nil {
+		t.Fatalf("write headers failed: %v", err)
+	}
+	ms.ReturnFlow(5)
+	if err := ms.WriteData([]byte("event: 1\n"), false); err != nil {
+		t.Fatalf("write data failed: %v", err)
+	}
+	if err := ms.WriteData([]byte("event: 2\n"), false); err != nil {
+		t.Fatalf("write data failed: %v", err)
+	}
+	ms.Response.Trailer = http.Header{"Grpc-Status": []string{"0"}}
+	if err := ms.WriteTrailers(); err != nil {
+		t.Fatalf("write trailers failed: %v", err)
+	}
+
+	// the frames are only valid until the next frame is read
+	framer := http2.NewFramer(nil, bytes.NewReader(conn.out.Bytes()))
+	next := func() http2.Frame {
+		f, err := framer.ReadFrame()
+		if err != nil {
+			t.Fatalf("read frame failed: %v", err)
+		}
+		return f
+	}
+	if f, ok := next().(*http2.HeadersFrame); !ok || f.StreamEnded() {
+		t.Errorf("the headers should not end the stream: %v", f)
+	}
+	// the upgraded request is half closed, only the connection window is refunded
+	if f, ok := next().(*http2.WindowUpdateFrame); !ok || f.StreamID != 0 || f.Increment != 5 {
+		t.Errorf("expected a connection window update: %v", f)
+	}
+	for _, body := range []string{"event: 1\n", "event: 2\n"} {
+		if f, ok := next().(*http2.DataFrame); !ok || f.StreamEnded() || string(f.Data()) != body {
+			t.Errorf("unexpected data frame: %v", f)
+		}
+	}
+	if f, ok := next().(*http2.HeadersFrame); !ok || !f.StreamEnded() {
+		t.Errorf("the trailers should end the stream: %v", f)
+	}
+	if f, err := framer.ReadFrame(); err == nil {
+		t.Errorf("unexpected frame after the trailers: %v", f)
+	}
+}
+
+func TestServerStreamingResponseNoTrailers(t *testing.T) {
+	conn := &mockConnection{}
+	codec := &serverCodec{sc: http2.NewServerConn(conn)}
+
+	data := buffer.NewIoBufferString(upgradeRequest(h2cSettings(), ""))
+	data.Write([]byte(http2.ClientPreface))
+	model, err := codec.Decode(context.Background(), data)
+	if err != nil {
+		t.Fatalf("decode upgrade request failed: %v", err)
+	}
+	ms := model.(*http2.H2cUpgrade).Stream
+	conn.out.Reset()
+
+	ms.Response = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	ms.WriteHeaders(false)
+	// the empty piece ends the stream
+	ms.WriteData(nil, true)
+
+	framer := http2.NewFramer(nil, bytes.NewReader(conn.out.Bytes()))
+	framer.ReadFrame()
+	f, err := framer.ReadFrame()
+	if err != nil {
+		t.Fatalf("read data frame failed: %v", err)
+	}
+	if df, ok := f.(*http2.DataFrame); !ok || !df.StreamEnded() || len(df.Data()) != 0 {
+		t.Errorf("expected an empty data frame ending the stream: %v", f)
+	}
+}
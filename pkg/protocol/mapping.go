@@ -21,6 +21,7 @@ import (
 	"errors"
 	"strconv"
 
+	"sofastack.io/sofa-mosn/pkg/protocol/grpc"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -51,10 +52,13 @@ func MappingHeaderStatusCode(p types.Protocol, headers types.HeaderMap) (int, er
 	return 0, ErrNoMapping
 }
 
-// HTTP get status directly
+// HTTP get status directly, the grpc-status of a trailers-only grpc response takes precedence
 type httpMapping struct{}
 
 func (m *httpMapping) MappingHeaderStatusCode(headers types.HeaderMap) (int, error) {
+	if code, ok := grpc.MappingStatusCode(headers); ok {
+		return code, nil
+	}
 	status, ok := headers.Get(types.HeaderStatus)
 	if !ok {
 		return 0, errors.New("headers have no status code")
@@ -39,6 +39,14 @@ func TestMapping(t *testing.T) {
 			CommonHeader{},
 			0,
 		},
+		{
+			CommonHeader{types.HeaderStatus: "200", "grpc-status": "14"},
+			503,
+		},
+		{
+			CommonHeader{types.HeaderStatus: "200", "grpc-status": "0"},
+			200,
+		},
 	}
 	for i, tc := range testcases {
 		code, _ := MappingHeaderStatusCode(HTTP1, tc.Header)
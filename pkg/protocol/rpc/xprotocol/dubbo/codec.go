@@ -0,0 +1,203 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/AlexStocks/dubbogo/codec/hessian"
+)
+
+// dubbo flag bits
+const (
+	DUBBO_FLAG_REQUEST = 0x80
+	DUBBO_FLAG_TWOWAY  = 0x40
+	DUBBO_FLAG_EVENT   = 0x20
+	DUBBO_SERIAL_MASK  = 0x1f
+
+	DUBBO_HESSIAN2_SERIALIZATION = 2
+
+	// DUBBO_RESPONSE_OK is the status of a successful response
+	DUBBO_RESPONSE_OK = 20
+)
+
+// Header keys of the frame fields, which are used by the protocol conversion
+const (
+	HeaderRequestID       = "dubbo-request-id"
+	HeaderSerializationID = "dubbo-serialization-id"
+	HeaderTwoWay          = "dubbo-two-way"
+	HeaderEvent           = "dubbo-event"
+	HeaderDirection       = "dubbo-direction"
+	HeaderStatus          = "dubbo-status"
+)
+
+// Header keys of the invocation, which are used by the request routing.
+// The attachments are kept with their own keys.
+const (
+	HeaderDubboVersion   = "dubbo-version"
+	HeaderService        = "service"
+	HeaderMethod         = "method"
+	HeaderVersion        = "version"
+	HeaderGroup          = "group"
+	HeaderParameterTypes = "parameter-types"
+)
+
+// Direction values
+const (
+	DirectionRequest  = "request"
+	DirectionResponse = "response"
+)
+
+var (
+	ErrNotHessian2    = errors.New("dubbo serialization is not hessian2")
+	ErrInvalidPayload = errors.New("dubbo payload is invalid")
+)
+
+// invocation is the decoded dubbo request body
+type invocation struct {
+	dubboVersion   string
+	service        string
+	version        string
+	method         string
+	parameterTypes string
+	attachments    map[string]string
+}
+
+// metas returns the invocation as the routing headers
+func (inv *invocation) metas() map[string]string {
+	metas := make(map[string]string, len(inv.attachments)+5)
+	for k, v := range inv.attachments {
+		metas[k] = v
+	}
+	metas[HeaderDubboVersion] = inv.dubboVersion
+	metas[HeaderService] = inv.service
+	metas[HeaderMethod] = inv.method
+	metas[HeaderParameterTypes] = inv.parameterTypes
+	if inv.version != "" {
+		metas[HeaderVersion] = inv.version
+	}
+	return metas
+}
+
+// decodeInvocation decodes the hessian2 request body:
+// dubbo version, service path, service version, method name, parameter types, arguments and attachments.
+// The attachments are not decoded if the arguments are unknown objects, which can not be skipped.
+func decodeInvocation(serializationID int, body []byte) (*invocation, error) {
+	if serializationID != DUBBO_HESSIAN2_SERIALIZATION {
+		return nil, ErrNotHessian2
+	}
+	decoder := hessian.NewDecoder(body)
+
+	var fields [5]string
+	for i := range fields {
+		field, err := decoder.Decode()
+		if err != nil {
+			return nil, err
+		}
+		// null is decoded as nil, such as an empty version
+		str, ok := field.(string)
+		if !ok && field != nil {
+			return nil, ErrInvalidPayload
+		}
+		fields[i] = str
+	}
+	inv := &invocation{
+		dubboVersion:   fields[0],
+		service:        fields[1],
+		version:        fields[2],
+		method:         fields[3],
+		parameterTypes: fields[4],
+	}
+
+	// skip the arguments
+	for i := countParameterTypes(inv.parameterTypes); i > 0; i-- {
+		if _, err := decoder.Decode(); err != nil {
+			return inv, nil
+		}
+	}
+
+	field, err := decoder.Decode()
+	if err != nil {
+		return inv, nil
+	}
+	if attachments, ok := field.(map[interface{}]interface{}); ok {
+		inv.attachments = make(map[string]string, len(attachments))
+		for k, v := range attachments {
+			key, ok := k.(string)
+			if !ok || v == nil {
+				continue
+			}
+			inv.attachments[key] = fmt.Sprint(v)
+		}
+		if version, ok := inv.attachments[HeaderVersion]; ok && inv.version == "" {
+			inv.version = version
+		}
+	}
+	return inv, nil
+}
+
+// countParameterTypes counts the jvm type descriptors, such as Ljava/lang/String;I[J
+func countParameterTypes(desc string) int {
+	count := 0
+	for i := 0; i < len(desc); i++ {
+		switch desc[i] {
+		case '[':
+			// array, the element type follows
+			continue
+		case 'L':
+			for i < len(desc) && desc[i] != ';' {
+				i++
+			}
+		}
+		count++
+	}
+	return count
+}
+
+// frameFields returns the frame fields as headers
+func frameFields(data []byte) map[string]string {
+	flag := data[DUBBO_FLAG_IDX]
+	headers := map[string]string{
+		HeaderRequestID:       strconv.FormatUint(binary.BigEndian.Uint64(data[DUBBO_ID_IDX:]), 10),
+		HeaderSerializationID: strconv.Itoa(getSerializeId(flag)),
+		HeaderTwoWay:          strconv.FormatBool(flag&DUBBO_FLAG_TWOWAY != 0),
+		HeaderEvent:           strconv.FormatBool(getEventPing(flag)),
+	}
+	if isReqFrame(flag) {
+		headers[HeaderDirection] = DirectionRequest
+	} else {
+		headers[HeaderDirection] = DirectionResponse
+		headers[HeaderStatus] = strconv.Itoa(int(data[DUBBO_STATUS_IDX]))
+	}
+	return headers
+}
+
+// heartbeatResponse builds the response of a heartbeat request, the body is a hessian null
+func heartbeatResponse(request []byte) []byte {
+	response := make([]byte, DUBBO_HEADER_LEN+1)
+	copy(response, DUBBO_MAGIC_TAG)
+	response[DUBBO_FLAG_IDX] = byte(getSerializeId(request[DUBBO_FLAG_IDX])) | DUBBO_FLAG_EVENT
+	response[DUBBO_STATUS_IDX] = DUBBO_RESPONSE_OK
+	copy(response[DUBBO_ID_IDX:DUBBO_ID_IDX+DUBBO_ID_LEN], request[DUBBO_ID_IDX:DUBBO_ID_IDX+DUBBO_ID_LEN])
+	binary.BigEndian.PutUint32(response[DUBBO_DATA_LEN_IDX:], 1)
+	response[DUBBO_HEADER_LEN] = 'N'
+	return response
+}
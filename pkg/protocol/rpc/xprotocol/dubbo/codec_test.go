@@ -0,0 +1,192 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/AlexStocks/dubbogo/codec/hessian"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol"
+)
+
+func buildDubboFrame(flag byte, id uint64, body []byte) []byte {
+	frame := make([]byte, DUBBO_HEADER_LEN+len(body))
+	copy(frame, DUBBO_MAGIC_TAG)
+	frame[DUBBO_FLAG_IDX] = flag
+	binary.BigEndian.PutUint64(frame[DUBBO_ID_IDX:], id)
+	binary.BigEndian.PutUint32(frame[DUBBO_DATA_LEN_IDX:], uint32(len(body)))
+	copy(frame[DUBBO_HEADER_LEN:], body)
+	return frame
+}
+
+func buildDubboRequest(t *testing.T, args []interface{}, attachments map[interface{}]interface{}) []byte {
+	encoder := hessian.NewEncoder()
+	for _, v := range []interface{}{"2.6.2", "com.example.DemoService", "1.0.0", "sayHello", "Ljava/lang/String;I[J"} {
+		if err := encoder.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range args {
+		if err := encoder.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Encode(attachments); err != nil {
+		t.Fatal(err)
+	}
+	return buildDubboFrame(DUBBO_FLAG_REQUEST|DUBBO_FLAG_TWOWAY|DUBBO_HESSIAN2_SERIALIZATION, 10, encoder.Buffer())
+}
+
+func TestDubboGetMetas(t *testing.T) {
+	frame := buildDubboRequest(t, []interface{}{"world", int32(1), []interface{}{int64(1), int64(2)}}, map[interface{}]interface{}{
+		"group":   "blue",
+		"path":    "com.example.DemoService",
+		"timeout": "3000",
+	})
+	rpc := NewRPCDubbo().(*rpcDubbo)
+	metas := rpc.GetMetas(frame)
+	expected := map[string]string{
+		HeaderDubboVersion:   "2.6.2",
+		HeaderService:        "com.example.DemoService",
+		HeaderVersion:        "1.0.0",
+		HeaderMethod:         "sayHello",
+		HeaderParameterTypes: "Ljava/lang/String;I[J",
+		HeaderGroup:          "blue",
+		"path":               "com.example.DemoService",
+		"timeout":            "3000",
+	}
+	if len(metas) != len(expected) {
+		t.Fatalf("unexpected metas: %v", metas)
+	}
+	for k, v := range expected {
+		if metas[k] != v {
+			t.Errorf("meta %s expected %s, but got %s", k, v, metas[k])
+		}
+	}
+	if rpc.GetServiceName(frame) != "com.example.DemoService" || rpc.GetMethodName(frame) != "sayHello" {
+		t.Error("unexpected service or method name")
+	}
+}
+
+func TestDubboGetMetasInvalid(t *testing.T) {
+	rpc := NewRPCDubbo().(*rpcDubbo)
+	// response
+	if metas := rpc.GetMetas(buildDubboFrame(DUBBO_HESSIAN2_SERIALIZATION, 1, []byte{'N'})); metas != nil {
+		t.Errorf("expected no metas for response, but got %v", metas)
+	}
+	// not hessian2
+	if metas := rpc.GetMetas(buildDubboFrame(DUBBO_FLAG_REQUEST|6, 1, []byte{'N'})); metas != nil {
+		t.Errorf("expected no metas for other serialization, but got %v", metas)
+	}
+	// truncated body
+	if metas := rpc.GetMetas(buildDubboFrame(DUBBO_FLAG_REQUEST|DUBBO_HESSIAN2_SERIALIZATION, 1, []byte{0x05, '2'})); metas != nil {
+		t.Errorf("expected no metas for invalid body, but got %v", metas)
+	}
+}
+
+func TestDubboConvert(t *testing.T) {
+	rpc := NewRPCDubbo().(*rpcDubbo)
+	request := buildDubboFrame(DUBBO_FLAG_REQUEST|DUBBO_FLAG_TWOWAY|DUBBO_HESSIAN2_SERIALIZATION, 78, []byte{'N'})
+	headers, data := rpc.Convert(request)
+	if string(data) != string(request) {
+		t.Error("data should not be changed")
+	}
+	if headers[HeaderRequestID] != "78" || headers[HeaderSerializationID] != "2" || headers[HeaderTwoWay] != "true" ||
+		headers[HeaderEvent] != "false" || headers[HeaderDirection] != DirectionRequest {
+		t.Errorf("unexpected request headers: %v", headers)
+	}
+	if _, ok := headers[HeaderStatus]; ok {
+		t.Error("request should have no status")
+	}
+
+	response := buildDubboFrame(DUBBO_HESSIAN2_SERIALIZATION, 78, []byte{'N'})
+	response[DUBBO_STATUS_IDX] = 70
+	headers, _ = rpc.Convert(response)
+	if headers[HeaderDirection] != DirectionResponse || headers[HeaderStatus] != "70" || headers[HeaderTwoWay] != "false" {
+		t.Errorf("unexpected response headers: %v", headers)
+	}
+}
+
+func TestDubboHeartbeat(t *testing.T) {
+	rpc := NewRPCDubbo().(*rpcDubbo)
+	request := buildDubboFrame(DUBBO_FLAG_REQUEST|DUBBO_FLAG_TWOWAY|DUBBO_FLAG_EVENT|DUBBO_HESSIAN2_SERIALIZATION, 126, []byte{'N'})
+	if !rpc.IsHeartbeat(request) {
+		t.Fatal("expected heartbeat")
+	}
+	response := rpc.HeartbeatResponse(request)
+	if !rpc.IsHeartbeat(response) || isReqFrame(response[DUBBO_FLAG_IDX]) || response[DUBBO_STATUS_IDX] != DUBBO_RESPONSE_OK {
+		t.Errorf("unexpected heartbeat response: %v", response)
+	}
+	if rpc.GetStreamID(response) != "126" || getSerializeId(response[DUBBO_FLAG_IDX]) != DUBBO_HESSIAN2_SERIALIZATION {
+		t.Errorf("unexpected heartbeat response header: %v", response)
+	}
+	if len(rpc.SplitFrame(response)) != 1 {
+		t.Errorf("invalid heartbeat response frame: %v", response)
+	}
+
+	// no response for the heartbeat response and one way request
+	if rpc.HeartbeatResponse(response) != nil {
+		t.Error("heartbeat response should not be responded")
+	}
+	oneway := buildDubboFrame(DUBBO_FLAG_REQUEST|DUBBO_FLAG_EVENT|DUBBO_HESSIAN2_SERIALIZATION, 127, []byte{'N'})
+	if rpc.HeartbeatResponse(oneway) != nil {
+		t.Error("one way heartbeat should not be responded")
+	}
+
+	if rpc.IsHeartbeat(buildDubboFrame(DUBBO_FLAG_REQUEST|DUBBO_HESSIAN2_SERIALIZATION, 1, []byte{'N'})) {
+		t.Error("request is not heartbeat")
+	}
+}
+
+func TestCountParameterTypes(t *testing.T) {
+	testcases := []struct {
+		desc  string
+		count int
+	}{
+		{"", 0},
+		{"I", 1},
+		{"Ljava/lang/String;", 1},
+		{"Ljava/lang/String;IJ", 3},
+		{"[Ljava/lang/String;[[IZ", 3},
+	}
+	for i, tc := range testcases {
+		if count := countParameterTypes(tc.desc); count != tc.count {
+			t.Errorf("#%d %s expected %d, but got %d", i, tc.desc, tc.count, count)
+		}
+	}
+}
+
+func TestProtocolMatch(t *testing.T) {
+	rpc := NewRPCDubbo().(xprotocol.ProtocolMatcher)
+	testCases := []struct {
+		magic  []byte
+		result xprotocol.MatchResult
+	}{
+		{[]byte{0xda, 0xbb, 0xc2}, xprotocol.MatchSuccess},
+		{[]byte{0xda}, xprotocol.MatchAgain},
+		{[]byte{}, xprotocol.MatchAgain},
+		{[]byte{0xda, 0xbc}, xprotocol.MatchFailed},
+		{[]byte("GET"), xprotocol.MatchFailed},
+	}
+	for _, tc := range testCases {
+		if result := rpc.ProtocolMatch(tc.magic); result != tc.result {
+			t.Errorf("magic %v expected %d, got %d", tc.magic, tc.result, result)
+		}
+	}
+}
@@ -188,3 +188,61 @@ func (d *rpcDubbo) GetMethodName(data []byte) string {
 	}
 	return ""
 }
+
+// GetMetas returns the invocation of the request as headers, such as service, method, version, group
+// and the attachments, so the dubbo calls can be routed by the header matchers.
+func (d *rpcDubbo) GetMetas(data []byte) map[string]string {
+	rslt, bodyLen := isValidDubboData(data)
+	if rslt == false || bodyLen <= 0 {
+		return nil
+	}
+	flag := data[DUBBO_FLAG_IDX]
+	if getEventPing(flag) || !isReqFrame(flag) {
+		return nil
+	}
+	inv, err := decodeInvocation(getSerializeId(flag), data[DUBBO_HEADER_LEN:])
+	if err != nil {
+		return nil
+	}
+	return inv.metas()
+}
+
+// Convert returns the frame fields as headers for the protocol conversion, the data is not changed
+func (d *rpcDubbo) Convert(data []byte) (map[string]string, []byte) {
+	if rslt, _ := isValidDubboData(data); rslt == false {
+		return nil, data
+	}
+	return frameFields(data), data
+}
+
+// IsHeartbeat returns true for the event frames, heartbeat is the only event of dubbo
+func (d *rpcDubbo) IsHeartbeat(data []byte) bool {
+	if rslt, _ := isValidDubboData(data); rslt == false {
+		return false
+	}
+	return getEventPing(data[DUBBO_FLAG_IDX])
+}
+
+// HeartbeatResponse returns the response of a two way heartbeat request
+func (d *rpcDubbo) HeartbeatResponse(data []byte) []byte {
+	flag := data[DUBBO_FLAG_IDX]
+	if !isReqFrame(flag) || flag&DUBBO_FLAG_TWOWAY == 0 {
+		return nil
+	}
+	return heartbeatResponse(data)
+}
+
+// ProtocolMatch matches the dubbo magic
+func (d *rpcDubbo) ProtocolMatch(magic []byte) xprotocol.MatchResult {
+	size := len(magic)
+	if size > len(DUBBO_MAGIC_TAG) {
+		size = len(DUBBO_MAGIC_TAG)
+	}
+	if !bytes.Equal(magic[:size], DUBBO_MAGIC_TAG[:size]) {
+		return xprotocol.MatchFailed
+	}
+	if size < len(DUBBO_MAGIC_TAG) {
+		return xprotocol.MatchAgain
+	}
+	return xprotocol.MatchSuccess
+}
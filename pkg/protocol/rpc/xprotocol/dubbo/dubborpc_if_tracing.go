@@ -17,12 +17,6 @@
 
 package dubbo
 
-import (
-	"fmt"
-
-	"github.com/AlexStocks/dubbogo/codec/hessian"
-)
-
 func init() {
 	serviceNameFunc = dubboGetServiceName
 	methodNameFunc = dubboGetMethodName
@@ -46,67 +40,14 @@ type dubboAttr struct {
 }
 
 func unSerialize(serializeId int, data []byte) *dubboAttr {
-	if serializeId != 2 {
-		// not hessian, do not support
-		fmt.Printf("unSerialize: id=%d is not hessian\n", serializeId)
-		return nil
-	}
-	attr := &dubboAttr{}
-	decoder := hessian.NewDecoder(data[:])
-	var field interface{}
-	var err error
-	var ok bool
-	var str string
-
-	// dubbo version + path + version + method
-
-	field, err = decoder.Decode()
-	if err != nil {
-		fmt.Printf("unSerialize: Decode dubbo_version fail, err=%v\n", err)
-		return nil
-	}
-	str, ok = field.(string)
-	if !ok {
-		fmt.Printf("unSerialize: Decode dubbo_version fail, illegal type\n")
-		return nil
-	}
-
-	field, err = decoder.Decode()
-	if err != nil {
-		fmt.Printf("unSerialize: Decode path fail, err=%v\n", err)
-		return nil
-	}
-	str, ok = field.(string)
-	if !ok {
-		fmt.Printf("unSerialize: Decode path fail, illegal type\n")
-		return nil
-	}
-	attr.serviceName = str
-
-	field, err = decoder.Decode()
+	inv, err := decodeInvocation(serializeId, data)
 	if err != nil {
-		fmt.Printf("unSerialize: Decode version fail, err=%v\n", err)
 		return nil
 	}
-	str, ok = field.(string)
-	if !ok {
-		fmt.Printf("unSerialize: Decode version fail, illegal type\n")
-		return nil
+	return &dubboAttr{
+		serviceName: inv.service,
+		methodName:  inv.method,
 	}
-
-	field, err = decoder.Decode()
-	if err != nil {
-		fmt.Printf("unSerialize: Decode method fail, err=%v\n", err)
-		return nil
-	}
-	str, ok = field.(string)
-	if !ok {
-		fmt.Printf("unSerialize: Decode method fail, illegal type\n")
-		return nil
-	}
-	attr.methodName = str
-
-	return attr
 }
 
 func dubboGetServiceName(data []byte) string {
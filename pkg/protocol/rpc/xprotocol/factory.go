@@ -90,6 +90,25 @@ func CreateSubProtocolCodec(context context.Context, prot SubProtocol) Multiplex
 	return nil
 }
 
+// MatchSubProtocol tries the registered sub protocols that implement ProtocolMatcher,
+// returns the matched sub protocol, or MatchAgain if any sub protocol needs more bytes
+func MatchSubProtocol(context context.Context, magic []byte) (SubProtocol, MatchResult) {
+	result := MatchFailed
+	for prot, factory := range subProtocolFactories {
+		matcher, ok := factory.CreateSubProtocolCodec(context).(ProtocolMatcher)
+		if !ok {
+			continue
+		}
+		switch matcher.ProtocolMatch(magic) {
+		case MatchSuccess:
+			return prot, MatchSuccess
+		case MatchAgain:
+			result = MatchAgain
+		}
+	}
+	return "", result
+}
+
 // TODO should change the multiplexing interface to support decode into a data and header
 // XRpcCmd In XProtocol Mode , XRpcCmd is a codec wrapper , so data and header is useless currently
 type XRpcCmd struct {
@@ -188,6 +207,31 @@ func (xRpcCmd *XRpcCmd) Convert(data []byte) (map[string]string, []byte) {
 	return nil, nil
 }
 
+//Heartbeat
+func (xRpcCmd *XRpcCmd) IsHeartbeat(data []byte) bool {
+	heartbeatCmd, ok := xRpcCmd.codec.(Heartbeat)
+	if ok {
+		return heartbeatCmd.IsHeartbeat(data)
+	}
+	return false
+}
+func (xRpcCmd *XRpcCmd) HeartbeatResponse(data []byte) []byte {
+	heartbeatCmd, ok := xRpcCmd.codec.(Heartbeat)
+	if ok {
+		return heartbeatCmd.HeartbeatResponse(data)
+	}
+	return nil
+}
+
+//Hijacker
+func (xRpcCmd *XRpcCmd) HijackResponse(request []byte, statusCode int) []byte {
+	hijackCmd, ok := xRpcCmd.codec.(Hijacker)
+	if ok {
+		return hijackCmd.HijackResponse(request, statusCode)
+	}
+	return nil
+}
+
 func (xRpcCmd *XRpcCmd) Get(key string) (value string, ok bool) {
 	value, ok = xRpcCmd.header[key]
 	return
@@ -292,3 +292,24 @@ func getPacket(data []byte) ([]byte, int) {
 	copy(pkg, data[4:pkgLen])
 	return pkg, status
 }
+
+// ProtocolMatch matches the package length and the head of the first field,
+// the first field of request and response packets is the version with tag 1
+func (r *rpcTars) ProtocolMatch(magic []byte) xprotocol.MatchResult {
+	// the package length is less than 16M, so the first byte is zero
+	if len(magic) > 0 && magic[0] != 0 {
+		return xprotocol.MatchFailed
+	}
+	if len(magic) <= TARS_HEADER_LEN {
+		return xprotocol.MatchAgain
+	}
+	head := magic[TARS_HEADER_LEN]
+	if head>>4 != 1 {
+		return xprotocol.MatchFailed
+	}
+	switch head & 0x0f {
+	case codec.BYTE, codec.SHORT, codec.ZERO_TAG:
+		return xprotocol.MatchSuccess
+	}
+	return xprotocol.MatchFailed
+}
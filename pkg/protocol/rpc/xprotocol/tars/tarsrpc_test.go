@@ -17,7 +17,11 @@
 
 package tars
 
-import "testing"
+import (
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol"
+)
 
 func Test_tars_SplitFrame_Request(t *testing.T) {
 	msg := []byte{0, 0, 0, 31, 16, 1, 44, 49, 44, 146, 76, 92, 109, 0, 0, 9, 12, 45, 0, 0, 4, 1, 1, 1, 1, 120, 12, 134, 0, 152, 12, 0, 0, 0, 31, 16, 1, 44, 49, 36, 65, 76, 92, 109, 0, 0, 9, 12, 45, 0, 0, 4, 1, 1, 1, 1, 120, 12, 134, 0, 152, 12, 0, 0, 0, 31, 16, 1, 44, 49, 36, 66, 76, 92, 109, 0, 0, 9, 12, 45, 0, 0, 4, 1, 1, 1, 1, 120, 12, 134, 0, 152, 12}
@@ -112,3 +116,16 @@ func Test_tars_GetMethodName_01(t *testing.T) {
 		t.Log("get method-name succ ok")
 	}
 }
+
+func Test_tars_ProtocolMatch(t *testing.T) {
+	rpc := NewRpcTars().(xprotocol.ProtocolMatcher)
+	if result := rpc.ProtocolMatch([]byte{0, 0, 0, 31, 16, 1, 44, 49}); result != xprotocol.MatchSuccess {
+		t.Errorf("tars packet should be matched, got %d", result)
+	}
+	if result := rpc.ProtocolMatch([]byte{0, 0, 0}); result != xprotocol.MatchAgain {
+		t.Errorf("partial tars packet should wait for more bytes, got %d", result)
+	}
+	if result := rpc.ProtocolMatch([]byte{0xda, 0xbb}); result != xprotocol.MatchFailed {
+		t.Errorf("dubbo packet should not be matched, got %d", result)
+	}
+}
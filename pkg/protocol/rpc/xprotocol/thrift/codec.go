@@ -0,0 +1,381 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// thrift transport and protocol constants
+const (
+	FRAME_SIZE_LEN = 4
+	MAX_FRAME_SIZE = 16 * 1024 * 1024
+
+	// THeader transport
+	HEADER_MAGIC             = 0x0FFF
+	HEADER_SEQID_OFFSET      = FRAME_SIZE_LEN + 4
+	HEADER_SIZE_OFFSET       = FRAME_SIZE_LEN + 8
+	HEADER_DATA_OFFSET       = FRAME_SIZE_LEN + 10
+	HEADER_PROTOCOL_BINARY   = 0
+	HEADER_PROTOCOL_COMPACT  = 2
+	HEADER_INFO_PADDING      = 0
+	HEADER_INFO_KEYVALUE     = 1
+	HEADER_MAX_INFO_KEYVALUE = 1024
+
+	// binary protocol
+	BINARY_VERSION_MASK = 0xffff0000
+	BINARY_VERSION_1    = 0x80010000
+
+	// compact protocol
+	COMPACT_PROTOCOL_ID  = 0x82
+	COMPACT_VERSION      = 1
+	COMPACT_VERSION_MASK = 0x1f
+	COMPACT_TYPE_SHIFT   = 5
+
+	// message types
+	MESSAGE_CALL      = 1
+	MESSAGE_REPLY     = 2
+	MESSAGE_EXCEPTION = 3
+	MESSAGE_ONEWAY    = 4
+
+	// TApplicationException types
+	EXCEPTION_UNKNOWN_METHOD = 1
+	EXCEPTION_INTERNAL_ERROR = 6
+)
+
+// multiplexed protocol separates the service name and the method name in the message name
+const multiplexedSeparator = ":"
+
+var (
+	ErrFrameTooShort  = errors.New("thrift frame too short")
+	ErrUnknownMessage = errors.New("thrift unknown message protocol")
+)
+
+// frame is a decoded thrift frame, the offsets are absolute offsets in the frame data
+type frame struct {
+	// header is true on THeader transport
+	header     bool
+	protocolID uint64
+	// transformed payload can not be decoded, the message is nil
+	transformed bool
+	headers     map[string]string
+	message     *message
+}
+
+// message is a decoded thrift message header
+type message struct {
+	compact bool
+	// strict is false on old binary protocol without version
+	strict      bool
+	name        string
+	typeID      byte
+	seqID       int32
+	seqIDOffset int
+	seqIDLen    int
+}
+
+// decodeFrame decodes a complete frame split by SplitFrame
+func decodeFrame(data []byte) (*frame, error) {
+	if len(data) < FRAME_SIZE_LEN {
+		return nil, ErrFrameTooShort
+	}
+	f := &frame{}
+	payloadOffset := FRAME_SIZE_LEN
+	if isHeaderTransport(data) {
+		if len(data) < HEADER_DATA_OFFSET {
+			return nil, ErrFrameTooShort
+		}
+		f.header = true
+		headerLen := int(binary.BigEndian.Uint16(data[HEADER_SIZE_OFFSET:])) * 4
+		payloadOffset = HEADER_DATA_OFFSET + headerLen
+		if len(data) < payloadOffset {
+			return nil, ErrFrameTooShort
+		}
+		if err := f.decodeHeader(data[HEADER_DATA_OFFSET:payloadOffset]); err != nil {
+			return nil, err
+		}
+		if f.transformed {
+			return f, nil
+		}
+	}
+	msg, err := decodeMessage(data, payloadOffset)
+	if err != nil {
+		return nil, err
+	}
+	f.message = msg
+	return f, nil
+}
+
+func isHeaderTransport(data []byte) bool {
+	return len(data) >= FRAME_SIZE_LEN+2 && binary.BigEndian.Uint16(data[FRAME_SIZE_LEN:]) == HEADER_MAGIC
+}
+
+// decodeHeader decodes the THeader: protocol id, transforms and info headers
+func (f *frame) decodeHeader(header []byte) error {
+	r := &reader{data: header}
+	f.protocolID = r.varint()
+	transforms := r.varint()
+	for i := uint64(0); i < transforms; i++ {
+		r.varint()
+	}
+	f.transformed = transforms > 0
+	for r.err == nil && r.pos < len(r.data) {
+		switch r.varint() {
+		case HEADER_INFO_KEYVALUE:
+			count := r.varint()
+			if count > HEADER_MAX_INFO_KEYVALUE {
+				return ErrUnknownMessage
+			}
+			if f.headers == nil {
+				f.headers = make(map[string]string, count)
+			}
+			for i := uint64(0); i < count && r.err == nil; i++ {
+				key := r.varintString()
+				value := r.varintString()
+				if r.err == nil {
+					f.headers[key] = value
+				}
+			}
+		default:
+			// padding or unknown info, the rest is ignored
+			return r.err
+		}
+	}
+	return r.err
+}
+
+// decodeMessage decodes the message header at offset
+func decodeMessage(data []byte, offset int) (*message, error) {
+	if len(data) < offset+1 {
+		return nil, ErrFrameTooShort
+	}
+	if data[offset] == COMPACT_PROTOCOL_ID {
+		return decodeCompactMessage(data, offset)
+	}
+	return decodeBinaryMessage(data, offset)
+}
+
+func decodeBinaryMessage(data []byte, offset int) (*message, error) {
+	r := &reader{data: data, pos: offset}
+	msg := &message{}
+	size := r.uint32()
+	if size&BINARY_VERSION_MASK == BINARY_VERSION_1 {
+		msg.strict = true
+		msg.typeID = byte(size)
+		msg.name = r.string(int(r.uint32()))
+	} else if int32(size) >= 0 {
+		msg.name = r.string(int(size))
+		msg.typeID = r.byte()
+	} else {
+		return nil, ErrUnknownMessage
+	}
+	msg.seqIDOffset = r.pos
+	msg.seqIDLen = 4
+	msg.seqID = int32(r.uint32())
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+func decodeCompactMessage(data []byte, offset int) (*message, error) {
+	r := &reader{data: data, pos: offset + 1}
+	msg := &message{compact: true, strict: true}
+	versionAndType := r.byte()
+	if versionAndType&COMPACT_VERSION_MASK != COMPACT_VERSION {
+		return nil, ErrUnknownMessage
+	}
+	msg.typeID = versionAndType >> COMPACT_TYPE_SHIFT
+	msg.seqIDOffset = r.pos
+	msg.seqID = int32(r.varint())
+	msg.seqIDLen = r.pos - msg.seqIDOffset
+	msg.name = r.varintString()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// seqID returns the sequence id of the frame, THeader carries it in the transport header
+func (f *frame) seqID(data []byte) uint32 {
+	if f.header {
+		return binary.BigEndian.Uint32(data[HEADER_SEQID_OFFSET:])
+	}
+	return uint32(f.message.seqID)
+}
+
+// setSeqID rewrites the sequence id, the frame size is updated if the length of the varint changes
+func (f *frame) setSeqID(data []byte, seqID uint32) []byte {
+	if f.header {
+		binary.BigEndian.PutUint32(data[HEADER_SEQID_OFFSET:], seqID)
+	}
+	msg := f.message
+	if msg == nil {
+		return data
+	}
+	if !msg.compact {
+		binary.BigEndian.PutUint32(data[msg.seqIDOffset:], seqID)
+		return data
+	}
+	encoded := appendVarint(nil, uint64(seqID))
+	if len(encoded) == msg.seqIDLen {
+		copy(data[msg.seqIDOffset:], encoded)
+		return data
+	}
+	newData := make([]byte, 0, len(data)-msg.seqIDLen+len(encoded))
+	newData = append(newData, data[:msg.seqIDOffset]...)
+	newData = append(newData, encoded...)
+	newData = append(newData, data[msg.seqIDOffset+msg.seqIDLen:]...)
+	binary.BigEndian.PutUint32(newData, uint32(len(newData)-FRAME_SIZE_LEN))
+	return newData
+}
+
+// encodeException encodes a TApplicationException reply of the request frame,
+// in the same protocol and transport as the request
+func encodeException(f *frame, seqID uint32, exceptionType int32, reason string) []byte {
+	msg := f.message
+	var payload []byte
+	if msg.compact {
+		payload = append(payload, COMPACT_PROTOCOL_ID, MESSAGE_EXCEPTION<<COMPACT_TYPE_SHIFT|COMPACT_VERSION)
+		payload = appendVarint(payload, uint64(seqID))
+		payload = appendVarint(payload, uint64(len(msg.name)))
+		payload = append(payload, msg.name...)
+		// field 1: message string
+		payload = append(payload, 0x18)
+		payload = appendVarint(payload, uint64(len(reason)))
+		payload = append(payload, reason...)
+		// field 2: type i32, zigzag encoded
+		payload = append(payload, 0x15)
+		payload = appendVarint(payload, uint64(uint32((exceptionType<<1)^(exceptionType>>31))))
+		payload = append(payload, 0)
+	} else {
+		if msg.strict {
+			payload = appendUint32(payload, BINARY_VERSION_1|MESSAGE_EXCEPTION)
+			payload = appendUint32(payload, uint32(len(msg.name)))
+			payload = append(payload, msg.name...)
+		} else {
+			payload = appendUint32(payload, uint32(len(msg.name)))
+			payload = append(payload, msg.name...)
+			payload = append(payload, MESSAGE_EXCEPTION)
+		}
+		payload = appendUint32(payload, seqID)
+		// field 1: message string
+		payload = append(payload, 11, 0, 1)
+		payload = appendUint32(payload, uint32(len(reason)))
+		payload = append(payload, reason...)
+		// field 2: type i32
+		payload = append(payload, 8, 0, 2)
+		payload = appendUint32(payload, uint32(exceptionType))
+		payload = append(payload, 0)
+	}
+
+	var header []byte
+	if f.header {
+		// protocol id and no transforms, padded to 4 bytes
+		header = appendVarint(header, f.protocolID)
+		header = append(header, 0)
+		for len(header)%4 != 0 {
+			header = append(header, HEADER_INFO_PADDING)
+		}
+	}
+
+	size := len(payload)
+	if f.header {
+		size += HEADER_DATA_OFFSET - FRAME_SIZE_LEN + len(header)
+	}
+	data := make([]byte, 0, FRAME_SIZE_LEN+size)
+	data = appendUint32(data, uint32(size))
+	if f.header {
+		data = append(data, HEADER_MAGIC>>8, HEADER_MAGIC&0xff, 0, 0)
+		data = appendUint32(data, seqID)
+		data = append(data, byte(len(header)/4>>8), byte(len(header)/4))
+		data = append(data, header...)
+	}
+	return append(data, payload...)
+}
+
+// reader reads thrift primitives, the first error is kept and the following reads are no-op
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = ErrFrameTooShort
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) string(n int) string {
+	return string(r.next(n))
+}
+
+func (r *reader) varint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.err = ErrFrameTooShort
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *reader) varintString() string {
+	n := r.varint()
+	if n > uint64(len(r.data)) {
+		r.err = ErrFrameTooShort
+		return ""
+	}
+	return r.string(int(n))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
@@ -0,0 +1,242 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol"
+)
+
+// binaryMessage encodes a strict binary message header with an empty struct body
+func binaryMessage(name string, typeID byte, seqID uint32) []byte {
+	var b []byte
+	b = appendUint32(b, BINARY_VERSION_1|uint32(typeID))
+	b = appendUint32(b, uint32(len(name)))
+	b = append(b, name...)
+	b = appendUint32(b, seqID)
+	return append(b, 0)
+}
+
+// compactMessage encodes a compact message header with an empty struct body
+func compactMessage(name string, typeID byte, seqID uint32) []byte {
+	b := []byte{COMPACT_PROTOCOL_ID, typeID<<COMPACT_TYPE_SHIFT | COMPACT_VERSION}
+	b = appendVarint(b, uint64(seqID))
+	b = appendVarint(b, uint64(len(name)))
+	b = append(b, name...)
+	return append(b, 0)
+}
+
+func framed(payload []byte) []byte {
+	return append(appendUint32(nil, uint32(len(payload))), payload...)
+}
+
+// headerFramed encodes a THeader frame with key-value headers
+func headerFramed(protocolID uint64, seqID uint32, headers [][2]string, payload []byte) []byte {
+	header := appendVarint(nil, protocolID)
+	header = append(header, 0)
+	if len(headers) > 0 {
+		header = append(header, HEADER_INFO_KEYVALUE)
+		header = appendVarint(header, uint64(len(headers)))
+		for _, kv := range headers {
+			header = appendVarint(header, uint64(len(kv[0])))
+			header = append(header, kv[0]...)
+			header = appendVarint(header, uint64(len(kv[1])))
+			header = append(header, kv[1]...)
+		}
+	}
+	for len(header)%4 != 0 {
+		header = append(header, HEADER_INFO_PADDING)
+	}
+	b := []byte{HEADER_MAGIC >> 8, HEADER_MAGIC & 0xff, 0, 0}
+	b = appendUint32(b, seqID)
+	b = append(b, byte(len(header)/4>>8), byte(len(header)/4))
+	b = append(b, header...)
+	return framed(append(b, payload...))
+}
+
+func TestSplitFrame(t *testing.T) {
+	rpc := NewRPCThrift()
+	first := framed(binaryMessage("ping", MESSAGE_CALL, 1))
+	second := headerFramed(HEADER_PROTOCOL_COMPACT, 2, nil, compactMessage("ping", MESSAGE_CALL, 2))
+	data := append(append([]byte{}, first...), second...)
+	// half of the next frame
+	data = append(data, first[:6]...)
+	frames := rpc.SplitFrame(data)
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+	if !bytes.Equal(frames[0], first) || !bytes.Equal(frames[1], second) {
+		t.Error("split frames not matched")
+	}
+	if frames := rpc.SplitFrame([]byte{0xff, 0xff, 0xff, 0xff, 0}); len(frames) != 0 {
+		t.Error("oversize frame should not be split")
+	}
+}
+
+func TestStreamID(t *testing.T) {
+	rpc := NewRPCThrift()
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"framed binary", framed(binaryMessage("echo", MESSAGE_CALL, 100))},
+		{"framed compact", framed(compactMessage("echo", MESSAGE_CALL, 100))},
+		{"header binary", headerFramed(HEADER_PROTOCOL_BINARY, 100, nil, binaryMessage("echo", MESSAGE_CALL, 100))},
+		{"header compact", headerFramed(HEADER_PROTOCOL_COMPACT, 100, nil, compactMessage("echo", MESSAGE_CALL, 100))},
+	}
+	for _, tc := range testCases {
+		if id := rpc.GetStreamID(tc.data); id != "100" {
+			t.Errorf("%s: expected stream id 100, got %s", tc.name, id)
+			continue
+		}
+		// the compact varint grows from 1 byte to 3 bytes
+		data := rpc.SetStreamID(tc.data, "65536")
+		if id := rpc.GetStreamID(data); id != "65536" {
+			t.Errorf("%s: expected stream id 65536, got %s", tc.name, id)
+		}
+		if size := binary.BigEndian.Uint32(data); int(size) != len(data)-FRAME_SIZE_LEN {
+			t.Errorf("%s: frame size %d not matched data length %d", tc.name, size, len(data))
+		}
+		f, err := decodeFrame(data)
+		if err != nil || f.message.seqID != 65536 || f.message.name != "echo" {
+			t.Errorf("%s: message not updated: %+v, %v", tc.name, f, err)
+		}
+	}
+}
+
+func TestMetas(t *testing.T) {
+	rpc := NewRPCThrift()
+	data := headerFramed(HEADER_PROTOCOL_BINARY, 1, [][2]string{{"env", "gray"}, {"caller", "app"}},
+		binaryMessage("UserService:getUser", MESSAGE_CALL, 1))
+	tracing := rpc.(*rpcThrift)
+	if service := tracing.GetServiceName(data); service != "UserService" {
+		t.Errorf("unexpected service %s", service)
+	}
+	if method := tracing.GetMethodName(data); method != "getUser" {
+		t.Errorf("unexpected method %s", method)
+	}
+	metas := tracing.GetMetas(data)
+	expected := map[string]string{
+		"env":       "gray",
+		"caller":    "app",
+		SERVICE_KEY: "UserService",
+		METHOD_KEY:  "getUser",
+	}
+	for k, v := range expected {
+		if metas[k] != v {
+			t.Errorf("meta %s expected %s, got %s", k, v, metas[k])
+		}
+	}
+
+	// not multiplexed, the service comes from the THeader
+	data = headerFramed(HEADER_PROTOCOL_COMPACT, 1, [][2]string{{SERVICE_KEY, "OrderService"}},
+		compactMessage("create", MESSAGE_CALL, 1))
+	if service, method := tracing.GetServiceName(data), tracing.GetMethodName(data); service != "OrderService" || method != "create" {
+		t.Errorf("unexpected service %s method %s", service, method)
+	}
+
+	// non-strict binary
+	var old []byte
+	old = appendUint32(old, 4)
+	old = append(old, "ping"...)
+	old = append(old, MESSAGE_CALL)
+	old = appendUint32(old, 7)
+	old = framed(append(old, 0))
+	if method := tracing.GetMethodName(old); method != "ping" {
+		t.Errorf("unexpected method %s", method)
+	}
+	if id := tracing.GetStreamID(old); id != "7" {
+		t.Errorf("unexpected stream id %s", id)
+	}
+}
+
+func TestHijackResponse(t *testing.T) {
+	rpc := NewRPCThrift().(*rpcThrift)
+	testCases := []struct {
+		name    string
+		request []byte
+		header  bool
+	}{
+		{"framed binary", framed(binaryMessage("Svc:echo", MESSAGE_CALL, 9)), false},
+		{"framed compact", framed(compactMessage("Svc:echo", MESSAGE_CALL, 9)), false},
+		{"header binary", headerFramed(HEADER_PROTOCOL_BINARY, 9, nil, binaryMessage("Svc:echo", MESSAGE_CALL, 9)), true},
+		{"header compact", headerFramed(HEADER_PROTOCOL_COMPACT, 9, nil, compactMessage("Svc:echo", MESSAGE_CALL, 9)), true},
+	}
+	for _, tc := range testCases {
+		resp := rpc.HijackResponse(tc.request, 404)
+		if resp == nil {
+			t.Errorf("%s: no hijack response", tc.name)
+			continue
+		}
+		if frames := rpc.SplitFrame(resp); len(frames) != 1 {
+			t.Errorf("%s: invalid response frame", tc.name)
+			continue
+		}
+		f, err := decodeFrame(resp)
+		if err != nil {
+			t.Errorf("%s: decode response error: %v", tc.name, err)
+			continue
+		}
+		if f.header != tc.header || f.message.typeID != MESSAGE_EXCEPTION || f.message.name != "Svc:echo" || rpc.GetStreamID(resp) != "9" {
+			t.Errorf("%s: unexpected response %+v %+v", tc.name, f, f.message)
+		}
+	}
+
+	// binary exception body: message string, type i32
+	resp := rpc.HijackResponse(framed(binaryMessage("echo", MESSAGE_CALL, 1)), 503)
+	f, _ := decodeFrame(resp)
+	r := &reader{data: resp, pos: f.message.seqIDOffset + 4}
+	if r.byte() != 11 || r.byte() != 0 || r.byte() != 1 {
+		t.Fatal("expected field 1 string")
+	}
+	if reason := r.string(int(r.uint32())); reason != "mosn hijack: Service Unavailable" {
+		t.Errorf("unexpected reason %s", reason)
+	}
+	if r.byte() != 8 || r.byte() != 0 || r.byte() != 2 || r.uint32() != EXCEPTION_INTERNAL_ERROR || r.byte() != 0 {
+		t.Error("expected field 2 internal error type")
+	}
+
+	// one way calls have no reply
+	if resp := rpc.HijackResponse(framed(binaryMessage("notify", MESSAGE_ONEWAY, 1)), 404); resp != nil {
+		t.Error("one way call should not be replied")
+	}
+}
+
+func TestProtocolMatch(t *testing.T) {
+	rpc := NewRPCThrift().(*rpcThrift)
+	testCases := []struct {
+		name   string
+		magic  []byte
+		result xprotocol.MatchResult
+	}{
+		{"framed binary", framed(binaryMessage("ping", MESSAGE_CALL, 1)), xprotocol.MatchSuccess},
+		{"framed compact", framed(compactMessage("ping", MESSAGE_CALL, 1)), xprotocol.MatchSuccess},
+		{"header", headerFramed(HEADER_PROTOCOL_BINARY, 1, nil, binaryMessage("ping", MESSAGE_CALL, 1)), xprotocol.MatchSuccess},
+		{"partial", []byte{0, 0, 0, 10, 0x80}, xprotocol.MatchAgain},
+		{"tars", []byte{0, 0, 0, 31, 16, 1, 44, 49}, xprotocol.MatchFailed},
+		{"http", []byte("GET / HTTP/1.1"), xprotocol.MatchFailed},
+	}
+	for _, tc := range testCases {
+		if result := rpc.ProtocolMatch(tc.magic); result != tc.result {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.result, result)
+		}
+	}
+}
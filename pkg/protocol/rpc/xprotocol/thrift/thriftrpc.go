@@ -0,0 +1,190 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol"
+)
+
+// routing metadata keys
+const (
+	SERVICE_KEY = "service"
+	METHOD_KEY  = "method"
+)
+
+func init() {
+	xprotocol.Register("thrift", &pluginThriftFactory{})
+}
+
+type pluginThriftFactory struct{}
+
+func (ref *pluginThriftFactory) CreateSubProtocolCodec(context context.Context) xprotocol.Multiplexing {
+	return NewRPCThrift()
+}
+
+type rpcThrift struct{}
+
+// NewRPCThrift create thrift codec, supports framed and THeader transports with binary and compact protocols
+func NewRPCThrift() xprotocol.Tracing {
+	return &rpcThrift{}
+}
+
+func (r *rpcThrift) SplitFrame(data []byte) [][]byte {
+	var frames [][]byte
+	start := 0
+	dataLen := len(data)
+	for dataLen >= FRAME_SIZE_LEN {
+		size := int(uint32(data[start])<<24 | uint32(data[start+1])<<16 | uint32(data[start+2])<<8 | uint32(data[start+3]))
+		if size > MAX_FRAME_SIZE {
+			log.DefaultLogger.Errorf("[SplitFrame] thrift frame size %d exceeds the limit", size)
+			break
+		}
+		frameLen := FRAME_SIZE_LEN + size
+		if dataLen < frameLen {
+			log.DefaultLogger.Tracef("[SplitFrame] over! remain data len = %d", dataLen)
+			break
+		}
+		frames = append(frames, data[start:start+frameLen])
+		start += frameLen
+		dataLen -= frameLen
+	}
+	return frames
+}
+
+func (r *rpcThrift) GetStreamID(data []byte) string {
+	f, err := decodeFrame(data)
+	if err != nil {
+		log.DefaultLogger.Errorf("[GetStreamID] decode thrift frame error: %v", err)
+		return ""
+	}
+	return strconv.FormatUint(uint64(f.seqID(data)), 10)
+}
+
+func (r *rpcThrift) SetStreamID(data []byte, streamID string) []byte {
+	// the sequence id is 32 bits
+	seqID, err := strconv.ParseUint(streamID, 10, 64)
+	if err != nil {
+		log.DefaultLogger.Errorf("[SetStreamID] invalid stream id %s", streamID)
+		return data
+	}
+	f, err := decodeFrame(data)
+	if err != nil {
+		log.DefaultLogger.Errorf("[SetStreamID] decode thrift frame error: %v", err)
+		return data
+	}
+	return f.setSeqID(data, uint32(seqID))
+}
+
+func (r *rpcThrift) GetServiceName(data []byte) string {
+	service, _ := r.names(data)
+	return service
+}
+
+func (r *rpcThrift) GetMethodName(data []byte) string {
+	_, method := r.names(data)
+	return method
+}
+
+// GetMetas returns the service name, the method name and the THeader key-values
+func (r *rpcThrift) GetMetas(data []byte) map[string]string {
+	f, err := decodeFrame(data)
+	if err != nil {
+		log.DefaultLogger.Errorf("[GetMetas] decode thrift frame error: %v", err)
+		return nil
+	}
+	metas := make(map[string]string, len(f.headers)+2)
+	for k, v := range f.headers {
+		metas[k] = v
+	}
+	service, method := frameNames(f)
+	if service != "" {
+		metas[SERVICE_KEY] = service
+	}
+	if method != "" {
+		metas[METHOD_KEY] = method
+	}
+	return metas
+}
+
+// HijackResponse replies a TApplicationException for the request
+func (r *rpcThrift) HijackResponse(request []byte, statusCode int) []byte {
+	f, err := decodeFrame(request)
+	if err != nil || f.message == nil {
+		return nil
+	}
+	// one way calls have no reply
+	if f.message.typeID == MESSAGE_ONEWAY {
+		return nil
+	}
+	exceptionType := int32(EXCEPTION_INTERNAL_ERROR)
+	if statusCode == http.StatusNotFound {
+		exceptionType = EXCEPTION_UNKNOWN_METHOD
+	}
+	reason := "mosn hijack: " + http.StatusText(statusCode)
+	return encodeException(f, f.seqID(request), exceptionType, reason)
+}
+
+// ProtocolMatch matches the framed binary and compact protocols and the THeader transport,
+// the old binary protocol without version is not detected
+func (r *rpcThrift) ProtocolMatch(magic []byte) xprotocol.MatchResult {
+	// the frame size is less than MAX_FRAME_SIZE, so the first byte is zero
+	if len(magic) > 0 && magic[0] != 0 {
+		return xprotocol.MatchFailed
+	}
+	if len(magic) < FRAME_SIZE_LEN+2 {
+		return xprotocol.MatchAgain
+	}
+	switch {
+	case isHeaderTransport(magic):
+		return xprotocol.MatchSuccess
+	case binary.BigEndian.Uint16(magic[FRAME_SIZE_LEN:]) == BINARY_VERSION_1>>16:
+		return xprotocol.MatchSuccess
+	case magic[FRAME_SIZE_LEN] == COMPACT_PROTOCOL_ID && magic[FRAME_SIZE_LEN+1]&COMPACT_VERSION_MASK == COMPACT_VERSION:
+		return xprotocol.MatchSuccess
+	}
+	return xprotocol.MatchFailed
+}
+
+func (r *rpcThrift) names(data []byte) (string, string) {
+	f, err := decodeFrame(data)
+	if err != nil {
+		return "", ""
+	}
+	return frameNames(f)
+}
+
+// frameNames returns the service name and the method name, the service name of a multiplexed
+// message is the prefix of the message name, or it is read from the THeader
+func frameNames(f *frame) (string, string) {
+	service := f.headers[SERVICE_KEY]
+	if f.message == nil {
+		return service, f.headers[METHOD_KEY]
+	}
+	name := f.message.name
+	if idx := strings.Index(name, multiplexedSeparator); idx >= 0 {
+		return name[:idx], name[idx+1:]
+	}
+	return service, name
+}
@@ -40,6 +40,13 @@ type XCmd interface {
 
 	//ProtocolConvertor
 	Convert(data []byte) (map[string]string, []byte)
+
+	//Heartbeat
+	IsHeartbeat(data []byte) bool
+	HeartbeatResponse(data []byte) []byte
+
+	//Hijacker
+	HijackResponse(request []byte, statusCode int) []byte
 }
 
 // SubProtocol Name
@@ -70,3 +77,35 @@ type ProtocolConvertor interface {
 	Multiplexing
 	Convert(data []byte) (map[string]string, []byte)
 }
+
+// Heartbeat handles the heartbeat frames natively base on Multiplexing, the frames are not proxied
+type Heartbeat interface {
+	Multiplexing
+	IsHeartbeat(data []byte) bool
+	// HeartbeatResponse returns the response of a heartbeat request, nil if no response is needed
+	HeartbeatResponse(data []byte) []byte
+}
+
+// Hijacker builds the reply of a hijacked request base on Multiplexing
+type Hijacker interface {
+	Multiplexing
+	// HijackResponse returns the error response of the request for the status code, nil if no response can be built
+	HijackResponse(request []byte, statusCode int) []byte
+}
+
+// MatchResult is the result of the sub protocol detection
+type MatchResult int
+
+// Sub protocol detection results
+const (
+	MatchFailed MatchResult = iota
+	MatchAgain
+	MatchSuccess
+)
+
+// ProtocolMatcher detects the sub protocol by the first bytes of a connection base on Multiplexing,
+// MatchAgain means more bytes are needed
+type ProtocolMatcher interface {
+	Multiplexing
+	ProtocolMatch(magic []byte) MatchResult
+}
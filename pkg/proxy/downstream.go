@@ -64,13 +64,17 @@ type downStream struct {
 	requestInfo     types.RequestInfo
 	responseSender  types.StreamSender
 	upstreamRequest *upstreamRequest
+	shadow          *shadowRequest
 	perRetryTimer   *utils.Timer
 	responseTimer   *utils.Timer
+	retryTimer      *utils.Timer
 
 	// ~~~ downstream request buf
 	downstreamReqHeaders  types.HeaderMap
 	downstreamReqDataBuf  types.IoBuffer
 	downstreamReqTrailers types.HeaderMap
+	// the streamed request body, the data passes through as it arrives
+	reqBody *bodyPipe
 
 	// ~~~ downstream response buf
 	downstreamRespHeaders  types.HeaderMap
@@ -92,6 +96,8 @@ type downStream struct {
 	directResponse bool
 	// oneway
 	oneway bool
+	// the tunnel of the upgraded connections, the stream ends when the tunnel closed
+	tunnel *upgradeTunnel
 
 	notify chan struct{}
 
@@ -131,6 +137,9 @@ func newActiveStream(ctx context.Context, proxy *proxy, responseSender types.Str
 	stream.proxy = proxy
 	stream.requestInfo = &proxyBuffers.info
 	stream.requestInfo.SetStartTime()
+	if span != nil && trace.IsEnabled() {
+		stream.requestInfo.SetTraceId(span.TraceId())
+	}
 	stream.context = ctx
 	stream.reuseBuffer = 1
 	stream.notify = make(chan struct{}, 1)
@@ -192,6 +201,19 @@ func (s *downStream) cleanStream() {
 	// clean up timers
 	s.cleanUp()
 
+	// stop the streamed bodies
+	if s.reqBody != nil {
+		s.reqBody.close()
+	}
+	if s.upstreamRequest != nil && s.upstreamRequest.respBody != nil {
+		s.upstreamRequest.respBody.close()
+	}
+
+	// the request is finished in the route's retry budget
+	if s.retryState != nil {
+		s.retryState.finish()
+	}
+
 	// tell filters it's time to destroy
 	for _, ef := range s.senderFilters {
 		ef.filter.OnDestroy()
@@ -362,7 +384,7 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 				if log.Proxy.GetLogLevel() >= log.DEBUG {
 					log.Proxy.Debugf(s.context, "[proxy] [downstream] enter phase %d, proxyId = %d  ", phase, id)
 				}
-				s.receiveHeaders(s.downstreamReqDataBuf == nil && s.downstreamReqTrailers == nil)
+				s.receiveHeaders(s.downstreamReqDataBuf == nil && s.downstreamReqTrailers == nil && s.reqBody == nil)
 
 				if p, err := s.processError(id); err != nil {
 					return p
@@ -379,6 +401,15 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 				s.downstreamReqDataBuf.Count(1)
 				s.receiveData(s.downstreamReqTrailers == nil)
 
+				if p, err := s.processError(id); err != nil {
+					return p
+				}
+			} else if s.reqBody != nil {
+				if log.Proxy.GetLogLevel() >= log.DEBUG {
+					log.Proxy.Debugf(s.context, "[proxy] [downstream] enter phase %d, stream request body, proxyId = %d  ", phase, id)
+				}
+				s.streamRequestBody()
+
 				if p, err := s.processError(id); err != nil {
 					return p
 				}
@@ -422,6 +453,11 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 				log.Proxy.Debugf(s.context, "[proxy] [downstream] enter phase %d, proxyId = %d  ", phase, id)
 			}
 
+			// wait for the back off, the global timeout or reset stops the retry
+			if p, err := s.waitRetryBackOff(id); err != nil {
+				return p
+			}
+
 			if s.downstreamReqDataBuf != nil {
 				s.downstreamReqDataBuf.Count(1)
 			}
@@ -475,7 +511,7 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 				if log.Proxy.GetLogLevel() >= log.DEBUG {
 					log.Proxy.Debugf(s.context, "[proxy] [downstream] enter phase %d, proxyId = %d  ", phase, id)
 				}
-				s.upstreamRequest.receiveHeaders(s.downstreamRespDataBuf == nil && s.downstreamRespTrailers == nil)
+				s.upstreamRequest.receiveHeaders(s.downstreamRespDataBuf == nil && s.downstreamRespTrailers == nil && s.upstreamRequest.respBody == nil)
 
 				if p, err := s.processError(id); err != nil {
 					return p
@@ -491,6 +527,15 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 				}
 				s.upstreamRequest.receiveData(s.downstreamRespTrailers == nil)
 
+				if p, err := s.processError(id); err != nil {
+					return p
+				}
+			} else if s.upstreamRequest.respBody != nil {
+				if log.Proxy.GetLogLevel() >= log.DEBUG {
+					log.Proxy.Debugf(s.context, "[proxy] [downstream] enter phase %d, stream response body, proxyId = %d  ", phase, id)
+				}
+				s.streamResponseBody()
+
 				if p, err := s.processError(id); err != nil {
 					return p
 				}
@@ -602,6 +647,14 @@ func (s *downStream) receiveHeaders(endStream bool) {
 		}
 		return
 	}
+	// check if route have redirect, redirect will response now
+	if redirect := s.route.RedirectRule(); redirect != nil {
+		location := redirect.RedirectLocation(s.downstreamReqHeaders)
+		log.Proxy.Infof(s.context, "[proxy] [downstream] redirect response, proxyId = %d, location = %s", s.ID, location)
+		s.downstreamReqHeaders.Set(http.HeaderLocation, location)
+		s.sendHijackReply(redirect.RedirectCode(), s.downstreamReqHeaders)
+		return
+	}
 	// not direct response, needs a cluster snapshot and route rule
 	if rule := s.route.RouteRule(); rule == nil || reflect.ValueOf(rule).IsNil() {
 		log.Proxy.Warnf(s.context, "[proxy] [downstream] no route rule to init upstream, headers = %v", s.downstreamReqHeaders)
@@ -624,6 +677,9 @@ func (s *downStream) receiveHeaders(endStream bool) {
 	}
 
 	s.cluster = s.snapshot.ClusterInfo()
+	// the routing priority decides the cluster's resources used by the upstream request
+	priority := s.route.RouteRule().Priority()
+	s.context = mosnctx.WithValue(s.context, types.ContextKeyRoutingPriority, priority)
 
 	s.requestInfo.SetRouteEntry(s.route.RouteRule())
 	s.requestInfo.SetDownstreamLocalAddress(s.proxy.readCallbacks.Connection().LocalAddr())
@@ -645,7 +701,7 @@ func (s *downStream) receiveHeaders(endStream bool) {
 
 	prot := s.getUpstreamProtocol()
 
-	s.retryState = newRetryState(s.route.RouteRule().Policy().RetryPolicy(), s.downstreamReqHeaders, s.cluster, prot)
+	s.retryState = newRetryState(s.route.RouteRule().Policy().RetryPolicy(), s.downstreamReqHeaders, s.cluster, priority, prot)
 
 	//Build Request
 	proxyBuffers := proxyBuffersByContext(s.context)
@@ -655,6 +711,7 @@ func (s *downStream) receiveHeaders(endStream bool) {
 	s.upstreamRequest.protocol = prot
 	s.upstreamRequest.connPool = pool
 	s.route.RouteRule().FinalizeRequestHeaders(s.downstreamReqHeaders, s.requestInfo)
+	s.shadow = s.newShadowRequest()
 
 	//Call upstream's append header method to build upstream's request
 	s.upstreamRequest.appendHeaders(endStream)
@@ -728,7 +785,11 @@ func (s *downStream) onUpstreamRequestSent() {
 	s.upstreamRequestSent = true
 	s.requestInfo.SetRequestReceivedDuration(time.Now())
 
-	if s.upstreamRequest != nil && !s.oneway {
+	// mirror the request to the shadow cluster
+	s.startShadow()
+
+	// the timers are stopped once the response starts, a streamed request body may end after that
+	if s.upstreamRequest != nil && !s.oneway && !s.downstreamResponseStarted {
 		// setup per req timeout timer
 		s.setupPerReqTimeout()
 
@@ -771,6 +832,8 @@ func (s *downStream) onResponseTimeout() {
 		}
 
 		s.upstreamRequest.resetStream()
+		// the retry waiting for the back off is given up
+		s.upstreamRequest.setupRetry = false
 		s.upstreamRequest.OnResetStream(types.UpstreamGlobalTimeout)
 	}
 }
@@ -850,7 +913,7 @@ func (s *downStream) appendHeaders(endStream bool) {
 		log.Proxy.Alertf(s.context, types.ErrorKeyAppendHeader, "append headers error: %s", err)
 	}
 
-	if endStream {
+	if endStream && s.tunnel == nil {
 		s.endStream()
 	}
 }
@@ -936,7 +999,7 @@ func (s *downStream) onUpstreamReset(reason types.StreamResetReason) {
 	// see if we need a retry
 	if reason != types.UpstreamGlobalTimeout &&
 		!s.downstreamResponseStarted && s.retryState != nil {
-		retryCheck := s.retryState.retry(nil, reason)
+		retryCheck := s.retryState.retry(nil, nil, reason)
 
 		if retryCheck == types.ShouldRetry && s.setupRetry(true) {
 			if s.upstreamRequest != nil && s.upstreamRequest.host != nil {
@@ -990,7 +1053,7 @@ func (s *downStream) onUpstreamHeaders(endStream bool) {
 
 	// check retry
 	if s.retryState != nil {
-		retryCheck := s.retryState.retry(headers, "")
+		retryCheck := s.retryState.retry(headers, s.downstreamRespTrailers, "")
 
 		if retryCheck == types.ShouldRetry && s.setupRetry(endStream) {
 			if s.upstreamRequest != nil && s.upstreamRequest.host != nil {
@@ -1015,12 +1078,57 @@ func (s *downStream) onUpstreamHeaders(endStream bool) {
 		s.route.RouteRule().FinalizeResponseHeaders(headers, s.requestInfo)
 	}
 
+	// the upstream accepts the protocol upgrade, such as WebSocket
+	if s.requestInfo.ResponseCode() == http.SwitchingProtocols && !s.setupTunnel() {
+		s.sendHijackReply(types.NoHealthUpstreamCode, s.downstreamReqHeaders)
+		return
+	}
+
 	if endStream {
 		s.onUpstreamResponseRecvFinished()
 	}
 
 	// todo: insert proxy headers
 	s.appendHeaders(endStream)
+
+	// the upgrade response is sent, starts forwarding raw bytes
+	if s.tunnel != nil {
+		s.tunnel.start()
+	}
+}
+
+// setupTunnel turns the downstream and upstream connections into a raw byte tunnel.
+// both sides should support the upgrade, or the upgraded upstream connection is closed
+// and false is returned.
+func (s *downStream) setupTunnel() bool {
+	if s.upstreamRequest == nil {
+		return false
+	}
+	upstream, ok := s.upstreamRequest.requestSender.(types.UpgradableStream)
+	if !ok {
+		log.Proxy.Warnf(s.context, "[proxy] [downstream] upstream can not be upgraded, proxyId = %d", s.ID)
+		return false
+	}
+
+	tunnel := newUpgradeTunnel(s)
+	tunnel.upstream = upstream.Upgrade(&tunnelListener{tunnel: tunnel})
+
+	downstream, ok := s.responseSender.(types.UpgradableStream)
+	if !ok || !http.IsUpgradeRequest(s.downstreamReqHeaders) {
+		log.Proxy.Warnf(s.context, "[proxy] [downstream] downstream can not be upgraded, close the upstream, proxyId = %d", s.ID)
+		tunnel.upstream.Close()
+		return false
+	}
+	tunnel.downstream = downstream.Upgrade(&tunnelListener{tunnel: tunnel, downstream: true})
+
+	// the stream buffers are held by the tunnel, do not reuse them
+	atomic.StoreUint32(&s.reuseBuffer, 0)
+	s.tunnel = tunnel
+
+	if log.Proxy.GetLogLevel() >= log.INFO {
+		log.Proxy.Infof(s.context, "[proxy] [downstream] connections upgraded to a tunnel, proxyId = %d", s.ID)
+	}
+	return true
 }
 
 func (s *downStream) handleUpstreamStatusCode() {
@@ -1082,7 +1190,17 @@ func (s *downStream) onUpstreamResponseRecvFinished() {
 }
 
 func (s *downStream) setupRetry(endStream bool) bool {
+	// the streamed request body is not kept for the retry
+	if s.reqBody != nil {
+		return false
+	}
+	// drop the streamed response of the failed try
+	if s.upstreamRequest.respBody != nil {
+		s.upstreamRequest.respBody.close()
+	}
 	s.upstreamRequest.setupRetry = true
+	s.retryState.onHostAttempted(s.upstreamRequest.host)
+	s.requestInfo.SetRetryCount(s.requestInfo.RetryCount() + 1)
 
 	if !endStream {
 		s.upstreamRequest.resetStream()
@@ -1098,10 +1216,29 @@ func (s *downStream) setupRetry(endStream bool) bool {
 }
 
 // Note: retry-timer MUST be stopped before active stream got recycled, otherwise resetting stream's properties will cause panic here
-func (s *downStream) doRetry() {
-	// retry interval
-	time.Sleep(10 * time.Millisecond)
+// waitRetryBackOff waits for the jittered exponential back off before the retry.
+// the downstream is notified by the back off timer, or by the global timeout and the
+// downstream reset during the back off, which stop the retry.
+func (s *downStream) waitRetryBackOff(id uint32) (phase types.Phase, err error) {
+	backOff := s.retryState.nextBackOff()
+	if backOff <= 0 {
+		return
+	}
 
+	timer := utils.NewTimer(backOff, s.sendNotify)
+	s.retryTimer = timer
+	<-s.notify
+	timer.Stop()
+
+	// back off ends
+	if s.upstreamRequest != nil && s.upstreamRequest.setupRetry &&
+		atomic.LoadUint32(&s.downstreamReset) == 0 {
+		return
+	}
+	return s.processError(id)
+}
+
+func (s *downStream) doRetry() {
 	// no reuse buffer
 	atomic.StoreUint32(&s.reuseBuffer, 0)
 
@@ -1206,6 +1343,11 @@ func (s *downStream) cleanUp() {
 		s.responseTimer = nil
 	}
 
+	// reset retry back off timer
+	if s.retryTimer != nil {
+		s.retryTimer.Stop()
+		s.retryTimer = nil
+	}
 }
 
 func (s *downStream) setBufferLimit(bufferLimit uint32) {
@@ -1255,6 +1397,29 @@ func (s *downStream) DownstreamContext() context.Context {
 	return s.context
 }
 
+func (s *downStream) ComputeHashKey() (uint64, bool) {
+	route := s.requestInfo.RouteEntry()
+	if route == nil || route.Policy() == nil {
+		return 0, false
+	}
+	hashPolicy := route.Policy().HashPolicy()
+	if hashPolicy == nil {
+		return 0, false
+	}
+	return hashPolicy.GenerateHash(s.downstreamReqHeaders, s.proxy.readCallbacks.Connection().RemoteAddr())
+}
+
+func (s *downStream) HostSelectionRetryCount() uint32 {
+	if s.retryState == nil {
+		return 0
+	}
+	return s.retryState.hostSelectionMaxAttempts
+}
+
+func (s *downStream) ShouldSelectAnotherHost(host types.Host) bool {
+	return s.retryState != nil && s.retryState.hostAttempted(host)
+}
+
 func (s *downStream) giveStream() {
 	if atomic.LoadUint32(&s.reuseBuffer) != 1 {
 		return
@@ -26,6 +26,7 @@ import (
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
 	"sofastack.io/sofa-mosn/pkg/trace"
 	"sofastack.io/sofa-mosn/pkg/types"
 
@@ -148,6 +149,45 @@ func TestDirectResponse(t *testing.T) {
 	}
 }
 
+func TestRedirectResponse(t *testing.T) {
+	client := &mockResponseSender{}
+	s := &downStream{
+		proxy: &proxy{
+			config: &v2.Proxy{},
+			routersWrapper: &mockRouterWrapper{
+				routers: &mockRouters{
+					route: &mockRoute{
+						redirect: &mockRedirectRule{
+							code:     302,
+							location: "https://www.example.com/index.html",
+						},
+					},
+				},
+			},
+			clusterManager: &mockClusterManager{},
+			readCallbacks:  &mockReadFilterCallbacks{},
+			stats:          globalStats,
+			listenerStats:  newListenerStats("test"),
+		},
+		responseSender: client,
+		requestInfo:    &network.RequestInfo{},
+	}
+	// event call Receive Headers
+	// trigger redirect response
+	s.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil)
+	// check
+	time.Sleep(100 * time.Millisecond)
+	if client.headers == nil {
+		t.Fatal("want to receive a header response")
+	}
+	if code, ok := client.headers.Get(types.HeaderStatus); !ok || code != "302" {
+		t.Error("response status code not expected")
+	}
+	if location, ok := client.headers.Get(http.HeaderLocation); !ok || location != "https://www.example.com/index.html" {
+		t.Error("response location not expected")
+	}
+}
+
 func TestOnewayHijack(t *testing.T) {
 	initGlobalStats()
 	proxy := &proxy{
@@ -19,6 +19,7 @@ package proxy
 
 import (
 	"context"
+	"sync"
 
 	"sofastack.io/sofa-mosn/pkg/types"
 	"time"
@@ -62,7 +63,8 @@ func (r *mockRouters) MatchRoute(types.HeaderMap, uint64) types.Route {
 type mockRoute struct {
 	types.Route
 	rule   types.RouteRule
-	direct types.DirectResponseRule
+	direct   types.DirectResponseRule
+	redirect types.RedirectRule
 }
 
 func (r *mockRoute) RouteRule() types.RouteRule {
@@ -79,8 +81,20 @@ func (r *mockRoute) DirectResponseRule() types.DirectResponseRule {
 	return nil
 }
 
+func (r *mockRoute) RedirectRule() types.RedirectRule {
+	if r.redirect != nil {
+		return r.redirect
+	}
+	return nil
+}
+
 type mockRouteRule struct {
 	types.RouteRule
+	policy types.Policy
+}
+
+func (r *mockRouteRule) Policy() types.Policy {
+	return r.policy
 }
 
 func (r *mockRouteRule) ClusterName() string {
@@ -91,10 +105,27 @@ func (r *mockRouteRule) UpstreamProtocol() string {
 	return ""
 }
 
+func (r *mockRouteRule) AutoHostRewrite() bool {
+	return false
+}
+
 func (c *mockRouteRule) FinalizeResponseHeaders(headers types.HeaderMap, requestInfo types.RequestInfo) {
 	return
 }
 
+type mockRedirectRule struct {
+	code     int
+	location string
+}
+
+func (r *mockRedirectRule) RedirectCode() int {
+	return r.code
+}
+
+func (r *mockRedirectRule) RedirectLocation(headers types.HeaderMap) string {
+	return r.location
+}
+
 type mockDirectRule struct {
 	status int
 	body   string
@@ -110,16 +141,69 @@ func (r *mockDirectRule) Body() string {
 
 type mockClusterManager struct {
 	types.ClusterManager
+	snapshot types.ClusterSnapshot
+	pool     types.ConnectionPool
 }
 
 func (m *mockClusterManager) GetClusterSnapshot(ctx context.Context, name string) types.ClusterSnapshot {
+	if m.snapshot != nil {
+		return m.snapshot
+	}
 	return &mockClusterSnapshot{}
 }
+
+func (m *mockClusterManager) ConnPoolForCluster(balancerContext types.LoadBalancerContext, snapshot types.ClusterSnapshot, protocol types.Protocol) types.ConnectionPool {
+	return m.pool
+}
 func (m *mockClusterManager) PutClusterSnapshot(snapshot types.ClusterSnapshot) {
 }
 
 type mockClusterSnapshot struct {
 	types.ClusterSnapshot
+	info types.ClusterInfo
+}
+
+func (s *mockClusterSnapshot) ClusterInfo() types.ClusterInfo {
+	return s.info
+}
+
+type mockClusterInfo struct {
+	types.ClusterInfo
+	name  string
+	stats types.ClusterStats
+}
+
+func (ci *mockClusterInfo) Name() string {
+	return ci.name
+}
+
+func (ci *mockClusterInfo) Stats() types.ClusterStats {
+	return ci.stats
+}
+
+// mockConnPool calls OnReady with a mockRequestSender that records the request
+type mockConnPool struct {
+	types.ConnectionPool
+	sender *mockRequestSender
+}
+
+func (p *mockConnPool) NewStream(ctx context.Context, receiver types.StreamReceiveListener, listener types.PoolEventListener) {
+	listener.OnReady(p.sender, nil)
+}
+
+type mockRequestSender struct {
+	mockResponseSender
+	endStream bool
+}
+
+func (s *mockRequestSender) AppendHeaders(ctx context.Context, headers types.HeaderMap, endStream bool) error {
+	s.endStream = endStream
+	return s.mockResponseSender.AppendHeaders(ctx, headers, endStream)
+}
+
+func (s *mockRequestSender) AppendData(ctx context.Context, data types.IoBuffer, endStream bool) error {
+	s.endStream = endStream
+	return s.mockResponseSender.AppendData(ctx, data, endStream)
 }
 
 type mockResponseSender struct {
@@ -156,6 +240,12 @@ func (s *mockStream) ResetStream(reason types.StreamResetReason) {
 	// do nothing
 }
 
+func (s *mockStream) AddEventListener(listener types.StreamEventListener) {
+}
+
+func (s *mockStream) RemoveEventListener(listener types.StreamEventListener) {
+}
+
 type mockReadFilterCallbacks struct {
 	types.ReadFilterCallbacks
 }
@@ -219,3 +309,44 @@ func (s *mockSpan) InjectContext(requestHeaders types.HeaderMap) {
 func (s *mockSpan) SpawnChild(operationName string, startTime time.Time) types.Span {
 	return nil
 }
+
+type mockStreamTunnel struct {
+	mux     sync.Mutex
+	data    []byte
+	started bool
+	closed  bool
+}
+
+func (t *mockStreamTunnel) Start() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.started = true
+}
+
+func (t *mockStreamTunnel) Write(data types.IoBuffer) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.data = append(t.data, data.Bytes()...)
+	return nil
+}
+
+func (t *mockStreamTunnel) Close() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.closed = true
+}
+
+func (t *mockStreamTunnel) isClosed() bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.closed
+}
+
+type mockUpgradableRequestSender struct {
+	mockRequestSender
+	tunnel *mockStreamTunnel
+}
+
+func (s *mockUpgradableRequestSender) Upgrade(listener types.TunnelListener) types.StreamTunnel {
+	return s.tunnel
+}
@@ -23,6 +23,7 @@ import (
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
@@ -35,10 +36,21 @@ import (
 	"sofastack.io/sofa-mosn/pkg/stream"
 	mosnsync "sofastack.io/sofa-mosn/pkg/sync"
 	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
 )
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
+// defaultProtocolDetectTimeout is the time waiting for the first bytes to detect the downstream protocol
+const defaultProtocolDetectTimeout = 10 * time.Second
+
+// protocol detection states of the Auto downstream protocol
+const (
+	protocolDetecting uint32 = iota
+	protocolDetected
+	protocolDetectTimeout
+)
+
 var (
 	globalStats *Stats
 
@@ -88,6 +100,8 @@ type proxy struct {
 	stats              *Stats
 	listenerStats      *Stats
 	accessLogs         []types.AccessLog
+	detectState        uint32
+	detectTimer        *utils.Timer
 }
 
 // NewProxy create proxy instance for given v2.Proxy config
@@ -112,6 +126,11 @@ func NewProxy(ctx context.Context, config *v2.Proxy, clusterManager types.Cluste
 		log.DefaultLogger.Errorf("[proxy] get proxy extend config fail = %v", err)
 	}
 
+	// the stream connections pass the bodies through as they arrive
+	if proxy.config.StreamingConfig != nil {
+		proxy.context = mosnctx.WithValue(proxy.context, types.ContextKeyStreamBody, true)
+	}
+
 	listenerName := mosnctx.Get(ctx, types.ContextKeyListenerName).(string)
 	proxy.listenerStats = newListenerStats(listenerName)
 
@@ -145,9 +164,13 @@ func (p *proxy) OnData(buf types.IoBuffer) types.FilterStatus {
 				size = buf.Len()
 			}
 			log.DefaultLogger.Errorf("[proxy] Protocol Auto error magic :%v", buf.Bytes()[:size])
+			p.detectTimer.Stop()
 			p.readCallbacks.Connection().Close(types.NoFlush, types.OnReadErrClose)
 			return types.Stop
 		}
+		if !p.onProtocolDetected() {
+			return types.Stop
+		}
 		log.DefaultLogger.Debugf("[proxy] Protoctol Auto: %v", protocol)
 		p.serverStreamConn = stream.CreateServerStreamConnection(p.context, protocol, p.readCallbacks.Connection(), p)
 	}
@@ -156,9 +179,36 @@ func (p *proxy) OnData(buf types.IoBuffer) types.FilterStatus {
 	return types.Stop
 }
 
+// startProtocolDetect closes the connection if the downstream protocol is not detected in time,
+// so the clients that never send enough bytes are not kept
+func (p *proxy) startProtocolDetect() {
+	timeout := defaultProtocolDetectTimeout
+	if p.config.ProtocolDetectTimeout != nil {
+		timeout = p.config.ProtocolDetectTimeout.Duration
+	}
+	if timeout <= 0 {
+		return
+	}
+	p.detectTimer = utils.NewTimer(timeout, p.onProtocolDetectTimeout)
+}
+
+// onProtocolDetected returns false if the detection is timeout already
+func (p *proxy) onProtocolDetected() bool {
+	p.detectTimer.Stop()
+	return atomic.CompareAndSwapUint32(&p.detectState, protocolDetecting, protocolDetected)
+}
+
+func (p *proxy) onProtocolDetectTimeout() {
+	if atomic.CompareAndSwapUint32(&p.detectState, protocolDetecting, protocolDetectTimeout) {
+		log.DefaultLogger.Errorf("[proxy] Protocol Auto detect timeout, remote addr: %v", p.readCallbacks.Connection().RemoteAddr())
+		p.readCallbacks.Connection().Close(types.NoFlush, types.LocalClose)
+	}
+}
+
 //rpc realize upstream on event
 func (p *proxy) onDownstreamEvent(event types.ConnectionEvent) {
 	if event.IsClose() {
+		p.detectTimer.Stop()
 		p.stats.DownstreamConnectionDestroy.Inc(1)
 		p.stats.DownstreamConnectionActive.Dec(1)
 		p.listenerStats.DownstreamConnectionDestroy.Inc(1)
@@ -199,6 +249,8 @@ func (p *proxy) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {
 	p.readCallbacks.Connection().AddConnectionEventListener(p.downstreamListener)
 	if p.config.DownstreamProtocol != string(protocol.Auto) {
 		p.serverStreamConn = stream.CreateServerStreamConnection(p.context, types.Protocol(p.config.DownstreamProtocol), p.readCallbacks.Connection(), p)
+	} else {
+		p.startProtocolDetect()
 	}
 }
 
@@ -243,7 +295,7 @@ func (p *proxy) streamResetReasonToResponseFlag(reason types.StreamResetReason)
 		return types.UpstreamLocalReset
 	case types.StreamOverflow:
 		return types.UpstreamOverflow
-	case types.StreamRemoteReset:
+	case types.StreamRemoteReset, types.StreamRefusedReset:
 		return types.UpstreamRemoteReset
 	}
 
@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type mockDetectConnection struct {
+	types.Connection
+	mutex  sync.Mutex
+	closed bool
+}
+
+func (c *mockDetectConnection) Close(ccType types.ConnectionCloseType, eventType types.ConnectionEvent) error {
+	c.mutex.Lock()
+	c.closed = true
+	c.mutex.Unlock()
+	return nil
+}
+
+func (c *mockDetectConnection) isClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+func (c *mockDetectConnection) RemoteAddr() net.Addr {
+	return nil
+}
+
+type mockDetectReadFilterCallbacks struct {
+	types.ReadFilterCallbacks
+	conn types.Connection
+}
+
+func (cb *mockDetectReadFilterCallbacks) Connection() types.Connection {
+	return cb.conn
+}
+
+func newDetectProxy(timeout time.Duration) (*proxy, *mockDetectConnection) {
+	conn := &mockDetectConnection{}
+	p := &proxy{
+		config: &v2.Proxy{
+			DownstreamProtocol:    "Auto",
+			ProtocolDetectTimeout: &v2.DurationConfig{Duration: timeout},
+		},
+		readCallbacks: &mockDetectReadFilterCallbacks{conn: conn},
+	}
+	return p, conn
+}
+
+func TestProtocolDetectTimeout(t *testing.T) {
+	p, conn := newDetectProxy(20 * time.Millisecond)
+	p.startProtocolDetect()
+	time.Sleep(100 * time.Millisecond)
+	if !conn.isClosed() {
+		t.Error("connection should be closed if the protocol is not detected in time")
+	}
+	// the protocol detected after timeout is ignored
+	if p.onProtocolDetected() {
+		t.Error("protocol detected after timeout should be ignored")
+	}
+}
+
+func TestProtocolDetected(t *testing.T) {
+	p, conn := newDetectProxy(20 * time.Millisecond)
+	p.startProtocolDetect()
+	if !p.onProtocolDetected() {
+		t.Fatal("protocol should be detected before timeout")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if conn.isClosed() {
+		t.Error("connection should not be closed after the protocol detected")
+	}
+	// the timeout callback is no-op once the protocol is detected
+	p.onProtocolDetectTimeout()
+	if conn.isClosed() {
+		t.Error("connection should not be closed after the protocol detected")
+	}
+}
+
+func TestProtocolDetectDisabled(t *testing.T) {
+	p, _ := newDetectProxy(0)
+	p.startProtocolDetect()
+	if p.detectTimer != nil {
+		t.Error("zero timeout should disable the detect timer")
+	}
+}
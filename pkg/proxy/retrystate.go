@@ -18,110 +18,260 @@
 package proxy
 
 import (
-	"sofastack.io/sofa-mosn/pkg/protocol"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
 	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
+const (
+	// defaultNumRetries is used if the retry policy's NumRetries is not set
+	defaultNumRetries = 3
+	// defaultBackOffBaseInterval is same as envoy, the default max interval is 10 times of the base interval
+	defaultBackOffBaseInterval = 25 * time.Millisecond
+)
+
 type retryState struct {
 	retryPolicy      types.RetryPolicy
 	requestHeaders   types.HeaderMap // TODO: support retry policy by header
 	cluster          types.ClusterInfo
+	resourceManager  types.ResourceManager // the cluster's resource manager of the routing priority
 	retryOn          bool
+	conditions       types.RetryCondition
 	retiesRemaining  uint32
 	upstreamProtocol types.Protocol
+
+	// ~~~ back off
+	backOffBaseInterval time.Duration
+	backOffMaxInterval  time.Duration
+	backOffAttempts     uint32
+
+	// ~~~ host selection retry
+	hostSelectionMaxAttempts uint32
+	attemptedHosts           map[string]struct{}
+
+	// ~~~ retry budget
+	budget         types.RetryBudget
+	budgetAcquired uint32
+	requestActive  uint32
 }
 
 func newRetryState(retryPolicy types.RetryPolicy,
-	requestHeaders types.HeaderMap, cluster types.ClusterInfo, proto types.Protocol) *retryState {
+	requestHeaders types.HeaderMap, cluster types.ClusterInfo, priority types.ResourcePriority, proto types.Protocol) *retryState {
 	rs := &retryState{
-		retryPolicy:      retryPolicy,
-		requestHeaders:   requestHeaders,
-		cluster:          cluster,
-		retryOn:          retryPolicy.RetryOn(),
-		retiesRemaining:  3,
-		upstreamProtocol: proto,
+		retryPolicy:              retryPolicy,
+		requestHeaders:           requestHeaders,
+		cluster:                  cluster,
+		resourceManager:          cluster.PriorityResourceManager(priority),
+		retryOn:                  retryPolicy.RetryOn(),
+		conditions:               retryPolicy.RetryConditions(),
+		retiesRemaining:          retryPolicy.NumRetries(),
+		upstreamProtocol:         proto,
+		hostSelectionMaxAttempts: retryPolicy.HostSelectionRetryMaxAttempts(),
+		budget:                   retryPolicy.RetryBudget(),
+	}
+
+	// the retry budget is based on the active requests of the route
+	if rs.budget != nil {
+		rs.budget.OnRequestStart()
+		rs.requestActive = 1
 	}
 
-	if retryPolicy.NumRetries() > rs.retiesRemaining {
-		rs.retiesRemaining = retryPolicy.NumRetries()
+	if rs.retiesRemaining == 0 {
+		rs.retiesRemaining = defaultNumRetries
+	}
+	// retry on 5xx by default
+	if rs.conditions == 0 {
+		rs.conditions = types.RetryOn5xx
+	}
+
+	rs.backOffBaseInterval, rs.backOffMaxInterval = retryPolicy.RetryBackOff()
+	if rs.backOffBaseInterval <= 0 {
+		rs.backOffBaseInterval = defaultBackOffBaseInterval
+	}
+	if rs.backOffMaxInterval <= 0 {
+		rs.backOffMaxInterval = 10 * rs.backOffBaseInterval
+	}
+	if rs.backOffMaxInterval < rs.backOffBaseInterval {
+		rs.backOffMaxInterval = rs.backOffBaseInterval
 	}
 
 	return rs
 }
 
-func (r *retryState) retry(headers types.HeaderMap, reason types.StreamResetReason) types.RetryCheckStatus {
+func (r *retryState) retry(headers, trailers types.HeaderMap, reason types.StreamResetReason) types.RetryCheckStatus {
 	r.reset()
 
-	check := r.shouldRetry(headers, reason)
+	check := r.shouldRetry(headers, trailers, reason)
 
 	if check != 0 {
 		return check
 	}
 
-	r.cluster.ResourceManager().Retries().Increase()
+	r.resourceManager.Retries().Increase()
 	r.cluster.Stats().UpstreamRequestRetry.Inc(1)
 
 	return 0
 }
 
-func (r *retryState) shouldRetry(headers types.HeaderMap, reason types.StreamResetReason) types.RetryCheckStatus {
+func (r *retryState) shouldRetry(headers, trailers types.HeaderMap, reason types.StreamResetReason) types.RetryCheckStatus {
 	if r.retiesRemaining == 0 {
 		return types.NoRetry
 	}
 
 	r.retiesRemaining--
 
-	if !r.doRetryCheck(headers, reason) {
+	if !r.doRetryCheck(headers, trailers, reason) {
 		return types.NoRetry
 	}
 
-	if !r.cluster.ResourceManager().Retries().CanCreate() {
+	if !r.resourceManager.Retries().CanCreate() {
 		r.cluster.Stats().UpstreamRequestRetryOverflow.Inc(1)
 
 		return types.RetryOverflow
 	}
 
+	if r.budget != nil {
+		if !r.budget.TryAcquire() {
+			r.cluster.Stats().UpstreamRequestRetryOverflow.Inc(1)
+
+			return types.RetryOverflow
+		}
+		atomic.StoreUint32(&r.budgetAcquired, 1)
+	}
+
 	return types.ShouldRetry
 }
 
-func (r *retryState) doRetryCheck(headers types.HeaderMap, reason types.StreamResetReason) bool {
+func (r *retryState) doRetryCheck(headers, trailers types.HeaderMap, reason types.StreamResetReason) bool {
 	if reason == types.StreamOverflow {
 		return false
 	}
 
-	if r.retryOn {
-		// TODO: add retry policy to decide retry or not. use default policy now
-		if headers != nil {
-			// default policy , mapping all headers to http status code
-			code, err := protocol.MappingHeaderStatusCode(r.upstreamProtocol, headers)
-			if err == nil {
-				// todo: support config?
-				return code >= http.InternalServerError
-			}
+	if !r.retryOn {
+		// default support connectionFailed retry
+		return reason == types.StreamConnectionFailed
+	}
+
+	if headers != nil {
+		return r.checkResponse(headers, trailers)
+	}
+
+	return r.checkReset(reason)
+}
+
+// checkResponse checks the upstream response matches the retry conditions or not
+func (r *retryState) checkResponse(headers, trailers types.HeaderMap) bool {
+	// mapping all headers to http status code, including the grpc-status in the trailers
+	if code, err := responseStatusCode(r.upstreamProtocol, headers, trailers); err == nil {
+		if r.conditions&types.RetryOn5xx != 0 && code >= http.InternalServerError {
+			return true
+		}
+		if r.conditions&types.RetryOnGatewayError != 0 &&
+			(code == http.BadGateway || code == http.ServiceUnavailable || code == http.GatewayTimeout) {
+			return true
 		}
-		if reason == types.StreamConnectionFailed {
+		if r.conditions&types.RetryOnRetriableStatusCodes != 0 &&
+			containsStatus(r.retryPolicy.RetriableStatusCodes(), uint32(code)) {
 			return true
 		}
+	}
 
-		if reason == types.UpstreamPerTryTimeout {
+	if r.conditions&types.RetryOnRetriableSofaRpcStatus != 0 {
+		if resp, ok := headers.(rpc.RespStatus); ok && containsStatus(r.retryPolicy.RetriableSofaRpcStatus(), resp.RespStatus()) {
 			return true
 		}
+	}
+
+	return false
+}
 
-		if reason == types.StreamConnectionTermination {
+// checkReset checks the upstream reset matches the retry conditions or not
+// a reset is counted as a 5xx, same as envoy
+func (r *retryState) checkReset(reason types.StreamResetReason) bool {
+	if r.conditions&(types.RetryOn5xx|types.RetryOnGatewayError) != 0 {
+		switch reason {
+		case types.StreamConnectionFailed, types.StreamConnectionTermination, types.StreamRemoteReset,
+			types.StreamRefusedReset, types.UpstreamPerTryTimeout:
 			return true
 		}
-		// more policy
-	} else {
-		// default support connectionFailed retry
-		if reason == types.StreamConnectionFailed {
+	}
+
+	if r.conditions&types.RetryOnReset != 0 {
+		switch reason {
+		case types.StreamConnectionFailed, types.StreamConnectionTermination, types.StreamRemoteReset,
+			types.StreamRefusedReset, types.UpstreamPerTryTimeout:
 			return true
 		}
 	}
 
+	switch reason {
+	case types.StreamConnectionFailed:
+		return r.conditions&types.RetryOnConnectFailure != 0
+	case types.StreamRefusedReset:
+		return r.conditions&types.RetryOnRefusedStream != 0
+	}
+
+	return false
+}
+
+func containsStatus(statuses []uint32, status uint32) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
 	return false
 }
 
+// nextBackOff returns a random interval in [0, (2^n - 1) * base) before the n-th retry, the upper bound is limited by the max interval
+func (r *retryState) nextBackOff() time.Duration {
+	r.backOffAttempts++
+
+	ceiling := r.backOffBaseInterval
+	for i := uint32(1); i < r.backOffAttempts && ceiling < r.backOffMaxInterval; i++ {
+		ceiling = 2*ceiling + r.backOffBaseInterval
+	}
+	if ceiling > r.backOffMaxInterval {
+		ceiling = r.backOffMaxInterval
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// onHostAttempted records the attempted host, the host selection of retry will try to avoid it
+func (r *retryState) onHostAttempted(host types.Host) {
+	if r.hostSelectionMaxAttempts == 0 || host == nil {
+		return
+	}
+	if r.attemptedHosts == nil {
+		r.attemptedHosts = make(map[string]struct{})
+	}
+	r.attemptedHosts[host.AddressString()] = struct{}{}
+}
+
+func (r *retryState) hostAttempted(host types.Host) bool {
+	if r.attemptedHosts == nil || host == nil {
+		return false
+	}
+	_, ok := r.attemptedHosts[host.AddressString()]
+	return ok
+}
+
 func (r *retryState) reset() {
-	r.cluster.ResourceManager().Retries().Decrease()
+	r.resourceManager.Retries().Decrease()
+
+	if r.budget != nil && atomic.CompareAndSwapUint32(&r.budgetAcquired, 1, 0) {
+		r.budget.Release()
+	}
+}
+
+// finish is called when the request is finished, the request is not counted in the route's retry budget any more
+func (r *retryState) finish() {
+	if r.budget != nil && atomic.CompareAndSwapUint32(&r.requestActive, 1, 0) {
+		r.budget.OnRequestFinish()
+	}
 }
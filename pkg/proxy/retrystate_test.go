@@ -38,10 +38,15 @@ type fakeClusterInfo struct {
 func (ci *fakeClusterInfo) ResourceManager() types.ResourceManager {
 	return ci.mgr
 }
+
+func (ci *fakeClusterInfo) PriorityResourceManager(priority types.ResourcePriority) types.ResourceManager {
+	return ci.mgr
+}
 func (ci *fakeClusterInfo) Stats() types.ClusterStats {
 	return types.ClusterStats{
 		UpstreamRequestRetryOverflow: metrics.NewCounter(),
 		UpstreamRequestRetry:         metrics.NewCounter(),
+		UpstreamRequestActive:        metrics.NewCounter(),
 	}
 }
 
@@ -78,7 +83,7 @@ func TestRetryState(t *testing.T) {
 	clusterInfo := &fakeClusterInfo{
 		mgr: &fakeResourceManager{},
 	}
-	rs := newRetryState(policy, nil, clusterInfo, protocol.HTTP1)
+	rs := newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
 	headerException := protocol.CommonHeader{
 		types.HeaderStatus: "500",
 	}
@@ -95,7 +100,7 @@ func TestRetryState(t *testing.T) {
 		{headerOK, "", types.NoRetry},
 	}
 	for i, tc := range testcases {
-		if rs.retry(tc.Header, tc.Reason) != tc.Expected {
+		if rs.retry(tc.Header, nil, tc.Reason) != tc.Expected {
 			t.Errorf("#%d retry state failed", i)
 		}
 	}
@@ -117,7 +122,7 @@ func TestRetryConnetionFailed(t *testing.T) {
 	clusterInfo := &fakeClusterInfo{
 		mgr: &fakeResourceManager{},
 	}
-	rs := newRetryState(policy, nil, clusterInfo, protocol.HTTP1)
+	rs := newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
 	testcases := []struct {
 		Header   types.HeaderMap
 		Reason   types.StreamResetReason
@@ -126,8 +131,304 @@ func TestRetryConnetionFailed(t *testing.T) {
 		{nil, types.StreamConnectionFailed, types.ShouldRetry},
 	}
 	for i, tc := range testcases {
-		if rs.retry(tc.Header, tc.Reason) != tc.Expected {
+		if rs.retry(tc.Header, nil, tc.Reason) != tc.Expected {
+			t.Errorf("#%d retry state failed", i)
+		}
+	}
+}
+
+func newTestRetryState(t *testing.T, cfg v2.RetryPolicyConfig, proto types.Protocol) *retryState {
+	rcfg := &v2.Router{}
+	rcfg.Route.RetryPolicy = &v2.RetryPolicy{
+		RetryPolicyConfig: cfg,
+	}
+	r, err := router.NewRouteRuleImplBase(nil, rcfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusterInfo := &fakeClusterInfo{
+		mgr: &fakeResourceManager{},
+	}
+	return newRetryState(r.Policy().RetryPolicy(), nil, clusterInfo, types.DefaultPriority, proto)
+}
+
+func TestRetryGrpcStatus(t *testing.T) {
+	headers := protocol.CommonHeader{types.HeaderStatus: "200"}
+	testcases := []struct {
+		Header   types.HeaderMap
+		Trailer  types.HeaderMap
+		Expected types.RetryCheckStatus
+	}{
+		{headers, protocol.CommonHeader{"grpc-status": "14"}, types.ShouldRetry},
+		{headers, protocol.CommonHeader{"grpc-status": "0"}, types.NoRetry},
+		{headers, protocol.CommonHeader{"grpc-status": "3"}, types.NoRetry},
+		{headers, nil, types.NoRetry},
+		// trailers-only response
+		{protocol.CommonHeader{types.HeaderStatus: "200", "grpc-status": "14"}, nil, types.ShouldRetry},
+	}
+	for i, tc := range testcases {
+		rs := newTestRetryState(t, v2.RetryPolicyConfig{
+			RetryOn:         true,
+			RetryConditions: []v2.RetryCondition{v2.RETRY_ON_GATEWAY_ERROR},
+		}, protocol.HTTP2)
+		if rs.retry(tc.Header, tc.Trailer, "") != tc.Expected {
+			t.Errorf("#%d retry state failed", i)
+		}
+	}
+}
+
+func TestRetryConditions(t *testing.T) {
+	status := func(code string) types.HeaderMap {
+		return protocol.CommonHeader{types.HeaderStatus: code}
+	}
+	testcases := []struct {
+		Conditions []v2.RetryCondition
+		Header     types.HeaderMap
+		Reason     types.StreamResetReason
+		Expected   types.RetryCheckStatus
+	}{
+		// default retry on 5xx
+		{nil, status("500"), "", types.ShouldRetry},
+		{nil, nil, types.UpstreamPerTryTimeout, types.ShouldRetry},
+		{nil, nil, types.StreamRefusedReset, types.ShouldRetry},
+		{nil, status("404"), "", types.NoRetry},
+		{nil, nil, types.StreamOverflow, types.NoRetry},
+		// gateway error
+		{[]v2.RetryCondition{v2.RETRY_ON_GATEWAY_ERROR}, status("503"), "", types.ShouldRetry},
+		{[]v2.RetryCondition{v2.RETRY_ON_GATEWAY_ERROR}, status("500"), "", types.NoRetry},
+		{[]v2.RetryCondition{v2.RETRY_ON_GATEWAY_ERROR}, nil, types.StreamConnectionTermination, types.ShouldRetry},
+		// connect failure
+		{[]v2.RetryCondition{v2.RETRY_ON_CONNECT_FAILURE}, nil, types.StreamConnectionFailed, types.ShouldRetry},
+		{[]v2.RetryCondition{v2.RETRY_ON_CONNECT_FAILURE}, nil, types.UpstreamPerTryTimeout, types.NoRetry},
+		{[]v2.RetryCondition{v2.RETRY_ON_CONNECT_FAILURE}, status("500"), "", types.NoRetry},
+		// refused stream
+		{[]v2.RetryCondition{v2.RETRY_ON_REFUSED_STREAM}, nil, types.StreamRefusedReset, types.ShouldRetry},
+		{[]v2.RetryCondition{v2.RETRY_ON_REFUSED_STREAM}, nil, types.StreamRemoteReset, types.NoRetry},
+		// retriable status codes
+		{[]v2.RetryCondition{v2.RETRY_ON_RETRIABLE_STATUS_CODES}, status("409"), "", types.ShouldRetry},
+		{[]v2.RetryCondition{v2.RETRY_ON_RETRIABLE_STATUS_CODES}, status("500"), "", types.NoRetry},
+		// reset
+		{[]v2.RetryCondition{v2.RETRY_ON_RESET}, nil, types.StreamRemoteReset, types.ShouldRetry},
+		{[]v2.RetryCondition{v2.RETRY_ON_RESET}, nil, types.UpstreamPerTryTimeout, types.ShouldRetry},
+		{[]v2.RetryCondition{v2.RETRY_ON_RESET}, status("503"), "", types.NoRetry},
+	}
+	for i, tc := range testcases {
+		rs := newTestRetryState(t, v2.RetryPolicyConfig{
+			RetryOn:              true,
+			RetryConditions:      tc.Conditions,
+			RetriableStatusCodes: []uint32{409},
+		}, protocol.HTTP1)
+		if rs.retry(tc.Header, nil, tc.Reason) != tc.Expected {
 			t.Errorf("#%d retry state failed", i)
 		}
 	}
 }
+
+type fakeSofaRpcResponse struct {
+	protocol.CommonHeader
+	status uint32
+}
+
+func (r *fakeSofaRpcResponse) RespStatus() uint32 {
+	return r.status
+}
+
+func TestRetrySofaRpcStatus(t *testing.T) {
+	rs := newTestRetryState(t, v2.RetryPolicyConfig{
+		RetryOn:                true,
+		NumRetries:             3,
+		RetryConditions:        []v2.RetryCondition{v2.RETRY_ON_RETRIABLE_SOFARPC_STATUS},
+		RetriableSofaRpcStatus: []uint32{4},
+	}, protocol.SofaRPC)
+	if rs.retry(&fakeSofaRpcResponse{CommonHeader: protocol.CommonHeader{}, status: 4}, nil, "") != types.ShouldRetry {
+		t.Error("sofarpc status 4 should be retried")
+	}
+	if rs.retry(&fakeSofaRpcResponse{CommonHeader: protocol.CommonHeader{}, status: 0}, nil, "") != types.NoRetry {
+		t.Error("sofarpc status 0 should not be retried")
+	}
+}
+
+func TestRetryNumRetries(t *testing.T) {
+	rs := newTestRetryState(t, v2.RetryPolicyConfig{
+		RetryOn:    true,
+		NumRetries: 1,
+	}, protocol.HTTP1)
+	if rs.retry(nil, nil, types.StreamConnectionFailed) != types.ShouldRetry {
+		t.Error("first retry expected")
+	}
+	if rs.retry(nil, nil, types.StreamConnectionFailed) != types.NoRetry {
+		t.Error("num retries should not be more than the config")
+	}
+}
+
+func TestRetryBackOff(t *testing.T) {
+	rs := newTestRetryState(t, v2.RetryPolicyConfig{
+		RetryOn: true,
+		RetryBackOff: &v2.RetryBackOffConfig{
+			BaseIntervalConfig: v2.DurationConfig{Duration: 10 * time.Millisecond},
+			MaxIntervalConfig:  v2.DurationConfig{Duration: 50 * time.Millisecond},
+		},
+	}, protocol.HTTP1)
+	ceilings := []time.Duration{10, 30, 50, 50, 50}
+	for i, ceiling := range ceilings {
+		if d := rs.nextBackOff(); d < 0 || d >= ceiling*time.Millisecond {
+			t.Errorf("#%d back off %v out of range [0, %v)", i, d, ceiling*time.Millisecond)
+		}
+	}
+	// default back off
+	rs = newTestRetryState(t, v2.RetryPolicyConfig{RetryOn: true}, protocol.HTTP1)
+	if rs.backOffBaseInterval != defaultBackOffBaseInterval || rs.backOffMaxInterval != 10*defaultBackOffBaseInterval {
+		t.Errorf("unexpected default back off: %v, %v", rs.backOffBaseInterval, rs.backOffMaxInterval)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	cfg := v2.RetryPolicyConfig{
+		RetryOn:    true,
+		NumRetries: 10,
+		RetryBudget: &v2.RetryBudgetConfig{
+			BudgetPercent:       50,
+			MinRetryConcurrency: 1,
+		},
+	}
+	rcfg := &v2.Router{}
+	rcfg.Route.RetryPolicy = &v2.RetryPolicy{RetryPolicyConfig: cfg}
+	r, _ := router.NewRouteRuleImplBase(nil, rcfg)
+	policy := r.Policy().RetryPolicy()
+	clusterInfo := &fakeClusterInfo{
+		mgr: &fakeResourceManager{},
+	}
+	// the budget is shared by the requests of the route
+	rs1 := newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
+	rs2 := newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
+	if rs1.retry(nil, nil, types.StreamConnectionFailed) != types.ShouldRetry {
+		t.Fatal("retry in budget expected")
+	}
+	if rs2.retry(nil, nil, types.StreamConnectionFailed) != types.RetryOverflow {
+		t.Fatal("retry budget should be exhausted")
+	}
+	// release the budget
+	rs1.reset()
+	if rs2.retry(nil, nil, types.StreamConnectionFailed) != types.ShouldRetry {
+		t.Fatal("retry in budget expected")
+	}
+	rs1.finish()
+	rs2.finish()
+}
+
+func TestRetryBudgetRouteActiveRequests(t *testing.T) {
+	cfg := v2.RetryPolicyConfig{
+		RetryOn:    true,
+		NumRetries: 10,
+		RetryBudget: &v2.RetryBudgetConfig{
+			BudgetPercent:       20,
+			MinRetryConcurrency: 1,
+		},
+	}
+	rcfg := &v2.Router{}
+	rcfg.Route.RetryPolicy = &v2.RetryPolicy{RetryPolicyConfig: cfg}
+	r, _ := router.NewRouteRuleImplBase(nil, rcfg)
+	policy := r.Policy().RetryPolicy()
+	clusterInfo := &fakeClusterInfo{
+		mgr: &fakeResourceManager{},
+	}
+	// 10 active requests in the route, 2 concurrent retries are allowed
+	states := make([]*retryState, 10)
+	for i := range states {
+		states[i] = newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
+	}
+	for i := 0; i < 2; i++ {
+		if states[i].retry(nil, nil, types.StreamConnectionFailed) != types.ShouldRetry {
+			t.Fatalf("#%d retry in budget expected", i)
+		}
+	}
+	if states[2].retry(nil, nil, types.StreamConnectionFailed) != types.RetryOverflow {
+		t.Fatal("retry budget should be exhausted")
+	}
+	// the finished requests are not counted, only the min retry concurrency is allowed
+	for _, rs := range states {
+		rs.reset()
+		rs.finish()
+		rs.finish()
+	}
+	rs := newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1)
+	if rs.retry(nil, nil, types.StreamConnectionFailed) != types.ShouldRetry {
+		t.Fatal("retry in min retry concurrency expected")
+	}
+	if newRetryState(policy, nil, clusterInfo, types.DefaultPriority, protocol.HTTP1).retry(nil, nil, types.StreamConnectionFailed) != types.RetryOverflow {
+		t.Fatal("retry budget should be exhausted")
+	}
+}
+
+type fakeHost struct {
+	types.Host
+	addr string
+}
+
+func (h *fakeHost) AddressString() string {
+	return h.addr
+}
+
+func TestRetryHostSelection(t *testing.T) {
+	rs := newTestRetryState(t, v2.RetryPolicyConfig{
+		RetryOn:                       true,
+		HostSelectionRetryMaxAttempts: 3,
+	}, protocol.HTTP1)
+	s := &downStream{retryState: rs}
+	host1 := &fakeHost{addr: "127.0.0.1:8080"}
+	host2 := &fakeHost{addr: "127.0.0.1:8081"}
+	if s.HostSelectionRetryCount() != 3 {
+		t.Error("unexpected host selection retry count")
+	}
+	rs.onHostAttempted(host1)
+	if !s.ShouldSelectAnotherHost(host1) || s.ShouldSelectAnotherHost(host2) {
+		t.Error("only the attempted host should be avoided")
+	}
+	// host selection retry is disabled
+	rs = newTestRetryState(t, v2.RetryPolicyConfig{RetryOn: true}, protocol.HTTP1)
+	s = &downStream{retryState: rs}
+	rs.onHostAttempted(host1)
+	if s.HostSelectionRetryCount() != 0 || s.ShouldSelectAnotherHost(host1) {
+		t.Error("host selection retry should be disabled")
+	}
+}
+
+func TestRetryBackOffWait(t *testing.T) {
+	newStream := func(backOff time.Duration) *downStream {
+		rs := newTestRetryState(t, v2.RetryPolicyConfig{RetryOn: true}, protocol.HTTP1)
+		rs.backOffBaseInterval = backOff
+		rs.backOffMaxInterval = backOff
+		s := &downStream{
+			ID:         1,
+			notify:     make(chan struct{}, 1),
+			retryState: rs,
+		}
+		s.upstreamRequest = &upstreamRequest{
+			downStream: s,
+			setupRetry: true,
+		}
+		return s
+	}
+
+	// the back off ends
+	s := newStream(10 * time.Millisecond)
+	if _, err := s.waitRetryBackOff(s.ID); err != nil {
+		t.Fatalf("retry expected after the back off, but got: %v", err)
+	}
+
+	// the global timeout gives up the retry during the back off
+	s = newStream(time.Hour)
+	s.downstreamCleaned = 1
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.upstreamRequest.setupRetry = false
+		s.sendNotify()
+	}()
+	start := time.Now()
+	if _, err := s.waitRetryBackOff(s.ID); err != types.ErrExit {
+		t.Fatalf("retry should be given up, but got: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("the back off should be stopped")
+	}
+}
@@ -0,0 +1,203 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// shadowHostSuffix is appended to the host/authority of the shadow request
+const shadowHostSuffix = "-shadow"
+
+// shadowRequest mirrors the downstream request to the shadow cluster.
+// The shadow request is fire and forget, the response is dropped and
+// the failure never affects the downstream request.
+// types.StreamEventListener
+// types.StreamReceiveListener
+// types.PoolEventListener
+type shadowRequest struct {
+	context       context.Context
+	cluster       types.ClusterInfo
+	snapshot      types.ClusterSnapshot
+	timeout       time.Duration
+	oneway        bool
+	requestSender types.StreamSender
+
+	// ~~~ shadow request buf, cloned from the downstream request
+	headers  types.HeaderMap
+	data     types.IoBuffer
+	trailers types.HeaderMap
+
+	timer *utils.Timer
+	done  uint32
+}
+
+// newShadowRequest creates a shadow request if the route's shadow policy matches the request,
+// the request is cloned here, because the upstream request may modify the headers and
+// the stream may return the data to the buffer pool after it is sent.
+func (s *downStream) newShadowRequest() *shadowRequest {
+	// the streamed request body is not kept, so it can not be mirrored
+	if s.reqBody != nil {
+		return nil
+	}
+	policy := s.route.RouteRule().Policy()
+	if policy == nil {
+		return nil
+	}
+	shadowPolicy := policy.ShadowPolicy()
+	if shadowPolicy == nil || shadowPolicy.ClusterName() == "" {
+		return nil
+	}
+	if percent := shadowPolicy.Percent(); percent < 100 && rand.Float64()*100 >= percent {
+		return nil
+	}
+	snapshot := s.proxy.clusterManager.GetClusterSnapshot(s.context, shadowPolicy.ClusterName())
+	if snapshot == nil {
+		log.Proxy.Warnf(s.context, "[proxy] [shadow] shadow cluster %s is not found", shadowPolicy.ClusterName())
+		return nil
+	}
+
+	headers := s.downstreamReqHeaders.Clone()
+	for _, key := range []string{protocol.MosnHeaderHostKey, protocol.IstioHeaderHostKey} {
+		if host, ok := headers.Get(key); ok && host != "" {
+			headers.Set(key, host+shadowHostSuffix)
+		}
+	}
+
+	r := &shadowRequest{
+		// the shadow request outlives the downstream, so it needs its own buffer pool context
+		context:  buffer.NewBufferPoolContext(mosnctx.Clone(s.context)),
+		cluster:  snapshot.ClusterInfo(),
+		snapshot: snapshot,
+		timeout:  s.timeout.GlobalTimeout,
+		oneway:   s.oneway,
+		headers:  s.upstreamRequest.convertHeader(headers),
+	}
+	if s.downstreamReqDataBuf != nil {
+		r.data = s.upstreamRequest.convertData(s.downstreamReqDataBuf.Clone())
+	}
+	if s.downstreamReqTrailers != nil {
+		r.trailers = s.upstreamRequest.convertTrailer(s.downstreamReqTrailers.Clone())
+	}
+	return r
+}
+
+// startShadow sends the shadow request after the downstream request is received completely
+func (s *downStream) startShadow() {
+	r := s.shadow
+	s.shadow = nil
+	if r == nil {
+		return
+	}
+
+	r.cluster.Stats().UpstreamRequestShadow.Inc(1)
+	pool := s.proxy.clusterManager.ConnPoolForCluster(s, r.snapshot, s.getUpstreamProtocol())
+	if pool == nil {
+		log.Proxy.Warnf(s.context, "[proxy] [shadow] no healthy upstream in shadow cluster %s", r.cluster.Name())
+		r.cluster.Stats().UpstreamRequestShadowFailed.Inc(1)
+		return
+	}
+	if r.oneway {
+		pool.NewStream(r.context, nil, r)
+	} else {
+		pool.NewStream(r.context, r, r)
+	}
+}
+
+// finish marks the shadow request done, returns false if it is already done
+func (r *shadowRequest) finish() bool {
+	if !atomic.CompareAndSwapUint32(&r.done, 0, 1) {
+		return false
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	return true
+}
+
+func (r *shadowRequest) onTimeout() {
+	if !r.finish() {
+		return
+	}
+	r.cluster.Stats().UpstreamRequestTimeout.Inc(1)
+	r.cluster.Stats().UpstreamRequestShadowFailed.Inc(1)
+	r.requestSender.GetStream().RemoveEventListener(r)
+	r.requestSender.GetStream().ResetStream(types.StreamLocalReset)
+}
+
+// types.StreamEventListener
+func (r *shadowRequest) OnResetStream(reason types.StreamResetReason) {
+	if !r.finish() {
+		return
+	}
+	log.Proxy.Debugf(r.context, "[proxy] [shadow] shadow request to cluster %s reset, reason: %s", r.cluster.Name(), reason)
+	r.cluster.Stats().UpstreamRequestShadowFailed.Inc(1)
+}
+
+func (r *shadowRequest) OnDestroyStream() {}
+
+// types.StreamReceiveListener
+// the shadow response is dropped
+func (r *shadowRequest) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	if data != nil {
+		data.Drain(data.Len())
+	}
+	r.finish()
+}
+
+func (r *shadowRequest) OnDecodeError(context context.Context, err error, headers types.HeaderMap) {
+	r.OnResetStream(types.StreamLocalReset)
+}
+
+// types.PoolEventListener
+func (r *shadowRequest) OnFailure(reason types.PoolFailureReason, host types.Host) {
+	r.OnResetStream(types.StreamConnectionFailed)
+}
+
+func (r *shadowRequest) OnReady(sender types.StreamSender, host types.Host) {
+	r.requestSender = sender
+	sender.GetStream().AddEventListener(r)
+
+	if r.timeout > 0 && !r.oneway {
+		r.timer = utils.NewTimer(r.timeout, r.onTimeout)
+	}
+
+	endStream := r.data == nil && r.trailers == nil
+	sender.AppendHeaders(r.context, r.headers, endStream)
+	if r.data != nil {
+		sender.AppendData(r.context, r.data, r.trailers == nil)
+	}
+	if r.trailers != nil {
+		sender.AppendTrailers(r.context, r.trailers)
+	}
+
+	// the oneway request never receives a response
+	if r.oneway {
+		r.finish()
+	}
+}
@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	metrics "github.com/rcrowley/go-metrics"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/router"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func newShadowTestStream(t *testing.T, shadowPolicy *v2.ShadowPolicy, pool types.ConnectionPool) (*downStream, *mockClusterInfo) {
+	route := &v2.Router{}
+	route.Route = v2.RouteAction{
+		RouterActionConfig: v2.RouterActionConfig{
+			ClusterName:  "test",
+			ShadowPolicy: shadowPolicy,
+		},
+	}
+	rule, err := router.NewRouteRuleImplBase(nil, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shadowCluster := &mockClusterInfo{
+		name: "shadow",
+		stats: types.ClusterStats{
+			UpstreamRequestShadow:       metrics.NewCounter(),
+			UpstreamRequestShadowFailed: metrics.NewCounter(),
+			UpstreamRequestTimeout:      metrics.NewCounter(),
+		},
+	}
+	s := &downStream{
+		proxy: &proxy{
+			config: &v2.Proxy{
+				DownstreamProtocol: string(protocol.HTTP1),
+				UpstreamProtocol:   string(protocol.HTTP1),
+			},
+			clusterManager: &mockClusterManager{
+				snapshot: &mockClusterSnapshot{info: shadowCluster},
+				pool:     pool,
+			},
+		},
+		route: &mockRoute{
+			rule: &mockRouteRule{policy: rule.Policy()},
+		},
+		context: context.Background(),
+	}
+	s.upstreamRequest = &upstreamRequest{
+		downStream: s,
+	}
+	return s, shadowCluster
+}
+
+func TestShadowRequest(t *testing.T) {
+	pool := &mockConnPool{sender: &mockRequestSender{}}
+	s, shadowCluster := newShadowTestStream(t, &v2.ShadowPolicy{Cluster: "shadow"}, pool)
+	s.downstreamReqHeaders = protocol.CommonHeader{
+		protocol.MosnHeaderHostKey: "test.com",
+		"service":                  "test",
+	}
+	s.downstreamReqDataBuf = buffer.NewIoBufferString("shadow body")
+
+	s.shadow = s.newShadowRequest()
+	if s.shadow == nil {
+		t.Fatal("expected a shadow request")
+	}
+	// the upstream request may modify the headers, and the sent data may be returned to the buffer pool
+	s.downstreamReqHeaders.Del("service")
+	s.downstreamReqDataBuf.Reset()
+	s.startShadow()
+
+	if s.shadow != nil {
+		t.Error("shadow request should be started only once")
+	}
+	sender := pool.sender
+	if sender.headers == nil {
+		t.Fatal("shadow request headers is not sent")
+	}
+	if host, _ := sender.headers.Get(protocol.MosnHeaderHostKey); host != "test.com-shadow" {
+		t.Errorf("unexpected shadow host: %s", host)
+	}
+	if host, _ := s.downstreamReqHeaders.Get(protocol.MosnHeaderHostKey); host != "test.com" {
+		t.Errorf("downstream request headers should not be modified, got host: %s", host)
+	}
+	if v, ok := sender.headers.Get("service"); !ok || v != "test" {
+		t.Error("shadow request headers should be cloned before the upstream request sent")
+	}
+	if sender.data == nil || sender.data.String() != "shadow body" {
+		t.Error("unexpected shadow request data")
+	}
+	if !sender.endStream {
+		t.Error("shadow request should be ended")
+	}
+	if shadowCluster.stats.UpstreamRequestShadow.Count() != 1 || shadowCluster.stats.UpstreamRequestShadowFailed.Count() != 0 {
+		t.Error("unexpected shadow stats")
+	}
+	// the shadow response is dropped
+	s.shadow = nil
+	r := &shadowRequest{cluster: shadowCluster}
+	r.OnReceive(context.Background(), protocol.CommonHeader{}, nil, nil)
+	r.OnResetStream(types.StreamRemoteReset)
+	if shadowCluster.stats.UpstreamRequestShadowFailed.Count() != 0 {
+		t.Error("finished shadow request should not be failed")
+	}
+}
+
+func TestShadowRequestFailed(t *testing.T) {
+	// no healthy host in shadow cluster
+	s, shadowCluster := newShadowTestStream(t, &v2.ShadowPolicy{Cluster: "shadow"}, nil)
+	s.downstreamReqHeaders = protocol.CommonHeader{}
+	s.shadow = s.newShadowRequest()
+	s.startShadow()
+	if shadowCluster.stats.UpstreamRequestShadow.Count() != 1 || shadowCluster.stats.UpstreamRequestShadowFailed.Count() != 1 {
+		t.Error("unexpected shadow stats")
+	}
+	// connection failure
+	r := &shadowRequest{cluster: shadowCluster}
+	r.OnFailure(types.ConnectionFailure, nil)
+	if shadowCluster.stats.UpstreamRequestShadowFailed.Count() != 2 {
+		t.Error("unexpected shadow stats")
+	}
+}
+
+func TestShadowRequestNoPolicy(t *testing.T) {
+	for _, policy := range []*v2.ShadowPolicy{
+		nil,
+		{},
+	} {
+		s, _ := newShadowTestStream(t, policy, nil)
+		s.downstreamReqHeaders = protocol.CommonHeader{}
+		if s.newShadowRequest() != nil {
+			t.Errorf("no shadow request expected for policy: %+v", policy)
+		}
+		// start without shadow request should do nothing
+		s.startShadow()
+	}
+}
+
+func TestShadowRequestPercent(t *testing.T) {
+	s, _ := newShadowTestStream(t, &v2.ShadowPolicy{Cluster: "shadow", Percent: 50}, nil)
+	s.downstreamReqHeaders = protocol.CommonHeader{}
+	shadowed := 0
+	for i := 0; i < 1000; i++ {
+		if s.newShadowRequest() != nil {
+			shadowed++
+		}
+	}
+	if shadowed < 400 || shadowed > 600 {
+		t.Errorf("expected about half of the requests to be shadowed, got %d", shadowed)
+	}
+}
+
+func TestShadowRequestFractionalPercent(t *testing.T) {
+	s, _ := newShadowTestStream(t, &v2.ShadowPolicy{Cluster: "shadow", Percent: 0.5}, nil)
+	s.downstreamReqHeaders = protocol.CommonHeader{}
+	shadowed := 0
+	for i := 0; i < 100000; i++ {
+		if s.newShadowRequest() != nil {
+			shadowed++
+		}
+	}
+	if shadowed < 300 || shadowed > 700 {
+		t.Errorf("expected about 0.5%% of the requests to be shadowed, got %d", shadowed)
+	}
+}
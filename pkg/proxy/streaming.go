@@ -0,0 +1,322 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package proxy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// defaultStreamHighWatermark is used if no high watermark is configured for the streamed bodies,
+// the low watermark is half of the high watermark by default
+const defaultStreamHighWatermark = 1 << 20
+
+// bodyPipe passes the pieces of a streamed body from the stream connection that decodes them
+// to the goroutine that forwards them.
+// The source stream is read disabled while the queued bytes are above the high watermark,
+// and enabled again once they drop to the low watermark.
+type bodyPipe struct {
+	mux      sync.Mutex
+	pieces   []types.IoBuffer
+	trailers types.HeaderMap
+	queued   uint32
+	end      bool
+	finished bool
+	closed   bool
+
+	high     uint32
+	low      uint32
+	source   types.FlowControlStream
+	disabled bool
+
+	signal chan struct{}
+}
+
+func newBodyPipe(config *v2.StreamingProxy, source types.Stream) *bodyPipe {
+	p := &bodyPipe{
+		high:   defaultStreamHighWatermark,
+		signal: make(chan struct{}, 1),
+	}
+	if config != nil && config.HighWatermark > 0 {
+		p.high = config.HighWatermark
+	}
+	p.low = p.high / 2
+	if config != nil && config.LowWatermark > 0 && config.LowWatermark < p.high {
+		p.low = config.LowWatermark
+	}
+	if fc, ok := source.(types.FlowControlStream); ok {
+		p.source = fc
+	}
+	return p
+}
+
+// push queues a piece of the body, it is called by the stream connection
+func (p *bodyPipe) push(data types.IoBuffer, endStream bool) {
+	p.mux.Lock()
+	if p.closed || p.end {
+		p.mux.Unlock()
+		return
+	}
+	if data != nil && data.Len() > 0 {
+		p.pieces = append(p.pieces, data)
+		p.queued += uint32(data.Len())
+	}
+	p.end = endStream
+
+	disable := !p.disabled && p.source != nil && p.queued > p.high
+	if disable {
+		p.disabled = true
+	}
+	p.mux.Unlock()
+
+	if disable {
+		p.source.ReadDisable(true)
+	}
+	p.wakeup()
+}
+
+// pushTrailers queues the trailers, which end the body
+func (p *bodyPipe) pushTrailers(trailers types.HeaderMap) {
+	p.mux.Lock()
+	if p.closed || p.end {
+		p.mux.Unlock()
+		return
+	}
+	p.trailers = trailers
+	p.end = true
+	p.mux.Unlock()
+
+	p.wakeup()
+}
+
+// next returns the next piece of the body, it waits until a piece is queued, the pipe is closed
+// or the abort channel is notified.
+// trailers is returned after all the pieces if the body ends with trailers, ok is false if
+// the pipe is closed or aborted.
+func (p *bodyPipe) next(abort <-chan struct{}) (data types.IoBuffer, trailers types.HeaderMap, endStream bool, ok bool) {
+	for {
+		p.mux.Lock()
+		if p.closed || p.finished {
+			p.mux.Unlock()
+			return nil, nil, false, false
+		}
+
+		if len(p.pieces) > 0 {
+			data = p.pieces[0]
+			p.pieces[0] = nil
+			p.pieces = p.pieces[1:]
+			p.queued -= uint32(data.Len())
+			endStream = p.end && len(p.pieces) == 0 && p.trailers == nil
+			p.finished = endStream
+
+			enable := p.disabled && p.queued <= p.low
+			if enable {
+				p.disabled = false
+			}
+			p.mux.Unlock()
+
+			if enable {
+				p.source.ReadDisable(false)
+			}
+			return data, nil, endStream, true
+		}
+
+		if p.end {
+			p.finished = true
+			trailers = p.trailers
+			p.trailers = nil
+			p.mux.Unlock()
+
+			if trailers != nil {
+				return nil, trailers, true, true
+			}
+			// the body ends with an empty piece
+			return buffer.NewIoBuffer(0), nil, true, true
+		}
+		p.mux.Unlock()
+
+		select {
+		case <-p.signal:
+		case <-abort:
+			return nil, nil, false, false
+		}
+	}
+}
+
+// close drops the queued pieces and wakes up the waiting goroutine,
+// the source stream is enabled if it is read disabled by the pipe
+func (p *bodyPipe) close() {
+	p.mux.Lock()
+	if p.closed {
+		p.mux.Unlock()
+		return
+	}
+	p.closed = true
+	p.pieces = nil
+	p.trailers = nil
+	enable := p.disabled
+	p.disabled = false
+	p.mux.Unlock()
+
+	if enable {
+		p.source.ReadDisable(false)
+	}
+	p.wakeup()
+}
+
+func (p *bodyPipe) wakeup() {
+	select {
+	case p.signal <- struct{}{}:
+	default:
+	}
+}
+
+// types.StreamingReceiveListener
+// the request body streams through after the headers
+func (s *downStream) OnReceiveHeaders(ctx context.Context, headers types.HeaderMap, endStream bool) {
+	if !endStream {
+		// the forwarding goroutine may outlive the proxy goroutine, do not reuse the buffers
+		atomic.StoreUint32(&s.reuseBuffer, 0)
+
+		var source types.Stream
+		if s.responseSender != nil {
+			source = s.responseSender.GetStream()
+		}
+		s.reqBody = newBodyPipe(s.proxy.config.StreamingConfig, source)
+	}
+	s.OnReceive(ctx, headers, nil, nil)
+}
+
+func (s *downStream) OnReceiveData(ctx context.Context, data types.IoBuffer, endStream bool) {
+	if s.reqBody != nil {
+		s.reqBody.push(data, endStream)
+	}
+}
+
+func (s *downStream) OnReceiveTrailers(ctx context.Context, trailers types.HeaderMap) {
+	if s.reqBody != nil {
+		s.reqBody.pushTrailers(trailers)
+	}
+}
+
+// streamRequestBody forwards the streamed request body in its own goroutine, so the response
+// can be handled while the request is still being sent, such as a bidirectional stream
+func (s *downStream) streamRequestBody() {
+	if s.upstreamRequest == nil || s.processDone() {
+		return
+	}
+	pipe := s.reqBody
+	utils.GoWithRecover(func() {
+		s.forwardRequestBody(pipe)
+	}, nil)
+}
+
+func (s *downStream) forwardRequestBody(pipe *bodyPipe) {
+	for {
+		data, trailers, endStream, ok := pipe.next(nil)
+		if !ok || s.processDone() {
+			return
+		}
+
+		if trailers != nil {
+			s.downstreamReqTrailers = trailers
+			s.receiveTrailers()
+			return
+		}
+
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(s.context, "[proxy] [downstream] stream request data, len = %d, endStream = %t, proxyId = %d", data.Len(), endStream, s.ID)
+		}
+		s.requestInfo.SetBytesReceived(s.requestInfo.BytesReceived() + uint64(data.Len()))
+		if endStream {
+			s.downstreamRecvDone = true
+			s.onUpstreamRequestSent()
+		}
+		s.upstreamRequest.streamData(data, endStream)
+
+		if endStream {
+			return
+		}
+	}
+}
+
+// streamResponseBody forwards the streamed response body in the proxy goroutine,
+// it returns on the end of the body or on the downstream notified, such as a reset
+func (s *downStream) streamResponseBody() {
+	r := s.upstreamRequest
+	for {
+		data, trailers, endStream, ok := r.respBody.next(s.notify)
+		if !ok || s.processDone() {
+			return
+		}
+
+		if trailers != nil {
+			s.downstreamRespTrailers = trailers
+			r.receiveTrailers()
+			return
+		}
+
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(s.context, "[proxy] [downstream] stream response data, len = %d, endStream = %t, proxyId = %d", data.Len(), endStream, s.ID)
+		}
+		s.downstreamRespDataBuf = data
+		r.receiveData(endStream)
+
+		if endStream {
+			return
+		}
+	}
+}
+
+// types.StreamingReceiveListener
+// the response body streams through after the headers
+func (r *upstreamRequest) OnReceiveHeaders(ctx context.Context, headers types.HeaderMap, endStream bool) {
+	if !endStream && !r.downStream.processDone() && !r.setupRetry {
+		atomic.StoreUint32(&r.downStream.reuseBuffer, 0)
+		r.respBody = newBodyPipe(r.proxy.config.StreamingConfig, r.requestSender.GetStream())
+	}
+	r.OnReceive(ctx, headers, nil, nil)
+}
+
+func (r *upstreamRequest) OnReceiveData(ctx context.Context, data types.IoBuffer, endStream bool) {
+	if r.respBody != nil {
+		r.respBody.push(data, endStream)
+	}
+}
+
+func (r *upstreamRequest) OnReceiveTrailers(ctx context.Context, trailers types.HeaderMap) {
+	if r.respBody != nil {
+		r.respBody.pushTrailers(trailers)
+	}
+}
+
+// streamData sends a piece of the streamed request body
+func (r *upstreamRequest) streamData(data types.IoBuffer, endStream bool) {
+	if r.downStream.processDone() || r.requestSender == nil {
+		return
+	}
+	r.sendComplete = endStream
+	r.dataSent = true
+	r.requestSender.AppendData(r.downStream.context, r.convertData(data), endStream)
+}
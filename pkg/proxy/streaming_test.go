@@ -0,0 +1,197 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type mockFlowControlStream struct {
+	types.Stream
+	disabled int
+}
+
+func (s *mockFlowControlStream) ReadDisable(disable bool) {
+	if disable {
+		s.disabled++
+	} else {
+		s.disabled--
+	}
+}
+
+func TestBodyPipeWatermark(t *testing.T) {
+	source := &mockFlowControlStream{}
+	pipe := newBodyPipe(&v2.StreamingProxy{HighWatermark: 10, LowWatermark: 4}, source)
+
+	pipe.push(buffer.NewIoBufferString("12345"), false)
+	pipe.push(buffer.NewIoBufferString("12345"), false)
+	if source.disabled != 0 {
+		t.Fatalf("the source should not be disabled at the high watermark")
+	}
+	pipe.push(buffer.NewIoBufferString("123"), false)
+	if source.disabled != 1 {
+		t.Fatalf("the source should be disabled above the high watermark, disabled = %d", source.disabled)
+	}
+	pipe.push(buffer.NewIoBufferString("1"), false)
+	if source.disabled != 1 {
+		t.Fatalf("the source should be disabled only once, disabled = %d", source.disabled)
+	}
+
+	// 14 queued, drops to 9 and 4
+	pipe.next(nil)
+	if source.disabled != 1 {
+		t.Fatalf("the source should be disabled above the low watermark")
+	}
+	pipe.next(nil)
+	if source.disabled != 0 {
+		t.Fatalf("the source should be enabled at the low watermark, disabled = %d", source.disabled)
+	}
+}
+
+func TestBodyPipeDefaultWatermark(t *testing.T) {
+	pipe := newBodyPipe(nil, nil)
+	if pipe.high != defaultStreamHighWatermark || pipe.low != defaultStreamHighWatermark/2 {
+		t.Fatalf("unexpected default watermarks, high = %d, low = %d", pipe.high, pipe.low)
+	}
+	pipe = newBodyPipe(&v2.StreamingProxy{HighWatermark: 100, LowWatermark: 200}, nil)
+	if pipe.high != 100 || pipe.low != 50 {
+		t.Fatalf("the low watermark above the high watermark should be ignored, high = %d, low = %d", pipe.high, pipe.low)
+	}
+	// no source to disable
+	pipe.push(buffer.NewIoBufferString(string(make([]byte, 200))), false)
+	if pipe.disabled {
+		t.Fatalf("the pipe without a source should not be disabled")
+	}
+}
+
+func TestBodyPipeOrder(t *testing.T) {
+	pipe := newBodyPipe(nil, nil)
+	trailers := protocol.CommonHeader{"grpc-status": "0"}
+	go func() {
+		pipe.push(buffer.NewIoBufferString("hello "), false)
+		pipe.push(buffer.NewIoBufferString("world"), false)
+		pipe.pushTrailers(trailers)
+		// ignored after the end
+		pipe.push(buffer.NewIoBufferString("!"), true)
+	}()
+
+	var body string
+	for {
+		data, tr, endStream, ok := pipe.next(nil)
+		if !ok {
+			t.Fatalf("the pipe should not be closed")
+		}
+		if tr != nil {
+			if !endStream {
+				t.Fatalf("the trailers should end the body")
+			}
+			if v, _ := tr.Get("grpc-status"); v != "0" {
+				t.Fatalf("unexpected trailers: %v", tr)
+			}
+			break
+		}
+		if endStream {
+			t.Fatalf("the body should end with the trailers")
+		}
+		body += data.String()
+	}
+	if body != "hello world" {
+		t.Fatalf("unexpected body: %s", body)
+	}
+	if _, _, _, ok := pipe.next(nil); ok {
+		t.Fatalf("the pipe should be finished")
+	}
+}
+
+func TestBodyPipeEndStream(t *testing.T) {
+	pipe := newBodyPipe(nil, nil)
+	pipe.push(buffer.NewIoBufferString("data"), true)
+	data, _, endStream, ok := pipe.next(nil)
+	if !ok || !endStream || data.String() != "data" {
+		t.Fatalf("the last piece should end the body, data = %v, endStream = %v", data, endStream)
+	}
+
+	// the end of the body is reported with the last queued piece
+	pipe = newBodyPipe(nil, nil)
+	pipe.push(buffer.NewIoBufferString("data"), false)
+	pipe.push(nil, true)
+	data, _, endStream, ok = pipe.next(nil)
+	if !ok || !endStream || data.String() != "data" {
+		t.Fatalf("the queued piece should end the body, data = %v, endStream = %v", data, endStream)
+	}
+
+	// the body ends with an empty piece if every piece is forwarded already
+	pipe = newBodyPipe(nil, nil)
+	pipe.push(buffer.NewIoBufferString("data"), false)
+	pipe.next(nil)
+	pipe.push(nil, true)
+	data, _, endStream, ok = pipe.next(nil)
+	if !ok || !endStream || data.Len() != 0 {
+		t.Fatalf("the empty piece should end the body, data = %v, endStream = %v", data, endStream)
+	}
+}
+
+func TestBodyPipeClose(t *testing.T) {
+	source := &mockFlowControlStream{}
+	pipe := newBodyPipe(&v2.StreamingProxy{HighWatermark: 1}, source)
+	pipe.push(buffer.NewIoBufferString("data"), false)
+	if source.disabled != 1 {
+		t.Fatalf("the source should be disabled")
+	}
+
+	done := make(chan bool)
+	go func() {
+		pipe.next(nil)
+		_, _, _, ok := pipe.next(nil)
+		done <- ok
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pipe.close()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatalf("the closed pipe should return no data")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the waiting goroutine should be woken up by close")
+	}
+	if source.disabled != 0 {
+		t.Fatalf("the source should be enabled after close, disabled = %d", source.disabled)
+	}
+	// pushed after close is dropped
+	pipe.push(buffer.NewIoBufferString("data"), false)
+	if pipe.queued != 0 {
+		t.Fatalf("the closed pipe should drop the data")
+	}
+}
+
+func TestBodyPipeAbort(t *testing.T) {
+	pipe := newBodyPipe(nil, nil)
+	abort := make(chan struct{}, 1)
+	abort <- struct{}{}
+	if _, _, _, ok := pipe.next(abort); ok {
+		t.Fatalf("the aborted pipe should return no data")
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"sync/atomic"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// upgradeTunnel forwards raw bytes between the downstream and upstream connections
+// after the upstream accepts the protocol upgrade, such as WebSocket.
+// the downstream ends when the tunnel is closed.
+type upgradeTunnel struct {
+	downStream *downStream
+	downstream types.StreamTunnel
+	upstream   types.StreamTunnel
+
+	idleTimeout time.Duration
+	lastActive  int64
+	closed      uint32
+}
+
+// defaultTunnelIdleTimeout is used if no idle timeout is configured for the tunnel
+const defaultTunnelIdleTimeout = network.DefaultIdleTimeout
+
+func newUpgradeTunnel(s *downStream) *upgradeTunnel {
+	t := &upgradeTunnel{
+		downStream:  s,
+		idleTimeout: defaultTunnelIdleTimeout,
+	}
+	if cfg := s.proxy.config.WebSocketConfig; cfg != nil && cfg.IdleTimeout != nil {
+		t.idleTimeout = cfg.IdleTimeout.Duration
+	}
+	return t
+}
+
+// start starts forwarding on both sides, it should be called after the upgrade response is sent
+func (t *upgradeTunnel) start() {
+	atomic.StoreInt64(&t.lastActive, time.Now().UnixNano())
+	if t.idleTimeout > 0 {
+		utils.NewTimer(t.idleTimeout, t.onIdleTimeout)
+	}
+	t.upstream.Start()
+	t.downstream.Start()
+}
+
+func (t *upgradeTunnel) onIdleTimeout() {
+	if atomic.LoadUint32(&t.closed) == 1 {
+		return
+	}
+	idle := time.Since(time.Unix(0, atomic.LoadInt64(&t.lastActive)))
+	if idle < t.idleTimeout {
+		utils.NewTimer(t.idleTimeout-idle, t.onIdleTimeout)
+		return
+	}
+	log.Proxy.Infof(t.downStream.context, "[proxy] [tunnel] close the idle tunnel, proxyId = %d", t.downStream.ID)
+	t.close()
+}
+
+func (t *upgradeTunnel) close() {
+	if !atomic.CompareAndSwapUint32(&t.closed, 0, 1) {
+		return
+	}
+	t.downstream.Close()
+	t.upstream.Close()
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(t.downStream.context, "[proxy] [tunnel] tunnel closed, proxyId = %d", t.downStream.ID)
+	}
+	t.downStream.endStream()
+}
+
+// types.TunnelListener
+// tunnelListener passes the bytes read from one side of the tunnel to the other side
+type tunnelListener struct {
+	tunnel     *upgradeTunnel
+	downstream bool
+}
+
+func (l *tunnelListener) OnTunnelData(data types.IoBuffer) {
+	t := l.tunnel
+	atomic.StoreInt64(&t.lastActive, time.Now().UnixNano())
+
+	info := t.downStream.requestInfo
+	size := uint64(data.Len())
+
+	var err error
+	if l.downstream {
+		info.SetBytesReceived(info.BytesReceived() + size)
+		err = t.upstream.Write(data)
+	} else {
+		info.SetBytesSent(info.BytesSent() + size)
+		err = t.downstream.Write(data)
+	}
+
+	if err != nil {
+		log.Proxy.Errorf(t.downStream.context, "[proxy] [tunnel] forward data error: %v, proxyId = %d", err, t.downStream.ID)
+		t.close()
+	}
+}
+
+func (l *tunnelListener) OnTunnelClose() {
+	l.tunnel.close()
+}
@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+)
+
+func newTestTunnel(idleTimeout time.Duration) (*upgradeTunnel, *mockStreamTunnel, *mockStreamTunnel) {
+	s := &downStream{
+		proxy: &proxy{
+			config: &v2.Proxy{
+				WebSocketConfig: &v2.WebSocketProxy{
+					IdleTimeout: &v2.DurationConfig{Duration: idleTimeout},
+				},
+			},
+		},
+		requestInfo: &network.RequestInfo{},
+		context:     context.Background(),
+		// the stream lifecycle is not tested
+		downstreamCleaned: 1,
+	}
+	downstream := &mockStreamTunnel{}
+	upstream := &mockStreamTunnel{}
+	tunnel := newUpgradeTunnel(s)
+	tunnel.downstream = downstream
+	tunnel.upstream = upstream
+	return tunnel, downstream, upstream
+}
+
+func TestUpgradeTunnelForward(t *testing.T) {
+	tunnel, downstream, upstream := newTestTunnel(0)
+	tunnel.start()
+	if !downstream.started || !upstream.started {
+		t.Fatal("tunnel is not started")
+	}
+
+	downListener := &tunnelListener{tunnel: tunnel, downstream: true}
+	upListener := &tunnelListener{tunnel: tunnel}
+
+	downListener.OnTunnelData(buffer.NewIoBufferString("ping"))
+	upListener.OnTunnelData(buffer.NewIoBufferString("pong!"))
+
+	if string(upstream.data) != "ping" || string(downstream.data) != "pong!" {
+		t.Errorf("unexpected forward data, upstream: %s, downstream: %s", upstream.data, downstream.data)
+	}
+	info := tunnel.downStream.requestInfo
+	if info.BytesReceived() != 4 || info.BytesSent() != 5 {
+		t.Errorf("unexpected bytes, received: %d, sent: %d", info.BytesReceived(), info.BytesSent())
+	}
+
+	// one side closed, the tunnel is closed
+	upListener.OnTunnelClose()
+	if !downstream.isClosed() || !upstream.isClosed() {
+		t.Error("tunnel is not closed")
+	}
+}
+
+func TestUpgradeTunnelIdleTimeout(t *testing.T) {
+	tunnel, downstream, upstream := newTestTunnel(100 * time.Millisecond)
+	tunnel.start()
+
+	listener := &tunnelListener{tunnel: tunnel, downstream: true}
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		listener.OnTunnelData(buffer.NewIoBufferString("ping"))
+	}
+	if downstream.isClosed() || upstream.isClosed() {
+		t.Fatal("active tunnel is closed")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if !downstream.isClosed() || !upstream.isClosed() {
+		t.Error("idle tunnel is not closed")
+	}
+}
+
+func TestUpgradeTunnelDefaultIdleTimeout(t *testing.T) {
+	s := &downStream{
+		proxy: &proxy{
+			config: &v2.Proxy{},
+		},
+	}
+	if tunnel := newUpgradeTunnel(s); tunnel.idleTimeout != defaultTunnelIdleTimeout {
+		t.Errorf("expected default idle timeout, but got %v", tunnel.idleTimeout)
+	}
+}
+
+func TestSetupTunnelDownstreamNotUpgradable(t *testing.T) {
+	upstream := &mockStreamTunnel{}
+	s := &downStream{
+		proxy: &proxy{
+			config: &v2.Proxy{},
+		},
+		requestInfo: &network.RequestInfo{},
+		context:     context.Background(),
+		downstreamReqHeaders: protocol.CommonHeader(map[string]string{
+			"Connection": "Upgrade",
+			"Upgrade":    "websocket",
+		}),
+		responseSender: &mockResponseSender{},
+	}
+	s.upstreamRequest = &upstreamRequest{
+		downStream:    s,
+		requestSender: &mockUpgradableRequestSender{tunnel: upstream},
+	}
+	if s.setupTunnel() {
+		t.Fatal("setup tunnel should be failed")
+	}
+	if s.tunnel != nil || !upstream.isClosed() {
+		t.Error("the upgraded upstream should be closed")
+	}
+}
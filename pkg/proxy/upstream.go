@@ -26,6 +26,8 @@ import (
 
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/trace"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -42,6 +44,8 @@ type upstreamRequest struct {
 
 	// ~~~ upstream response buf
 	upstreamRespHeaders types.HeaderMap
+	// the streamed response body, the data passes through as it arrives
+	respBody *bodyPipe
 
 	//~~~ state
 	sendComplete bool
@@ -49,6 +53,8 @@ type upstreamRequest struct {
 	trailerSent  bool
 	setupRetry   bool
 
+	// time at request a upstream stream from the connection pool
+	connectStartTime time.Time
 	// time at send upstream request
 	startTime time.Time
 
@@ -81,11 +87,37 @@ func (r *upstreamRequest) OnResetStream(reason types.StreamResetReason) {
 	}
 
 	r.downStream.resetReason = reason
+	if result, ok := resetReasonToOutlierResult(reason); ok {
+		r.putOutlierResult(result)
+	}
 	r.downStream.sendNotify()
 }
 
 func (r *upstreamRequest) OnDestroyStream() {}
 
+// putOutlierResult reports the upstream request's result to the cluster's outlier detector
+func (r *upstreamRequest) putOutlierResult(result types.OutlierResult) {
+	if r.host == nil || r.downStream.cluster == nil {
+		return
+	}
+	if detector := r.downStream.cluster.OutlierDetector(); detector != nil {
+		detector.PutResult(r.host, result)
+	}
+}
+
+// resetReasonToOutlierResult maps the stream reset reason to the outlier result
+// the stream reset by local or overflow is not the host's fault, will not be reported
+func resetReasonToOutlierResult(reason types.StreamResetReason) (types.OutlierResult, bool) {
+	switch reason {
+	case types.StreamConnectionFailed:
+		return types.OutlierResultConnectFailed, true
+	case types.StreamConnectionTermination, types.StreamRemoteReset, types.StreamRefusedReset,
+		types.UpstreamPerTryTimeout, types.UpstreamGlobalTimeout:
+		return types.OutlierResultRequestFailed, true
+	}
+	return types.OutlierResultSuccess, false
+}
+
 func (r *upstreamRequest) endStream() {
 	upstreamResponseDurationNs := time.Now().Sub(r.startTime).Nanoseconds()
 	r.host.HostStats().UpstreamRequestDuration.Update(upstreamResponseDurationNs)
@@ -105,8 +137,15 @@ func (r *upstreamRequest) OnReceive(ctx context.Context, headers types.HeaderMap
 
 	r.endStream()
 
-	if code, err := protocol.MappingHeaderStatusCode(r.protocol, headers); err == nil {
+	if code, err := responseStatusCode(r.protocol, headers, trailers); err == nil {
 		r.downStream.requestInfo.SetResponseCode(code)
+		if code >= http.InternalServerError {
+			r.putOutlierResult(types.OutlierResultServerError)
+		} else {
+			r.putOutlierResult(types.OutlierResultSuccess)
+		}
+	} else {
+		r.putOutlierResult(types.OutlierResultSuccess)
 	}
 
 	r.downStream.requestInfo.SetResponseReceivedDuration(time.Now())
@@ -164,6 +203,7 @@ func (r *upstreamRequest) appendHeaders(endStream bool) {
 		log.Proxy.Debugf(r.downStream.context, "[proxy] [upstream] append headers: %+v", r.downStream.downstreamReqHeaders)
 	}
 	r.sendComplete = endStream
+	r.connectStartTime = time.Now()
 
 	if r.downStream.oneway {
 		r.connPool.NewStream(r.downStream.context, nil, r)
@@ -277,6 +317,20 @@ func (r *upstreamRequest) OnReady(sender types.StreamSender, host types.Host) {
 	r.requestSender.GetStream().AddEventListener(r)
 	// start a upstream send
 	r.startTime = time.Now()
+	r.downStream.requestInfo.SetUpstreamConnectDuration(r.startTime.Sub(r.connectStartTime))
+
+	// rewrite the host header with the selected upstream host's hostname
+	if rule := r.downStream.route.RouteRule(); rule.AutoHostRewrite() && host.Hostname() != "" {
+		r.downStream.downstreamReqHeaders.Set(protocol.MosnHeaderHostKey, host.Hostname())
+		r.downStream.downstreamReqHeaders.Set(protocol.IstioHeaderHostKey, host.Hostname())
+	}
+
+	// propagate the trace context to the upstream
+	if trace.IsEnabled() {
+		if span := trace.SpanFromContext(r.downStream.context); span != nil {
+			span.InjectContext(r.downStream.downstreamReqHeaders)
+		}
+	}
 
 	endStream := r.sendComplete && !r.dataSent && !r.trailerSent
 	r.requestSender.AppendHeaders(r.downStream.context, r.convertHeader(r.downStream.downstreamReqHeaders), endStream)
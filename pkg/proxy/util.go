@@ -21,6 +21,8 @@ import (
 	"strconv"
 	"time"
 
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/grpc"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -45,6 +47,14 @@ func parseProxyTimeout(timeout *Timeout, route types.Route, headers types.Header
 		}
 	}
 
+	// the grpc deadline bounds the whole request, a longer one does not extend the configured timeout
+	if gto, ok := headers.Get(grpc.HeaderTimeout); ok && gto != "" {
+		if grpctimeout, err := grpc.ParseTimeout(gto); err == nil && grpctimeout > 0 &&
+			(timeout.GlobalTimeout == 0 || grpctimeout < timeout.GlobalTimeout) {
+			timeout.GlobalTimeout = grpctimeout
+		}
+	}
+
 	if timeout.GlobalTimeout == 0 {
 		timeout.GlobalTimeout = types.GlobalTimeout
 	}
@@ -53,3 +63,12 @@ func parseProxyTimeout(timeout *Timeout, route types.Route, headers types.Header
 		timeout.TryTimeout = 0
 	}
 }
+
+// responseStatusCode maps the upstream response to a http status code,
+// the grpc-status carried by the trailers takes precedence over the headers
+func responseStatusCode(p types.Protocol, headers, trailers types.HeaderMap) (int, error) {
+	if code, ok := grpc.MappingStatusCode(trailers); ok {
+		return code, nil
+	}
+	return protocol.MappingHeaderStatusCode(p, headers)
+}
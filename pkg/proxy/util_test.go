@@ -0,0 +1,93 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type timeoutRouteRule struct {
+	mockRouteRule
+	globalTimeout time.Duration
+}
+
+func (r *timeoutRouteRule) GlobalTimeout() time.Duration {
+	return r.globalTimeout
+}
+
+type timeoutPolicy struct {
+	types.Policy
+}
+
+func (p *timeoutPolicy) RetryPolicy() types.RetryPolicy {
+	return &timeoutRetryPolicy{}
+}
+
+type timeoutRetryPolicy struct {
+	types.RetryPolicy
+}
+
+func (p *timeoutRetryPolicy) TryTimeout() time.Duration {
+	return 0
+}
+
+func TestParseProxyTimeoutGrpc(t *testing.T) {
+	testcases := []struct {
+		routeTimeout time.Duration
+		headers      protocol.CommonHeader
+		expected     time.Duration
+	}{
+		{0, protocol.CommonHeader{}, types.GlobalTimeout},
+		{0, protocol.CommonHeader{"grpc-timeout": "100m"}, 100 * time.Millisecond},
+		{time.Second, protocol.CommonHeader{"grpc-timeout": "100m"}, 100 * time.Millisecond},
+		{time.Second, protocol.CommonHeader{"grpc-timeout": "2S"}, time.Second},
+		{time.Second, protocol.CommonHeader{"grpc-timeout": "invalid"}, time.Second},
+		{time.Second, protocol.CommonHeader{"grpc-timeout": "0m"}, time.Second},
+		{0, protocol.CommonHeader{types.HeaderGlobalTimeout: "3000", "grpc-timeout": "5S"}, 3 * time.Second},
+	}
+	for i, tc := range testcases {
+		route := &mockRoute{
+			rule: &timeoutRouteRule{
+				mockRouteRule: mockRouteRule{policy: &timeoutPolicy{}},
+				globalTimeout: tc.routeTimeout,
+			},
+		}
+		timeout := &Timeout{}
+		parseProxyTimeout(timeout, route, tc.headers)
+		if timeout.GlobalTimeout != tc.expected {
+			t.Errorf("#%d expected timeout %v, but got %v", i, tc.expected, timeout.GlobalTimeout)
+		}
+	}
+}
+
+func TestResponseStatusCode(t *testing.T) {
+	headers := protocol.CommonHeader{types.HeaderStatus: "200"}
+	if code, err := responseStatusCode(protocol.HTTP2, headers, nil); err != nil || code != 200 {
+		t.Errorf("unexpected status code %d %v", code, err)
+	}
+	if code, err := responseStatusCode(protocol.HTTP2, headers, protocol.CommonHeader{"grpc-status": "5"}); err != nil || code != 404 {
+		t.Errorf("unexpected status code %d %v", code, err)
+	}
+	if code, err := responseStatusCode(protocol.HTTP2, headers, protocol.CommonHeader{"x-other": "1"}); err != nil || code != 200 {
+		t.Errorf("unexpected status code %d %v", code, err)
+	}
+}
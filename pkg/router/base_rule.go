@@ -19,6 +19,7 @@ package router
 
 import (
 	"math/rand"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -31,6 +32,7 @@ import (
 )
 
 type RouteRuleImplBase struct {
+	name string
 	// match
 	vHost                 *VirtualHostImpl
 	routerMatch           v2.RouterMatch
@@ -38,17 +40,22 @@ type RouteRuleImplBase struct {
 	configQueryParameters []types.QueryParameterMatcher //TODO: not implement yet
 	// rewrite
 	prefixRewrite         string
+	regexRewrite          *regexp.Regexp
+	regexSubstitution     string
 	hostRewrite           string
-	autoHostRewrite       bool // TODO: not implement yet
+	autoHostRewrite       bool
 	requestHeadersParser  *headerParser
 	responseHeadersParser *headerParser
 	// information
 	upstreamProtocol string
 	perFilterConfig  map[string]interface{}
+	priority         types.ResourcePriority
 	// policy
 	policy *policy
 	// direct response
 	directResponseRule *directResponseImpl
+	// redirect
+	redirectRule *redirectImpl
 	// action
 	routerAction       v2.RouteAction
 	defaultCluster     *weightedClusterEntry // cluster name and metadata
@@ -60,6 +67,7 @@ type RouteRuleImplBase struct {
 
 func NewRouteRuleImplBase(vHost *VirtualHostImpl, route *v2.Router) (*RouteRuleImplBase, error) {
 	base := &RouteRuleImplBase{
+		name:                  route.Name,
 		vHost:                 vHost,
 		routerMatch:           route.Match,
 		configHeaders:         getRouterHeaders(route.Match.Headers),
@@ -70,6 +78,7 @@ func NewRouteRuleImplBase(vHost *VirtualHostImpl, route *v2.Router) (*RouteRuleI
 		responseHeadersParser: getHeaderParser(route.Route.ResponseHeadersToAdd, route.Route.ResponseHeadersToRemove),
 		upstreamProtocol:      route.Route.UpstreamProtocol,
 		perFilterConfig:       route.PerFilterConfig,
+		priority:              getRoutingPriority(route.Route.Priority),
 		policy:                &policy{},
 		routerAction:          route.Route,
 		defaultCluster: &weightedClusterEntry{
@@ -84,11 +93,13 @@ func NewRouteRuleImplBase(vHost *VirtualHostImpl, route *v2.Router) (*RouteRuleI
 	}
 	// add policy
 	if route.Route.RetryPolicy != nil {
-		base.policy.retryPolicy = &retryPolicyImpl{
-			retryOn:      route.Route.RetryPolicy.RetryOn,
-			retryTimeout: route.Route.RetryPolicy.RetryTimeout,
-			numRetries:   route.Route.RetryPolicy.NumRetries,
-		}
+		base.policy.retryPolicy = newRetryPolicyImpl(route.Route.RetryPolicy)
+	}
+	if len(route.Route.HashPolicy) > 0 {
+		base.policy.hashPolicy = newHashPolicyImpl(route.Route.HashPolicy)
+	}
+	if route.Route.ShadowPolicy != nil {
+		base.policy.shadowPolicy = newShadowPolicyImpl(route.Route.ShadowPolicy)
 	}
 	// add direct repsonse rule
 	if route.DirectResponse != nil {
@@ -97,13 +108,47 @@ func NewRouteRuleImplBase(vHost *VirtualHostImpl, route *v2.Router) (*RouteRuleI
 			body:   route.DirectResponse.Body,
 		}
 	}
+	// add redirect rule
+	if route.Redirect != nil {
+		base.redirectRule = newRedirectImpl(route.Match, route.Redirect)
+	}
+	// add regex rewrite
+	if rewrite := route.Route.RegexRewrite; rewrite != nil && rewrite.Pattern != "" {
+		regex, err := regexp.Compile(rewrite.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		base.regexRewrite = regex
+		base.regexSubstitution = convertRegexSubstitution(rewrite.Substitution)
+	}
 	return base, nil
 }
 
+// substitutionGroup matches the capture group reference like \1 in the substitution
+var substitutionGroup = regexp.MustCompile(`\\(\d+)`)
+
+// convertRegexSubstitution converts the capture group reference \N to ${N}, which is used by regexp.ReplaceAllString
+func convertRegexSubstitution(substitution string) string {
+	return substitutionGroup.ReplaceAllString(substitution, "$${${1}}")
+}
+
 func (rri *RouteRuleImplBase) DirectResponseRule() types.DirectResponseRule {
 	return rri.directResponseRule
 }
 
+// RedirectRule returns an untyped nil if the route is not a redirect route
+func (rri *RouteRuleImplBase) RedirectRule() types.RedirectRule {
+	if rri.redirectRule == nil {
+		return nil
+	}
+	return rri.redirectRule
+}
+
+// types.RouteRule
+func (rri *RouteRuleImplBase) RouteName() string {
+	return rri.name
+}
+
 // types.RouteRule
 // Select Cluster for Routing
 // if weighted cluster is nil, return clusterName directly, else
@@ -156,6 +201,14 @@ func (rri *RouteRuleImplBase) PerFilterConfig() map[string]interface{} {
 	return rri.perFilterConfig
 }
 
+func (rri *RouteRuleImplBase) AutoHostRewrite() bool {
+	return rri.autoHostRewrite
+}
+
+func (rri *RouteRuleImplBase) Priority() types.ResourcePriority {
+	return rri.priority
+}
+
 // matchRoute is a common matched for http
 func (rri *RouteRuleImplBase) matchRoute(headers types.HeaderMap, randomValue uint64) bool {
 	// 1. match headers' KV
@@ -178,10 +231,17 @@ func (rri *RouteRuleImplBase) matchRoute(headers types.HeaderMap, randomValue ui
 }
 
 func (rri *RouteRuleImplBase) finalizePathHeader(headers types.HeaderMap, matchedPath string) {
-	if len(rri.prefixRewrite) < 1 {
+	if len(rri.prefixRewrite) < 1 && rri.regexRewrite == nil {
 		return
 	}
 	if path, ok := headers.Get(protocol.MosnHeaderPathKey); ok {
+		// regex rewrite takes precedence over prefix rewrite
+		if rri.regexRewrite != nil {
+			headers.Set(protocol.MosnOriginalHeaderPathKey, path)
+			headers.Set(protocol.MosnHeaderPathKey, rri.regexRewrite.ReplaceAllString(path, rri.regexSubstitution))
+			log.DefaultLogger.Infof(RouterLogFormat, "routerule", "finalizePathHeader", "rewrite path by regex, pattern is "+rri.regexRewrite.String())
+			return
+		}
 		if strings.HasPrefix(path, matchedPath) {
 			headers.Set(protocol.MosnOriginalHeaderPathKey, path)
 			headers.Set(protocol.MosnHeaderPathKey, rri.prefixRewrite+path[len(matchedPath):])
@@ -199,7 +259,7 @@ func (rri *RouteRuleImplBase) finalizeRequestHeaders(headers types.HeaderMap, re
 	rri.vHost.requestHeadersParser.evaluateHeaders(headers, requestInfo)
 	rri.vHost.globalRouteConfig.requestHeadersParser.evaluateHeaders(headers, requestInfo)
 	if len(rri.hostRewrite) > 0 {
-		headers.Set(protocol.IstioHeaderHostKey, rri.hostRewrite)
+		rewriteHost(headers, rri.hostRewrite)
 	}
 }
 
@@ -208,3 +268,10 @@ func (rri *RouteRuleImplBase) FinalizeResponseHeaders(headers types.HeaderMap, r
 	rri.vHost.responseHeadersParser.evaluateHeaders(headers, requestInfo)
 	rri.vHost.globalRouteConfig.responseHeadersParser.evaluateHeaders(headers, requestInfo)
 }
+
+// rewriteHost rewrites the request's host, the http1 upstream uses the IstioHeaderHostKey,
+// and the http2 upstream uses the MosnHeaderHostKey
+func rewriteHost(headers types.HeaderMap, host string) {
+	headers.Set(protocol.MosnHeaderHostKey, host)
+	headers.Set(protocol.IstioHeaderHostKey, host)
+}
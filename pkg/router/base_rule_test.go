@@ -248,7 +248,7 @@ func Test_RouteRuleImplBase_FinalizeRequestHeaders(t *testing.T) {
 				headers:     protocol.CommonHeader{"host": "xxx.default.svc.cluster.local"},
 				requestInfo: nil,
 			},
-			want: protocol.CommonHeader{"host": "xxx.default.svc.cluster.local", "authority": "www.xxx.com", "level": "1,2,3", "route": "true", "vhost": "true", "global": "true"},
+			want: protocol.CommonHeader{"host": "xxx.default.svc.cluster.local", "authority": "www.xxx.com", protocol.MosnHeaderHostKey: "www.xxx.com", "level": "1,2,3", "route": "true", "vhost": "true", "global": "true"},
 		},
 
 		{
@@ -459,3 +459,104 @@ func Test_RouteRuleImplBase_FinalizeResponseHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestShadowPolicy(t *testing.T) {
+	testCases := []struct {
+		config  *v2.ShadowPolicy
+		cluster string
+		percent float64
+	}{
+		{nil, "", 0},
+		{&v2.ShadowPolicy{}, "", 0},
+		{&v2.ShadowPolicy{Cluster: "shadow"}, "shadow", 100},
+		{&v2.ShadowPolicy{Cluster: "shadow", Percent: 30}, "shadow", 30},
+		{&v2.ShadowPolicy{Cluster: "shadow", Percent: 200}, "shadow", 100},
+		{&v2.ShadowPolicy{Cluster: "shadow", Percent: 0.01}, "shadow", 0.01},
+	}
+	for i, tc := range testCases {
+		route := &v2.Router{}
+		route.Route.ShadowPolicy = tc.config
+		rule, err := NewRouteRuleImplBase(nil, route)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sp := rule.Policy().ShadowPolicy()
+		if sp.ClusterName() != tc.cluster || sp.Percent() != tc.percent {
+			t.Errorf("#%d unexpected shadow policy, cluster: %s, percent: %v", i, sp.ClusterName(), sp.Percent())
+		}
+	}
+}
+
+func TestRegexRewrite(t *testing.T) {
+	route := &v2.Router{
+		RouterConfig: v2.RouterConfig{
+			Match: v2.RouterMatch{
+				Prefix: "/",
+			},
+			Route: v2.RouteAction{
+				RouterActionConfig: v2.RouterActionConfig{
+					ClusterName: "test",
+					RegexRewrite: &v2.RegexRewrite{
+						Pattern:      "^/service/([^/]+)(/.*)$",
+						Substitution: "${2}/instance/${1}",
+					},
+				},
+			},
+		},
+	}
+	rule, err := NewRouteRuleImplBase(nil, route)
+	if err != nil {
+		t.Fatal("create route rule failed, ", err)
+	}
+	headers := protocol.CommonHeader{protocol.MosnHeaderPathKey: "/service/foo/v1/api"}
+	rule.finalizePathHeader(headers, "/")
+	want := protocol.CommonHeader{protocol.MosnHeaderPathKey: "/v1/api/instance/foo", protocol.MosnOriginalHeaderPathKey: "/service/foo/v1/api"}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("regex rewrite path is not expected, got %v, want %v", headers, want)
+	}
+	// the capture groups in \N style
+	route.Route.RegexRewrite.Substitution = `\2/instance/\1`
+	rule, err = NewRouteRuleImplBase(nil, route)
+	if err != nil {
+		t.Fatal("create route rule failed, ", err)
+	}
+	headers = protocol.CommonHeader{protocol.MosnHeaderPathKey: "/service/foo/v1/api"}
+	rule.finalizePathHeader(headers, "/")
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("regex rewrite path is not expected, got %v, want %v", headers, want)
+	}
+	// invalid regex
+	route.Route.RegexRewrite.Pattern = "(["
+	if _, err := NewRouteRuleImplBase(nil, route); err == nil {
+		t.Error("expected an error for invalid regex rewrite pattern")
+	}
+}
+
+func TestConvertRegexSubstitution(t *testing.T) {
+	testCases := []struct {
+		substitution string
+		want         string
+	}{
+		{`\1`, "${1}"},
+		{`/v\2/\10`, "/v${2}/${10}"},
+		{"${1}/$2", "${1}/$2"},
+		{"/static", "/static"},
+	}
+	for i, tc := range testCases {
+		if got := convertRegexSubstitution(tc.substitution); got != tc.want {
+			t.Errorf("#%d convert substitution %s, got %s, want %s", i, tc.substitution, got, tc.want)
+		}
+	}
+}
+
+func TestRouteName(t *testing.T) {
+	route := &v2.Router{}
+	route.Name = "test_route"
+	rule, err := NewRouteRuleImplBase(nil, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.RouteName() != "test_route" {
+		t.Errorf("unexpected route name: %s", rule.RouteName())
+	}
+}
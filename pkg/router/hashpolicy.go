@@ -0,0 +1,131 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"net"
+	"strings"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+const cookieHeaderKey = "cookie"
+
+// hashKeyGenerator returns the key string used to generate the hash
+type hashKeyGenerator func(headers types.HeaderMap, remoteAddr net.Addr) (string, bool)
+
+// hashPolicyImpl is an implementation of types.HashPolicy
+// the hash keys generated by each policy are combined in order
+type hashPolicyImpl struct {
+	generators []hashKeyGenerator
+}
+
+func newHashPolicyImpl(policies []v2.HashPolicy) *hashPolicyImpl {
+	generators := make([]hashKeyGenerator, 0, len(policies))
+	for _, p := range policies {
+		switch {
+		case p.Header != nil && p.Header.Key != "":
+			generators = append(generators, headerHashKey(p.Header.Key))
+		case p.SofaService != nil:
+			generators = append(generators, headerHashKey(types.SofaRouteMatchKey))
+		case p.Cookie != nil && p.Cookie.Name != "":
+			generators = append(generators, cookieHashKey(p.Cookie.Name))
+		case p.ConnectionProperties != nil && p.ConnectionProperties.SourceIP:
+			generators = append(generators, sourceIPHashKey)
+		}
+	}
+	if len(generators) == 0 {
+		return nil
+	}
+	return &hashPolicyImpl{
+		generators: generators,
+	}
+}
+
+func (hp *hashPolicyImpl) GenerateHash(headers types.HeaderMap, remoteAddr net.Addr) (uint64, bool) {
+	if hp == nil {
+		return 0, false
+	}
+	var hash uint64
+	generated := false
+	for _, gen := range hp.generators {
+		key, ok := gen(headers, remoteAddr)
+		if !ok {
+			continue
+		}
+		if generated {
+			hash = utils.CombineHash(hash, utils.HashString(key))
+		} else {
+			hash = utils.HashString(key)
+			generated = true
+		}
+	}
+	return hash, generated
+}
+
+func headerHashKey(key string) hashKeyGenerator {
+	return func(headers types.HeaderMap, remoteAddr net.Addr) (string, bool) {
+		if headers == nil {
+			return "", false
+		}
+		value, ok := headers.Get(key)
+		if !ok || value == "" {
+			return "", false
+		}
+		return value, true
+	}
+}
+
+func cookieHashKey(name string) hashKeyGenerator {
+	return func(headers types.HeaderMap, remoteAddr net.Addr) (string, bool) {
+		if headers == nil {
+			return "", false
+		}
+		cookies, ok := headers.Get(cookieHeaderKey)
+		if !ok {
+			return "", false
+		}
+		for _, cookie := range strings.Split(cookies, ";") {
+			cookie = strings.TrimSpace(cookie)
+			if idx := strings.IndexByte(cookie, '='); idx > 0 && cookie[:idx] == name {
+				if value := cookie[idx+1:]; value != "" {
+					return value, true
+				}
+			}
+		}
+		return "", false
+	}
+}
+
+func sourceIPHashKey(headers types.HeaderMap, remoteAddr net.Addr) (string, bool) {
+	if remoteAddr == nil {
+		return "", false
+	}
+	switch addr := remoteAddr.(type) {
+	case *net.TCPAddr:
+		return addr.IP.String(), true
+	case *net.UDPAddr:
+		return addr.IP.String(), true
+	}
+	if host, _, err := net.SplitHostPort(remoteAddr.String()); err == nil {
+		return host, true
+	}
+	return remoteAddr.String(), true
+}
@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"net"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestHashPolicy(t *testing.T) {
+	route := &v2.Router{}
+	route.Route = v2.RouteAction{
+		RouterActionConfig: v2.RouterActionConfig{
+			ClusterName: "test",
+			HashPolicy: []v2.HashPolicy{
+				{Header: &v2.HeaderHashPolicy{Key: "x-user"}},
+			},
+		},
+	}
+	rule, err := NewRouteRuleImplBase(nil, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := rule.Policy().HashPolicy()
+	h1, ok := hp.GenerateHash(protocol.CommonHeader{"x-user": "alice"}, nil)
+	if !ok {
+		t.Fatal("generate hash failed")
+	}
+	if h2, _ := hp.GenerateHash(protocol.CommonHeader{"x-user": "alice"}, nil); h1 != h2 {
+		t.Fatal("same header value should generate same hash")
+	}
+	if h3, _ := hp.GenerateHash(protocol.CommonHeader{"x-user": "bob"}, nil); h1 == h3 {
+		t.Fatal("different header value should generate different hash")
+	}
+	if _, ok := hp.GenerateHash(protocol.CommonHeader{}, nil); ok {
+		t.Fatal("no header should not generate hash")
+	}
+	// no hash policy
+	noHashRule, _ := NewRouteRuleImplBase(nil, &v2.Router{})
+	if noHashRule.Policy().HashPolicy() != nil {
+		t.Fatal("route without hash policy should return a nil hash policy")
+	}
+}
+
+func TestHashPolicyKeys(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 12345}
+	otherPort := &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 54321}
+	testCases := []struct {
+		name     string
+		policy   v2.HashPolicy
+		headers  types.HeaderMap
+		remote   net.Addr
+		expected string
+	}{
+		{
+			name:     "sofa service",
+			policy:   v2.HashPolicy{SofaService: &v2.SofaServiceHashPolicy{}},
+			headers:  protocol.CommonHeader{types.SofaRouteMatchKey: "com.alipay.test.TestService:1.0"},
+			expected: "com.alipay.test.TestService:1.0",
+		},
+		{
+			name:     "cookie",
+			policy:   v2.HashPolicy{Cookie: &v2.CookieHashPolicy{Name: "session"}},
+			headers:  protocol.CommonHeader{"cookie": "a=1; session=abcd; b=2"},
+			expected: "abcd",
+		},
+		{
+			name:     "source ip",
+			policy:   v2.HashPolicy{ConnectionProperties: &v2.ConnectionPropertiesHashPolicy{SourceIP: true}},
+			remote:   remote,
+			expected: "10.1.1.1",
+		},
+	}
+	for _, tc := range testCases {
+		hp := newHashPolicyImpl([]v2.HashPolicy{tc.policy})
+		hash, ok := hp.GenerateHash(tc.headers, tc.remote)
+		if !ok {
+			t.Fatalf("%s generate hash failed", tc.name)
+		}
+		expected, _ := newHashPolicyImpl([]v2.HashPolicy{
+			{Header: &v2.HeaderHashPolicy{Key: "key"}},
+		}).GenerateHash(protocol.CommonHeader{"key": tc.expected}, nil)
+		if hash != expected {
+			t.Fatalf("%s generate hash not expected", tc.name)
+		}
+	}
+	// source ip hash ignores the port
+	hp := newHashPolicyImpl([]v2.HashPolicy{
+		{ConnectionProperties: &v2.ConnectionPropertiesHashPolicy{SourceIP: true}},
+	})
+	h1, _ := hp.GenerateHash(nil, remote)
+	h2, _ := hp.GenerateHash(nil, otherPort)
+	if h1 != h2 {
+		t.Fatal("source ip hash should ignore the port")
+	}
+	// cookie not found
+	cookieHp := newHashPolicyImpl([]v2.HashPolicy{
+		{Cookie: &v2.CookieHashPolicy{Name: "session"}},
+	})
+	if _, ok := cookieHp.GenerateHash(protocol.CommonHeader{"cookie": "sessionid=1"}, nil); ok {
+		t.Fatal("cookie not found should not generate hash")
+	}
+}
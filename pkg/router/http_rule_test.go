@@ -154,3 +154,39 @@ func TestRegexRouteRuleImpl(t *testing.T) {
 		}
 	}
 }
+
+func TestRegexRouteRulePrefixRewrite(t *testing.T) {
+	virtualHostImpl := &VirtualHostImpl{
+		virtualHostName:   "test",
+		globalRouteConfig: &configImpl{},
+	}
+	route := &v2.Router{
+		RouterConfig: v2.RouterConfig{
+			Match: v2.RouterMatch{Regex: "/foo"},
+			Route: v2.RouteAction{
+				RouterActionConfig: v2.RouterActionConfig{
+					ClusterName:   "test",
+					PrefixRewrite: "/bar",
+				},
+			},
+		},
+	}
+	routeRule, _ := NewRouteRuleImplBase(virtualHostImpl, route)
+	rr := &RegexRouteRuleImpl{
+		routeRule,
+		route.Match.Regex,
+		regexp.MustCompile(route.Match.Regex),
+	}
+	// the path prefix same as the regex string is rewritten
+	headers := protocol.CommonHeader{protocol.MosnHeaderPathKey: "/foo/index.html"}
+	rr.FinalizeRequestHeaders(headers, nil)
+	if path, _ := headers.Get(protocol.MosnHeaderPathKey); path != "/bar/index.html" {
+		t.Errorf("unexpected rewritten path: %s", path)
+	}
+	// the path has no such prefix is not rewritten
+	headers = protocol.CommonHeader{protocol.MosnHeaderPathKey: "/a/foo"}
+	rr.FinalizeRequestHeaders(headers, nil)
+	if path, _ := headers.Get(protocol.MosnHeaderPathKey); path != "/a/foo" {
+		t.Errorf("unexpected rewritten path: %s", path)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"strings"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	httpmosn "sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const defaultRedirectScheme = "http"
+
+type redirectImpl struct {
+	code          int
+	scheme        string
+	host          string
+	path          string
+	prefixRewrite string
+	matchPrefix   string
+	stripQuery    bool
+}
+
+func newRedirectImpl(match v2.RouterMatch, redirect *v2.RedirectAction) *redirectImpl {
+	code := redirect.ResponseCode
+	switch code {
+	case httpmosn.MovedPermanently, httpmosn.Found, httpmosn.SeeOther, httpmosn.TemporaryRedirect, httpmosn.PermanentRedirect:
+	case 0:
+		code = httpmosn.MovedPermanently
+	default:
+		log.DefaultLogger.Errorf(RouterLogFormat, "redirect", "newRedirectImpl", "invalid redirect response code, use 301 instead")
+		code = httpmosn.MovedPermanently
+	}
+	return &redirectImpl{
+		code:          code,
+		scheme:        redirect.SchemeRedirect,
+		host:          redirect.HostRedirect,
+		path:          redirect.PathRedirect,
+		prefixRewrite: redirect.PrefixRewrite,
+		matchPrefix:   match.Prefix,
+		stripQuery:    redirect.StripQuery,
+	}
+}
+
+func (rule *redirectImpl) RedirectCode() int {
+	return rule.code
+}
+
+func (rule *redirectImpl) RedirectLocation(headers types.HeaderMap) string {
+	scheme := defaultRedirectScheme
+	if proto, ok := headers.Get(httpmosn.HeaderForwardedProto); ok && proto != "" {
+		scheme = proto
+	}
+	if rule.scheme != "" {
+		scheme = rule.scheme
+	}
+
+	host, _ := headers.Get(protocol.MosnHeaderHostKey)
+	if rule.host != "" {
+		host = rule.host
+	}
+
+	path, _ := headers.Get(protocol.MosnHeaderPathKey)
+	if rule.path != "" {
+		path = rule.path
+	} else if rule.prefixRewrite != "" && strings.HasPrefix(path, rule.matchPrefix) {
+		path = rule.prefixRewrite + path[len(rule.matchPrefix):]
+	}
+
+	location := scheme + "://" + host + path
+	if !rule.stripQuery {
+		if query, ok := headers.Get(protocol.MosnHeaderQueryStringKey); ok && query != "" {
+			location += "?" + query
+		}
+	}
+	return location
+}
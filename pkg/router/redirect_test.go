@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	httpmosn "sofastack.io/sofa-mosn/pkg/protocol/http"
+)
+
+func TestRedirect(t *testing.T) {
+	routeConfigStr := `{
+		"match": {
+			"prefix": "/old/"
+		},
+		"redirect": {
+			"response_code": 302,
+			"scheme_redirect": "https",
+			"host_redirect": "new.example.com",
+			"prefix_rewrite": "/new/"
+		}
+	}`
+	routeCfg := &v2.Router{}
+	if err := json.Unmarshal([]byte(routeConfigStr), routeCfg); err != nil {
+		t.Fatal("unmarshal config to router failed, ", err)
+	}
+	rule, err := NewRouteRuleImplBase(nil, routeCfg)
+	if err != nil {
+		t.Fatal("create route rule failed, ", err)
+	}
+	redirect := rule.RedirectRule()
+	if redirect == nil {
+		t.Fatal("rule have no redirect rule")
+	}
+	if redirect.RedirectCode() != 302 {
+		t.Errorf("redirect code is not expected, got %d", redirect.RedirectCode())
+	}
+	headers := protocol.CommonHeader{
+		protocol.MosnHeaderHostKey:        "old.example.com",
+		protocol.MosnHeaderPathKey:        "/old/index.html",
+		protocol.MosnHeaderQueryStringKey: "a=b",
+	}
+	if location := redirect.RedirectLocation(headers); location != "https://new.example.com/new/index.html?a=b" {
+		t.Errorf("redirect location is not expected, got %s", location)
+	}	// not a redirect route
+	noRedirectRule, _ := NewRouteRuleImplBase(nil, &v2.Router{})
+	if noRedirectRule.RedirectRule() != nil {
+		t.Error("route without redirect should return a nil redirect rule")
+	}
+}
+
+func TestRedirectLocation(t *testing.T) {
+	headers := protocol.CommonHeader{
+		protocol.MosnHeaderHostKey:        "www.example.com",
+		protocol.MosnHeaderPathKey:        "/index.html",
+		protocol.MosnHeaderQueryStringKey: "a=b",
+	}
+	testCases := []struct {
+		redirect *v2.RedirectAction
+		code     int
+		location string
+	}{
+		{
+			redirect: &v2.RedirectAction{},
+			code:     httpmosn.MovedPermanently,
+			location: "http://www.example.com/index.html?a=b",
+		},
+		{
+			redirect: &v2.RedirectAction{
+				ResponseCode: 200, // invalid redirect code
+				PathRedirect: "/new.html",
+				StripQuery:   true,
+			},
+			code:     httpmosn.MovedPermanently,
+			location: "http://www.example.com/new.html",
+		},
+		{
+			redirect: &v2.RedirectAction{
+				ResponseCode:   httpmosn.PermanentRedirect,
+				SchemeRedirect: "https",
+			},
+			code:     httpmosn.PermanentRedirect,
+			location: "https://www.example.com/index.html?a=b",
+		},
+	}
+	for i, tc := range testCases {
+		redirect := newRedirectImpl(v2.RouterMatch{Prefix: "/"}, tc.redirect)
+		if redirect.RedirectCode() != tc.code {
+			t.Errorf("#%d redirect code is not expected, got %d, want %d", i, redirect.RedirectCode(), tc.code)
+		}
+		if location := redirect.RedirectLocation(headers); location != tc.location {
+			t.Errorf("#%d redirect location is not expected, got %s, want %s", i, location, tc.location)
+		}
+	}
+	// scheme from the x-forwarded-proto header
+	redirect := newRedirectImpl(v2.RouterMatch{Prefix: "/"}, &v2.RedirectAction{})
+	forwarded := protocol.CommonHeader{
+		protocol.MosnHeaderHostKey:    "www.example.com",
+		protocol.MosnHeaderPathKey:    "/",
+		httpmosn.HeaderForwardedProto: "https",
+	}
+	if location := redirect.RedirectLocation(forwarded); location != "https://www.example.com/" {
+		t.Errorf("redirect location is not expected, got %s", location)
+	}
+}
@@ -0,0 +1,200 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// default retry budget, same as envoy
+const (
+	defaultRetryBudgetPercent        = 20.0
+	defaultRetryBudgetMinConcurrency = 3
+)
+
+var retryConditions = map[v2.RetryCondition]types.RetryCondition{
+	v2.RETRY_ON_5XX:                      types.RetryOn5xx,
+	v2.RETRY_ON_GATEWAY_ERROR:            types.RetryOnGatewayError,
+	v2.RETRY_ON_CONNECT_FAILURE:          types.RetryOnConnectFailure,
+	v2.RETRY_ON_REFUSED_STREAM:           types.RetryOnRefusedStream,
+	v2.RETRY_ON_RETRIABLE_STATUS_CODES:   types.RetryOnRetriableStatusCodes,
+	v2.RETRY_ON_RETRIABLE_SOFARPC_STATUS: types.RetryOnRetriableSofaRpcStatus,
+	v2.RETRY_ON_RESET:                    types.RetryOnReset,
+}
+
+// unknownRetryConditions records the unknown retry conditions have been warned
+var unknownRetryConditions sync.Map
+
+type retryPolicyImpl struct {
+	retryOn                       bool
+	retryTimeout                  time.Duration
+	numRetries                    uint32
+	conditions                    types.RetryCondition
+	retriableStatusCodes          []uint32
+	retriableSofaRpcStatus        []uint32
+	backOffBaseInterval           time.Duration
+	backOffMaxInterval            time.Duration
+	hostSelectionRetryMaxAttempts uint32
+	budget                        *retryBudgetImpl
+}
+
+func newRetryPolicyImpl(config *v2.RetryPolicy) *retryPolicyImpl {
+	p := &retryPolicyImpl{
+		retryOn:                       config.RetryOn,
+		retryTimeout:                  config.RetryTimeout,
+		numRetries:                    config.NumRetries,
+		retriableStatusCodes:          config.RetriableStatusCodes,
+		retriableSofaRpcStatus:        config.RetriableSofaRpcStatus,
+		hostSelectionRetryMaxAttempts: config.HostSelectionRetryMaxAttempts,
+	}
+	for _, name := range config.RetryConditions {
+		if cond, ok := retryConditions[name]; ok {
+			p.conditions |= cond
+		} else if _, warned := unknownRetryConditions.LoadOrStore(name, struct{}{}); !warned {
+			log.DefaultLogger.Warnf(RouterLogFormat, "retrypolicy", "newRetryPolicyImpl", "unknown retry condition is ignored: "+string(name))
+		}
+	}
+	if config.RetryBackOff != nil {
+		p.backOffBaseInterval = config.RetryBackOff.BaseIntervalConfig.Duration
+		p.backOffMaxInterval = config.RetryBackOff.MaxIntervalConfig.Duration
+	}
+	if config.RetryBudget != nil {
+		p.budget = newRetryBudgetImpl(config.RetryBudget)
+	}
+	return p
+}
+
+func (p *retryPolicyImpl) RetryOn() bool {
+	if p == nil {
+		return false
+	}
+	return p.retryOn
+}
+
+func (p *retryPolicyImpl) TryTimeout() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return p.retryTimeout
+}
+
+func (p *retryPolicyImpl) NumRetries() uint32 {
+	if p == nil {
+		return 0
+	}
+	return p.numRetries
+}
+
+func (p *retryPolicyImpl) RetryConditions() types.RetryCondition {
+	if p == nil {
+		return 0
+	}
+	return p.conditions
+}
+
+func (p *retryPolicyImpl) RetriableStatusCodes() []uint32 {
+	if p == nil {
+		return nil
+	}
+	return p.retriableStatusCodes
+}
+
+func (p *retryPolicyImpl) RetriableSofaRpcStatus() []uint32 {
+	if p == nil {
+		return nil
+	}
+	return p.retriableSofaRpcStatus
+}
+
+func (p *retryPolicyImpl) RetryBackOff() (time.Duration, time.Duration) {
+	if p == nil {
+		return 0, 0
+	}
+	return p.backOffBaseInterval, p.backOffMaxInterval
+}
+
+func (p *retryPolicyImpl) HostSelectionRetryMaxAttempts() uint32 {
+	if p == nil {
+		return 0
+	}
+	return p.hostSelectionRetryMaxAttempts
+}
+
+func (p *retryPolicyImpl) RetryBudget() types.RetryBudget {
+	// avoid returns a non-nil interface with nil value
+	if p == nil || p.budget == nil {
+		return nil
+	}
+	return p.budget
+}
+
+// retryBudgetImpl is shared by all the requests of a route
+type retryBudgetImpl struct {
+	percent        float64
+	minConcurrency int64
+	activeRequests int64
+	activeRetries  int64
+}
+
+func newRetryBudgetImpl(config *v2.RetryBudgetConfig) *retryBudgetImpl {
+	b := &retryBudgetImpl{
+		percent:        config.BudgetPercent,
+		minConcurrency: int64(config.MinRetryConcurrency),
+	}
+	if b.percent <= 0 {
+		b.percent = defaultRetryBudgetPercent
+	}
+	if b.minConcurrency == 0 {
+		b.minConcurrency = defaultRetryBudgetMinConcurrency
+	}
+	return b
+}
+
+func (b *retryBudgetImpl) OnRequestStart() {
+	atomic.AddInt64(&b.activeRequests, 1)
+}
+
+func (b *retryBudgetImpl) OnRequestFinish() {
+	atomic.AddInt64(&b.activeRequests, -1)
+}
+
+func (b *retryBudgetImpl) TryAcquire() bool {
+	limit := int64(float64(atomic.LoadInt64(&b.activeRequests)) * b.percent / 100)
+	if limit < b.minConcurrency {
+		limit = b.minConcurrency
+	}
+	for {
+		active := atomic.LoadInt64(&b.activeRetries)
+		if active >= limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.activeRetries, active, active+1) {
+			return true
+		}
+	}
+}
+
+func (b *retryBudgetImpl) Release() {
+	atomic.AddInt64(&b.activeRetries, -1)
+}
@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	route := &v2.Router{}
+	route.Route.RetryPolicy = &v2.RetryPolicy{
+		RetryPolicyConfig: v2.RetryPolicyConfig{
+			RetryOn:         true,
+			NumRetries:      2,
+			RetryConditions: []v2.RetryCondition{v2.RETRY_ON_GATEWAY_ERROR, v2.RETRY_ON_CONNECT_FAILURE, "unknown"},
+			RetryBackOff: &v2.RetryBackOffConfig{
+				BaseIntervalConfig: v2.DurationConfig{Duration: time.Millisecond},
+				MaxIntervalConfig:  v2.DurationConfig{Duration: time.Second},
+			},
+			RetryBudget: &v2.RetryBudgetConfig{},
+		},
+	}
+	rule, err := NewRouteRuleImplBase(nil, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := rule.Policy().RetryPolicy()
+	if !rp.RetryOn() || rp.NumRetries() != 2 {
+		t.Error("unexpected retry policy")
+	}
+	if rp.RetryConditions() != types.RetryOnGatewayError|types.RetryOnConnectFailure {
+		t.Errorf("unexpected retry conditions: %b", rp.RetryConditions())
+	}
+	if _, warned := unknownRetryConditions.Load(v2.RetryCondition("unknown")); !warned {
+		t.Error("unknown retry condition should be warned")
+	}
+	if base, max := rp.RetryBackOff(); base != time.Millisecond || max != time.Second {
+		t.Errorf("unexpected retry back off: %v, %v", base, max)
+	}
+	// default budget: 20% of active requests, at least 3 concurrent retries
+	budget := rp.RetryBudget()
+	if budget == nil {
+		t.Fatal("retry budget expected")
+	}
+	for i := 0; i < 3; i++ {
+		if !budget.TryAcquire() {
+			t.Fatalf("#%d retry should be acquired by min concurrency", i)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		budget.OnRequestStart()
+	}
+	if budget.TryAcquire() {
+		t.Fatal("retry budget should be exhausted")
+	}
+	for i := 0; i < 10; i++ {
+		budget.OnRequestStart()
+	}
+	if !budget.TryAcquire() {
+		t.Fatal("retry budget should be increased by the route's active requests")
+	}
+	// no retry policy
+	noRetryRule, _ := NewRouteRuleImplBase(nil, &v2.Router{})
+	noRetry := noRetryRule.Policy().RetryPolicy()
+	if noRetry.RetryOn() || noRetry.RetryConditions() != 0 || noRetry.RetryBudget() != nil {
+		t.Error("unexpected retry policy without config")
+	}
+}
@@ -21,7 +21,6 @@ import (
 	"context"
 	"errors"
 	"strings"
-	"time"
 
 	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/types"
@@ -84,7 +83,8 @@ type RouteBase interface {
 // Policy
 type policy struct {
 	retryPolicy  *retryPolicyImpl
-	shadowPolicy *shadowPolicyImpl //TODO: not implement yet
+	shadowPolicy *shadowPolicyImpl
+	hashPolicy   *hashPolicyImpl
 }
 
 func (p *policy) RetryPolicy() types.RetryPolicy {
@@ -95,44 +95,50 @@ func (p *policy) ShadowPolicy() types.ShadowPolicy {
 	return p.shadowPolicy
 }
 
-type retryPolicyImpl struct {
-	retryOn      bool
-	retryTimeout time.Duration
-	numRetries   uint32
-}
-
-func (p *retryPolicyImpl) RetryOn() bool {
-	if p == nil {
-		return false
+// HashPolicy returns an untyped nil if the route has no hash policy
+func (p *policy) HashPolicy() types.HashPolicy {
+	if p.hashPolicy == nil {
+		return nil
 	}
-	return p.retryOn
+	return p.hashPolicy
 }
 
-func (p *retryPolicyImpl) TryTimeout() time.Duration {
-	if p == nil {
-		return 0
-	}
-	return p.retryTimeout
+type shadowPolicyImpl struct {
+	cluster string
+	percent float64
 }
 
-func (p *retryPolicyImpl) NumRetries() uint32 {
-	if p == nil {
-		return 0
+func newShadowPolicyImpl(config *v2.ShadowPolicy) *shadowPolicyImpl {
+	if config.Cluster == "" {
+		return nil
+	}
+	percent := config.Percent
+	if percent <= 0 || percent > 100 {
+		percent = 100
+	}
+	return &shadowPolicyImpl{
+		cluster: config.Cluster,
+		percent: percent,
 	}
-	return p.numRetries
-}
-
-type shadowPolicyImpl struct {
-	cluster    string
-	runtimeKey string
 }
 
 func (spi *shadowPolicyImpl) ClusterName() string {
+	if spi == nil {
+		return ""
+	}
 	return spi.cluster
 }
 
+// RuntimeKey is not supported, the shadow percent is always the configured one
 func (spi *shadowPolicyImpl) RuntimeKey() string {
-	return spi.runtimeKey
+	return ""
+}
+
+func (spi *shadowPolicyImpl) Percent() float64 {
+	if spi == nil {
+		return 0
+	}
+	return spi.percent
 }
 
 // RouterRuleFactory creates a RouteBase
@@ -19,6 +19,7 @@ package router
 
 import (
 	"regexp"
+	"strings"
 
 	"sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
@@ -72,6 +73,14 @@ func getRouterHeaders(headers []v2.HeaderMatcher) []*types.HeaderData {
 	return headerDatas
 }
 
+// getRoutingPriority returns the resource priority of the route, the default priority is used if not configured
+func getRoutingPriority(priority v2.RoutingPriority) types.ResourcePriority {
+	if strings.EqualFold(string(priority), string(v2.PRIORITY_HIGH)) {
+		return types.HighPriority
+	}
+	return types.DefaultPriority
+}
+
 func getHeaderParser(headersToAdd []*v2.HeaderValueOption, headersToRemove []string) *headerParser {
 	if headersToAdd == nil && headersToRemove == nil {
 		return nil
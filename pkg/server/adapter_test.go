@@ -361,3 +361,48 @@ func TestIdleTimeoutAndUpdate(t *testing.T) {
 	}()
 
 }
+
+func TestProxyProtocolListener(t *testing.T) {
+	addrStr := "127.0.0.1:8084"
+	name := "listener_proxy_protocol"
+	listenerConfig := baseListenerConfig(addrStr, name)
+	listenerConfig.UseProxyProtocol = true
+	listenerConfig.FilterChains[0].TLSContexts = []v2.TLSConfig{{}}
+	addrChan := make(chan net.Addr, 1)
+	nfcfs := []types.NetworkFilterChainFactory{
+		&mockRemoteAddrFilterFactory{addrChan: addrChan},
+	}
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, listenerConfig, nfcfs, nil); err != nil {
+		t.Fatalf("add a new listener failed %v", err)
+	}
+	defer GetListenerAdapterInstance().DeleteListener(testServerName, name)
+	time.Sleep(time.Second) // wait listener start
+	conn, err := net.DialTimeout("tcp", addrStr, time.Second)
+	if err != nil {
+		t.Fatal("dial listener failed", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("PROXY TCP4 192.168.1.10 127.0.0.1 56324 8084\r\n"))
+	select {
+	case addr := <-addrChan:
+		if addr.String() != "192.168.1.10:56324" {
+			t.Fatalf("remote address is not expected: %s", addr)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no connection created")
+	}
+	// connection without PROXY protocol header is closed
+	plain, err := net.DialTimeout("tcp", addrStr, time.Second)
+	if err != nil {
+		t.Fatal("dial listener failed", err)
+	}
+	defer plain.Close()
+	plain.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	plain.SetReadDeadline(time.Now().Add(3 * time.Second))
+	// the connection maybe reset as the data is not read
+	if _, err := plain.Read(make([]byte, 1)); err == nil {
+		t.Fatal("connection without header should be closed")
+	} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		t.Fatal("connection without header should be closed, but read timeout")
+	}
+}
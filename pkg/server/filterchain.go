@@ -0,0 +1,325 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/filter"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/mtls"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// transport protocols in filter chain match
+const (
+	transportProtocolTLS       = "tls"
+	transportProtocolRawBuffer = "raw_buffer"
+)
+
+// filterChainPeekTimeout is the max time to wait for the tls client hello
+// when a filter chain is matched by server names or application protocols
+var filterChainPeekTimeout = 3 * time.Second
+
+// filterChainMatch is the parsed v2.FilterChainMatchConfig
+type filterChainMatch struct {
+	destinationPort      int
+	prefixRanges         []*net.IPNet
+	sourcePrefixRanges   []*net.IPNet
+	serverNames          []string
+	transportProtocol    string
+	applicationProtocols []string
+}
+
+func newFilterChainMatch(cfg *v2.FilterChainMatchConfig) (*filterChainMatch, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	m := &filterChainMatch{
+		destinationPort:      int(cfg.DestinationPort),
+		serverNames:          cfg.ServerNames,
+		transportProtocol:    cfg.TransportProtocol,
+		applicationProtocols: cfg.ApplicationProtocols,
+	}
+	switch m.transportProtocol {
+	case "", transportProtocolTLS, transportProtocolRawBuffer:
+	default:
+		return nil, fmt.Errorf("unknown transport protocol: %s", m.transportProtocol)
+	}
+	var err error
+	if m.prefixRanges, err = parseCIDRs(cfg.PrefixRanges); err != nil {
+		return nil, err
+	}
+	if m.sourcePrefixRanges, err = parseCIDRs(cfg.SourcePrefixRanges); err != nil {
+		return nil, err
+	}
+	if m.empty() {
+		return nil, nil
+	}
+	return m, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		// a single ip is treated as a full length prefix
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr = cidr + "/32"
+			} else {
+				cidr = cidr + "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix range %s: %v", cidr, err)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+func (m *filterChainMatch) empty() bool {
+	return m.destinationPort == 0 &&
+		len(m.prefixRanges) == 0 &&
+		len(m.sourcePrefixRanges) == 0 &&
+		!m.needClientHello()
+}
+
+// needClientHello returns true if the match conditions depend on tls client hello
+func (m *filterChainMatch) needClientHello() bool {
+	return len(m.serverNames) > 0 ||
+		len(m.applicationProtocols) > 0 ||
+		m.transportProtocol != ""
+}
+
+// connectionInfo contains the informations used to choose a filter chain
+type connectionInfo struct {
+	destination net.Addr
+	source      net.Addr
+	// hello is nil if the connection is not tls
+	hello *mtls.ClientHello
+}
+
+func (m *filterChainMatch) matches(info *connectionInfo) bool {
+	if m.destinationPort != 0 || len(m.prefixRanges) > 0 {
+		ip, port := splitAddr(info.destination)
+		if m.destinationPort != 0 && m.destinationPort != port {
+			return false
+		}
+		if len(m.prefixRanges) > 0 && !containsIP(m.prefixRanges, ip) {
+			return false
+		}
+	}
+	if len(m.sourcePrefixRanges) > 0 {
+		ip, _ := splitAddr(info.source)
+		if !containsIP(m.sourcePrefixRanges, ip) {
+			return false
+		}
+	}
+	switch m.transportProtocol {
+	case transportProtocolTLS:
+		if info.hello == nil {
+			return false
+		}
+	case transportProtocolRawBuffer:
+		if info.hello != nil {
+			return false
+		}
+	}
+	if len(m.serverNames) > 0 {
+		if info.hello == nil || !matchServerName(m.serverNames, info.hello.ServerName) {
+			return false
+		}
+	}
+	if len(m.applicationProtocols) > 0 {
+		if info.hello == nil || !matchALPN(m.applicationProtocols, info.hello.ALPN) {
+			return false
+		}
+	}
+	return true
+}
+
+func splitAddr(addr net.Addr) (net.IP, int) {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP, a.Port
+	case *net.UDPAddr:
+		return a.IP, a.Port
+	}
+	if addr == nil {
+		return nil, 0
+	}
+	if tcpAddr, err := net.ResolveTCPAddr("tcp", addr.String()); err == nil {
+		return tcpAddr.IP, tcpAddr.Port
+	}
+	return nil, 0
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchServerName matches the sni, a name starts with "*." matches all the sub domains
+func matchServerName(names []string, sni string) bool {
+	if sni == "" {
+		return false
+	}
+	sni = strings.ToLower(sni)
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "*.") {
+			if strings.HasSuffix(sni, name[1:]) {
+				return true
+			}
+		} else if name == sni {
+			return true
+		}
+	}
+	return false
+}
+
+func matchALPN(protos []string, alpn []string) bool {
+	for _, p := range protos {
+		for _, a := range alpn {
+			if p == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// activeFilterChain is a filter chain in listener,
+// contains the network filters, stream filters and tls context manager
+type activeFilterChain struct {
+	match                       *filterChainMatch // nil means the chain have no conditions
+	networkFiltersFactories     []types.NetworkFilterChainFactory
+	streamFiltersFactoriesStore *atomic.Value // store []types.StreamFilterChainFactory
+	tlsMng                      types.TLSContextManager
+}
+
+// newFilterChains creates the listener's filter chains.
+// The first filter chain uses the network filters created by the caller, others are
+// created by the filter chains config.
+// A filter chain without stream filters config uses the listener's stream filters.
+func newFilterChains(lc *v2.Listener, networkFiltersFactories []types.NetworkFilterChainFactory,
+	listenerStreamFilters *atomic.Value) ([]*activeFilterChain, error) {
+	if len(lc.FilterChains) == 0 {
+		return nil, nil
+	}
+	chains := make([]*activeFilterChain, 0, len(lc.FilterChains))
+	for idx := range lc.FilterChains {
+		fc := &lc.FilterChains[idx]
+		match, err := newFilterChainMatch(fc.Match)
+		if err != nil {
+			return nil, fmt.Errorf("filter chain %d: %v", idx, err)
+		}
+		chain := &activeFilterChain{
+			match:                       match,
+			streamFiltersFactoriesStore: listenerStreamFilters,
+		}
+		if idx == 0 {
+			chain.networkFiltersFactories = networkFiltersFactories
+		} else if !lc.UseOriginalDst {
+			chain.networkFiltersFactories = createNetworkFilters(fc.Filters)
+		}
+		if len(fc.StreamFilters) > 0 && !lc.UseOriginalDst {
+			chain.streamFiltersFactoriesStore = &atomic.Value{}
+			chain.streamFiltersFactoriesStore.Store(createStreamFilters(fc.StreamFilters))
+		}
+		// each filter chain have its own tls context manager
+		chainConfig := *lc
+		chainConfig.FilterChains = []v2.FilterChain{*fc}
+		mgr, err := mtls.NewTLSServerContextManager(&chainConfig)
+		if err != nil {
+			return nil, err
+		}
+		chain.tlsMng = mgr
+		chains = append(chains, chain)
+	}
+	return chains, nil
+}
+
+func createNetworkFilters(configs []v2.Filter) []types.NetworkFilterChainFactory {
+	var factories []types.NetworkFilterChainFactory
+	for _, c := range configs {
+		factory, err := filter.CreateNetworkFilterChainFactory(c.Type, c.Config)
+		if err != nil {
+			log.DefaultLogger.Errorf("[server] [filter chain] network filter create failed, type:%s, error: %v", c.Type, err)
+			continue
+		}
+		factories = append(factories, factory)
+	}
+	return factories
+}
+
+func createStreamFilters(configs []v2.Filter) []types.StreamFilterChainFactory {
+	var factories []types.StreamFilterChainFactory
+	for _, c := range configs {
+		factory, err := filter.CreateStreamFilterChainFactory(c.Type, c.Config)
+		if err != nil {
+			log.DefaultLogger.Errorf("[server] [filter chain] stream filter create failed, type:%s, error: %v", c.Type, err)
+			continue
+		}
+		factories = append(factories, factory)
+	}
+	return factories
+}
+
+// needClientHello returns true if any filter chain is matched by tls client hello
+func needClientHello(chains []*activeFilterChain) bool {
+	for _, chain := range chains {
+		if chain.match != nil && chain.match.needClientHello() {
+			return true
+		}
+	}
+	return false
+}
+
+// chooseFilterChain chooses a filter chain for the connection.
+// The filter chains with conditions are matched in order, if no one matched,
+// the first filter chain without conditions is chosen.
+func chooseFilterChain(chains []*activeFilterChain, info *connectionInfo) *activeFilterChain {
+	var defaultChain *activeFilterChain
+	for _, chain := range chains {
+		if chain.match == nil {
+			if defaultChain == nil {
+				defaultChain = chain
+			}
+			continue
+		}
+		if chain.match.matches(info) {
+			return chain
+		}
+	}
+	return defaultChain
+}
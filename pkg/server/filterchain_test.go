@@ -0,0 +1,220 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/filter"
+	"sofastack.io/sofa-mosn/pkg/mtls"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestFilterChainMatch(t *testing.T) {
+	dst := &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 443}
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 12345}
+	tlsHello := &mtls.ClientHello{
+		ServerName: "api.example.com",
+		ALPN:       []string{"h2", "http/1.1"},
+	}
+	cases := []struct {
+		cfg     v2.FilterChainMatchConfig
+		hello   *mtls.ClientHello
+		matched bool
+	}{
+		{v2.FilterChainMatchConfig{DestinationPort: 443}, nil, true},
+		{v2.FilterChainMatchConfig{DestinationPort: 80}, nil, false},
+		{v2.FilterChainMatchConfig{PrefixRanges: []string{"10.0.0.0/8"}}, nil, true},
+		{v2.FilterChainMatchConfig{PrefixRanges: []string{"10.1.1.2"}}, nil, false},
+		{v2.FilterChainMatchConfig{SourcePrefixRanges: []string{"192.168.1.0/24"}}, nil, true},
+		{v2.FilterChainMatchConfig{SourcePrefixRanges: []string{"192.168.2.0/24"}}, nil, false},
+		{v2.FilterChainMatchConfig{ServerNames: []string{"*.example.com"}}, tlsHello, true},
+		{v2.FilterChainMatchConfig{ServerNames: []string{"api.example.com"}}, tlsHello, true},
+		{v2.FilterChainMatchConfig{ServerNames: []string{"www.example.com"}}, tlsHello, false},
+		{v2.FilterChainMatchConfig{ServerNames: []string{"*.example.com"}}, nil, false},
+		{v2.FilterChainMatchConfig{ApplicationProtocols: []string{"h2"}}, tlsHello, true},
+		{v2.FilterChainMatchConfig{ApplicationProtocols: []string{"sofa"}}, tlsHello, false},
+		{v2.FilterChainMatchConfig{TransportProtocol: "tls"}, tlsHello, true},
+		{v2.FilterChainMatchConfig{TransportProtocol: "tls"}, nil, false},
+		{v2.FilterChainMatchConfig{TransportProtocol: "raw_buffer"}, nil, true},
+		// all conditions should be matched
+		{v2.FilterChainMatchConfig{DestinationPort: 443, ServerNames: []string{"*.example.com"}, SourcePrefixRanges: []string{"172.16.0.0/12"}}, tlsHello, false},
+		{v2.FilterChainMatchConfig{DestinationPort: 443, ServerNames: []string{"*.example.com"}, SourcePrefixRanges: []string{"192.168.0.0/16"}}, tlsHello, true},
+	}
+	for i, c := range cases {
+		m, err := newFilterChainMatch(&c.cfg)
+		if err != nil || m == nil {
+			t.Fatalf("#%d create filter chain match failed: %v", i, err)
+		}
+		info := &connectionInfo{
+			destination: dst,
+			source:      src,
+			hello:       c.hello,
+		}
+		if m.matches(info) != c.matched {
+			t.Errorf("#%d expected matched: %v", i, c.matched)
+		}
+	}
+	// invalid config
+	if _, err := newFilterChainMatch(&v2.FilterChainMatchConfig{PrefixRanges: []string{"10.0.0.0/33"}}); err == nil {
+		t.Error("invalid prefix range should be failed")
+	}
+	if _, err := newFilterChainMatch(&v2.FilterChainMatchConfig{TransportProtocol: "quic"}); err == nil {
+		t.Error("invalid transport protocol should be failed")
+	}
+	// empty config means no conditions
+	if m, err := newFilterChainMatch(&v2.FilterChainMatchConfig{}); m != nil || err != nil {
+		t.Error("empty config should have no conditions")
+	}
+}
+
+func TestChooseFilterChain(t *testing.T) {
+	newChain := func(cfg *v2.FilterChainMatchConfig) *activeFilterChain {
+		m, _ := newFilterChainMatch(cfg)
+		return &activeFilterChain{match: m}
+	}
+	defaultChain := newChain(nil)
+	portChain := newChain(&v2.FilterChainMatchConfig{DestinationPort: 8080})
+	sniChain := newChain(&v2.FilterChainMatchConfig{ServerNames: []string{"www.example.com"}})
+	chains := []*activeFilterChain{portChain, defaultChain, sniChain}
+	info := &connectionInfo{
+		destination: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080},
+	}
+	if chooseFilterChain(chains, info) != portChain {
+		t.Error("expected port filter chain")
+	}
+	info.destination = &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 80}
+	info.hello = &mtls.ClientHello{ServerName: "www.example.com"}
+	if chooseFilterChain(chains, info) != sniChain {
+		t.Error("expected sni filter chain")
+	}
+	info.hello = nil
+	if chooseFilterChain(chains, info) != defaultChain {
+		t.Error("expected default filter chain")
+	}
+	if chooseFilterChain([]*activeFilterChain{portChain, sniChain}, info) != nil {
+		t.Error("expected no filter chain matched")
+	}
+	if !needClientHello(chains) || needClientHello([]*activeFilterChain{portChain, defaultChain}) {
+		t.Error("need client hello is not expected")
+	}
+}
+
+const mockCountFilterType = "mock_count_filter"
+
+type mockCountFilterFactory struct {
+	count int32
+}
+
+func (ff *mockCountFilterFactory) CreateFilterChain(context context.Context, clusterManager types.ClusterManager, callbacks types.NetWorkFilterChainFactoryCallbacks) {
+	atomic.AddInt32(&ff.count, 1)
+	callbacks.AddReadFilter(&mockNetworkFilter{})
+}
+
+func TestMultipleFilterChains(t *testing.T) {
+	sniFactory := &mockCountFilterFactory{}
+	filter.RegisterNetwork(mockCountFilterType, func(conf map[string]interface{}) (types.NetworkFilterChainFactory, error) {
+		return sniFactory, nil
+	})
+	defaultFactory := &mockCountFilterFactory{}
+	addrStr := "127.0.0.1:8083"
+	name := "listener_filter_chains"
+	listenerConfig := baseListenerConfig(addrStr, name)
+	tlsContexts := listenerConfig.FilterChains[0].TLSContexts
+	listenerConfig.FilterChains = []v2.FilterChain{
+		{
+			// default filter chain, without tls
+			TLSContexts: []v2.TLSConfig{{}},
+		},
+		{
+			FilterChainConfig: v2.FilterChainConfig{
+				Match: &v2.FilterChainMatchConfig{
+					ServerNames: []string{"*.example.com"},
+				},
+				Filters: []v2.Filter{
+					{
+						Type: mockCountFilterType,
+					},
+				},
+			},
+			TLSContexts: tlsContexts,
+		},
+		{
+			FilterChainConfig: v2.FilterChainConfig{
+				Match: &v2.FilterChainMatchConfig{
+					SourcePrefixRanges: []string{"10.0.0.0/8"},
+				},
+			},
+			TLSContexts: tlsContexts,
+		},
+	}
+	nfcfs := []types.NetworkFilterChainFactory{defaultFactory}
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, listenerConfig, nfcfs, nil); err != nil {
+		t.Fatalf("add a new listener failed %v", err)
+	}
+	defer GetListenerAdapterInstance().DeleteListener(testServerName, name)
+	time.Sleep(time.Second) // wait listener start
+	dialer := &net.Dialer{
+		Timeout: time.Second,
+	}
+	// sni matched, use tls
+	conn, err := tls.DialWithDialer(dialer, "tcp", addrStr, &tls.Config{
+		ServerName:         "www.example.com",
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		t.Fatal("dial tls failed", err)
+	}
+	conn.Close()
+	// sni not matched, use default filter chain without tls
+	if conn, err := tls.DialWithDialer(dialer, "tcp", addrStr, &tls.Config{
+		ServerName:         "www.test.com",
+		InsecureSkipVerify: true,
+	}); err == nil {
+		conn.Close()
+		t.Fatal("default filter chain should not support tls")
+	}
+	// plain connection use default filter chain
+	plain, err := net.DialTimeout("tcp", addrStr, time.Second)
+	if err != nil {
+		t.Fatal("dial listener failed", err)
+	}
+	plain.Write([]byte("hello"))
+	time.Sleep(100 * time.Millisecond)
+	plain.Close()
+	if atomic.LoadInt32(&sniFactory.count) != 1 {
+		t.Errorf("sni filter chain is not used expected, count: %d", sniFactory.count)
+	}
+	if atomic.LoadInt32(&defaultFactory.count) != 2 {
+		t.Errorf("default filter chain is not used expected, count: %d", defaultFactory.count)
+	}
+	// invalid filter chain match update
+	invalid := baseListenerConfig(addrStr, name)
+	invalid.FilterChains[0].Match = &v2.FilterChainMatchConfig{
+		PrefixRanges: []string{"invalid"},
+	}
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, invalid, nil, nil); err == nil {
+		t.Fatal("update listener with invalid filter chain match should be failed")
+	}
+}
@@ -36,7 +36,9 @@ import (
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	mosnctx "sofastack.io/sofa-mosn/pkg/context"
 	"sofastack.io/sofa-mosn/pkg/filter/accept/originaldst"
+	"sofastack.io/sofa-mosn/pkg/filter/accept/proxyprotocol"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/log/als"
 	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/mtls"
 	"sofastack.io/sofa-mosn/pkg/network"
@@ -115,19 +117,22 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener, networkFiltersFactor
 			al.listener.Addr().Network() != lc.Addr.Network() {
 			return nil, errors.New("error updating listener, listen address and listen name doesn't match")
 		}
-		// currently, we just support one filter chain
-		if len(lc.FilterChains) != 1 {
-			return nil, errors.New("error updating listener, listener have filter chains count is not 1")
+		if len(lc.FilterChains) == 0 {
+			return nil, errors.New("error updating listener, listener have no filter chains")
 		}
 		rawConfig := al.listener.Config()
 		// FIXME: update log level need the pkg/logger support.
 
-		// only chaned if not nil
+		// the filter chains config changed, except the network filters of the first
+		// filter chain, which is only chaned if not nil
+		filterChains := make([]v2.FilterChain, len(lc.FilterChains))
+		copy(filterChains, lc.FilterChains)
 		if networkFiltersFactories != nil {
 			log.DefaultLogger.Infof("[server] [AddOrUpdateListener] [update] update network filters")
-			al.networkFiltersFactories = networkFiltersFactories
-			rawConfig.FilterChains[0].FilterChainMatch = lc.FilterChains[0].FilterChainMatch
-			rawConfig.FilterChains[0].Filters = lc.FilterChains[0].Filters
+		} else {
+			networkFiltersFactories = al.filterChains()[0].networkFiltersFactories
+			filterChains[0].FilterChainMatch = rawConfig.FilterChains[0].FilterChainMatch
+			filterChains[0].Filters = rawConfig.FilterChains[0].Filters
 		}
 		if streamFiltersFactories != nil {
 			log.DefaultLogger.Infof("[server] [AddOrUpdateListener] [update] update stream filters")
@@ -137,17 +142,19 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener, networkFiltersFactor
 
 		// tls update only take effects on new connections
 		// config changed
-		rawConfig.FilterChains[0].TLSContexts = lc.FilterChains[0].TLSContexts
-		rawConfig.FilterChains[0].TLSConfig = lc.FilterChains[0].TLSConfig
-		rawConfig.FilterChains[0].TLSConfigs = lc.FilterChains[0].TLSConfigs
-		rawConfig.Inspector = lc.Inspector
-		mgr, err := mtls.NewTLSServerContextManager(rawConfig)
+		newConfig := *rawConfig
+		newConfig.FilterChains = filterChains
+		newConfig.Inspector = lc.Inspector
+		newConfig.UseOriginalDst = lc.UseOriginalDst
+		chains, err := newFilterChains(&newConfig, networkFiltersFactories, &al.streamFiltersFactoriesStore)
 		if err != nil {
-			log.DefaultLogger.Errorf("[server] [conn handler] [update listener] create tls context manager failed, %v", err)
+			log.DefaultLogger.Errorf("[server] [conn handler] [update listener] create filter chains failed, %v", err)
 			return nil, err
 		}
 		// object changed
-		al.tlsMng = mgr
+		al.filterChainsStore.Store(chains)
+		rawConfig.FilterChains = filterChains
+		rawConfig.Inspector = lc.Inspector
 		// some simle config update
 		rawConfig.PerConnBufferLimitBytes = lc.PerConnBufferLimitBytes
 		al.listener.SetPerConnBufferLimitBytes(lc.PerConnBufferLimitBytes)
@@ -155,6 +162,7 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener, networkFiltersFactor
 		al.listener.SetListenerTag(lc.ListenerTag)
 		rawConfig.UseOriginalDst = lc.UseOriginalDst
 		al.listener.SetUseOriginalDst(lc.UseOriginalDst)
+		rawConfig.UseProxyProtocol = lc.UseProxyProtocol
 		al.idleTimeout = lc.ConnectionIdleTimeout
 
 		al.listener.SetConfig(rawConfig)
@@ -178,11 +186,11 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener, networkFiltersFactor
 				alConfig.Path = types.MosnLogBasePath + string(os.PathSeparator) + lc.Name + "_access.log"
 			}
 
-			if al, err := log.NewAccessLog(alConfig.Path, nil, alConfig.Format); err == nil {
-				als = append(als, al)
-			} else {
+			accessLog, err := newAccessLog(alConfig)
+			if err != nil {
 				return nil, fmt.Errorf("initialize listener access logger %s failed: %v", alConfig.Path, err.Error())
 			}
+			als = append(als, accessLog)
 		}
 
 		l := network.NewListener(lc)
@@ -327,7 +335,7 @@ func (ch *connHandler) StopConnection() {
 type activeListener struct {
 	disableConnIo               bool
 	listener                    types.Listener
-	networkFiltersFactories     []types.NetworkFilterChainFactory
+	filterChainsStore           atomic.Value // store []*activeFilterChain
 	streamFiltersFactoriesStore atomic.Value // store []types.StreamFilterChainFactory
 	listenIP                    string
 	listenPort                  int
@@ -339,22 +347,45 @@ type activeListener struct {
 	accessLogs                  []types.AccessLog
 	updatedLabel                bool
 	idleTimeout                 *v2.DurationConfig
-	tlsMng                      types.TLSContextManager
+}
+
+// newAccessLog creates the access log by the config, the grpc access log streams to a collector and
+// uses the path as the local file fallback, and the json format is preferred to the format if it is configured
+func newAccessLog(alConfig v2.AccessLog) (types.AccessLog, error) {
+	var filter types.AccessLogFilter
+	if len(alConfig.Filters) > 0 {
+		filters := make([]types.AccessLogFilter, 0, len(alConfig.Filters))
+		for _, filterConfig := range alConfig.Filters {
+			f, err := log.CreateAccessLogFilter(filterConfig.Type, filterConfig.Config)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, f)
+		}
+		filter = log.NewAndFilter(filters...)
+	}
+
+	if alConfig.GRPCService != nil {
+		return als.NewAccessLog(alConfig.GRPCService, alConfig.Path, filter)
+	}
+	if len(alConfig.JSONFormat) > 0 {
+		return log.NewJSONAccessLog(alConfig.Path, filter, alConfig.JSONFormat)
+	}
+	return log.NewAccessLog(alConfig.Path, filter, alConfig.Format)
 }
 
 func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers []types.AccessLog,
 	networkFiltersFactories []types.NetworkFilterChainFactory, streamFiltersFactories []types.StreamFilterChainFactory,
 	handler *connHandler, stopChan chan struct{}) (*activeListener, error) {
 	al := &activeListener{
-		disableConnIo:           lc.DisableConnIo,
-		listener:                listener,
-		networkFiltersFactories: networkFiltersFactories,
-		conns:        list.New(),
-		handler:      handler,
-		stopChan:     stopChan,
-		accessLogs:   accessLoggers,
-		updatedLabel: false,
-		idleTimeout:  lc.ConnectionIdleTimeout,
+		disableConnIo: lc.DisableConnIo,
+		listener:      listener,
+		conns:         list.New(),
+		handler:       handler,
+		stopChan:      stopChan,
+		accessLogs:    accessLoggers,
+		updatedLabel:  false,
+		idleTimeout:   lc.ConnectionIdleTimeout,
 	}
 	al.streamFiltersFactoriesStore.Store(streamFiltersFactories)
 
@@ -371,16 +402,38 @@ func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers [
 	al.listenPort = listenPort
 	al.stats = newListenerStats(al.listener.Name())
 
-	mgr, err := mtls.NewTLSServerContextManager(lc)
+	chains, err := newFilterChains(lc, networkFiltersFactories, &al.streamFiltersFactoriesStore)
 	if err != nil {
-		log.DefaultLogger.Errorf("[server] [new listener] create tls context manager failed, %v", err)
+		log.DefaultLogger.Errorf("[server] [new listener] create filter chains failed, %v", err)
 		return nil, err
 	}
-	al.tlsMng = mgr
+	al.filterChainsStore.Store(chains)
 
 	return al, nil
 }
 
+func (al *activeListener) filterChains() []*activeFilterChain {
+	chains, _ := al.filterChainsStore.Load().([]*activeFilterChain)
+	return chains
+}
+
+// chooseFilterChain returns the filter chain matched by the connection,
+// the returned connection should be used instead of rawc, as the tls client hello maybe peeked.
+func (al *activeListener) chooseFilterChain(rawc net.Conn, oriRemoteAddr net.Addr) (net.Conn, *activeFilterChain) {
+	chains := al.filterChains()
+	info := &connectionInfo{
+		destination: rawc.LocalAddr(),
+		source:      rawc.RemoteAddr(),
+	}
+	if oriRemoteAddr != nil {
+		info.destination = oriRemoteAddr
+	}
+	if needClientHello(chains) {
+		rawc, info.hello = mtls.PeekClientHello(rawc, filterChainPeekTimeout)
+	}
+	return rawc, chooseFilterChain(chains, info)
+}
+
 func (al *activeListener) GoStart(lctx context.Context) {
 	utils.GoWithRecover(func() {
 		al.listener.Start(lctx)
@@ -396,7 +449,7 @@ func (al *activeListener) GoStart(lctx context.Context) {
 func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemoteAddr net.Addr, ch chan types.Connection, buf []byte) {
 	var rawf *os.File
 
-	// only store fd and tls conn handshake in final working listener
+	// only store fd in final working listener, the filter chain is chosen after listener filters
 	if !useOriginalDst {
 		if !al.disableConnIo && network.UseNetpollMode {
 			// store fd for further usage
@@ -404,14 +457,15 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 				rawf, _ = tc.File()
 			}
 		}
-		if al.tlsMng != nil {
-			rawc = al.tlsMng.Conn(rawc)
-		}
 	}
 
 	arc := newActiveRawConn(rawc, al)
 	// TODO: create listener filter chain
 
+	// the PROXY protocol header is parsed in final working listener
+	if !useOriginalDst && al.listener.Config().UseProxyProtocol {
+		arc.acceptedFilters = append(arc.acceptedFilters, proxyprotocol.NewProxyProtocol())
+	}
 	if useOriginalDst {
 		arc.acceptedFilters = append(arc.acceptedFilters, originaldst.NewOriginalDst())
 		arc.useOriginalDst = true
@@ -423,8 +477,6 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyListenerPort, al.listenPort)
 	ctx = mosnctx.WithValue(ctx, types.ContextKeyListenerType, al.listener.Config().Type)
 	ctx = mosnctx.WithValue(ctx, types.ContextKeyListenerName, al.listener.Name())
-	ctx = mosnctx.WithValue(ctx, types.ContextKeyNetworkFilterChainFactories, al.networkFiltersFactories)
-	ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamFilterChainFactories, &al.streamFiltersFactoriesStore)
 	ctx = mosnctx.WithValue(ctx, types.ContextKeyAccessLogs, al.accessLogs)
 	if rawf != nil {
 		ctx = mosnctx.WithValue(ctx, types.ContextKeyConnectionFd, rawf)
@@ -443,7 +495,11 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 func (al *activeListener) OnNewConnection(ctx context.Context, conn types.Connection) {
 	//Register Proxy's Filter
 	filterManager := conn.FilterManager()
-	for _, nfcf := range al.networkFiltersFactories {
+	var networkFiltersFactories []types.NetworkFilterChainFactory
+	if value := mosnctx.Get(ctx, types.ContextKeyNetworkFilterChainFactories); value != nil {
+		networkFiltersFactories = value.([]types.NetworkFilterChainFactory)
+	}
+	for _, nfcf := range networkFiltersFactories {
 		nfcf.CreateFilterChain(ctx, al.handler.clusterManager, filterManager)
 	}
 	filterManager.InitializeReadFilters()
@@ -489,7 +545,21 @@ func (al *activeListener) removeConnection(ac *activeConnection) {
 // we declared the defaultIdleTimeout reference to the network.DefaultIdleTimeout
 var defaultIdleTimeout = network.DefaultIdleTimeout
 
-func (al *activeListener) newConnection(ctx context.Context, rawc net.Conn) {
+func (al *activeListener) newConnection(ctx context.Context, rawc net.Conn, remoteAddr net.Addr) {
+	oriRemoteAddr, _ := mosnctx.Get(ctx, types.ContextOriRemoteAddr).(net.Addr)
+	// choose filter chain and tls conn handshake
+	rawc, chain := al.chooseFilterChain(rawc, oriRemoteAddr)
+	if chain == nil {
+		log.DefaultLogger.Errorf("[server] [listener] no filter chain matched, listener: %s, remote addr: %s", al.listener.Name(), rawc.RemoteAddr())
+		rawc.Close()
+		return
+	}
+	if chain.tlsMng != nil {
+		rawc = chain.tlsMng.Conn(rawc)
+	}
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyNetworkFilterChainFactories, chain.networkFiltersFactories)
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyStreamFilterChainFactories, chain.streamFiltersFactoriesStore)
+
 	conn := network.NewServerConnection(ctx, rawc, al.stopChan)
 	if al.idleTimeout != nil {
 		conn.SetIdleTimeout(al.idleTimeout.Duration)
@@ -498,9 +568,12 @@ func (al *activeListener) newConnection(ctx context.Context, rawc net.Conn) {
 		// notice only server side connection set the default value
 		conn.SetIdleTimeout(defaultIdleTimeout)
 	}
-	oriRemoteAddr := mosnctx.Get(ctx, types.ContextOriRemoteAddr)
 	if oriRemoteAddr != nil {
-		conn.SetRemoteAddr(oriRemoteAddr.(net.Addr))
+		conn.SetRemoteAddr(oriRemoteAddr)
+	}
+	// the real remote address set by listener filters
+	if remoteAddr != nil {
+		conn.SetRemoteAddr(remoteAddr)
 	}
 	newCtx := mosnctx.WithValue(ctx, types.ContextKeyConnectionID, conn.ID())
 
@@ -515,6 +588,7 @@ type activeRawConn struct {
 	originalDstIP       string
 	originalDstPort     int
 	oriRemoteAddr       net.Addr
+	remoteAddr          net.Addr
 	useOriginalDst      bool
 	rawcElement         *list.Element
 	activeListener      *activeListener
@@ -538,6 +612,13 @@ func (arc *activeRawConn) SetOriginalAddr(ip string, port int) {
 	}
 }
 
+func (arc *activeRawConn) SetRemoteAddr(addr net.Addr) {
+	arc.remoteAddr = addr
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[server] [conn] conn set remote addr:%s", addr)
+	}
+}
+
 func (arc *activeRawConn) UseOriginalDst(ctx context.Context) {
 	var listener, localListener *activeListener
 
@@ -592,7 +673,7 @@ func (arc *activeRawConn) ContinueFilterChain(ctx context.Context, success bool)
 	if arc.useOriginalDst {
 		arc.UseOriginalDst(ctx)
 	} else {
-		arc.activeListener.newConnection(ctx, arc.rawc)
+		arc.activeListener.newConnection(ctx, arc.rawc, arc.remoteAddr)
 	}
 
 }
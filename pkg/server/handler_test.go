@@ -0,0 +1,48 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package server
+
+import (
+	"testing"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+)
+
+func TestNewAccessLog(t *testing.T) {
+	if _, err := newAccessLog(v2.AccessLog{
+		Path:       "/tmp/mosn_accesslog/listener_json_access.log",
+		JSONFormat: map[string]string{"code": "%ResponseCode%"},
+		Filters: []v2.Filter{
+			{Type: "status_code", Config: map[string]interface{}{"op": "ge", "value": 500}},
+			{Type: "runtime_fraction", Config: map[string]interface{}{"numerator": 10}},
+		},
+	}); err != nil {
+		t.Fatalf("create access log failed: %v", err)
+	}
+	if _, err := newAccessLog(v2.AccessLog{
+		Path:    "/tmp/mosn_accesslog/listener_access.log",
+		Filters: []v2.Filter{{Type: "unknown"}},
+	}); err == nil {
+		t.Fatal("create access log with an unknown filter expected an error")
+	}
+	if _, err := newAccessLog(v2.AccessLog{
+		Path:        "/tmp/mosn_accesslog/listener_grpc_access.log",
+		GRPCService: &v2.GRPCAccessLog{LogName: "listener"},
+	}); err == nil {
+		t.Fatal("create grpc access log without the collector address expected an error")
+	}
+}
@@ -19,6 +19,7 @@ package server
 
 import (
 	"context"
+	"net"
 
 	"sofastack.io/sofa-mosn/pkg/types"
 )
@@ -53,6 +54,32 @@ func (ff *mockNetworkFilterFactory) CreateFilterChain(context context.Context, c
 	callbacks.AddReadFilter(&mockNetworkFilter{})
 }
 
+// mockRemoteAddrFilter records the remote address of the new connection
+type mockRemoteAddrFilter struct {
+	mockNetworkFilter
+	cb       types.ReadFilterCallbacks
+	addrChan chan net.Addr
+}
+
+func (nf *mockRemoteAddrFilter) InitializeReadFilterCallbacks(cb types.ReadFilterCallbacks) {
+	nf.cb = cb
+}
+
+func (nf *mockRemoteAddrFilter) OnNewConnection() types.FilterStatus {
+	nf.addrChan <- nf.cb.Connection().RemoteAddr()
+	return types.Continue
+}
+
+type mockRemoteAddrFilterFactory struct {
+	addrChan chan net.Addr
+}
+
+func (ff *mockRemoteAddrFilterFactory) CreateFilterChain(context context.Context, clusterManager types.ClusterManager, callbacks types.NetWorkFilterChainFactoryCallbacks) {
+	callbacks.AddReadFilter(&mockRemoteAddrFilter{
+		addrChan: ff.addrChan,
+	})
+}
+
 const mockCAPEM = `-----BEGIN CERTIFICATE-----
 MIIC4jCCAcqgAwIBAgIQdme9dUKPZVTgfIVpy+fmmjANBgkqhkiG9w0BAQsFADAS
 MRAwDgYDVQQKEwdBY21lIENvMB4XDTE5MDIyMDA3NTMyOFoXDTIwMDIyMDA3NTMy
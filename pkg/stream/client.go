@@ -118,7 +118,15 @@ func (c *client) NewStream(context context.Context, respReceiver types.StreamRec
 		streamReceiver: respReceiver,
 	}
 
-	streamSender := c.ClientStreamConnection.NewStream(context, wrapper)
+	var receiver types.StreamReceiveListener = wrapper
+	if streaming, ok := respReceiver.(types.StreamingReceiveListener); ok {
+		receiver = &clientStreamingReceiverWrapper{
+			clientStreamReceiverWrapper: wrapper,
+			streamingReceiver:           streaming,
+		}
+	}
+
+	streamSender := c.ClientStreamConnection.NewStream(context, receiver)
 	wrapper.stream = streamSender.GetStream()
 
 	return streamSender
@@ -133,6 +141,13 @@ func (c *client) OnGoAway() {
 	c.StreamConnectionEventListener.OnGoAway()
 }
 
+// types.StreamConnectionUpgradeListener
+func (c *client) OnUpgrade() {
+	if listener, ok := c.StreamConnectionEventListener.(types.StreamConnectionUpgradeListener); ok {
+		listener.OnUpgrade()
+	}
+}
+
 // types.ConnectionEventListener
 // conn callbacks
 func (c *client) OnEvent(event types.ConnectionEvent) {
@@ -181,3 +196,28 @@ func (w *clientStreamReceiverWrapper) OnReceive(ctx context.Context, headers typ
 func (w *clientStreamReceiverWrapper) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
 	w.streamReceiver.OnDecodeError(ctx, err, headers)
 }
+
+// clientStreamingReceiverWrapper destroys the stream after the last piece of a streamed response
+type clientStreamingReceiverWrapper struct {
+	*clientStreamReceiverWrapper
+	streamingReceiver types.StreamingReceiveListener
+}
+
+func (w *clientStreamingReceiverWrapper) OnReceiveHeaders(ctx context.Context, headers types.HeaderMap, endStream bool) {
+	if endStream {
+		w.stream.DestroyStream()
+	}
+	w.streamingReceiver.OnReceiveHeaders(ctx, headers, endStream)
+}
+
+func (w *clientStreamingReceiverWrapper) OnReceiveData(ctx context.Context, data types.IoBuffer, endStream bool) {
+	if endStream {
+		w.stream.DestroyStream()
+	}
+	w.streamingReceiver.OnReceiveData(ctx, data, endStream)
+}
+
+func (w *clientStreamingReceiverWrapper) OnReceiveTrailers(ctx context.Context, trailers types.HeaderMap) {
+	w.stream.DestroyStream()
+	w.streamingReceiver.OnReceiveTrailers(ctx, trailers)
+}
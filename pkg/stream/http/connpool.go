@@ -76,26 +76,28 @@ func (p *connPool) CheckAndInit(ctx context.Context) bool {
 
 //由 PROXY 调用
 func (p *connPool) NewStream(ctx context.Context, receiver types.StreamReceiveListener, listener types.PoolEventListener) {
-	c, reason := p.getAvailableClient(ctx)
-
-	if c == nil {
-		listener.OnFailure(reason, p.host)
-		return
-	}
-
-	if !p.host.ClusterInfo().ResourceManager().Requests().CanCreate() {
+	// check the request resource before getting a client, so the client will not be taken away when overflow
+	requests := str.NewRequestResource(ctx, p.host)
+	if !requests.CanCreate() {
 		listener.OnFailure(types.Overflow, p.host)
 		p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
 	} else {
+		c, reason := p.getAvailableClient(ctx)
+		if c == nil {
+			listener.OnFailure(reason, p.host)
+			return
+		}
+
 		p.host.HostStats().UpstreamRequestTotal.Inc(1)
 		p.host.HostStats().UpstreamRequestActive.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestTotal.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
-		p.host.ClusterInfo().ResourceManager().Requests().Increase()
+		requests.Increase()
 
 		streamEncoder := c.client.NewStream(ctx, receiver)
 		streamEncoder.GetStream().AddEventListener(c)
+		streamEncoder.GetStream().AddEventListener(requests)
 		listener.OnReady(streamEncoder, p.host)
 	}
 
@@ -109,10 +111,16 @@ func (p *connPool) getAvailableClient(ctx context.Context) (*activeClient, types
 	n := len(p.availableClients)
 	// no available client
 	if n == 0 {
-		maxConns := p.host.ClusterInfo().ResourceManager().Connections().Max()
-		if p.totalClientCount < maxConns {
+		maxConns := p.host.ClusterInfo().PriorityResourceManager(str.RoutingPriority(ctx)).Connections().Max()
+		hostConns := p.host.ResourceManager().Connections()
+		if p.totalClientCount < maxConns && hostConns.CanCreate() {
+			c, reason := newActiveClient(ctx, p)
+			if c == nil {
+				return nil, reason
+			}
 			p.totalClientCount++
-			return newActiveClient(ctx, p)
+			hostConns.Increase()
+			return c, reason
 		} else {
 			p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 			p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
@@ -158,6 +166,7 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 		defer p.clientMux.Unlock()
 
 		p.totalClientCount--
+		p.host.ResourceManager().Connections().Decrease()
 
 		for i, c := range p.availableClients {
 			if c == client {
@@ -182,11 +191,10 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 func (p *connPool) onStreamDestroy(client *activeClient) {
 	p.host.HostStats().UpstreamRequestActive.Dec(1)
 	p.host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
-	p.host.ClusterInfo().ResourceManager().Requests().Decrease()
 
-	// return to pool
+	// return to pool, the upgraded client is used by the tunnel
 	p.clientMux.Lock()
-	if !client.closed {
+	if !client.closed && !client.upgraded {
 		p.availableClients = append(p.availableClients, client)
 	}
 	p.clientMux.Unlock()
@@ -233,6 +241,7 @@ type activeClient struct {
 	closeWithActiveReq bool
 	closed             bool
 	closeConn          bool
+	upgraded           bool
 }
 
 func newActiveClient(ctx context.Context, pool *connPool) (*activeClient, types.PoolFailureReason) {
@@ -289,3 +298,8 @@ func (ac *activeClient) OnResetStream(reason types.StreamResetReason) {
 func (ac *activeClient) OnGoAway() {
 	ac.closeConn = true
 }
+
+// types.StreamConnectionUpgradeListener
+func (ac *activeClient) OnUpgrade() {
+	ac.upgraded = true
+}
@@ -0,0 +1,65 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
+)
+
+func TestConnPoolConnectFailed(t *testing.T) {
+	// get a closed address
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	c := cluster.NewCluster(v2.Cluster{
+		Name: "test_connect_failed",
+		CirBreThresholds: v2.CircuitBreakers{
+			PerHostThresholds: []v2.Thresholds{
+				{MaxConnections: 1},
+			},
+		},
+	})
+	host := cluster.NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: addr,
+		},
+	}, c.Snapshot().ClusterInfo())
+	pool := NewConnPool(host).(*connPool)
+	for i := 0; i < 3; i++ {
+		client, reason := pool.getAvailableClient(context.Background())
+		if client != nil || reason != types.ConnectionFailure {
+			t.Fatalf("#%d expected connection failure, but got %v", i, reason)
+		}
+	}
+	// the failed connections are not counted
+	if pool.totalClientCount != 0 {
+		t.Errorf("total client count expected 0, but got %d", pool.totalClientCount)
+	}
+	if !host.ResourceManager().Connections().CanCreate() {
+		t.Error("host connections resource should not be taken by the failed connections")
+	}
+}
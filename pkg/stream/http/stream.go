@@ -33,6 +33,7 @@ import (
 	mosnctx "sofastack.io/sofa-mosn/pkg/context"
 	"sofastack.io/sofa-mosn/pkg/log"
 	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/grpc"
 	mosnhttp "sofastack.io/sofa-mosn/pkg/protocol/http"
 	str "sofastack.io/sofa-mosn/pkg/stream"
 	"sofastack.io/sofa-mosn/pkg/trace"
@@ -89,6 +90,10 @@ func (f *streamConnFactory) CreateBiDirectStream(context context.Context, connec
 }
 
 func (f *streamConnFactory) ProtocolMatch(context context.Context, prot string, magic []byte) error {
+	// negotiated by TLS ALPN
+	if prot == "http/1.1" {
+		return nil
+	}
 	if len(magic) < minMethodLengh {
 		return str.EAGAIN
 	}
@@ -172,6 +177,8 @@ type clientStreamConnection struct {
 
 	stream                        *clientStream
 	requestSent                   chan bool
+	upgraded                      bool
+	tunnelChan                    chan *tunnel
 	mutex                         sync.RWMutex
 	connectionEventListener       types.ConnectionEventListener
 	streamConnectionEventListener types.StreamConnectionEventListener
@@ -191,6 +198,7 @@ func newClientStreamConnection(ctx context.Context, connection types.ClientConne
 		connectionEventListener:       connCallbacks,
 		streamConnectionEventListener: streamConnCallbacks,
 		requestSent:                   make(chan bool, 1),
+		tunnelChan:                    make(chan *tunnel, 1),
 	}
 
 	csc.br = bufio.NewReader(csc)
@@ -215,8 +223,14 @@ func (conn *clientStreamConnection) serve() {
 		buffers := httpBuffersByContext(s.ctx)
 		s.response = &buffers.clientResponse
 
-		// 1. blocking read using fasthttp.Response.Read
-		err := s.response.Read(conn.br)
+		// 1. blocking read using fasthttp.Response.Read, only the headers are read if the body is streamed
+		receiver, streaming := str.StreamingReceiver(s.ctx, s.receiver)
+		var err error
+		if streaming {
+			err = s.response.Header.Read(conn.br)
+		} else {
+			err = s.response.Read(conn.br)
+		}
 		if err != nil {
 			if s != nil {
 				log.Proxy.Errorf(s.connection.context, "[stream] [http] client stream connection wait response error: %s", err)
@@ -239,15 +253,46 @@ func (conn *clientStreamConnection) serve() {
 			resetConn = true
 		}
 
+		// the upgrade is accepted, the connection turns into a tunnel after the response handled
+		upgraded := false
+		if s.response.StatusCode() == fasthttp.StatusSwitchingProtocols {
+			if s.request.Header.ConnectionUpgrade() {
+				upgraded = true
+				conn.mutex.Lock()
+				conn.upgraded = true
+				conn.mutex.Unlock()
+				// keep the connection out of the connection pool
+				if listener, ok := conn.streamConnectionEventListener.(types.StreamConnectionUpgradeListener); ok {
+					listener.OnUpgrade()
+				}
+			} else {
+				resetConn = true
+			}
+		}
+
 		// 3. local reset if header 'Connection: close' exists
 		if resetConn {
 			// goaway the connpool
 			s.connection.streamConnectionEventListener.OnGoAway()
 		}
 
-		if atomic.LoadInt32(&s.readDisableCount) <= 0 {
+		if streaming && hasResponseBody(s.request, s.response) {
+			if !s.receiveResponse(receiver) {
+				return
+			}
+		} else if atomic.LoadInt32(&s.readDisableCount) <= 0 {
 			s.handleResponse()
 		}
+
+		// 4. wait for the tunnel, the connection will not be decoded any more
+		if upgraded {
+			select {
+			case t := <-conn.tunnelChan:
+				t.serve(conn.br)
+			case <-conn.connClosed:
+			}
+			return
+		}
 	}
 }
 
@@ -275,13 +320,21 @@ func (conn *clientStreamConnection) ActiveStreamsNum() int {
 	conn.mutex.RLock()
 	defer conn.mutex.RUnlock()
 
-	if conn.stream == nil {
+	// the upgraded connection is always in use
+	if conn.stream == nil && !conn.upgraded {
 		return 0
 	} else {
 		return 1
 	}
 }
 
+func (conn *clientStreamConnection) isUpgraded() bool {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+
+	return conn.upgraded
+}
+
 func (conn *clientStreamConnection) Reset(reason types.StreamResetReason) {
 	close(conn.bufChan)
 	close(conn.connClosed)
@@ -296,6 +349,7 @@ type serverStreamConnection struct {
 	close bool
 
 	stream                   *serverStream
+	tunnel                   *tunnel
 	mutex                    sync.RWMutex
 	serverStreamConnListener types.ServerStreamConnectionEventListener
 }
@@ -349,8 +403,14 @@ func (conn *serverStreamConnection) serve() {
 		buffers := httpBuffersByContext(ctx)
 		request := &buffers.serverRequest
 
-		// 2. blocking read using fasthttp.Request.Read
-		err := request.ReadLimitBody(conn.br, defaultMaxRequestBodySize)
+		// 2. blocking read using fasthttp.Request.Read, only the headers are read if the body is streamed
+		streaming := str.StreamBodyEnabled(ctx)
+		var err error
+		if streaming {
+			err = request.Header.Read(conn.br)
+		} else {
+			err = request.ReadLimitBody(conn.br, defaultMaxRequestBodySize)
+		}
 		if err == nil {
 			// 3. 'Expect: 100-continue' request handling.
 			// See http://www.w3.org/Protocols/rfc2616/rfc2616-sec8.html for details.
@@ -359,22 +419,16 @@ func (conn *serverStreamConnection) serve() {
 				conn.conn.Write(buffer.NewIoBufferBytes(strResponseContinue))
 
 				// read request body
-				err = request.ContinueReadBody(conn.br, defaultMaxRequestBodySize)
+				if !streaming {
+					err = request.ContinueReadBody(conn.br, defaultMaxRequestBodySize)
+				}
 
 				// remove 'Expect' header, so it would not be sent to the upstream
 				request.Header.Del("Expect")
 			}
 		}
 		if err != nil {
-			// "read timeout with nothing read" is the error of returned by fasthttp v1.2.0
-			// if connection closed with nothing read.
-			if err != errConnClose && err != io.EOF && err.Error() != "read timeout with nothing read" {
-				// write error response
-				conn.conn.Write(buffer.NewIoBufferBytes(strErrorResponse))
-
-				// close connection with flush
-				conn.conn.Close(types.FlushWrite, types.LocalClose)
-			}
+			conn.readFailed(err)
 			return
 		}
 
@@ -411,7 +465,11 @@ func (conn *serverStreamConnection) serve() {
 		conn.stream = s
 		conn.mutex.Unlock()
 
-		if atomic.LoadInt32(&s.readDisableCount) <= 0 {
+		if streaming && hasRequestBody(request.Header.ContentLength()) {
+			if !s.receiveRequest() {
+				return
+			}
+		} else if atomic.LoadInt32(&s.readDisableCount) <= 0 {
 			s.handleRequest()
 		}
 
@@ -422,10 +480,32 @@ func (conn *serverStreamConnection) serve() {
 			return
 		}
 
+		// 6. the connection is upgraded, stop decoding requests
+		conn.mutex.RLock()
+		t := conn.tunnel
+		conn.mutex.RUnlock()
+		if t != nil {
+			t.serve(conn.br)
+			return
+		}
+
 		conn.contextManager.Next()
 	}
 }
 
+// readFailed responds the bad request and closes the connection, unless the connection is closed
+func (conn *serverStreamConnection) readFailed(err error) {
+	// "read timeout with nothing read" is the error of returned by fasthttp v1.2.0
+	// if connection closed with nothing read.
+	if err != errConnClose && err != io.EOF && err.Error() != "read timeout with nothing read" {
+		// write error response
+		conn.conn.Write(buffer.NewIoBufferBytes(strErrorResponse))
+
+		// close connection with flush
+		conn.conn.Close(types.FlushWrite, types.LocalClose)
+	}
+}
+
 func (conn *serverStreamConnection) ActiveStreamsNum() int {
 	conn.mutex.RLock()
 	defer conn.mutex.RUnlock()
@@ -455,6 +535,14 @@ type stream struct {
 	response *fasthttp.Response
 
 	receiver types.StreamReceiveListener
+
+	// ~~~ streaming mode
+	// resume wakes up the body reader blocked by the read disable
+	resume chan struct{}
+	// the headers are sent, the body follows in pieces
+	streamSend bool
+	// the body is sent in the chunked encoding
+	chunked bool
 }
 
 // types.Stream
@@ -488,12 +576,19 @@ func (s *clientStream) AppendHeaders(context context.Context, headersIn types.He
 
 	if endStream {
 		s.endStream()
+	} else if str.StreamBodyEnabled(context) {
+		s.sendHeaders()
 	}
 
 	return nil
 }
 
 func (s *clientStream) AppendData(context context.Context, data types.IoBuffer, endStream bool) error {
+	if s.streamSend {
+		s.sendData(data, endStream)
+		return nil
+	}
+
 	s.request.SetBody(data.Bytes())
 
 	if endStream {
@@ -504,21 +599,18 @@ func (s *clientStream) AppendData(context context.Context, data types.IoBuffer,
 }
 
 func (s *clientStream) AppendTrailers(context context.Context, trailers types.HeaderMap) error {
+	if s.streamSend {
+		s.sendTrailers(trailers)
+		return nil
+	}
+
 	s.endStream()
 	return nil
 }
 
 func (s *clientStream) endStream() {
-	err := s.doSend()
-
-	if err != nil {
-		log.Proxy.Errorf(s.stream.ctx, "[stream] [http] send client request error: %+v", err)
-
-		if err == types.ErrConnectionHasClosed {
-			s.ResetStream(types.StreamConnectionFailed)
-		} else {
-			s.ResetStream(types.StreamLocalReset)
-		}
+	if err := s.doSend(); err != nil {
+		s.sendFailed(err)
 		return
 	}
 
@@ -528,13 +620,44 @@ func (s *clientStream) endStream() {
 	s.connection.requestSent <- true
 }
 
+// types.UpgradableStream
+// Upgrade should be called after the response with status 101 is received
+func (s *clientStream) Upgrade(listener types.TunnelListener) types.StreamTunnel {
+	t := newTunnel(s.connection.conn, listener)
+	select {
+	case s.connection.tunnelChan <- t:
+	default:
+		log.Proxy.Errorf(s.stream.ctx, "[stream] [http] client stream connection has a tunnel already, requestId = %v", s.stream.id)
+	}
+	return t
+}
+
+func (s *clientStream) sendFailed(err error) {
+	log.Proxy.Errorf(s.stream.ctx, "[stream] [http] send client request error: %+v", err)
+
+	if err == types.ErrConnectionHasClosed {
+		s.ResetStream(types.StreamConnectionFailed)
+	} else {
+		s.ResetStream(types.StreamLocalReset)
+	}
+}
+
+// ResetStream closes the upgraded connection, as it can not be reused by other streams,
+// and the connection with a partly streamed message
+func (s *clientStream) ResetStream(reason types.StreamResetReason) {
+	if s.connection.isUpgraded() || s.streamSend || s.resume != nil {
+		s.connection.conn.Close(types.NoFlush, types.LocalClose)
+	}
+	s.stream.ResetStream(reason)
+}
+
 func (s *clientStream) ReadDisable(disable bool) {
 	if disable {
 		atomic.AddInt32(&s.readDisableCount, 1)
 	} else {
 		newCount := atomic.AddInt32(&s.readDisableCount, -1)
 
-		if newCount <= 0 {
+		if newCount <= 0 && !s.resumeRead() {
 			s.handleResponse()
 		}
 	}
@@ -545,14 +668,19 @@ func (s *clientStream) doSend() (err error) {
 	return
 }
 
+func (s *clientStream) responseHeader() mosnhttp.ResponseHeader {
+	header := mosnhttp.ResponseHeader{&s.response.Header, nil}
+
+	statusCode := header.StatusCode()
+	status := strconv.Itoa(statusCode)
+	// inherit upstream's response status
+	header.Set(types.HeaderStatus, status)
+	return header
+}
+
 func (s *clientStream) handleResponse() {
 	if s.response != nil {
-		header := mosnhttp.ResponseHeader{&s.response.Header, nil}
-
-		statusCode := header.StatusCode()
-		status := strconv.Itoa(statusCode)
-		// inherit upstream's response status
-		header.Set(types.HeaderStatus, status)
+		header := s.responseHeader()
 
 		hasData := true
 		if len(s.response.Body()) == 0 {
@@ -586,6 +714,8 @@ type serverStream struct {
 	header           mosnhttp.RequestHeader
 	connection       *serverStreamConnection
 	responseDoneChan chan bool
+	// the connection is closed after the streamed response
+	closeConn bool
 }
 
 // types.StreamSender
@@ -619,12 +749,19 @@ func (s *serverStream) AppendHeaders(context context.Context, headersIn types.He
 
 	if endStream {
 		s.endStream()
+	} else if str.StreamBodyEnabled(s.ctx) {
+		s.sendHeaders()
 	}
 
 	return nil
 }
 
 func (s *serverStream) AppendData(context context.Context, data types.IoBuffer, endStream bool) error {
+	if s.streamSend {
+		s.sendData(data, endStream)
+		return nil
+	}
+
 	s.response.SetBody(data.Bytes())
 
 	if endStream {
@@ -635,25 +772,43 @@ func (s *serverStream) AppendData(context context.Context, data types.IoBuffer,
 }
 
 func (s *serverStream) AppendTrailers(context context.Context, trailers types.HeaderMap) error {
+	if s.streamSend {
+		s.sendTrailers(trailers)
+		return nil
+	}
+
+	// http/1.1 has no trailers, the grpc-web response carries them as the last frame in the body
+	if contentType := string(s.response.Header.ContentType()); grpc.IsWeb(contentType) {
+		s.response.AppendBody(grpc.EncodeWebTrailers(contentType, trailers))
+	}
 	s.endStream()
 	return nil
 }
 
-func (s *serverStream) endStream() {
-	resetConn := false
-	// check if we need close connection
+// checkConnection sets the connection header of the response, returns true if the connection should be closed
+func (s *serverStream) checkConnection() bool {
 	if s.connection.close || s.request.Header.ConnectionClose() {
 		s.response.SetConnectionClose()
-		resetConn = true
+		return true
 	} else if !s.request.Header.IsHTTP11() {
 		// Set 'Connection: keep-alive' response header for non-HTTP/1.1 request.
 		// There is no need in setting this header for http/1.1, since in http/1.1
 		// connections are keep-alive by default.
 		s.response.Header.SetCanonical(HKConnection, HVKeepAlive)
 	}
+	return false
+}
+
+func (s *serverStream) endStream() {
+	// check if we need close connection
+	resetConn := s.checkConnection()
 	defer s.DestroyStream()
 
 	s.doSend()
+	s.finish(resetConn)
+}
+
+func (s *serverStream) finish(resetConn bool) {
 	s.responseDoneChan <- true
 
 	if resetConn {
@@ -667,18 +822,36 @@ func (s *serverStream) endStream() {
 	s.connection.mutex.Unlock()
 }
 
+// types.UpgradableStream
+// Upgrade should be called before the response with status 101 is sent
+func (s *serverStream) Upgrade(listener types.TunnelListener) types.StreamTunnel {
+	t := newTunnel(s.connection.conn, listener)
+	s.connection.mutex.Lock()
+	s.connection.tunnel = t
+	s.connection.mutex.Unlock()
+	return t
+}
+
 func (s *serverStream) ReadDisable(disable bool) {
 	if disable {
 		atomic.AddInt32(&s.readDisableCount, 1)
 	} else {
 		newCount := atomic.AddInt32(&s.readDisableCount, -1)
 
-		if newCount <= 0 {
+		if newCount <= 0 && !s.resumeRead() {
 			s.handleRequest()
 		}
 	}
 }
 
+// ResetStream closes the connection with a partly streamed response, as the response can not be completed
+func (s *serverStream) ResetStream(reason types.StreamResetReason) {
+	if s.streamSend {
+		s.connection.conn.Close(types.NoFlush, types.LocalClose)
+	}
+	s.stream.ResetStream(reason)
+}
+
 func (s *serverStream) doSend() {
 	if _, err := s.response.WriteTo(s.connection); err != nil {
 		log.Proxy.Errorf(s.stream.ctx, "[stream] [http] send server response error: %+v", err)
@@ -27,6 +27,7 @@ import (
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/protocol"
 	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	str "sofastack.io/sofa-mosn/pkg/stream"
 	"github.com/valyala/fasthttp"
 )
 
@@ -253,3 +254,23 @@ func convertHeader(payload protocol.CommonHeader) http.RequestHeader {
 
 	return header
 }
+
+func Test_ProtocolMatch(t *testing.T) {
+	factory := &streamConnFactory{}
+	testCases := []struct {
+		prot  string
+		magic string
+		err   error
+	}{
+		{"", "GET / HTTP/1.1", nil},
+		{"", "GE", str.EAGAIN},
+		{"", "PRI * HTTP/2.0", str.FAILED},
+		// negotiated by TLS ALPN
+		{"http/1.1", "", nil},
+	}
+	for _, tc := range testCases {
+		if err := factory.ProtocolMatch(nil, tc.prot, []byte(tc.magic)); err != tc.err {
+			t.Errorf("prot %s magic %s expected %v, got %v", tc.prot, tc.magic, tc.err, err)
+		}
+	}
+}
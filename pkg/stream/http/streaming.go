@@ -0,0 +1,399 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/grpc"
+	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const (
+	// the max size of a body piece read from the connection in the streaming mode
+	streamPieceSize = 16 * 1024
+
+	headerContentLength = "Content-Length"
+)
+
+var (
+	errChunkSize     = errors.New("invalid chunk size")
+	errChunkEnd      = errors.New("invalid chunk end")
+	strChunkEnd      = []byte("\r\n")
+	strLastChunk     = []byte("0\r\n")
+	strHeaderDivider = []byte(": ")
+)
+
+// bodyReader reads the body of a http1 message in pieces
+type bodyReader struct {
+	br *bufio.Reader
+	// the remaining bytes of a body with content length,
+	// -1 for the chunked encoding, and -2 for the body ends with the connection
+	remaining int
+	chunkLeft int
+	trailers  protocol.CommonHeader
+}
+
+func newBodyReader(br *bufio.Reader, contentLength int) *bodyReader {
+	return &bodyReader{
+		br:        br,
+		remaining: contentLength,
+	}
+}
+
+// next reads the next piece of the body into buf, endStream is true at the end of the body.
+// the trailers of the chunked encoding are kept in trailers.
+func (r *bodyReader) next(buf []byte) (n int, endStream bool, err error) {
+	switch {
+	case r.remaining >= 0:
+		if r.remaining == 0 {
+			return 0, true, nil
+		}
+		if len(buf) > r.remaining {
+			buf = buf[:r.remaining]
+		}
+		n, err = r.br.Read(buf)
+		r.remaining -= n
+		return n, err == nil && r.remaining == 0, err
+
+	case r.remaining == -1:
+		if r.chunkLeft == 0 {
+			size, err := readChunkSize(r.br)
+			if err != nil {
+				return 0, false, err
+			}
+			if size == 0 {
+				r.trailers, err = readChunkTrailers(r.br)
+				return 0, err == nil, err
+			}
+			r.chunkLeft = size
+		}
+		if len(buf) > r.chunkLeft {
+			buf = buf[:r.chunkLeft]
+		}
+		n, err = r.br.Read(buf)
+		r.chunkLeft -= n
+		if err == nil && r.chunkLeft == 0 {
+			err = readChunkEnd(r.br)
+		}
+		return n, false, err
+
+	default:
+		n, err = r.br.Read(buf)
+		if err == io.EOF || err == errConnClose {
+			return n, true, nil
+		}
+		return n, false, err
+	}
+}
+
+func readChunkSize(br *bufio.Reader) (int, error) {
+	line, err := br.ReadSlice('\n')
+	if err != nil {
+		return 0, err
+	}
+	// ignore the chunk extensions
+	if i := bytes.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	line = bytes.TrimSpace(line)
+	size, err := strconv.ParseInt(string(line), 16, 32)
+	if err != nil || size < 0 {
+		return 0, errChunkSize
+	}
+	return int(size), nil
+}
+
+func readChunkEnd(br *bufio.Reader) error {
+	line, err := br.ReadSlice('\n')
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(line)) != 0 {
+		return errChunkEnd
+	}
+	return nil
+}
+
+// readChunkTrailers reads the trailers after the last chunk, it returns nil if there are no trailers
+func readChunkTrailers(br *bufio.Reader) (protocol.CommonHeader, error) {
+	var trailers protocol.CommonHeader
+	for {
+		line, err := br.ReadSlice('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			return trailers, nil
+		}
+		if i := bytes.IndexByte(line, ':'); i > 0 {
+			if trailers == nil {
+				trailers = make(protocol.CommonHeader)
+			}
+			trailers.Set(string(bytes.TrimSpace(line[:i])), string(bytes.TrimSpace(line[i+1:])))
+		}
+	}
+}
+
+// appendBody appends a piece of the body, in the chunked encoding if chunked is true
+func appendBody(dst []byte, p []byte, chunked bool) []byte {
+	if !chunked {
+		return append(dst, p...)
+	}
+	if len(p) == 0 {
+		return dst
+	}
+	dst = strconv.AppendInt(dst, int64(len(p)), 16)
+	dst = append(dst, strChunkEnd...)
+	dst = append(dst, p...)
+	return append(dst, strChunkEnd...)
+}
+
+// appendLastChunk appends the last chunk and the trailers of the chunked encoding
+func appendLastChunk(dst []byte, trailers types.HeaderMap) []byte {
+	dst = append(dst, strLastChunk...)
+	if trailers != nil {
+		trailers.Range(func(key, value string) bool {
+			dst = append(dst, key...)
+			dst = append(dst, strHeaderDivider...)
+			dst = append(dst, value...)
+			dst = append(dst, strChunkEnd...)
+			return true
+		})
+	}
+	return append(dst, strChunkEnd...)
+}
+
+// hasRequestBody returns true if a body follows the request headers
+func hasRequestBody(contentLength int) bool {
+	// the request without content length and chunked encoding has no body
+	return contentLength > 0 || contentLength == -1
+}
+
+// hasResponseBody returns true if a body follows the response headers
+func hasResponseBody(request *fasthttp.Request, response *fasthttp.Response) bool {
+	statusCode := response.StatusCode()
+	if request.Header.IsHead() || statusCode < 200 ||
+		statusCode == fasthttp.StatusNoContent || statusCode == fasthttp.StatusNotModified {
+		return false
+	}
+	return response.Header.ContentLength() != 0
+}
+
+// waitReadEnabled waits until the stream is read enabled, returns false if the connection is closed
+func (s *stream) waitReadEnabled(connClosed chan bool) bool {
+	for atomic.LoadInt32(&s.readDisableCount) > 0 {
+		select {
+		case <-s.resume:
+		case <-connClosed:
+			return false
+		}
+	}
+	return true
+}
+
+// resumeRead wakes up the streaming body reader, returns false if the stream is not in the streaming mode
+func (s *stream) resumeRead() bool {
+	if s.resume == nil {
+		return false
+	}
+	select {
+	case s.resume <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// receiveBody reads the body in pieces and passes them to the receiver,
+// returns an error if the body can not be read
+func (s *stream) receiveBody(receiver types.StreamingReceiveListener, body *bodyReader, connClosed chan bool) error {
+	buf := make([]byte, streamPieceSize)
+	for {
+		if !s.waitReadEnabled(connClosed) {
+			return errConnClose
+		}
+		n, endStream, err := body.next(buf)
+		if err != nil {
+			return err
+		}
+		if endStream && body.trailers != nil {
+			if n > 0 {
+				receiver.OnReceiveData(s.ctx, buffer.NewIoBufferBytes(append([]byte(nil), buf[:n]...)), false)
+			}
+			receiver.OnReceiveTrailers(s.ctx, body.trailers)
+			return nil
+		}
+		if n > 0 || endStream {
+			receiver.OnReceiveData(s.ctx, buffer.NewIoBufferBytes(append([]byte(nil), buf[:n]...)), endStream)
+		}
+		if endStream {
+			return nil
+		}
+	}
+}
+
+// types.StreamSender in the streaming mode
+// sendHeaders writes the request headers, the body is sent in the chunked encoding without content length
+func (s *clientStream) sendHeaders() {
+	s.streamSend = true
+	s.chunked = len(s.request.Header.Peek(headerContentLength)) == 0
+	if s.chunked {
+		s.request.Header.SetContentLength(-1)
+	}
+	if _, err := s.connection.Write(s.request.Header.Header()); err != nil {
+		s.sendFailed(err)
+		return
+	}
+	// the response may arrive before the request body is sent
+	s.connection.requestSent <- true
+}
+
+func (s *clientStream) sendData(data types.IoBuffer, endStream bool) {
+	buf := appendBody(nil, data.Bytes(), s.chunked)
+	if endStream && s.chunked {
+		buf = appendLastChunk(buf, nil)
+	}
+	s.write(buf)
+}
+
+func (s *clientStream) sendTrailers(trailers types.HeaderMap) {
+	if s.chunked {
+		s.write(appendLastChunk(nil, trailers))
+	}
+}
+
+func (s *clientStream) write(buf []byte) {
+	if len(buf) == 0 {
+		return
+	}
+	if _, err := s.connection.Write(buf); err != nil {
+		s.sendFailed(err)
+	}
+}
+
+// receiveResponse reads the response body in pieces, it returns false if the connection is broken
+func (s *clientStream) receiveResponse(receiver types.StreamingReceiveListener) bool {
+	header := s.responseHeader()
+
+	s.connection.mutex.Lock()
+	s.connection.stream = nil
+	s.connection.mutex.Unlock()
+
+	s.resume = make(chan struct{}, 1)
+	receiver.OnReceiveHeaders(s.ctx, header, false)
+
+	body := newBodyReader(s.connection.br, s.response.Header.ContentLength())
+	if err := s.receiveBody(receiver, body, s.connection.connClosed); err != nil {
+		log.Proxy.Errorf(s.connection.context, "[stream] [http] client stream connection read response body error: %s", err)
+		reason := s.connection.resetReason
+		if reason == "" {
+			reason = types.StreamRemoteReset
+		}
+		s.ResetStream(reason)
+		return false
+	}
+	return true
+}
+
+// sendHeaders writes the response headers, the body is sent in the chunked encoding without content length
+func (s *serverStream) sendHeaders() {
+	s.streamSend = true
+	s.closeConn = s.checkConnection()
+	if s.response.Header.ContentLength() == -2 {
+		// the upstream body ends with its connection, use the chunked encoding instead
+		if !s.closeConn {
+			s.response.Header.ResetConnectionClose()
+		}
+		s.response.Header.SetContentLength(-1)
+	} else if len(s.response.Header.Peek(headerContentLength)) == 0 {
+		s.response.Header.SetContentLength(-1)
+	}
+	s.chunked = s.response.Header.ContentLength() == -1
+	s.write(s.response.Header.Header())
+}
+
+func (s *serverStream) sendData(data types.IoBuffer, endStream bool) {
+	s.write(appendBody(nil, data.Bytes(), s.chunked))
+	if endStream {
+		s.endStreaming(nil)
+	}
+}
+
+func (s *serverStream) sendTrailers(trailers types.HeaderMap) {
+	// the grpc-web response carries the trailers as the last frame in the body
+	if contentType := string(s.response.Header.ContentType()); grpc.IsWeb(contentType) {
+		s.write(appendBody(nil, grpc.EncodeWebTrailers(contentType, trailers), s.chunked))
+		trailers = nil
+	}
+	s.endStreaming(trailers)
+}
+
+func (s *serverStream) endStreaming(trailers types.HeaderMap) {
+	defer s.DestroyStream()
+
+	if s.chunked {
+		s.write(appendLastChunk(nil, trailers))
+	}
+	s.finish(s.closeConn)
+}
+
+func (s *serverStream) write(buf []byte) {
+	if _, err := s.connection.Write(buf); err != nil {
+		log.Proxy.Errorf(s.stream.ctx, "[stream] [http] send server response error: %+v", err)
+	}
+}
+
+// receiveRequest reads the request body in pieces, it returns false if the connection is broken
+func (s *serverStream) receiveRequest() bool {
+	receiver, ok := str.StreamingReceiver(s.ctx, s.receiver)
+	if !ok {
+		// the receiver does not accept the streamed body, read it completely
+		if err := s.request.ContinueReadBody(s.connection.br, defaultMaxRequestBodySize); err != nil {
+			s.connection.readFailed(err)
+			return false
+		}
+		if atomic.LoadInt32(&s.readDisableCount) <= 0 {
+			s.handleRequest()
+		}
+		return true
+	}
+
+	injectInternalHeaders(s.header, s.request.URI())
+	s.resume = make(chan struct{}, 1)
+	receiver.OnReceiveHeaders(s.ctx, s.header, false)
+
+	body := newBodyReader(s.connection.br, s.request.Header.ContentLength())
+	if err := s.receiveBody(receiver, body, s.connection.connClosed); err != nil {
+		if err != errConnClose {
+			log.Proxy.Errorf(s.stream.ctx, "[stream] [http] server stream connection read request body error: %s", err)
+			s.connection.conn.Close(types.NoFlush, types.LocalClose)
+		}
+		return false
+	}
+	return true
+}
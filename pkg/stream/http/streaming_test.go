@@ -0,0 +1,323 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	mosnhttp "sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func readBody(t *testing.T, body *bodyReader, size int) []string {
+	var pieces []string
+	buf := make([]byte, size)
+	for i := 0; i < 100; i++ {
+		n, endStream, err := body.next(buf)
+		if err != nil {
+			t.Fatalf("read body error: %v", err)
+		}
+		if n > 0 {
+			pieces = append(pieces, string(buf[:n]))
+		}
+		if endStream {
+			return pieces
+		}
+	}
+	t.Fatalf("the body does not end")
+	return nil
+}
+
+func TestBodyReader(t *testing.T) {
+	testCases := []struct {
+		name          string
+		raw           string
+		contentLength int
+		size          int
+		pieces        []string
+		trailers      map[string]string
+	}{
+		{
+			name:          "content length",
+			raw:           "hello world, next request",
+			contentLength: 11,
+			size:          4,
+			pieces:        []string{"hell", "o wo", "rld"},
+		},
+		{
+			name:          "chunked",
+			raw:           "5\r\nhello\r\n6;ext=1\r\n world\r\n0\r\n\r\nnext request",
+			contentLength: -1,
+			size:          16,
+			pieces:        []string{"hello", " world"},
+		},
+		{
+			name:          "chunked pieces",
+			raw:           "b\r\nhello world\r\n0\r\nGrpc-Status: 0\r\nGrpc-Message: ok\r\n\r\n",
+			contentLength: -1,
+			size:          4,
+			pieces:        []string{"hell", "o wo", "rld"},
+			trailers:      map[string]string{"Grpc-Status": "0", "Grpc-Message": "ok"},
+		},
+		{
+			name:          "connection close",
+			raw:           "hello world",
+			contentLength: -2,
+			size:          16,
+			pieces:        []string{"hello world"},
+		},
+	}
+
+	for _, tc := range testCases {
+		body := newBodyReader(bufio.NewReader(strings.NewReader(tc.raw)), tc.contentLength)
+		pieces := readBody(t, body, tc.size)
+		if strings.Join(pieces, "|") != strings.Join(tc.pieces, "|") {
+			t.Errorf("%s: unexpected pieces: %q", tc.name, pieces)
+		}
+		if len(body.trailers) != len(tc.trailers) {
+			t.Errorf("%s: unexpected trailers: %v", tc.name, body.trailers)
+		}
+		for k, v := range tc.trailers {
+			if got, _ := body.trailers.Get(k); got != v {
+				t.Errorf("%s: unexpected trailer %s: %s", tc.name, k, got)
+			}
+		}
+	}
+}
+
+func TestBodyReaderInvalidChunk(t *testing.T) {
+	for _, raw := range []string{"x\r\nhello\r\n", "5\r\nhelloxx\r\n", "-1\r\n"} {
+		body := newBodyReader(bufio.NewReader(strings.NewReader(raw)), -1)
+		buf := make([]byte, 16)
+		var err error
+		for i := 0; i < 3 && err == nil; i++ {
+			_, _, err = body.next(buf)
+		}
+		if err == nil {
+			t.Errorf("invalid chunk %q should fail", raw)
+		}
+	}
+}
+
+func TestAppendBody(t *testing.T) {
+	buf := appendBody(nil, []byte("hello world, hello mosn"), true)
+	buf = appendBody(buf, nil, true)
+	buf = appendLastChunk(buf, protocol.CommonHeader{"Grpc-Status": "0"})
+	if string(buf) != "17\r\nhello world, hello mosn\r\n0\r\nGrpc-Status: 0\r\n\r\n" {
+		t.Errorf("unexpected chunked body: %q", buf)
+	}
+	if buf := appendBody(nil, []byte("hello"), false); string(buf) != "hello" {
+		t.Errorf("unexpected body: %q", buf)
+	}
+}
+
+type mockStreamingReceiver struct {
+	headers  chan types.HeaderMap
+	data     chan string
+	end      chan bool
+	trailers chan types.HeaderMap
+}
+
+func newMockStreamingReceiver() *mockStreamingReceiver {
+	return &mockStreamingReceiver{
+		headers:  make(chan types.HeaderMap, 1),
+		data:     make(chan string, 16),
+		end:      make(chan bool, 1),
+		trailers: make(chan types.HeaderMap, 1),
+	}
+}
+
+func (r *mockStreamingReceiver) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	r.headers <- headers
+	r.end <- true
+}
+
+func (r *mockStreamingReceiver) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+}
+
+func (r *mockStreamingReceiver) OnReceiveHeaders(ctx context.Context, headers types.HeaderMap, endStream bool) {
+	r.headers <- headers
+}
+
+func (r *mockStreamingReceiver) OnReceiveData(ctx context.Context, data types.IoBuffer, endStream bool) {
+	r.data <- data.String()
+	if endStream {
+		r.end <- true
+	}
+}
+
+func (r *mockStreamingReceiver) OnReceiveTrailers(ctx context.Context, trailers types.HeaderMap) {
+	r.trailers <- trailers
+}
+
+func (r *mockStreamingReceiver) receiveData(t *testing.T, expected string) {
+	var received string
+	for received != expected {
+		select {
+		case data := <-r.data:
+			received += data
+		case <-time.After(time.Second):
+			t.Fatalf("wait data timeout, received: %q", received)
+		}
+	}
+}
+
+func TestClientStreamStreaming(t *testing.T) {
+	conn := &mockTunnelConnection{}
+	csc := newClientStreamConnection(context.Background(), conn, &mockUpgradeEventListener{}, nil).(*clientStreamConnection)
+
+	receiver := newMockStreamingReceiver()
+	ctx := buffer.NewBufferPoolContext(mosnctx.WithValue(context.Background(), types.ContextKeyStreamBody, true))
+	sender := csc.NewStream(ctx, receiver)
+
+	// the request without content length is sent in the chunked encoding as it arrives
+	sender.AppendHeaders(ctx, convertHeader(protocol.CommonHeader{
+		protocol.MosnHeaderMethod: "POST",
+	}), false)
+	sender.AppendData(ctx, buffer.NewIoBufferString("hello"), false)
+	conn.mux.Lock()
+	written := string(conn.written)
+	conn.mux.Unlock()
+	if !strings.Contains(written, "Transfer-Encoding: chunked\r\n") || !strings.HasSuffix(written, "\r\n\r\n5\r\nhello\r\n") {
+		t.Fatalf("unexpected written request: %q", written)
+	}
+	sender.AppendTrailers(ctx, protocol.CommonHeader{"Grpc-Status": "0"})
+	conn.mux.Lock()
+	written = string(conn.written)
+	conn.mux.Unlock()
+	if !strings.HasSuffix(written, "5\r\nhello\r\n0\r\nGrpc-Status: 0\r\n\r\n") {
+		t.Fatalf("unexpected written request: %q", written)
+	}
+
+	// the response is received in pieces, and paused by the read disable
+	go csc.Dispatch(buffer.NewIoBufferString("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n"))
+	select {
+	case headers := <-receiver.headers:
+		if code, _ := headers.Get(types.HeaderStatus); code != "200" {
+			t.Fatalf("unexpected response status: %s", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wait response headers timeout")
+	}
+	receiver.receiveData(t, "hello")
+
+	stream := sender.GetStream().(types.FlowControlStream)
+	stream.ReadDisable(true)
+	// the reader is waiting for the piece in flight already
+	go csc.Dispatch(buffer.NewIoBufferString("6\r\n world\r\n"))
+	receiver.receiveData(t, " world")
+	go csc.Dispatch(buffer.NewIoBufferString("1\r\n!\r\n0\r\n\r\n"))
+	select {
+	case data := <-receiver.data:
+		t.Fatalf("the read disabled stream should not receive data: %q", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+	stream.ReadDisable(false)
+	receiver.receiveData(t, "!")
+	select {
+	case <-receiver.end:
+	case <-time.After(time.Second):
+		t.Fatal("wait response end timeout")
+	}
+	if csc.ActiveStreamsNum() != 0 {
+		t.Fatal("the connection should be released after the response")
+	}
+	csc.Reset(types.StreamConnectionTermination)
+}
+
+type mockServerConnection struct {
+	mockTunnelConnection
+}
+
+func (c *mockServerConnection) AddConnectionEventListener(listener types.ConnectionEventListener) {}
+
+func (c *mockServerConnection) SetTransferEventListener(listener func() bool) {}
+
+type mockServerListener struct {
+	receiver *mockStreamingReceiver
+	senders  chan types.StreamSender
+}
+
+func (l *mockServerListener) OnGoAway() {}
+
+func (l *mockServerListener) NewStreamDetect(ctx context.Context, sender types.StreamSender, span types.Span) types.StreamReceiveListener {
+	l.senders <- sender
+	return l.receiver
+}
+
+func TestServerStreamStreaming(t *testing.T) {
+	conn := &mockServerConnection{}
+	listener := &mockServerListener{
+		receiver: newMockStreamingReceiver(),
+		senders:  make(chan types.StreamSender, 1),
+	}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyStreamBody, true)
+	ssc := newServerStreamConnection(ctx, conn, listener).(*serverStreamConnection)
+
+	go ssc.Dispatch(buffer.NewIoBufferString("POST /upload HTTP/1.1\r\nHost: mosn\r\nContent-Length: 11\r\n\r\nhello"))
+	var sender types.StreamSender
+	select {
+	case sender = <-listener.senders:
+	case <-time.After(time.Second):
+		t.Fatal("wait new stream timeout")
+	}
+	select {
+	case headers := <-listener.receiver.headers:
+		if path, _ := headers.Get(protocol.MosnHeaderPathKey); path != "/upload" {
+			t.Fatalf("unexpected request path: %s", path)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wait request headers timeout")
+	}
+	listener.receiver.receiveData(t, "hello")
+
+	// the response is sent before the request ends
+	header := mosnhttp.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	header.Set(types.HeaderStatus, "200")
+	sender.AppendHeaders(ctx, header, false)
+	sender.AppendData(ctx, buffer.NewIoBufferString("event: 1\n"), false)
+
+	go ssc.Dispatch(buffer.NewIoBufferString(" world"))
+	listener.receiver.receiveData(t, " world")
+	select {
+	case <-listener.receiver.end:
+	case <-time.After(time.Second):
+		t.Fatal("wait request end timeout")
+	}
+
+	sender.AppendData(ctx, buffer.NewIoBufferString("event: 2\n"), true)
+	conn.mux.Lock()
+	written := string(conn.written)
+	conn.mux.Unlock()
+	if !strings.HasPrefix(written, "HTTP/1.1 200 OK\r\n") || !strings.Contains(written, "Transfer-Encoding: chunked\r\n") ||
+		!strings.HasSuffix(written, "\r\n\r\n9\r\nevent: 1\n\r\n9\r\nevent: 2\n\r\n0\r\n\r\n") {
+		t.Fatalf("unexpected written response: %q", written)
+	}
+	if ssc.ActiveStreamsNum() != 0 {
+		t.Fatal("the stream should be finished")
+	}
+	ssc.OnEvent(types.RemoteClose)
+}
@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"io"
+	"sync"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const defaultTunnelReadSize = 16 * 1024
+
+// types.StreamTunnel
+// tunnel is the raw byte tunnel on an upgraded connection, the bytes read from
+// the connection are not decoded as http1 any more.
+type tunnel struct {
+	conn     types.Connection
+	listener types.TunnelListener
+
+	started   chan struct{}
+	startOnce sync.Once
+}
+
+func newTunnel(conn types.Connection, listener types.TunnelListener) *tunnel {
+	return &tunnel{
+		conn:     conn,
+		listener: listener,
+		started:  make(chan struct{}),
+	}
+}
+
+func (t *tunnel) Start() {
+	t.startOnce.Do(func() {
+		close(t.started)
+	})
+}
+
+func (t *tunnel) Write(data types.IoBuffer) error {
+	return t.conn.Write(data)
+}
+
+func (t *tunnel) Close() {
+	t.conn.Close(types.FlushWrite, types.LocalClose)
+	// the tunnel may be closed before started, wake up the reader to see the close
+	t.Start()
+}
+
+// serve reads the raw bytes until the connection is closed.
+// the reader may have buffered some bytes after the upgrade request/response, they are passed first.
+func (t *tunnel) serve(r io.Reader) {
+	<-t.started
+
+	p := make([]byte, defaultTunnelReadSize)
+	for {
+		n, err := r.Read(p)
+		if n > 0 {
+			data := buffer.GetIoBuffer(n)
+			data.Write(p[:n])
+			t.listener.OnTunnelData(data)
+		}
+		if err != nil {
+			t.listener.OnTunnelClose()
+			return
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package http
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+type mockTunnelConnection struct {
+	types.ClientConnection
+	mux     sync.Mutex
+	written []byte
+	closed  bool
+}
+
+func (c *mockTunnelConnection) Write(bufs ...types.IoBuffer) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for _, buf := range bufs {
+		c.written = append(c.written, buf.Bytes()...)
+	}
+	return nil
+}
+
+func (c *mockTunnelConnection) RemoteAddr() net.Addr {
+	addr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:8080")
+	return addr
+}
+
+func (c *mockTunnelConnection) Close(ccType types.ConnectionCloseType, eventType types.ConnectionEvent) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.closed = true
+	return nil
+}
+
+type mockTunnelListener struct {
+	data   chan string
+	closed chan struct{}
+}
+
+func (l *mockTunnelListener) OnTunnelData(data types.IoBuffer) {
+	l.data <- data.String()
+}
+
+func (l *mockTunnelListener) OnTunnelClose() {
+	close(l.closed)
+}
+
+type mockUpgradeReceiver struct {
+	headers chan types.HeaderMap
+}
+
+func (r *mockUpgradeReceiver) OnReceive(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+	r.headers <- headers
+}
+
+func (r *mockUpgradeReceiver) OnDecodeError(ctx context.Context, err error, headers types.HeaderMap) {
+}
+
+type mockUpgradeEventListener struct {
+	upgraded bool
+}
+
+func (l *mockUpgradeEventListener) OnGoAway() {}
+
+func (l *mockUpgradeEventListener) OnUpgrade() {
+	l.upgraded = true
+}
+
+func TestClientStreamUpgrade(t *testing.T) {
+	conn := &mockTunnelConnection{}
+	eventListener := &mockUpgradeEventListener{}
+	csc := newClientStreamConnection(context.Background(), conn, eventListener, nil).(*clientStreamConnection)
+
+	receiver := &mockUpgradeReceiver{headers: make(chan types.HeaderMap, 1)}
+	ctx := buffer.NewBufferPoolContext(context.Background())
+	sender := csc.NewStream(ctx, receiver)
+	sender.AppendHeaders(ctx, convertHeader(protocol.CommonHeader{
+		"Connection": "Upgrade",
+		"Upgrade":    "websocket",
+	}), true)
+
+	// the bytes after the upgrade response belong to the tunnel
+	go csc.Dispatch(buffer.NewIoBufferString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\nhello"))
+
+	select {
+	case headers := <-receiver.headers:
+		if code, _ := headers.Get(types.HeaderStatus); code != "101" {
+			t.Fatalf("unexpected response status: %s", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wait upgrade response timeout")
+	}
+	if !eventListener.upgraded || csc.ActiveStreamsNum() != 1 {
+		t.Fatal("the upgraded connection should be in use")
+	}
+
+	listener := &mockTunnelListener{
+		data:   make(chan string, 2),
+		closed: make(chan struct{}),
+	}
+	tunnel := sender.(types.UpgradableStream).Upgrade(listener)
+	tunnel.Start()
+
+	go csc.Dispatch(buffer.NewIoBufferString(" world"))
+	var received string
+	for received != "hello world" {
+		select {
+		case data := <-listener.data:
+			received += data
+		case <-time.After(time.Second):
+			t.Fatalf("wait tunnel data timeout, received: %s", received)
+		}
+	}
+
+	tunnel.Write(buffer.NewIoBufferString("ping"))
+	conn.mux.Lock()
+	written := string(conn.written)
+	conn.mux.Unlock()
+	if len(written) < 4 || written[len(written)-4:] != "ping" {
+		t.Errorf("unexpected written data: %s", written)
+	}
+
+	// the connection is closed, the tunnel is closed
+	csc.Reset(types.StreamConnectionTermination)
+	select {
+	case <-listener.closed:
+	case <-time.After(time.Second):
+		t.Fatal("wait tunnel close timeout")
+	}
+}
@@ -81,7 +81,8 @@ func (p *connPool) NewStream(ctx context.Context,
 		return
 	}
 
-	if !p.host.ClusterInfo().ResourceManager().Requests().CanCreate() {
+	requests := str.NewRequestResource(ctx, p.host)
+	if !requests.CanCreate() {
 		listener.OnFailure(types.Overflow, p.host)
 		p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
@@ -91,9 +92,10 @@ func (p *connPool) NewStream(ctx context.Context,
 		p.host.HostStats().UpstreamRequestActive.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestTotal.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
-		p.host.ClusterInfo().ResourceManager().Requests().Increase()
+		requests.Increase()
 		streamEncoder := activeClient.client.NewStream(ctx, responseDecoder)
 		streamEncoder.GetStream().AddEventListener(activeClient)
+		streamEncoder.GetStream().AddEventListener(requests)
 
 		listener.OnReady(streamEncoder, p.host)
 	}
@@ -140,7 +142,6 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 func (p *connPool) onStreamDestroy(client *activeClient) {
 	p.host.HostStats().UpstreamRequestActive.Dec(1)
 	p.host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
-	p.host.ClusterInfo().ResourceManager().Requests().Decrease()
 }
 
 func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetReason) {
@@ -151,7 +152,7 @@ func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetR
 	} else if reason == types.StreamLocalReset {
 		p.host.HostStats().UpstreamRequestLocalReset.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestLocalReset.Inc(1)
-	} else if reason == types.StreamRemoteReset {
+	} else if reason == types.StreamRemoteReset || reason == types.StreamRefusedReset {
 		p.host.HostStats().UpstreamRequestRemoteReset.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestRemoteReset.Inc(1)
 	}
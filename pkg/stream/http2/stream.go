@@ -27,6 +27,7 @@ import (
 	"reflect"
 	"strconv"
 	"sync"
+	"time"
 
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	mosnctx "sofastack.io/sofa-mosn/pkg/context"
@@ -34,8 +35,10 @@ import (
 	"sofastack.io/sofa-mosn/pkg/module/http2"
 	"sofastack.io/sofa-mosn/pkg/mtls"
 	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/grpc"
 	mhttp2 "sofastack.io/sofa-mosn/pkg/protocol/http2"
 	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/trace"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -62,6 +65,10 @@ func (f *streamConnFactory) CreateBiDirectStream(context context.Context, connec
 }
 
 func (f *streamConnFactory) ProtocolMatch(context context.Context, prot string, magic []byte) error {
+	// negotiated by TLS ALPN
+	if prot == http2.NextProtoTLS {
+		return nil
+	}
 	var size int
 	var again bool
 	if len(magic) >= len(http2.ClientPreface) {
@@ -114,6 +121,12 @@ type stream struct {
 	header   types.HeaderMap
 	sendData []types.IoBuffer
 	conn     types.Connection
+
+	// ~~~ streaming mode
+	// streaming receives the data frames as they arrive
+	streaming types.StreamingReceiveListener
+	// the headers are sent, the data frames follow
+	streamSend bool
 }
 
 // ~~ types.Stream
@@ -133,6 +146,17 @@ func (s *stream) GetStream() types.Stream {
 	return s
 }
 
+// receiveStreaming passes a data frame or the trailers to the streaming receiver
+func (s *stream) receiveStreaming(ctx context.Context, data []byte, hasTrailer bool, trailer http.Header, endStream bool) {
+	if hasTrailer {
+		log.Proxy.Debugf(s.ctx, "http2 stream trailer: id = %d, trailers = %+v", s.id, trailer)
+		s.streaming.OnReceiveTrailers(ctx, mhttp2.NewHeaderMap(trailer))
+		return
+	}
+	log.Proxy.Debugf(s.ctx, "http2 stream data: id = %d, len = %d, endStream = %t", s.id, len(data), endStream)
+	s.streaming.OnReceiveData(ctx, buffer.NewIoBufferBytes(data).Clone(), endStream)
+}
+
 func (s *stream) buildData() types.IoBuffer {
 	if s.sendData == nil {
 		return buffer.NewIoBuffer(0)
@@ -232,6 +256,11 @@ func (conn *serverStreamConnection) Reset(reason types.StreamResetReason) {
 }
 
 func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface{}, err error) {
+	if upgrade, ok := i.(*http2.H2cUpgrade); ok && err == nil {
+		conn.handleUpgrade(ctx, upgrade)
+		return
+	}
+
 	f, _ := i.(http2.Frame)
 	if err != nil {
 		conn.handleError(ctx, f, err)
@@ -262,33 +291,15 @@ func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface
 			return
 		}
 
-		header := mhttp2.NewReqHeader(h2s.Request)
-
-		scheme := "http"
-		if _, ok := conn.conn.RawConn().(*mtls.TLSConn); ok {
-			scheme = "https"
-		}
-		var URI string
-		if h2s.Request.URL.RawQuery == "" {
-			URI = fmt.Sprintf(scheme+"://%s%s", h2s.Request.Host, h2s.Request.URL.Path)
-		} else {
-			URI = fmt.Sprintf(scheme+"://%s%s?%s", h2s.Request.Host, h2s.Request.URL.Path, h2s.Request.URL.RawQuery)
-
-		}
-		URL, _ := url.Parse(URI)
-		h2s.Request.URL = URL
-
-		header.Set(protocol.MosnHeaderMethod, h2s.Request.Method)
-		header.Set(protocol.MosnHeaderHostKey, h2s.Request.Host)
-		header.Set(protocol.MosnHeaderPathKey, h2s.Request.URL.Path)
-		if h2s.Request.URL.RawQuery != "" {
-			header.Set(protocol.MosnHeaderQueryStringKey, h2s.Request.URL.RawQuery)
-		}
+		header := conn.requestHeader(h2s)
 
 		log.Proxy.Debugf(stream.ctx, "http2 server header: %d, %+v", id, h2s.Request.Header)
 
 		if endStream {
 			stream.receiver.OnReceive(ctx, header, nil, nil)
+		} else if receiver, ok := str.StreamingReceiver(stream.ctx, stream.receiver); ok {
+			stream.streaming = receiver
+			receiver.OnReceiveHeaders(ctx, header, false)
 		} else {
 			stream.header = header
 		}
@@ -301,6 +312,13 @@ func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface
 		return
 	}
 
+	// the streamed request passes through as it arrives
+	if stream.streaming != nil {
+		stream.h2s.ReturnFlow(len(data))
+		stream.receiveStreaming(ctx, data, hasTrailer, stream.h2s.Request.Trailer, endStream)
+		return
+	}
+
 	// data
 	if data != nil {
 		log.DefaultLogger.Debugf("http2 server receive data: %d", id)
@@ -330,6 +348,54 @@ func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface
 	}
 }
 
+// handleUpgrade delivers the request of an h2c upgrade handshake, it is
+// complete once received so the stream is half-closed (remote) already.
+func (conn *serverStreamConnection) handleUpgrade(ctx context.Context, upgrade *http2.H2cUpgrade) {
+	stream, err := conn.onNewStreamDetect(ctx, upgrade.Stream, true)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "http2 server h2c upgrade error: %v", err)
+		conn.conn.Close(types.NoFlush, types.OnReadErrClose)
+		return
+	}
+
+	header := conn.requestHeader(upgrade.Stream)
+	log.Proxy.Debugf(stream.ctx, "http2 server h2c upgrade header: %d, %+v", stream.id, upgrade.Stream.Request.Header)
+
+	var data types.IoBuffer
+	if len(upgrade.Data) > 0 {
+		data = buffer.NewIoBufferBytes(upgrade.Data)
+	}
+	stream.receiver.OnReceive(ctx, header, data, nil)
+}
+
+// requestHeader wraps the decoded request and exposes its pseudo headers
+// for routing and protocol conversion
+func (conn *serverStreamConnection) requestHeader(h2s *http2.MStream) *mhttp2.ReqHeader {
+	header := mhttp2.NewReqHeader(h2s.Request)
+
+	scheme := "http"
+	if _, ok := conn.conn.RawConn().(*mtls.TLSConn); ok {
+		scheme = "https"
+	}
+	var URI string
+	if h2s.Request.URL.RawQuery == "" {
+		URI = fmt.Sprintf(scheme+"://%s%s", h2s.Request.Host, h2s.Request.URL.Path)
+	} else {
+		URI = fmt.Sprintf(scheme+"://%s%s?%s", h2s.Request.Host, h2s.Request.URL.Path, h2s.Request.URL.RawQuery)
+
+	}
+	URL, _ := url.Parse(URI)
+	h2s.Request.URL = URL
+
+	header.Set(protocol.MosnHeaderMethod, h2s.Request.Method)
+	header.Set(protocol.MosnHeaderHostKey, h2s.Request.Host)
+	header.Set(protocol.MosnHeaderPathKey, h2s.Request.URL.Path)
+	if h2s.Request.URL.RawQuery != "" {
+		header.Set(protocol.MosnHeaderQueryStringKey, h2s.Request.URL.RawQuery)
+	}
+	return header
+}
+
 func (conn *serverStreamConnection) handleError(ctx context.Context, f http2.Frame, err error) {
 	conn.sc.HandleError(ctx, f, err)
 	if err != nil {
@@ -370,7 +436,15 @@ func (conn *serverStreamConnection) onNewStreamDetect(ctx context.Context, h2s *
 		conn.mutex.Unlock()
 	}
 
-	stream.receiver = conn.serverCallbacks.NewStreamDetect(stream.ctx, stream, nil)
+	var span types.Span
+	if trace.IsEnabled() {
+		tracer := trace.Tracer(protocol.HTTP2)
+		if tracer != nil {
+			span = tracer.Start(ctx, mhttp2.NewReqHeader(h2s.Request), time.Now())
+		}
+	}
+
+	stream.receiver = conn.serverCallbacks.NewStreamDetect(stream.ctx, stream, span)
 	return stream, nil
 }
 
@@ -392,6 +466,9 @@ type serverStream struct {
 	stream
 	h2s *http2.MStream
 	sc  *serverStreamConnection
+
+	// trailersOnly is set when a grpc request is hijacked
+	trailersOnly bool
 }
 
 // types.StreamSender
@@ -416,8 +493,15 @@ func (s *serverStream) AppendHeaders(ctx context.Context, headers types.HeaderMa
 	case *mhttp2.ReqHeader:
 		// indicates the invocation is under hijack scene
 		rsp = new(http.Response)
-		rsp.StatusCode = status
-		rsp.Header = s.h2s.Request.Header
+		if grpc.IsGRPC(s.h2s.Request.Header.Get(grpc.HeaderContentType)) {
+			// grpc clients read the status from the trailers, reply a trailers-only response
+			rsp.StatusCode = http.StatusOK
+			rsp.Header = mhttp2.EncodeHeader(grpc.TrailersOnly(status))
+			s.trailersOnly = true
+		} else {
+			rsp.StatusCode = status
+			rsp.Header = s.h2s.Request.Header
+		}
 	default:
 		log.Proxy.Errorf(s.ctx, "http2 Server AppendHeaders error type :%v", reflect.TypeOf(headers))
 		return errors.New("header type error")
@@ -429,13 +513,25 @@ func (s *serverStream) AppendHeaders(ctx context.Context, headers types.HeaderMa
 
 	if endStream {
 		s.endStream()
+	} else if !s.trailersOnly && str.StreamBodyEnabled(s.ctx) {
+		s.streamSend = true
+		s.sendStreaming(s.h2s.WriteHeaders(false), false)
 	}
 
 	return nil
 }
 
 func (s *serverStream) AppendData(context context.Context, data types.IoBuffer, endStream bool) error {
-	s.h2s.SendData = data
+	if s.streamSend {
+		log.Proxy.Debugf(s.ctx, "http2 server stream data id = %d, len = %d", s.id, data.Len())
+		s.sendStreaming(s.h2s.WriteData(data.Bytes(), endStream), endStream)
+		return nil
+	}
+
+	// the body of a hijack reply is dropped, a trailers-only response has no data
+	if !s.trailersOnly {
+		s.h2s.SendData = data
+	}
 	log.Proxy.Debugf(s.ctx, "http2 server ApppendData id = %d", s.id)
 
 	if endStream {
@@ -456,11 +552,28 @@ func (s *serverStream) AppendTrailers(context context.Context, trailers types.He
 		return errors.New("trailers type error")
 	}
 	log.Proxy.Debugf(s.ctx, "http2 server ApppendTrailers id = %d, trailers = %+v", s.id, s.h2s.Response.Trailer)
+	if s.streamSend {
+		s.sendStreaming(s.h2s.WriteTrailers(), true)
+		return nil
+	}
 	s.endStream()
 
 	return nil
 }
 
+// sendStreaming handles the result of sending a part of the streamed response
+func (s *serverStream) sendStreaming(err error, endStream bool) {
+	if err != nil {
+		log.Proxy.Errorf(s.ctx, "http2 server stream send error :%v", err)
+		s.ResetStream(types.StreamLocalReset)
+		return
+	}
+	if endStream {
+		log.Proxy.Debugf(s.ctx, "http2 server SendResponse id = %d", s.id)
+		s.DestroyStream()
+	}
+}
+
 func (s *serverStream) endStream() {
 	defer s.DestroyStream()
 
@@ -622,12 +735,21 @@ func (conn *clientStreamConnection) handleFrame(ctx context.Context, i interface
 		log.Proxy.Debugf(stream.ctx, "http2 client header: id = %d, headers = %+v", id, rsp.Header)
 		if endStream {
 			stream.receiver.OnReceive(ctx, header, nil, nil)
+		} else if receiver, ok := str.StreamingReceiver(stream.ctx, stream.receiver); ok {
+			stream.streaming = receiver
+			receiver.OnReceiveHeaders(ctx, header, false)
 		} else {
 			stream.header = header
 		}
 		return
 	}
 
+	// the streamed response passes through as it arrives
+	if stream.streaming != nil {
+		stream.receiveStreaming(stream.ctx, data, trailer != nil, trailer, endStream)
+		return
+	}
+
 	// data
 	if data != nil {
 		log.Proxy.Debugf(stream.ctx, "http2 client receive data: id = %d", id)
@@ -671,7 +793,11 @@ func (conn *clientStreamConnection) handleError(ctx context.Context, f http2.Fra
 			}
 			conn.mutex.Unlock()
 			if s != nil {
-				s.ResetStream(types.StreamRemoteReset)
+				if err.Code == http2.ErrCodeRefusedStream {
+					s.ResetStream(types.StreamRefusedReset)
+				} else {
+					s.ResetStream(types.StreamRemoteReset)
+				}
 			}
 		case http2.ConnectionError:
 			log.Proxy.Errorf(ctx, "Http2 client handleError conn err: %v", err)
@@ -742,14 +868,14 @@ func (s *clientStream) AppendHeaders(ctx context.Context, headersIn types.Header
 	if path, ok := headersIn.Get(protocol.MosnHeaderPathKey); ok {
 		headersIn.Del(protocol.MosnHeaderPathKey)
 		if query != "" {
-			URI := fmt.Sprintf(scheme+"://%s%s?", req.Host, path, query)
+			URI := fmt.Sprintf(scheme+"://%s%s?%s", host, path, query)
 			URL, _ = url.Parse(URI)
 		} else {
-			URI := fmt.Sprintf(scheme+"://%s%s", req.Host, path)
+			URI := fmt.Sprintf(scheme+"://%s%s", host, path)
 			URL, _ = url.Parse(URI)
 		}
 	} else {
-		URI := fmt.Sprintf(scheme+"://%s/", req.Host)
+		URI := fmt.Sprintf(scheme+"://%s/", host)
 		URL, _ = url.Parse(URI)
 	}
 
@@ -766,11 +892,20 @@ func (s *clientStream) AppendHeaders(ctx context.Context, headersIn types.Header
 
 	if endStream {
 		s.endStream()
+	} else if str.StreamBodyEnabled(ctx) {
+		s.streamSend = true
+		s.sendHeaders(ctx)
 	}
 	return nil
 }
 
 func (s *clientStream) AppendData(context context.Context, data types.IoBuffer, endStream bool) error {
+	if s.streamSend {
+		log.Proxy.Debugf(s.ctx, "http2 client stream data: id = %d, len = %d", s.id, data.Len())
+		s.sendStreaming(s.h2s.WriteData(data.Bytes(), endStream))
+		return nil
+	}
+
 	s.h2s.SendData = data
 	log.Proxy.Debugf(s.ctx, "http2 client AppendData: id = %d", s.id)
 	if endStream {
@@ -791,11 +926,42 @@ func (s *clientStream) AppendTrailers(context context.Context, trailers types.He
 		return errors.New("trailers type error")
 	}
 	log.Proxy.Debugf(s.ctx, "http2 client AppendTrailers: id = %d, trailers = %+v", s.id, s.h2s.Request.Trailer)
+	if s.streamSend {
+		s.sendStreaming(s.h2s.WriteTrailers())
+		return nil
+	}
 	s.endStream()
 
 	return nil
 }
 
+// sendHeaders sends the headers of the streamed request, the response may arrive before the request ends
+func (s *clientStream) sendHeaders(ctx context.Context) {
+	s.sc.mutex.Lock()
+	defer s.sc.mutex.Unlock()
+
+	if err := s.h2s.WriteHeaders(ctx, false); err != nil {
+		s.sendStreaming(err)
+		return
+	}
+	s.id = s.h2s.GetID()
+	s.sc.streams[s.id] = s
+
+	log.Proxy.Debugf(s.ctx, "http2 client stream headers id = %d", s.id)
+}
+
+// sendStreaming handles the result of sending a part of the streamed request
+func (s *clientStream) sendStreaming(err error) {
+	if err != nil {
+		log.Proxy.Errorf(s.ctx, "http2 client stream send error = %v", err)
+		if err == types.ErrConnectionHasClosed {
+			s.ResetStream(types.StreamConnectionFailed)
+		} else {
+			s.ResetStream(types.StreamLocalReset)
+		}
+	}
+}
+
 func (s *clientStream) endStream() {
 	s.sc.mutex.Lock()
 	defer s.sc.mutex.Unlock()
@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"context"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// RoutingPriority returns the routing priority in the context, the default priority is returned if not setted
+func RoutingPriority(ctx context.Context) types.ResourcePriority {
+	if priority, ok := mosnctx.Get(ctx, types.ContextKeyRoutingPriority).(types.ResourcePriority); ok {
+		return priority
+	}
+	return types.DefaultPriority
+}
+
+// RequestResource is the request resource used by an upstream stream, a request takes both the cluster's
+// request resource of the routing priority and the host's request resource.
+// RequestResource implements types.StreamEventListener, the resource is released when the stream is destroyed
+type RequestResource struct {
+	cluster types.Resource
+	host    types.Resource
+}
+
+// NewRequestResource returns the request resource of the host with the routing priority in the context
+func NewRequestResource(ctx context.Context, host types.Host) *RequestResource {
+	return &RequestResource{
+		cluster: host.ClusterInfo().PriorityResourceManager(RoutingPriority(ctx)).Requests(),
+		host:    host.ResourceManager().Requests(),
+	}
+}
+
+// CanCreate returns true if both the cluster and the host have remaining capacity
+func (r *RequestResource) CanCreate() bool {
+	return r.cluster.CanCreate() && r.host.CanCreate()
+}
+
+func (r *RequestResource) Increase() {
+	r.cluster.Increase()
+	r.host.Increase()
+}
+
+func (r *RequestResource) Decrease() {
+	r.cluster.Decrease()
+	r.host.Decrease()
+}
+
+// types.StreamEventListener
+func (r *RequestResource) OnResetStream(reason types.StreamResetReason) {}
+
+func (r *RequestResource) OnDestroyStream() {
+	r.Decrease()
+}
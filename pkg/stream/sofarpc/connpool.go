@@ -132,7 +132,8 @@ func (p *connPool) NewStream(ctx context.Context,
 		return
 	}
 
-	if !p.host.ClusterInfo().ResourceManager().Requests().CanCreate() {
+	requests := str.NewRequestResource(ctx, p.host)
+	if !requests.CanCreate() {
 		listener.OnFailure(types.Overflow, p.host)
 		p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
@@ -148,10 +149,11 @@ func (p *connPool) NewStream(ctx context.Context,
 		} else {
 			streamEncoder = activeClient.client.NewStream(ctx, responseDecoder)
 			streamEncoder.GetStream().AddEventListener(activeClient)
+			streamEncoder.GetStream().AddEventListener(requests)
 
 			p.host.HostStats().UpstreamRequestActive.Inc(1)
 			p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
-			p.host.ClusterInfo().ResourceManager().Requests().Increase()
+			requests.Increase()
 		}
 
 		listener.OnReady(streamEncoder, p.host)
@@ -231,7 +233,6 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 func (p *connPool) onStreamDestroy(client *activeClient) {
 	p.host.HostStats().UpstreamRequestActive.Dec(1)
 	p.host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
-	p.host.ClusterInfo().ResourceManager().Requests().Decrease()
 }
 
 func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetReason) {
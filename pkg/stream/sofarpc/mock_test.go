@@ -22,6 +22,7 @@ import (
 	"net"
 	"time"
 
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/buffer"
 	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
 	"sofastack.io/sofa-mosn/pkg/types"
@@ -141,3 +142,11 @@ func (ci *mockClusterInfo) ConnBufferLimitBytes() uint32 {
 func (ci *mockClusterInfo) SourceAddress() net.Addr {
 	return nil
 }
+
+func (ci *mockClusterInfo) PerHostThresholds() v2.Thresholds {
+	return v2.Thresholds{}
+}
+
+func (ci *mockClusterInfo) ProxyProtocolVersion() int {
+	return 0
+}
@@ -79,7 +79,29 @@ func (f *streamConnFactory) CreateBiDirectStream(context context.Context, connec
 	return newStreamConnection(context, connection, clientCallbacks, serverCallbacks)
 }
 
+// ProtocolMatch matches the bolt protocol code, and the request type of bolt v1 or the version of bolt v2
 func (f *streamConnFactory) ProtocolMatch(context context.Context, prot string, magic []byte) error {
+	if len(magic) == 0 {
+		return str.EAGAIN
+	}
+	switch magic[0] {
+	case sofarpc.PROTOCOL_CODE_V1:
+		if len(magic) < 2 {
+			return str.EAGAIN
+		}
+		switch magic[1] {
+		case sofarpc.REQUEST, sofarpc.REQUEST_ONEWAY, sofarpc.RESPONSE:
+			return nil
+		}
+	case sofarpc.PROTOCOL_CODE_V2:
+		if len(magic) < 2 {
+			return str.EAGAIN
+		}
+		switch magic[1] {
+		case sofarpc.PROTOCOL_VERSION_1, sofarpc.PROTOCOL_VERSION_2:
+			return nil
+		}
+	}
 	return str.FAILED
 }
 
@@ -0,0 +1,46 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sofarpc
+
+import (
+	"context"
+	"testing"
+
+	str "sofastack.io/sofa-mosn/pkg/stream"
+)
+
+func TestProtocolMatch(t *testing.T) {
+	factory := &streamConnFactory{}
+	testCases := []struct {
+		magic []byte
+		err   error
+	}{
+		{[]byte{1, 1, 0, 1}, nil},
+		{[]byte{1, 0}, nil},
+		{[]byte{2, 1, 1}, nil},
+		{[]byte{}, str.EAGAIN},
+		{[]byte{1}, str.EAGAIN},
+		{[]byte{1, 9}, str.FAILED},
+		{[]byte("GET /"), str.FAILED},
+	}
+	for _, tc := range testCases {
+		if err := factory.ProtocolMatch(context.Background(), "", tc.magic); err != tc.err {
+			t.Errorf("magic %v expected %v, got %v", tc.magic, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package stream
+
+import (
+	"context"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// StreamBodyEnabled returns true if the bodies are streamed instead of fully buffered in the context
+func StreamBodyEnabled(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	enabled, _ := mosnctx.Get(ctx, types.ContextKeyStreamBody).(bool)
+	return enabled
+}
+
+// StreamingReceiver returns the receiver as a streaming receiver if the body streaming is enabled
+// in the context and the receiver supports it
+func StreamingReceiver(ctx context.Context, receiver types.StreamReceiveListener) (types.StreamingReceiveListener, bool) {
+	if receiver == nil || !StreamBodyEnabled(ctx) {
+		return nil, false
+	}
+	listener, ok := receiver.(types.StreamingReceiveListener)
+	return listener, ok
+}
@@ -85,7 +85,8 @@ func (p *connPool) NewStream(context context.Context, responseDecoder types.Stre
 		return
 	}
 
-	if !p.host.ClusterInfo().ResourceManager().Requests().CanCreate() {
+	requests := str.NewRequestResource(context, p.host)
+	if !requests.CanCreate() {
 		listener.OnFailure(types.Overflow, p.host)
 		p.host.HostStats().UpstreamRequestPendingOverflow.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestPendingOverflow.Inc(1)
@@ -95,10 +96,11 @@ func (p *connPool) NewStream(context context.Context, responseDecoder types.Stre
 		p.host.HostStats().UpstreamRequestActive.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestTotal.Inc(1)
 		p.host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
-		p.host.ClusterInfo().ResourceManager().Requests().Increase()
+		requests.Increase()
 		log.DefaultLogger.Tracef("xprotocol conn pool codec client new stream")
 		streamSender := activeClient.client.NewStream(context, responseDecoder)
 		streamSender.GetStream().AddEventListener(activeClient)
+		streamSender.GetStream().AddEventListener(requests)
 
 		log.DefaultLogger.Tracef("xprotocol conn pool codec client new stream success,invoked OnPoolReady")
 		listener.OnReady(streamSender, p.host)
@@ -153,7 +155,6 @@ func (p *connPool) onConnectionEvent(client *activeClient, event types.Connectio
 func (p *connPool) onStreamDestroy(client *activeClient) {
 	p.host.HostStats().UpstreamRequestActive.Dec(1)
 	p.host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
-	p.host.ClusterInfo().ResourceManager().Requests().Decrease()
 }
 
 func (p *connPool) onStreamReset(client *activeClient, reason types.StreamResetReason) {
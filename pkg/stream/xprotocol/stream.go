@@ -71,9 +71,17 @@ func (f *streamConnFactory) CreateBiDirectStream(context context.Context, connec
 	return newStreamConnection(context, connection, clientCallbacks, serverCallbacks)
 }
 
+// ProtocolMatch tries the registered sub protocols, the matched sub protocol is set in the context,
+// which is the connection context used to create the server stream connection
 func (f *streamConnFactory) ProtocolMatch(context context.Context, prot string, magic []byte) error {
-	// set sub protocol
-	// mosnctx.WithValue(context, types.ContextSubProtocol, SubProtocol)
+	subProtocol, result := xprotocol.MatchSubProtocol(context, magic)
+	switch result {
+	case xprotocol.MatchSuccess:
+		mosnctx.WithValue(context, types.ContextSubProtocol, string(subProtocol))
+		return nil
+	case xprotocol.MatchAgain:
+		return str.EAGAIN
+	}
 	return str.FAILED
 }
 
@@ -126,13 +134,27 @@ func (conn *streamConnection) Dispatch(buffer types.IoBuffer) {
 		log.DefaultLogger.Tracef("before Dispatch on decode header")
 
 		requestLen := len(request)
+		// heartbeat is handled natively, the response is written directly
+		heartbeatCodec, ok := conn.codec.(xprotocol.Heartbeat)
+		if ok && heartbeatCodec.IsHeartbeat(request) {
+			response := heartbeatCodec.HeartbeatResponse(request)
+			if response != nil {
+				conn.connection.Write(networkbuffer.NewIoBufferBytes(response))
+			}
+			log.DefaultLogger.Tracef("xprotocol handle heartbeat, response = %v", response)
+			buffer.Drain(requestLen)
+			continue
+		}
+
 		// ProtocolConvertor
 		// convertor first
 		convertorCodec, ok := conn.codec.(xprotocol.ProtocolConvertor)
 		if ok {
 			newHeaders, newData := convertorCodec.Convert(request)
 			request = newData
-			headers = newHeaders
+			for k, v := range newHeaders {
+				headers[k] = v
+			}
 		}
 
 		// get stream id
@@ -210,7 +232,7 @@ func (conn *streamConnection) OnReceive(context context.Context, streamID string
 	log.DefaultLogger.Tracef("xprotocol stream on decode header")
 	if conn.serverStreamConnectionEventListener != nil {
 		log.DefaultLogger.Tracef("xprotocol stream on new stream detected invoked")
-		conn.onNewStreamDetected(streamID, headers)
+		conn.onNewStreamDetected(streamID, headers, data)
 	}
 	if stream, ok := conn.activeStream.Get(streamID); ok {
 		log.DefaultLogger.Tracef("xprotocol stream on decode header and data")
@@ -224,7 +246,7 @@ func (conn *streamConnection) OnReceive(context context.Context, streamID string
 	return types.Stop
 }
 
-func (conn *streamConnection) onNewStreamDetected(streamID string, headers types.HeaderMap) {
+func (conn *streamConnection) onNewStreamDetected(streamID string, headers types.HeaderMap, data types.IoBuffer) {
 	if ok := conn.activeStream.Has(streamID); ok {
 		return
 	}
@@ -234,6 +256,10 @@ func (conn *streamConnection) onNewStreamDetected(streamID string, headers types
 		direction:  ServerStream,
 		connection: conn,
 	}
+	// keep the request for the hijack reply, the data is reused by the connection buffer
+	if _, ok := conn.codec.(xprotocol.Hijacker); ok && data != nil {
+		stream.request = append([]byte(nil), data.Bytes()...)
+	}
 
 	stream.streamReceiver = conn.serverStreamConnectionEventListener.NewStreamDetect(conn.context, &stream, nil)
 	conn.activeStream.Set(streamID, stream)
@@ -253,6 +279,7 @@ type stream struct {
 	streamReceiver   types.StreamReceiveListener
 	encodedHeaders   types.IoBuffer
 	encodedData      types.IoBuffer
+	request          []byte
 }
 
 // AddEventListener add stream event callback
@@ -276,6 +303,17 @@ func (s *stream) BufferLimit() uint32 {
 // types.StreamEncoder
 func (s *stream) AppendHeaders(context context.Context, headers types.HeaderMap, endStream bool) error {
 	log.DefaultLogger.Tracef("EncodeHeaders,request id = %s, direction = %d", s.streamID, s.direction)
+	// hijack reply, the sub protocol builds the error response of the request
+	if s.direction == ServerStream {
+		if status, ok := headers.Get(types.HeaderStatus); ok {
+			if hijacker, ok := s.connection.codec.(xprotocol.Hijacker); ok {
+				code, _ := strconv.Atoi(status)
+				if response := hijacker.HijackResponse(s.request, code); response != nil {
+					s.encodedData = networkbuffer.NewIoBufferBytes(response)
+				}
+			}
+		}
+	}
 	if endStream {
 		s.endStream()
 	}
@@ -0,0 +1,57 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package xprotocol
+
+import (
+	"context"
+	"testing"
+
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	_ "sofastack.io/sofa-mosn/pkg/protocol/rpc/xprotocol/thrift"
+	str "sofastack.io/sofa-mosn/pkg/stream"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestProtocolMatch(t *testing.T) {
+	factory := &streamConnFactory{}
+	testCases := []struct {
+		name        string
+		magic       []byte
+		err         error
+		subProtocol string
+	}{
+		{"dubbo", []byte{0xda, 0xbb, 0xc2, 0x00}, nil, "dubbo"},
+		{"thrift binary", []byte{0, 0, 0, 20, 0x80, 0x01, 0, 1}, nil, "thrift"},
+		{"thrift compact", []byte{0, 0, 0, 20, 0x82, 0x21, 1}, nil, "thrift"},
+		{"thrift header", []byte{0, 0, 0, 20, 0x0f, 0xff, 0, 0}, nil, "thrift"},
+		{"partial dubbo", []byte{0xda}, str.EAGAIN, ""},
+		{"partial frame length", []byte{0, 0, 0}, str.EAGAIN, ""},
+		{"http", []byte("GET / HTTP/1.1\r\n"), str.FAILED, ""},
+		{"unknown frame", []byte{0, 0, 0, 20, 0x7f, 0x7f}, str.FAILED, ""},
+	}
+	for _, tc := range testCases {
+		ctx := mosnctx.WithValue(context.Background(), types.ContextSubProtocol, "")
+		if err := factory.ProtocolMatch(ctx, "", tc.magic); err != tc.err {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.err, err)
+			continue
+		}
+		if subProtocol := mosnctx.Get(ctx, types.ContextSubProtocol).(string); subProtocol != tc.subProtocol {
+			t.Errorf("%s: expected sub protocol %s, got %s", tc.name, tc.subProtocol, subProtocol)
+		}
+	}
+}
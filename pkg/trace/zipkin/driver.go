@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/trace"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// DriverName is the tracing driver name used in the tracing config
+const DriverName = "Zipkin"
+
+const (
+	defaultServiceName   = "mosn"
+	defaultBatchSize     = 100
+	defaultQueueSize     = 1024
+	defaultFlushInterval = time.Second
+)
+
+var (
+	ErrNoCollector       = errors.New("zipkin collector_url is required")
+	ErrInvalidSampleRate = errors.New("zipkin sample_rate should be in [0, 1]")
+	ErrInvalidFormat     = errors.New("zipkin propagation should be one of b3, b3-single, w3c")
+)
+
+// Config is the config of the zipkin driver, it is the `config` of the tracing config
+type Config struct {
+	CollectorURL string `json:"collector_url"`
+	ServiceName  string `json:"service_name"`
+	// SampleRate is the probability a new trace is sampled, defaults to 1
+	SampleRate *float64 `json:"sample_rate"`
+	// MaxTracesPerSecond limits the new traces sampled per second, 0 means no limit
+	MaxTracesPerSecond float64 `json:"max_traces_per_second"`
+	// Propagation is the header format used for traces started by mosn
+	Propagation   string `json:"propagation"`
+	TraceID64Bit  bool   `json:"trace_id_64bit"`
+	BatchSize     int    `json:"batch_size"`
+	QueueSize     int    `json:"queue_size"`
+	FlushInterval string `json:"flush_interval"`
+
+	flushInterval time.Duration
+}
+
+// ParseConfig parses the driver config and fills the default values
+func ParseConfig(config map[string]interface{}) (*Config, error) {
+	cfg := &Config{}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.CollectorURL == "" {
+		return nil, ErrNoCollector
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = defaultServiceName
+	}
+	if cfg.SampleRate == nil {
+		rate := 1.0
+		cfg.SampleRate = &rate
+	} else if *cfg.SampleRate < 0 || *cfg.SampleRate > 1 {
+		return nil, ErrInvalidSampleRate
+	}
+	switch cfg.Propagation {
+	case "":
+		cfg.Propagation = FormatB3
+	case FormatB3, FormatB3Single, FormatW3C:
+	default:
+		return nil, ErrInvalidFormat
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	cfg.flushInterval = defaultFlushInterval
+	if cfg.FlushInterval != "" {
+		interval, err := time.ParseDuration(cfg.FlushInterval)
+		if err != nil {
+			return nil, err
+		}
+		if interval > 0 {
+			cfg.flushInterval = interval
+		}
+	}
+	return cfg, nil
+}
+
+// driver shares one sampler and one reporter between the tracers of all protocols
+type driver struct {
+	types.Driver
+
+	mutex    sync.RWMutex
+	config   *Config
+	sampler  Sampler
+	reporter *Reporter
+}
+
+func (d *driver) Init(config map[string]interface{}) error {
+	cfg, err := ParseConfig(config)
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	old := d.reporter
+	d.config = cfg
+	d.sampler = NewSampler(*cfg.SampleRate, cfg.MaxTracesPerSecond)
+	d.reporter = NewReporter(cfg)
+	d.mutex.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return d.Driver.Init(config)
+}
+
+func (d *driver) get() (*Config, Sampler, *Reporter) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.config, d.sampler, d.reporter
+}
+
+var zipkinDriver = &driver{
+	Driver: trace.NewDefaultDriverImpl(),
+}
@@ -0,0 +1,293 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"strings"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// header formats of the trace context
+const (
+	FormatB3       = "b3"
+	FormatB3Single = "b3-single"
+	FormatW3C      = "w3c"
+)
+
+// B3 and W3C trace context headers
+const (
+	headerB3TraceID      = "x-b3-traceid"
+	headerB3SpanID       = "x-b3-spanid"
+	headerB3ParentSpanID = "x-b3-parentspanid"
+	headerB3Sampled      = "x-b3-sampled"
+	headerB3Flags        = "x-b3-flags"
+	headerB3Single       = "b3"
+	headerTraceParent    = "traceparent"
+	headerTraceState     = "tracestate"
+)
+
+// canonical spellings, tried for header maps that are case sensitive, such as sofarpc
+var canonicalHeaders = map[string]string{
+	headerB3TraceID:      "X-B3-TraceId",
+	headerB3SpanID:       "X-B3-SpanId",
+	headerB3ParentSpanID: "X-B3-ParentSpanId",
+	headerB3Sampled:      "X-B3-Sampled",
+	headerB3Flags:        "X-B3-Flags",
+}
+
+type samplingState uint8
+
+const (
+	samplingUnknown samplingState = iota
+	samplingAccept
+	samplingDeny
+	samplingDebug
+)
+
+// SpanContext is the trace context carried by the request headers
+type SpanContext struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	// TraceState is the W3C vendor specific state, passed through unchanged
+	TraceState string
+	Format     string
+
+	sampling samplingState
+}
+
+func getHeader(headers types.HeaderMap, key string) (string, bool) {
+	if value, ok := headers.Get(key); ok && value != "" {
+		return value, true
+	}
+	if canonical, ok := canonicalHeaders[key]; ok {
+		if value, ok := headers.Get(canonical); ok && value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Extract reads the trace context from the headers, W3C traceparent is preferred
+// over B3 single header, which is preferred over B3 multi headers.
+// A context with an empty TraceID carries the sampling decision only.
+func Extract(headers types.HeaderMap) (SpanContext, bool) {
+	if headers == nil {
+		return SpanContext{}, false
+	}
+	if value, ok := getHeader(headers, headerTraceParent); ok {
+		if sc, ok := parseTraceParent(value); ok {
+			sc.TraceState, _ = getHeader(headers, headerTraceState)
+			return sc, true
+		}
+	}
+	if value, ok := getHeader(headers, headerB3Single); ok {
+		if sc, ok := parseB3Single(value); ok {
+			return sc, true
+		}
+	}
+	return extractB3(headers)
+}
+
+func extractB3(headers types.HeaderMap) (SpanContext, bool) {
+	sc := SpanContext{Format: FormatB3}
+	if flags, ok := getHeader(headers, headerB3Flags); ok && flags == "1" {
+		sc.sampling = samplingDebug
+	} else if sampled, ok := getHeader(headers, headerB3Sampled); ok {
+		switch strings.ToLower(sampled) {
+		case "1", "true":
+			sc.sampling = samplingAccept
+		case "0", "false":
+			sc.sampling = samplingDeny
+		}
+	}
+
+	traceID, hasTrace := getHeader(headers, headerB3TraceID)
+	spanID, hasSpan := getHeader(headers, headerB3SpanID)
+	if !hasTrace || !hasSpan {
+		// sampling only
+		return sc, sc.sampling != samplingUnknown
+	}
+	if !validTraceID(traceID) || !validSpanID(spanID) {
+		return SpanContext{}, false
+	}
+	sc.TraceID = strings.ToLower(traceID)
+	sc.SpanID = strings.ToLower(spanID)
+	if parentID, ok := getHeader(headers, headerB3ParentSpanID); ok && validSpanID(parentID) {
+		sc.ParentID = strings.ToLower(parentID)
+	}
+	return sc, true
+}
+
+// parseB3Single parses {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId},
+// the last two parts are optional, or a single sampling state
+func parseB3Single(value string) (SpanContext, bool) {
+	sc := SpanContext{Format: FormatB3Single}
+	parts := strings.Split(strings.ToLower(value), "-")
+	if len(parts) == 1 {
+		state, ok := parseB3SamplingState(parts[0])
+		sc.sampling = state
+		return sc, ok
+	}
+	if len(parts) > 4 || !validTraceID(parts[0]) || !validSpanID(parts[1]) {
+		return SpanContext{}, false
+	}
+	sc.TraceID = parts[0]
+	sc.SpanID = parts[1]
+	if len(parts) > 2 {
+		state, ok := parseB3SamplingState(parts[2])
+		if !ok {
+			return SpanContext{}, false
+		}
+		sc.sampling = state
+	}
+	if len(parts) > 3 {
+		if !validSpanID(parts[3]) {
+			return SpanContext{}, false
+		}
+		sc.ParentID = parts[3]
+	}
+	return sc, true
+}
+
+func parseB3SamplingState(value string) (samplingState, bool) {
+	switch value {
+	case "1":
+		return samplingAccept, true
+	case "0":
+		return samplingDeny, true
+	case "d":
+		return samplingDebug, true
+	}
+	return samplingUnknown, false
+}
+
+// parseTraceParent parses {version}-{trace-id}-{parent-id}-{trace-flags}
+func parseTraceParent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(value)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || !isHex(parts[0]) {
+		return SpanContext{}, false
+	}
+	// version 00 has exactly 4 parts, future versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	if len(parts[1]) != 32 || !validTraceID(parts[1]) || !validSpanID(parts[2]) || len(parts[3]) != 2 || !isHex(parts[3]) {
+		return SpanContext{}, false
+	}
+	sc := SpanContext{
+		TraceID:  parts[1],
+		SpanID:   parts[2],
+		Format:   FormatW3C,
+		sampling: samplingDeny,
+	}
+	if flags := parts[3]; (hexValue(flags[1]) & 0x1) == 1 {
+		sc.sampling = samplingAccept
+	}
+	return sc, true
+}
+
+// Inject writes the trace context to the headers in the given format
+func Inject(sc SpanContext, format string, headers types.HeaderMap) {
+	if headers == nil || sc.TraceID == "" {
+		return
+	}
+	switch format {
+	case FormatW3C:
+		flags := "00"
+		if sc.sampling == samplingAccept || sc.sampling == samplingDebug {
+			flags = "01"
+		}
+		headers.Set(headerTraceParent, "00-"+padTraceID(sc.TraceID)+"-"+sc.SpanID+"-"+flags)
+		if sc.TraceState != "" {
+			headers.Set(headerTraceState, sc.TraceState)
+		}
+	case FormatB3Single:
+		value := sc.TraceID + "-" + sc.SpanID
+		switch sc.sampling {
+		case samplingAccept:
+			value += "-1"
+		case samplingDeny:
+			value += "-0"
+		case samplingDebug:
+			value += "-d"
+		}
+		if sc.ParentID != "" && sc.sampling != samplingUnknown {
+			value += "-" + sc.ParentID
+		}
+		headers.Set(headerB3Single, value)
+	default:
+		headers.Set(headerB3TraceID, sc.TraceID)
+		headers.Set(headerB3SpanID, sc.SpanID)
+		if sc.ParentID != "" {
+			headers.Set(headerB3ParentSpanID, sc.ParentID)
+		} else {
+			headers.Del(headerB3ParentSpanID)
+		}
+		switch sc.sampling {
+		case samplingAccept:
+			headers.Set(headerB3Sampled, "1")
+		case samplingDeny:
+			headers.Set(headerB3Sampled, "0")
+		case samplingDebug:
+			headers.Set(headerB3Flags, "1")
+		}
+	}
+}
+
+// padTraceID left pads a 64 bits trace id to the 128 bits W3C requires
+func padTraceID(traceID string) string {
+	if len(traceID) == 16 {
+		return "0000000000000000" + traceID
+	}
+	return traceID
+}
+
+func validTraceID(id string) bool {
+	return (len(id) == 16 || len(id) == 32) && isHex(id) && !isZero(id)
+}
+
+func validSpanID(id string) bool {
+	return len(id) == 16 && isHex(id) && !isZero(id)
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if hexValue(s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+func hexValue(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
@@ -0,0 +1,164 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/protocol"
+)
+
+const (
+	testTraceID  = "463ac35c9f6413ad48485a3953bb6124"
+	testSpanID   = "a2fb4a1d1a96d312"
+	testParentID = "0020000000000001"
+)
+
+func TestExtractB3(t *testing.T) {
+	headers := protocol.CommonHeader{
+		"x-b3-traceid":      testTraceID,
+		"x-b3-spanid":       testSpanID,
+		"x-b3-parentspanid": testParentID,
+		"x-b3-sampled":      "1",
+	}
+	sc, ok := Extract(headers)
+	if !ok || sc.TraceID != testTraceID || sc.SpanID != testSpanID || sc.ParentID != testParentID ||
+		sc.sampling != samplingAccept || sc.Format != FormatB3 {
+		t.Errorf("extract b3 failed: %+v, %v", sc, ok)
+	}
+
+	// case sensitive header maps with canonical names
+	headers = protocol.CommonHeader{
+		"X-B3-TraceId": testTraceID[16:],
+		"X-B3-SpanId":  testSpanID,
+		"X-B3-Flags":   "1",
+	}
+	sc, ok = Extract(headers)
+	if !ok || sc.TraceID != testTraceID[16:] || sc.sampling != samplingDebug {
+		t.Errorf("extract canonical b3 failed: %+v, %v", sc, ok)
+	}
+
+	// sampling decision only
+	sc, ok = Extract(protocol.CommonHeader{"x-b3-sampled": "0"})
+	if !ok || sc.TraceID != "" || sc.sampling != samplingDeny {
+		t.Errorf("extract b3 sampling failed: %+v, %v", sc, ok)
+	}
+
+	// invalid ids
+	for _, id := range []string{"", "123", "0000000000000000", "zzzzzzzzzzzzzzzz"} {
+		if _, ok := Extract(protocol.CommonHeader{"x-b3-traceid": testTraceID, "x-b3-spanid": id}); ok {
+			t.Errorf("span id %q should be invalid", id)
+		}
+	}
+}
+
+func TestExtractB3Single(t *testing.T) {
+	testCases := []struct {
+		value    string
+		ok       bool
+		traceID  string
+		parentID string
+		sampling samplingState
+	}{
+		{testTraceID + "-" + testSpanID, true, testTraceID, "", samplingUnknown},
+		{testTraceID + "-" + testSpanID + "-1", true, testTraceID, "", samplingAccept},
+		{testTraceID + "-" + testSpanID + "-d-" + testParentID, true, testTraceID, testParentID, samplingDebug},
+		{"0", true, "", "", samplingDeny},
+		{"x", false, "", "", samplingUnknown},
+		{testTraceID + "-" + testSpanID + "-2", false, "", "", samplingUnknown},
+		{testTraceID + "-" + testSpanID + "-1-" + testParentID + "-1", false, "", "", samplingUnknown},
+	}
+	for i, tc := range testCases {
+		sc, ok := Extract(protocol.CommonHeader{"b3": tc.value})
+		if ok != tc.ok || sc.TraceID != tc.traceID || sc.ParentID != tc.parentID || sc.sampling != tc.sampling {
+			t.Errorf("#%d extract %q got %+v, %v", i, tc.value, sc, ok)
+		}
+	}
+}
+
+func TestExtractW3C(t *testing.T) {
+	headers := protocol.CommonHeader{
+		"traceparent": "00-" + testTraceID + "-" + testSpanID + "-01",
+		"tracestate":  "congo=t61rcWkgMzE",
+		// traceparent wins
+		"b3": "0",
+	}
+	sc, ok := Extract(headers)
+	if !ok || sc.TraceID != testTraceID || sc.SpanID != testSpanID || sc.TraceState != "congo=t61rcWkgMzE" ||
+		sc.sampling != samplingAccept || sc.Format != FormatW3C {
+		t.Errorf("extract w3c failed: %+v, %v", sc, ok)
+	}
+
+	for _, value := range []string{
+		"ff-" + testTraceID + "-" + testSpanID + "-01",
+		"00-" + testTraceID + "-" + testSpanID + "-01-00",
+		"00-" + testTraceID[16:] + "-" + testSpanID + "-01",
+		"00-00000000000000000000000000000000-" + testSpanID + "-01",
+		"00-" + testTraceID + "-" + testSpanID + "-0x",
+	} {
+		if _, ok := Extract(protocol.CommonHeader{"traceparent": value}); ok {
+			t.Errorf("traceparent %q should be invalid", value)
+		}
+	}
+
+	// future versions may have more fields
+	sc, ok = Extract(protocol.CommonHeader{"traceparent": "01-" + testTraceID + "-" + testSpanID + "-00-extra"})
+	if !ok || sc.sampling != samplingDeny {
+		t.Errorf("extract future traceparent failed: %+v, %v", sc, ok)
+	}
+}
+
+func TestInject(t *testing.T) {
+	sc := SpanContext{
+		TraceID:    testTraceID[16:],
+		SpanID:     testSpanID,
+		ParentID:   testParentID,
+		TraceState: "a=b",
+		sampling:   samplingAccept,
+	}
+
+	headers := protocol.CommonHeader{}
+	Inject(sc, FormatB3, headers)
+	if headers["x-b3-traceid"] != testTraceID[16:] || headers["x-b3-spanid"] != testSpanID ||
+		headers["x-b3-parentspanid"] != testParentID || headers["x-b3-sampled"] != "1" {
+		t.Errorf("inject b3 failed: %v", headers)
+	}
+
+	headers = protocol.CommonHeader{}
+	Inject(sc, FormatB3Single, headers)
+	if headers["b3"] != testTraceID[16:]+"-"+testSpanID+"-1-"+testParentID {
+		t.Errorf("inject b3 single failed: %v", headers)
+	}
+
+	headers = protocol.CommonHeader{}
+	Inject(sc, FormatW3C, headers)
+	if headers["traceparent"] != "00-0000000000000000"+testTraceID[16:]+"-"+testSpanID+"-01" || headers["tracestate"] != "a=b" {
+		t.Errorf("inject w3c failed: %v", headers)
+	}
+
+	// extract what is injected
+	for _, format := range []string{FormatB3, FormatB3Single, FormatW3C} {
+		sc := SpanContext{TraceID: testTraceID, SpanID: testSpanID, sampling: samplingDeny}
+		headers := protocol.CommonHeader{}
+		Inject(sc, format, headers)
+		got, ok := Extract(headers)
+		if !ok || got.TraceID != sc.TraceID || got.SpanID != sc.SpanID || got.sampling != samplingDeny || got.Format != format {
+			t.Errorf("%s round trip failed: %+v, %v", format, got, ok)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+const reportTimeout = 5 * time.Second
+
+// Reporter batches the finished spans and posts them to the zipkin collector
+// in v2 json, a batch is sent once it is full or the flush interval elapsed
+type Reporter struct {
+	url       string
+	client    *http.Client
+	batchSize int
+	interval  time.Duration
+
+	spans     chan *spanModel
+	quit      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewReporter(config *Config) *Reporter {
+	r := &Reporter{
+		url:       config.CollectorURL,
+		client:    &http.Client{Timeout: reportTimeout},
+		batchSize: config.BatchSize,
+		interval:  config.flushInterval,
+		spans:     make(chan *spanModel, config.QueueSize),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	utils.GoWithRecover(r.run, nil)
+	return r
+}
+
+// Report queues the span, returns types.ErrChanFull if the queue is full
+func (r *Reporter) Report(span *spanModel) error {
+	select {
+	case <-r.quit:
+		return nil
+	default:
+	}
+	select {
+	case r.spans <- span:
+		return nil
+	default:
+		return types.ErrChanFull
+	}
+}
+
+// Close sends the queued spans and stops the reporter
+func (r *Reporter) Close() {
+	r.closeOnce.Do(func() {
+		close(r.quit)
+	})
+	<-r.done
+}
+
+func (r *Reporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	batch := make([]*spanModel, 0, r.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := r.send(batch); err != nil {
+			log.DefaultLogger.Errorf("[zipkin] report %d spans to %s failed: %v", len(batch), r.url, err)
+		}
+		batch = make([]*spanModel, 0, r.batchSize)
+	}
+
+	for {
+		select {
+		case span := <-r.spans:
+			batch = append(batch, span)
+			if len(batch) >= r.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-r.quit:
+			for {
+				select {
+				case span := <-r.spans:
+					batch = append(batch, span)
+					if len(batch) >= r.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Reporter) send(batch []*spanModel) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is a local stand-in of the zipkin collector
+type collector struct {
+	mutex   sync.Mutex
+	batches [][]map[string]interface{}
+	server  *httptest.Server
+}
+
+func newCollector(t *testing.T) *collector {
+	c := &collector{}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/spans" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected report request: %s %s %s", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		var batch []map[string]interface{}
+		if err := json.Unmarshal(body, &batch); err != nil {
+			t.Errorf("invalid report body %s: %v", body, err)
+		}
+		c.mutex.Lock()
+		c.batches = append(c.batches, batch)
+		c.mutex.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	return c
+}
+
+func (c *collector) url() string {
+	return c.server.URL + "/api/v2/spans"
+}
+
+func (c *collector) spans() []map[string]interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var spans []map[string]interface{}
+	for _, batch := range c.batches {
+		spans = append(spans, batch...)
+	}
+	return spans
+}
+
+func (c *collector) batchCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.batches)
+}
+
+func TestReporterBatch(t *testing.T) {
+	c := newCollector(t)
+	defer c.server.Close()
+
+	cfg, err := ParseConfig(map[string]interface{}{
+		"collector_url":  c.url(),
+		"batch_size":     2,
+		"flush_interval": "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReporter(cfg)
+	for i := 0; i < 5; i++ {
+		if err := r.Report(&spanModel{TraceID: testTraceID, ID: testSpanID, Duration: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// two full batches are sent without waiting for the flush interval
+	deadline := time.Now().Add(time.Second)
+	for c.batchCount() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c.batchCount() != 2 {
+		t.Fatalf("expected 2 batches, got %d", c.batchCount())
+	}
+	// close flushes the rest
+	r.Close()
+	if c.batchCount() != 3 || len(c.spans()) != 5 {
+		t.Errorf("expected 5 spans in 3 batches, got %d spans in %d batches", len(c.spans()), c.batchCount())
+	}
+	if c.spans()[0]["traceId"] != testTraceID {
+		t.Errorf("unexpected span: %v", c.spans()[0])
+	}
+	// reports after close are dropped
+	if err := r.Report(&spanModel{}); err != nil {
+		t.Errorf("report after close should be ignored, got %v", err)
+	}
+}
+
+func TestReporterFlushInterval(t *testing.T) {
+	c := newCollector(t)
+	defer c.server.Close()
+
+	cfg, _ := ParseConfig(map[string]interface{}{
+		"collector_url":  c.url(),
+		"flush_interval": "20ms",
+	})
+	r := NewReporter(cfg)
+	defer r.Close()
+	r.Report(&spanModel{TraceID: testTraceID, ID: testSpanID, Duration: 1})
+
+	deadline := time.Now().Add(time.Second)
+	for c.batchCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(c.spans()) != 1 {
+		t.Errorf("expected the span flushed by interval, got %d", len(c.spans()))
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	if _, err := ParseConfig(map[string]interface{}{}); err != ErrNoCollector {
+		t.Errorf("expected no collector error, got %v", err)
+	}
+	if _, err := ParseConfig(map[string]interface{}{"collector_url": "http://127.0.0.1", "sample_rate": 2}); err != ErrInvalidSampleRate {
+		t.Errorf("expected invalid sample rate error, got %v", err)
+	}
+	if _, err := ParseConfig(map[string]interface{}{"collector_url": "http://127.0.0.1", "propagation": "jaeger"}); err != ErrInvalidFormat {
+		t.Errorf("expected invalid format error, got %v", err)
+	}
+	cfg, err := ParseConfig(map[string]interface{}{"collector_url": "http://127.0.0.1", "sample_rate": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *cfg.SampleRate != 0 || cfg.ServiceName != defaultServiceName || cfg.Propagation != FormatB3 ||
+		cfg.BatchSize != defaultBatchSize || cfg.QueueSize != defaultQueueSize || cfg.flushInterval != defaultFlushInterval {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Sampler decides whether a new trace is sampled
+type Sampler interface {
+	Sample() bool
+}
+
+// NewSampler returns a sampler that keeps a trace with the probability rate,
+// and no more than maxPerSecond traces per second if it is positive
+func NewSampler(rate float64, maxPerSecond float64) Sampler {
+	s := &sampler{
+		rate: rate,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if maxPerSecond > 0 {
+		s.limiter = newRateLimiter(maxPerSecond)
+	}
+	return s
+}
+
+type sampler struct {
+	mutex   sync.Mutex
+	rate    float64
+	rand    *rand.Rand
+	limiter *rateLimiter
+}
+
+func (s *sampler) Sample() bool {
+	if s.rate <= 0 {
+		return false
+	}
+	if s.rate < 1 {
+		s.mutex.Lock()
+		sampled := s.rand.Float64() < s.rate
+		s.mutex.Unlock()
+		if !sampled {
+			return false
+		}
+	}
+	if s.limiter != nil {
+		return s.limiter.allow(time.Now())
+	}
+	return true
+}
+
+// rateLimiter is a token bucket holds at most one second of tokens
+type rateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{
+		rate:   rate,
+		tokens: rate,
+		last:   time.Now(),
+	}
+}
+
+func (l *rateLimiter) allow(now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > l.rate {
+			l.tokens = l.rate
+		}
+		l.last = now
+	}
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
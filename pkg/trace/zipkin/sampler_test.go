@@ -0,0 +1,74 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSamplerRate(t *testing.T) {
+	if NewSampler(0, 0).Sample() {
+		t.Error("sample rate 0 should never sample")
+	}
+	always := NewSampler(1, 0)
+	for i := 0; i < 100; i++ {
+		if !always.Sample() {
+			t.Fatal("sample rate 1 should always sample")
+		}
+	}
+	half := NewSampler(0.5, 0)
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		if half.Sample() {
+			sampled++
+		}
+	}
+	if sampled < 4000 || sampled > 6000 {
+		t.Errorf("sample rate 0.5 sampled %d of 10000", sampled)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(10)
+	now := limiter.last
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		if limiter.allow(now) {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("expected 10 allowed in a burst, got %d", allowed)
+	}
+	// 100ms refills one token
+	if !limiter.allow(now.Add(100*time.Millisecond)) || limiter.allow(now.Add(100*time.Millisecond)) {
+		t.Error("expected one token refilled")
+	}
+	// never more than one second of tokens
+	now = now.Add(time.Hour)
+	allowed = 0
+	for i := 0; i < 100; i++ {
+		if limiter.allow(now) {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("expected 10 allowed after idle, got %d", allowed)
+	}
+}
@@ -0,0 +1,247 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// span tag keys
+const (
+	PROTOCOL uint64 = iota
+	METHOD
+	PATH
+	SERVICE_NAME
+	STATUS_CODE
+	REQUEST_SIZE
+	RESPONSE_SIZE
+	UPSTREAM_HOST_ADDRESS
+	DOWNSTREAM_HOST_ADDRESS
+	ERROR
+	TAG_END
+)
+
+// tagNames are the zipkin tag names of the span tag keys
+var tagNames = [TAG_END]string{
+	PROTOCOL:                "protocol",
+	METHOD:                  "method",
+	PATH:                    "http.path",
+	SERVICE_NAME:            "rpc.service",
+	STATUS_CODE:             "status_code",
+	REQUEST_SIZE:            "request_size",
+	RESPONSE_SIZE:           "response_size",
+	UPSTREAM_HOST_ADDRESS:   "upstream.address",
+	DOWNSTREAM_HOST_ADDRESS: "downstream.address",
+	ERROR:                   "error",
+}
+
+// span kinds
+const (
+	KindClient = "CLIENT"
+	KindServer = "SERVER"
+)
+
+// Span is a zipkin span, it is reported when finished if sampled
+type Span struct {
+	context       SpanContext
+	format        string
+	kind          string
+	operationName string
+	startTime     time.Time
+	endTime       time.Time
+	tags          [TAG_END]string
+
+	local    *endpoint
+	remote   *endpoint
+	reporter *Reporter
+}
+
+func (s *Span) TraceId() string {
+	return s.context.TraceID
+}
+
+func (s *Span) SpanId() string {
+	return s.context.SpanID
+}
+
+func (s *Span) ParentSpanId() string {
+	return s.context.ParentID
+}
+
+// Sampled returns whether the span will be reported
+func (s *Span) Sampled() bool {
+	return s.context.sampling == samplingAccept || s.context.sampling == samplingDebug
+}
+
+func (s *Span) SetOperation(operation string) {
+	s.operationName = operation
+}
+
+func (s *Span) SetTag(key uint64, value string) {
+	if key < TAG_END {
+		s.tags[key] = value
+	}
+}
+
+func (s *Span) Tag(key uint64) string {
+	if key < TAG_END {
+		return s.tags[key]
+	}
+	return ""
+}
+
+func (s *Span) SetRequestInfo(reqinfo types.RequestInfo) {
+	s.tags[REQUEST_SIZE] = strconv.FormatUint(reqinfo.BytesReceived(), 10)
+	s.tags[RESPONSE_SIZE] = strconv.FormatUint(reqinfo.BytesSent(), 10)
+	code := reqinfo.ResponseCode()
+	s.tags[STATUS_CODE] = strconv.Itoa(code)
+	if code >= 500 || code == 0 {
+		s.tags[ERROR] = strconv.Itoa(code)
+	}
+	if host := reqinfo.UpstreamHost(); host != nil {
+		s.tags[UPSTREAM_HOST_ADDRESS] = host.AddressString()
+		s.remote = newEndpoint("", host.Address())
+	}
+	if addr := reqinfo.DownstreamRemoteAddress(); addr != nil {
+		s.tags[DOWNSTREAM_HOST_ADDRESS] = addr.String()
+	}
+	if addr := reqinfo.DownstreamLocalAddress(); addr != nil && s.local != nil {
+		s.local = newEndpoint(s.local.ServiceName, addr)
+	}
+}
+
+func (s *Span) FinishSpan() {
+	s.endTime = time.Now()
+	if !s.Sampled() || s.reporter == nil {
+		return
+	}
+	if err := s.reporter.Report(s.model()); err == types.ErrChanFull {
+		log.DefaultLogger.Warnf("[zipkin] report queue is full, discard span, trace id is %s, span id is %s", s.context.TraceID, s.context.SpanID)
+	}
+}
+
+// InjectContext sets the trace context of the upstream request,
+// the upstream sees this span as its parent
+func (s *Span) InjectContext(requestHeaders types.HeaderMap) {
+	Inject(s.context, s.format, requestHeaders)
+}
+
+func (s *Span) SpawnChild(operationName string, startTime time.Time) types.Span {
+	return &Span{
+		context: SpanContext{
+			TraceID:    s.context.TraceID,
+			SpanID:     newSpanID(),
+			ParentID:   s.context.SpanID,
+			TraceState: s.context.TraceState,
+			Format:     s.context.Format,
+			sampling:   s.context.sampling,
+		},
+		format:        s.format,
+		kind:          KindClient,
+		operationName: operationName,
+		startTime:     startTime,
+		local:         s.local,
+		reporter:      s.reporter,
+	}
+}
+
+func (s *Span) StartTime() time.Time {
+	return s.startTime
+}
+
+func (s *Span) EndTime() time.Time {
+	return s.endTime
+}
+
+func (s *Span) model() *spanModel {
+	m := &spanModel{
+		TraceID:        s.context.TraceID,
+		ID:             s.context.SpanID,
+		ParentID:       s.context.ParentID,
+		Name:           s.operationName,
+		Kind:           s.kind,
+		Timestamp:      s.startTime.UnixNano() / int64(time.Microsecond),
+		Duration:       int64(s.endTime.Sub(s.startTime) / time.Microsecond),
+		Debug:          s.context.sampling == samplingDebug,
+		LocalEndpoint:  s.local,
+		RemoteEndpoint: s.remote,
+	}
+	// zipkin drops spans with zero duration
+	if m.Duration < 1 {
+		m.Duration = 1
+	}
+	for key, value := range s.tags {
+		if value == "" {
+			continue
+		}
+		if m.Tags == nil {
+			m.Tags = make(map[string]string)
+		}
+		m.Tags[tagNames[key]] = value
+	}
+	return m
+}
+
+// spanModel is the zipkin v2 json span
+type spanModel struct {
+	TraceID        string            `json:"traceId"`
+	ID             string            `json:"id"`
+	ParentID       string            `json:"parentId,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Kind           string            `json:"kind,omitempty"`
+	Timestamp      int64             `json:"timestamp"`
+	Duration       int64             `json:"duration"`
+	Debug          bool              `json:"debug,omitempty"`
+	LocalEndpoint  *endpoint         `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *endpoint         `json:"remoteEndpoint,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+type endpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+	Port        int    `json:"port,omitempty"`
+}
+
+func newEndpoint(serviceName string, addr net.Addr) *endpoint {
+	ep := &endpoint{
+		ServiceName: serviceName,
+	}
+	if addr == nil {
+		return ep
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return ep
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			ep.IPv4 = ip4.String()
+		} else {
+			ep.IPv6 = ip.String()
+		}
+	}
+	ep.Port, _ = strconv.Atoi(port)
+	return ep
+}
@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/protocol/http2"
+	"sofastack.io/sofa-mosn/pkg/protocol/sofarpc/models"
+	"sofastack.io/sofa-mosn/pkg/trace"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+var (
+	idMutex sync.Mutex
+	idRand  = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func randomID(size int) string {
+	b := make([]byte, size)
+	idMutex.Lock()
+	idRand.Read(b)
+	idMutex.Unlock()
+	// all zero ids are invalid
+	b[0] |= 0x1
+	return hex.EncodeToString(b)
+}
+
+func newSpanID() string {
+	return randomID(8)
+}
+
+func newTraceID(is64Bit bool) string {
+	if is64Bit {
+		return randomID(8)
+	}
+	return randomID(16)
+}
+
+// Tracer starts a span for each downstream request, which joins the trace
+// carried by the request headers, or starts a new trace
+type Tracer struct {
+	protocol types.Protocol
+	// describe fills the span name and tags from the request
+	describe func(span *Span, request interface{})
+}
+
+func newTracerBuilder(proto types.Protocol, describe func(span *Span, request interface{})) types.TracerBuilder {
+	return func(config map[string]interface{}) (types.Tracer, error) {
+		return &Tracer{
+			protocol: proto,
+			describe: describe,
+		}, nil
+	}
+}
+
+func (t *Tracer) Start(ctx context.Context, request interface{}, startTime time.Time) types.Span {
+	config, sampler, reporter := zipkinDriver.get()
+	if config == nil {
+		return nil
+	}
+
+	span := &Span{
+		format:    config.Propagation,
+		startTime: startTime,
+		local:     &endpoint{ServiceName: config.ServiceName},
+		reporter:  reporter,
+	}
+	headers, _ := request.(types.HeaderMap)
+	parent, ok := Extract(headers)
+	if ok && parent.TraceID != "" {
+		span.context = SpanContext{
+			TraceID:    parent.TraceID,
+			ParentID:   parent.SpanID,
+			TraceState: parent.TraceState,
+			Format:     parent.Format,
+			sampling:   parent.sampling,
+		}
+		// keep the format of the downstream for the upstream
+		span.format = parent.Format
+	} else {
+		span.context = SpanContext{
+			TraceID:  newTraceID(config.TraceID64Bit),
+			Format:   config.Propagation,
+			sampling: parent.sampling,
+		}
+	}
+	span.context.SpanID = newSpanID()
+	if span.context.sampling == samplingUnknown {
+		if sampler.Sample() {
+			span.context.sampling = samplingAccept
+		} else {
+			span.context.sampling = samplingDeny
+		}
+	}
+
+	switch mosnctx.Get(ctx, types.ContextKeyListenerType) {
+	case v2.INGRESS:
+		span.kind = KindServer
+	case v2.EGRESS:
+		span.kind = KindClient
+	}
+	span.SetTag(PROTOCOL, string(t.protocol))
+	if t.describe != nil && request != nil {
+		t.describe(span, request)
+	}
+	return span
+}
+
+func describeHTTP1(span *Span, request interface{}) {
+	header, ok := request.(http.RequestHeader)
+	if !ok || header.RequestHeader == nil {
+		return
+	}
+	path := string(header.RequestURI())
+	if idx := strings.IndexByte(path, '?'); idx >= 0 {
+		path = path[:idx]
+	}
+	describeHTTP(span, string(header.Method()), path)
+}
+
+func describeHTTP2(span *Span, request interface{}) {
+	header, ok := request.(*http2.ReqHeader)
+	if !ok || header.Req == nil {
+		return
+	}
+	path := ""
+	if header.Req.URL != nil {
+		path = header.Req.URL.Path
+	}
+	describeHTTP(span, header.Req.Method, path)
+}
+
+func describeHTTP(span *Span, method, path string) {
+	span.SetTag(METHOD, method)
+	span.SetTag(PATH, path)
+	span.SetOperation(strings.TrimSpace(method + " " + path))
+}
+
+func describeSofaRPC(span *Span, request interface{}) {
+	headers, ok := request.(types.HeaderMap)
+	if !ok {
+		return
+	}
+	service, _ := headers.Get(models.SERVICE_KEY)
+	method, _ := headers.Get(models.TARGET_METHOD)
+	span.SetTag(SERVICE_NAME, service)
+	span.SetTag(METHOD, method)
+	if method != "" {
+		span.SetOperation(service + "#" + method)
+	} else {
+		span.SetOperation(service)
+	}
+}
+
+func init() {
+	trace.RegisterDriver(DriverName, zipkinDriver)
+	trace.RegisterTracerBuilder(DriverName, protocol.HTTP1, newTracerBuilder(protocol.HTTP1, describeHTTP1))
+	trace.RegisterTracerBuilder(DriverName, protocol.HTTP2, newTracerBuilder(protocol.HTTP2, describeHTTP2))
+	trace.RegisterTracerBuilder(DriverName, protocol.SofaRPC, newTracerBuilder(protocol.SofaRPC, describeSofaRPC))
+}
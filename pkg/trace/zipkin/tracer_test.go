@@ -0,0 +1,138 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zipkin
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/network"
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/pkg/protocol/http"
+	"sofastack.io/sofa-mosn/pkg/protocol/sofarpc/models"
+	"sofastack.io/sofa-mosn/pkg/trace"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestTracerJoinTrace(t *testing.T) {
+	c := newCollector(t)
+	defer c.server.Close()
+
+	if err := trace.Init(DriverName, map[string]interface{}{
+		"collector_url":  c.url(),
+		"service_name":   "test-mosn",
+		"flush_interval": "10ms",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer trace.Disable()
+
+	header := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	header.SetMethod("GET")
+	header.SetRequestURI("/hello?name=mosn")
+	header.Set("b3", testTraceID+"-"+testSpanID+"-1")
+
+	ctx := context.WithValue(context.Background(), types.ContextKeyListenerType, v2.INGRESS)
+	span := trace.Tracer(protocol.HTTP1).Start(ctx, header, time.Now())
+	if span.TraceId() != testTraceID || span.ParentSpanId() != testSpanID || span.SpanId() == testSpanID {
+		t.Fatalf("span should join the trace: %s %s %s", span.TraceId(), span.SpanId(), span.ParentSpanId())
+	}
+
+	// the upstream request carries this span as its parent in the downstream format
+	upstream := protocol.CommonHeader{}
+	span.InjectContext(upstream)
+	if upstream["b3"] != testTraceID+"-"+span.SpanId()+"-1-"+testSpanID {
+		t.Errorf("unexpected injected b3: %v", upstream)
+	}
+
+	info := network.NewRequestInfo()
+	info.SetResponseCode(200)
+	info.SetBytesReceived(10)
+	info.OnUpstreamHostSelected(nil)
+	info.SetDownstreamLocalAddress(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 2045})
+	span.SetRequestInfo(info)
+	span.FinishSpan()
+
+	deadline := time.Now().Add(time.Second)
+	for c.batchCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	spans := c.spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span reported, got %d", len(spans))
+	}
+	got := spans[0]
+	if got["traceId"] != testTraceID || got["id"] != span.SpanId() || got["parentId"] != testSpanID ||
+		got["name"] != "GET /hello" || got["kind"] != KindServer {
+		t.Errorf("unexpected span: %v", got)
+	}
+	local := got["localEndpoint"].(map[string]interface{})
+	if local["serviceName"] != "test-mosn" || local["ipv4"] != "127.0.0.1" || local["port"] != float64(2045) {
+		t.Errorf("unexpected local endpoint: %v", local)
+	}
+	tags := got["tags"].(map[string]interface{})
+	if tags["http.path"] != "/hello" || tags["status_code"] != "200" || tags["request_size"] != "10" || tags["protocol"] != string(protocol.HTTP1) {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+}
+
+func TestTracerNewTrace(t *testing.T) {
+	if err := trace.Init(DriverName, map[string]interface{}{
+		"collector_url": "http://127.0.0.1:0",
+		"propagation":   FormatW3C,
+		"sample_rate":   0,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer trace.Disable()
+
+	request := protocol.CommonHeader{
+		models.SERVICE_KEY:   "com.alipay.test.TestService:1.0",
+		models.TARGET_METHOD: "echo",
+	}
+	ctx := context.WithValue(context.Background(), types.ContextKeyListenerType, v2.EGRESS)
+	span := trace.Tracer(protocol.SofaRPC).Start(ctx, request, time.Now()).(*Span)
+	if len(span.TraceId()) != 32 || len(span.SpanId()) != 16 || span.ParentSpanId() != "" {
+		t.Fatalf("unexpected new trace: %s %s %s", span.TraceId(), span.SpanId(), span.ParentSpanId())
+	}
+	if span.Sampled() || span.kind != KindClient || span.operationName != "com.alipay.test.TestService:1.0#echo" {
+		t.Errorf("unexpected span: %+v", span)
+	}
+
+	// not sampled traces are still propagated
+	upstream := protocol.CommonHeader{}
+	span.InjectContext(upstream)
+	if upstream["traceparent"] != "00-"+span.TraceId()+"-"+span.SpanId()+"-00" {
+		t.Errorf("unexpected injected traceparent: %v", upstream)
+	}
+
+	// the downstream sampling decision is kept
+	span = trace.Tracer(protocol.HTTP2).Start(ctx, protocol.CommonHeader{"x-b3-sampled": "1"}, time.Now()).(*Span)
+	if !span.Sampled() || span.ParentSpanId() != "" {
+		t.Errorf("the sampling decision should be kept: %+v", span.context)
+	}
+
+	child := span.SpawnChild("child", time.Now())
+	if child.TraceId() != span.TraceId() || child.ParentSpanId() != span.SpanId() || child.SpanId() == span.SpanId() {
+		t.Errorf("unexpected child span: %s %s %s", child.TraceId(), child.SpanId(), child.ParentSpanId())
+	}
+}
@@ -57,6 +57,17 @@ const (
 	LogDownstreamLocalAddress     string = "DownstreamLocalAddress"
 	LogDownstreamRemoteAddress    string = "DownstreamRemoteAddress"
 	LogUpstreamHostSelectedGetter string = "UpstreamHostSelected"
+	LogRouteName                  string = "RouteName"
+	LogClusterName                string = "ClusterName"
+	LogUpstreamConnectDuration    string = "UpstreamConnectDuration"
+	LogRetryCount                 string = "RetryCount"
+	LogTraceId                    string = "TraceId"
+)
+
+// The identification of a request header's content
+const (
+	LogSofaRpcService string = "SofaRpcService"
+	LogSofaRpcMethod  string = "SofaRpcMethod"
 )
 
 const (
@@ -64,6 +75,10 @@ const (
 	ReqHeaderPrefix string = "REQ."
 	// RespHeaderPrefix is the prefix of response header's formatter
 	RespHeaderPrefix string = "RESP."
+	// ReqHeaderOperator is the operator of request header's formatter, such as "%REQ(x-foo)%"
+	ReqHeaderOperator string = "REQ("
+	// RespHeaderOperator is the operator of response header's formatter, such as "%RESP(x-foo)%"
+	RespHeaderOperator string = "RESP("
 )
 
 const (
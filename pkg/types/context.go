@@ -40,6 +40,11 @@ const (
 	ContextKeyTraceSpanKey
 	ContextKeyActiveSpan
 	ContextKeyTraceId
+	ContextKeyRoutingPriority
+	ContextKeyDownstreamRemoteAddr
+	ContextKeyDownstreamLocalAddr
+	ContextKeyGrpcWebContentType
+	ContextKeyStreamBody
 	ContextKeyEnd
 )
 
@@ -27,8 +27,13 @@ type LoadBalancerType string
 
 // The load balancer's types
 const (
-	RoundRobin LoadBalancerType = "LB_ROUNDROBIN"
-	Random     LoadBalancerType = "LB_RANDOM"
+	RoundRobin         LoadBalancerType = "LB_ROUNDROBIN"
+	Random             LoadBalancerType = "LB_RANDOM"
+	WeightedRoundRobin LoadBalancerType = "LB_WEIGHTED_ROUNDROBIN"
+	LeastActiveRequest LoadBalancerType = "LB_LEAST_REQUEST"
+	RingHash           LoadBalancerType = "LB_RING_HASH"
+	Maglev             LoadBalancerType = "LB_MAGLEV"
+	LocalityAware      LoadBalancerType = "LB_LOCALITY_AWARE"
 )
 
 // LoadBalancer is a upstream load balancer.
@@ -55,6 +60,18 @@ type LoadBalancerContext interface {
 
 	// DownstreamContext returns the downstream context
 	DownstreamContext() context.Context
+
+	// ComputeHashKey returns the hash key used by consistent hash load balancer.
+	// If no hash key is generated, returns false
+	ComputeHashKey() (uint64, bool)
+
+	// HostSelectionRetryCount returns the max attempts to choose another host
+	// if ShouldSelectAnotherHost returns true
+	HostSelectionRetryCount() uint32
+
+	// ShouldSelectAnotherHost returns true if the host chosen by the load balancer should be rejected,
+	// such as the host has been attempted by a previous retry
+	ShouldSelectAnotherHost(host Host) bool
 }
 
 // LBSubsetEntry is a entry that stored in the subset hierarchy.
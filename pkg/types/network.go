@@ -163,6 +163,9 @@ type ListenerFilterCallbacks interface {
 
 	// SetOriginalAddr sets the original ip and port
 	SetOriginalAddr(ip string, port int)
+
+	// SetRemoteAddr sets the real remote address of the connection, such as the address in PROXY protocol header
+	SetRemoteAddr(addr net.Addr)
 }
 
 // ListenerFilterManager manages the listener filter
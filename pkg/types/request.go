@@ -146,4 +146,22 @@ type RequestInfo interface {
 
 	// SetRouteEntry sets the route rule
 	SetRouteEntry(routerRule RouteRule)
+
+	// UpstreamConnectDuration reports the duration between requesting an upstream stream and the stream is ready
+	UpstreamConnectDuration() time.Duration
+
+	// SetUpstreamConnectDuration sets the duration between requesting an upstream stream and the stream is ready
+	SetUpstreamConnectDuration(duration time.Duration)
+
+	// RetryCount reports the times the request is retried
+	RetryCount() uint32
+
+	// SetRetryCount sets the times the request is retried
+	SetRetryCount(count uint32)
+
+	// TraceId reports the trace id of the request, which is empty if the tracing is disabled
+	TraceId() string
+
+	// SetTraceId sets the trace id of the request
+	SetTraceId(traceId string)
 }
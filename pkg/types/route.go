@@ -19,6 +19,7 @@ package types
 
 import (
 	"context"
+	"net"
 	"regexp"
 	"time"
 
@@ -97,10 +98,16 @@ type Route interface {
 
 	// DirectResponseRule returns direct response rile
 	DirectResponseRule() DirectResponseRule
+
+	// RedirectRule returns redirect rule
+	RedirectRule() RedirectRule
 }
 
 // RouteRule defines parameters for a route
 type RouteRule interface {
+	// RouteName returns the route's name, which is empty if it is not configured
+	RouteName() string
+
 	// ClusterName returns the route's cluster name
 	ClusterName() string
 
@@ -132,6 +139,12 @@ type RouteRule interface {
 
 	// PathMatchCriterion returns the route's PathMatchCriterion
 	PathMatchCriterion() PathMatchCriterion
+
+	// AutoHostRewrite returns true if the host header should be rewritten to the chosen upstream host's hostname
+	AutoHostRewrite() bool
+
+	// Priority returns the route's routing priority, which decides the cluster's resources used by the request
+	Priority() ResourcePriority
 }
 
 // Policy defines a group of route policy
@@ -139,6 +152,8 @@ type Policy interface {
 	RetryPolicy() RetryPolicy
 
 	ShadowPolicy() ShadowPolicy
+
+	HashPolicy() HashPolicy
 }
 
 // RetryCheckStatus type
@@ -151,6 +166,20 @@ const (
 	RetryOverflow RetryCheckStatus = -2
 )
 
+// RetryCondition is a bit set of the conditions to retry an upstream request
+type RetryCondition uint32
+
+// RetryCondition types
+const (
+	RetryOn5xx RetryCondition = 1 << iota
+	RetryOnGatewayError
+	RetryOnConnectFailure
+	RetryOnRefusedStream
+	RetryOnRetriableStatusCodes
+	RetryOnRetriableSofaRpcStatus
+	RetryOnReset
+)
+
 // RetryPolicy is a type of Policy
 type RetryPolicy interface {
 	RetryOn() bool
@@ -158,6 +187,40 @@ type RetryPolicy interface {
 	TryTimeout() time.Duration
 
 	NumRetries() uint32
+
+	// RetryConditions returns the conditions to retry, works only if RetryOn is true
+	RetryConditions() RetryCondition
+
+	// RetriableStatusCodes returns the response status codes to retry with RetryOnRetriableStatusCodes
+	RetriableStatusCodes() []uint32
+
+	// RetriableSofaRpcStatus returns the sofarpc response status to retry with RetryOnRetriableSofaRpcStatus
+	RetriableSofaRpcStatus() []uint32
+
+	// RetryBackOff returns the base and max interval of the exponential back off between retries
+	RetryBackOff() (baseInterval, maxInterval time.Duration)
+
+	// HostSelectionRetryMaxAttempts returns the max attempts to choose a host
+	// that has not been attempted before, zero means the attempted hosts are not avoided
+	HostSelectionRetryMaxAttempts() uint32
+
+	// RetryBudget returns the retry budget of the route, nil means no budget
+	RetryBudget() RetryBudget
+}
+
+// RetryBudget limits the concurrent retries to a percentage of the route's active requests
+type RetryBudget interface {
+	// OnRequestStart records an active request of the route
+	OnRequestStart()
+
+	// OnRequestFinish records an active request of the route is finished
+	OnRequestFinish()
+
+	// TryAcquire acquires a retry from the budget, returns false if the budget is exhausted
+	TryAcquire() bool
+
+	// Release releases a retry acquired from the budget
+	Release()
 }
 
 type DoRetryCallback func()
@@ -168,11 +231,22 @@ type RetryState interface {
 	ShouldRetry(respHeaders map[string]string, resetReson string, doRetryCb DoRetryCallback) bool
 }
 
-// ShadowPolicy is a type of Policy
+// ShadowPolicy is a type of Policy, mirrors the requests to the shadow cluster
 type ShadowPolicy interface {
+	// ClusterName returns the shadow cluster name, empty means no request should be mirrored
 	ClusterName() string
 
 	RuntimeKey() string
+
+	// Percent returns the percentage of the requests to be mirrored, in the range [0, 100]
+	Percent() float64
+}
+
+// HashPolicy is a type of Policy, generates the hash key for consistent hash load balancer
+type HashPolicy interface {
+	// GenerateHash returns the hash key generated by the request headers and downstream address,
+	// if no hash key is generated, returns false
+	GenerateHash(headers HeaderMap, remoteAddr net.Addr) (uint64, bool)
 }
 
 type VirtualHost interface {
@@ -199,6 +273,14 @@ type DirectResponseRule interface {
 	Body() string
 }
 
+// RedirectRule contains redirect response info
+type RedirectRule interface {
+	// RedirectCode returns the redirect response status code
+	RedirectCode() int
+	// RedirectLocation returns the redirect location built from the request headers
+	RedirectLocation(headers HeaderMap) string
+}
+
 type MetadataMatchCriterion interface {
 	// the name of the metadata key
 	MetadataKeyName() string
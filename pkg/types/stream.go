@@ -102,6 +102,7 @@ const (
 	StreamLocalReset            StreamResetReason = "StreamLocalReset"
 	StreamOverflow              StreamResetReason = "StreamOverflow"
 	StreamRemoteReset           StreamResetReason = "StreamRemoteReset"
+	StreamRefusedReset          StreamResetReason = "StreamRefusedReset"
 	UpstreamReset               StreamResetReason = "UpstreamReset"
 	UpstreamGlobalTimeout       StreamResetReason = "UpstreamGlobalTimeout"
 	UpstreamPerTryTimeout       StreamResetReason = "UpstreamPerTryTimeout"
@@ -166,6 +167,62 @@ type StreamReceiveListener interface {
 	OnDecodeError(ctx context.Context, err error, headers HeaderMap)
 }
 
+// StreamingReceiveListener is a StreamReceiveListener that receives the request/response in pieces
+// as they are decoded, instead of a fully buffered message.
+// A stream connection uses it only if body streaming is enabled in the stream's context, see
+// ContextKeyStreamBody, OnReceive is called as before otherwise.
+type StreamingReceiveListener interface {
+	StreamReceiveListener
+
+	// OnReceiveHeaders is called once the headers are decoded
+	// endStream is false if a body or trailers follow
+	OnReceiveHeaders(ctx context.Context, headers HeaderMap, endStream bool)
+
+	// OnReceiveData is called with each piece of the body as it arrives
+	// endStream supplies whether this is the last piece
+	OnReceiveData(ctx context.Context, data IoBuffer, endStream bool)
+
+	// OnReceiveTrailers is called with the trailers, implicitly ends the stream
+	OnReceiveTrailers(ctx context.Context, trailers HeaderMap)
+}
+
+// FlowControlStream is a stream whose decoding can be paused for flow control
+type FlowControlStream interface {
+	// ReadDisable stops or resumes reading the stream's data from the connection,
+	// the calls are counted, reading resumes when every disable is paired with an enable
+	ReadDisable(disable bool)
+}
+
+// UpgradableStream is a stream whose connection can be upgraded to another protocol,
+// such as the HTTP/1.1 Upgrade mechanism used by WebSocket
+type UpgradableStream interface {
+	// Upgrade stops decoding the stream connection after the current request/response,
+	// the raw bytes read from the connection later are passed to the listener.
+	// On server scenario, the tunnel takes effect after the response is sent
+	Upgrade(listener TunnelListener) StreamTunnel
+}
+
+// StreamTunnel is a raw bidirectional byte tunnel on an upgraded stream connection
+type StreamTunnel interface {
+	// Start starts to read raw bytes from the connection
+	Start()
+
+	// Write writes raw bytes to the connection
+	Write(data IoBuffer) error
+
+	// Close closes the connection
+	Close()
+}
+
+// TunnelListener is called on raw bytes read from a StreamTunnel
+type TunnelListener interface {
+	// OnTunnelData is called with the raw bytes read from the connection
+	OnTunnelData(data IoBuffer)
+
+	// OnTunnelClose is called when the connection is closed
+	OnTunnelClose()
+}
+
 // StreamConnection is a connection runs multiple streams
 type StreamConnection interface {
 	// Dispatch incoming data
@@ -207,6 +264,12 @@ type StreamConnectionEventListener interface {
 	OnGoAway()
 }
 
+// StreamConnectionUpgradeListener is an optional StreamConnectionEventListener for the upgradable connections
+type StreamConnectionUpgradeListener interface {
+	// OnUpgrade is called when the connection is upgraded, the connection can not be used by other streams
+	OnUpgrade()
+}
+
 // ServerStreamConnectionEventListener is a stream connection event listener for server connection
 type ServerStreamConnectionEventListener interface {
 	StreamConnectionEventListener
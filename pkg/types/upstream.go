@@ -67,6 +67,18 @@ type ClusterManager interface {
 	// RemoveClusterHosts, remove the host by address string
 	RemoveClusterHosts(clusterName string, hosts []string) error
 
+	// ClusterNames returns all the clusters' name
+	ClusterNames() []string
+
+	// SetHostHealthFlag sets the host's health flag, the cluster's healthy hosts will be refreshed
+	SetHostHealthFlag(clusterName string, addr string, flag HealthFlag) error
+
+	// ClearHostHealthFlag clears the host's health flag, the cluster's healthy hosts will be refreshed
+	ClearHostHealthFlag(clusterName string, addr string, flag HealthFlag) error
+
+	// UpdateHostWeight updates the host's weight, the host's health flags are kept
+	UpdateHostWeight(clusterName string, addr string, weight uint32) error
+
 	// Destroy the cluster manager
 	Destroy()
 }
@@ -119,8 +131,32 @@ const (
 	FAILED_ACTIVE_HC HealthFlag = 0x1
 	// The host is currently considered an outlier and has been ejected.
 	FAILED_OUTLIER_CHECK HealthFlag = 0x02
+	// The host is drained manually, such as drained by the admin api.
+	MANUAL_DRAIN HealthFlag = 0x04
+)
+
+// OutlierResult is the result of an upstream request, reported to the outlier detector
+type OutlierResult int
+
+// OutlierResult types
+const (
+	// The request is success
+	OutlierResultSuccess OutlierResult = iota
+	// The upstream responses a server error, such as http 5xx
+	OutlierResultServerError
+	// The request is failed with a stream reset or timeout
+	OutlierResultRequestFailed
+	// The connection to upstream host is failed
+	OutlierResultConnectFailed
 )
 
+// OutlierDetector detects the misbehaving hosts in a cluster by the results of upstream requests,
+// the misbehaving hosts will be ejected from the load balancing for a while
+type OutlierDetector interface {
+	// PutResult reports an upstream request's result of the host
+	PutResult(host Host, result OutlierResult)
+}
+
 // Host is an upstream host
 type Host interface {
 	HostInfo
@@ -142,6 +178,9 @@ type Host interface {
 
 	// Health checks whether the host is healthy or not
 	Health() bool
+
+	// ResourceManager returns the host's ResourceManager, which limits the host's connections and requests
+	ResourceManager() ResourceManager
 }
 
 // HostInfo defines a host's basic information
@@ -174,7 +213,14 @@ type HostInfo interface {
 	// If returns true, means support tls connection
 	SupportTLS() bool
 
-	// TODO: add deploy locality
+	// Locality returns the host's deploy locality
+	Locality() v2.Locality
+
+	// Priority returns the host's priority, 0 is the highest priority
+	Priority() uint32
+
+	// LocalityWeight returns the weight of the host's locality
+	LocalityWeight() uint32
 }
 
 // HostStats defines a host's statistics information
@@ -218,16 +264,42 @@ type ClusterInfo interface {
 	// Stats returns the cluster's stats metrics
 	Stats() ClusterStats
 
-	// ResourceManager returns the ResourceManager
+	// ResourceManager returns the ResourceManager of the default routing priority
 	ResourceManager() ResourceManager
 
+	// PriorityResourceManager returns the ResourceManager of the routing priority
+	PriorityResourceManager(priority ResourcePriority) ResourceManager
+
+	// PerHostThresholds returns the circuit breakers thresholds of each host in the cluster
+	PerHostThresholds() v2.Thresholds
+
 	// TLSMng returns the tls manager
 	TLSMng() TLSContextManager
 
 	// LbSubsetInfo returns the load balancer subset's config
 	LbSubsetInfo() LBSubsetInfo
+
+	// OutlierDetector returns the cluster's outlier detector, returns nil if outlier detection is not configured
+	OutlierDetector() OutlierDetector
+
+	// OverprovisioningFactor returns the overprovisioning factor in percentage, used by the locality aware load balancer
+	OverprovisioningFactor() uint32
+
+	// ProxyProtocolVersion returns the PROXY protocol version of the header prepended on upstream connections, 0 means disabled
+	ProxyProtocolVersion() int
 }
 
+// ResourcePriority is the routing priority of the upstream resources
+type ResourcePriority int
+
+// Group of ResourcePriority
+const (
+	DefaultPriority ResourcePriority = iota
+	HighPriority
+	// MaxResourcePriority is the number of ResourcePriority, not a valid priority
+	MaxResourcePriority
+)
+
 // ResourceManager manages different types of Resource
 type ResourceManager interface {
 	// Connections resource to count connections in pool. Only used by protocol which has a connection pool which has multiple connections.
@@ -280,6 +352,14 @@ type ClusterStats struct {
 	UpstreamResponseFailed                         metrics.Counter
 	LBSubSetsFallBack                              metrics.Counter
 	LBSubsetsCreated                               metrics.Gauge
+	OutlierEjectionsTotal                          metrics.Counter
+	OutlierEjectionsActive                         metrics.Gauge
+	OutlierEjectionsOverflow                       metrics.Counter
+	OutlierEjectionsConsecutive5xx                 metrics.Counter
+	OutlierEjectionsConsecutiveConnectFailure      metrics.Counter
+	OutlierEjectionsSuccessRate                    metrics.Counter
+	UpstreamRequestShadow                          metrics.Counter
+	UpstreamRequestShadowFailed                    metrics.Counter
 }
 
 type CreateConnectionData struct {
@@ -18,10 +18,13 @@
 package cluster
 
 import (
+	"sync"
 	"sync/atomic"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/filter/accept/proxyprotocol"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/mtls"
 	"sofastack.io/sofa-mosn/pkg/types"
 	"sofastack.io/sofa-mosn/pkg/upstream/healthcheck"
@@ -35,11 +38,16 @@ func NewCluster(clusterConfig v2.Cluster) types.Cluster {
 
 // simpleCluster is an implementation of types.Cluster
 type simpleCluster struct {
-	info          *clusterInfo
-	healthChecker types.HealthChecker
-	lbInstance    types.LoadBalancer // load balancer used for this cluster
-	hostSet       *hostSet
-	snapshot      atomic.Value
+	info            *clusterInfo
+	healthChecker   types.HealthChecker
+	outlierDetector *outlierDetector
+	lbInstance      types.LoadBalancer // load balancer used for this cluster
+	hostSet         *hostSet
+	snapshot        atomic.Value
+	// drainedHosts records the address of the manual drained hosts,
+	// the drain state is kept for the hosts that have the same address when the hosts are updated
+	drainMutex   sync.Mutex
+	drainedHosts map[string]struct{}
 }
 
 func newSimpleCluster(clusterConfig v2.Cluster) *simpleCluster {
@@ -51,7 +59,14 @@ func newSimpleCluster(clusterConfig v2.Cluster) *simpleCluster {
 		stats:                newClusterStats(clusterConfig.Name),
 		lbSubsetInfo:         NewLBSubsetInfo(&clusterConfig.LBSubSetConfig), // new subset load balancer info
 		lbType:               types.LoadBalancerType(clusterConfig.LbType),
-		resourceManager:      NewResourceManager(clusterConfig.CirBreThresholds),
+		resourceManagers:     newPriorityResourceManagers(clusterConfig.Name, clusterConfig.CirBreThresholds),
+	}
+	if thresholds := findThresholds(clusterConfig.CirBreThresholds.PerHostThresholds, v2.PRIORITY_DEFAULT); thresholds != nil {
+		info.perHostThresholds = *thresholds
+	}
+	info.overprovisioningFactor = clusterConfig.OverprovisioningFactor
+	if info.overprovisioningFactor == 0 {
+		info.overprovisioningFactor = defaultOverprovisioningFactor
 	}
 	// tls mng
 	mgr, err := mtls.NewTLSClientContextManager(&clusterConfig.TLS)
@@ -59,8 +74,22 @@ func newSimpleCluster(clusterConfig v2.Cluster) *simpleCluster {
 		log.DefaultLogger.Errorf("[upstream] [cluster] [new cluster] create tls context manager failed, %v", err)
 	}
 	info.tlsMng = mgr
+	switch clusterConfig.ProxyProtocol {
+	case "":
+	case v2.ProxyProtocolV1:
+		info.proxyProtocolVersion = proxyprotocol.Version1
+	case v2.ProxyProtocolV2:
+		info.proxyProtocolVersion = proxyprotocol.Version2
+	default:
+		log.DefaultLogger.Errorf("[upstream] [cluster] [new cluster] cluster %s have unknown proxy protocol version: %s", clusterConfig.Name, clusterConfig.ProxyProtocol)
+	}
+	if info.proxyProtocolVersion != 0 && mgr != nil && mgr.Enabled() {
+		log.DefaultLogger.Errorf("[upstream] [cluster] [new cluster] cluster %s enables tls, proxy protocol is ignored", clusterConfig.Name)
+		info.proxyProtocolVersion = 0
+	}
 	cluster := &simpleCluster{
-		info: info,
+		info:         info,
+		drainedHosts: make(map[string]struct{}),
 	}
 	// init a empty
 	hostSet := &hostSet{}
@@ -80,11 +109,26 @@ func newSimpleCluster(clusterConfig v2.Cluster) *simpleCluster {
 		})
 
 	}
+	if clusterConfig.OutlierDetection != nil {
+		log.DefaultLogger.Infof("[upstream] [cluster] [new cluster] cluster %s have outlier detection", clusterConfig.Name)
+		cluster.outlierDetector = newOutlierDetector(clusterConfig.OutlierDetection, info.stats, func(host types.Host) {
+			if hs := cluster.hostSet; hs != nil {
+				hs.refreshHealthHost(host)
+			}
+		})
+		info.outlierDetector = cluster.outlierDetector
+	}
 	return cluster
 }
 
 func (sc *simpleCluster) UpdateHosts(newHosts []types.Host) {
 	info := sc.info
+	// outlier detector should keep the ejected state before the hosts are used
+	if sc.outlierDetector != nil {
+		sc.outlierDetector.SetHosts(newHosts)
+	}
+	sc.keepDrainState(newHosts)
+	info.keepHostResourceManagers(newHosts)
 	hostSet := &hostSet{}
 	hostSet.setFinalHost(newHosts)
 	// load balance
@@ -109,6 +153,39 @@ func (sc *simpleCluster) UpdateHosts(newHosts []types.Host) {
 
 }
 
+// keepDrainState sets the manual drain flag for the hosts that are drained before,
+// the hosts that are already drained, such as the hosts reused from an old cluster, are recorded too.
+// the drain state of the removed hosts is dropped.
+func (sc *simpleCluster) keepDrainState(hosts []types.Host) {
+	sc.drainMutex.Lock()
+	defer sc.drainMutex.Unlock()
+	drainedHosts := make(map[string]struct{}, len(sc.drainedHosts))
+	for _, host := range hosts {
+		addr := host.AddressString()
+		if _, ok := sc.drainedHosts[addr]; ok {
+			host.SetHealthFlag(types.MANUAL_DRAIN)
+		}
+		if host.ContainHealthFlag(types.MANUAL_DRAIN) {
+			drainedHosts[addr] = struct{}{}
+		}
+	}
+	sc.drainedHosts = drainedHosts
+}
+
+// setHostDrained records the drain state of the host address
+func (sc *simpleCluster) setHostDrained(addr string, drained bool) {
+	sc.drainMutex.Lock()
+	defer sc.drainMutex.Unlock()
+	if drained {
+		if sc.drainedHosts == nil {
+			sc.drainedHosts = make(map[string]struct{})
+		}
+		sc.drainedHosts[addr] = struct{}{}
+	} else {
+		delete(sc.drainedHosts, addr)
+	}
+}
+
 func (sc *simpleCluster) Snapshot() types.ClusterSnapshot {
 	si := sc.snapshot.Load()
 	if snap, ok := si.(*clusterSnapshot); ok {
@@ -129,10 +206,41 @@ type clusterInfo struct {
 	lbType               types.LoadBalancerType // if use subset lb , lbType is used as inner LB algorithm for choosing subset's host
 	connBufferLimitBytes uint32
 	maxRequestsPerConn   uint32
-	resourceManager      types.ResourceManager
+	resourceManagers     []types.ResourceManager // index is the types.ResourcePriority
+	perHostThresholds    v2.Thresholds
+	// hostResourceManagers keeps the per host resource manager by address,
+	// so the hosts rebuilt with the same address share the same limits
+	hostResourceManagers sync.Map // host address -> types.ResourceManager
 	stats                types.ClusterStats
 	lbSubsetInfo         types.LBSubsetInfo
 	tlsMng               types.TLSContextManager
+	outlierDetector      types.OutlierDetector
+	// overprovisioningFactor is used by the locality aware load balancer
+	overprovisioningFactor uint32
+	proxyProtocolVersion   int
+}
+
+// hostResourceManager returns the resource manager of the host address, a new one is created if not exists
+func (ci *clusterInfo) hostResourceManager(addr string) types.ResourceManager {
+	if rm, ok := ci.hostResourceManagers.Load(addr); ok {
+		return rm.(types.ResourceManager)
+	}
+	rm, _ := ci.hostResourceManagers.LoadOrStore(addr, newHostResourceManager(ci.perHostThresholds, metrics.NewHostStats(ci.name, addr)))
+	return rm.(types.ResourceManager)
+}
+
+// keepHostResourceManagers deletes the resource managers of the hosts that are removed
+func (ci *clusterInfo) keepHostResourceManagers(hosts []types.Host) {
+	addrs := make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		addrs[host.AddressString()] = struct{}{}
+	}
+	ci.hostResourceManagers.Range(func(key, value interface{}) bool {
+		if _, ok := addrs[key.(string)]; !ok {
+			ci.hostResourceManagers.Delete(key)
+		}
+		return true
+	})
 }
 
 func (ci *clusterInfo) Name() string {
@@ -160,7 +268,18 @@ func (ci *clusterInfo) Stats() types.ClusterStats {
 }
 
 func (ci *clusterInfo) ResourceManager() types.ResourceManager {
-	return ci.resourceManager
+	return ci.resourceManagers[types.DefaultPriority]
+}
+
+func (ci *clusterInfo) PriorityResourceManager(priority types.ResourcePriority) types.ResourceManager {
+	if priority < types.DefaultPriority || priority >= types.MaxResourcePriority {
+		return ci.ResourceManager()
+	}
+	return ci.resourceManagers[priority]
+}
+
+func (ci *clusterInfo) PerHostThresholds() v2.Thresholds {
+	return ci.perHostThresholds
 }
 
 func (ci *clusterInfo) TLSMng() types.TLSContextManager {
@@ -171,6 +290,18 @@ func (ci *clusterInfo) LbSubsetInfo() types.LBSubsetInfo {
 	return ci.lbSubsetInfo
 }
 
+func (ci *clusterInfo) OutlierDetector() types.OutlierDetector {
+	return ci.outlierDetector
+}
+
+func (ci *clusterInfo) OverprovisioningFactor() uint32 {
+	return ci.overprovisioningFactor
+}
+
+func (ci *clusterInfo) ProxyProtocolVersion() int {
+	return ci.proxyProtocolVersion
+}
+
 type clusterSnapshot struct {
 	info    types.ClusterInfo
 	hostSet types.HostSet
@@ -19,6 +19,8 @@ package cluster
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
@@ -232,6 +234,167 @@ func TestClusterRemoveHostWithSnapshot(t *testing.T) {
 	}
 }
 
+func TestClusterManagerClusterNames(t *testing.T) {
+	cm := _createClusterManager()
+	cm.AddOrUpdatePrimaryCluster(v2.Cluster{
+		Name:   "test0",
+		LbType: v2.LB_RANDOM,
+	})
+	names := cm.ClusterNames()
+	if len(names) != 2 || names[0] != "test0" || names[1] != "test1" {
+		t.Fatalf("cluster names is not expected: %v", names)
+	}
+}
+
+func TestClusterManagerHostHealthFlag(t *testing.T) {
+	cm := _createClusterManager()
+	if err := cm.SetHostHealthFlag("test1", "127.0.0.1:10000", types.MANUAL_DRAIN); err != nil {
+		t.Fatal("set host health flag failed", err)
+	}
+	snap := cm.GetClusterSnapshot(context.Background(), "test1")
+	healthyHosts := snap.HostSet().HealthyHosts()
+	if len(healthyHosts) != 1 || healthyHosts[0].AddressString() != "127.0.0.1:10001" {
+		t.Fatalf("drained host should not be healthy, healthy hosts: %v", healthyHosts)
+	}
+	// the drained host should not be chosen
+	for i := 0; i < 10; i++ {
+		if host := snap.LoadBalancer().ChooseHost(nil); host == nil || host.AddressString() != "127.0.0.1:10001" {
+			t.Fatalf("choose an unexpected host: %v", host)
+		}
+	}
+	if err := cm.ClearHostHealthFlag("test1", "127.0.0.1:10000", types.MANUAL_DRAIN); err != nil {
+		t.Fatal("clear host health flag failed", err)
+	}
+	if len(snap.HostSet().HealthyHosts()) != 2 {
+		t.Fatal("host should be healthy after clear the health flag")
+	}
+	// not exists
+	if err := cm.SetHostHealthFlag("test1", "127.0.0.1:9999", types.MANUAL_DRAIN); err == nil {
+		t.Error("set a not exists host's health flag, but no error")
+	}
+	if err := cm.SetHostHealthFlag("test0", "127.0.0.1:10000", types.MANUAL_DRAIN); err == nil {
+		t.Error("set a not exists cluster's host health flag, but no error")
+	}
+}
+
+func TestClusterManagerDrainKeptOnUpdate(t *testing.T) {
+	cm := _createClusterManager()
+	if err := cm.SetHostHealthFlag("test1", "127.0.0.1:10000", types.MANUAL_DRAIN); err != nil {
+		t.Fatal("set host health flag failed", err)
+	}
+	isDrained := func(addr string) bool {
+		for _, host := range cm.GetClusterSnapshot(context.Background(), "test1").HostSet().Hosts() {
+			if host.AddressString() == addr {
+				return host.ContainHealthFlag(types.MANUAL_DRAIN)
+			}
+		}
+		return false
+	}
+	// hosts are updated, such as eds, the new host with same address is drained too
+	if err := cm.UpdateClusterHosts("test1", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}},
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10001"}},
+	}); err != nil {
+		t.Fatal("update cluster hosts failed", err)
+	}
+	if !isDrained("127.0.0.1:10000") || isDrained("127.0.0.1:10001") {
+		t.Fatal("drain state is not kept after hosts updated")
+	}
+	if n := len(cm.GetClusterSnapshot(context.Background(), "test1").HostSet().HealthyHosts()); n != 1 {
+		t.Fatalf("drained host should not be healthy, healthy hosts: %d", n)
+	}
+	// cluster config is updated
+	cm.AddOrUpdatePrimaryCluster(v2.Cluster{
+		Name:   "test1",
+		LbType: v2.LB_ROUNDROBIN,
+	})
+	cm.AppendClusterHosts("test1", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10002"}},
+	})
+	if !isDrained("127.0.0.1:10000") {
+		t.Fatal("drain state is not kept after cluster updated")
+	}
+	// clear the drain, the state is not kept any more
+	cm.ClearHostHealthFlag("test1", "127.0.0.1:10000", types.MANUAL_DRAIN)
+	cm.AppendClusterHosts("test1", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10003"}},
+	})
+	if isDrained("127.0.0.1:10000") {
+		t.Fatal("drain state is kept after cleared")
+	}
+	// removed host's drain state is dropped
+	cm.SetHostHealthFlag("test1", "127.0.0.1:10000", types.MANUAL_DRAIN)
+	cm.RemoveClusterHosts("test1", []string{"127.0.0.1:10000"})
+	cm.AppendClusterHosts("test1", []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}},
+	})
+	if isDrained("127.0.0.1:10000") {
+		t.Fatal("removed host's drain state should be dropped")
+	}
+}
+
+func TestClusterManagerUpdateHostWeight(t *testing.T) {
+	cm := _createClusterManager()
+	cm.SetHostHealthFlag("test1", "127.0.0.1:10000", types.MANUAL_DRAIN)
+	var oldHost types.Host
+	for _, host := range cm.GetClusterSnapshot(context.Background(), "test1").HostSet().Hosts() {
+		if host.AddressString() == "127.0.0.1:10000" {
+			oldHost = host
+		}
+	}
+	if err := cm.UpdateHostWeight("test1", "127.0.0.1:10000", 10); err != nil {
+		t.Fatal("update host weight failed", err)
+	}
+	hosts := cm.GetClusterSnapshot(context.Background(), "test1").HostSet().Hosts()
+	if len(hosts) != 2 {
+		t.Fatalf("update host weight should not change the hosts number, got %d", len(hosts))
+	}
+	for _, host := range hosts {
+		if host.AddressString() != "127.0.0.1:10000" {
+			continue
+		}
+		if host.Weight() != 10 {
+			t.Errorf("host weight is not updated, got %d", host.Weight())
+		}
+		if !host.ContainHealthFlag(types.MANUAL_DRAIN) {
+			t.Error("host health flag is not kept")
+		}
+		if host.Metadata()["version"] != "1.0.0" {
+			t.Error("host metadata is not kept")
+		}
+		if host != oldHost {
+			t.Error("host should not be recreated")
+		}
+	}
+	if err := cm.UpdateHostWeight("test1", "127.0.0.1:9999", 10); err == nil {
+		t.Error("update a not exists host's weight, but no error")
+	}
+}
+
+func TestClusterManagerConcurrentHostsUpdate(t *testing.T) {
+	cm := _createClusterManager()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		addr := fmt.Sprintf("127.0.0.1:%d", 20000+i)
+		go func() {
+			defer wg.Done()
+			cm.AppendClusterHosts("test1", []v2.Host{
+				{HostConfig: v2.HostConfig{Address: addr}},
+			})
+		}()
+		go func(weight uint32) {
+			defer wg.Done()
+			cm.UpdateHostWeight("test1", "127.0.0.1:10000", weight)
+		}(uint32(i + 1))
+	}
+	wg.Wait()
+	// no host is lost
+	if n := len(cm.GetClusterSnapshot(context.Background(), "test1").HostSet().Hosts()); n != 22 {
+		t.Fatalf("expected 22 hosts, but got %d", n)
+	}
+}
+
 func TestConnPoolForCluster(t *testing.T) {
 	_createClusterManager()
 	snap := GetClusterMngAdapterInstance().GetClusterSnapshot(nil, "test1")
@@ -271,7 +434,7 @@ func TestConnPoolUpdateTLS(t *testing.T) {
 			},
 		},
 	}); err != nil {
-		t.Fatal("update cluster hosts failed, %v", err)
+		t.Fatalf("update cluster hosts failed, %v", err)
 	}
 	newSnap := GetClusterMngAdapterInstance().GetClusterSnapshot(nil, "test1")
 	if connPool := GetClusterMngAdapterInstance().ConnPoolForCluster(newMockLbContext(nil), newSnap, mockProtocol); !connPool.SupportTLS() {
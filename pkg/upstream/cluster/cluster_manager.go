@@ -55,6 +55,8 @@ type clusterManager struct {
 	clustersMap      sync.Map
 	protocolConnPool sync.Map
 	mux              sync.Mutex
+	// hostsMutexes serializes the hosts mutations of a cluster, cluster name -> *sync.Mutex
+	hostsMutexes sync.Map
 }
 
 type clusterManagerSingleton struct {
@@ -70,6 +72,13 @@ func (singleton *clusterManagerSingleton) Destroy() {
 
 var clusterMangerInstance = &clusterManagerSingleton{}
 
+// hostsMutex returns the mutex that serializes the hosts mutations of the cluster.
+// the hosts are read from the snapshot, modified and updated, so the mutations should not be concurrent.
+func (cm *clusterManager) hostsMutex(clusterName string) *sync.Mutex {
+	mu, _ := cm.hostsMutexes.LoadOrStore(clusterName, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
 func NewClusterManagerSingleton(clusters []v2.Cluster, clusterMap map[string][]v2.Host) types.ClusterManager {
 	clusterMangerInstance.instanceMutex.Lock()
 	defer clusterMangerInstance.instanceMutex.Unlock()
@@ -107,6 +116,9 @@ func (cm *clusterManager) AddOrUpdatePrimaryCluster(cluster v2.Cluster) error {
 	}
 	// check update or new
 	clusterName := cluster.Name
+	mu := cm.hostsMutex(clusterName)
+	mu.Lock()
+	defer mu.Unlock()
 	// set config
 	store.SetClusterConfig(clusterName, cluster)
 	// add or update
@@ -163,6 +175,9 @@ func (cm *clusterManager) RemovePrimaryCluster(clusterNames ...string) error {
 
 // UpdateClusterHosts update all hosts in the cluster
 func (cm *clusterManager) UpdateClusterHosts(clusterName string, hostConfigs []v2.Host) error {
+	mu := cm.hostsMutex(clusterName)
+	mu.Lock()
+	defer mu.Unlock()
 	ci, ok := cm.clustersMap.Load(clusterName)
 	if !ok {
 		log.DefaultLogger.Alertf(types.ErrorKeyHostsUpdate, "cluster %s not found", clusterName)
@@ -181,6 +196,9 @@ func (cm *clusterManager) UpdateClusterHosts(clusterName string, hostConfigs []v
 
 // AppendClusterHosts adds new hosts into cluster
 func (cm *clusterManager) AppendClusterHosts(clusterName string, hostConfigs []v2.Host) error {
+	mu := cm.hostsMutex(clusterName)
+	mu.Lock()
+	defer mu.Unlock()
 	ci, ok := cm.clustersMap.Load(clusterName)
 	if !ok {
 		log.DefaultLogger.Alertf(types.ErrorKeyHostsAppend, "cluster %s not found", clusterName)
@@ -200,6 +218,9 @@ func (cm *clusterManager) AppendClusterHosts(clusterName string, hostConfigs []v
 
 // RemoveClusterHosts removes hosts from cluster by address string
 func (cm *clusterManager) RemoveClusterHosts(clusterName string, addrs []string) error {
+	mu := cm.hostsMutex(clusterName)
+	mu.Lock()
+	defer mu.Unlock()
 	ci, ok := cm.clustersMap.Load(clusterName)
 	if !ok {
 		log.DefaultLogger.Alertf(types.ErrorKeyHostsDelete, "cluster %s not found", clusterName)
@@ -226,6 +247,84 @@ func (cm *clusterManager) RemoveClusterHosts(clusterName string, addrs []string)
 	return nil
 }
 
+// ClusterNames returns all the clusters' name in sorted order
+func (cm *clusterManager) ClusterNames() []string {
+	names := []string{}
+	cm.clustersMap.Range(func(key, value interface{}) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+// SetHostHealthFlag sets the host's health flag, and refresh the cluster's healthy hosts
+func (cm *clusterManager) SetHostHealthFlag(clusterName string, addr string, flag types.HealthFlag) error {
+	return cm.updateHostHealthFlag(clusterName, addr, flag, true)
+}
+
+// ClearHostHealthFlag clears the host's health flag, and refresh the cluster's healthy hosts
+func (cm *clusterManager) ClearHostHealthFlag(clusterName string, addr string, flag types.HealthFlag) error {
+	return cm.updateHostHealthFlag(clusterName, addr, flag, false)
+}
+
+func (cm *clusterManager) updateHostHealthFlag(clusterName string, addr string, flag types.HealthFlag, set bool) error {
+	mu := cm.hostsMutex(clusterName)
+	mu.Lock()
+	defer mu.Unlock()
+	ci, ok := cm.clustersMap.Load(clusterName)
+	if !ok {
+		return fmt.Errorf("cluster %s is not exists", clusterName)
+	}
+	c := ci.(types.Cluster)
+	hs := c.Snapshot().HostSet()
+	for _, host := range hs.Hosts() {
+		if host.AddressString() == addr {
+			if set {
+				host.SetHealthFlag(flag)
+			} else {
+				host.ClearHealthFlag(flag)
+			}
+			// the manual drain state should be kept when the hosts are updated
+			if sc, ok := c.(*simpleCluster); ok && flag&types.MANUAL_DRAIN != 0 {
+				sc.setHostDrained(addr, set)
+			}
+			if h, ok := hs.(*hostSet); ok {
+				h.refreshHealthHost(host)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s is not exists in cluster %s", addr, clusterName)
+}
+
+// UpdateHostWeight updates the weight of the existing host, and rebuilds the cluster's load balancer
+func (cm *clusterManager) UpdateHostWeight(clusterName string, addr string, weight uint32) error {
+	mu := cm.hostsMutex(clusterName)
+	mu.Lock()
+	defer mu.Unlock()
+	ci, ok := cm.clustersMap.Load(clusterName)
+	if !ok {
+		return fmt.Errorf("cluster %s is not exists", clusterName)
+	}
+	c := ci.(types.Cluster)
+	hosts := c.Snapshot().HostSet().Hosts()
+	for _, host := range hosts {
+		if host.AddressString() == addr {
+			sh, ok := host.(*simpleHost)
+			if !ok {
+				return fmt.Errorf("host %s in cluster %s does not support update weight", addr, clusterName)
+			}
+			sh.setWeight(weight)
+			// the load balancer is rebuilt with the same hosts
+			c.UpdateHosts(hosts)
+			refreshHostsConfig(clusterName, hosts)
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s is not exists in cluster %s", addr, clusterName)
+}
+
 // GetClusterSnapshot returns cluster snap
 // do not needs PutClusterSnapshot any more
 func (cm *clusterManager) GetClusterSnapshot(ctx context.Context, clusterName string) types.ClusterSnapshot {
@@ -243,11 +342,15 @@ func (cm *clusterManager) TCPConnForCluster(lbCtx types.LoadBalancerContext, sna
 	if snapshot == nil || reflect.ValueOf(snapshot).IsNil() {
 		return types.CreateConnectionData{}
 	}
-	host := snapshot.LoadBalancer().ChooseHost(lbCtx)
+	host := chooseHost(lbCtx, snapshot)
 	if host == nil {
 		return types.CreateConnectionData{}
 	}
-	return host.CreateConnection(context.Background())
+	ctx := context.Background()
+	if lbCtx != nil && lbCtx.DownstreamContext() != nil {
+		ctx = lbCtx.DownstreamContext()
+	}
+	return host.CreateConnection(ctx)
 }
 
 func (cm *clusterManager) ConnPoolForCluster(balancerContext types.LoadBalancerContext, snapshot types.ClusterSnapshot, protocol types.Protocol) types.ConnectionPool {
@@ -262,6 +365,24 @@ func (cm *clusterManager) ConnPoolForCluster(balancerContext types.LoadBalancerC
 	return pool
 }
 
+// chooseHost chooses a host by the cluster's load balancer, if the host is rejected by the load balancer context,
+// chooses another one, up to the context's host selection retry count. The last chosen host is returned anyway.
+func chooseHost(lbCtx types.LoadBalancerContext, snapshot types.ClusterSnapshot) types.Host {
+	lb := snapshot.LoadBalancer()
+	host := lb.ChooseHost(lbCtx)
+	if lbCtx == nil || host == nil {
+		return host
+	}
+	for i := uint32(0); i < lbCtx.HostSelectionRetryCount() && lbCtx.ShouldSelectAnotherHost(host); i++ {
+		next := lb.ChooseHost(lbCtx)
+		if next == nil {
+			break
+		}
+		host = next
+	}
+	return host
+}
+
 const cycleTimes = 5
 
 var (
@@ -279,7 +400,7 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 	var pools [cycleTimes]types.ConnectionPool
 
 	for i := 0; i < cycleTimes; i++ {
-		host := clusterSnapshot.LoadBalancer().ChooseHost(balancerContext)
+		host := chooseHost(balancerContext, clusterSnapshot)
 		if host == nil {
 			return nil, errNilHostChoose
 		}
@@ -1,9 +1,14 @@
 package cluster
 
 import (
+	"context"
+	"net"
 	"testing"
+	"time"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/filter/accept/proxyprotocol"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -163,3 +168,51 @@ func TestUpdateHostLabels(t *testing.T) {
 		}
 	}
 }
+
+func TestHostCreateConnectionWithProxyProtocol(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+	headerChan := make(chan *proxyprotocol.Header, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		header, _ := proxyprotocol.ReadHeader(conn)
+		headerChan <- header
+	}()
+	cluster := newSimpleCluster(v2.Cluster{
+		Name:          "proxy_protocol",
+		ClusterType:   v2.SIMPLE_CLUSTER,
+		LbType:        v2.LB_RANDOM,
+		ProxyProtocol: v2.ProxyProtocolV1,
+	})
+	host := NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address: ln.Addr().String(),
+		},
+	}, cluster.Snapshot().ClusterInfo())
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443}
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyDownstreamRemoteAddr, src)
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyDownstreamLocalAddr, dst)
+	conn := host.CreateConnection(ctx).Connection
+	if err := conn.Connect(true); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	defer conn.Close(types.NoFlush, types.LocalClose)
+	select {
+	case header := <-headerChan:
+		if header == nil || header.Version != proxyprotocol.Version1 ||
+			header.SourceAddr.String() != src.String() ||
+			header.DestinationAddr.String() != dst.String() {
+			t.Fatalf("proxy protocol header is not expected: %+v", header)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no proxy protocol header received")
+	}
+}
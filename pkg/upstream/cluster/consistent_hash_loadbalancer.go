@@ -0,0 +1,224 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+const (
+	defaultMinRingSize     = 1024
+	defaultHashesPerHost   = 64
+	defaultMaglevTableSize = 65537 // should be a prime number
+)
+
+// consistentHashKey returns the hash key from the load balancer context,
+// if the context have no hash key, a random one is used.
+type consistentHashKey struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+func newConsistentHashKey() consistentHashKey {
+	return consistentHashKey{
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (k *consistentHashKey) hashKey(context types.LoadBalancerContext) uint64 {
+	if context != nil {
+		if key, ok := context.ComputeHashKey(); ok {
+			return key
+		}
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return k.rand.Uint64()
+}
+
+type ringHashEntry struct {
+	hash uint64
+	host types.Host
+}
+
+// ringHashLoadBalancer is a ketama-like consistent hash load balancer.
+// Each host is placed on the ring several times according to its weight,
+// a request chooses the first healthy host clockwise from the request's hash key.
+// The ring is built with all the hosts, so a host's health state changes, or a host added or removed
+// only affects the keys that near the host on the ring.
+type ringHashLoadBalancer struct {
+	consistentHashKey
+	hosts types.HostSet
+	ring  []ringHashEntry
+}
+
+func newRingHashLoadBalancer(hosts types.HostSet) types.LoadBalancer {
+	lb := &ringHashLoadBalancer{
+		consistentHashKey: newConsistentHashKey(),
+		hosts:             hosts,
+	}
+	lb.ring = buildHashRing(hosts.Hosts())
+	return lb
+}
+
+func buildHashRing(hosts []types.Host) []ringHashEntry {
+	if len(hosts) == 0 {
+		return nil
+	}
+	var totalWeight int64
+	for _, host := range hosts {
+		totalWeight += hostWeight(host)
+	}
+	ringSize := int64(len(hosts) * defaultHashesPerHost)
+	if ringSize < defaultMinRingSize {
+		ringSize = defaultMinRingSize
+	}
+	ring := make([]ringHashEntry, 0, ringSize)
+	for _, host := range hosts {
+		// ceil(ringSize * weight / totalWeight)
+		hashes := (ringSize*hostWeight(host) + totalWeight - 1) / totalWeight
+		addr := host.AddressString()
+		for i := int64(0); i < hashes; i++ {
+			ring = append(ring, ringHashEntry{
+				hash: utils.HashString(addr + "_" + strconv.FormatInt(i, 10)),
+				host: host,
+			})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+	return ring
+}
+
+func (lb *ringHashLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	if len(lb.ring) == 0 || len(lb.hosts.HealthyHosts()) == 0 {
+		return nil
+	}
+	key := lb.hashKey(context)
+	total := len(lb.ring)
+	idx := sort.Search(total, func(i int) bool {
+		return lb.ring[i].hash >= key
+	})
+	for i := 0; i < total; i++ {
+		host := lb.ring[(idx+i)%total].host
+		if host.Health() {
+			return host
+		}
+	}
+	return nil
+}
+
+func (lb *ringHashLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCriteria) bool {
+	return len(lb.hosts.Hosts()) > 0
+}
+
+// maglevLoadBalancer is the consistent hash load balancer described in
+// "Maglev: A Fast and Reliable Software Network Load Balancer".
+// Each host fills the lookup table in turns according to its weight, with the permutation
+// generated by the host's address.
+// If the host in the lookup table is unhealthy, the next entry in the table will be used.
+type maglevLoadBalancer struct {
+	consistentHashKey
+	hosts     types.HostSet
+	hostsList []types.Host
+	table     []int32 // index of hostsList
+}
+
+func newMaglevLoadBalancer(hosts types.HostSet) types.LoadBalancer {
+	lb := &maglevLoadBalancer{
+		consistentHashKey: newConsistentHashKey(),
+		hosts:             hosts,
+		hostsList:         hosts.Hosts(),
+	}
+	lb.table = buildMaglevTable(lb.hostsList, defaultMaglevTableSize)
+	return lb
+}
+
+func buildMaglevTable(hosts []types.Host, size uint64) []int32 {
+	if len(hosts) == 0 {
+		return nil
+	}
+	offsets := make([]uint64, len(hosts))
+	skips := make([]uint64, len(hosts))
+	weights := make([]int64, len(hosts))
+	nexts := make([]uint64, len(hosts))
+	counts := make([]int64, len(hosts))
+	var maxWeight int64
+	for i, host := range hosts {
+		addr := host.AddressString()
+		offsets[i] = utils.HashString(addr) % size
+		skips[i] = utils.HashString("skip_"+addr)%(size-1) + 1
+		weights[i] = hostWeight(host)
+		if weights[i] > maxWeight {
+			maxWeight = weights[i]
+		}
+	}
+	table := make([]int32, size)
+	for i := range table {
+		table[i] = -1
+	}
+	var filled uint64
+	for iteration := int64(1); filled < size; iteration++ {
+		for i := range hosts {
+			// a host with lower weight skips some turns
+			if counts[i]*maxWeight >= iteration*weights[i] {
+				continue
+			}
+			c := (offsets[i] + nexts[i]*skips[i]) % size
+			for table[c] >= 0 {
+				nexts[i]++
+				c = (offsets[i] + nexts[i]*skips[i]) % size
+			}
+			table[c] = int32(i)
+			nexts[i]++
+			counts[i]++
+			filled++
+			if filled == size {
+				break
+			}
+		}
+	}
+	return table
+}
+
+func (lb *maglevLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	if len(lb.table) == 0 || len(lb.hosts.HealthyHosts()) == 0 {
+		return nil
+	}
+	total := uint64(len(lb.table))
+	idx := lb.hashKey(context) % total
+	for i := uint64(0); i < total; i++ {
+		host := lb.hostsList[lb.table[(idx+i)%total]]
+		if host.Health() {
+			return host
+		}
+	}
+	return nil
+}
+
+func (lb *maglevLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCriteria) bool {
+	return len(lb.hosts.Hosts()) > 0
+}
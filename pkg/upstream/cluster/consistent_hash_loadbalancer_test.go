@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"fmt"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func makeHashHostSet(count int) (*hostSet, []types.Host) {
+	var hosts []types.Host
+	for i := 0; i < count; i++ {
+		hosts = append(hosts, &mockHost{
+			addr: fmt.Sprintf("127.0.0.1:%d", 8080+i),
+		})
+	}
+	hs := &hostSet{}
+	hs.setFinalHost(hosts)
+	return hs, hosts
+}
+
+func chooseHostsByKey(lb types.LoadBalancer, keys int) []string {
+	result := make([]string, keys)
+	for i := 0; i < keys; i++ {
+		host := lb.ChooseHost(newMockLbContextWithHashKey(uint64(i) * 0x9e3779b97f4a7c15))
+		if host != nil {
+			result[i] = host.AddressString()
+		}
+	}
+	return result
+}
+
+func testConsistentHashLB(t *testing.T, lbType types.LoadBalancerType) {
+	const keys = 10000
+	hs, _ := makeHashHostSet(10)
+	lb := NewLoadBalancer(lbType, hs)
+	before := chooseHostsByKey(lb, keys)
+	// same key choose same host
+	if again := chooseHostsByKey(lb, keys); fmt.Sprint(again) != fmt.Sprint(before) {
+		t.Fatal("same hash key choose different hosts")
+	}
+	// every host should be choosed
+	distribution := map[string]int{}
+	for _, addr := range before {
+		distribution[addr]++
+	}
+	if len(distribution) != 10 {
+		t.Fatalf("hosts are not choosed balance: %v", distribution)
+	}
+	// add a host, only a minimal share of keys should be moved
+	hsAdded, _ := makeHashHostSet(11)
+	added := chooseHostsByKey(NewLoadBalancer(lbType, hsAdded), keys)
+	moved := 0
+	for i := range before {
+		if before[i] != added[i] {
+			moved++
+		}
+	}
+	// expected moved 1/11, allow some deviation
+	if moved > keys/5 {
+		t.Fatalf("add host moved too many keys: %d", moved)
+	}
+	// remove a host, only a minimal share of keys should be moved
+	hsRemoved, _ := makeHashHostSet(9)
+	removed := chooseHostsByKey(NewLoadBalancer(lbType, hsRemoved), keys)
+	moved = 0
+	for i := range before {
+		if before[i] != removed[i] {
+			moved++
+		}
+	}
+	if moved > keys/5 {
+		t.Fatalf("remove host moved too many keys: %d", moved)
+	}
+}
+
+func TestRingHashLB(t *testing.T) {
+	testConsistentHashLB(t, types.RingHash)
+}
+
+func TestMaglevLB(t *testing.T) {
+	testConsistentHashLB(t, types.Maglev)
+}
+
+func TestConsistentHashLBUnhealthyHost(t *testing.T) {
+	for _, lbType := range []types.LoadBalancerType{types.RingHash, types.Maglev} {
+		hs, hosts := makeHashHostSet(5)
+		lb := NewLoadBalancer(lbType, hs)
+		ctx := newMockLbContextWithHashKey(12345)
+		choosed := lb.ChooseHost(ctx)
+		if choosed == nil {
+			t.Fatalf("%s choose host failed", lbType)
+		}
+		choosed.SetHealthFlag(types.FAILED_ACTIVE_HC)
+		hs.refreshHealthHost(choosed)
+		another := lb.ChooseHost(ctx)
+		if another == nil || another == choosed {
+			t.Fatalf("%s choose host not expected, get: %v", lbType, another)
+		}
+		// recover
+		choosed.ClearHealthFlag(types.FAILED_ACTIVE_HC)
+		hs.refreshHealthHost(choosed)
+		if host := lb.ChooseHost(ctx); host != choosed {
+			t.Fatalf("%s choose host not expected, get: %v", lbType, host)
+		}
+		// no healthy host
+		for _, h := range hosts {
+			h.SetHealthFlag(types.FAILED_ACTIVE_HC)
+		}
+		hs.resetHealthyHosts()
+		if host := lb.ChooseHost(ctx); host != nil {
+			t.Fatalf("%s choose host not expected, get: %v", lbType, host)
+		}
+		// no hash key, choose randomly
+		hs.allHosts[0].ClearHealthFlag(types.FAILED_ACTIVE_HC)
+		hs.resetHealthyHosts()
+		if host := lb.ChooseHost(nil); host != hs.allHosts[0] {
+			t.Fatalf("%s choose host not expected, get: %v", lbType, host)
+		}
+	}
+}
+
+func TestConsistentHashLBWeight(t *testing.T) {
+	for _, lbType := range []types.LoadBalancerType{types.RingHash, types.Maglev} {
+		hs := &hostSet{}
+		hs.setFinalHost([]types.Host{
+			&mockHost{addr: "127.0.0.1:8080", w: 3},
+			&mockHost{addr: "127.0.0.1:8081", w: 1},
+		})
+		lb := NewLoadBalancer(lbType, hs)
+		distribution := map[string]int{}
+		for _, addr := range chooseHostsByKey(lb, 10000) {
+			distribution[addr]++
+		}
+		// expected 3:1
+		if distribution["127.0.0.1:8080"] < 2*distribution["127.0.0.1:8081"] {
+			t.Fatalf("%s hosts are not choosed by weight: %v", lbType, distribution)
+		}
+	}
+}
@@ -21,9 +21,14 @@ import (
 	"context"
 	"net"
 	"sync"
+	"sync/atomic"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	mosnctx "sofastack.io/sofa-mosn/pkg/context"
+	"sofastack.io/sofa-mosn/pkg/filter/accept/proxyprotocol"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/network"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
@@ -38,21 +43,43 @@ type simpleHost struct {
 	tlsDisable    bool
 	weight        uint32
 	healthFlags   uint64
+	// resourceManager limits the host's connections and requests
+	resourceManager types.ResourceManager
+	// deploy locality
+	locality       v2.Locality
+	priority       uint32
+	localityWeight uint32
 }
 
 func NewSimpleHost(config v2.Host, clusterInfo types.ClusterInfo) types.Host {
 	// clusterInfo should not be nil
 	// pre resolve address
 	GetOrCreateAddr(config.Address)
-	return &simpleHost{
-		hostname:      config.Hostname,
-		addressString: config.Address,
-		clusterInfo:   clusterInfo,
-		stats:         newHostStats(clusterInfo.Name(), config.Address),
-		metaData:      config.MetaData,
-		tlsDisable:    config.TLSDisable,
-		weight:        config.Weight,
+	host := &simpleHost{
+		hostname:       config.Hostname,
+		addressString:  config.Address,
+		clusterInfo:    clusterInfo,
+		stats:          newHostStats(clusterInfo.Name(), config.Address),
+		metaData:       config.MetaData,
+		tlsDisable:     config.TLSDisable,
+		weight:         config.Weight,
+		priority:       config.Priority,
+		localityWeight: config.LocalityWeight,
 	}
+	if config.Locality != nil {
+		host.locality = *config.Locality
+	}
+	host.resourceManager = getHostResourceManager(clusterInfo, config.Address)
+	return host
+}
+
+// getHostResourceManager returns the host's resource manager, the resource manager is kept in the cluster,
+// so the host rebuilt with the same address reuses it
+func getHostResourceManager(info types.ClusterInfo, addr string) types.ResourceManager {
+	if ci, ok := info.(*clusterInfo); ok {
+		return ci.hostResourceManager(addr)
+	}
+	return newHostResourceManager(info.PerHostThresholds(), metrics.NewHostStats(info.Name(), addr))
 }
 
 // types.HostInfo Implement
@@ -81,19 +108,43 @@ func (sh *simpleHost) HostStats() types.HostStats {
 }
 
 func (sh *simpleHost) Weight() uint32 {
-	return sh.weight
+	return atomic.LoadUint32(&sh.weight)
+}
+
+// setWeight changes the host's weight, the load balancer should be rebuilt to use the new weight
+func (sh *simpleHost) setWeight(weight uint32) {
+	atomic.StoreUint32(&sh.weight, weight)
+}
+
+func (sh *simpleHost) Locality() v2.Locality {
+	return sh.locality
+}
+
+func (sh *simpleHost) Priority() uint32 {
+	return sh.priority
+}
+
+func (sh *simpleHost) LocalityWeight() uint32 {
+	return sh.localityWeight
 }
 
 func (sh *simpleHost) Config() v2.Host {
-	return v2.Host{
+	config := v2.Host{
 		HostConfig: v2.HostConfig{
-			Address:    sh.addressString,
-			Hostname:   sh.hostname,
-			TLSDisable: sh.tlsDisable,
-			Weight:     sh.weight,
+			Address:        sh.addressString,
+			Hostname:       sh.hostname,
+			TLSDisable:     sh.tlsDisable,
+			Weight:         sh.Weight(),
+			Priority:       sh.priority,
+			LocalityWeight: sh.localityWeight,
 		},
 		MetaData: sh.metaData,
 	}
+	if sh.locality != (v2.Locality{}) {
+		locality := sh.locality
+		config.Locality = &locality
+	}
+	return config
 }
 
 func (sh *simpleHost) SupportTLS() bool {
@@ -108,6 +159,10 @@ func (sh *simpleHost) CreateConnection(context context.Context) types.CreateConn
 	}
 	clientConn := network.NewClientConnection(nil, tlsMng, sh.Address(), nil)
 	clientConn.SetBufferLimit(sh.clusterInfo.ConnBufferLimitBytes())
+	if version := sh.clusterInfo.ProxyProtocolVersion(); version != 0 {
+		// should be added before other listeners, so the header is the first data written
+		clientConn.AddConnectionEventListener(newProxyProtocolWriter(context, clientConn, version))
+	}
 
 	return types.CreateConnectionData{
 		Connection: clientConn,
@@ -115,6 +170,38 @@ func (sh *simpleHost) CreateConnection(context context.Context) types.CreateConn
 	}
 }
 
+// proxyProtocolWriter writes the PROXY protocol header when the upstream connection is connected.
+// The downstream addresses are stored in the context, if not found, a header without addresses is written.
+type proxyProtocolWriter struct {
+	ctx     context.Context
+	conn    types.ClientConnection
+	version int
+}
+
+func newProxyProtocolWriter(ctx context.Context, conn types.ClientConnection, version int) *proxyProtocolWriter {
+	return &proxyProtocolWriter{
+		ctx:     ctx,
+		conn:    conn,
+		version: version,
+	}
+}
+
+func (w *proxyProtocolWriter) OnEvent(event types.ConnectionEvent) {
+	if event != types.Connected {
+		return
+	}
+	src, _ := mosnctx.Get(w.ctx, types.ContextKeyDownstreamRemoteAddr).(net.Addr)
+	dst, _ := mosnctx.Get(w.ctx, types.ContextKeyDownstreamLocalAddr).(net.Addr)
+	header, err := proxyprotocol.EncodeHeader(w.version, src, dst)
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [host] encode proxy protocol header failed: %v", err)
+		return
+	}
+	if err := w.conn.Write(buffer.NewIoBufferBytes(header)); err != nil {
+		log.DefaultLogger.Errorf("[upstream] [host] write proxy protocol header to %s failed: %v", w.conn.RemoteAddr(), err)
+	}
+}
+
 func (sh *simpleHost) ClearHealthFlag(flag types.HealthFlag) {
 	sh.healthFlags &= ^uint64(flag)
 }
@@ -135,6 +222,10 @@ func (sh *simpleHost) Health() bool {
 	return sh.healthFlags == 0
 }
 
+func (sh *simpleHost) ResourceManager() types.ResourceManager {
+	return sh.resourceManager
+}
+
 // net.Addr reuse for same address, valid in simple type
 var AddrStore sync.Map
 
@@ -44,6 +44,11 @@ func init() {
 	}
 	RegisterLBType(types.RoundRobin, rrFactory.newRoundRobinLoadBalancer)
 	RegisterLBType(types.Random, newRandomLoadBalancer)
+	RegisterLBType(types.WeightedRoundRobin, newWeightedRoundRobinLoadBalancer)
+	RegisterLBType(types.LeastActiveRequest, newLeastActiveRequestLoadBalancer)
+	RegisterLBType(types.RingHash, newRingHashLoadBalancer)
+	RegisterLBType(types.Maglev, newMaglevLoadBalancer)
+	RegisterLBType(types.LocalityAware, newLocalityLoadBalancer)
 }
 
 func NewLoadBalancer(lbType types.LoadBalancerType, hosts types.HostSet) types.LoadBalancer {
@@ -120,5 +125,104 @@ func (lb *roundRobinLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCrit
 	return len(lb.hosts.Hosts()) > 0
 }
 
-// TODO:
-// WRR
+// weightedRoundRobinLoadBalancer is a smooth weighted round robin load balancer,
+// the same algorithm as nginx.
+// the hosts with higher weight will be choosed more times, but not continuously
+type weightedRoundRobinLoadBalancer struct {
+	mutex          sync.Mutex
+	hosts          types.HostSet
+	currentWeights map[string]int64 // host address -> current weight
+}
+
+func newWeightedRoundRobinLoadBalancer(hosts types.HostSet) types.LoadBalancer {
+	return &weightedRoundRobinLoadBalancer{
+		hosts:          hosts,
+		currentWeights: make(map[string]int64, len(hosts.Hosts())),
+	}
+}
+
+func (lb *weightedRoundRobinLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	targets := lb.hosts.HealthyHosts()
+	if len(targets) == 0 {
+		return nil
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	return smoothWeightedChoose(targets, lb.currentWeights)
+}
+
+// smoothWeightedChoose chooses a host from the targets by the smooth weighted round robin algorithm,
+// the current weights are updated, so the caller should serialize the calls with the same current weights.
+func smoothWeightedChoose(targets []types.Host, currentWeights map[string]int64) types.Host {
+	var total int64
+	var choosed types.Host
+	var choosedWeight int64
+	for _, host := range targets {
+		weight := hostWeight(host)
+		addr := host.AddressString()
+		current := currentWeights[addr] + weight
+		currentWeights[addr] = current
+		total += weight
+		if choosed == nil || current > choosedWeight {
+			choosed = host
+			choosedWeight = current
+		}
+	}
+	currentWeights[choosed.AddressString()] -= total
+	return choosed
+}
+
+func (lb *weightedRoundRobinLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCriteria) bool {
+	return len(lb.hosts.Hosts()) > 0
+}
+
+// hostWeight returns the host's weight, a host without weight is treated as the min weight
+func hostWeight(host types.Host) int64 {
+	if w := host.Weight(); w > 0 {
+		return int64(w)
+	}
+	return 1
+}
+
+// leastActiveRequestLoadBalancer choose two healthy hosts randomly, and returns the one
+// with fewer active requests (power of two choices).
+type leastActiveRequestLoadBalancer struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+	hosts types.HostSet
+}
+
+func newLeastActiveRequestLoadBalancer(hosts types.HostSet) types.LoadBalancer {
+	return &leastActiveRequestLoadBalancer{
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		hosts: hosts,
+	}
+}
+
+func (lb *leastActiveRequestLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	targets := lb.hosts.HealthyHosts()
+	total := len(targets)
+	if total == 0 {
+		return nil
+	}
+	if total == 1 {
+		return targets[0]
+	}
+	lb.mutex.Lock()
+	first := lb.rand.Intn(total)
+	second := lb.rand.Intn(total - 1)
+	lb.mutex.Unlock()
+	// makes sure the two choices are different
+	if second >= first {
+		second++
+	}
+	candidate, other := targets[first], targets[second]
+	if other.HostStats().UpstreamRequestActive.Count() < candidate.HostStats().UpstreamRequestActive.Count() {
+		return other
+	}
+	return candidate
+}
+
+func (lb *leastActiveRequestLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCriteria) bool {
+	return len(lb.hosts.Hosts()) > 0
+}
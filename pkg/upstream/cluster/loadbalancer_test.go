@@ -0,0 +1,169 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"testing"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestWeightedRoundRobinLB(t *testing.T) {
+	hs := &hostSet{}
+	hs.setFinalHost([]types.Host{
+		&mockHost{addr: "127.0.0.1:8080", w: 5},
+		&mockHost{addr: "127.0.0.1:8081", w: 1},
+		&mockHost{addr: "127.0.0.1:8082", w: 1},
+	})
+	lb := NewLoadBalancer(types.WeightedRoundRobin, hs)
+	if _, ok := lb.(*weightedRoundRobinLoadBalancer); !ok {
+		t.Fatal("load balancer created not expected")
+	}
+	// smooth weighted round robin: a a b a c a a
+	expected := []string{
+		"127.0.0.1:8080", "127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8080",
+		"127.0.0.1:8082", "127.0.0.1:8080", "127.0.0.1:8080",
+	}
+	for round := 0; round < 3; round++ {
+		for i, addr := range expected {
+			host := lb.ChooseHost(nil)
+			if host == nil || host.AddressString() != addr {
+				t.Fatalf("round %d index %d choose host not expected, want %s, get %v", round, i, addr, host)
+			}
+		}
+	}
+	// unhealthy host will not be choosed
+	hs.allHosts[0].SetHealthFlag(types.FAILED_ACTIVE_HC)
+	hs.refreshHealthHost(hs.allHosts[0])
+	for i := 0; i < 10; i++ {
+		host := lb.ChooseHost(nil)
+		if host == nil || host.AddressString() == "127.0.0.1:8080" {
+			t.Fatal("choose host not expected, get: ", host)
+		}
+	}
+}
+
+func TestLeastActiveRequestLB(t *testing.T) {
+	var hosts []types.Host
+	for _, addr := range []string{"127.0.0.1:8080", "127.0.0.1:8081"} {
+		hosts = append(hosts, &mockHost{
+			addr:  addr,
+			stats: newHostStats("least_request", addr),
+		})
+	}
+	hs := &hostSet{}
+	hs.setFinalHost(hosts)
+	lb := NewLoadBalancer(types.LeastActiveRequest, hs)
+	if _, ok := lb.(*leastActiveRequestLoadBalancer); !ok {
+		t.Fatal("load balancer created not expected")
+	}
+	hosts[0].HostStats().UpstreamRequestActive.Inc(10)
+	defer hosts[0].HostStats().UpstreamRequestActive.Dec(10)
+	for i := 0; i < 100; i++ {
+		host := lb.ChooseHost(nil)
+		if host == nil || host.AddressString() != "127.0.0.1:8081" {
+			t.Fatal("choose host not expected, get: ", host)
+		}
+	}
+	// no healthy hosts
+	for _, h := range hosts {
+		h.SetHealthFlag(types.FAILED_ACTIVE_HC)
+	}
+	hs.resetHealthyHosts()
+	if host := lb.ChooseHost(nil); host != nil {
+		t.Fatal("choose host not expected, get: ", host)
+	}
+}
+
+func TestWeightedRoundRobinSubsetLB(t *testing.T) {
+	cfg := exampleHostConfigs()
+	for i := range cfg {
+		cfg[i].Weight = uint32(i + 1)
+	}
+	hs := createHostset(cfg)
+	subsetInfo := NewLBSubsetInfo(exampleSubsetConfig())
+	lb := newSubsetLoadBalancer(types.WeightedRoundRobin, hs, newClusterStats("test"), subsetInfo)
+	ctx := newMockLbContext(map[string]string{
+		"version": "1.0",
+	})
+	// choose e1,e2,e5
+	for i := 0; i < 100; i++ {
+		host := lb.ChooseHost(ctx)
+		if host == nil {
+			t.Fatal("choose host failed")
+		}
+		switch host.Hostname() {
+		case "e1", "e2", "e5":
+		default:
+			t.Fatal("choose host not expected, get: ", host)
+		}
+	}
+}
+
+func TestNewLBClusterWithLbType(t *testing.T) {
+	for _, lbType := range []v2.LbType{v2.LB_WEIGHTED_ROUNDROBIN, v2.LB_LEAST_REQUEST} {
+		c := newSimpleCluster(v2.Cluster{
+			Name:        "test",
+			ClusterType: v2.SIMPLE_CLUSTER,
+			LbType:      lbType,
+		})
+		if c == nil || c.info == nil {
+			t.Fatal("create cluster failed")
+		}
+		if c.info.lbType != types.LoadBalancerType(lbType) {
+			t.Fatal("create cluster lb type not expected")
+		}
+	}
+}
+
+func TestChooseHostWithSelectionRetry(t *testing.T) {
+	hs := &hostSet{}
+	hs.setFinalHost([]types.Host{
+		&mockHost{addr: "127.0.0.1:8080"},
+		&mockHost{addr: "127.0.0.1:8081"},
+	})
+	snapshot := &clusterSnapshot{
+		hostSet: hs,
+		lb:      NewLoadBalancer(types.RoundRobin, hs),
+	}
+	ctx := &mockLbContext{
+		rejected:       map[string]bool{"127.0.0.1:8080": true},
+		selectionRetry: 1,
+	}
+	for i := 0; i < 10; i++ {
+		host := chooseHost(ctx, snapshot)
+		if host == nil || host.AddressString() != "127.0.0.1:8081" {
+			t.Fatalf("rejected host should not be chosen, get: %v", host)
+		}
+	}
+	// all hosts are rejected, returns the last chosen host
+	ctx.rejected["127.0.0.1:8081"] = true
+	if host := chooseHost(ctx, snapshot); host == nil {
+		t.Fatal("a host should be chosen even if it is rejected")
+	}
+	// no selection retry
+	ctx.selectionRetry = 0
+	chosen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		chosen[chooseHost(ctx, snapshot).AddressString()] = true
+	}
+	if len(chosen) != 2 {
+		t.Fatal("rejected host should be chosen without selection retry")
+	}
+}
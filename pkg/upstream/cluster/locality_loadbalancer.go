@@ -0,0 +1,291 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const defaultOverprovisioningFactor = 140
+
+// localLocality is the locality of the running mosn, hosts in the same zone are preferred
+// by the locality aware load balancer
+var localLocality atomic.Value
+
+func init() {
+	localLocality.Store(v2.Locality{})
+}
+
+// SetLocalLocality sets the locality of the running mosn
+func SetLocalLocality(locality v2.Locality) {
+	localLocality.Store(locality)
+}
+
+// GetLocalLocality returns the locality of the running mosn
+func GetLocalLocality() v2.Locality {
+	return localLocality.Load().(v2.Locality)
+}
+
+// sameZone returns true if the host locality is in the same zone as the local locality.
+// the region is compared only if both of them are configured.
+func sameZone(local, host v2.Locality) bool {
+	if local.Zone == "" || local.Zone != host.Zone {
+		return false
+	}
+	if local.Region != "" && host.Region != "" && local.Region != host.Region {
+		return false
+	}
+	return true
+}
+
+// healthPercent returns the health percentage of hosts scaled by the overprovisioning factor, max 100.
+func healthPercent(healthy, total int, factor uint32) uint64 {
+	if total == 0 {
+		return 0
+	}
+	percent := uint64(healthy) * uint64(factor) / uint64(total)
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+type localityState struct {
+	weight uint64
+	hosts  []types.Host
+}
+
+type priorityState struct {
+	load uint64
+	// hosts is the candidate hosts when the locality weight is not used
+	hosts       []types.Host
+	localities  []localityState
+	totalWeight uint64
+}
+
+type localityLBState struct {
+	healthyHosts []types.Host
+	priorities   []priorityState
+	totalLoad    uint64
+}
+
+// localityLoadBalancer is a locality and priority aware load balancer.
+// The priority (0 is the highest) is choosed according to the priority load, the load of a priority is
+// its healthy percentage scaled by the cluster's overprovisioning factor, and the remaining load
+// spills to the next priority.
+// In a priority, the healthy hosts in the same zone as the local mosn are preferred, if the zone is not
+// healthy enough, the hosts are choosed by the locality weights, or from all the healthy hosts
+// if there is no locality weight.
+// The host in the choosed hosts is choosed by the host weight, same as the weighted round robin load balancer.
+type localityLoadBalancer struct {
+	mutex          sync.Mutex
+	rand           *rand.Rand
+	hosts          types.HostSet
+	currentWeights map[string]int64 // host address -> current weight
+	state          atomic.Value     // *localityLBState
+}
+
+func newLocalityLoadBalancer(hosts types.HostSet) types.LoadBalancer {
+	return &localityLoadBalancer{
+		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		hosts:          hosts,
+		currentWeights: make(map[string]int64, len(hosts.Hosts())),
+	}
+}
+
+func (lb *localityLoadBalancer) ChooseHost(context types.LoadBalancerContext) types.Host {
+	state := lb.getState()
+	if state == nil || state.totalLoad == 0 {
+		return nil
+	}
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	value := lb.rand.Uint64() % state.totalLoad
+	var weightValue uint64
+	ps := &state.priorities[len(state.priorities)-1]
+	for i := range state.priorities {
+		if value < state.priorities[i].load {
+			ps = &state.priorities[i]
+			break
+		}
+		value -= state.priorities[i].load
+	}
+	if ps.totalWeight > 0 {
+		weightValue = lb.rand.Uint64() % ps.totalWeight
+	}
+	targets := ps.hosts
+	if ps.totalWeight > 0 {
+		for _, locality := range ps.localities {
+			if weightValue < locality.weight {
+				targets = locality.hosts
+				break
+			}
+			weightValue -= locality.weight
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	return smoothWeightedChoose(targets, lb.currentWeights)
+}
+
+func (lb *localityLoadBalancer) IsExistsHosts(metadata types.MetadataMatchCriteria) bool {
+	return len(lb.hosts.Hosts()) > 0
+}
+
+// getState returns the cached state, the state is rebuilt when the healthy hosts changed.
+func (lb *localityLoadBalancer) getState() *localityLBState {
+	healthyHosts := lb.hosts.HealthyHosts()
+	if len(healthyHosts) == 0 {
+		return nil
+	}
+	if v := lb.state.Load(); v != nil {
+		state := v.(*localityLBState)
+		if sameHosts(state.healthyHosts, healthyHosts) {
+			return state
+		}
+	}
+	state := newLocalityLBState(lb.hosts.Hosts(), healthyHosts, GetLocalLocality())
+	lb.state.Store(state)
+	return state
+}
+
+// sameHosts checks whether the two slices are the same one, the healthy hosts slice is
+// recreated when the hosts health state changed
+func sameHosts(a, b []types.Host) bool {
+	return len(a) == len(b) && &a[0] == &b[0]
+}
+
+func overprovisioningFactor(hosts []types.Host) uint32 {
+	if len(hosts) > 0 {
+		if info := hosts[0].ClusterInfo(); info != nil {
+			if factor := info.OverprovisioningFactor(); factor > 0 {
+				return factor
+			}
+		}
+	}
+	return defaultOverprovisioningFactor
+}
+
+type hostsCounter struct {
+	total   int
+	healthy []types.Host
+}
+
+func (c *hostsCounter) add(host types.Host) {
+	c.total++
+	if host.Health() {
+		c.healthy = append(c.healthy, host)
+	}
+}
+
+func newLocalityLBState(allHosts, healthyHosts []types.Host, local v2.Locality) *localityLBState {
+	factor := overprovisioningFactor(allHosts)
+	// group hosts by priority
+	priorityHosts := make(map[uint32][]types.Host)
+	for _, host := range allHosts {
+		priorityHosts[host.Priority()] = append(priorityHosts[host.Priority()], host)
+	}
+	levels := make([]uint32, 0, len(priorityHosts))
+	for p := range priorityHosts {
+		levels = append(levels, p)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i] < levels[j]
+	})
+	state := &localityLBState{
+		healthyHosts: healthyHosts,
+		priorities:   make([]priorityState, 0, len(levels)),
+	}
+	var totalHealth uint64
+	for _, p := range levels {
+		ps := newPriorityState(priorityHosts[p], local, factor)
+		totalHealth += ps.load
+		state.priorities = append(state.priorities, ps)
+	}
+	// if the total health is not enough, the load is distributed by the health of each priority.
+	// otherwise the higher priority takes the load as much as it can, and the remaining spills to the next.
+	if totalHealth >= 100 {
+		var remaining uint64 = 100
+		for i := range state.priorities {
+			load := state.priorities[i].load
+			if load > remaining {
+				load = remaining
+			}
+			state.priorities[i].load = load
+			remaining -= load
+		}
+		totalHealth = 100
+	}
+	state.totalLoad = totalHealth
+	return state
+}
+
+func newPriorityState(hosts []types.Host, local v2.Locality, factor uint32) priorityState {
+	all := &hostsCounter{}
+	localZone := &hostsCounter{}
+	localities := make(map[v2.Locality]*hostsCounter)
+	var order []v2.Locality
+	weights := make(map[v2.Locality]uint32)
+	for _, host := range hosts {
+		all.add(host)
+		locality := host.Locality()
+		if sameZone(local, locality) {
+			localZone.add(host)
+		}
+		c, ok := localities[locality]
+		if !ok {
+			c = &hostsCounter{}
+			localities[locality] = c
+			order = append(order, locality)
+		}
+		c.add(host)
+		if w := host.LocalityWeight(); w > weights[locality] {
+			weights[locality] = w
+		}
+	}
+	ps := priorityState{
+		load:  healthPercent(len(all.healthy), all.total, factor),
+		hosts: all.healthy,
+	}
+	// the local zone is healthy enough, use local zone only
+	if localZone.total > 0 && healthPercent(len(localZone.healthy), localZone.total, factor) >= 100 {
+		ps.hosts = localZone.healthy
+		return ps
+	}
+	for _, locality := range order {
+		c := localities[locality]
+		weight := uint64(weights[locality]) * healthPercent(len(c.healthy), c.total, factor)
+		if weight == 0 {
+			continue
+		}
+		ps.localities = append(ps.localities, localityState{
+			weight: weight,
+			hosts:  c.healthy,
+		})
+		ps.totalWeight += weight
+	}
+	return ps
+}
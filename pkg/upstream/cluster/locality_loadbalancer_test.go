@@ -0,0 +1,211 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"testing"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func newLocalityHosts(zone string, priority, localityWeight uint32, addrs ...string) []types.Host {
+	hosts := make([]types.Host, 0, len(addrs))
+	for _, addr := range addrs {
+		hosts = append(hosts, &mockHost{
+			addr:     addr,
+			locality: v2.Locality{Zone: zone},
+			priority: priority,
+			lw:       localityWeight,
+		})
+	}
+	return hosts
+}
+
+func setUnhealthy(hs *hostSet, hosts ...types.Host) {
+	for _, h := range hosts {
+		h.SetHealthFlag(types.FAILED_ACTIVE_HC)
+	}
+	hs.resetHealthyHosts()
+}
+
+func TestLocalityLBPriority(t *testing.T) {
+	p0 := newLocalityHosts("", 0, 0, "127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8082", "127.0.0.1:8083")
+	p1 := newLocalityHosts("", 1, 0, "127.0.0.1:9080", "127.0.0.1:9081")
+	hs := &hostSet{}
+	hs.setFinalHost(append(append([]types.Host{}, p0...), p1...))
+	lb := NewLoadBalancer(types.LocalityAware, hs)
+	llb, ok := lb.(*localityLoadBalancer)
+	if !ok {
+		t.Fatal("load balancer created not expected")
+	}
+	// all hosts are healthy, only the priority 0 is used
+	for i := 0; i < 100; i++ {
+		host := lb.ChooseHost(nil)
+		if host == nil || host.Priority() != 0 {
+			t.Fatal("choose host not expected, get: ", host)
+		}
+	}
+	// half of priority 0 is unhealthy, 2 * 140 / 4 = 70% load in priority 0, and spills 30% to priority 1
+	setUnhealthy(hs, p0[0], p0[1])
+	state := llb.getState()
+	if len(state.priorities) != 2 || state.totalLoad != 100 ||
+		state.priorities[0].load != 70 || state.priorities[1].load != 30 {
+		t.Fatalf("priority load not expected: %+v", state)
+	}
+	counts := map[uint32]int{}
+	for i := 0; i < 1000; i++ {
+		host := lb.ChooseHost(nil)
+		if host == nil || !host.Health() {
+			t.Fatal("choose host not expected, get: ", host)
+		}
+		counts[host.Priority()]++
+	}
+	if counts[0] == 0 || counts[1] == 0 || counts[0] < counts[1] {
+		t.Fatalf("priority choosed not expected: %v", counts)
+	}
+	// priority 0 is all unhealthy, all the load goes to priority 1
+	setUnhealthy(hs, p0[2], p0[3])
+	for i := 0; i < 100; i++ {
+		host := lb.ChooseHost(nil)
+		if host == nil || host.Priority() != 1 {
+			t.Fatal("choose host not expected, get: ", host)
+		}
+	}
+	// no healthy hosts
+	setUnhealthy(hs, p1...)
+	if host := lb.ChooseHost(nil); host != nil {
+		t.Fatal("choose host not expected, get: ", host)
+	}
+	if !lb.IsExistsHosts(nil) {
+		t.Fatal("hosts should be exists")
+	}
+}
+
+func TestLocalityLBNotEnoughHealth(t *testing.T) {
+	p0 := newLocalityHosts("", 0, 0, "127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8082", "127.0.0.1:8083")
+	p1 := newLocalityHosts("", 1, 0, "127.0.0.1:9080", "127.0.0.1:9081", "127.0.0.1:9082", "127.0.0.1:9083")
+	hs := &hostSet{}
+	hs.setFinalHost(append(append([]types.Host{}, p0...), p1...))
+	lb := newLocalityLoadBalancer(hs).(*localityLoadBalancer)
+	// 1 * 140 / 4 = 35% in each priority, the load is distributed by the health
+	setUnhealthy(hs, p0[0], p0[1], p0[2], p1[0], p1[1], p1[2])
+	state := lb.getState()
+	if state.totalLoad != 70 || state.priorities[0].load != 35 || state.priorities[1].load != 35 {
+		t.Fatalf("priority load not expected: %+v", state)
+	}
+	for i := 0; i < 100; i++ {
+		host := lb.ChooseHost(nil)
+		if host == nil || !host.Health() {
+			t.Fatal("choose host not expected, get: ", host)
+		}
+	}
+}
+
+func TestLocalityLBLocalZone(t *testing.T) {
+	SetLocalLocality(v2.Locality{Zone: "zone_a"})
+	defer SetLocalLocality(v2.Locality{})
+	local := newLocalityHosts("zone_a", 0, 0, "127.0.0.1:8080", "127.0.0.1:8081")
+	remote := newLocalityHosts("zone_b", 0, 0, "127.0.0.1:9080", "127.0.0.1:9081")
+	hs := &hostSet{}
+	hs.setFinalHost(append(append([]types.Host{}, local...), remote...))
+	lb := NewLoadBalancer(types.LocalityAware, hs)
+	// the local zone is preferred
+	for i := 0; i < 100; i++ {
+		host := lb.ChooseHost(nil)
+		if host == nil || host.Locality().Zone != "zone_a" {
+			t.Fatal("choose host not expected, get: ", host)
+		}
+	}
+	// 1 * 140 / 2 = 70% is not healthy enough, fail over to all the zones
+	setUnhealthy(hs, local[0])
+	counts := map[string]int{}
+	for i := 0; i < 100; i++ {
+		host := lb.ChooseHost(nil)
+		if host == nil || host == local[0] {
+			t.Fatal("choose host not expected, get: ", host)
+		}
+		counts[host.Locality().Zone]++
+	}
+	if counts["zone_a"] == 0 || counts["zone_b"] == 0 {
+		t.Fatalf("zone choosed not expected: %v", counts)
+	}
+}
+
+func TestLocalityLBLocalityWeight(t *testing.T) {
+	zoneA := newLocalityHosts("zone_a", 0, 3, "127.0.0.1:8080", "127.0.0.1:8081")
+	zoneB := newLocalityHosts("zone_b", 0, 1, "127.0.0.1:9080", "127.0.0.1:9081")
+	zoneC := newLocalityHosts("zone_c", 0, 0, "127.0.0.1:7080")
+	hs := &hostSet{}
+	hs.setFinalHost(append(append(append([]types.Host{}, zoneA...), zoneB...), zoneC...))
+	lb := NewLoadBalancer(types.LocalityAware, hs)
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		host := lb.ChooseHost(nil)
+		if host == nil {
+			t.Fatal("choose host failed")
+		}
+		counts[host.Locality().Zone]++
+	}
+	// the locality without weight will not be choosed
+	if counts["zone_c"] != 0 || counts["zone_a"] < 2*counts["zone_b"] {
+		t.Fatalf("locality choosed not expected: %v", counts)
+	}
+}
+
+func TestLocalityLBHostWeight(t *testing.T) {
+	SetLocalLocality(v2.Locality{Zone: "zone_a"})
+	defer SetLocalLocality(v2.Locality{})
+	hosts := newLocalityHosts("zone_a", 0, 0, "127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8082")
+	hosts[0].(*mockHost).w = 3
+	hosts[1].(*mockHost).w = 1
+	// no weight is treated as the min weight
+	hs := &hostSet{}
+	hs.setFinalHost(hosts)
+	lb := NewLoadBalancer(types.LocalityAware, hs)
+	counts := map[string]int{}
+	for i := 0; i < 500; i++ {
+		host := lb.ChooseHost(nil)
+		if host == nil {
+			t.Fatal("choose host failed")
+		}
+		counts[host.AddressString()]++
+	}
+	// smooth weighted round robin, 3:1:1
+	if counts["127.0.0.1:8080"] != 300 || counts["127.0.0.1:8081"] != 100 || counts["127.0.0.1:8082"] != 100 {
+		t.Fatalf("host choosed not expected: %v", counts)
+	}
+}
+
+func TestSameZone(t *testing.T) {
+	testCases := []struct {
+		local, host v2.Locality
+		expected    bool
+	}{
+		{v2.Locality{Zone: "a"}, v2.Locality{Region: "r1", Zone: "a"}, true},
+		{v2.Locality{Region: "r1", Zone: "a"}, v2.Locality{Region: "r1", Zone: "a"}, true},
+		{v2.Locality{Region: "r1", Zone: "a"}, v2.Locality{Region: "r2", Zone: "a"}, false},
+		{v2.Locality{Zone: "a"}, v2.Locality{Zone: "b"}, false},
+		{v2.Locality{}, v2.Locality{}, false},
+	}
+	for i, tc := range testCases {
+		if sameZone(tc.local, tc.host) != tc.expected {
+			t.Errorf("#%d same zone expected %v", i, tc.expected)
+		}
+	}
+}
@@ -32,6 +32,12 @@ type mockHost struct {
 	addr       string
 	meta       v2.Metadata
 	healthFlag uint64
+	w          uint32
+	stats      types.HostStats
+	info       types.ClusterInfo
+	locality   v2.Locality
+	priority   uint32
+	lw         uint32
 	types.Host
 }
 
@@ -47,6 +53,14 @@ func (h *mockHost) Metadata() v2.Metadata {
 	return h.meta
 }
 
+func (h *mockHost) Weight() uint32 {
+	return h.w
+}
+
+func (h *mockHost) HostStats() types.HostStats {
+	return h.stats
+}
+
 func (h *mockHost) Health() bool {
 	return h.healthFlag == 0
 }
@@ -59,10 +73,30 @@ func (h *mockHost) SetHealthFlag(flag types.HealthFlag) {
 	h.healthFlag |= uint64(flag)
 }
 
+func (h *mockHost) ContainHealthFlag(flag types.HealthFlag) bool {
+	return h.healthFlag&uint64(flag) > 0
+}
+
 func (h *mockHost) HealthFlag() types.HealthFlag {
 	return types.HealthFlag(h.healthFlag)
 }
 
+func (h *mockHost) ClusterInfo() types.ClusterInfo {
+	return h.info
+}
+
+func (h *mockHost) Locality() v2.Locality {
+	return h.locality
+}
+
+func (h *mockHost) Priority() uint32 {
+	return h.priority
+}
+
+func (h *mockHost) LocalityWeight() uint32 {
+	return h.lw
+}
+
 type ipPool struct {
 	idx int
 	ips []string
@@ -133,8 +167,12 @@ func init() {
 
 type mockLbContext struct {
 	types.LoadBalancerContext
-	mmc    types.MetadataMatchCriteria
-	header types.HeaderMap
+	mmc     types.MetadataMatchCriteria
+	header  types.HeaderMap
+	hashKey *uint64
+	// rejected hosts address
+	rejected       map[string]bool
+	selectionRetry uint32
 }
 
 func newMockLbContext(m map[string]string) types.LoadBalancerContext {
@@ -152,6 +190,27 @@ func newMockLbContextWithHeader(m map[string]string, header types.HeaderMap) typ
 	}
 }
 
+func newMockLbContextWithHashKey(key uint64) types.LoadBalancerContext {
+	return &mockLbContext{
+		hashKey: &key,
+	}
+}
+
+func (ctx *mockLbContext) HostSelectionRetryCount() uint32 {
+	return ctx.selectionRetry
+}
+
+func (ctx *mockLbContext) ShouldSelectAnotherHost(host types.Host) bool {
+	return ctx.rejected[host.AddressString()]
+}
+
+func (ctx *mockLbContext) ComputeHashKey() (uint64, bool) {
+	if ctx.hashKey == nil {
+		return 0, false
+	}
+	return *ctx.hashKey, true
+}
+
 func (ctx *mockLbContext) MetadataMatchCriteria() types.MetadataMatchCriteria {
 	return ctx.mmc
 }
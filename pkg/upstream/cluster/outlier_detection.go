@@ -0,0 +1,351 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+	"sofastack.io/sofa-mosn/pkg/utils"
+)
+
+// default outlier detection parameters, same as envoy
+const (
+	defaultConsecutive5xx            = 5
+	defaultConsecutiveConnectFailure = 5
+	defaultDetectionInterval         = 10 * time.Second
+	defaultBaseEjectionTime          = 30 * time.Second
+	defaultMaxEjectionTime           = 300 * time.Second
+	defaultMaxEjectionPercent        = 10
+	defaultSuccessRateMinimumHosts   = 5
+	defaultSuccessRateRequestVolume  = 100
+	defaultSuccessRateStdevFactor    = 1900
+)
+
+// ejection reasons
+const (
+	ejectConsecutive5xx            = "consecutive_5xx"
+	ejectConsecutiveConnectFailure = "consecutive_connect_failure"
+	ejectSuccessRate               = "success_rate"
+)
+
+// outlierDetectionConfig is the outlier detection config with default values
+type outlierDetectionConfig struct {
+	consecutive5xx            uint32
+	consecutiveConnectFailure uint32
+	interval                  time.Duration
+	baseEjectionTime          time.Duration
+	maxEjectionTime           time.Duration
+	maxEjectionPercent        uint32
+	successRateMinimumHosts   uint32
+	successRateRequestVolume  uint32
+	successRateStdevFactor    float64
+}
+
+func newOutlierDetectionConfig(cfg *v2.OutlierDetection) outlierDetectionConfig {
+	c := outlierDetectionConfig{
+		consecutive5xx:            cfg.Consecutive5xx,
+		consecutiveConnectFailure: cfg.ConsecutiveConnectFailure,
+		interval:                  cfg.Interval,
+		baseEjectionTime:          cfg.BaseEjectionTime,
+		maxEjectionTime:           cfg.MaxEjectionTime,
+		maxEjectionPercent:        cfg.MaxEjectionPercent,
+		successRateMinimumHosts:   cfg.SuccessRateMinimumHosts,
+		successRateRequestVolume:  cfg.SuccessRateRequestVolume,
+		successRateStdevFactor:    float64(cfg.SuccessRateStdevFactor) / 1000,
+	}
+	if c.consecutive5xx == 0 {
+		c.consecutive5xx = defaultConsecutive5xx
+	}
+	if c.consecutiveConnectFailure == 0 {
+		c.consecutiveConnectFailure = defaultConsecutiveConnectFailure
+	}
+	if c.interval <= 0 {
+		c.interval = defaultDetectionInterval
+	}
+	if c.baseEjectionTime <= 0 {
+		c.baseEjectionTime = defaultBaseEjectionTime
+	}
+	if c.maxEjectionTime <= 0 {
+		c.maxEjectionTime = defaultMaxEjectionTime
+	}
+	if c.maxEjectionTime < c.baseEjectionTime {
+		c.maxEjectionTime = c.baseEjectionTime
+	}
+	if c.maxEjectionPercent == 0 {
+		c.maxEjectionPercent = defaultMaxEjectionPercent
+	}
+	if c.maxEjectionPercent > 100 {
+		c.maxEjectionPercent = 100
+	}
+	if c.successRateMinimumHosts == 0 {
+		c.successRateMinimumHosts = defaultSuccessRateMinimumHosts
+	}
+	if c.successRateRequestVolume == 0 {
+		c.successRateRequestVolume = defaultSuccessRateRequestVolume
+	}
+	if c.successRateStdevFactor == 0 {
+		c.successRateStdevFactor = float64(defaultSuccessRateStdevFactor) / 1000
+	}
+	return c
+}
+
+// hostMonitor records a host's upstream request results
+type hostMonitor struct {
+	host types.Host
+	// updated by PutResult
+	consecutive5xx            uint32
+	consecutiveConnectFailure uint32
+	requestSuccess            uint64
+	requestTotal              uint64
+	// protected by detector's mutex
+	ejected          bool
+	numEjections     uint32
+	lastEjectionTime time.Time
+	lastUnejectTime  time.Time
+}
+
+// ejectionDuration returns the ejection time of the host,
+// the ejection time grows with the number of ejections, and limited by the max ejection time
+func (m *hostMonitor) ejectionDuration(cfg *outlierDetectionConfig) time.Duration {
+	d := cfg.baseEjectionTime * time.Duration(m.numEjections)
+	if d > cfg.maxEjectionTime || d <= 0 {
+		d = cfg.maxEjectionTime
+	}
+	return d
+}
+
+// outlierDetector is an implementation of types.OutlierDetector
+type outlierDetector struct {
+	mutex    sync.RWMutex
+	config   outlierDetectionConfig
+	stats    types.ClusterStats
+	monitors map[string]*hostMonitor // host address -> monitor
+	ejected  int
+	// onHostChanged is called when a host is ejected or brought back
+	onHostChanged func(host types.Host)
+	// the interval timer is running only if there are requests or ejected hosts
+	timerRunning uint32
+	timer        *utils.Timer
+}
+
+func newOutlierDetector(cfg *v2.OutlierDetection, stats types.ClusterStats, onHostChanged func(host types.Host)) *outlierDetector {
+	return &outlierDetector{
+		config:        newOutlierDetectionConfig(cfg),
+		stats:         stats,
+		monitors:      make(map[string]*hostMonitor),
+		onHostChanged: onHostChanged,
+	}
+}
+
+// SetHosts sets the hosts that the detector monitored, it should be called before the hosts are used.
+// The monitor records and ejection state are kept for the host that have the same address.
+func (d *outlierDetector) SetHosts(hosts []types.Host) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	monitors := make(map[string]*hostMonitor, len(hosts))
+	ejected := 0
+	for _, host := range hosts {
+		addr := host.AddressString()
+		// duplicate address, the first one is used, same as the host set
+		if _, exists := monitors[addr]; exists {
+			continue
+		}
+		m, ok := d.monitors[addr]
+		if !ok {
+			m = &hostMonitor{}
+		}
+		m.host = host
+		if m.ejected {
+			host.SetHealthFlag(types.FAILED_OUTLIER_CHECK)
+			ejected++
+		}
+		monitors[addr] = m
+	}
+	d.monitors = monitors
+	d.ejected = ejected
+	d.stats.OutlierEjectionsActive.Update(int64(ejected))
+}
+
+func (d *outlierDetector) PutResult(host types.Host, result types.OutlierResult) {
+	d.mutex.RLock()
+	m, ok := d.monitors[host.AddressString()]
+	d.mutex.RUnlock()
+	if !ok {
+		return
+	}
+	d.startTimer()
+	atomic.AddUint64(&m.requestTotal, 1)
+	switch result {
+	case types.OutlierResultSuccess:
+		atomic.AddUint64(&m.requestSuccess, 1)
+		atomic.StoreUint32(&m.consecutive5xx, 0)
+		atomic.StoreUint32(&m.consecutiveConnectFailure, 0)
+	case types.OutlierResultServerError, types.OutlierResultRequestFailed:
+		if atomic.AddUint32(&m.consecutive5xx, 1) >= d.config.consecutive5xx {
+			d.ejectHost(m, ejectConsecutive5xx)
+		}
+	case types.OutlierResultConnectFailed:
+		if atomic.AddUint32(&m.consecutiveConnectFailure, 1) >= d.config.consecutiveConnectFailure {
+			d.ejectHost(m, ejectConsecutiveConnectFailure)
+		}
+	}
+}
+
+func (d *outlierDetector) ejectHost(m *hostMonitor, reason string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.ejectHostLocked(m, reason, time.Now())
+}
+
+func (d *outlierDetector) ejectHostLocked(m *hostMonitor, reason string, now time.Time) {
+	if m.ejected {
+		return
+	}
+	// the ejected host is removed from the detector
+	if current, ok := d.monitors[m.host.AddressString()]; !ok || current != m {
+		return
+	}
+	if uint32(d.ejected*100) >= d.config.maxEjectionPercent*uint32(len(d.monitors)) {
+		d.stats.OutlierEjectionsOverflow.Inc(1)
+		log.DefaultLogger.Warnf("[upstream] [outlier detection] eject host %s overflow, reason: %s", m.host.AddressString(), reason)
+		return
+	}
+	m.ejected = true
+	m.numEjections++
+	m.lastEjectionTime = now
+	d.ejected++
+	d.stats.OutlierEjectionsTotal.Inc(1)
+	d.stats.OutlierEjectionsActive.Update(int64(d.ejected))
+	switch reason {
+	case ejectConsecutive5xx:
+		d.stats.OutlierEjectionsConsecutive5xx.Inc(1)
+	case ejectConsecutiveConnectFailure:
+		d.stats.OutlierEjectionsConsecutiveConnectFailure.Inc(1)
+	case ejectSuccessRate:
+		d.stats.OutlierEjectionsSuccessRate.Inc(1)
+	}
+	m.host.HostStats().UpstreamRequestFailureEject.Inc(1)
+	m.host.SetHealthFlag(types.FAILED_OUTLIER_CHECK)
+	log.DefaultLogger.Infof("[upstream] [outlier detection] host %s ejected, reason: %s, ejections: %d", m.host.AddressString(), reason, m.numEjections)
+	if d.onHostChanged != nil {
+		d.onHostChanged(m.host)
+	}
+}
+
+func (d *outlierDetector) unejectHostLocked(m *hostMonitor, now time.Time) {
+	m.ejected = false
+	m.lastUnejectTime = now
+	atomic.StoreUint32(&m.consecutive5xx, 0)
+	atomic.StoreUint32(&m.consecutiveConnectFailure, 0)
+	d.ejected--
+	d.stats.OutlierEjectionsActive.Update(int64(d.ejected))
+	m.host.ClearHealthFlag(types.FAILED_OUTLIER_CHECK)
+	log.DefaultLogger.Infof("[upstream] [outlier detection] host %s is brought back", m.host.AddressString())
+	if d.onHostChanged != nil {
+		d.onHostChanged(m.host)
+	}
+}
+
+func (d *outlierDetector) startTimer() {
+	if atomic.CompareAndSwapUint32(&d.timerRunning, 0, 1) {
+		d.timer = utils.NewTimer(d.config.interval, d.onInterval)
+	}
+}
+
+// onInterval brings back the ejected hosts that the ejection time is expired,
+// and checks the success rate of the hosts.
+func (d *outlierDetector) onInterval() {
+	active := d.checkInterval(time.Now())
+	atomic.StoreUint32(&d.timerRunning, 0)
+	// if no requests is reported and no hosts is ejected, the timer stops until a new result is reported.
+	if active {
+		d.startTimer()
+	}
+}
+
+func (d *outlierDetector) checkInterval(now time.Time) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	active := false
+	for _, m := range d.monitors {
+		if m.ejected {
+			if now.Sub(m.lastEjectionTime) >= m.ejectionDuration(&d.config) {
+				d.unejectHostLocked(m, now)
+			}
+		} else if m.numEjections > 0 && now.Sub(m.lastUnejectTime) >= d.config.baseEjectionTime {
+			// the host behaves well after brought back, decrease the ejection time step by step
+			m.numEjections--
+			m.lastUnejectTime = now
+		}
+		if m.ejected || m.numEjections > 0 {
+			active = true
+		}
+	}
+	if d.checkSuccessRateLocked(now) {
+		active = true
+	}
+	return active
+}
+
+// checkSuccessRateLocked ejects the hosts that success rate is lower than
+// mean - stdev * factor of all the hosts that have enough request volume.
+// returns true if any request is reported in this interval.
+func (d *outlierDetector) checkSuccessRateLocked(now time.Time) bool {
+	hasRequest := false
+	type hostRate struct {
+		m    *hostMonitor
+		rate float64
+	}
+	rates := make([]hostRate, 0, len(d.monitors))
+	var sum float64
+	for _, m := range d.monitors {
+		total := atomic.SwapUint64(&m.requestTotal, 0)
+		success := atomic.SwapUint64(&m.requestSuccess, 0)
+		if total > 0 {
+			hasRequest = true
+		}
+		if m.ejected || total < uint64(d.config.successRateRequestVolume) {
+			continue
+		}
+		rate := float64(success) * 100 / float64(total)
+		rates = append(rates, hostRate{m: m, rate: rate})
+		sum += rate
+	}
+	if len(rates) == 0 || len(rates) < int(d.config.successRateMinimumHosts) {
+		return hasRequest
+	}
+	mean := sum / float64(len(rates))
+	var variance float64
+	for _, r := range rates {
+		variance += (r.rate - mean) * (r.rate - mean)
+	}
+	stdev := math.Sqrt(variance / float64(len(rates)))
+	threshold := mean - stdev*d.config.successRateStdevFactor
+	for _, r := range rates {
+		if r.rate < threshold {
+			d.ejectHostLocked(r.m, ejectSuccessRate, now)
+		}
+	}
+	return hasRequest
+}
@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func newOutlierTestCluster(name string, cfg *v2.OutlierDetection, hostCount int) *simpleCluster {
+	c := newSimpleCluster(v2.Cluster{
+		Name:             name,
+		ClusterType:      v2.SIMPLE_CLUSTER,
+		LbType:           v2.LB_RANDOM,
+		OutlierDetection: cfg,
+	})
+	c.UpdateHosts(newOutlierTestHosts(c, hostCount))
+	return c
+}
+
+func newOutlierTestHosts(c *simpleCluster, count int) []types.Host {
+	var hosts []types.Host
+	for i := 0; i < count; i++ {
+		hosts = append(hosts, NewSimpleHost(v2.Host{
+			HostConfig: v2.HostConfig{
+				Address: fmt.Sprintf("127.0.0.1:%d", 10000+i),
+			},
+		}, c.info))
+	}
+	return hosts
+}
+
+func TestOutlierDetectionConsecutive5xx(t *testing.T) {
+	c := newOutlierTestCluster("outlier_5xx", &v2.OutlierDetection{
+		OutlierDetectionConfig: v2.OutlierDetectionConfig{
+			Consecutive5xx:     3,
+			MaxEjectionPercent: 50,
+		},
+		Interval:         time.Hour, // checked by hand
+		BaseEjectionTime: time.Second,
+	}, 4)
+	detector := c.info.OutlierDetector()
+	if detector == nil {
+		t.Fatal("cluster have no outlier detector")
+	}
+	host := c.hostSet.Hosts()[0]
+	// success resets the consecutive counter
+	detector.PutResult(host, types.OutlierResultServerError)
+	detector.PutResult(host, types.OutlierResultServerError)
+	detector.PutResult(host, types.OutlierResultSuccess)
+	detector.PutResult(host, types.OutlierResultServerError)
+	if !host.Health() {
+		t.Fatal("host should not be ejected")
+	}
+	detector.PutResult(host, types.OutlierResultRequestFailed)
+	detector.PutResult(host, types.OutlierResultServerError)
+	if host.Health() || !host.ContainHealthFlag(types.FAILED_OUTLIER_CHECK) {
+		t.Fatal("host should be ejected")
+	}
+	if len(c.Snapshot().HostSet().HealthyHosts()) != 3 {
+		t.Fatal("ejected host should be removed from healthy hosts")
+	}
+	if c.info.stats.OutlierEjectionsConsecutive5xx.Count() != 1 || c.info.stats.OutlierEjectionsActive.Value() != 1 {
+		t.Fatal("outlier stats not expected")
+	}
+	// brought back after base ejection time
+	d := c.outlierDetector
+	m := d.monitors[host.AddressString()]
+	ejectTime := m.lastEjectionTime
+	d.checkInterval(ejectTime.Add(500 * time.Millisecond))
+	if host.Health() {
+		t.Fatal("host should be ejected in ejection time")
+	}
+	d.checkInterval(ejectTime.Add(time.Second))
+	if !host.Health() || len(c.Snapshot().HostSet().HealthyHosts()) != 4 {
+		t.Fatal("host should be brought back")
+	}
+	// eject again, the ejection time grows
+	for i := 0; i < 3; i++ {
+		detector.PutResult(host, types.OutlierResultServerError)
+	}
+	if host.Health() || m.numEjections != 2 {
+		t.Fatalf("host should be ejected again, ejections: %d", m.numEjections)
+	}
+	ejectTime = m.lastEjectionTime
+	d.checkInterval(ejectTime.Add(time.Second))
+	if host.Health() {
+		t.Fatal("host ejection time should be grown")
+	}
+	d.checkInterval(ejectTime.Add(2 * time.Second))
+	if !host.Health() {
+		t.Fatal("host should be brought back")
+	}
+}
+
+func TestOutlierDetectionConnectFailureAndMaxPercent(t *testing.T) {
+	c := newOutlierTestCluster("outlier_connect", &v2.OutlierDetection{
+		OutlierDetectionConfig: v2.OutlierDetectionConfig{
+			ConsecutiveConnectFailure: 2,
+			MaxEjectionPercent:        50,
+		},
+		Interval: time.Hour,
+	}, 4)
+	detector := c.info.OutlierDetector()
+	for _, host := range c.hostSet.Hosts() {
+		detector.PutResult(host, types.OutlierResultConnectFailed)
+		detector.PutResult(host, types.OutlierResultConnectFailed)
+	}
+	// only 50% hosts can be ejected
+	if len(c.Snapshot().HostSet().HealthyHosts()) != 2 {
+		t.Fatalf("ejected hosts not expected, healthy hosts: %d", len(c.Snapshot().HostSet().HealthyHosts()))
+	}
+	if c.info.stats.OutlierEjectionsConsecutiveConnectFailure.Count() != 2 || c.info.stats.OutlierEjectionsOverflow.Count() != 2 {
+		t.Fatal("outlier stats not expected")
+	}
+	// update hosts keeps the ejected state
+	c.UpdateHosts(newOutlierTestHosts(c, 5))
+	if len(c.Snapshot().HostSet().HealthyHosts()) != 3 {
+		t.Fatalf("ejected hosts should be kept, healthy hosts: %d", len(c.Snapshot().HostSet().HealthyHosts()))
+	}
+}
+
+func TestOutlierDetectionSuccessRate(t *testing.T) {
+	c := newOutlierTestCluster("outlier_success_rate", &v2.OutlierDetection{
+		OutlierDetectionConfig: v2.OutlierDetectionConfig{
+			Consecutive5xx:           1000,
+			MaxEjectionPercent:       50,
+			SuccessRateMinimumHosts:  5,
+			SuccessRateRequestVolume: 10,
+			SuccessRateStdevFactor:   1000,
+		},
+		Interval: time.Hour,
+	}, 6)
+	detector := c.info.OutlierDetector()
+	hosts := c.hostSet.Hosts()
+	for i, host := range hosts {
+		for j := 0; j < 100; j++ {
+			// the last host fails 50%, others fails 1%
+			if (i == len(hosts)-1 && j%2 == 0) || j == 0 {
+				detector.PutResult(host, types.OutlierResultServerError)
+			} else {
+				detector.PutResult(host, types.OutlierResultSuccess)
+			}
+		}
+	}
+	c.outlierDetector.checkInterval(time.Now())
+	for i, host := range hosts {
+		if host.Health() != (i != len(hosts)-1) {
+			t.Fatalf("host %s health state not expected", host.AddressString())
+		}
+	}
+	if c.info.stats.OutlierEjectionsSuccessRate.Count() != 1 {
+		t.Fatal("outlier stats not expected")
+	}
+}
+
+func TestClusterWithoutOutlierDetection(t *testing.T) {
+	c := newSimpleCluster(v2.Cluster{
+		Name:        "no_outlier",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	})
+	if c.info.OutlierDetector() != nil {
+		t.Fatal("cluster should have no outlier detector")
+	}
+}
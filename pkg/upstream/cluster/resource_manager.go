@@ -18,9 +18,13 @@
 package cluster
 
 import (
+	"math"
+	"strings"
 	"sync/atomic"
 
+	gometrics "github.com/rcrowley/go-metrics"
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -32,6 +36,15 @@ const (
 	DefaultMaxRetries         = uint64(3)
 )
 
+// noLimit is the max value of a resource without limit
+const noLimit = uint64(math.MaxUint64)
+
+// routingPriorities maps the types.ResourcePriority to the configured priority
+var routingPriorities = [types.MaxResourcePriority]v2.RoutingPriority{
+	types.DefaultPriority: v2.PRIORITY_DEFAULT,
+	types.HighPriority:    v2.PRIORITY_HIGH,
+}
+
 // ResourceManager
 type resourcemanager struct {
 	connections     *resource
@@ -40,33 +53,72 @@ type resourcemanager struct {
 	retries         *resource
 }
 
+// NewResourceManager creates the ResourceManager of the default routing priority
 func NewResourceManager(circuitBreakers v2.CircuitBreakers) types.ResourceManager {
+	return newResourceManager(findThresholds(circuitBreakers.Thresholds, v2.PRIORITY_DEFAULT), nil)
+}
+
+// newPriorityResourceManagers creates the ResourceManager of each routing priority,
+// the remaining capacity of the resources are recorded in the cluster's priority stats
+func newPriorityResourceManagers(clusterName string, circuitBreakers v2.CircuitBreakers) []types.ResourceManager {
+	managers := make([]types.ResourceManager, types.MaxResourcePriority)
+	for priority, routingPriority := range routingPriorities {
+		stats := metrics.NewClusterPriorityStats(clusterName, strings.ToLower(string(routingPriority)))
+		managers[priority] = newResourceManager(findThresholds(circuitBreakers.Thresholds, routingPriority), stats)
+	}
+	return managers
+}
+
+// findThresholds returns the thresholds of the routing priority, the thresholds without priority
+// is treated as the default priority. returns nil if no thresholds found.
+func findThresholds(thresholds []v2.Thresholds, priority v2.RoutingPriority) *v2.Thresholds {
+	for i := range thresholds {
+		p := thresholds[i].Priority
+		if p == "" {
+			p = v2.PRIORITY_DEFAULT
+		}
+		if strings.EqualFold(string(p), string(priority)) {
+			return &thresholds[i]
+		}
+	}
+	return nil
+}
+
+func newResourceManager(thresholds *v2.Thresholds, stats types.Metrics) types.ResourceManager {
 	maxConnections := DefaultMaxConnections
 	maxPendingRequests := DefaultMaxPendingRequests
 	maxRequests := DefaultMaxRequests
 	maxRetries := DefaultMaxRetries
 
-	// note: we don't support group cb by priority
-	if circuitBreakers.Thresholds != nil && len(circuitBreakers.Thresholds) > 0 {
-		maxConnections = uint64(circuitBreakers.Thresholds[0].MaxConnections)
-		maxPendingRequests = uint64(circuitBreakers.Thresholds[0].MaxPendingRequests)
-		maxRequests = uint64(circuitBreakers.Thresholds[0].MaxRequests)
-		maxRetries = uint64(circuitBreakers.Thresholds[0].MaxRetries)
+	if thresholds != nil {
+		maxConnections = uint64(thresholds.MaxConnections)
+		maxPendingRequests = uint64(thresholds.MaxPendingRequests)
+		maxRequests = uint64(thresholds.MaxRequests)
+		maxRetries = uint64(thresholds.MaxRetries)
+	}
+
+	return &resourcemanager{
+		connections:     newResource(maxConnections, stats, metrics.UpstreamRemainingConnections),
+		pendingRequests: newResource(maxPendingRequests, stats, metrics.UpstreamRemainingPendingRequests),
+		requests:        newResource(maxRequests, stats, metrics.UpstreamRemainingRequests),
+		retries:         newResource(maxRetries, stats, metrics.UpstreamRemainingRetries),
 	}
+}
 
+// newHostResourceManager creates a ResourceManager limits a host's connections and requests,
+// zero in the thresholds means no limit. the pending requests and retries of a host are not limited.
+func newHostResourceManager(thresholds v2.Thresholds, stats types.Metrics) types.ResourceManager {
+	hostLimit := func(max uint32) uint64 {
+		if max == 0 {
+			return noLimit
+		}
+		return uint64(max)
+	}
 	return &resourcemanager{
-		connections: &resource{
-			max: maxConnections,
-		},
-		pendingRequests: &resource{
-			max: maxPendingRequests,
-		},
-		requests: &resource{
-			max: maxRequests,
-		},
-		retries: &resource{
-			max: maxRetries,
-		},
+		connections:     newResource(hostLimit(thresholds.MaxConnections), stats, metrics.UpstreamRemainingConnections),
+		pendingRequests: newResource(noLimit, nil, ""),
+		requests:        newResource(hostLimit(thresholds.MaxRequests), stats, metrics.UpstreamRemainingRequests),
+		retries:         newResource(noLimit, nil, ""),
 	}
 }
 
@@ -90,6 +142,19 @@ func (rm *resourcemanager) Retries() types.Resource {
 type resource struct {
 	current int64
 	max     uint64
+	// remaining records the remaining capacity, nil if the resource is not limited or no stats
+	remaining gometrics.Gauge
+}
+
+func newResource(max uint64, stats types.Metrics, key string) *resource {
+	r := &resource{
+		max: max,
+	}
+	if stats != nil && max != noLimit {
+		r.remaining = stats.Gauge(key)
+		r.remaining.Update(int64(max))
+	}
+	return r
 }
 
 func (r *resource) CanCreate() bool {
@@ -103,13 +168,24 @@ func (r *resource) CanCreate() bool {
 }
 
 func (r *resource) Increase() {
-	atomic.AddInt64(&r.current, 1)
+	r.updateRemaining(atomic.AddInt64(&r.current, 1))
 }
 
 func (r *resource) Decrease() {
-	atomic.AddInt64(&r.current, -1)
+	r.updateRemaining(atomic.AddInt64(&r.current, -1))
 }
 
 func (r *resource) Max() uint64 {
 	return r.max
 }
+
+func (r *resource) updateRemaining(current int64) {
+	if r.remaining == nil {
+		return
+	}
+	remaining := int64(r.max) - current
+	if remaining < 0 {
+		remaining = 0
+	}
+	r.remaining.Update(remaining)
+}
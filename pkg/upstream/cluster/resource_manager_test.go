@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"testing"
+
+	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
+	"sofastack.io/sofa-mosn/pkg/metrics"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+func TestPriorityResourceManager(t *testing.T) {
+	info := newSimpleCluster(v2.Cluster{
+		Name: "priority_resource",
+		CirBreThresholds: v2.CircuitBreakers{
+			Thresholds: []v2.Thresholds{
+				{
+					MaxConnections: 10,
+					MaxRequests:    1,
+				},
+				{
+					Priority:       v2.PRIORITY_HIGH,
+					MaxConnections: 20,
+					MaxRequests:    2,
+				},
+			},
+		},
+	}).Snapshot().ClusterInfo()
+	if info.ResourceManager() != info.PriorityResourceManager(types.DefaultPriority) {
+		t.Fatal("default priority resource manager not expected")
+	}
+	defaultRequests := info.PriorityResourceManager(types.DefaultPriority).Requests()
+	highRequests := info.PriorityResourceManager(types.HighPriority).Requests()
+	if info.PriorityResourceManager(types.DefaultPriority).Connections().Max() != 10 ||
+		info.PriorityResourceManager(types.HighPriority).Connections().Max() != 20 ||
+		defaultRequests.Max() != 1 || highRequests.Max() != 2 {
+		t.Fatal("priority thresholds not expected")
+	}
+	// the default priority is overflow, the high priority is not affected
+	defaultRequests.Increase()
+	if defaultRequests.CanCreate() || !highRequests.CanCreate() {
+		t.Fatal("priority resource is not isolated")
+	}
+	// remaining capacity
+	stats := metrics.NewClusterPriorityStats("priority_resource", "default")
+	if remaining := stats.Gauge(metrics.UpstreamRemainingRequests).Value(); remaining != 0 {
+		t.Fatalf("remaining requests expected 0, but got %d", remaining)
+	}
+	defaultRequests.Decrease()
+	if remaining := stats.Gauge(metrics.UpstreamRemainingRequests).Value(); remaining != 1 {
+		t.Fatalf("remaining requests expected 1, but got %d", remaining)
+	}
+	stats = metrics.NewClusterPriorityStats("priority_resource", "high")
+	if remaining := stats.Gauge(metrics.UpstreamRemainingConnections).Value(); remaining != 20 {
+		t.Fatalf("remaining connections expected 20, but got %d", remaining)
+	}
+}
+
+func TestDefaultResourceManager(t *testing.T) {
+	rm := NewResourceManager(v2.CircuitBreakers{})
+	if rm.Connections().Max() != DefaultMaxConnections ||
+		rm.PendingRequests().Max() != DefaultMaxPendingRequests ||
+		rm.Requests().Max() != DefaultMaxRequests ||
+		rm.Retries().Max() != DefaultMaxRetries {
+		t.Fatal("default resource manager not expected")
+	}
+}
+
+func TestHostResourceManager(t *testing.T) {
+	info := newSimpleCluster(v2.Cluster{
+		Name: "host_resource",
+		CirBreThresholds: v2.CircuitBreakers{
+			PerHostThresholds: []v2.Thresholds{
+				{
+					MaxRequests: 2,
+				},
+			},
+		},
+	}).Snapshot().ClusterInfo()
+	host1 := NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}}, info)
+	host2 := NewSimpleHost(v2.Host{HostConfig: v2.HostConfig{Address: "127.0.0.1:10001"}}, info)
+	// connections is not limited
+	if host1.ResourceManager().Connections().Max() != noLimit {
+		t.Fatal("host connections should not be limited")
+	}
+	requests := host1.ResourceManager().Requests()
+	requests.Increase()
+	requests.Increase()
+	if requests.CanCreate() {
+		t.Fatal("host requests should be overflow")
+	}
+	// other hosts are not affected
+	if !host2.ResourceManager().Requests().CanCreate() {
+		t.Fatal("host resource is not isolated")
+	}
+	stats := metrics.NewHostStats("host_resource", "127.0.0.1:10000")
+	if remaining := stats.Gauge(metrics.UpstreamRemainingRequests).Value(); remaining != 0 {
+		t.Fatalf("remaining requests expected 0, but got %d", remaining)
+	}
+	requests.Decrease()
+	if !requests.CanCreate() {
+		t.Fatal("host requests should not be overflow")
+	}
+}
+
+func TestHostResourceManagerKeptByAddress(t *testing.T) {
+	cluster := newSimpleCluster(v2.Cluster{
+		Name: "host_resource_kept",
+		CirBreThresholds: v2.CircuitBreakers{
+			PerHostThresholds: []v2.Thresholds{
+				{
+					MaxConnections: 1,
+				},
+			},
+		},
+	})
+	info := cluster.Snapshot().ClusterInfo()
+	cfg := v2.Host{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000"}}
+	host := NewSimpleHost(cfg, info)
+	cluster.UpdateHosts([]types.Host{host})
+	host.ResourceManager().Connections().Increase()
+	// the host is rebuilt, such as updated by eds, the connections limit is shared
+	rebuilt := NewSimpleHost(cfg, info)
+	cluster.UpdateHosts([]types.Host{rebuilt})
+	if rebuilt.ResourceManager() != host.ResourceManager() || rebuilt.ResourceManager().Connections().CanCreate() {
+		t.Fatal("rebuilt host should reuse the resource manager")
+	}
+	// the host is removed, the resource manager is removed too
+	cluster.UpdateHosts([]types.Host{})
+	if NewSimpleHost(cfg, info).ResourceManager() == host.ResourceManager() {
+		t.Fatal("removed host's resource manager should not be reused")
+	}
+}
@@ -79,5 +79,13 @@ func newClusterStats(clustername string) types.ClusterStats {
 		UpstreamResponseFailed:                         s.Counter(metrics.UpstreamResponseFailed),
 		LBSubSetsFallBack:                              s.Counter(metrics.UpstreamLBSubSetsFallBack),
 		LBSubsetsCreated:                               s.Gauge(metrics.UpstreamLBSubsetsCreated),
+		OutlierEjectionsTotal:                          s.Counter(metrics.UpstreamOutlierEjectionsTotal),
+		OutlierEjectionsActive:                         s.Gauge(metrics.UpstreamOutlierEjectionsActive),
+		OutlierEjectionsOverflow:                       s.Counter(metrics.UpstreamOutlierEjectionsOverflow),
+		OutlierEjectionsConsecutive5xx:                 s.Counter(metrics.UpstreamOutlierEjectionsConsecutive5xx),
+		OutlierEjectionsConsecutiveConnectFailure:      s.Counter(metrics.UpstreamOutlierEjectionsConsecutiveConnectFailure),
+		OutlierEjectionsSuccessRate:                    s.Counter(metrics.UpstreamOutlierEjectionsSuccessRate),
+		UpstreamRequestShadow:                          s.Counter(metrics.UpstreamRequestShadow),
+		UpstreamRequestShadowFailed:                    s.Counter(metrics.UpstreamRequestShadowFailed),
 	}
 }
@@ -101,6 +101,7 @@ func createHostset(cfg []v2.Host) *hostSet {
 			name: h.Hostname,
 			addr: h.Address,
 			meta: h.MetaData,
+			w:    h.Weight,
 		}
 		hosts = append(hosts, host)
 	}
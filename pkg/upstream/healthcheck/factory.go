@@ -22,11 +22,24 @@ import (
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
+// The health check protocols of the sessions registered by default.
+// They are different from the upstream protocols, so the health check configured with
+// an upstream protocol such as SofaRpc still uses the TCPDial session.
+const (
+	HTTPHealthCheck    types.Protocol = "HttpHealthCheck"
+	GRPCHealthCheck    types.Protocol = "GrpcHealthCheck"
+	SofaRPCHealthCheck types.Protocol = "SofaRpcHealthCheck"
+)
+
 var sessionFactories map[types.Protocol]types.HealthCheckSessionFactory
 
 func init() {
 	sessionFactories = make(map[types.Protocol]types.HealthCheckSessionFactory)
 	commonCallbacks = make(map[string]types.HealthCheckCb)
+	// register the default health check session factories
+	RegisterSessionFactory(HTTPHealthCheck, &HTTPSessionFactory{})
+	RegisterSessionFactory(GRPCHealthCheck, &GRPCSessionFactory{})
+	RegisterSessionFactory(SofaRPCHealthCheck, &SofaRPCSessionFactory{})
 }
 
 func RegisterSessionFactory(p types.Protocol, f types.HealthCheckSessionFactory) {
@@ -35,7 +48,8 @@ func RegisterSessionFactory(p types.Protocol, f types.HealthCheckSessionFactory)
 
 // CreateHealthCheck is a extendable function that can create different health checker
 // by different health check session.
-// The Default session is TCPDial session
+// The Default session is TCPDial session, the http, grpc(grpc health checking protocol) and sofarpc
+// sessions are registered by default, see HTTPHealthCheck, GRPCHealthCheck and SofaRPCHealthCheck
 func CreateHealthCheck(cfg v2.HealthCheck) types.HealthChecker {
 	f, ok := sessionFactories[types.Protocol(cfg.Protocol)]
 	if !ok {
@@ -0,0 +1,217 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/module/http2"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+// grpc health checking protocol, see https://github.com/grpc/grpc/blob/master/doc/health-checking.md
+const (
+	grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+	// HealthCheckResponse_SERVING in grpc.health.v1
+	grpcHealthServing = 1
+	// length-prefixed message header: 1 byte compressed flag and 4 bytes message length
+	grpcMessageHeaderLen = 5
+)
+
+var errGRPCInvalidMessage = errors.New("invalid grpc health check response message")
+
+// GRPCCheckConfig is the session config of grpc health check
+type GRPCCheckConfig struct {
+	// ServiceName is the service to check, empty means check the server's overall health
+	ServiceName string `json:"service_name,omitempty"`
+	Authority   string `json:"authority,omitempty"`
+}
+
+type GRPCSessionFactory struct{}
+
+func (f *GRPCSessionFactory) NewSession(cfg map[string]interface{}, host types.Host) types.HealthCheckSession {
+	grpcCfg := &GRPCCheckConfig{}
+	if err := parseSessionConfig(cfg, grpcCfg); err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [grpc session] parse config failed: %v", err)
+		return nil
+	}
+	return &GRPCSession{
+		addr:   host.AddressString(),
+		config: grpcCfg,
+		// the transport keeps a h2c connection to the host
+		transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.DialTimeout(network, addr, defaultSessionTimeout)
+			},
+		},
+	}
+}
+
+type GRPCSession struct {
+	addr      string
+	config    *GRPCCheckConfig
+	transport *http2.Transport
+	mutex     sync.Mutex
+	cancel    context.CancelFunc
+}
+
+func (s *GRPCSession) CheckHealth() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSessionTimeout)
+	s.setCancel(cancel)
+	defer s.setCancel(nil)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, "http://"+s.addr+grpcHealthCheckPath, bytes.NewReader(encodeGRPCHealthCheckRequest(s.config.ServiceName)))
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [grpc session] create request for host %s error: %v", s.addr, err)
+		return false
+	}
+	req = req.WithContext(ctx)
+	if s.config.Authority != "" {
+		req.Host = s.config.Authority
+	}
+	req.Header.Set("content-type", "application/grpc")
+	req.Header.Set("te", "trailers")
+	resp, err := s.transport.RoundTrip(req)
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [grpc session] request host %s error: %v", s.addr, err)
+		return false
+	}
+	defer closeResponseBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		log.DefaultLogger.Errorf("[upstream] [health check] [grpc session] host %s response unexpected status: %d", s.addr, resp.StatusCode)
+		return false
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodyMatchLength))
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [grpc session] read host %s response error: %v", s.addr, err)
+		return false
+	}
+	// grpc-status is in the trailers, or in the headers for trailers-only response
+	grpcStatus := resp.Trailer.Get("grpc-status")
+	if grpcStatus == "" {
+		grpcStatus = resp.Header.Get("grpc-status")
+	}
+	if grpcStatus != "0" {
+		log.DefaultLogger.Errorf("[upstream] [health check] [grpc session] host %s response grpc-status: %s", s.addr, grpcStatus)
+		return false
+	}
+	status, err := decodeGRPCHealthCheckResponse(body)
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [grpc session] decode host %s response error: %v", s.addr, err)
+		return false
+	}
+	return status == grpcHealthServing
+}
+
+// Close closes the connections when the session is stopped
+func (s *GRPCSession) Close() error {
+	// cancel the running request, so its connection will not be left in the transport
+	s.OnTimeout()
+	s.transport.CloseIdleConnections()
+	return nil
+}
+
+// OnTimeout cancels the running request
+func (s *GRPCSession) OnTimeout() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+func (s *GRPCSession) setCancel(cancel context.CancelFunc) {
+	s.mutex.Lock()
+	s.cancel = cancel
+	s.mutex.Unlock()
+}
+
+// encodeGRPCHealthCheckRequest encodes a length-prefixed grpc.health.v1.HealthCheckRequest message
+// message HealthCheckRequest { string service = 1; }
+func encodeGRPCHealthCheckRequest(service string) []byte {
+	msg := make([]byte, 0, len(service)+binary.MaxVarintLen64+1)
+	if service != "" {
+		msg = append(msg, 0x0a) // field 1, wire type 2
+		msg = appendVarint(msg, uint64(len(service)))
+		msg = append(msg, service...)
+	}
+	data := make([]byte, grpcMessageHeaderLen, grpcMessageHeaderLen+len(msg))
+	binary.BigEndian.PutUint32(data[1:], uint32(len(msg)))
+	return append(data, msg...)
+}
+
+// decodeGRPCHealthCheckResponse decodes a length-prefixed grpc.health.v1.HealthCheckResponse message
+// message HealthCheckResponse { ServingStatus status = 1; }
+func decodeGRPCHealthCheckResponse(data []byte) (uint64, error) {
+	if len(data) < grpcMessageHeaderLen || data[0] != 0 {
+		// compressed message is not supported
+		return 0, errGRPCInvalidMessage
+	}
+	length := binary.BigEndian.Uint32(data[1:grpcMessageHeaderLen])
+	msg := data[grpcMessageHeaderLen:]
+	if uint32(len(msg)) < length {
+		return 0, errGRPCInvalidMessage
+	}
+	msg = msg[:length]
+	var status uint64
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, errGRPCInvalidMessage
+		}
+		msg = msg[n:]
+		switch key & 0x7 {
+		case 0: // varint
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, errGRPCInvalidMessage
+			}
+			msg = msg[n:]
+			if key>>3 == 1 {
+				status = v
+			}
+		case 2: // length-delimited, skip unknown fields
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < l {
+				return 0, errGRPCInvalidMessage
+			}
+			msg = msg[n+int(l):]
+		default:
+			return 0, errGRPCInvalidMessage
+		}
+	}
+	return status, nil
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
@@ -0,0 +1,150 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"sofastack.io/sofa-mosn/pkg/module/http2"
+)
+
+type mockGRPCHealthServer struct {
+	listener net.Listener
+	status   map[string]uint64
+}
+
+func newMockGRPCHealthServer(t *testing.T, status map[string]uint64) *mockGRPCHealthServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("listen failed", err)
+	}
+	s := &mockGRPCHealthServer{
+		listener: ln,
+		status:   status,
+	}
+	srv := &http2.Server{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeConn(conn, &http2.ServeConnOpts{
+				Handler: http.HandlerFunc(s.ServeHTTP),
+			})
+		}
+	}()
+	return s
+}
+
+func (s *mockGRPCHealthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.URL.Path != grpcHealthCheckPath || len(body) < grpcMessageHeaderLen {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	// the request message only contains the service name
+	service := ""
+	if len(body) > grpcMessageHeaderLen+2 {
+		service = string(body[grpcMessageHeaderLen+2:])
+	}
+	w.Header().Set("content-type", "application/grpc")
+	status, ok := s.status[service]
+	if !ok {
+		// trailers-only response with NOT_FOUND
+		w.Header().Set("grpc-status", "5")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Trailer", "grpc-status")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte{0, 0, 0, 0, 2, 0x08, byte(status)})
+	w.Header().Set("grpc-status", "0")
+}
+
+func (s *mockGRPCHealthServer) Close() {
+	s.listener.Close()
+}
+
+func TestGRPCSession(t *testing.T) {
+	s := newMockGRPCHealthServer(t, map[string]uint64{
+		"":         grpcHealthServing,
+		"serving":  grpcHealthServing,
+		"stopping": 2, // NOT_SERVING
+	})
+	defer s.Close()
+	host := &mockHost{
+		addr: s.listener.Addr().String(),
+	}
+	factory := &GRPCSessionFactory{}
+	testCases := []struct {
+		service string
+		healthy bool
+	}{
+		{"", true},
+		{"serving", true},
+		{"stopping", false},
+		{"unknown", false},
+	}
+	for _, tc := range testCases {
+		session := factory.NewSession(map[string]interface{}{
+			"service_name": tc.service,
+		}, host)
+		if session == nil {
+			t.Fatalf("create grpc session for service %s failed", tc.service)
+		}
+		if session.CheckHealth() != tc.healthy {
+			t.Errorf("grpc check service %s health expected %v", tc.service, tc.healthy)
+		}
+	}
+	session := factory.NewSession(nil, host)
+	s.Close()
+	if session.CheckHealth() {
+		t.Error("grpc check a closed server, but returns ok")
+	}
+}
+
+func TestGRPCHealthCheckMessage(t *testing.T) {
+	req := encodeGRPCHealthCheckRequest("test")
+	expected := []byte{0, 0, 0, 0, 6, 0x0a, 4, 't', 'e', 's', 't'}
+	if string(req) != string(expected) {
+		t.Errorf("encode grpc health check request unexpected: %v", req)
+	}
+	if req := encodeGRPCHealthCheckRequest(""); len(req) != grpcMessageHeaderLen {
+		t.Errorf("encode grpc health check request with empty service unexpected: %v", req)
+	}
+	// unknown fields should be skipped
+	status, err := decodeGRPCHealthCheckResponse([]byte{0, 0, 0, 0, 5, 0x12, 1, 'a', 0x08, 1})
+	if err != nil || status != grpcHealthServing {
+		t.Errorf("decode grpc health check response unexpected, status: %d, error: %v", status, err)
+	}
+	// invalid messages
+	for _, data := range [][]byte{
+		{0, 0, 0},
+		{1, 0, 0, 0, 2, 0x08, 1},
+		{0, 0, 0, 0, 3, 0x08, 1},
+		{0, 0, 0, 0, 2, 0x12, 5},
+	} {
+		if _, err := decodeGRPCHealthCheckResponse(data); err == nil {
+			t.Errorf("decode invalid message %v, but no error", data)
+		}
+	}
+}
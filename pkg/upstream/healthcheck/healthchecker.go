@@ -25,6 +25,7 @@ import (
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/types"
 	"sofastack.io/sofa-mosn/pkg/utils"
 )
@@ -38,6 +39,7 @@ const (
 // we use different implementations of types.Session to implement different health checker
 type healthChecker struct {
 	//
+	serviceName         string
 	sessionConfig       map[string]interface{}
 	sessionFactory      types.HealthCheckSessionFactory
 	mutex               sync.Mutex
@@ -66,6 +68,7 @@ func newHealthChecker(cfg v2.HealthCheck, f types.HealthCheckSessionFactory) typ
 	}
 	hc := &healthChecker{
 		// cfg
+		serviceName:        cfg.ServiceName,
 		sessionConfig:      cfg.SessionConfig,
 		timeout:            timeout,
 		intervalBase:       interval,
@@ -108,6 +111,7 @@ func (hc *healthChecker) Stop() {
 	hc.mutex.Lock()
 	defer hc.mutex.Unlock()
 	hc.stop()
+	hc.deleteHostStats(hc.hosts, nil)
 }
 
 func (hc *healthChecker) stop() {
@@ -125,8 +129,23 @@ func (hc *healthChecker) SetHealthCheckerHostSet(hostSet types.HostSet) {
 	hc.mutex.Lock()
 	defer hc.mutex.Unlock()
 	hc.stop()
+	oldHosts := hc.hosts
 	hc.hosts = hostSet.Hosts()
 	hc.start()
+	hc.deleteHostStats(oldHosts, hc.hosts)
+}
+
+// deleteHostStats deletes the stats of the hosts that are not in the current hosts
+func (hc *healthChecker) deleteHostStats(hosts []types.Host, current []types.Host) {
+	addrs := make(map[string]struct{}, len(current))
+	for _, h := range current {
+		addrs[h.AddressString()] = struct{}{}
+	}
+	for _, h := range hosts {
+		if _, ok := addrs[h.AddressString()]; !ok {
+			metrics.DeleteHostHealthStats(hc.serviceName, h.AddressString())
+		}
+	}
 }
 
 func (hc *healthChecker) startCheck(host types.Host) {
@@ -19,12 +19,14 @@ package healthcheck
 
 import (
 	"fmt"
+	"reflect"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	v2 "sofastack.io/sofa-mosn/pkg/api/v2"
 	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/metrics"
 	"sofastack.io/sofa-mosn/pkg/types"
 )
 
@@ -214,3 +216,88 @@ func TestHealthCheck(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateHealthCheckSessionFactory(t *testing.T) {
+	testCases := []struct {
+		protocol string
+		factory  types.HealthCheckSessionFactory
+	}{
+		// the upstream protocols use the tcp dial session
+		{"SofaRpc", &TCPDialSessionFactory{}},
+		{"Http1", &TCPDialSessionFactory{}},
+		{"Http2", &TCPDialSessionFactory{}},
+		{string(HTTPHealthCheck), &HTTPSessionFactory{}},
+		{string(GRPCHealthCheck), &GRPCSessionFactory{}},
+		{string(SofaRPCHealthCheck), &SofaRPCSessionFactory{}},
+	}
+	for i, tc := range testCases {
+		hc := CreateHealthCheck(v2.HealthCheck{
+			HealthCheckConfig: v2.HealthCheckConfig{
+				Protocol: tc.protocol,
+			},
+		})
+		f := hc.(*healthChecker).sessionFactory
+		if reflect.TypeOf(f) != reflect.TypeOf(tc.factory) {
+			t.Errorf("#%d protocol %s expected session factory %T, but got %T", i, tc.protocol, tc.factory, f)
+		}
+	}
+}
+
+func hasHostHealthStats(serviceName, host string) bool {
+	for _, m := range metrics.GetAll() {
+		labels := m.Labels()
+		if m.Type() == metrics.HealthCheckType && labels["service"] == serviceName && labels["host"] == host {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHealthCheckUpdateHosts(t *testing.T) {
+	factory := &mockClosableSessionFactory{
+		closed: map[string]int{},
+	}
+	RegisterSessionFactory(types.Protocol("test_closable"), factory)
+	cfg := v2.HealthCheck{
+		HealthCheckConfig: v2.HealthCheckConfig{
+			Protocol:           "test_closable",
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+			ServiceName:        "test_update_hosts",
+		},
+		Interval: 100 * time.Millisecond,
+	}
+	host1 := &mockHost{addr: "test_update_host1", status: true}
+	host2 := &mockHost{addr: "test_update_host2", status: true}
+	hc := CreateHealthCheck(cfg)
+	hc.SetHealthCheckerHostSet(&mockHostSet{
+		hosts: []types.Host{host1, host2},
+	})
+	time.Sleep(300 * time.Millisecond)
+	if !hasHostHealthStats("test_update_hosts", host1.addr) || !hasHostHealthStats("test_update_hosts", host2.addr) {
+		t.Fatal("host health stats not found")
+	}
+	// remove host1
+	hc.SetHealthCheckerHostSet(&mockHostSet{
+		hosts: []types.Host{host2},
+	})
+	time.Sleep(100 * time.Millisecond)
+	if hasHostHealthStats("test_update_hosts", host1.addr) {
+		t.Error("removed host's stats should be deleted")
+	}
+	if !hasHostHealthStats("test_update_hosts", host2.addr) {
+		t.Error("host2's stats should not be deleted")
+	}
+	// the old sessions are closed when the checkers are stopped
+	if factory.closedCount(host1.addr) != 1 || factory.closedCount(host2.addr) != 1 {
+		t.Errorf("sessions are not closed, host1: %d, host2: %d", factory.closedCount(host1.addr), factory.closedCount(host2.addr))
+	}
+	hc.Stop()
+	time.Sleep(100 * time.Millisecond)
+	if factory.closedCount(host2.addr) != 2 {
+		t.Errorf("session is not closed when stop, host2: %d", factory.closedCount(host2.addr))
+	}
+	if hasHostHealthStats("test_update_hosts", host2.addr) {
+		t.Error("host stats should be deleted when stop")
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+const (
+	defaultHTTPCheckPath = "/"
+	// defaultSessionTimeout is used to make sure a session will not be blocked forever,
+	// maybe already timeout by checker
+	defaultSessionTimeout = 30 * time.Second
+	// max bytes of the response body that can be used to match
+	maxBodyMatchLength = 1 << 16
+)
+
+// StatusRange represents a status code range [Start, End)
+type StatusRange struct {
+	Start int `json:"start,omitempty"`
+	End   int `json:"end,omitempty"`
+}
+
+// HTTPCheckConfig is the session config of http health check
+type HTTPCheckConfig struct {
+	Path             string        `json:"path,omitempty"`
+	Host             string        `json:"host,omitempty"`
+	ExpectedStatuses []StatusRange `json:"expected_statuses,omitempty"`
+	// BodyMatch is a substring that the response body must contain, ignored if empty
+	BodyMatch string `json:"body_match,omitempty"`
+}
+
+// parseSessionConfig parses the session config into the specified struct
+func parseSessionConfig(cfg map[string]interface{}, v interface{}) error {
+	if cfg == nil {
+		return nil
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+type HTTPSessionFactory struct{}
+
+func (f *HTTPSessionFactory) NewSession(cfg map[string]interface{}, host types.Host) types.HealthCheckSession {
+	httpCfg := &HTTPCheckConfig{}
+	if err := parseSessionConfig(cfg, httpCfg); err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [http session] parse config failed: %v", err)
+		return nil
+	}
+	if httpCfg.Path == "" {
+		httpCfg.Path = defaultHTTPCheckPath
+	}
+	if len(httpCfg.ExpectedStatuses) == 0 {
+		httpCfg.ExpectedStatuses = []StatusRange{{Start: http.StatusOK, End: http.StatusOK + 1}}
+	}
+	// each session has its own transport, so the connections can be closed with the session
+	transport := &http.Transport{}
+	return &HTTPSession{
+		addr:      host.AddressString(),
+		config:    httpCfg,
+		transport: transport,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   defaultSessionTimeout,
+			// health check should not follow the redirect
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+type HTTPSession struct {
+	addr      string
+	config    *HTTPCheckConfig
+	transport *http.Transport
+	client    *http.Client
+	mutex     sync.Mutex
+	cancel    context.CancelFunc
+}
+
+func (s *HTTPSession) CheckHealth() bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.setCancel(cancel)
+	defer s.setCancel(nil)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+s.addr+s.config.Path, nil)
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [http session] create request for host %s error: %v", s.addr, err)
+		return false
+	}
+	req = req.WithContext(ctx)
+	if s.config.Host != "" {
+		req.Host = s.config.Host
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [http session] request host %s error: %v", s.addr, err)
+		return false
+	}
+	defer closeResponseBody(resp)
+	if !s.expectedStatus(resp.StatusCode) {
+		log.DefaultLogger.Errorf("[upstream] [health check] [http session] host %s response unexpected status: %d", s.addr, resp.StatusCode)
+		return false
+	}
+	if s.config.BodyMatch != "" {
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodyMatchLength))
+		if err != nil || !strings.Contains(string(body), s.config.BodyMatch) {
+			log.DefaultLogger.Errorf("[upstream] [health check] [http session] host %s response body is not matched", s.addr)
+			return false
+		}
+	}
+	return true
+}
+
+// Close closes the connections when the session is stopped
+func (s *HTTPSession) Close() error {
+	// cancel the running request, so its connection will not be left in the transport
+	s.OnTimeout()
+	s.transport.CloseIdleConnections()
+	return nil
+}
+
+// closeResponseBody drains the body before close it, so the connection can be reused
+func closeResponseBody(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// OnTimeout cancels the running request
+func (s *HTTPSession) OnTimeout() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+func (s *HTTPSession) setCancel(cancel context.CancelFunc) {
+	s.mutex.Lock()
+	s.cancel = cancel
+	s.mutex.Unlock()
+}
+
+func (s *HTTPSession) expectedStatus(code int) bool {
+	for _, r := range s.config.ExpectedStatuses {
+		if code >= r.Start && code < r.End {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPSession(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("root"))
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "health.example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+	host := &mockHost{
+		addr: strings.Split(s.URL, "http://")[1],
+	}
+	factory := &HTTPSessionFactory{}
+	testCases := []struct {
+		cfg     map[string]interface{}
+		healthy bool
+	}{
+		// default config
+		{
+			cfg:     nil,
+			healthy: true,
+		},
+		{
+			cfg: map[string]interface{}{
+				"body_match": "root",
+			},
+			healthy: true,
+		},
+		{
+			cfg: map[string]interface{}{
+				"body_match": "not matched",
+			},
+			healthy: false,
+		},
+		{
+			cfg: map[string]interface{}{
+				"path": "/health",
+				"host": "health.example.com",
+				"expected_statuses": []interface{}{
+					map[string]interface{}{"start": 200, "end": 300},
+				},
+			},
+			healthy: true,
+		},
+		// 204 is not expected by default
+		{
+			cfg: map[string]interface{}{
+				"path": "/health",
+				"host": "health.example.com",
+			},
+			healthy: false,
+		},
+		{
+			cfg: map[string]interface{}{
+				"path": "/fail",
+			},
+			healthy: false,
+		},
+	}
+	for i, tc := range testCases {
+		session := factory.NewSession(tc.cfg, host)
+		if session == nil {
+			t.Fatalf("#%d create http session failed", i)
+		}
+		if session.CheckHealth() != tc.healthy {
+			t.Errorf("#%d http check health expected %v", i, tc.healthy)
+		}
+	}
+	// invalid config
+	if session := factory.NewSession(map[string]interface{}{"path": 1}, host); session != nil {
+		t.Error("create http session with invalid config, but returns a session")
+	}
+	// server closed
+	session := factory.NewSession(nil, host)
+	s.Close()
+	if session.CheckHealth() {
+		t.Error("http check a closed server, but returns ok")
+	}
+}
+
+func TestHTTPSessionClose(t *testing.T) {
+	var closed int32
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body is drained before close"))
+	}))
+	s.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			atomic.AddInt32(&closed, 1)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	host := &mockHost{
+		addr: strings.Split(s.URL, "http://")[1],
+	}
+	session := (&HTTPSessionFactory{}).NewSession(nil, host)
+	// the connection is reused
+	for i := 0; i < 3; i++ {
+		if !session.CheckHealth() {
+			t.Fatal("http check health failed")
+		}
+	}
+	if atomic.LoadInt32(&closed) != 0 {
+		t.Fatal("connection is closed before the session is closed")
+	}
+	session.(io.Closer).Close()
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&closed) != 1 {
+		t.Errorf("expected one connection closed, but got %d", atomic.LoadInt32(&closed))
+	}
+}
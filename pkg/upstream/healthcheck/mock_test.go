@@ -46,6 +46,37 @@ func (s *mockSession) CheckHealth() bool {
 }
 func (s *mockSession) OnTimeout() {}
 
+// use a mock closable session factory to record the closed sessions
+type mockClosableSessionFactory struct {
+	mutex  sync.Mutex
+	closed map[string]int
+}
+
+func (f *mockClosableSessionFactory) NewSession(cfg map[string]interface{}, host types.Host) types.HealthCheckSession {
+	return &mockClosableSession{
+		mockSession: mockSession{host},
+		factory:     f,
+	}
+}
+
+func (f *mockClosableSessionFactory) closedCount(addr string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.closed[addr]
+}
+
+type mockClosableSession struct {
+	mockSession
+	factory *mockClosableSessionFactory
+}
+
+func (s *mockClosableSession) Close() error {
+	s.factory.mutex.Lock()
+	s.factory.closed[s.host.AddressString()]++
+	s.factory.mutex.Unlock()
+	return nil
+}
+
 type mockCluster struct {
 	types.Cluster
 	hs *mockHostSet
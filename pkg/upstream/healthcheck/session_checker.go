@@ -18,6 +18,7 @@
 package healthcheck
 
 import (
+	"io"
 	"runtime/debug"
 	"sync/atomic"
 
@@ -31,6 +32,7 @@ type sessionChecker struct {
 	Session       types.HealthCheckSession
 	Host          types.Host
 	HealthChecker *healthChecker
+	stats         *hostHealthCheckStats
 	//
 	resp          chan checkResponse
 	timeout       chan bool
@@ -52,6 +54,7 @@ func newChecker(s types.HealthCheckSession, h types.Host, hc *healthChecker) *se
 		Session:       s,
 		Host:          h,
 		HealthChecker: hc,
+		stats:         newHostHealthCheckStats(hc.serviceName, h.AddressString()),
 		resp:          make(chan checkResponse),
 		timeout:       make(chan bool),
 		stop:          make(chan struct{}),
@@ -67,6 +70,10 @@ func (c *sessionChecker) Start() {
 		// stop all the timer when start is finished
 		c.checkTimer.Stop()
 		c.checkTimeout.Stop()
+		// release the session's resources, such as the connections
+		if closer, ok := c.Session.(io.Closer); ok {
+			closer.Close()
+		}
 	}()
 	interval := c.HealthChecker.getCheckInterval()
 	c.checkTimer = utils.NewTimer(interval, c.OnCheck)
@@ -128,6 +135,8 @@ func (c *sessionChecker) HandleSuccess() {
 			c.Host.ClearHealthFlag(types.FAILED_ACTIVE_HC)
 		}
 	}
+	c.stats.success.Inc(1)
+	c.updateHostHealthy()
 	c.HealthChecker.incHealthy(c.Host, changed)
 }
 
@@ -142,13 +151,30 @@ func (c *sessionChecker) HandleFailure(reason types.FailureType) {
 			c.Host.SetHealthFlag(types.FAILED_ACTIVE_HC)
 		}
 	}
+	c.stats.failure.Inc(1)
+	switch reason {
+	case types.FailureActive:
+		c.stats.activeFailure.Inc(1)
+	case types.FailureNetwork:
+		c.stats.networkFailure.Inc(1)
+	}
+	c.updateHostHealthy()
 	c.HealthChecker.decHealthy(c.Host, reason, changed)
 }
 
+func (c *sessionChecker) updateHostHealthy() {
+	if c.Host.ContainHealthFlag(types.FAILED_ACTIVE_HC) {
+		c.stats.healthy.Update(0)
+	} else {
+		c.stats.healthy.Update(1)
+	}
+}
+
 func (c *sessionChecker) OnCheck() {
 	// record current id
 	id := atomic.LoadUint64(&c.checkID)
 	c.HealthChecker.stats.attempt.Inc(1)
+	c.stats.attempt.Inc(1)
 	// start a timeout before check health
 	c.checkTimeout.Stop()
 	c.checkTimeout = utils.NewTimer(c.HealthChecker.timeout, c.OnTimeout)
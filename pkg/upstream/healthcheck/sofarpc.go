@@ -0,0 +1,168 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/log"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	"sofastack.io/sofa-mosn/pkg/types"
+)
+
+var (
+	errHeartbeatUnexpected = errors.New("unexpected heartbeat response")
+	errHeartbeatFailed     = errors.New("heartbeat response status is not success")
+)
+
+// SofaRPCCheckConfig is the session config of sofarpc health check
+type SofaRPCCheckConfig struct {
+	// ProtocolCode is the sub protocol used to send heartbeat, default is bolt v1
+	ProtocolCode byte `json:"protocol_code,omitempty"`
+}
+
+type SofaRPCSessionFactory struct{}
+
+func (f *SofaRPCSessionFactory) NewSession(cfg map[string]interface{}, host types.Host) types.HealthCheckSession {
+	rpcCfg := &SofaRPCCheckConfig{}
+	if err := parseSessionConfig(cfg, rpcCfg); err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [sofarpc session] parse config failed: %v", err)
+		return nil
+	}
+	if rpcCfg.ProtocolCode == 0 {
+		rpcCfg.ProtocolCode = sofarpc.PROTOCOL_CODE_V1
+	}
+	// make sure the heartbeat builder is registered
+	if sofarpc.NewHeartbeat(rpcCfg.ProtocolCode) == nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [sofarpc session] no heartbeat builder for protocol code %d", rpcCfg.ProtocolCode)
+		return nil
+	}
+	return &SofaRPCSession{
+		addr:         host.AddressString(),
+		protocolCode: rpcCfg.ProtocolCode,
+	}
+}
+
+// SofaRPCSession sends a heartbeat command on a long connection,
+// and checks the heartbeat ack's response status
+type SofaRPCSession struct {
+	addr         string
+	protocolCode byte
+	requestID    uint64
+	mutex        sync.Mutex
+	conn         net.Conn
+}
+
+func (s *SofaRPCSession) CheckHealth() bool {
+	conn, err := s.getConn()
+	if err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [sofarpc session] dial tcp for host %s error: %v", s.addr, err)
+		return false
+	}
+	if err := s.heartbeat(conn); err != nil {
+		log.DefaultLogger.Errorf("[upstream] [health check] [sofarpc session] heartbeat for host %s error: %v", s.addr, err)
+		s.closeConn(conn)
+		return false
+	}
+	return true
+}
+
+// OnTimeout closes the connection, the blocked heartbeat will returns an error
+func (s *SofaRPCSession) OnTimeout() {
+	s.mutex.Lock()
+	conn := s.conn
+	s.mutex.Unlock()
+	if conn != nil {
+		s.closeConn(conn)
+	}
+}
+
+// Close closes the connection when the session is stopped
+func (s *SofaRPCSession) Close() error {
+	s.OnTimeout()
+	return nil
+}
+
+func (s *SofaRPCSession) heartbeat(conn net.Conn) error {
+	ctx := buffer.NewBufferPoolContext(context.Background())
+	defer buffer.PoolContext(ctx).Give()
+
+	hb := sofarpc.NewHeartbeat(s.protocolCode)
+	reqID := atomic.AddUint64(&s.requestID, 1)
+	hb.SetRequestID(reqID)
+	data, err := sofarpc.Engine().Encode(ctx, hb)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(defaultSessionTimeout))
+	if _, err := conn.Write(data.Bytes()); err != nil {
+		return err
+	}
+	buf := buffer.NewIoBuffer(1024)
+	for {
+		if _, err := buf.ReadOnce(conn); err != nil {
+			return err
+		}
+		cmd, err := sofarpc.Engine().Decode(ctx, buf)
+		if err != nil {
+			return err
+		}
+		if cmd == nil {
+			// not enough data
+			continue
+		}
+		resp, ok := cmd.(sofarpc.SofaRpcCmd)
+		if !ok || resp.CommandType() != sofarpc.RESPONSE || resp.RequestID() != reqID {
+			return errHeartbeatUnexpected
+		}
+		if status, ok := cmd.(rpc.RespStatus); !ok || int16(status.RespStatus()) != sofarpc.RESPONSE_STATUS_SUCCESS {
+			return errHeartbeatFailed
+		}
+		return nil
+	}
+}
+
+func (s *SofaRPCSession) getConn() (net.Conn, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	conn, err := net.DialTimeout("tcp", s.addr, defaultSessionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+func (s *SofaRPCSession) closeConn(conn net.Conn) {
+	s.mutex.Lock()
+	if s.conn == conn {
+		s.conn = nil
+	}
+	s.mutex.Unlock()
+	conn.Close()
+}
@@ -0,0 +1,156 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/buffer"
+	"sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc"
+	_ "sofastack.io/sofa-mosn/pkg/protocol/rpc/sofarpc/codec"
+)
+
+// mockBoltServer replies the heartbeat with the configured response status
+type mockBoltServer struct {
+	listener net.Listener
+	status   int16
+}
+
+func newMockBoltServer(t *testing.T, status int16) *mockBoltServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("listen failed", err)
+	}
+	s := &mockBoltServer{
+		listener: ln,
+		status:   status,
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *mockBoltServer) serve(conn net.Conn) {
+	defer conn.Close()
+	buf := buffer.NewIoBuffer(1024)
+	for {
+		if _, err := buf.ReadOnce(conn); err != nil {
+			return
+		}
+		ctx := buffer.NewBufferPoolContext(context.Background())
+		cmd, err := sofarpc.Engine().Decode(ctx, buf)
+		if err != nil {
+			return
+		}
+		req, ok := cmd.(sofarpc.SofaRpcCmd)
+		if !ok || req.CommandCode() != sofarpc.HEARTBEAT {
+			continue
+		}
+		ack := sofarpc.NewHeartbeatAck(req.ProtocolCode())
+		ack.(*sofarpc.BoltResponse).ResponseStatus = s.status
+		ack.SetRequestID(req.RequestID())
+		data, err := sofarpc.Engine().Encode(ctx, ack)
+		if err != nil {
+			return
+		}
+		conn.Write(data.Bytes())
+	}
+}
+
+func (s *mockBoltServer) Close() {
+	s.listener.Close()
+}
+
+func TestSofaRPCSession(t *testing.T) {
+	factory := &SofaRPCSessionFactory{}
+	// heartbeat success
+	s := newMockBoltServer(t, sofarpc.RESPONSE_STATUS_SUCCESS)
+	host := &mockHost{
+		addr: s.listener.Addr().String(),
+	}
+	session := factory.NewSession(nil, host)
+	if session == nil {
+		t.Fatal("create sofarpc session failed")
+	}
+	// the connection is reused
+	for i := 0; i < 3; i++ {
+		if !session.CheckHealth() {
+			t.Errorf("#%d sofarpc check health failed", i)
+		}
+	}
+	s.Close()
+	// heartbeat failed
+	failed := newMockBoltServer(t, sofarpc.RESPONSE_STATUS_SERVER_EXCEPTION)
+	defer failed.Close()
+	failedSession := factory.NewSession(nil, &mockHost{
+		addr: failed.listener.Addr().String(),
+	})
+	if failedSession.CheckHealth() {
+		t.Error("sofarpc heartbeat response failed, but returns ok")
+	}
+	// unknown protocol code
+	if session := factory.NewSession(map[string]interface{}{"protocol_code": 100}, host); session != nil {
+		t.Error("create sofarpc session with unknown protocol code, but returns a session")
+	}
+}
+
+func TestSofaRPCSessionTimeout(t *testing.T) {
+	// a server never response
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("listen failed", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	session := (&SofaRPCSessionFactory{}).NewSession(nil, &mockHost{
+		addr: ln.Addr().String(),
+	})
+	result := make(chan bool)
+	go func() {
+		result <- session.CheckHealth()
+	}()
+	for {
+		session.OnTimeout()
+		select {
+		case healthy := <-result:
+			if healthy {
+				t.Error("sofarpc check health timeout, but returns ok")
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -49,3 +49,26 @@ func newHealthCheckStats(namespace string) *healthCheckStats {
 		healthy:        m.Gauge(metrics.HealthCheckHealthy),
 	}
 }
+
+// hostHealthCheckStats records a host's health check status
+type hostHealthCheckStats struct {
+	attempt        gometrics.Counter
+	success        gometrics.Counter
+	failure        gometrics.Counter
+	activeFailure  gometrics.Counter
+	networkFailure gometrics.Counter
+	// healthy is 1 if the host is healthy, or 0
+	healthy gometrics.Gauge
+}
+
+func newHostHealthCheckStats(namespace string, host string) *hostHealthCheckStats {
+	m := metrics.NewHostHealthStats(namespace, host)
+	return &hostHealthCheckStats{
+		attempt:        m.Counter(metrics.HealthCheckAttempt),
+		success:        m.Counter(metrics.HealthCheckSuccess),
+		failure:        m.Counter(metrics.HealthCheckFailure),
+		activeFailure:  m.Counter(metrics.HealthCheckActiveFailure),
+		networkFailure: m.Counter(metrics.HealthCheckNetworkFailure),
+		healthy:        m.Gauge(metrics.HealthCheckHealthy),
+	}
+}
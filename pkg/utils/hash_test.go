@@ -0,0 +1,33 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import "testing"
+
+func TestHashString(t *testing.T) {
+	if HashString("127.0.0.1:8080") != HashString("127.0.0.1:8080") {
+		t.Fatal("hash of same string should be equal")
+	}
+	if HashString("127.0.0.1:8080_1") == HashString("127.0.0.1:8080_2") {
+		t.Fatal("hash of different string should not be equal")
+	}
+	h1, h2 := HashString("a"), HashString("b")
+	if CombineHash(h1, h2) == CombineHash(h2, h1) {
+		t.Fatal("combine hash should be order sensitive")
+	}
+}
@@ -20,7 +20,9 @@ package conv
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
@@ -77,11 +79,12 @@ func ConvertListenerConfig(xdsListener *xdsapi.Listener) *v2.Listener {
 
 	listenerConfig := &v2.Listener{
 		ListenerConfig: v2.ListenerConfig{
-			Name:           xdsListener.GetName(),
-			BindToPort:     convertBindToPort(xdsListener.GetDeprecatedV1()),
-			Inspector:      true,
-			UseOriginalDst: xdsListener.GetUseOriginalDst().GetValue(),
-			AccessLogs:     convertAccessLogs(xdsListener),
+			Name:             xdsListener.GetName(),
+			BindToPort:       convertBindToPort(xdsListener.GetDeprecatedV1()),
+			Inspector:        true,
+			UseOriginalDst:   xdsListener.GetUseOriginalDst().GetValue(),
+			UseProxyProtocol: convertUseProxyProtocol(xdsListener),
+			AccessLogs:       convertAccessLogs(xdsListener),
 		},
 		Addr: convertAddress(&xdsListener.Address),
 		PerConnBufferLimitBytes: xdsListener.GetPerConnectionBufferLimitBytes().GetValue(),
@@ -115,13 +118,13 @@ func ConvertClustersConfig(xdsClusters []*xdsapi.Cluster) []*v2.Cluster {
 		cluster := &v2.Cluster{
 			Name:                 xdsCluster.GetName(),
 			ClusterType:          convertClusterType(xdsCluster.GetType()),
-			LbType:               convertLbPolicy(xdsCluster.GetLbPolicy()),
+			LbType:               convertClusterLbType(xdsCluster),
 			LBSubSetConfig:       convertLbSubSetConfig(xdsCluster.GetLbSubsetConfig()),
 			MaxRequestPerConn:    xdsCluster.GetMaxRequestsPerConnection().GetValue(),
 			ConnBufferLimitBytes: xdsCluster.GetPerConnectionBufferLimitBytes().GetValue(),
 			HealthCheck:          convertHealthChecks(xdsCluster.GetHealthChecks()),
 			CirBreThresholds:     convertCircuitBreakers(xdsCluster.GetCircuitBreakers()),
-			//OutlierDetection:     convertOutlierDetection(xdsCluster.GetOutlierDetection()),
+			OutlierDetection:     convertOutlierDetection(xdsCluster.GetOutlierDetection()),
 			Hosts: convertClusterHosts(xdsCluster.GetHosts()),
 			Spec:  convertSpec(xdsCluster),
 			TLS:   convertTLS(xdsCluster.GetTlsContext()),
@@ -138,6 +141,8 @@ func ConvertEndpointsConfig(xdsEndpoint *xdsendpoint.LocalityLbEndpoints) []v2.H
 		return nil
 	}
 	hosts := make([]v2.Host, 0, len(xdsEndpoint.GetLbEndpoints()))
+	locality := convertLocality(xdsEndpoint.GetLocality())
+	localityWeight := xdsEndpoint.GetLoadBalancingWeight().GetValue()
 	for _, xdsHost := range xdsEndpoint.GetLbEndpoints() {
 		var address string
 		if xdsAddress, ok := xdsHost.GetEndpoint().GetAddress().GetAddress().(*xdscore.Address_SocketAddress); ok {
@@ -158,7 +163,10 @@ func ConvertEndpointsConfig(xdsEndpoint *xdsendpoint.LocalityLbEndpoints) []v2.H
 		}
 		host := v2.Host{
 			HostConfig: v2.HostConfig{
-				Address: address,
+				Address:        address,
+				Locality:       locality,
+				Priority:       xdsEndpoint.GetPriority(),
+				LocalityWeight: localityWeight,
 			},
 			MetaData: convertMeta(xdsHost.Metadata),
 		}
@@ -167,6 +175,8 @@ func ConvertEndpointsConfig(xdsEndpoint *xdsendpoint.LocalityLbEndpoints) []v2.H
 			host.Weight = config.MinHostWeight
 		} else if weight > config.MaxHostWeight {
 			host.Weight = config.MaxHostWeight
+		} else {
+			host.Weight = weight
 		}
 
 		hosts = append(hosts, host)
@@ -367,6 +377,17 @@ func convertIstioPercentage(percent *xdstype.FractionalPercent) uint32 {
 	return percent.Numerator
 }
 
+// convertFractionalPercent converts the fractional percent to a percentage without losing the precision
+func convertFractionalPercent(percent *xdstype.FractionalPercent) float64 {
+	switch percent.Denominator {
+	case xdstype.FractionalPercent_MILLION:
+		return float64(percent.Numerator) / 10000
+	case xdstype.FractionalPercent_TEN_THOUSAND:
+		return float64(percent.Numerator) / 100
+	}
+	return float64(percent.Numerator)
+}
+
 func makeJsonMap(v interface{}) (map[string]interface{}, error) {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -401,6 +422,22 @@ func convertMixerConfig(s *types.Struct) (map[string]interface{}, error) {
 	return config, nil
 }
 
+// convertUseProxyProtocol returns true if the proxy protocol listener filter is configured,
+// or the deprecated use_proxy_proto in filter chains is set
+func convertUseProxyProtocol(xdsListener *xdsapi.Listener) bool {
+	for _, lf := range xdsListener.GetListenerFilters() {
+		if lf.GetName() == xdsutil.ProxyProtocol {
+			return true
+		}
+	}
+	for _, fc := range xdsListener.GetFilterChains() {
+		if fc.GetUseProxyProto().GetValue() {
+			return true
+		}
+	}
+	return false
+}
+
 func convertFilterChains(xdsFilterChains []xdslistener.FilterChain) []v2.FilterChain {
 	if xdsFilterChains == nil {
 		return nil
@@ -412,6 +449,7 @@ func convertFilterChains(xdsFilterChains []xdslistener.FilterChain) []v2.FilterC
 		filterChain := v2.FilterChain{
 			FilterChainConfig: v2.FilterChainConfig{
 				FilterChainMatch: xdsFilterChain.GetFilterChainMatch().String(),
+				Match:            convertFilterChainMatch(xdsFilterChain.GetFilterChainMatch()),
 				Filters:          convertFilters(xdsFilterChain.GetFilters()),
 				TLSConfig:        &tlsConfig,
 			},
@@ -419,11 +457,41 @@ func convertFilterChains(xdsFilterChains []xdslistener.FilterChain) []v2.FilterC
 				tlsConfig,
 			},
 		}
+		// multiple filter chains have their own stream filters
+		if len(xdsFilterChains) > 1 && len(xdsFilterChain.GetFilters()) > 0 {
+			filterChain.StreamFilters = convertStreamFilters(&xdsFilterChain.Filters[0])
+		}
 		filterChains = append(filterChains, filterChain)
 	}
 	return filterChains
 }
 
+func convertFilterChainMatch(xdsMatch *xdslistener.FilterChainMatch) *v2.FilterChainMatchConfig {
+	if xdsMatch == nil {
+		return nil
+	}
+	match := &v2.FilterChainMatchConfig{
+		DestinationPort:      xdsMatch.GetDestinationPort().GetValue(),
+		PrefixRanges:         convertPrefixRanges(xdsMatch.GetPrefixRanges()),
+		SourcePrefixRanges:   convertPrefixRanges(xdsMatch.GetSourcePrefixRanges()),
+		ServerNames:          xdsMatch.GetServerNames(),
+		TransportProtocol:    xdsMatch.GetTransportProtocol(),
+		ApplicationProtocols: xdsMatch.GetApplicationProtocols(),
+	}
+	return match
+}
+
+func convertPrefixRanges(xdsRanges []*xdscore.CidrRange) []string {
+	if xdsRanges == nil {
+		return nil
+	}
+	ranges := make([]string, 0, len(xdsRanges))
+	for _, r := range xdsRanges {
+		ranges = append(ranges, fmt.Sprintf("%s/%d", r.GetAddressPrefix(), r.GetPrefixLen().GetValue()))
+	}
+	return ranges
+}
+
 func convertFilters(xdsFilters []xdslistener.Filter) []v2.Filter {
 	if xdsFilters == nil {
 		return nil
@@ -638,7 +706,7 @@ func convertRoutes(xdsRoutes []xdsroute.Route) []v2.Router {
 			route := v2.Router{
 				RouterConfig: v2.RouterConfig{
 					Match: convertRouteMatch(xdsRoute.GetMatch()),
-					//Redirect:  convertRedirectAction(xdsRouteAction),
+					Redirect: convertRedirectAction(xdsRouteAction),
 					//Decorator: v2.Decorator(xdsRoute.GetDecorator().String()),
 				},
 				Metadata: convertMeta(xdsRoute.GetMetadata()),
@@ -760,12 +828,63 @@ func convertRouteAction(xdsRouteAction *xdsroute.RouteAction) v2.RouteAction {
 			RequestHeadersToAdd:     convertHeadersToAdd(xdsRouteAction.GetRequestHeadersToAdd()),
 			ResponseHeadersToAdd:    convertHeadersToAdd(xdsRouteAction.GetResponseHeadersToAdd()),
 			ResponseHeadersToRemove: xdsRouteAction.GetResponseHeadersToRemove(),
+			HashPolicy:              convertHashPolicy(xdsRouteAction.GetHashPolicy()),
+			ShadowPolicy:            convertShadowPolicy(xdsRouteAction.GetRequestMirrorPolicy()),
+			Priority:                convertRoutingPriority(xdsRouteAction.GetPriority()),
 		},
 		MetadataMatch: convertMeta(xdsRouteAction.GetMetadataMatch()),
 		Timeout:       convertTimeDurPoint2TimeDur(xdsRouteAction.GetTimeout()),
 	}
 }
 
+func convertHashPolicy(xdsHashPolicy []*xdsroute.RouteAction_HashPolicy) []v2.HashPolicy {
+	if len(xdsHashPolicy) < 1 {
+		return nil
+	}
+	hashPolicy := make([]v2.HashPolicy, 0, len(xdsHashPolicy))
+	for _, policy := range xdsHashPolicy {
+		if header := policy.GetHeader(); header != nil {
+			hashPolicy = append(hashPolicy, v2.HashPolicy{
+				Header: &v2.HeaderHashPolicy{
+					Key: header.GetHeaderName(),
+				},
+			})
+		} else if cookie := policy.GetCookie(); cookie != nil {
+			hashPolicy = append(hashPolicy, v2.HashPolicy{
+				Cookie: &v2.CookieHashPolicy{
+					Name: cookie.GetName(),
+				},
+			})
+		} else if connProps := policy.GetConnectionProperties(); connProps != nil {
+			hashPolicy = append(hashPolicy, v2.HashPolicy{
+				ConnectionProperties: &v2.ConnectionPropertiesHashPolicy{
+					SourceIP: connProps.GetSourceIp(),
+				},
+			})
+		}
+	}
+	return hashPolicy
+}
+
+func convertShadowPolicy(xdsMirrorPolicy *xdsroute.RouteAction_RequestMirrorPolicy) *v2.ShadowPolicy {
+	if xdsMirrorPolicy == nil || xdsMirrorPolicy.GetCluster() == "" {
+		return nil
+	}
+	// the runtime is not supported, only the default fraction is used
+	shadowPolicy := &v2.ShadowPolicy{
+		Cluster: xdsMirrorPolicy.GetCluster(),
+	}
+	if fraction := xdsMirrorPolicy.GetRuntimeFraction(); fraction != nil && fraction.GetDefaultValue() != nil {
+		percent := convertFractionalPercent(fraction.GetDefaultValue())
+		// no request should be mirrored
+		if percent == 0 {
+			return nil
+		}
+		shadowPolicy.Percent = percent
+	}
+	return shadowPolicy
+}
+
 func convertHeadersToAdd(headerValueOption []*xdscore.HeaderValueOption) []*v2.HeaderValueOption {
 	if len(headerValueOption) < 1 {
 		return nil
@@ -827,27 +946,77 @@ func convertRetryPolicy(xdsRetryPolicy *xdsroute.RetryPolicy) *v2.RetryPolicy {
 	if xdsRetryPolicy == nil {
 		return &v2.RetryPolicy{}
 	}
+	conditions, statusCodes := convertRetryConditions(xdsRetryPolicy.GetRetryOn(), xdsRetryPolicy.GetRetriableStatusCodes())
 	return &v2.RetryPolicy{
 		RetryPolicyConfig: v2.RetryPolicyConfig{
-			RetryOn:    len(xdsRetryPolicy.GetRetryOn()) > 0,
-			NumRetries: xdsRetryPolicy.GetNumRetries().GetValue(),
+			RetryOn:                       len(conditions) > 0,
+			NumRetries:                    xdsRetryPolicy.GetNumRetries().GetValue(),
+			RetryConditions:               conditions,
+			RetriableStatusCodes:          statusCodes,
+			HostSelectionRetryMaxAttempts: uint32(xdsRetryPolicy.GetHostSelectionRetryMaxAttempts()),
 		},
 		RetryTimeout: convertTimeDurPoint2TimeDur(xdsRetryPolicy.GetPerTryTimeout()),
 	}
 }
 
-/*
-func convertRedirectAction(xdsRedirectAction *xdsroute.RedirectAction) v2.RedirectAction {
+// unsupportedRetryConditions records the unsupported xDS retry conditions have been warned
+var unsupportedRetryConditions sync.Map
+
+// convertRetryConditions converts the xDS retry_on to the retry conditions,
+// retriable-4xx is converted to the retriable status code 409, same as envoy.
+// the unsupported conditions are ignored.
+func convertRetryConditions(retryOn string, statusCodes []uint32) ([]v2.RetryCondition, []uint32) {
+	var conditions []v2.RetryCondition
+	for _, cond := range strings.Split(retryOn, ",") {
+		switch cond = strings.TrimSpace(cond); cond {
+		case "":
+		case string(v2.RETRY_ON_5XX), string(v2.RETRY_ON_GATEWAY_ERROR), string(v2.RETRY_ON_CONNECT_FAILURE),
+			string(v2.RETRY_ON_REFUSED_STREAM), string(v2.RETRY_ON_RETRIABLE_STATUS_CODES), string(v2.RETRY_ON_RESET):
+			conditions = append(conditions, v2.RetryCondition(cond))
+		case "retriable-4xx":
+			conditions = append(conditions, v2.RETRY_ON_RETRIABLE_STATUS_CODES)
+			statusCodes = append(statusCodes, http.StatusConflict)
+		default:
+			if _, warned := unsupportedRetryConditions.LoadOrStore(cond, struct{}{}); !warned {
+				log.DefaultLogger.Warnf("unsupported retry condition %s is ignored", cond)
+			}
+		}
+	}
+	return conditions, statusCodes
+}
+
+func convertRedirectAction(xdsRedirectAction *xdsroute.RedirectAction) *v2.RedirectAction {
 	if xdsRedirectAction == nil {
-		return v2.RedirectAction{}
+		return nil
 	}
-	return v2.RedirectAction{
-		HostRedirect: xdsRedirectAction.GetHostRedirect(),
-		PathRedirect: xdsRedirectAction.GetPathRedirect(),
-		ResponseCode: uint32(xdsRedirectAction.GetResponseCode()),
+	scheme := xdsRedirectAction.GetSchemeRedirect()
+	if xdsRedirectAction.GetHttpsRedirect() {
+		scheme = "https"
+	}
+	return &v2.RedirectAction{
+		ResponseCode:   convertRedirectResponseCode(xdsRedirectAction.GetResponseCode()),
+		SchemeRedirect: scheme,
+		HostRedirect:   xdsRedirectAction.GetHostRedirect(),
+		PathRedirect:   xdsRedirectAction.GetPathRedirect(),
+		PrefixRewrite:  xdsRedirectAction.GetPrefixRewrite(),
+		StripQuery:     xdsRedirectAction.GetStripQuery(),
+	}
+}
+
+func convertRedirectResponseCode(code xdsroute.RedirectAction_RedirectResponseCode) int {
+	switch code {
+	case xdsroute.RedirectAction_FOUND:
+		return http.StatusFound
+	case xdsroute.RedirectAction_SEE_OTHER:
+		return http.StatusSeeOther
+	case xdsroute.RedirectAction_TEMPORARY_REDIRECT:
+		return http.StatusTemporaryRedirect
+	case xdsroute.RedirectAction_PERMANENT_REDIRECT:
+		return http.StatusPermanentRedirect
+	default:
+		return http.StatusMovedPermanently
 	}
 }
-*/
 
 /*
 func convertVirtualClusters(xdsVirtualClusters []*xdsroute.VirtualCluster) []v2.VirtualCluster {
@@ -906,16 +1075,40 @@ func convertClusterType(xdsClusterType xdsapi.Cluster_DiscoveryType) v2.ClusterT
 	return v2.SIMPLE_CLUSTER
 }
 
+func convertLocality(xdsLocality *xdscore.Locality) *v2.Locality {
+	if xdsLocality == nil {
+		return nil
+	}
+	return &v2.Locality{
+		Region:  xdsLocality.GetRegion(),
+		Zone:    xdsLocality.GetZone(),
+		SubZone: xdsLocality.GetSubZone(),
+	}
+}
+
+// convertClusterLbType returns the locality aware load balancer if the cluster enables
+// the zone aware or locality weighted load balancing
+func convertClusterLbType(xdsCluster *xdsapi.Cluster) v2.LbType {
+	commonLbConfig := xdsCluster.GetCommonLbConfig()
+	if commonLbConfig.GetZoneAwareLbConfig() != nil || commonLbConfig.GetLocalityWeightedLbConfig() != nil {
+		return v2.LB_LOCALITY_AWARE
+	}
+	return convertLbPolicy(xdsCluster.GetLbPolicy())
+}
+
 func convertLbPolicy(xdsLbPolicy xdsapi.Cluster_LbPolicy) v2.LbType {
 	switch xdsLbPolicy {
 	case xdsapi.Cluster_ROUND_ROBIN:
 		return v2.LB_ROUNDROBIN
 	case xdsapi.Cluster_LEAST_REQUEST:
+		return v2.LB_LEAST_REQUEST
 	case xdsapi.Cluster_RING_HASH:
+		return v2.LB_RING_HASH
 	case xdsapi.Cluster_RANDOM:
 		return v2.LB_RANDOM
 	case xdsapi.Cluster_ORIGINAL_DST_LB:
 	case xdsapi.Cluster_MAGLEV:
+		return v2.LB_MAGLEV
 	}
 	//log.DefaultLogger.Fatalf("unsupported lb policy: %s, exchange to LB_RANDOM", xdsLbPolicy.String())
 	return v2.LB_RANDOM
@@ -980,6 +1173,7 @@ func convertCircuitBreakers(xdsCircuitBreaker *xdscluster.CircuitBreakers) v2.Ci
 			continue
 		}
 		threshold := v2.Thresholds{
+			Priority:           convertRoutingPriority(xdsThreshold.GetPriority()),
 			MaxConnections:     xdsThreshold.GetMaxConnections().GetValue(),
 			MaxPendingRequests: xdsThreshold.GetMaxPendingRequests().GetValue(),
 			MaxRequests:        xdsThreshold.GetMaxRequests().GetValue(),
@@ -992,26 +1186,31 @@ func convertCircuitBreakers(xdsCircuitBreaker *xdscluster.CircuitBreakers) v2.Ci
 	}
 }
 
-/*
-func convertOutlierDetection(xdsOutlierDetection *xdscluster.OutlierDetection) v2.OutlierDetection {
+func convertRoutingPriority(xdsPriority xdscore.RoutingPriority) v2.RoutingPriority {
+	if xdsPriority == xdscore.RoutingPriority_HIGH {
+		return v2.PRIORITY_HIGH
+	}
+	return v2.PRIORITY_DEFAULT
+}
+
+func convertOutlierDetection(xdsOutlierDetection *xdscluster.OutlierDetection) *v2.OutlierDetection {
 	if xdsOutlierDetection == nil || xdsOutlierDetection.Size() == 0 {
-		return v2.OutlierDetection{}
-	}
-	return v2.OutlierDetection{
-		Consecutive5xx:                     xdsOutlierDetection.GetConsecutive_5Xx().GetValue(),
-		Interval:                           convertDuration(xdsOutlierDetection.GetInterval()),
-		BaseEjectionTime:                   convertDuration(xdsOutlierDetection.GetBaseEjectionTime()),
-		MaxEjectionPercent:                 xdsOutlierDetection.GetMaxEjectionPercent().GetValue(),
-		ConsecutiveGatewayFailure:          xdsOutlierDetection.GetEnforcingConsecutive_5Xx().GetValue(),
-		EnforcingConsecutive5xx:            xdsOutlierDetection.GetConsecutive_5Xx().GetValue(),
-		EnforcingConsecutiveGatewayFailure: xdsOutlierDetection.GetEnforcingConsecutiveGatewayFailure().GetValue(),
-		EnforcingSuccessRate:               xdsOutlierDetection.GetEnforcingSuccessRate().GetValue(),
-		SuccessRateMinimumHosts:            xdsOutlierDetection.GetSuccessRateMinimumHosts().GetValue(),
-		SuccessRateRequestVolume:           xdsOutlierDetection.GetSuccessRateRequestVolume().GetValue(),
-		SuccessRateStdevFactor:             xdsOutlierDetection.GetSuccessRateStdevFactor().GetValue(),
+		return nil
+	}
+	// the xDS outlier detection has no consecutive connect failure and max ejection time,
+	// they are left unset and the outlier detector's defaults are used.
+	return &v2.OutlierDetection{
+		OutlierDetectionConfig: v2.OutlierDetectionConfig{
+			Consecutive5xx:           xdsOutlierDetection.GetConsecutive_5Xx().GetValue(),
+			MaxEjectionPercent:       xdsOutlierDetection.GetMaxEjectionPercent().GetValue(),
+			SuccessRateMinimumHosts:  xdsOutlierDetection.GetSuccessRateMinimumHosts().GetValue(),
+			SuccessRateRequestVolume: xdsOutlierDetection.GetSuccessRateRequestVolume().GetValue(),
+			SuccessRateStdevFactor:   xdsOutlierDetection.GetSuccessRateStdevFactor().GetValue(),
+		},
+		Interval:         convertDuration(xdsOutlierDetection.GetInterval()),
+		BaseEjectionTime: convertDuration(xdsOutlierDetection.GetBaseEjectionTime()),
 	}
 }
-*/
 
 func convertSpec(xdsCluster *xdsapi.Cluster) v2.ClusterSpecInfo {
 	if xdsCluster == nil || xdsCluster.GetEdsClusterConfig() == nil {
@@ -35,6 +35,7 @@ import (
 	"sofastack.io/sofa-mosn/pkg/upstream/cluster"
 
 	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	xdscluster "github.com/envoyproxy/go-control-plane/envoy/api/v2/cluster"
 	xdscore "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	xdsendpoint "github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
 	xdslistener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
@@ -84,6 +85,52 @@ func Test_convertEndpointsConfig(t *testing.T) {
 			},
 			want: []v2.Host{},
 		},
+		{
+			name: "locality",
+			args: args{
+				xdsEndpoint: &xdsendpoint.LocalityLbEndpoints{
+					Locality: &core.Locality{
+						Region: "region",
+						Zone:   "zone",
+					},
+					LbEndpoints: []xdsendpoint.LbEndpoint{
+						{
+							HostIdentifier: &xdsendpoint.LbEndpoint_Endpoint{
+								Endpoint: &xdsendpoint.Endpoint{
+									Address: &core.Address{
+										Address: &core.Address_SocketAddress{
+											SocketAddress: &core.SocketAddress{
+												Address: "127.0.0.1",
+												PortSpecifier: &core.SocketAddress_PortValue{
+													PortValue: 8080,
+												},
+											},
+										},
+									},
+								},
+							},
+							LoadBalancingWeight: &types.UInt32Value{Value: 50},
+						},
+					},
+					LoadBalancingWeight: &types.UInt32Value{Value: 10},
+					Priority:            1,
+				},
+			},
+			want: []v2.Host{
+				{
+					HostConfig: v2.HostConfig{
+						Address: "127.0.0.1:8080",
+						Weight:  50,
+						Locality: &v2.Locality{
+							Region: "region",
+							Zone:   "zone",
+						},
+						Priority:       1,
+						LocalityWeight: 10,
+					},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -420,6 +467,67 @@ func Test_convertCidrRange(t *testing.T) {
 	}
 }
 
+func Test_convertFilterChainMatch(t *testing.T) {
+	xdsMatch := &xdslistener.FilterChainMatch{
+		DestinationPort: &google_protobuf1.UInt32Value{Value: 443},
+		PrefixRanges: []*xdscore.CidrRange{
+			{
+				AddressPrefix: "10.0.0.0",
+				PrefixLen:     &google_protobuf1.UInt32Value{Value: 8},
+			},
+		},
+		SourcePrefixRanges: []*xdscore.CidrRange{
+			{
+				AddressPrefix: "192.168.1.1",
+				PrefixLen:     &google_protobuf1.UInt32Value{Value: 32},
+			},
+		},
+		ServerNames:          []string{"*.example.com"},
+		TransportProtocol:    "tls",
+		ApplicationProtocols: []string{"h2"},
+	}
+	want := &v2.FilterChainMatchConfig{
+		DestinationPort:      443,
+		PrefixRanges:         []string{"10.0.0.0/8"},
+		SourcePrefixRanges:   []string{"192.168.1.1/32"},
+		ServerNames:          []string{"*.example.com"},
+		TransportProtocol:    "tls",
+		ApplicationProtocols: []string{"h2"},
+	}
+	if got := convertFilterChainMatch(xdsMatch); !reflect.DeepEqual(got, want) {
+		t.Errorf("convertFilterChainMatch() = %+v, want %+v", got, want)
+	}
+	if got := convertFilterChainMatch(nil); got != nil {
+		t.Errorf("convertFilterChainMatch(nil) = %+v, want nil", got)
+	}
+}
+
+func Test_convertUseProxyProtocol(t *testing.T) {
+	listener := &xdsapi.Listener{
+		ListenerFilters: []xdslistener.ListenerFilter{
+			{
+				Name: xdsutil.ProxyProtocol,
+			},
+		},
+	}
+	if !convertUseProxyProtocol(listener) {
+		t.Error("proxy protocol listener filter should be converted")
+	}
+	listener = &xdsapi.Listener{
+		FilterChains: []xdslistener.FilterChain{
+			{
+				UseProxyProto: NewBoolValue(true),
+			},
+		},
+	}
+	if !convertUseProxyProtocol(listener) {
+		t.Error("use proxy proto should be converted")
+	}
+	if convertUseProxyProtocol(&xdsapi.Listener{}) {
+		t.Error("proxy protocol should not be used")
+	}
+}
+
 func Test_convertTCPRoute(t *testing.T) {
 	type args struct {
 		deprecatedV1 *xdstcp.TcpProxy_DeprecatedV1
@@ -739,3 +847,108 @@ func Test_convertPerRouteConfig(t *testing.T) {
 	}
 
 }
+
+func Test_convertLbPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy xdsapi.Cluster_LbPolicy
+		want   v2.LbType
+	}{
+		{"round robin", xdsapi.Cluster_ROUND_ROBIN, v2.LB_ROUNDROBIN},
+		{"least request", xdsapi.Cluster_LEAST_REQUEST, v2.LB_LEAST_REQUEST},
+		{"ring hash", xdsapi.Cluster_RING_HASH, v2.LB_RING_HASH},
+		{"maglev", xdsapi.Cluster_MAGLEV, v2.LB_MAGLEV},
+		{"random", xdsapi.Cluster_RANDOM, v2.LB_RANDOM},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertLbPolicy(tt.policy); got != tt.want {
+				t.Errorf("convertLbPolicy(%v) = %v, want %v", tt.policy, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_convertOutlierDetection(t *testing.T) {
+	if convertOutlierDetection(nil) != nil {
+		t.Error("nil outlier detection should be converted to nil")
+	}
+	xdsOutlierDetection := &xdscluster.OutlierDetection{
+		Consecutive_5Xx:           &google_protobuf1.UInt32Value{Value: 5},
+		ConsecutiveGatewayFailure: &google_protobuf1.UInt32Value{Value: 3},
+		Interval:                  &google_protobuf1.Duration{Seconds: 10},
+		BaseEjectionTime:          &google_protobuf1.Duration{Seconds: 30},
+		MaxEjectionPercent:        &google_protobuf1.UInt32Value{Value: 20},
+		SuccessRateMinimumHosts:   &google_protobuf1.UInt32Value{Value: 5},
+		SuccessRateRequestVolume:  &google_protobuf1.UInt32Value{Value: 100},
+		SuccessRateStdevFactor:    &google_protobuf1.UInt32Value{Value: 1900},
+	}
+	want := &v2.OutlierDetection{
+		OutlierDetectionConfig: v2.OutlierDetectionConfig{
+			Consecutive5xx:           5,
+			MaxEjectionPercent:       20,
+			SuccessRateMinimumHosts:  5,
+			SuccessRateRequestVolume: 100,
+			SuccessRateStdevFactor:   1900,
+		},
+		Interval:         10 * time.Second,
+		BaseEjectionTime: 30 * time.Second,
+	}
+	if got := convertOutlierDetection(xdsOutlierDetection); !reflect.DeepEqual(got, want) {
+		t.Errorf("convertOutlierDetection() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_convertShadowPolicy(t *testing.T) {
+	mirror := func(numerator uint32, denominator xdstype.FractionalPercent_DenominatorType) *xdsroute.RouteAction_RequestMirrorPolicy {
+		return &xdsroute.RouteAction_RequestMirrorPolicy{
+			Cluster: "shadow",
+			RuntimeFraction: &xdscore.RuntimeFractionalPercent{
+				DefaultValue: &xdstype.FractionalPercent{
+					Numerator:   numerator,
+					Denominator: denominator,
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name   string
+		policy *xdsroute.RouteAction_RequestMirrorPolicy
+		want   *v2.ShadowPolicy
+	}{
+		{"no policy", nil, nil},
+		{"all", &xdsroute.RouteAction_RequestMirrorPolicy{Cluster: "shadow"}, &v2.ShadowPolicy{Cluster: "shadow"}},
+		{"hundred", mirror(30, xdstype.FractionalPercent_HUNDRED), &v2.ShadowPolicy{Cluster: "shadow", Percent: 30}},
+		{"ten thousand", mirror(50, xdstype.FractionalPercent_TEN_THOUSAND), &v2.ShadowPolicy{Cluster: "shadow", Percent: 0.5}},
+		{"million", mirror(100, xdstype.FractionalPercent_MILLION), &v2.ShadowPolicy{Cluster: "shadow", Percent: 0.01}},
+		{"none", mirror(0, xdstype.FractionalPercent_HUNDRED), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertShadowPolicy(tt.policy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertShadowPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_convertRetryPolicy(t *testing.T) {
+	policy := convertRetryPolicy(&xdsroute.RetryPolicy{
+		RetryOn:              "5xx,reset,retriable-4xx,unknown-condition",
+		NumRetries:           &google_protobuf1.UInt32Value{Value: 3},
+		RetriableStatusCodes: []uint32{429},
+	})
+	want := v2.RetryPolicyConfig{
+		RetryOn:              true,
+		NumRetries:           3,
+		RetryConditions:      []v2.RetryCondition{v2.RETRY_ON_5XX, v2.RETRY_ON_RESET, v2.RETRY_ON_RETRIABLE_STATUS_CODES},
+		RetriableStatusCodes: []uint32{429, 409},
+	}
+	if !reflect.DeepEqual(policy.RetryPolicyConfig, want) {
+		t.Errorf("convertRetryPolicy() = %+v, want %+v", policy.RetryPolicyConfig, want)
+	}
+	// no supported condition
+	if policy := convertRetryPolicy(&xdsroute.RetryPolicy{RetryOn: "unknown-condition"}); policy.RetryOn {
+		t.Error("retry should be off without supported conditions")
+	}
+}
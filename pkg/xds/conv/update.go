@@ -69,9 +69,9 @@ func ConvertAddOrUpdateListeners(listeners []*envoy_api_v2.Listener) {
 		var networkFilters []types.NetworkFilterChainFactory
 
 		if !mosnListener.UseOriginalDst {
-			for _, filterChain := range mosnListener.FilterChains {
-				nf := config.GetNetworkFilters(&filterChain)
-				networkFilters = append(networkFilters, nf...)
+			// network filters of other filter chains are created by the listener
+			if len(mosnListener.FilterChains) > 0 {
+				networkFilters = config.GetNetworkFilters(&mosnListener.FilterChains[0])
 			}
 			streamFilters = config.GetStreamFilters(mosnListener.StreamFilters)
 
@@ -179,26 +179,30 @@ func ConvertUpdateEndpoints(loadAssignments []*envoy_api_v2.ClusterLoadAssignmen
 	for _, loadAssignment := range loadAssignments {
 		clusterName := loadAssignment.ClusterName
 
+		// all the localities' hosts belong to the same cluster, update them at once
+		var hosts []v2.Host
 		for _, endpoints := range loadAssignment.Endpoints {
-			hosts := ConvertEndpointsConfig(&endpoints)
+			localityHosts := ConvertEndpointsConfig(&endpoints)
 			log.DefaultLogger.Debugf("xds client update endpoints: cluster: %s, priority: %d", loadAssignment.ClusterName, endpoints.Priority)
-			for index, host := range hosts {
+			for index, host := range localityHosts {
 				log.DefaultLogger.Debugf("host[%d] is : %+v", index, host)
 			}
+			hosts = append(hosts, localityHosts...)
+		}
 
-			clusterMngAdapter := clusterAdapter.GetClusterMngAdapterInstance()
-			if clusterMngAdapter == nil {
-				log.DefaultLogger.Errorf("xds client update Error: clusterMngAdapter nil , hosts are %+v", hosts)
-				errGlobal = fmt.Errorf("xds client update Error: clusterMngAdapter nil , hosts are %+v", hosts)
-			}
+		clusterMngAdapter := clusterAdapter.GetClusterMngAdapterInstance()
+		if clusterMngAdapter == nil {
+			log.DefaultLogger.Errorf("xds client update Error: clusterMngAdapter nil , hosts are %+v", hosts)
+			errGlobal = fmt.Errorf("xds client update Error: clusterMngAdapter nil , hosts are %+v", hosts)
+			continue
+		}
 
-			if err := clusterAdapter.GetClusterMngAdapterInstance().TriggerClusterHostUpdate(clusterName, hosts); err != nil {
-				log.DefaultLogger.Errorf("xds client update Error = %s, hosts are %+v", err.Error(), hosts)
-				errGlobal = fmt.Errorf("xds client update Error = %s, hosts are %+v", err.Error(), hosts)
+		if err := clusterMngAdapter.TriggerClusterHostUpdate(clusterName, hosts); err != nil {
+			log.DefaultLogger.Errorf("xds client update Error = %s, hosts are %+v", err.Error(), hosts)
+			errGlobal = fmt.Errorf("xds client update Error = %s, hosts are %+v", err.Error(), hosts)
 
-			} else {
-				log.DefaultLogger.Debugf("xds client update host success,hosts are %+v", hosts)
-			}
+		} else {
+			log.DefaultLogger.Debugf("xds client update host success,hosts are %+v", hosts)
 		}
 	}
 
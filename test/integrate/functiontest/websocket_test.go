@@ -0,0 +1,106 @@
+package functiontest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"sofastack.io/sofa-mosn/pkg/protocol"
+	"sofastack.io/sofa-mosn/test/integrate"
+	"sofastack.io/sofa-mosn/test/util"
+)
+
+// ServeUpgradeEcho accepts the upgrade request, and echoes the raw bytes after the upgrade
+func ServeUpgradeEcho(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		t.Logf("read upgrade request failed: %v", err)
+		return
+	}
+	if req.Header.Get("Upgrade") != "websocket" {
+		conn.Write([]byte("HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n"))
+		return
+	}
+	conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n"))
+	buf := make([]byte, 1024)
+	for {
+		n, err := br.Read(buf)
+		if err != nil {
+			return
+		}
+		conn.Write(buf[:n])
+	}
+}
+
+type WebSocketCase struct {
+	*integrate.TestCase
+}
+
+func (c *WebSocketCase) RunCase(n int, interval int) {
+	call := func() error {
+		conn, err := net.Dial("tcp", c.ClientMeshAddr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		req := "GET /chat HTTP/1.1\r\nHost: mosn\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n"
+		if _, err := conn.Write([]byte(req)); err != nil {
+			return err
+		}
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			return fmt.Errorf("response status: %d", resp.StatusCode)
+		}
+		// the raw bytes are echoed through the tunnel
+		for i := 0; i < 3; i++ {
+			msg := fmt.Sprintf("message %d", i)
+			if _, err := conn.Write([]byte(msg)); err != nil {
+				return err
+			}
+			echo := make([]byte, len(msg))
+			if _, err := io.ReadFull(br, echo); err != nil {
+				return err
+			}
+			if string(echo) != msg {
+				return fmt.Errorf("unexpected echo: %s, expected: %s", echo, msg)
+			}
+		}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		if err := call(); err != nil {
+			c.C <- err
+			return
+		}
+		time.Sleep(time.Duration(interval) * time.Millisecond)
+	}
+	c.C <- nil
+}
+
+func TestWebSocketUpgrade(t *testing.T) {
+	appaddr := "127.0.0.1:8080"
+	tc := &WebSocketCase{integrate.NewTestCase(t, protocol.HTTP1, protocol.HTTP1, util.NewUpstreamServer(t, appaddr, ServeUpgradeEcho))}
+	tc.StartProxy()
+	go tc.RunCase(2, 0)
+	select {
+	case err := <-tc.C:
+		if err != nil {
+			t.Errorf("[ERROR MESSAGE] websocket upgrade test failed, error: %v\n", err)
+		}
+	case <-time.After(15 * time.Second):
+		t.Error("[ERROR MESSAGE] websocket upgrade test hang\n")
+	}
+	tc.FinishCase()
+}